@@ -0,0 +1,498 @@
+package apix
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 管理界面数据浏览 ---------
+//
+// 挂载 REST 时可同时挂载一个内嵌的数据浏览页面，按表分页浏览、过滤、排序，并可直接编辑单元格、新增与删除记录：
+//
+//	h, err := apix.New("./cfgs").
+//		WithRest(apix.RestOptions{}).
+//		WithAdminUI(apix.AdminUIOptions{}). // 默认挂载在 /admin
+//		Mount(router)
+//
+//	GET /api/admin/browser   # 页面使用的表清单：当前调用者可 list 的表、字段与可执行的写操作
+//
+// 页面中的读写全部通过 REST 接口完成，与直接调用接口一样受 operation_roles、只读库、维护模式等限制；
+// 页面顶部可填写随请求发送的请求头（如 Authorization 或 auth.roles_header），保存在浏览器本地。
+// 过滤框接受与列表接口相同的过滤参数（如 age__gte=18&_or=status:1,status:2），列头输入框为等值过滤，
+// 以 ~ 开头时为 __icontains。字段信息来自生成的 swagger.yaml，只读字段与主键不能编辑。
+
+type AdminUIOptions struct {
+	Prefix string
+}
+
+func (b *Builder) WithAdminUI(opts AdminUIOptions) *Builder {
+	if opts.Prefix == "" {
+		opts.Prefix = defaultAdminUIPrefix
+	}
+	b.adminUI = &opts
+	return b
+}
+
+// browserTable 页面使用的表信息
+type browserTable struct {
+	Database   string          `json:"database"`
+	Table      string          `json:"table"`
+	Comment    string          `json:"comment,omitempty"`
+	PrimaryKey string          `json:"primary_key"`
+	Fields     []browserField  `json:"fields"`
+	Operations map[string]bool `json:"operations"` // create / update / delete 是否可用
+}
+
+type browserField struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	ReadOnly bool          `json:"read_only,omitempty"`
+	Nullable bool          `json:"nullable,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+}
+
+func mountAdminUI(router *gin.Engine, prefix, restPrefix, browserEndpoint string) {
+	html := strings.NewReplacer("__REST_PREFIX__", restPrefix, "__BROWSER_ENDPOINT__", browserEndpoint).Replace(adminUIHTML)
+	serve := func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.String(http.StatusOK, html)
+	}
+	router.GET(prefix, serve)
+	if prefix != "/" {
+		router.GET(strings.TrimSuffix(prefix, "/")+"/", serve)
+	}
+}
+
+// handleBrowserTables 返回当前调用者可浏览的表，不可 list 的表不出现在清单中
+func (dm *databaseManager) handleBrowserTables(c *gin.Context) {
+	dm.mutex.RLock()
+	aliases := make([]string, 0, len(dm.config.Databases))
+	for alias := range dm.config.Databases {
+		aliases = append(aliases, alias)
+	}
+	dm.mutex.RUnlock()
+	sort.Strings(aliases)
+	tables := []browserTable{}
+	for _, alias := range aliases {
+		dm.mutex.RLock()
+		dbConf := dm.config.Databases[alias]
+		adapter := dm.adapters[alias]
+		dm.mutex.RUnlock()
+		readOnly := false
+		if ro, ok := adapter.(readOnlyAdapter); ok && ro.ReadOnly() {
+			readOnly = true
+		}
+		// swagger.yaml 不存在时（如刚新增的库）只返回表名，页面按返回的记录推断列
+		doc, _ := dm.browserSpec(alias)
+		for i := range dbConf.Tables {
+			tc := &dbConf.Tables[i]
+			if !dm.allowed(c, tc, opList) {
+				continue
+			}
+			writable := !readOnly && !tc.isRollup()
+			t := browserTable{
+				Database:   alias,
+				Table:      tc.Alias,
+				PrimaryKey: tc.apiFieldName(tc.PrimaryKey),
+				Fields:     []browserField{},
+				Operations: map[string]bool{
+					opCreate: writable && dm.allowed(c, tc, opCreate),
+					opUpdate: writable && tc.PrimaryKey != "" && dm.allowed(c, tc, opUpdate),
+					opDelete: writable && tc.PrimaryKey != "" && dm.allowed(c, tc, opDelete),
+				},
+			}
+			if doc != nil {
+				if schema := doc.resolve(doc.Components.Schemas[tc.Alias]); schema != nil {
+					t.Comment = schema.Description
+					t.Fields = browserFields(schema, t.PrimaryKey)
+				}
+			}
+			tables = append(tables, t)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"rest_prefix": dm.restPrefix, "tables": tables})
+}
+
+func (dm *databaseManager) browserSpec(alias string) (*openapiDoc, error) {
+	if dm.contract != nil {
+		return dm.contract.spec(dm.configDir, alias)
+	}
+	file, err := swaggerSpecFile(dm.configDir, alias)
+	if err != nil {
+		return nil, err
+	}
+	return readOpenAPIFile(file)
+}
+
+// browserFields 按主键在前、其余按名称排序返回字段，跳过只写的隐藏字段
+func browserFields(schema *openapiSchema, pk string) []browserField {
+	fields := []browserField{}
+	for name, prop := range schema.Properties {
+		if prop == nil || prop.WriteOnly {
+			continue
+		}
+		fields = append(fields, browserField{
+			Name:     name,
+			Type:     string(prop.Type),
+			ReadOnly: prop.ReadOnly || name == pk,
+			Nullable: prop.Nullable,
+			Enum:     prop.Enum,
+		})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if (fields[i].Name == pk) != (fields[j].Name == pk) {
+			return fields[i].Name == pk
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// 注意 __REST_PREFIX__、__BROWSER_ENDPOINT__ 会被替换为实际路径
+const adminUIHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>ego data browser</title>
+  <style>
+    body { margin: 0; font: 13px/1.4 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; display: flex; height: 100vh; }
+    #side { width: 220px; border-right: 1px solid #ddd; overflow: auto; background: #fafafa; }
+    #side h3 { margin: 12px 12px 4px; font-size: 12px; color: #888; text-transform: uppercase; }
+    #side a { display: block; padding: 3px 16px; color: #222; text-decoration: none; cursor: pointer; }
+    #side a.active, #side a:hover { background: #e6f0ff; }
+    #main { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+    #bar, #pager, #status { padding: 6px 10px; border-bottom: 1px solid #eee; display: flex; gap: 8px; align-items: center; }
+    #status { border: 0; border-top: 1px solid #eee; min-height: 18px; }
+    #status.error { color: #c00; }
+    #filter { flex: 1; }
+    #grid { flex: 1; overflow: auto; }
+    table { border-collapse: collapse; width: max-content; min-width: 100%; }
+    th, td { border: 1px solid #e4e4e4; padding: 3px 6px; text-align: left; max-width: 320px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+    th { background: #f3f3f3; position: sticky; top: 0; cursor: pointer; }
+    th input { width: 100%; box-sizing: border-box; font: inherit; }
+    td.editable:hover { background: #fffbe6; cursor: text; }
+    td.null { color: #aaa; font-style: italic; }
+    td input, td select { width: 100%; box-sizing: border-box; font: inherit; }
+    tr.new td { background: #f0fff0; }
+    button { font: inherit; }
+    #headers { width: 320px; }
+  </style>
+</head>
+<body>
+  <div id="side"></div>
+  <div id="main">
+    <div id="bar">
+      <strong id="title">Select a table</strong>
+      <input id="filter" placeholder="filters, e.g. age__gte=18&amp;_or=status:1,status:2">
+      <button id="apply">Apply</button>
+      <button id="add" disabled>Add row</button>
+      <input id="headers" placeholder='request headers, e.g. {"X-Roles": "admin"}'>
+      <button id="saveHeaders">Save headers</button>
+    </div>
+    <div id="grid"></div>
+    <div id="pager">
+      <button id="prev">&lt;</button><span id="pageInfo"></span><button id="next">&gt;</button>
+      <select id="pageSize"><option>20</option><option selected>50</option><option>100</option><option>200</option></select>
+    </div>
+    <div id="status"></div>
+  </div>
+  <script>
+    const restPrefix = '__REST_PREFIX__';
+    const browserEndpoint = '__BROWSER_ENDPOINT__';
+    const state = { tables: [], table: null, page: 1, total: 0, order: '', columnFilters: {}, rows: [] };
+    const $ = (id) => document.getElementById(id);
+
+    $('headers').value = localStorage.getItem('ego.headers') || '';
+    $('saveHeaders').onclick = () => {
+      try {
+        if ($('headers').value.trim()) JSON.parse($('headers').value);
+        localStorage.setItem('ego.headers', $('headers').value.trim());
+        loadTables();
+      } catch (e) { status('Invalid headers JSON: ' + e.message, true); }
+    };
+
+    function status(msg, isError) {
+      $('status').textContent = msg || '';
+      $('status').className = isError ? 'error' : '';
+    }
+
+    async function api(method, url, body) {
+      const headers = Object.assign({}, JSON.parse(localStorage.getItem('ego.headers') || '{}'));
+      const init = { method, headers };
+      if (body !== undefined) {
+        headers['Content-Type'] = 'application/json';
+        init.body = JSON.stringify(body);
+      }
+      const resp = await fetch(url, init);
+      const text = await resp.text();
+      let data = null;
+      try { data = text ? JSON.parse(text) : null; } catch (e) { data = text; }
+      if (!resp.ok) throw new Error(resp.status + ': ' + (data && data.error ? data.error : text));
+      return data;
+    }
+
+    async function loadTables() {
+      try {
+        const data = await api('GET', browserEndpoint);
+        state.tables = data.tables;
+        renderSide();
+        status(state.tables.length + ' tables');
+      } catch (e) { status(e.message, true); }
+    }
+
+    function renderSide() {
+      const side = $('side');
+      side.innerHTML = '';
+      let db = null;
+      state.tables.forEach((t) => {
+        if (t.database !== db) {
+          db = t.database;
+          const h = document.createElement('h3');
+          h.textContent = db;
+          side.appendChild(h);
+        }
+        const a = document.createElement('a');
+        a.textContent = t.table;
+        a.title = t.comment || '';
+        if (state.table && state.table.database === t.database && state.table.table === t.table) a.className = 'active';
+        a.onclick = () => { selectTable(t); };
+        side.appendChild(a);
+      });
+    }
+
+    function selectTable(t) {
+      state.table = t;
+      state.page = 1;
+      state.order = '';
+      state.columnFilters = {};
+      $('filter').value = '';
+      $('title').textContent = t.database + '.' + t.table;
+      $('add').disabled = !t.operations.create;
+      renderSide();
+      loadRows();
+    }
+
+    function tableURL() {
+      return restPrefix + '/' + encodeURIComponent(state.table.database) + '/' + encodeURIComponent(state.table.table);
+    }
+
+    function listQuery() {
+      const q = new URLSearchParams($('filter').value.trim());
+      Object.keys(state.columnFilters).forEach((name) => {
+        const v = state.columnFilters[name];
+        if (v === '') return;
+        if (v.startsWith('~')) q.set(name + '__icontains', v.slice(1));
+        else q.set(name, v);
+      });
+      q.set('page', state.page);
+      q.set('page_size', $('pageSize').value);
+      if (state.order) q.set('order', state.order);
+      return q.toString();
+    }
+
+    async function loadRows() {
+      if (!state.table) return;
+      try {
+        const data = await api('GET', tableURL() + '?' + listQuery());
+        state.rows = data.data || [];
+        state.total = data.total;
+        renderGrid();
+        status('');
+      } catch (e) { status(e.message, true); }
+    }
+
+    function columns() {
+      const t = state.table;
+      if (t.fields.length) return t.fields;
+      const names = [];
+      state.rows.forEach((r) => Object.keys(r).forEach((k) => { if (!names.includes(k)) names.push(k); }));
+      return names.map((n) => ({ name: n, type: 'string', read_only: n === t.primary_key }));
+    }
+
+    function formatValue(v) {
+      if (v === null || v === undefined) return 'null';
+      return typeof v === 'object' ? JSON.stringify(v) : String(v);
+    }
+
+    function parseValue(field, text) {
+      if (text === '' && field.nullable) return null;
+      switch (field.type) {
+        case 'integer': case 'number': {
+          const n = Number(text);
+          if (text === '' || isNaN(n)) throw new Error(field.name + ' must be a number');
+          return n;
+        }
+        case 'boolean': return text === 'true' || text === '1';
+        case 'object': case 'array': return JSON.parse(text);
+      }
+      return text;
+    }
+
+    function renderGrid() {
+      const t = state.table;
+      const cols = columns();
+      const grid = $('grid');
+      grid.innerHTML = '';
+      const table = document.createElement('table');
+      const head = document.createElement('tr');
+      const filters = document.createElement('tr');
+      cols.forEach((f) => {
+        const th = document.createElement('th');
+        const desc = state.order === '-' + f.name;
+        th.textContent = f.name + (state.order === f.name ? ' ▲' : desc ? ' ▼' : '');
+        th.title = f.type + (f.read_only ? ', read only' : '');
+        th.onclick = () => {
+          state.order = state.order === f.name ? '-' + f.name : f.name;
+          loadRows();
+        };
+        head.appendChild(th);
+        const fth = document.createElement('th');
+        const input = document.createElement('input');
+        input.placeholder = '= or ~';
+        input.value = state.columnFilters[f.name] || '';
+        input.onkeydown = (e) => {
+          if (e.key !== 'Enter') return;
+          state.columnFilters[f.name] = input.value;
+          state.page = 1;
+          loadRows();
+        };
+        fth.appendChild(input);
+        filters.appendChild(fth);
+      });
+      head.appendChild(document.createElement('th'));
+      filters.appendChild(document.createElement('th'));
+      table.appendChild(head);
+      table.appendChild(filters);
+      state.rows.forEach((row) => {
+        const tr = document.createElement('tr');
+        cols.forEach((f) => {
+          const td = document.createElement('td');
+          const v = row[f.name];
+          td.textContent = formatValue(v);
+          td.title = td.textContent;
+          if (v === null || v === undefined) td.className = 'null';
+          if (t.operations.update && !f.read_only) {
+            td.className += ' editable';
+            td.ondblclick = () => editCell(td, row, f);
+          }
+          tr.appendChild(td);
+        });
+        const actions = document.createElement('td');
+        if (t.operations.delete) {
+          const del = document.createElement('button');
+          del.textContent = 'Delete';
+          del.onclick = () => deleteRow(row);
+          actions.appendChild(del);
+        }
+        tr.appendChild(actions);
+        table.appendChild(tr);
+      });
+      grid.appendChild(table);
+      const pages = Math.max(1, Math.ceil(state.total / Number($('pageSize').value)));
+      $('pageInfo').textContent = ' page ' + state.page + ' / ' + pages + ' (' + state.total + ' rows) ';
+      $('prev').disabled = state.page <= 1;
+      $('next').disabled = state.page >= pages;
+    }
+
+    function editor(f, value) {
+      let input;
+      if (f.enum && f.enum.length) {
+        input = document.createElement('select');
+        f.enum.forEach((opt) => {
+          const o = document.createElement('option');
+          o.textContent = formatValue(opt);
+          input.appendChild(o);
+        });
+      } else {
+        input = document.createElement('input');
+      }
+      input.value = value === null || value === undefined ? '' : formatValue(value);
+      return input;
+    }
+
+    function editCell(td, row, f) {
+      const input = editor(f, row[f.name]);
+      td.textContent = '';
+      td.appendChild(input);
+      input.focus();
+      let done = false;
+      const finish = async (save) => {
+        if (done) return;
+        done = true;
+        if (!save || input.value === formatValue(row[f.name])) { renderGrid(); return; }
+        try {
+          const body = {};
+          body[f.name] = parseValue(f, input.value);
+          await api('PUT', tableURL() + '/' + encodeURIComponent(row[state.table.primary_key]), body);
+          status('Updated ' + f.name + ' of ' + row[state.table.primary_key]);
+          loadRows();
+        } catch (e) { status(e.message, true); renderGrid(); }
+      };
+      input.onkeydown = (e) => {
+        if (e.key === 'Enter') finish(true);
+        if (e.key === 'Escape') finish(false);
+      };
+      input.onblur = () => finish(true);
+    }
+
+    async function deleteRow(row) {
+      const id = row[state.table.primary_key];
+      if (!confirm('Delete ' + state.table.table + ' ' + id + '?')) return;
+      try {
+        await api('DELETE', tableURL() + '/' + encodeURIComponent(id));
+        status('Deleted ' + id);
+        loadRows();
+      } catch (e) { status(e.message, true); }
+    }
+
+    $('add').onclick = () => {
+      const cols = columns().filter((f) => !f.read_only);
+      const table = $('grid').querySelector('table');
+      if (!table || table.querySelector('tr.new')) return;
+      const tr = document.createElement('tr');
+      tr.className = 'new';
+      const inputs = {};
+      columns().forEach((f) => {
+        const td = document.createElement('td');
+        if (!f.read_only) {
+          inputs[f.name] = editor(f, null);
+          td.appendChild(inputs[f.name]);
+        }
+        tr.appendChild(td);
+      });
+      const actions = document.createElement('td');
+      const save = document.createElement('button');
+      save.textContent = 'Save';
+      save.onclick = async () => {
+        try {
+          const record = {};
+          cols.forEach((f) => {
+            if (inputs[f.name].value !== '') record[f.name] = parseValue(f, inputs[f.name].value);
+          });
+          await api('POST', tableURL(), [record]);
+          status('Created');
+          loadRows();
+        } catch (e) { status(e.message, true); }
+      };
+      const cancel = document.createElement('button');
+      cancel.textContent = 'Cancel';
+      cancel.onclick = () => tr.remove();
+      actions.appendChild(save);
+      actions.appendChild(cancel);
+      tr.appendChild(actions);
+      table.insertBefore(tr, table.rows[2] || null);
+    };
+
+    $('apply').onclick = () => { state.page = 1; loadRows(); };
+    $('filter').onkeydown = (e) => { if (e.key === 'Enter') { state.page = 1; loadRows(); } };
+    $('prev').onclick = () => { state.page--; loadRows(); };
+    $('next').onclick = () => { state.page++; loadRows(); };
+    $('pageSize').onchange = () => { state.page = 1; loadRows(); };
+    loadTables();
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,384 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// --------- 分组聚合 ---------
+//
+//	GET /api/rest/:database/:table/aggregate?group_by=status&metrics=count,sum:amount,avg:score&created_time__gte=2024-01-01
+//	GET /api/rest/:database/:table/aggregate?group_by=city&metrics=count&order=-count&limit=10
+//
+// metrics 为逗号分隔的 函数:字段，函数支持 count | sum | avg | min | max；单独的 count 统计行数，
+// count:字段 统计该字段非空的行数。结果中指标名为 函数_字段（如 sum_amount），count 为 count。
+// 不带 group_by 时对全部匹配记录聚合，返回一行。过滤条件与列表接口相同。
+// order 可按分组字段或指标名排序（- 前缀降序），默认按分组字段升序；limit 限制返回的分组数，
+// 未指定时分组超过 10000 个返回 400。隐藏字段不能用于分组或聚合，脱敏字段只能 count，
+// 不能 sum、avg、min、max（结果会暴露原值），分组值照常脱敏。
+
+const (
+	aggregateParamMetrics = "metrics"
+	aggregateParamLimit   = "limit"
+
+	maxAggregateGroups  = 10000
+	maxAggregateMetrics = 20
+	aggregateCountKey   = "count"
+)
+
+// aggregateMetric 为一个聚合指标，Field 为物理列名，count 不指定字段时为空
+type aggregateMetric struct {
+	Func  string
+	Field string
+	Name  string
+}
+
+type aggregateOrder struct {
+	Key  string // 分组字段（物理列名）或指标名
+	Desc bool
+}
+
+type aggregateQuery struct {
+	GroupBy []string
+	Metrics []aggregateMetric
+	Order   []aggregateOrder
+	Filters url.Values
+	Limit   int
+}
+
+// groupAggregator 为可选能力：按字段分组计算聚合指标，返回的每行包含分组字段与指标名
+type groupAggregator interface {
+	Aggregate(ctx context.Context, tc *tableConfig, q aggregateQuery) ([]map[string]interface{}, error)
+}
+
+var aggregateFuncs = map[string]string{"count": "COUNT", "sum": "SUM", "avg": "AVG", "min": "MIN", "max": "MAX"}
+
+// parseAggregateMetrics 解析 metrics 参数，字段为 API 字段名
+func parseAggregateMetrics(tc *tableConfig, value string) ([]aggregateMetric, error) {
+	var metrics []aggregateMetric
+	seen := map[string]bool{}
+	for _, item := range parseStringList(value) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fn, field, _ := strings.Cut(item, ":")
+		fn = strings.ToLower(fn)
+		if _, ok := aggregateFuncs[fn]; !ok {
+			return nil, fmt.Errorf("invalid metric %s, expected count, sum, avg, min or max", item)
+		}
+		m := aggregateMetric{Func: fn, Name: aggregateCountKey}
+		if field != "" && field != "*" {
+			m.Field, m.Name = tc.physicalFieldName(field), fn+"_"+field
+		} else if fn != "count" {
+			return nil, fmt.Errorf("metric %s requires a field", fn)
+		}
+		if seen[m.Name] {
+			continue
+		}
+		seen[m.Name] = true
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// parseAggregateOrder 解析 order 参数，只能按分组字段或指标排序
+func parseAggregateOrder(tc *tableConfig, value string, q aggregateQuery) ([]aggregateOrder, error) {
+	var orders []aggregateOrder
+	for _, item := range parseStringList(value) {
+		key := strings.TrimSpace(item)
+		if key == "" {
+			continue
+		}
+		o := aggregateOrder{}
+		if k, ok := strings.CutPrefix(key, "-"); ok {
+			key, o.Desc = k, true
+		}
+		isMetric := slices.ContainsFunc(q.Metrics, func(m aggregateMetric) bool { return m.Name == key })
+		if !isMetric {
+			key = tc.physicalFieldName(key)
+			if !slices.Contains(q.GroupBy, key) {
+				return nil, fmt.Errorf("order must be a group_by field or a metric: %s", item)
+			}
+		}
+		o.Key = key
+		orders = append(orders, o)
+	}
+	if len(orders) == 0 {
+		for _, g := range q.GroupBy {
+			orders = append(orders, aggregateOrder{Key: g})
+		}
+	}
+	return orders, nil
+}
+
+func (dm *databaseManager) handleAggregate(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opList) {
+		return
+	}
+	aggregator, ok := adapter.(groupAggregator)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "aggregate is not supported by this database type"})
+		return
+	}
+	raw := c.Request.URL.Query()
+	q := aggregateQuery{GroupBy: []string{}}
+	for _, g := range parseStringList(raw.Get(timeSeriesParamGroupBy)) {
+		if g = strings.TrimSpace(g); g != "" {
+			q.GroupBy = append(q.GroupBy, tableConfig.physicalFieldName(g))
+		}
+	}
+	metrics := raw.Get(aggregateParamMetrics)
+	if metrics == "" {
+		metrics = aggregateCountKey
+	}
+	if q.Metrics, err = parseAggregateMetrics(tableConfig, metrics); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(q.Metrics) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metrics is required"})
+		return
+	}
+	if len(q.Metrics)+len(q.GroupBy) > maxAggregateMetrics {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many group_by fields and metrics, max %d", maxAggregateMetrics)})
+		return
+	}
+	for _, m := range q.Metrics {
+		if m.Field != "" && slices.Contains(tableConfig.HiddenFields, m.Field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(m.Field)})
+			return
+		}
+		if _, masked := tableConfig.MaskedFields[m.Field]; masked && m.Func != "count" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("metric %s is not allowed on masked field %s", m.Func, tableConfig.apiFieldName(m.Field))})
+			return
+		}
+	}
+	for _, g := range q.GroupBy {
+		if slices.Contains(tableConfig.HiddenFields, g) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(g)})
+			return
+		}
+	}
+	if q.Order, err = parseAggregateOrder(tableConfig, raw.Get(queryParamOrder), q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 多取一行用于判断分组是否超限
+	explicitLimit := raw.Get(aggregateParamLimit) != ""
+	q.Limit = maxAggregateGroups + 1
+	if explicitLimit {
+		limit, err := strconv.Atoi(raw.Get(aggregateParamLimit))
+		if err != nil || limit < 1 || limit > maxAggregateGroups {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit, expected 1 to %d", maxAggregateGroups)})
+			return
+		}
+		q.Limit = limit
+	}
+	for _, p := range []string{timeSeriesParamGroupBy, aggregateParamMetrics, aggregateParamLimit, queryParamOrder, queryParamFields} {
+		raw.Del(p)
+	}
+	q.Filters = tableConfig.physicalQuery(raw)
+	rows, err := aggregator.Aggregate(c.Request.Context(), tableConfig, q)
+	if err != nil {
+		var unknown *unknownFieldError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(unknown.Field)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate: " + err.Error()})
+		return
+	}
+	if !explicitLimit && len(rows) > maxAggregateGroups {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many groups (max %d), pass limit or narrow the filters", maxAggregateGroups)})
+		return
+	}
+	for _, row := range rows {
+		tableConfig.applyFieldPolicy(row)
+	}
+	names := make([]string, len(q.Metrics))
+	for i, m := range q.Metrics {
+		names[i] = m.Name
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"group_by": tableConfig.apiFieldNames(q.GroupBy),
+		"metrics":  names,
+		"data":     tableConfig.apiRecords(rows),
+	})
+}
+
+// aggregateResult 把别名列（m0/g0）还原为指标名与字段名
+func aggregateResult(q aggregateQuery, get func(key string) interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(q.Metrics)+len(q.GroupBy))
+	for i, m := range q.Metrics {
+		row[m.Name] = normalizeStatsValue(get(fmt.Sprintf("m%d", i)))
+	}
+	for i, g := range q.GroupBy {
+		row[g] = normalizeStatsValue(get(fmt.Sprintf("g%d", i)))
+	}
+	return row
+}
+
+// aggregateAlias 返回排序键对应的别名列
+func (q aggregateQuery) aggregateAlias(key string) string {
+	for i, m := range q.Metrics {
+		if m.Name == key {
+			return fmt.Sprintf("m%d", i)
+		}
+	}
+	return fmt.Sprintf("g%d", slices.Index(q.GroupBy, key))
+}
+
+// ---- GORM ----
+
+func (a *gormAdapter) Aggregate(ctx context.Context, tc *tableConfig, q aggregateQuery) ([]map[string]interface{}, error) {
+	db := a.db.WithContext(ctx)
+	columnTypes, err := db.Migrator().ColumnTypes(tc.Name)
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]struct{}, len(columnTypes))
+	for _, ct := range columnTypes {
+		columns[ct.Name()] = struct{}{}
+	}
+	// 字段名会拼入 SQL，必须是表中存在的列
+	quote := func(f string) (string, error) {
+		if _, ok := columns[f]; !ok {
+			return "", &unknownFieldError{Field: f}
+		}
+		return db.Statement.Quote(f), nil
+	}
+	var exprs, groups, orders []string
+	for i, m := range q.Metrics {
+		arg := "*"
+		if m.Field != "" {
+			if arg, err = quote(m.Field); err != nil {
+				return nil, err
+			}
+		}
+		exprs = append(exprs, fmt.Sprintf("%s(%s) AS m%d", aggregateFuncs[m.Func], arg, i))
+	}
+	for i, g := range q.GroupBy {
+		col, err := quote(g)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, fmt.Sprintf("%s AS g%d", col, i))
+		groups = append(groups, col)
+	}
+	for _, o := range q.Order {
+		dir := "ASC"
+		if o.Desc {
+			dir = "DESC"
+		}
+		orders = append(orders, q.aggregateAlias(o.Key)+" "+dir)
+	}
+	var rows []map[string]interface{}
+	err = a.read(ctx, func(db *gorm.DB) error {
+		query := applyGormSoftDeleteFilter(db.Table(tc.Name), tc)
+		query, _ = applyGormQueryFilters(query, q.Filters)
+		query = query.Select(strings.Join(exprs, ", "))
+		if len(groups) > 0 {
+			query = query.Group(strings.Join(groups, ", "))
+		}
+		if len(orders) > 0 {
+			query = query.Order(strings.Join(orders, ", "))
+		}
+		return query.Limit(q.Limit).Find(&rows).Error
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = aggregateResult(q, func(key string) interface{} {
+			// 部分驱动会把别名转为大写
+			if v, ok := row[key]; ok {
+				return v
+			}
+			return row[strings.ToUpper(key)]
+		})
+	}
+	return result, nil
+}
+
+// ---- Mongo ----
+
+func (a *mongoAdapter) Aggregate(ctx context.Context, tc *tableConfig, q aggregateQuery) ([]map[string]interface{}, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, q.Filters)
+	var id interface{}
+	if len(q.GroupBy) > 0 {
+		idDoc := bson.M{}
+		for i, g := range q.GroupBy {
+			idDoc[fmt.Sprintf("g%d", i)] = "$" + g
+		}
+		id = idDoc
+	}
+	group := bson.M{"_id": id}
+	for i, m := range q.Metrics {
+		key := fmt.Sprintf("m%d", i)
+		switch {
+		case m.Func == "count" && m.Field == "":
+			group[key] = bson.M{"$sum": 1}
+		case m.Func == "count":
+			group[key] = bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$" + m.Field, nil}}, nil}}, 0, 1}}}
+		default:
+			group[key] = bson.M{mongoTimeSeriesAccumulators[m.Func]: "$" + m.Field}
+		}
+	}
+	pipeline := bson.A{bson.M{"$match": filter}, bson.M{"$group": group}}
+	if len(q.Order) > 0 {
+		sort := bson.D{}
+		for _, o := range q.Order {
+			dir, key := 1, q.aggregateAlias(o.Key)
+			if o.Desc {
+				dir = -1
+			}
+			if strings.HasPrefix(key, "g") {
+				key = "_id." + key
+			}
+			sort = append(sort, bson.E{Key: key, Value: dir})
+		}
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+	pipeline = append(pipeline, bson.M{"$limit": q.Limit})
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var docs []bson.M
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		idDoc, _ := doc["_id"].(bson.M)
+		result[i] = aggregateResult(q, func(key string) interface{} {
+			if strings.HasPrefix(key, "m") {
+				return doc[key]
+			}
+			return idDoc[key]
+		})
+	}
+	return result, nil
+}
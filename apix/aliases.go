@@ -0,0 +1,133 @@
+package apix
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 别名冲突检测 ---------
+//
+// 库别名与同一库内的表别名必须唯一，否则后加载的配置会覆盖或遮蔽前一个。
+// 启动加载配置与生成 swagger 时检测冲突：库别名冲突、表配置中显式写出的表别名冲突直接报错；
+// 命名策略生成的默认别名冲突按 table_naming.on_collision 处理：
+//
+//	table_naming:
+//	  on_collision: error    # error（默认）报错；suffix 按表名排序后依次追加 _2、_3……
+//
+// 例如 singularize 后 user 与 users 都得到 user：users 的别名为 user_2，写入表配置后保持不变。
+//
+// 管理接口查看全部别名映射：
+//
+//	GET /api/admin/aliases
+//
+//	[{"database": "test", "alias": "test", "type": "sqlite",
+//	  "tables": [{"name": "users", "alias": "user", "field_aliases": {"age": "years"}}]}]
+
+const (
+	aliasCollisionError  = "error"
+	aliasCollisionSuffix = "suffix"
+)
+
+// resolveTableAliases 处理同一库内的表别名冲突，explicit 为表配置中已写出别名的表名
+func resolveTableAliases(dbAlias string, tables []TableMeta, explicit map[string]bool, onCollision string) error {
+	byAlias := map[string][]int{}
+	for i, t := range tables {
+		byAlias[t.Alias] = append(byAlias[t.Alias], i)
+	}
+	aliases := make([]string, 0, len(byAlias))
+	for alias := range byAlias {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		idx := byAlias[alias]
+		if len(idx) < 2 {
+			continue
+		}
+		// 显式别名优先保留，其余按表名排序
+		sort.Slice(idx, func(a, b int) bool {
+			ea, eb := explicit[tables[idx[a]].Name], explicit[tables[idx[b]].Name]
+			if ea != eb {
+				return ea
+			}
+			return tables[idx[a]].Name < tables[idx[b]].Name
+		})
+		names := make([]string, len(idx))
+		for k, i := range idx {
+			names[k] = tables[i].Name
+		}
+		if onCollision != aliasCollisionSuffix || explicit[tables[idx[1]].Name] {
+			return fmt.Errorf("alias collision in db %s: tables %s all map to '%s'", dbAlias, strings.Join(names, ", "), alias)
+		}
+		n := 2
+		for _, i := range idx[1:] {
+			for byAlias[fmt.Sprintf("%s_%d", alias, n)] != nil {
+				n++
+			}
+			suffixed := fmt.Sprintf("%s_%d", alias, n)
+			byAlias[suffixed] = []int{i}
+			tables[i].Alias = suffixed
+		}
+	}
+	return nil
+}
+
+// checkDatabaseAliases 库别名冲突时报错
+func checkDatabaseAliases(dbCfgs []DbBaseCfg) error {
+	seen := map[string]string{}
+	for _, cfg := range dbCfgs {
+		if prev, ok := seen[cfg.Alias]; ok {
+			return fmt.Errorf("alias collision: databases %s and %s both map to '%s'", prev, cfg.Database, cfg.Alias)
+		}
+		seen[cfg.Alias] = cfg.Database
+	}
+	return nil
+}
+
+// checkTableConfigAliases 加载配置时检查同一库内的表别名
+func checkTableConfigAliases(dbAlias string, tables []tableConfig) error {
+	seen := map[string]string{}
+	for _, t := range tables {
+		if prev, ok := seen[t.Alias]; ok {
+			return fmt.Errorf("alias collision in db %s: tables %s and %s both map to '%s'", dbAlias, prev, t.Name, t.Alias)
+		}
+		seen[t.Alias] = t.Name
+	}
+	return nil
+}
+
+type tableAliasInfo struct {
+	Name         string            `json:"name"`
+	Alias        string            `json:"alias"`
+	FieldAliases map[string]string `json:"field_aliases,omitempty"`
+}
+
+type databaseAliasInfo struct {
+	Database string           `json:"database"`
+	Alias    string           `json:"alias"`
+	Type     string           `json:"type"`
+	Tables   []tableAliasInfo `json:"tables"`
+}
+
+func (dm *databaseManager) handleAliasReport(c *gin.Context) {
+	if !dm.authorize(c, nil, opAliasReport) {
+		return
+	}
+	dm.mutex.RLock()
+	report := make([]databaseAliasInfo, 0, len(dm.config.Databases))
+	for alias, db := range dm.config.Databases {
+		info := databaseAliasInfo{Database: db.Database, Alias: alias, Type: db.Type, Tables: []tableAliasInfo{}}
+		for _, t := range db.Tables {
+			info.Tables = append(info.Tables, tableAliasInfo{Name: t.Name, Alias: t.Alias, FieldAliases: t.FieldAliases})
+		}
+		sort.Slice(info.Tables, func(i, j int) bool { return info.Tables[i].Alias < info.Tables[j].Alias })
+		report = append(report, info)
+	}
+	dm.mutex.RUnlock()
+	sort.Slice(report, func(i, j int) bool { return report[i].Alias < report[j].Alias })
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,209 @@
+package apix
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- API Key 认证 ---------
+//
+// 为服务间调用配置固定的 API Key，每个 key 限定可访问的库、表与 HTTP 方法：
+//
+//	auth:
+//	  api_keys:
+//	    header: X-API-Key                # 默认 X-API-Key
+//	    required: false                  # true 时没有 key 的请求返回 401（配置了 auth.jwt 时可改用 token）
+//	    keys:
+//	      - name: reporting              # 作为调用者标识（auto_actor_fields）
+//	        key_sha256: 9f86d08...       # key 的 SHA-256（十六进制），也可用 key 直接配置明文
+//	        roles: [reader]              # 参与 operation_roles 校验
+//	        databases: [test]            # 为空时不限制
+//	        tables: [user, shop.orders]  # 表别名，可加库别名前缀；为空时不限制
+//	        methods: [GET]               # 为空时不限制；注意 batch_get、aggregate_pipeline 等查询为 POST
+//	        claims: {tid: 7}             # 供 row_filter 使用（见 rowfilter.go）
+//
+// 请求带有 key 时不再校验 JWT；key 无效返回 401，访问范围之外的库、表或方法返回 403，均在进入处理函数前拒绝。
+// 限定了 databases 或 tables 的 key 只能访问目标库、表在范围内的任务（/api/jobs/:id），
+// 不能访问任务列表、admin 等不属于某个库的接口。
+// REST、jobs、meta、admin 接口与 GraphQL 直连查询均支持。
+
+const defaultAPIKeyHeader = "X-API-Key"
+
+type apiKeysConfig struct {
+	Header   string         `mapstructure:"header"`
+	Required bool           `mapstructure:"required"`
+	Keys     []apiKeyConfig `mapstructure:"keys"`
+}
+
+type apiKeyConfig struct {
+	Name      string                 `mapstructure:"name"`
+	Key       string                 `mapstructure:"key"`
+	KeySHA256 string                 `mapstructure:"key_sha256"`
+	Roles     []string               `mapstructure:"roles"`
+	Databases []string               `mapstructure:"databases"`
+	Tables    []string               `mapstructure:"tables"`
+	Methods   []string               `mapstructure:"methods"`
+	Claims    map[string]interface{} `mapstructure:"claims"`
+}
+
+// apiKeyStore 按 key 的 SHA-256 查找配置，比较的是摘要，不会因明文比较泄露时间差
+type apiKeyStore struct {
+	header   string
+	required bool
+	keys     map[string]*apiKeyConfig
+}
+
+func newAPIKeyStore(cfg apiKeysConfig) (*apiKeyStore, error) {
+	if len(cfg.Keys) == 0 && !cfg.Required {
+		return nil, nil
+	}
+	s := &apiKeyStore{header: cfg.Header, required: cfg.Required, keys: map[string]*apiKeyConfig{}}
+	if s.header == "" {
+		s.header = defaultAPIKeyHeader
+	}
+	for i := range cfg.Keys {
+		k := &cfg.Keys[i]
+		if k.Name == "" {
+			return nil, fmt.Errorf("auth.api_keys: key #%d has no name", i+1)
+		}
+		digest := strings.ToLower(strings.TrimSpace(k.KeySHA256))
+		switch {
+		case k.Key != "" && digest != "":
+			return nil, fmt.Errorf("auth.api_keys: key %s: only one of key and key_sha256 may be set", k.Name)
+		case k.Key != "":
+			digest = apiKeyDigest(k.Key)
+		case digest == "":
+			return nil, fmt.Errorf("auth.api_keys: key %s: key or key_sha256 is required", k.Name)
+		}
+		if _, dup := s.keys[digest]; dup {
+			return nil, fmt.Errorf("auth.api_keys: key %s duplicates another key", k.Name)
+		}
+		s.keys[digest] = k
+	}
+	return s, nil
+}
+
+func apiKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *apiKeyStore) lookup(key string) *apiKeyConfig {
+	return s.keys[apiKeyDigest(key)]
+}
+
+// permits 校验 key 能否以 method 访问 dbName 库的 table 表，dbName、table 为空时不检查
+func (k *apiKeyConfig) permits(method, dbName, table string) error {
+	if len(k.Methods) > 0 && !containsFold(k.Methods, method) {
+		return fmt.Errorf("API key '%s' is not allowed to use method %s", k.Name, method)
+	}
+	if dbName != "" && len(k.Databases) > 0 && !contains(k.Databases, dbName) {
+		return fmt.Errorf("API key '%s' is not allowed to access database %s", k.Name, dbName)
+	}
+	if table != "" && len(k.Tables) > 0 && !contains(k.Tables, table) && !contains(k.Tables, dbName+"."+table) {
+		return fmt.Errorf("API key '%s' is not allowed to access table %s", k.Name, table)
+	}
+	return nil
+}
+
+// scoped key 是否限定了可访问的库或表
+func (k *apiKeyConfig) scoped() bool {
+	return len(k.Databases) > 0 || len(k.Tables) > 0
+}
+
+func (k *apiKeyConfig) principal() *principal {
+	return &principal{ID: k.Name, Roles: k.Roles}
+}
+
+func containsFold(arr []string, target string) bool {
+	for _, s := range arr {
+		if strings.EqualFold(s, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---- 中间件 ----
+
+const ctxKeyAPIKey = "ego.api_key"
+
+// withAPIKey 校验 API Key 及其访问范围，写入调用者身份；请求没有 key 时交给后续的 JWT 认证
+func (dm *databaseManager) withAPIKey(c *gin.Context) {
+	if dm.apiKeys == nil {
+		c.Next()
+		return
+	}
+	raw := strings.TrimSpace(c.GetHeader(dm.apiKeys.header))
+	if raw == "" {
+		if dm.apiKeys.required && dm.jwt == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: missing API key"})
+			return
+		}
+		c.Next()
+		return
+	}
+	key := dm.apiKeys.lookup(raw)
+	if key == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid API key"})
+		return
+	}
+	dbName, table := c.Param("database"), c.Param("table")
+	if dbName == "" && key.scoped() {
+		// 限定了库或表的 key：jobs 接口按任务的目标库、表校验，其余不属于某个库的接口（任务列表、admin 等）不允许访问
+		if strings.HasPrefix(c.FullPath(), dm.jobsPrefix) {
+			dbName, table = dm.jobTarget(c.Param("id"))
+		}
+		if dbName == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key '%s' is limited to specific databases or tables", key.Name)})
+			return
+		}
+	}
+	if err := key.permits(c.Request.Method, dbName, table); err != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set(ctxKeyAPIKey, key)
+	setPrincipal(c, key.principal())
+	if key.Claims != nil {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jwtClaimsKey{}, key.Claims))
+	}
+	c.Next()
+}
+
+// authenticatedByAPIKey 请求是否已由 API Key 认证
+func authenticatedByAPIKey(c *gin.Context) bool {
+	_, ok := c.Get(ctxKeyAPIKey)
+	return ok
+}
+
+// graphqlAPIKey GraphQL 直连查询按转发的 API Key 认证；请求没有带 key 时返回的 key 为 nil
+func (dm *databaseManager) graphqlAPIKey(ctx context.Context, dbName string, tc *tableConfig) (context.Context, *apiKeyConfig, error) {
+	if dm.apiKeys == nil {
+		return ctx, nil, nil
+	}
+	raw := strings.TrimSpace(incomingHeader(ctx, dm.apiKeys.header))
+	if raw == "" {
+		if dm.apiKeys.required && dm.jwt == nil {
+			return nil, nil, fmt.Errorf("Unauthorized: missing API key")
+		}
+		return ctx, nil, nil
+	}
+	key := dm.apiKeys.lookup(raw)
+	if key == nil {
+		return nil, nil, fmt.Errorf("Unauthorized: invalid API key")
+	}
+	if err := key.permits(http.MethodGet, dbName, tc.Alias); err != nil {
+		return nil, nil, err
+	}
+	if key.Claims != nil {
+		ctx = context.WithValue(ctx, jwtClaimsKey{}, key.Claims)
+	}
+	return ctx, key, nil
+}
@@ -0,0 +1,335 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- 回收站清理与归档 ---------
+//
+// 软删除超过 days 天的记录分批转存到归档表或 NDJSON 文件后物理删除，保持在线表精简：
+//
+//	archive:
+//	  schedule: "0 0 4 * * *"   # 定时清理（秒级 cron），为空时只能手动触发
+//	  batch_size: 1000
+//
+// 表配置（需 softdel_type 为 timestamp 或未配置，SQL 库）：
+//
+//	archive:
+//	  days: 30
+//	  table: user_archive                                  # 同库的归档表，与删除在同一事务中写入
+//	  url: https://minio/archive/{table}/{time}-{seq}.ndjson  # 或本地路径；不配置 table 时使用
+//	  headers: {Authorization: "..."}                      # http(s) 上传时的请求头
+//
+// url 支持 {database}、{table}、{date}、{time}、{seq} 占位符，每批写入一个对象（HTTP PUT），
+// 本地路径不含 {seq} 时各批追加到同一文件。NDJSON 写入成功后才删除对应记录，
+// 删除失败时下次清理会重复导出这部分记录。
+//
+// 管理接口（受 operation_roles.archive 控制，默认 admin）：
+//
+//	POST /api/admin/archive?database=test&table=user   提交清理任务，返回任务 ID
+
+const (
+	jobTypeArchive = "archive"
+
+	defaultArchiveBatchSize = 1000
+)
+
+type archiveConfig struct {
+	Schedule  string `mapstructure:"schedule"`
+	BatchSize int    `mapstructure:"batch_size"`
+}
+
+type tableArchiveConfig struct {
+	Days    int               `mapstructure:"days"`
+	Table   string            `mapstructure:"table"`
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+type archiveJobPayload struct {
+	Database string `json:"database,omitempty"`
+	Table    string `json:"table,omitempty"`
+}
+
+type archiveProgress struct {
+	Archived map[string]int64  `json:"archived"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// archiver 为可选能力：把软删除早于 cutoff 的记录分批交给 sink（或写入归档表）后物理删除
+type archiver interface {
+	ArchiveDeleted(ctx context.Context, tc *tableConfig, cutoff time.Time, batchSize int, sink func(rows []map[string]interface{}) error) (int64, error)
+}
+
+func (tc *tableConfig) validateArchive() error {
+	cfg := tc.Archive
+	if cfg.Days <= 0 {
+		return nil
+	}
+	if tc.SoftDeleteKey == "" || (tc.SoftDeleteType != "" && tc.SoftDeleteType != softDeleteTypeTimestamp) {
+		return fmt.Errorf("table %s: archive requires a timestamp softdel_key", tc.Alias)
+	}
+	if tc.PrimaryKey == "" {
+		return fmt.Errorf("table %s: archive requires primary_key", tc.Alias)
+	}
+	if cfg.Table == "" && cfg.URL == "" {
+		return fmt.Errorf("table %s: archive requires table or url", tc.Alias)
+	}
+	if isHTTPURL(cfg.URL) && !strings.Contains(cfg.URL, "{seq}") {
+		return fmt.Errorf("table %s: archive url must contain {seq} so each batch gets its own object", tc.Alias)
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// scheduleArchive 校验表配置，并按 archive.schedule 定时提交清理任务
+func (dm *databaseManager) scheduleArchive() error {
+	for _, db := range dm.config.Databases {
+		for i := range db.Tables {
+			if err := db.Tables[i].validateArchive(); err != nil {
+				return err
+			}
+		}
+	}
+	spec := dm.config.Archive.Schedule
+	if spec == "" {
+		return nil
+	}
+	s := utils.NewScheduler()
+	err := s.AddJob(jobTypeArchive, spec, func() {
+		if _, err := dm.jobQueue.Enqueue(jobTypeArchive, archiveJobPayload{}, utils.EnqueueOptions{}); err != nil {
+			log.Printf("enqueue archive job failed: %v", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("invalid archive.schedule: %w", err)
+	}
+	dm.schedulers = append(dm.schedulers, s)
+	return nil
+}
+
+func (dm *databaseManager) runArchiveJob(ctx context.Context, job utils.Job) error {
+	var payload archiveJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid archive job payload: %w", err)
+	}
+	batchSize := dm.config.Archive.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultArchiveBatchSize
+	}
+	progress := archiveProgress{Archived: map[string]int64{}}
+	var names []string
+	for name := range dm.config.Databases {
+		if payload.Database == "" || payload.Database == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, t := range dm.config.Databases[name].Tables {
+			if t.Archive.Days <= 0 || (payload.Table != "" && payload.Table != t.Alias) {
+				continue
+			}
+			key := name + "." + t.Alias
+			n, err := dm.archiveTable(ctx, name, t.Alias, batchSize)
+			progress.Archived[key] = n
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if progress.Errors == nil {
+					progress.Errors = map[string]string{}
+				}
+				progress.Errors[key] = err.Error()
+				log.Printf("archive %s failed: %v", key, err)
+			}
+			if err := dm.jobQueue.SetProgress(job.ID, progress); err != nil {
+				return err
+			}
+		}
+	}
+	if len(progress.Errors) > 0 {
+		return fmt.Errorf("archive failed for %d table(s)", len(progress.Errors))
+	}
+	return nil
+}
+
+func (dm *databaseManager) archiveTable(ctx context.Context, dbName, alias string, batchSize int) (int64, error) {
+	adapter, tc, err := dm.getAdapterAndTableConfig(dbName, alias)
+	if err != nil {
+		return 0, err
+	}
+	ar, ok := adapter.(archiver)
+	if !ok {
+		return 0, fmt.Errorf("archive is not supported for this database type")
+	}
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -tc.Archive.Days)
+	var sink func(rows []map[string]interface{}) error
+	if tc.Archive.Table == "" {
+		sink = archiveNDJSONSink(ctx, dbName, tc, now)
+	}
+	return ar.ArchiveDeleted(ctx, tc, cutoff, batchSize, sink)
+}
+
+// archiveNDJSONSink 把每批记录写成 NDJSON：http(s) 地址逐批 PUT，本地路径逐批追加
+func archiveNDJSONSink(ctx context.Context, dbName string, tc *tableConfig, runAt time.Time) func(rows []map[string]interface{}) error {
+	seq := 0
+	return func(rows []map[string]interface{}) error {
+		seq++
+		dest := strings.NewReplacer(
+			"{database}", dbName,
+			"{table}", tc.Name,
+			"{date}", runAt.Format("20060102"),
+			"{time}", runAt.Format("20060102T150405"),
+			"{seq}", strconv.Itoa(seq),
+		).Replace(tc.Archive.URL)
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		if isHTTPURL(dest) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, dest, &buf)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/x-ndjson")
+			for k, v := range tc.Archive.Headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				return fmt.Errorf("upload archive failed: %s", resp.Status)
+			}
+			return nil
+		}
+		dest = strings.TrimPrefix(dest, "file://")
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+func (a *gormAdapter) ArchiveDeleted(ctx context.Context, tc *tableConfig, cutoff time.Time, batchSize int, sink func(rows []map[string]interface{}) error) (int64, error) {
+	key := tc.SoftDeleteKey
+	expired := func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("%s IS NOT NULL AND %s > ? AND %s < ?", key, key, key), time.Time{}, cutoff)
+	}
+	var total int64
+	for {
+		var rows []map[string]interface{}
+		if err := expired(a.db.WithContext(ctx).Table(tc.Name)).Order(tc.PrimaryKey).Limit(batchSize).Find(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		ids := make([]interface{}, len(rows))
+		for i, r := range rows {
+			ids[i] = r[tc.PrimaryKey]
+		}
+		if sink != nil {
+			if err := sink(rows); err != nil {
+				return total, err
+			}
+		}
+		var deleted int64
+		err := a.transaction(ctx, func(tx *gorm.DB) error {
+			if tc.Archive.Table != "" {
+				if err := tx.Table(tc.Archive.Table).Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+			// 再次带上过期条件，期间被恢复的记录不会被删除
+			res := expired(tx.Table(tc.Name).Where(fmt.Sprintf("%s IN (?)", tc.PrimaryKey), ids)).Delete(nil)
+			deleted = res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted == 0 {
+			return total, nil
+		}
+	}
+}
+
+func (a *shardedAdapter) ArchiveDeleted(ctx context.Context, tc *tableConfig, cutoff time.Time, batchSize int, sink func(rows []map[string]interface{}) error) (int64, error) {
+	var total int64
+	for _, s := range a.shards {
+		n, err := s.ArchiveDeleted(ctx, tc, cutoff, batchSize, sink)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (dm *databaseManager) handleArchiveRun(c *gin.Context) {
+	if !dm.authorize(c, nil, opArchive) {
+		return
+	}
+	payload := archiveJobPayload{Database: c.Query("database"), Table: c.Query("table")}
+	if payload.Table != "" {
+		_, tc, err := dm.getAdapterAndTableConfig(payload.Database, payload.Table)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if tc.Archive.Days <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Archive is not configured for table " + payload.Table})
+			return
+		}
+	} else if _, ok := dm.config.Databases[payload.Database]; payload.Database != "" && !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Database not found: " + payload.Database})
+		return
+	}
+	job, err := dm.jobQueue.Enqueue(jobTypeArchive, payload, utils.EnqueueOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", dm.jobsPrefix+"/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,688 @@
+package apix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 审计日志 ---------
+//
+// 记录每次创建、更新、删除的调用者、时间、请求 ID 与前后的值：
+//
+//	audit:
+//	  enabled: true
+//	  sink: table                    # table：写入各库的审计表；store：写入 job_queue.store 的 KVStore
+//	  table: ego_audit               # sink 为 table 时的表名，不存在时自动创建
+//	  retention: 720h                # sink 为 store 时的保留时长，0 为永久保存
+//	  request_id_header: X-Request-Id
+//	  databases: []                  # 只审计这些库，为空时全部 SQL 库
+//	  tables: []                     # 只审计这些表（表别名或 库别名.表别名），为空时全部
+//
+// 写入通过 gorm 回调捕获（SQL 库），与 history 一样覆盖单条、批量、upsert、update_where、delete_where、导入等全部写入：
+// 更新、删除前按同一 WHERE 条件读出旧值，更新后按主键读回新值，软删除记为 delete，ON CONFLICT 写入记为 upsert。
+// sink 为 table 时审计记录与写入处于同一事务，写审计失败则写入失败，试运行回滚时一并回滚；
+// sink 为 store 时在事务提交后写入 KVStore。值按物理列名记录，hidden_fields 不记录，masked_fields 脱敏后记录。
+//
+// 审计表不会生成表配置，只能经查询接口读取。
+// 请求 ID 取自 request_id_header，请求没有带时生成一个，并通过同名响应头返回；调用者来自 API Key、JWT 或 actor_header。
+// 后台任务（bulk_jobs、import 等）中的写入没有请求信息，只记录库、表与前后的值。
+//
+// 查询接口（受 operation_roles.audit 控制，默认 admin）：
+//
+//	GET /api/audit?database=test&table=user&op=update&actor=alice&request_id=...&record_id=1&since=2025-06-01T00:00:00Z&until=...&page=1&page_size=20
+//
+// 按时间倒序返回 {"total": 12, "data": [{"time", "request_id", "actor", "roles", "client_ip", "method", "path",
+// "database", "table", "op", "record_id", "old", "new"}]}，所有条件均可省略。
+
+const (
+	auditSinkTable = "table"
+	auditSinkStore = "store"
+
+	defaultAuditTable           = "ego_audit"
+	defaultAuditRequestIDHeader = "X-Request-Id"
+
+	auditOpCreate = "create"
+	auditOpUpsert = "upsert"
+	auditOpUpdate = "update"
+	auditOpDelete = "delete"
+
+	auditKeyPrefix  = "audit:"
+	auditOldRowsKey = "ego:audit_old_rows"
+	auditEntriesKey = "ego:audit_entries"
+)
+
+type auditConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Sink            string        `mapstructure:"sink"`
+	Table           string        `mapstructure:"table"`
+	Retention       time.Duration `mapstructure:"retention"`
+	RequestIDHeader string        `mapstructure:"request_id_header"`
+	Databases       []string      `mapstructure:"databases"`
+	Tables          []string      `mapstructure:"tables"`
+}
+
+// auditEntry 一条审计记录，old、new 为物理列名
+type auditEntry struct {
+	Time      time.Time              `json:"time"`
+	RequestID string                 `json:"request_id"`
+	Actor     string                 `json:"actor"`
+	Roles     []string               `json:"roles,omitempty"`
+	ClientIP  string                 `json:"client_ip"`
+	Method    string                 `json:"method"`
+	Path      string                 `json:"path"`
+	Database  string                 `json:"database"`
+	Table     string                 `json:"table"`
+	Op        string                 `json:"op"`
+	RecordID  string                 `json:"record_id"`
+	Old       map[string]interface{} `json:"old"`
+	New       map[string]interface{} `json:"new"`
+}
+
+// auditRow 审计表的结构
+type auditRow struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	AuditTime time.Time `gorm:"index"`
+	RequestID string    `gorm:"size:128;index"`
+	Actor     string    `gorm:"size:255;index"`
+	Roles     string    `gorm:"size:255"`
+	ClientIP  string    `gorm:"size:64"`
+	Method    string    `gorm:"size:16"`
+	Path      string    `gorm:"size:512"`
+	Database  string    `gorm:"column:database_name;size:128"`
+	TableName string    `gorm:"column:table_name;size:128;index"`
+	Op        string    `gorm:"size:16"`
+	RecordID  string    `gorm:"size:255;index"`
+	OldValues string    `gorm:"type:text"`
+	NewValues string    `gorm:"type:text"`
+}
+
+func (r auditRow) entry() auditEntry {
+	e := auditEntry{
+		Time: r.AuditTime.UTC(), RequestID: r.RequestID, Actor: r.Actor, ClientIP: r.ClientIP,
+		Method: r.Method, Path: r.Path, Database: r.Database, Table: r.TableName, Op: r.Op, RecordID: r.RecordID,
+		Roles: parseKeyFields(r.Roles),
+	}
+	if r.OldValues != "" {
+		_ = json.Unmarshal([]byte(r.OldValues), &e.Old)
+	}
+	if r.NewValues != "" {
+		_ = json.Unmarshal([]byte(r.NewValues), &e.New)
+	}
+	return e
+}
+
+func (e auditEntry) row() auditRow {
+	r := auditRow{
+		AuditTime: e.Time, RequestID: e.RequestID, Actor: e.Actor, Roles: strings.Join(e.Roles, ","), ClientIP: e.ClientIP,
+		Method: e.Method, Path: e.Path, Database: e.Database, TableName: e.Table, Op: e.Op, RecordID: e.RecordID,
+	}
+	if e.Old != nil {
+		data, _ := json.Marshal(e.Old)
+		r.OldValues = string(data)
+	}
+	if e.New != nil {
+		data, _ := json.Marshal(e.New)
+		r.NewValues = string(data)
+	}
+	return r
+}
+
+// auditRequest 写入所属请求的信息，由 withAuditContext 放入请求的 context
+type auditRequest struct {
+	ID       string
+	Actor    string
+	Roles    []string
+	ClientIP string
+	Method   string
+	Path     string
+}
+
+type auditRequestKey struct{}
+
+type auditTable struct {
+	dbName string
+	tc     *tableConfig
+}
+
+type auditLog struct {
+	config auditConfig
+	store  *utils.KVStore
+	dbs    map[string][]*gorm.DB // 库别名 → 审计表所在的连接（分片库为各分片），sink 为 table 时使用
+	seq    atomic.Uint64
+}
+
+// setupAudit 为审计的库注册回调，sink 为 table 时创建审计表；需在 setupJobQueue 之后
+func (dm *databaseManager) setupAudit() error {
+	cfg := dm.config.Audit
+	if !cfg.Enabled {
+		return nil
+	}
+	switch cfg.Sink {
+	case "":
+		cfg.Sink = auditSinkTable
+	case auditSinkTable, auditSinkStore:
+	default:
+		return fmt.Errorf("invalid audit.sink %q, expected table or store", cfg.Sink)
+	}
+	if cfg.Table == "" {
+		cfg.Table = defaultAuditTable
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = defaultAuditRequestIDHeader
+	}
+	if cfg.Sink == auditSinkStore && dm.store == nil {
+		return fmt.Errorf("audit.sink store requires job_queue.store")
+	}
+	l := &auditLog{config: cfg, store: dm.store, dbs: map[string][]*gorm.DB{}}
+	for name, dbCfg := range dm.config.Databases {
+		if len(cfg.Databases) > 0 && !contains(cfg.Databases, name) {
+			continue
+		}
+		var dbs []*gorm.DB
+		switch a := dm.adapters[name].(type) {
+		case *gormAdapter:
+			dbs = []*gorm.DB{a.db}
+		case *shardedAdapter:
+			for _, s := range a.shards {
+				dbs = append(dbs, s.db)
+			}
+		default:
+			if len(cfg.Databases) > 0 {
+				return fmt.Errorf("audit is only supported on SQL databases, %s is %s", name, dbCfg.Type)
+			}
+			continue
+		}
+		tables := map[string]*auditTable{}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if len(cfg.Tables) > 0 && !contains(cfg.Tables, tc.Alias) && !contains(cfg.Tables, name+"."+tc.Alias) {
+				continue
+			}
+			if tc.PrimaryKey == "" {
+				return fmt.Errorf("table %s.%s: audit requires primary_key", name, tc.Alias)
+			}
+			tables[tc.Name] = &auditTable{dbName: name, tc: tc}
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		for _, db := range dbs {
+			if cfg.Sink == auditSinkTable && !db.Migrator().HasTable(cfg.Table) {
+				if err := db.Table(cfg.Table).Migrator().CreateTable(&auditRow{}); err != nil {
+					return fmt.Errorf("failed to create audit table for %s: %w", name, err)
+				}
+			}
+			if err := l.register(db, tables); err != nil {
+				return fmt.Errorf("failed to set up audit for %s: %w", name, err)
+			}
+		}
+		l.dbs[name] = dbs
+	}
+	dm.audit = l
+	return nil
+}
+
+// readAuditTable 读取 _base.yaml 中的审计表名，未开启或不写入表时为空，用于提取元数据时跳过该表
+func readAuditTable(cfgs string) string {
+	var conf struct {
+		Audit struct {
+			Enabled bool   `yaml:"enabled"`
+			Sink    string `yaml:"sink"`
+			Table   string `yaml:"table"`
+		} `yaml:"audit"`
+	}
+	if data, err := os.ReadFile(filepath.Join(cfgs, "_base.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &conf)
+	}
+	if !conf.Audit.Enabled || (conf.Audit.Sink != "" && conf.Audit.Sink != auditSinkTable) {
+		return ""
+	}
+	if conf.Audit.Table == "" {
+		return defaultAuditTable
+	}
+	return conf.Audit.Table
+}
+
+// withAuditContext 把请求 ID 与调用者放入请求的 context，需在认证中间件之后
+func (dm *databaseManager) withAuditContext(c *gin.Context) {
+	if dm.audit == nil {
+		c.Next()
+		return
+	}
+	header := dm.audit.config.RequestIDHeader
+	id := strings.TrimSpace(c.GetHeader(header))
+	if id == "" {
+		id = globalSnowflakeNode.Generate().String()
+	}
+	c.Header(header, id)
+	req := &auditRequest{ID: id, ClientIP: c.ClientIP(), Method: c.Request.Method, Path: c.Request.URL.Path}
+	if p := dm.currentPrincipal(c); p != nil {
+		req.Actor, req.Roles = p.ID, p.Roles
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), auditRequestKey{}, req))
+	c.Next()
+}
+
+// ---- 捕获写入：gorm 回调 ----
+
+// readOldRows 返回更新、删除前按同一 WHERE 条件读出旧值的回调，审计、webhook 与事件发布共用，已读过时不再读
+func readOldRows(tables map[string]*auditTable) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		t, ok := tables[db.Statement.Table]
+		if !ok {
+			return
+		}
+		if _, read := db.InstanceGet(auditOldRowsKey); read {
+			return
+		}
+		where, ok := db.Statement.Clauses["WHERE"]
+		if !ok {
+			return
+		}
+		var rows []map[string]interface{}
+		tx := db.Session(&gorm.Session{NewDB: true})
+		if err := tx.Table(t.tc.Name).Clauses(where.Expression).Find(&rows).Error; err != nil {
+			db.AddError(fmt.Errorf("read old values failed: %w", err))
+			return
+		}
+		db.InstanceSet(auditOldRowsKey, rows)
+	}
+}
+
+// changeBatch 一条语句产生的变更记录
+type changeBatch struct {
+	tc      *tableConfig
+	entries []auditEntry
+}
+
+// registerChangeFeed 注册回调，在写入提交后把变更记录交给 fn（webhook 与事件发布）；处于 gormAdapter.transaction
+// 中时推迟到外层事务提交后，试运行与回滚的写入不交付。name 区分各自的回调
+func registerChangeFeed(db *gorm.DB, name string, tables map[string]*auditTable, fn func(tc *tableConfig, entries []auditEntry)) error {
+	key := "ego:" + name + "_changes"
+	after := func(op string) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			if db.Error != nil || db.DryRun {
+				return
+			}
+			t, ok := tables[db.Statement.Table]
+			if !ok {
+				return
+			}
+			entries, err := changeEntries(db, t, op)
+			if err != nil {
+				db.AddError(fmt.Errorf("read %s values failed: %w", name, err))
+				return
+			}
+			if len(entries) > 0 {
+				db.InstanceSet(key, changeBatch{tc: t.tc, entries: entries})
+			}
+		}
+	}
+	committed := func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		v, ok := db.InstanceGet(key)
+		if !ok {
+			return
+		}
+		batch := v.(changeBatch)
+		if hooks := afterCommitFrom(db.Statement.Context); hooks != nil {
+			hooks.add(func() { fn(batch.tc, batch.entries) })
+			return
+		}
+		if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+			// 处于看不到提交结果的外层事务中（试运行），不交付
+			return
+		}
+		fn(batch.tc, batch.entries)
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Update().Before("gorm:update").Register("ego:"+name+"_before", readOldRows(tables)),
+		cb.Delete().Before("gorm:delete").Register("ego:"+name+"_before", readOldRows(tables)),
+		cb.Create().After("gorm:create").Register("ego:"+name+"_after", after(auditOpCreate)),
+		cb.Update().After("gorm:update").Register("ego:"+name+"_after", after(auditOpUpdate)),
+		cb.Delete().After("gorm:delete").Register("ego:"+name+"_after", after(auditOpDelete)),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("ego:"+name+"_commit", committed),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("ego:"+name+"_commit", committed),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("ego:"+name+"_commit", committed),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *auditLog) register(db *gorm.DB, tables map[string]*auditTable) error {
+	before := readOldRows(tables)
+	after := func(op string) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			if db.Error != nil || db.DryRun {
+				return
+			}
+			t, ok := tables[db.Statement.Table]
+			if !ok {
+				return
+			}
+			entries, err := changeEntries(db, t, op)
+			if err != nil {
+				db.AddError(fmt.Errorf("read audit new values failed: %w", err))
+				return
+			}
+			if len(entries) == 0 {
+				return
+			}
+			if l.config.Sink == auditSinkStore {
+				db.InstanceSet(auditEntriesKey, entries)
+				return
+			}
+			rows := make([]auditRow, len(entries))
+			for i, e := range entries {
+				rows[i] = e.row()
+			}
+			tx := db.Session(&gorm.Session{NewDB: true})
+			if err := tx.Table(l.config.Table).Create(&rows).Error; err != nil {
+				db.AddError(fmt.Errorf("write audit log failed: %w", err))
+			}
+		}
+	}
+	committed := func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		v, ok := db.InstanceGet(auditEntriesKey)
+		entries, _ := v.([]auditEntry)
+		if !ok || len(entries) == 0 {
+			return
+		}
+		if hooks := afterCommitFrom(db.Statement.Context); hooks != nil {
+			hooks.add(func() { l.save(entries) })
+			return
+		}
+		if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+			// 处于看不到提交结果的外层事务中（试运行），不记录
+			return
+		}
+		l.save(entries)
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Update().Before("gorm:update").Register("ego:audit_before", before),
+		cb.Delete().Before("gorm:delete").Register("ego:audit_before", before),
+		cb.Create().After("gorm:create").Register("ego:audit_after", after(auditOpCreate)),
+		cb.Update().After("gorm:update").Register("ego:audit_after", after(auditOpUpdate)),
+		cb.Delete().After("gorm:delete").Register("ego:audit_after", after(auditOpDelete)),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("ego:audit_save", committed),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("ego:audit_save", committed),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("ego:audit_save", committed),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeEntries 按语句生成变更记录（审计与 webhook 共用）：创建取写入的记录，更新取回调前读出的旧值并按主键读回新值，
+// 删除只有旧值
+func changeEntries(db *gorm.DB, t *auditTable, op string) ([]auditEntry, error) {
+	tc, pk := t.tc, t.tc.PrimaryKey
+	var pairs [][2]map[string]interface{} // {old, new}
+	switch op {
+	case auditOpCreate:
+		if _, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+			op = auditOpUpsert
+		}
+		var records []map[string]interface{}
+		switch d := db.Statement.Dest.(type) {
+		case map[string]interface{}:
+			records = []map[string]interface{}{d}
+		case *map[string]interface{}:
+			records = []map[string]interface{}{*d}
+		case []map[string]interface{}:
+			records = d
+		case *[]map[string]interface{}:
+			records = *d
+		}
+		for _, r := range records {
+			created := make(map[string]interface{}, len(r))
+			for k, v := range r {
+				if k == idPlaceholder {
+					if r[pk] == nil {
+						created[pk] = v
+					}
+					continue
+				}
+				created[k] = v
+			}
+			pairs = append(pairs, [2]map[string]interface{}{nil, created})
+		}
+	case auditOpUpdate, auditOpDelete:
+		v, _ := db.InstanceGet(auditOldRowsKey)
+		old, _ := v.([]map[string]interface{})
+		if len(old) == 0 {
+			return nil, nil
+		}
+		current := map[string]map[string]interface{}{}
+		if op == auditOpUpdate {
+			keys := make([]interface{}, len(old))
+			for i, r := range old {
+				keys[i] = r[pk]
+			}
+			var rows []map[string]interface{}
+			tx := db.Session(&gorm.Session{NewDB: true})
+			if err := tx.Table(tc.Name).Where(clause.IN{Column: clause.Column{Name: pk}, Values: keys}).Find(&rows).Error; err != nil {
+				return nil, err
+			}
+			for _, r := range rows {
+				current[fmt.Sprint(r[pk])] = r
+			}
+			if tc.SoftDeleteKey != "" {
+				if data, ok := db.Statement.Dest.(map[string]interface{}); ok {
+					if _, set := data[tc.SoftDeleteKey]; set {
+						op = auditOpDelete
+					}
+				}
+			}
+		}
+		for _, r := range old {
+			pairs = append(pairs, [2]map[string]interface{}{r, current[fmt.Sprint(r[pk])]})
+		}
+	}
+	req, _ := db.Statement.Context.Value(auditRequestKey{}).(*auditRequest)
+	now := time.Now().UTC()
+	entries := make([]auditEntry, 0, len(pairs))
+	for _, p := range pairs {
+		e := auditEntry{Time: now, Database: t.dbName, Table: tc.Alias, Op: op, Old: auditValues(tc, p[0]), New: auditValues(tc, p[1])}
+		if r := p[1]; r != nil && r[pk] != nil {
+			e.RecordID = fmt.Sprint(r[pk])
+		} else if r := p[0]; r != nil {
+			e.RecordID = fmt.Sprint(r[pk])
+		}
+		if req != nil {
+			e.RequestID, e.Actor, e.Roles, e.ClientIP, e.Method, e.Path = req.ID, req.Actor, req.Roles, req.ClientIP, req.Method, req.Path
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// auditValues 复制记录并应用字段隐藏与脱敏，[]byte 转为字符串
+func auditValues(tc *tableConfig, record map[string]interface{}) map[string]interface{} {
+	if record == nil {
+		return nil
+	}
+	values := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		values[k] = v
+	}
+	return tc.applyFieldPolicy(values)
+}
+
+// save 写入 KVStore，key 按时间排序
+func (l *auditLog) save(entries []auditEntry) {
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("encode audit entry failed: %v", err)
+			continue
+		}
+		key := fmt.Sprintf("%s%020d:%d", auditKeyPrefix, e.Time.UnixNano(), l.seq.Add(1))
+		if err := l.store.Set([]byte(key), data, l.config.Retention); err != nil {
+			log.Printf("write audit entry failed: %v", err)
+		}
+	}
+}
+
+// ---- 查询 ----
+
+type auditQuery struct {
+	Database  string
+	Table     string
+	Op        string
+	Actor     string
+	RequestID string
+	RecordID  string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (q auditQuery) match(e auditEntry) bool {
+	return (q.Database == "" || e.Database == q.Database) &&
+		(q.Table == "" || e.Table == q.Table) &&
+		(q.Op == "" || e.Op == q.Op) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.RequestID == "" || e.RequestID == q.RequestID) &&
+		(q.RecordID == "" || e.RecordID == q.RecordID) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until))
+}
+
+func (q auditQuery) apply(db *gorm.DB) *gorm.DB {
+	for col, v := range map[string]string{"table_name": q.Table, "op": q.Op, "actor": q.Actor, "request_id": q.RequestID, "record_id": q.RecordID} {
+		if v != "" {
+			db = db.Where(clause.Eq{Column: clause.Column{Name: col}, Value: v})
+		}
+	}
+	if !q.Since.IsZero() {
+		db = db.Where(clause.Gte{Column: clause.Column{Name: "audit_time"}, Value: q.Since.UTC()})
+	}
+	if !q.Until.IsZero() {
+		db = db.Where(clause.Lt{Column: clause.Column{Name: "audit_time"}, Value: q.Until.UTC()})
+	}
+	return db
+}
+
+// query 按时间倒序分页读取审计记录
+func (l *auditLog) query(ctx context.Context, q auditQuery, page, pageSize int) ([]auditEntry, int64, error) {
+	var all []auditEntry
+	var total int64
+	if l.config.Sink == auditSinkStore {
+		err := l.store.Scan([]byte(auditKeyPrefix), func(_, v []byte) error {
+			var e auditEntry
+			if json.Unmarshal(v, &e) == nil && q.match(e) {
+				all = append(all, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		total = int64(len(all))
+	} else {
+		for name, dbs := range l.dbs {
+			if q.Database != "" && name != q.Database {
+				continue
+			}
+			for _, db := range dbs {
+				var n int64
+				var rows []auditRow
+				tx := db.WithContext(ctx)
+				if err := q.apply(tx.Table(l.config.Table)).Count(&n).Error; err != nil {
+					return nil, 0, err
+				}
+				// 多个库或分片时各取前 page*pageSize 条再合并
+				err := q.apply(tx.Table(l.config.Table)).Order("audit_time DESC, id DESC").Limit(page * pageSize).Find(&rows).Error
+				if err != nil {
+					return nil, 0, err
+				}
+				for _, r := range rows {
+					all = append(all, r.entry())
+				}
+				total += n
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.After(all[j].Time) })
+	start := (page - 1) * pageSize
+	if start >= len(all) {
+		return []auditEntry{}, total, nil
+	}
+	return all[start:min(start+pageSize, len(all))], total, nil
+}
+
+func (dm *databaseManager) handleAuditList(c *gin.Context) {
+	if !dm.authorize(c, nil, opAudit) {
+		return
+	}
+	if dm.audit == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audit log is not enabled"})
+		return
+	}
+	q := auditQuery{
+		Database: c.Query("database"), Table: c.Query("table"), Op: c.Query("op"), Actor: c.Query("actor"),
+		RequestID: c.Query("request_id"), RecordID: c.Query("record_id"),
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(name); v != "" {
+			parsed, ok := asTime(v, true)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %s, expected RFC3339 time", name, v)})
+				return
+			}
+			*t = parsed
+		}
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery(queryParamPage, strconv.Itoa(dm.config.DefaultPage)))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery(queryParamPageSize, strconv.Itoa(dm.config.DefaultPageSize)))
+	if page <= 0 {
+		page = dm.config.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = dm.config.DefaultPageSize
+	}
+	if pageSize > dm.config.MaxPageSize {
+		pageSize = dm.config.MaxPageSize
+	}
+	entries, total, err := dm.audit.query(c.Request.Context(), q, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "data": entries})
+}
@@ -0,0 +1,197 @@
+package apix
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 调用者身份与操作权限 ---------
+//
+// 调用者身份（principal）由认证中间件（auth.jwt、auth.api_keys，见 jwtauth.go、apikey.go）写入 gin.Context；未接入认证时，可信任上游网关透传的请求头：
+//
+//	auth:
+//	  actor_header: X-User           # 调用者标识
+//	  roles_header: X-Roles          # 逗号分隔的角色列表
+//	  operation_roles:               # 操作 → 允许的角色，未列出的操作不限制
+//	    delete_where: [admin, ops]
+//
+// 表配置中的 operation_roles 优先于全局配置；按条件批量变更（update_where、delete_where）与管理类操作
+// （如 aggregate_pipeline）未配置时默认要求 admin 角色。
+// 按角色限定可访问的库、表与方法见 rbac.go（auth.roles）。
+
+// 操作名，用于权限配置
+const (
+	opList        = "list"
+	opGet         = "get"
+	opCreate      = "create"
+	opUpdate      = "update"
+	opDelete      = "delete"
+	opUpdateWhere = "update_where"
+	opDeleteWhere = "delete_where"
+	opStats       = "stats"
+	opExport      = "export"
+	opManageJobs  = "manage_jobs"
+
+	opAggregatePipeline = "aggregate_pipeline"
+	opIndexAdvisor      = "index_advisor"
+	opSlowQueries       = "slow_queries"
+	opArchive           = "archive"
+	opRollup            = "rollup"
+	opGraphqlReload     = "graphql_reload"
+	opSwaggerRegen      = "swagger_regenerate"
+	opAliasReport       = "alias_report"
+	opInflightQueries   = "inflight_queries"
+	opIncludeDeleted    = "include_deleted"
+	opMaintenance       = "maintenance"
+	opMirror            = "mirror"
+	opShadowReads       = "shadow_reads"
+	opContract          = "contract"
+	opAudit             = "audit"
+	opMetrics           = "metrics"
+	opRecordings        = "recordings"
+	opWebhooks          = "webhooks"
+	opEvents            = "events"
+	opConfigReload      = "config_reload"
+	opMetaWarnings      = "meta_warnings"
+)
+
+// 管理类操作未配置 operation_roles 时默认只允许 admin
+const defaultAdminRole = "admin"
+
+var defaultOperationRoles = map[string][]string{
+	opUpdateWhere:       {defaultAdminRole},
+	opDeleteWhere:       {defaultAdminRole},
+	opManageJobs:        {defaultAdminRole},
+	opAggregatePipeline: {defaultAdminRole},
+	opIndexAdvisor:      {defaultAdminRole},
+	opSlowQueries:       {defaultAdminRole},
+	opArchive:           {defaultAdminRole},
+	opRollup:            {defaultAdminRole},
+	opGraphqlReload:     {defaultAdminRole},
+	opSwaggerRegen:      {defaultAdminRole},
+	opAliasReport:       {defaultAdminRole},
+	opInflightQueries:   {defaultAdminRole},
+	opIncludeDeleted:    {defaultAdminRole},
+	opMaintenance:       {defaultAdminRole},
+	opMirror:            {defaultAdminRole},
+	opShadowReads:       {defaultAdminRole},
+	opContract:          {defaultAdminRole},
+	opAudit:             {defaultAdminRole},
+	opMetrics:           {defaultAdminRole},
+	opRecordings:        {defaultAdminRole},
+	opWebhooks:          {defaultAdminRole},
+	opEvents:            {defaultAdminRole},
+	opConfigReload:      {defaultAdminRole},
+	opMetaWarnings:      {defaultAdminRole},
+}
+
+const ctxKeyPrincipal = "ego.principal"
+
+type authConfig struct {
+	ActorHeader    string                 `mapstructure:"actor_header"`
+	RolesHeader    string                 `mapstructure:"roles_header"`
+	OperationRoles map[string][]string    `mapstructure:"operation_roles"`
+	JWT            jwtConfig              `mapstructure:"jwt"`      // 见 jwtauth.go
+	APIKeys        apiKeysConfig          `mapstructure:"api_keys"` // 见 apikey.go
+	Roles          map[string][]roleGrant `mapstructure:"roles"`    // 见 rbac.go
+}
+
+type principal struct {
+	ID    string
+	Roles []string
+}
+
+func (p *principal) hasAnyRole(roles []string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range roles {
+		if contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func setPrincipal(c *gin.Context, p *principal) {
+	c.Set(ctxKeyPrincipal, p)
+}
+
+// currentPrincipal 返回当前请求的调用者，未认证时为 nil
+func (dm *databaseManager) currentPrincipal(c *gin.Context) *principal {
+	if v, ok := c.Get(ctxKeyPrincipal); ok {
+		if p, ok := v.(*principal); ok {
+			return p
+		}
+	}
+	return dm.headerPrincipal(c.GetHeader)
+}
+
+// headerPrincipal 按 actor_header、roles_header 取调用者，未配置或请求头为空时为 nil
+func (dm *databaseManager) headerPrincipal(header func(string) string) *principal {
+	var p principal
+	if dm.config.Auth.ActorHeader != "" {
+		p.ID = strings.TrimSpace(header(dm.config.Auth.ActorHeader))
+	}
+	if dm.config.Auth.RolesHeader != "" {
+		p.Roles = parseKeyFields(header(dm.config.Auth.RolesHeader))
+	}
+	if p.ID == "" && len(p.Roles) == 0 {
+		return nil
+	}
+	return &p
+}
+
+// graphqlPrincipal GraphQL 直连查询的调用者，来源与 REST 一致：API Key、token，或转发的 actor_header、roles_header
+func (dm *databaseManager) graphqlPrincipal(ctx context.Context, key *apiKeyConfig) *principal {
+	switch {
+	case key != nil:
+		return key.principal()
+	case dm.jwt != nil:
+		if claims := requestClaims(ctx); claims != nil {
+			return dm.jwt.principal(claims)
+		}
+		return nil
+	}
+	return dm.headerPrincipal(func(name string) string { return incomingHeader(ctx, name) })
+}
+
+// currentActor 返回调用者标识，未认证时为空
+func (dm *databaseManager) currentActor(c *gin.Context) string {
+	if p := dm.currentPrincipal(c); p != nil {
+		return p.ID
+	}
+	return ""
+}
+
+// operationRoles 返回执行某操作所需的角色，nil 表示不限制
+func (dm *databaseManager) operationRoles(tc *tableConfig, op string) []string {
+	if tc != nil {
+		if roles, ok := tc.OperationRoles[op]; ok {
+			return roles
+		}
+	}
+	if roles, ok := dm.config.Auth.OperationRoles[op]; ok {
+		return roles
+	}
+	return defaultOperationRoles[op]
+}
+
+// authorize 校验当前调用者能否执行操作，不允许时直接写 403 并返回 false
+func (dm *databaseManager) authorize(c *gin.Context, tc *tableConfig, op string) bool {
+	if dm.allowed(c, tc, op) {
+		return true
+	}
+	roles := dm.operationRoles(tc, op)
+	c.JSON(http.StatusForbidden, gin.H{"error": "Operation '" + op + "' requires one of roles: " + strings.Join(roles, ",")})
+	return false
+}
+
+// allowed 判断当前调用者能否执行操作，不写响应
+func (dm *databaseManager) allowed(c *gin.Context, tc *tableConfig, op string) bool {
+	roles := dm.operationRoles(tc, op)
+	return len(roles) == 0 || dm.currentPrincipal(c).hasAnyRole(roles)
+}
@@ -0,0 +1,293 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --------- 批量按键查询 POST /:database/:table/batch_get ---------
+//
+// 请求体支持三种形式，结果按输入顺序返回，未找到的位置为 null 并记入 not_found：
+//
+//	["1", "2", "3"]                                   // 主键（或 ?key=email 指定的单字段唯一键）
+//	[["138...", null], ["139...", null]]              // ?key=phone,deleted_time 指定的联合唯一键元组
+//	[{"email": "a@x.com"}, {"id": 5}]                 // 对象形式，字段组合须为主键或已配置的唯一键
+
+// batchGetter 为可选能力：适配器实现后单字段键可一次 IN 查询取回，否则逐条 GetOne
+type batchGetter interface {
+	BatchGet(ctx context.Context, tc *tableConfig, field string, values []interface{}, fields string) ([]map[string]interface{}, error)
+}
+
+// 单个待查键：字段与对应值一一对应
+type lookupKey struct {
+	Fields []string
+	Values []interface{}
+}
+
+func (k lookupKey) String() string {
+	parts := make([]string, len(k.Values))
+	for i, v := range k.Values {
+		parts[i] = keyValueString(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// keyValueString 统一键值的字符串形式，用于结果与输入的对应
+func keyValueString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case primitive.ObjectID:
+		return t.Hex()
+	case json.Number:
+		return t.String()
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// matchKeyFields 判断一组字段（无序）是否为主键或某个已配置的唯一键，返回其配置顺序
+func (tc *tableConfig) matchKeyFields(fields []string) ([]string, bool) {
+	if len(fields) == 1 && fields[0] == tc.PrimaryKey && tc.PrimaryKey != "" {
+		return fields, true
+	}
+	sorted := append([]string{}, fields...)
+	sort.Strings(sorted)
+	for _, uk := range tc.GetUniqueKeys() {
+		if len(uk) != len(sorted) {
+			continue
+		}
+		cand := append([]string{}, uk...)
+		sort.Strings(cand)
+		if strings.Join(cand, ",") == strings.Join(sorted, ",") {
+			return uk, true
+		}
+	}
+	return nil, false
+}
+
+// recordLookupKey 用对象中非空的字段匹配主键或唯一键，唯一键中的软删除字段可省略
+func (tc *tableConfig) recordLookupKey(rec map[string]interface{}) (lookupKey, bool) {
+	names := make([]string, 0, len(rec))
+	for k, v := range rec {
+		if v != nil {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, fields := range tc.conflictKeys() {
+		cand := append([]string{}, fields...)
+		sort.Strings(cand)
+		if strings.Join(cand, ",") != strings.Join(names, ",") {
+			continue
+		}
+		vals := make([]interface{}, len(fields))
+		for i, f := range fields {
+			vals[i] = rec[f]
+		}
+		return lookupKey{Fields: fields, Values: vals}, true
+	}
+	return lookupKey{}, false
+}
+
+func parseBatchGetKeys(body []byte, tc *tableConfig, keyFields []string) ([]lookupKey, error) {
+	var items []interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // 避免大整数（如 snowflake ID）精度丢失
+	if err := decoder.Decode(&items); err != nil {
+		return nil, fmt.Errorf("Invalid JSON payload. Expected array of IDs, key tuples or key objects: %s", err)
+	}
+	if len(keyFields) == 0 && tc.PrimaryKey != "" {
+		keyFields = []string{tc.PrimaryKey}
+	}
+	keys := make([]lookupKey, 0, len(items))
+	for i, item := range items {
+		switch v := item.(type) {
+		case map[string]interface{}:
+			v = tc.physicalRecord(v)
+			names := make([]string, 0, len(v))
+			for k := range v {
+				names = append(names, k)
+			}
+			ordered, ok := tc.matchKeyFields(names)
+			if !ok {
+				return nil, fmt.Errorf("item %d: fields %v are not the primary key or a configured unique key", i, tc.apiFieldNames(names))
+			}
+			vals := make([]interface{}, len(ordered))
+			for j, f := range ordered {
+				vals[j] = v[f]
+			}
+			keys = append(keys, lookupKey{Fields: ordered, Values: vals})
+		case []interface{}:
+			if len(v) != len(keyFields) {
+				return nil, fmt.Errorf("item %d: tuple size %d does not match key fields %v", i, len(v), keyFields)
+			}
+			keys = append(keys, lookupKey{Fields: keyFields, Values: v})
+		default:
+			if len(keyFields) != 1 {
+				return nil, fmt.Errorf("item %d: scalar id requires a single key field", i)
+			}
+			keys = append(keys, lookupKey{Fields: keyFields, Values: []interface{}{v}})
+		}
+	}
+	return keys, nil
+}
+
+func (dm *databaseManager) handleBatchGet(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	keyFields := tableConfig.physicalFieldNames(parseKeyFields(c.Query(queryParamKey)))
+	if len(keyFields) > 0 && !tableConfig.IsValidKeyCombination(keyFields) && !(len(keyFields) == 1 && keyFields[0] == tableConfig.PrimaryKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", tableConfig.apiFieldNames(keyFields))})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Read body failed"})
+		return
+	}
+	keys, err := parseBatchGetKeys(body, tableConfig, keyFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(keys) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No IDs provided"})
+		return
+	}
+	if len(keys) > dm.config.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many IDs, max %d", dm.config.MaxPageSize)})
+		return
+	}
+	fields := tableConfig.physicalFieldList(c.Query(queryParamFields))
+	results, err := batchGetRecords(c.Request.Context(), adapter, tableConfig, keys, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to batch get: " + err.Error()})
+		return
+	}
+	data := make([]interface{}, len(keys))
+	notFound := []interface{}{}
+	for i, k := range keys {
+		if results[i] == nil {
+			notFound = append(notFound, k.String())
+			continue
+		}
+		data[i] = tableConfig.renderRecord(fixPkFieldToString(results[i], tableConfig.PrimaryKey).(map[string]interface{}))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data, "found": len(keys) - len(notFound), "not_found": notFound})
+}
+
+// batchGetRecords 返回与 keys 等长的结果，未找到的位置为 nil
+func batchGetRecords(ctx context.Context, adapter databaseAdapter, tc *tableConfig, keys []lookupKey, fields string) ([]map[string]interface{}, error) {
+	results := make([]map[string]interface{}, len(keys))
+	// 单字段键按字段分组走批量查询
+	groups := map[string][]int{}
+	bg, canBatch := adapter.(batchGetter)
+	for i, k := range keys {
+		if canBatch && len(k.Fields) == 1 {
+			groups[k.Fields[0]] = append(groups[k.Fields[0]], i)
+			continue
+		}
+		filter := make(map[string]interface{}, len(k.Fields))
+		for j, f := range k.Fields {
+			filter[f] = k.Values[j]
+		}
+		record, err := adapter.GetOne(ctx, tc, filter, fields)
+		if err != nil {
+			if isNotFoundErr(err) {
+				continue
+			}
+			return nil, err
+		}
+		results[i] = record
+	}
+	for field, idxs := range groups {
+		values := make([]interface{}, 0, len(idxs))
+		for _, i := range idxs {
+			values = append(values, keys[i].Values[0])
+		}
+		selectFields := fields
+		if selectFields != "" && !contains(parseStringList(selectFields), field) {
+			selectFields += "," + field
+		}
+		records, err := bg.BatchGet(ctx, tc, field, values, selectFields)
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string]map[string]interface{}, len(records))
+		for _, r := range records {
+			byKey[keyValueString(r[field])] = r
+		}
+		for _, i := range idxs {
+			results[i] = byKey[keyValueString(keys[i].Values[0])]
+		}
+	}
+	return results, nil
+}
+
+func (a *gormAdapter) BatchGet(ctx context.Context, tc *tableConfig, field string, values []interface{}, fields string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	db := a.db.WithContext(ctx).Table(tc.Name)
+	db = applyGormSoftDeleteFilter(db, tc)
+	if fields != "" {
+		db = db.Select(fields)
+	}
+	if err := db.Where(fmt.Sprintf("%s IN (?)", field), values).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (a *mongoAdapter) BatchGet(ctx context.Context, tc *tableConfig, field string, values []interface{}, fields string) ([]map[string]interface{}, error) {
+	collection := a.collection(ctx, tc)
+	converted := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if field == "_id" {
+			if str, ok := v.(string); ok && len(str) == 24 {
+				if oid, err := primitive.ObjectIDFromHex(str); err == nil {
+					v = oid
+				}
+			}
+		}
+		converted = append(converted, v)
+	}
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{field: bson.M{"$in": converted}}, tc)
+	opts := options.Find()
+	if fields != "" {
+		projection := bson.M{}
+		for _, f := range strings.Split(fields, ",") {
+			projection[strings.TrimSpace(f)] = 1
+		}
+		opts.SetProjection(projection)
+	}
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var results []map[string]interface{}
+	for cur.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		results = append(results, doc)
+	}
+	return results, cur.Err()
+}
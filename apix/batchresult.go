@@ -0,0 +1,111 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 批量操作逐条结果 ---------
+//
+// 批量创建/更新/删除支持 ?results=per_record（或请求头 Prefer: results=per_record），
+// 响应中附带与请求顺序一致的逐条结果，客户端无需比对计数即可知道每条记录的去向：
+//
+//	{"results": [{"index": 0, "status": "updated", "id": "1"}, {"index": 1, "status": "not_found", "id": "9"}], ...}
+//
+// status 取值：created、updated、deleted、not_found、failed（仅 on_error=continue 时出现，附带 error）。
+// 批量创建原本直接返回记录数组，开启后改为 {"data": [...], "results": [...]}。
+
+const (
+	queryParamResults = "results"
+	resultsPerRecord  = "per_record"
+
+	recordStatusCreated  = "created"
+	recordStatusUpdated  = "updated"
+	recordStatusDeleted  = "deleted"
+	recordStatusNotFound = "not_found"
+	recordStatusFailed   = "failed"
+)
+
+type recordResult struct {
+	Index  int         `json:"index"`
+	Status string      `json:"status"`
+	ID     interface{} `json:"id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// wantsPerRecordResults 解析 results 参数，未传时读取 Prefer 请求头
+func wantsPerRecordResults(c *gin.Context) (bool, error) {
+	switch v := preference(c, queryParamResults); v {
+	case "":
+		return false, nil
+	case resultsPerRecord:
+		c.Writer.Header().Add(headerPreferenceApplied, queryParamResults+"="+resultsPerRecord)
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %s, expected %s", queryParamResults, v, resultsPerRecord)
+	}
+}
+
+// recordID 取出主键值，按响应惯例把整数主键转成字符串
+func recordID(tc *tableConfig, id interface{}) interface{} {
+	if id == nil {
+		return nil
+	}
+	return fixPkFieldToString(map[string]interface{}{tc.PrimaryKey: id}, tc.PrimaryKey).(map[string]interface{})[tc.PrimaryKey]
+}
+
+// existingRecords 按主键检查记录是否存在，结果与 ids 等长；nil 视为不存在
+func existingRecords(ctx context.Context, adapter databaseAdapter, tc *tableConfig, ids []interface{}) ([]bool, error) {
+	var keys []lookupKey
+	var idx []int
+	for i, id := range ids {
+		if id != nil {
+			keys = append(keys, lookupKey{Fields: []string{tc.PrimaryKey}, Values: []interface{}{id}})
+			idx = append(idx, i)
+		}
+	}
+	exists := make([]bool, len(ids))
+	if len(keys) == 0 {
+		return exists, nil
+	}
+	found, err := batchGetRecords(ctx, adapter, tc, keys, tc.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	for j, r := range found {
+		exists[idx[j]] = r != nil
+	}
+	return exists, nil
+}
+
+// existenceResults 根据记录是否存在生成更新/删除的逐条结果
+func existenceResults(tc *tableConfig, ids []interface{}, exists []bool, status string) []recordResult {
+	results := make([]recordResult, len(ids))
+	for i, id := range ids {
+		results[i] = recordResult{Index: i, Status: status, ID: recordID(tc, id)}
+		if !exists[i] {
+			results[i].Status = recordStatusNotFound
+		}
+	}
+	return results
+}
+
+// createResults 生成批量创建的逐条结果；created 中为 nil 的位置视为失败
+func createResults(tc *tableConfig, ids []interface{}, created []map[string]interface{}, failures []recordFailure) []recordResult {
+	results := make([]recordResult, len(created))
+	for i, r := range created {
+		var id interface{}
+		if i < len(ids) && ids[i] != nil {
+			id = ids[i]
+		} else if r != nil {
+			id = r[tc.PrimaryKey]
+		}
+		results[i] = recordResult{Index: i, Status: recordStatusCreated, ID: recordID(tc, id)}
+	}
+	for _, f := range failures {
+		results[f.Index] = recordResult{Index: f.Index, Status: recordStatusFailed, Error: f.Error}
+	}
+	return results
+}
@@ -0,0 +1,734 @@
+package apix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"gorm.io/gorm"
+)
+
+// --------- BigQuery Adapter 实现 ---------
+//
+// 数据库配置：
+//
+//	type: bigquery
+//	dsn: "bigquery://my-project?location=US&credentials_file=/etc/ego/sa.json"
+//	database: my_dataset
+//
+// 未指定 credentials_file 时使用 Application Default Credentials。
+// 列表与查询使用带命名参数的标准 SQL，创建使用流式插入（insertAll），更新/删除使用 DML。
+// BigQuery 对同一表的并发 DML 有配额限制，adapter 内串行执行 DML，并在限流错误时退避重试；
+// 流式缓冲区中的数据暂时不能被 DML 修改，此时返回 BigQuery 的原始错误。
+// 列表过滤使用不支持的操作符或取值不合法时返回 400；数据集超过 500 张表时只提取前 500 张，其余记入元数据告警。
+
+const (
+	bigqueryDMLRetries    = 5
+	bigqueryDMLRetryDelay = 2 * time.Second
+	bigqueryMaxTables     = 500 // 元数据提取的表数量上限，与其他库的 LIMIT 500 一致
+)
+
+var bigqueryIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseBigQueryDSN 解析 bigquery://project?location=..&credentials_file=..，也接受直接写项目 ID
+func parseBigQueryDSN(dsn string) (project, location string, opts []option.ClientOption, err error) {
+	if !strings.Contains(dsn, "://") {
+		return dsn, "", nil, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if u.Scheme != "bigquery" || u.Host == "" {
+		return "", "", nil, fmt.Errorf("expected bigquery://project")
+	}
+	if f := u.Query().Get("credentials_file"); f != "" {
+		opts = append(opts, option.WithCredentialsFile(f))
+	}
+	return u.Host, u.Query().Get("location"), opts, nil
+}
+
+func newBigQueryClient(ctx context.Context, dsn string) (*bigquery.Client, error) {
+	project, location, opts, err := parseBigQueryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	client, err := bigquery.NewClient(ctx, project, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client.Location = location
+	return client, nil
+}
+
+type bigqueryAdapter struct {
+	client  *bigquery.Client
+	dataset string
+	config  *databaseConfig
+	dmlMu   sync.Mutex
+}
+
+func newBigQueryAdapter(client *bigquery.Client, dataset string, cfg *databaseConfig) *bigqueryAdapter {
+	return &bigqueryAdapter{client: client, dataset: dataset, config: cfg}
+}
+
+func bigqueryQuote(name string) (string, error) {
+	if !bigqueryIdent.MatchString(name) {
+		return "", fmt.Errorf("invalid field name: %s", name)
+	}
+	return "`" + name + "`", nil
+}
+
+func (a *bigqueryAdapter) tableRef(tc *tableConfig) string {
+	return fmt.Sprintf("`%s.%s.%s`", a.client.Project(), a.dataset, tc.Name)
+}
+
+// bigqueryWhere 累积 WHERE 条件与命名参数
+type bigqueryWhere struct {
+	conds  []string
+	params []bigquery.QueryParameter
+}
+
+func (w *bigqueryWhere) param(v interface{}) string {
+	name := fmt.Sprintf("p%d", len(w.params))
+	w.params = append(w.params, bigquery.QueryParameter{Name: name, Value: bigqueryParamValue(v)})
+	return "@" + name
+}
+
+func (w *bigqueryWhere) add(cond string) {
+	w.conds = append(w.conds, cond)
+}
+
+func (w *bigqueryWhere) sql() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// bigqueryParamValue 把 JSON 解码得到的值转换为 BigQuery 参数类型（整数值的 float64 转为 INT64，复杂值序列化为 JSON）
+func bigqueryParamValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+		return val
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case int:
+		return int64(val)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return v
+	}
+}
+
+// bigqueryArrayParam 把 IN 查询的值转换为同类型数组
+func bigqueryArrayParam(values []interface{}) interface{} {
+	ints := make([]int64, 0, len(values))
+	for _, v := range values {
+		i, ok := bigqueryParamValue(v).(int64)
+		if !ok {
+			strs := make([]string, len(values))
+			for j, s := range values {
+				strs[j] = fmt.Sprint(s)
+			}
+			return strs
+		}
+		ints = append(ints, i)
+	}
+	return ints
+}
+
+func (w *bigqueryWhere) softDelete(ctx context.Context, tc *tableConfig) error {
+	if tc.SoftDeleteKey == "" || includeDeleted(ctx) {
+		return nil
+	}
+	col, err := bigqueryQuote(tc.SoftDeleteKey)
+	if err != nil {
+		return err
+	}
+	switch tc.SoftDeleteType {
+	case softDeleteTypeBoolean:
+		w.add(fmt.Sprintf("(%s IS NULL OR %s = FALSE)", col, col))
+	case softDeleteTypeInt:
+		w.add(fmt.Sprintf("(%s IS NULL OR %s = 0)", col, col))
+	default:
+		w.add(fmt.Sprintf("%s IS NULL", col))
+	}
+	return nil
+}
+
+func (w *bigqueryWhere) equals(filter map[string]interface{}) error {
+	for k, v := range filter {
+		col, err := bigqueryQuote(k)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			w.add(col + " IS NULL")
+			continue
+		}
+		w.add(fmt.Sprintf("%s = %s", col, w.param(v)))
+	}
+	return nil
+}
+
+// queryFilters 与 applyGormQueryFilters 支持相同的操作符
+func (w *bigqueryWhere) queryFilters(filters url.Values) (bool, error) {
+	hasFilter := false
+	for key, values := range filters {
+		if isReservedQueryParam(key) || len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		hasFilter = true
+		fieldName, op := key, "="
+		if strings.Contains(key, "__") {
+			parts := strings.SplitN(key, "__", 2)
+			fieldName, op = parts[0], "__"+parts[1]
+		}
+		col, err := bigqueryQuote(fieldName)
+		if err != nil {
+			return false, &invalidFilterError{Key: key, Reason: err.Error()}
+		}
+		parsedVal := parseFilterValue(value)
+		switch op {
+		case "=", "__eq":
+			w.add(fmt.Sprintf("%s = %s", col, w.param(parsedVal)))
+		case "__gte":
+			w.add(fmt.Sprintf("%s >= %s", col, w.param(parsedVal)))
+		case "__lte":
+			w.add(fmt.Sprintf("%s <= %s", col, w.param(parsedVal)))
+		case "__gt":
+			w.add(fmt.Sprintf("%s > %s", col, w.param(parsedVal)))
+		case "__lt":
+			w.add(fmt.Sprintf("%s < %s", col, w.param(parsedVal)))
+		case "__ne":
+			w.add(fmt.Sprintf("%s <> %s", col, w.param(parsedVal)))
+		case "__like":
+			w.add(fmt.Sprintf("%s LIKE %s", col, w.param(normalizeLikeValue(value))))
+		case "__icontains":
+			w.add(fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", col, w.param("%"+normalizeLikeValue(value)+"%")))
+		case "__in":
+			w.add(fmt.Sprintf("%s IN UNNEST(%s)", col, w.param(bigqueryArrayParam(parseFilterValues(value)))))
+		case "__isnull":
+			bVal, ok := parsedVal.(bool)
+			if !ok {
+				return false, &invalidFilterError{Key: key, Reason: "expected true or false"}
+			}
+			if bVal {
+				w.add(col + " IS NULL")
+			} else {
+				w.add(col + " IS NOT NULL")
+			}
+		case "__between":
+			vals := parseFilterValues(value)
+			if len(vals) != 2 {
+				return false, &invalidFilterError{Key: key, Reason: "expected two comma separated values"}
+			}
+			w.add(fmt.Sprintf("%s BETWEEN %s AND %s", col, w.param(vals[0]), w.param(vals[1])))
+		default:
+			// 忽略未知操作符会放宽查询条件
+			return false, &invalidFilterError{Key: key, Reason: "unsupported operator " + strings.TrimPrefix(op, "__")}
+		}
+	}
+	return hasFilter, nil
+}
+
+func (a *bigqueryAdapter) selectList(fields string) (string, error) {
+	if fields == "" {
+		return "*", nil
+	}
+	var cols []string
+	for _, f := range strings.Split(fields, ",") {
+		col, err := bigqueryQuote(strings.TrimSpace(f))
+		if err != nil {
+			return "", err
+		}
+		cols = append(cols, col)
+	}
+	return strings.Join(cols, ", "), nil
+}
+
+func (a *bigqueryAdapter) query(ctx context.Context, sql string, params []bigquery.QueryParameter) ([]map[string]interface{}, error) {
+	q := a.client.Query(sql)
+	q.Parameters = params
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := []map[string]interface{}{}
+	for {
+		var row map[string]bigquery.Value
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			record[k] = bigqueryValue(v)
+		}
+		results = append(results, record)
+	}
+	return results, nil
+}
+
+// bigqueryValue 把 BigQuery 返回值转换为可 JSON 序列化的值
+func bigqueryValue(v bigquery.Value) interface{} {
+	switch val := v.(type) {
+	case civil.Date:
+		return val.String()
+	case civil.DateTime:
+		return val.String()
+	case civil.Time:
+		return val.String()
+	case *big.Rat:
+		if val == nil {
+			return nil
+		}
+		f, _ := val.Float64()
+		return f
+	case []bigquery.Value:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = bigqueryValue(item)
+		}
+		return items
+	case map[string]bigquery.Value:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = bigqueryValue(item)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+func (a *bigqueryAdapter) count(ctx context.Context, tc *tableConfig, w *bigqueryWhere) (int64, error) {
+	rows, err := a.query(ctx, "SELECT COUNT(*) AS cnt FROM "+a.tableRef(tc)+w.sql(), w.params)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	cnt, _ := rows[0]["cnt"].(int64)
+	return cnt, nil
+}
+
+// execDML 串行执行 DML，遇到限流错误时指数退避重试，返回影响行数
+func (a *bigqueryAdapter) execDML(ctx context.Context, sql string, params []bigquery.QueryParameter) (int64, error) {
+	a.dmlMu.Lock()
+	defer a.dmlMu.Unlock()
+	var lastErr error
+	for attempt := 0; attempt < bigqueryDMLRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(bigqueryDMLRetryDelay << (attempt - 1)):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+		affected, err := a.runDML(ctx, sql, params)
+		if err == nil || !isBigQueryRateLimit(err) {
+			return affected, err
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+func (a *bigqueryAdapter) runDML(ctx context.Context, sql string, params []bigquery.QueryParameter) (int64, error) {
+	q := a.client.Query(sql)
+	q.Parameters = params
+	job, err := q.Run(ctx)
+	if err != nil {
+		return 0, err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := status.Err(); err != nil {
+		return 0, err
+	}
+	if stats, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok {
+		return stats.NumDMLAffectedRows, nil
+	}
+	return 0, nil
+}
+
+func isBigQueryRateLimit(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		for _, e := range apiErr.Errors {
+			if e.Reason == "rateLimitExceeded" || e.Reason == "quotaExceeded" {
+				return true
+			}
+		}
+		return apiErr.Code == 429
+	}
+	var bqErr *bigquery.Error
+	if errors.As(err, &bqErr) {
+		return bqErr.Reason == "rateLimitExceeded" || bqErr.Reason == "quotaExceeded"
+	}
+	return false
+}
+
+// setClause 生成 UPDATE 的 SET 部分，nil 值写为 NULL
+func (w *bigqueryWhere) setClause(data map[string]interface{}) (string, error) {
+	sets := make([]string, 0, len(data))
+	for k, v := range data {
+		col, err := bigqueryQuote(k)
+		if err != nil {
+			return "", err
+		}
+		if v == nil {
+			sets = append(sets, col+" = NULL")
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", col, w.param(v)))
+	}
+	return strings.Join(sets, ", "), nil
+}
+
+func softDeleteValue(tc *tableConfig) interface{} {
+	switch tc.SoftDeleteType {
+	case softDeleteTypeBoolean:
+		return true
+	case softDeleteTypeInt:
+		return 1
+	default:
+		return time.Now()
+	}
+}
+
+func (a *bigqueryAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	w := &bigqueryWhere{}
+	if err := w.softDelete(ctx, tc); err != nil {
+		return nil, 0, err
+	}
+	hasFilter, err := w.queryFilters(params.QueryFilters)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if hasFilter || includeDeleted(ctx) {
+		if total, err = a.count(ctx, tc, w); err != nil {
+			return nil, 0, fmt.Errorf("failed to count records: %w", err)
+		}
+	}
+	cols, err := a.selectList(params.Fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s%s", cols, a.tableRef(tc), w.sql())
+	order := params.Order
+	if order == "" {
+		// 无 ORDER BY 时分页结果不稳定
+		order = tc.PrimaryKey
+	}
+	if order != "" {
+		dir := "ASC"
+		if strings.HasPrefix(order, "-") {
+			order, dir = order[1:], "DESC"
+		}
+		col, err := bigqueryQuote(order)
+		if err != nil {
+			return nil, 0, err
+		}
+		sql += fmt.Sprintf(" ORDER BY %s %s", col, dir)
+	}
+	sql += fmt.Sprintf(" LIMIT %d OFFSET %d", params.PageSize, (params.Page-1)*params.PageSize)
+	results, err := a.query(ctx, sql, w.params)
+	if err != nil {
+		return nil, total, fmt.Errorf("failed to query database: %w", err)
+	}
+	return results, total, nil
+}
+
+// bigqueryRow 实现 ValueSaver，用于流式插入 map 记录
+type bigqueryRow map[string]interface{}
+
+func (r bigqueryRow) Save() (map[string]bigquery.Value, string, error) {
+	row := make(map[string]bigquery.Value, len(r))
+	for k, v := range r {
+		row[k] = v
+	}
+	return row, bigquery.NoDedupeID, nil
+}
+
+func (a *bigqueryAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
+	rows := make([]bigqueryRow, len(records))
+	for i, r := range records {
+		rows[i] = bigqueryRow(r)
+	}
+	if err := a.client.Dataset(a.dataset).Table(tc.Name).Inserter().Put(ctx, rows); err != nil {
+		return nil, nil, err
+	}
+	return nil, records, nil
+}
+
+func (a *bigqueryAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
+	var totalAffected int64
+	pkField := tc.PrimaryKey
+	for _, record := range records {
+		idVal, ok := record[pkField]
+		if !ok {
+			return totalAffected, totalAffected, fmt.Errorf("record missing primary key '%s'", pkField)
+		}
+		data := make(map[string]interface{})
+		for k, v := range record {
+			if k != pkField {
+				data[k] = v
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+		affected, err := a.updateWhere(ctx, tc, map[string]interface{}{pkField: idVal}, data, false)
+		if err != nil {
+			return totalAffected, totalAffected, err
+		}
+		totalAffected += affected
+	}
+	return totalAffected, totalAffected, nil
+}
+
+func (a *bigqueryAdapter) updateWhere(ctx context.Context, tc *tableConfig, filter, data map[string]interface{}, excludeDeleted bool) (int64, error) {
+	w := &bigqueryWhere{}
+	set, err := w.setClause(data)
+	if err != nil {
+		return 0, err
+	}
+	if excludeDeleted {
+		if err := w.softDelete(ctx, tc); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.equals(filter); err != nil {
+		return 0, err
+	}
+	if len(w.conds) == 0 {
+		return 0, fmt.Errorf("refusing to update without conditions")
+	}
+	return a.execDML(ctx, fmt.Sprintf("UPDATE %s SET %s%s", a.tableRef(tc), set, w.sql()), w.params)
+}
+
+func (a *bigqueryAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
+	pk, err := bigqueryQuote(tc.PrimaryKey)
+	if err != nil {
+		return 0, err
+	}
+	w := &bigqueryWhere{}
+	if tc.SoftDeleteKey != "" {
+		set, err := w.setClause(map[string]interface{}{tc.SoftDeleteKey: softDeleteValue(tc)})
+		if err != nil {
+			return 0, err
+		}
+		w.add(fmt.Sprintf("%s IN UNNEST(%s)", pk, w.param(bigqueryArrayParam(ids))))
+		return a.execDML(ctx, fmt.Sprintf("UPDATE %s SET %s%s", a.tableRef(tc), set, w.sql()), w.params)
+	}
+	w.add(fmt.Sprintf("%s IN UNNEST(%s)", pk, w.param(bigqueryArrayParam(ids))))
+	return a.execDML(ctx, "DELETE FROM "+a.tableRef(tc)+w.sql(), w.params)
+}
+
+func (a *bigqueryAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
+	w := &bigqueryWhere{}
+	if err := w.softDelete(ctx, tc); err != nil {
+		return nil, err
+	}
+	if err := w.equals(filter); err != nil {
+		return nil, err
+	}
+	cols, err := a.selectList(fields)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.query(ctx, fmt.Sprintf("SELECT %s FROM %s%s LIMIT 1", cols, a.tableRef(tc), w.sql()), w.params)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+// exists 用于影响行数为 0 时区分记录不存在与值未变化
+func (a *bigqueryAdapter) exists(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (bool, error) {
+	w := &bigqueryWhere{}
+	if err := w.equals(filter); err != nil {
+		return false, err
+	}
+	cnt, err := a.count(ctx, tc, w)
+	return cnt > 0, err
+}
+
+func (a *bigqueryAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
+	affected, err := a.updateWhere(ctx, tc, filter, data, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	if affected == 0 {
+		found, err := a.exists(ctx, tc, filter)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !found {
+			return 0, 0, gorm.ErrRecordNotFound
+		}
+	}
+	return affected, affected, nil
+}
+
+func (a *bigqueryAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
+	var affected int64
+	var err error
+	if tc.SoftDeleteKey != "" {
+		affected, err = a.updateWhere(ctx, tc, filter, map[string]interface{}{tc.SoftDeleteKey: softDeleteValue(tc)}, false)
+	} else {
+		w := &bigqueryWhere{}
+		if err := w.equals(filter); err != nil {
+			return 0, err
+		}
+		if len(w.conds) == 0 {
+			return 0, fmt.Errorf("refusing to delete without conditions")
+		}
+		affected, err = a.execDML(ctx, "DELETE FROM "+a.tableRef(tc)+w.sql(), w.params)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		found, err := a.exists(ctx, tc, filter)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, gorm.ErrRecordNotFound
+		}
+	}
+	return affected, nil
+}
+
+func (a *bigqueryAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
+	w := &bigqueryWhere{}
+	if err := w.softDelete(ctx, tc); err != nil {
+		return 0, err
+	}
+	return a.count(ctx, tc, w)
+}
+
+func (a *bigqueryAdapter) Close() error {
+	return a.client.Close()
+}
+
+// ---- BigQuery 元数据 ----
+func extractBigQueryMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	client, err := newBigQueryClient(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open bigquery dataset %s failed: %w", dbName, err)
+	}
+	defer client.Close()
+	var tables []TableMeta
+	it := client.Dataset(dbName).Tables(ctx)
+	for {
+		t, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		md, err := t.Metadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if md.Type != bigquery.RegularTable {
+			continue
+		}
+		if len(tables) == bigqueryMaxTables {
+			// 超出上限的表不生成配置，记录告警而不是静默截断
+			in.skipTable(t.TableID, fmt.Errorf("dataset has more than %d tables, this and the remaining tables are not extracted", bigqueryMaxTables))
+			break
+		}
+		tm := TableMeta{Name: t.TableID, Comment: md.Description}
+		for _, fs := range md.Schema {
+			f := FieldMeta{
+				Name:     fs.Name,
+				Type:     string(fs.Type),
+				Nullable: !fs.Required,
+				Comment:  fs.Description,
+			}
+			if fs.Repeated {
+				f.Type = "ARRAY<" + f.Type + ">"
+			}
+			f.HasDefault = fs.DefaultValueExpression != ""
+			f.Default = convertDefaultByType(fs.DefaultValueExpression, f.Type, f.Nullable)
+			tm.Fields = append(tm.Fields, f)
+		}
+		// 主键约束不强制执行，作为逻辑主键使用
+		if md.TableConstraints != nil && md.TableConstraints.PrimaryKey != nil {
+			cols := md.TableConstraints.PrimaryKey.Columns
+			for j := range tm.Fields {
+				for _, col := range cols {
+					if tm.Fields[j].Name == col {
+						tm.Fields[j].IsPrimary = true
+					}
+				}
+			}
+			if len(cols) == 1 {
+				tm.PrimaryKey = cols[0]
+			} else if len(cols) > 1 {
+				tm.UniqueKeys = [][]string{cols}
+			}
+		}
+		tm.DefaultVals = collectDefaultValueFields(tm.Fields, tm.PrimaryKey)
+		for _, f := range tm.Fields {
+			if isSoftDelField(f.Name) {
+				tm.SoftDelKey = f.Name
+				tm.SoftDelType = guessSoftDelType(f.Type)
+				break
+			}
+		}
+		autoUpdate := map[string]interface{}{}
+		for _, f := range tm.Fields {
+			if isAutoUpdateField(f.Name) && isTimeType(f.Type) {
+				autoUpdate[f.Name] = "{{now}}"
+			}
+		}
+		if len(autoUpdate) > 0 {
+			tm.AutoUpdate = autoUpdate
+		}
+		tables = append(tables, tm)
+	}
+	return tables, nil
+}
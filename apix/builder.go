@@ -0,0 +1,273 @@
+package apix
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 注册构建器 ---------
+//
+// 按需挂载 REST、GraphQL 与 Swagger，并返回可管理生命周期的句柄：
+//
+//	h, err := apix.New("./cfgs").
+//		WithRest(apix.RestOptions{}).
+//		WithGraphql(apix.GraphqlOptions{RestBaseURL: "http://localhost:8080"}).
+//		WithSwagger(apix.SwaggerOptions{}).
+//		Mount(router)
+//	defer h.Close()
+//	h.Reload() // 重新生成 swagger.yaml，校验通过后切换到新配置并重建 GraphQL schema
+//
+// 各项的前缀留空时使用默认值（/api/rest、/api/graphql、/graphiql、/swagger、/admin）。
+// WithAdminUI 挂载数据浏览页面（见 adminui.go），需要同时挂载 REST。
+// 同时挂载 REST 时 GraphQL 查询直接调用数据库适配器（见 graphqlnative.go）；
+// 未挂载 REST 或 Resolver 为 proxy 时通过 HTTP 代理到 REST，RestBaseURL 必须指向已挂载 REST 的服务地址。
+
+const (
+	defaultRestPrefix     = "/api/rest"
+	defaultGraphqlPrefix  = "/api/graphql"
+	defaultGraphiQLPrefix = "/graphiql"
+	defaultSwaggerPrefix  = "/swagger"
+	defaultAdminUIPrefix  = "/admin"
+)
+
+type RestOptions struct {
+	Prefix string
+}
+
+type GraphqlOptions struct {
+	Prefix         string // 每个库挂载在 {Prefix}/{库别名}
+	GraphiQLPrefix string
+	NoGraphiQL     bool
+	RestBaseURL    string // 如 http://localhost:8080，proxy 模式使用
+	Resolver       string // native | proxy，为空时取 _base.yaml 的 graphql.resolver，默认 native
+}
+
+type SwaggerOptions struct {
+	Prefix string
+}
+
+type Builder struct {
+	cfgs    string
+	rest    *RestOptions
+	graphql *GraphqlOptions
+	swagger *SwaggerOptions
+	adminUI *AdminUIOptions
+}
+
+// Handle 由 Mount 返回，用于重新加载与关闭
+type Handle struct {
+	cfgs       string
+	restPrefix string
+	dm         *databaseManager
+	endpoints  []*graphqlEndpoint
+	stopWatch  func()
+}
+
+func New(cfgs string) *Builder {
+	return &Builder{cfgs: cfgs}
+}
+
+func (b *Builder) WithRest(opts RestOptions) *Builder {
+	if opts.Prefix == "" {
+		opts.Prefix = defaultRestPrefix
+	}
+	b.rest = &opts
+	return b
+}
+
+func (b *Builder) WithGraphql(opts GraphqlOptions) *Builder {
+	if opts.Prefix == "" {
+		opts.Prefix = defaultGraphqlPrefix
+	}
+	if opts.GraphiQLPrefix == "" {
+		opts.GraphiQLPrefix = defaultGraphiQLPrefix
+	}
+	b.graphql = &opts
+	return b
+}
+
+func (b *Builder) WithSwagger(opts SwaggerOptions) *Builder {
+	if opts.Prefix == "" {
+		opts.Prefix = defaultSwaggerPrefix
+	}
+	b.swagger = &opts
+	return b
+}
+
+// Mount 生成 swagger.yaml 并挂载已选择的部分，失败时释放已创建的资源
+func (b *Builder) Mount(router *gin.Engine) (*Handle, error) {
+	if b.rest == nil && b.graphql == nil && b.swagger == nil {
+		return nil, errors.New("nothing to mount: call WithRest, WithGraphql or WithSwagger first")
+	}
+	if b.adminUI != nil && b.rest == nil {
+		return nil, errors.New("admin UI requires REST: call WithRest")
+	}
+	if b.graphql != nil && b.graphql.RestBaseURL == "" && b.rest == nil {
+		return nil, errors.New("graphql requires RestBaseURL when REST is not mounted")
+	}
+	h := &Handle{cfgs: b.cfgs, restPrefix: defaultRestPrefix, stopWatch: func() {}}
+	if b.rest != nil {
+		h.restPrefix = b.rest.Prefix
+	}
+
+	// 解析数据元信息（多库），swagger 与 GraphQL 均依赖生成的 swagger.yaml
+	if err := ExtractDbMeta(b.cfgs, h.restPrefix); err != nil {
+		return nil, err
+	}
+
+	if b.rest != nil {
+		dm, err := mountRestAPI(router, b.rest.Prefix, b.cfgs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database manager: %w", err)
+		}
+		h.dm = dm
+		if b.adminUI != nil {
+			mountAdminUI(router, b.adminUI.Prefix, b.rest.Prefix, path.Join(path.Dir(b.rest.Prefix), "admin", "browser"))
+		}
+	}
+
+	if b.swagger != nil {
+		RegisterSwaggerUI(router, b.swagger.Prefix, b.cfgs)
+	}
+
+	if b.graphql != nil {
+		if err := h.mountGraphql(router, *b.graphql); err != nil {
+			h.Close()
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *Handle) mountGraphql(router *gin.Engine, opts GraphqlOptions) error {
+	gqlOpts := readGraphqlOptions(h.cfgs)
+	resolver := opts.Resolver
+	if resolver == "" {
+		resolver = gqlOpts.Resolver
+	}
+	var resolvers graphqlResolvers
+	switch resolver {
+	case "", graphqlResolverNative, graphqlResolverProxy:
+	default:
+		return fmt.Errorf("invalid graphql resolver '%s'", resolver)
+	}
+	if h.dm != nil && resolver != graphqlResolverProxy {
+		resolvers = nativeResolvers(h.dm, router)
+	} else {
+		if opts.RestBaseURL == "" {
+			return errors.New("graphql proxy resolver requires RestBaseURL")
+		}
+		resolvers = proxyResolvers(opts.RestBaseURL)
+	}
+	tableCfgDir := filepath.Join(h.cfgs, "table")
+	entries, err := os.ReadDir(tableCfgDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dbAlias := findAliasByDatabase(filepath.Join(h.cfgs, "database"), entry.Name())
+		graphqlPath := fmt.Sprintf("%s/%s", opts.Prefix, dbAlias)
+		ep, err := mountGraphqlAPI(router, graphqlPath, filepath.Join(tableCfgDir, entry.Name()), resolvers, gqlOpts.MaxPageSize)
+		if err != nil {
+			return fmt.Errorf("graphql %s: %w", graphqlPath, err)
+		}
+		h.endpoints = append(h.endpoints, ep)
+		if !opts.NoGraphiQL {
+			RegisterGraphiQL(router, fmt.Sprintf("%s/%s", opts.GraphiQLPrefix, dbAlias), graphqlPath)
+		}
+	}
+	h.stopWatch = watchGraphqlSchemas(gqlOpts.WatchInterval)
+	return nil
+}
+
+// Reload 重新生成所有库的表配置与 swagger.yaml。挂载了 REST 时在后台按新配置构建并校验新的管理器，
+// 通过后连同直连 GraphQL schema 一起切换，未通过时继续使用原配置并返回错误，见 configswap.go。
+// 其余 GraphQL 端点随后重建，重建失败的端点继续使用原 schema。
+func (h *Handle) Reload() error {
+	if h.dm != nil {
+		if report := h.dm.live.reload(); !report.Applied {
+			return report.err()
+		}
+	} else {
+		swaggerRegenMu.Lock()
+		err := ExtractDbMeta(h.cfgs, h.restPrefix)
+		swaggerRegenMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, ep := range h.endpoints {
+		if h.dm != nil && ep.boundTo(h.dm.live.manager()) {
+			continue
+		}
+		if err := ep.reload(); err != nil {
+			errs = append(errs, fmt.Errorf("graphql %s: %w", ep.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止后台任务并关闭数据库连接。gin 不支持注销路由，关闭后已挂载的路由不应再接收请求。
+func (h *Handle) Close() error {
+	h.stopWatch()
+	for _, ep := range h.endpoints {
+		unregisterGraphqlEndpoint(ep)
+	}
+	if h.dm == nil {
+		return nil
+	}
+	return h.dm.live.manager().close()
+}
+
+// close 停止任务队列，释放管理器并关闭 KVStore
+func (dm *databaseManager) close() error {
+	if dm.jobQueue != nil {
+		dm.jobQueue.Stop()
+	}
+	err := dm.release()
+	if dm.store != nil {
+		dm.store.Close()
+	}
+	return err
+}
+
+// release 停止统计与定时任务并关闭所有适配器。任务队列与 KVStore 在配置切换的各代之间共用，由 close 关闭
+func (dm *databaseManager) release() error {
+	if dm.cancelTableCounter != nil {
+		dm.cancelTableCounter()
+	}
+	dm.stopSchedules()
+	if dm.mirror != nil {
+		dm.mirror.stop()
+	}
+	if dm.events != nil {
+		dm.events.stop()
+	}
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	var errs []error
+	for name, adapter := range dm.adapters {
+		if err := adapter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// discard 释放初始化失败的管理器；配置切换中构建的新一代不关闭共用的任务队列
+func (dm *databaseManager) discard() {
+	if dm.live.manager() == dm {
+		dm.close()
+		return
+	}
+	dm.release()
+}
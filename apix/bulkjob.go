@@ -0,0 +1,444 @@
+package apix
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 异步批量任务 ---------
+//
+// 大数据量导入/更新/删除不适合同步接口，改为两阶段：
+//
+//	POST /api/rest/:database/:table/bulk_jobs?operation=create   请求体为 JSON 数组或 NDJSON
+//	POST /api/rest/:database/:table/bulk_jobs?operation=update&source=https://bucket.s3.../data.ndjson
+//	  -> 202 {"id": "..."}，Location: /api/jobs/:id
+//
+// 请求体先落盘到 bulk_job.dir，再作为 bulk 类型任务提交到任务队列（见 jobs.go），
+// 由后台按 chunk_size 分批调用适配器执行；source 为数据地址（如 S3 预签名 URL），由后台直接流式读取，只接受 bulk_job.source_prefixes 中的地址（见 bulksource.go）。
+// 每条记录与同步接口一样经过字段别名、时间解析、默认值、auto_update、auto_actor_fields 处理。
+// 进度在每批完成后写入任务，重试时从上次处理到的位置继续。
+
+const (
+	bulkOpCreate = "create"
+	bulkOpUpdate = "update"
+	bulkOpDelete = "delete"
+
+	maxBulkJobErrors = 100
+)
+
+type bulkJobConfig struct {
+	Dir        string `mapstructure:"dir"`
+	ChunkSize  int    `mapstructure:"chunk_size"`
+	Workers    int    `mapstructure:"workers"`
+	MaxRetries int    `mapstructure:"max_retries"`
+
+	SourcePrefixes []string      `mapstructure:"source_prefixes"` // 允许的 source 地址前缀，见 bulksource.go
+	SourceTimeout  time.Duration `mapstructure:"source_timeout"`
+}
+
+// bulkJobPayload 为队列中 bulk 任务的参数
+type bulkJobPayload struct {
+	Database  string                 `json:"database"`
+	Table     string                 `json:"table"`
+	Operation string                 `json:"operation"`
+	Source    string                 `json:"source,omitempty"`
+	Actor     string                 `json:"actor,omitempty"`
+	Scoped    bool                   `json:"scoped,omitempty"` // 按提交者的 claims 执行 row_filter
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+	Total     int64                  `json:"total,omitempty"` // 上传数据的记录数
+}
+
+type bulkJobError struct {
+	Offset int64  `json:"offset"`
+	Count  int    `json:"count"`
+	Error  string `json:"error"`
+}
+
+type bulkJobProgress struct {
+	Total     int64          `json:"total"` // source 任务执行完成前为 0
+	Processed int64          `json:"processed"`
+	Succeeded int64          `json:"succeeded"`
+	Failed    int64          `json:"failed"`
+	Errors    []bulkJobError `json:"errors"`
+}
+
+func (dm *databaseManager) bulkSpoolFile(id string) string {
+	return filepath.Join(dm.config.BulkJob.Dir, id+".json")
+}
+
+// sweepBulkSpool 清理已完成或已被清出队列的任务遗留的暂存文件
+func (dm *databaseManager) sweepBulkSpool() {
+	entries, err := os.ReadDir(dm.config.BulkJob.Dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || id == e.Name() {
+			continue
+		}
+		if job, ok := dm.jobQueue.Get(id); !ok || job.Status == utils.JobCompleted {
+			os.Remove(filepath.Join(dm.config.BulkJob.Dir, e.Name()))
+		}
+	}
+}
+
+// --------- 记录流读取 ---------
+
+// recordReader 逐条读取 JSON 数组或 NDJSON
+type recordReader struct {
+	dec   *json.Decoder
+	array bool
+	empty bool
+}
+
+func newRecordReader(r io.Reader) (*recordReader, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return &recordReader{empty: true}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\n' || b[0] == '\r' || b[0] == '\t' {
+			br.ReadByte()
+			continue
+		}
+		rr := &recordReader{dec: json.NewDecoder(br), array: b[0] == '['}
+		if rr.array {
+			if _, err := rr.dec.Token(); err != nil {
+				return nil, err
+			}
+		}
+		return rr, nil
+	}
+}
+
+// Next 返回下一条记录，读完返回 io.EOF
+func (r *recordReader) Next() (interface{}, error) {
+	if r.empty {
+		return nil, io.EOF
+	}
+	if r.array && !r.dec.More() {
+		return nil, io.EOF
+	}
+	var v interface{}
+	if err := r.dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// --------- Handler ---------
+
+func (dm *databaseManager) handleBulkJobSubmit(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	_, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	operation := c.DefaultQuery("operation", bulkOpCreate)
+	var op string
+	switch operation {
+	case bulkOpCreate:
+		op = opCreate
+	case bulkOpUpdate:
+		op = opUpdate
+	case bulkOpDelete:
+		op = opDelete
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation, expected create, update or delete"})
+		return
+	}
+	if !dm.authorize(c, tableConfig, op) {
+		return
+	}
+	if (operation == bulkOpUpdate || operation == bulkOpDelete) && tableConfig.PrimaryKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Primary key not defined for table, bulk " + operation + " requires primary key."})
+		return
+	}
+	priority := 0
+	if v := c.Query("priority"); v != "" {
+		if priority, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority: " + v})
+			return
+		}
+	}
+	id, err := generateULID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	payload := bulkJobPayload{
+		Database:  dbName,
+		Table:     tableAlias,
+		Operation: operation,
+		Actor:     dm.currentActor(c),
+	}
+	if scope := requestRowScope(c.Request.Context()); scope != nil {
+		payload.Scoped, payload.Claims = true, scope.claims
+	}
+	if source := c.Query("source"); source != "" {
+		if err := dm.config.BulkJob.allowSource(source); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		payload.Source = source
+	} else {
+		dm.sweepBulkSpool()
+		payload.Total, err = dm.spoolBulkJobBody(c.Request.Body, id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload: " + err.Error()})
+			return
+		}
+		if payload.Total == 0 {
+			os.Remove(dm.bulkSpoolFile(id))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No records in payload"})
+			return
+		}
+	}
+	job, err := dm.jobQueue.Enqueue(jobTypeBulk, payload, utils.EnqueueOptions{
+		ID:         id,
+		Priority:   priority,
+		MaxRetries: dm.config.BulkJob.MaxRetries,
+	})
+	if err != nil {
+		os.Remove(dm.bulkSpoolFile(id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", dm.jobsPrefix+"/"+id)
+	c.JSON(http.StatusAccepted, gin.H{"id": id, "status": job.Status, "total": payload.Total})
+}
+
+// spoolBulkJobBody 把请求体写入暂存文件，同时校验格式并统计条数
+func (dm *databaseManager) spoolBulkJobBody(body io.Reader, id string) (int64, error) {
+	if err := os.MkdirAll(dm.config.BulkJob.Dir, 0755); err != nil {
+		return 0, err
+	}
+	file := dm.bulkSpoolFile(id)
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	reader, err := newRecordReader(io.TeeReader(body, f))
+	if err != nil {
+		os.Remove(file)
+		return 0, err
+	}
+	var total int64
+	for {
+		if _, err := reader.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			os.Remove(file)
+			return 0, fmt.Errorf("record %d: %w", total, err)
+		}
+		total++
+	}
+	return total, nil
+}
+
+// --------- 执行 ---------
+
+// runBulkJob 为 bulk 类型任务的处理函数
+func (dm *databaseManager) runBulkJob(ctx context.Context, job utils.Job) error {
+	var payload bulkJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid bulk job payload: %w", err)
+	}
+	progress := bulkJobProgress{Total: payload.Total, Errors: []bulkJobError{}}
+	if len(job.Progress) > 0 {
+		if err := json.Unmarshal(job.Progress, &progress); err != nil {
+			return fmt.Errorf("invalid bulk job progress: %w", err)
+		}
+	}
+	if payload.Scoped {
+		ctx = withRowScope(ctx, payload.Claims)
+	}
+	if err := dm.processBulkJob(ctx, job.ID, &payload, &progress); err != nil {
+		return err
+	}
+	if progress.Total == 0 {
+		progress.Total = progress.Processed
+		if err := dm.jobQueue.SetProgress(job.ID, progress); err != nil {
+			return err
+		}
+	}
+	if payload.Source == "" {
+		os.Remove(dm.bulkSpoolFile(job.ID))
+	}
+	return nil
+}
+
+func (dm *databaseManager) openBulkJobSource(ctx context.Context, id string, payload *bulkJobPayload) (io.ReadCloser, error) {
+	if payload.Source == "" {
+		return os.Open(dm.bulkSpoolFile(id))
+	}
+	resp, err := dm.config.BulkJob.fetchBulkSource(ctx, payload.Source)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (dm *databaseManager) processBulkJob(ctx context.Context, id string, payload *bulkJobPayload, progress *bulkJobProgress) error {
+	adapter, tc, err := dm.getAdapterAndTableConfig(payload.Database, payload.Table)
+	if err != nil {
+		return err
+	}
+	src, err := dm.openBulkJobSource(ctx, id, payload)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	reader, err := newRecordReader(src)
+	if err != nil {
+		return err
+	}
+	chunkSize := dm.config.BulkJob.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	chunk := make([]interface{}, 0, chunkSize)
+	var offset int64
+	// 跳过之前执行已处理的记录
+	for ; offset < progress.Processed; offset++ {
+		if _, err := reader.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("record %d: %w", offset, err)
+		}
+	}
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		chunkErr := dm.executeBulkChunk(ctx, adapter, tc, payload, chunk)
+		progress.Processed += int64(len(chunk))
+		if chunkErr != nil {
+			progress.Failed += int64(len(chunk))
+			if len(progress.Errors) < maxBulkJobErrors {
+				progress.Errors = append(progress.Errors, bulkJobError{Offset: offset, Count: len(chunk), Error: chunkErr.Error()})
+			}
+		} else {
+			progress.Succeeded += int64(len(chunk))
+		}
+		offset += int64(len(chunk))
+		chunk = chunk[:0]
+		return dm.jobQueue.SetProgress(id, progress)
+	}
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		item, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", offset+int64(len(chunk)), err)
+		}
+		chunk = append(chunk, item)
+		if len(chunk) >= chunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// executeBulkChunk 按与同步接口相同的规则处理并写入一批记录
+func (dm *databaseManager) executeBulkChunk(ctx context.Context, adapter databaseAdapter, tc *tableConfig, payload *bulkJobPayload, items []interface{}) error {
+	if payload.Operation == bulkOpDelete {
+		ids := make([]interface{}, 0, len(items))
+		for i, item := range items {
+			if rec, ok := item.(map[string]interface{}); ok {
+				rec = tc.physicalRecord(rec)
+				idVal, exists := rec[tc.PrimaryKey]
+				if !exists {
+					return fmt.Errorf("record %d missing primary key '%s'", i, tc.apiFieldName(tc.PrimaryKey))
+				}
+				ids = append(ids, idVal)
+				continue
+			}
+			ids = append(ids, item)
+		}
+		_, err := adapter.BatchDelete(ctx, tc, ids)
+		return err
+	}
+	records := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		rec, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("record %d is not an object", i)
+		}
+		rec, err := tc.inputRecord(rec)
+		if err != nil {
+			return err
+		}
+		if payload.Operation == bulkOpCreate {
+			if err := tc.scopeRecords(ctx, []map[string]interface{}{rec}, true); err != nil {
+				return err
+			}
+			if err := applyDefaultValues(rec, tc); err != nil {
+				return err
+			}
+			applyAutoActorFields(rec, tc, payload.Actor, true)
+		} else {
+			applyAutoUpdateFields(rec, tc)
+			applyAutoActorFields(rec, tc, payload.Actor, false)
+		}
+		records = append(records, rec)
+	}
+	var err error
+	if payload.Operation == bulkOpCreate {
+		if err = tc.validateRecords(records, false); err != nil {
+			return err
+		}
+		if err = tc.guardInitialStates(records); err != nil {
+			return err
+		}
+		_, _, err = adapter.BatchCreate(ctx, tc, records)
+	} else {
+		if _, ok := adapter.(concurrencyAware); tc.checksConcurrency() && !ok {
+			return fmt.Errorf("concurrency strategy '%s' is not supported by this database type", tc.Concurrency.Strategy)
+		}
+		if err = tc.scopeRecords(ctx, records, false); err != nil {
+			return err
+		}
+		if err = tc.validateRecords(records, true); err != nil {
+			return err
+		}
+		keys := tc.primaryKeyLookups(records)
+		if err = tc.guardImmutable(ctx, adapter, records, keys); err != nil {
+			return err
+		}
+		if err = tc.guardTransitions(ctx, adapter, records, keys); err != nil {
+			return err
+		}
+		_, _, err = adapter.BatchUpdate(ctx, tc, records)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// --------- 批量任务的数据地址 ---------
+//
+// bulk_jobs 的 source 由服务端直接下载，只接受配置的地址前缀，未配置时不接受 source：
+//
+//	bulk_job:
+//	  source_prefixes:                 # 协议、主机必须一致，路径以前缀开头
+//	    - https://my-bucket.s3.amazonaws.com/imports/
+//	  source_timeout: 30m              # 单次下载（含读取数据）的超时
+//
+// 解析后的地址为回环、私有、链路本地等内网地址时拒绝连接，重定向的目标同样需要匹配前缀。
+
+const defaultBulkSourceTimeout = 30 * time.Minute
+
+var errBulkSourceDisabled = errors.New("source is not enabled, configure bulk_job.source_prefixes")
+
+// checkBulkSourcePrefixes 加载配置时校验 source_prefixes
+func checkBulkSourcePrefixes(prefixes []string) error {
+	for _, p := range prefixes {
+		u, err := url.Parse(p)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+			return fmt.Errorf("invalid bulk_job.source_prefixes entry %q, expected http(s)://host/path", p)
+		}
+	}
+	return nil
+}
+
+// allowSource 判断 source 是否匹配 source_prefixes
+func (cfg bulkJobConfig) allowSource(source string) error {
+	if len(cfg.SourcePrefixes) == 0 {
+		return errBulkSourceDisabled
+	}
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("source must be an http(s) URL, use a pre-signed URL for object storage")
+	}
+	if u.User != nil {
+		return errors.New("source must not contain credentials")
+	}
+	if strings.Contains(u.Path+"/", "/../") {
+		return errors.New("source path must not contain ..")
+	}
+	for _, p := range cfg.SourcePrefixes {
+		pu, err := url.Parse(p)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == pu.Scheme && strings.EqualFold(u.Host, pu.Host) && strings.HasPrefix(u.EscapedPath(), pu.EscapedPath()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source is not allowed by bulk_job.source_prefixes: %s://%s%s", u.Scheme, u.Host, u.EscapedPath())
+}
+
+// sourceClient 下载 source 的客户端：限时、只连接公网地址、重定向同样校验前缀
+func (cfg bulkJobConfig) sourceClient() *http.Client {
+	timeout := cfg.SourceTimeout
+	if timeout <= 0 {
+		timeout = defaultBulkSourceTimeout
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: publicAddressOnly}
+	// 不使用环境变量中的代理，否则连接的是代理地址，内网校验失效
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return cfg.allowSource(req.URL.String())
+		},
+	}
+}
+
+// publicAddressOnly 在 DNS 解析之后、建立连接之前拒绝内网地址
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", address)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("source address %s is not allowed", ip)
+	}
+	return nil
+}
+
+// fetchBulkSource 下载 source，返回响应体
+func (cfg bulkJobConfig) fetchBulkSource(ctx context.Context, source string) (*http.Response, error) {
+	if err := cfg.allowSource(source); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfg.sourceClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch source failed: %s", resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package apix
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// --------- 相同请求合并 ---------
+//
+// 大量客户端同时发出相同的列表或单条查询时（如看板刷新），只执行一次数据库查询，结果分发给所有等待的请求：
+//
+//	request_coalescing:
+//	  enabled: true
+//	  vary_headers: [X-Tenant]   # 影响查询结果的请求头（如 gorm scope、会话设置读取的头）
+//
+// 合并键为请求路径、排序后的查询参数与相关请求头；Authorization、Cookie 以及 auth.actor_header、
+// auth.roles_header、auth.api_keys.header 始终参与。查询在第一个请求的上下文中执行，但不随其取消而中断；
+// 该请求的截止时间（如 statement_timeout）仍然生效。
+// 各请求拿到的是结果的副本，字段策略等后续处理互不影响。
+
+type coalesceConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	VaryHeaders []string `mapstructure:"vary_headers"`
+}
+
+var requestGroup singleflight.Group
+
+// coalesceKey 由请求路径、查询参数与相关请求头组成
+func (dm *databaseManager) coalesceKey(c *gin.Context) string {
+	var b strings.Builder
+	b.WriteString(c.Request.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(c.Request.URL.Query().Encode())
+	headers := append([]string{"Authorization", "Cookie", dm.config.Auth.ActorHeader, dm.config.Auth.RolesHeader}, dm.config.Coalesce.VaryHeaders...)
+	if dm.apiKeys != nil {
+		headers = append(headers, dm.apiKeys.header)
+	}
+	for _, h := range headers {
+		if h == "" {
+			continue
+		}
+		b.WriteString("\n" + http.CanonicalHeaderKey(h) + ":")
+		b.WriteString(strings.Join(c.Request.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// coalesce 未开启时直接执行 fn；开启时相同请求共享一次执行，shared 表示结果被多个请求共用，使用前需复制
+func coalesce[T any](dm *databaseManager, c *gin.Context, fn func(ctx context.Context) (T, error)) (result T, shared bool, err error) {
+	if !dm.config.Coalesce.Enabled {
+		result, err = fn(c.Request.Context())
+		return result, false, err
+	}
+	// 共享执行不随发起者断开而取消，但保留 statement_timeout 等设置的截止时间
+	orig := c.Request.Context()
+	v, err, shared := requestGroup.Do(dm.coalesceKey(c), func() (interface{}, error) {
+		ctx := context.WithoutCancel(orig)
+		if d, ok := orig.Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, d)
+			defer cancel()
+		}
+		return fn(ctx)
+	})
+	result, _ = v.(T)
+	return result, shared, err
+}
+
+func copyRecord(record map[string]interface{}) map[string]interface{} {
+	if record == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		cp[k] = v
+	}
+	return cp
+}
+
+func copyRecords(records []map[string]interface{}) []map[string]interface{} {
+	if records == nil {
+		return nil
+	}
+	cp := make([]map[string]interface{}, len(records))
+	for i, r := range records {
+		cp[i] = copyRecord(r)
+	}
+	return cp
+}
@@ -0,0 +1,103 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// --------- CockroachDB ---------
+//
+// type: cockroachdb 使用 PostgreSQL 方言。CockroachDB 默认 SERIALIZABLE 隔离，
+// 并发事务提交时可能返回 40001（restart transaction），adapter 的事务方法会自动重试：
+//
+//	cockroachdb:
+//	  tx_retries: 5                 # 默认 5，设为 -1 关闭重试
+//
+// 元数据提取跳过隐藏列（如无主键表的 rowid），并把 unique_rowid()/gen_random_uuid() 默认值识别为自动生成主键。
+
+const defaultCockroachTxRetries = 5
+
+type cockroachConfig struct {
+	TxRetries int `mapstructure:"tx_retries"`
+}
+
+func (c cockroachConfig) retries() int {
+	switch {
+	case c.TxRetries < 0:
+		return 0
+	case c.TxRetries == 0:
+		return defaultCockroachTxRetries
+	default:
+		return c.TxRetries
+	}
+}
+
+func isCockroachRetryableError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
+// isGeneratedDefault 判断默认值是否由数据库生成唯一标识
+func isGeneratedDefault(def string) bool {
+	d := strings.ToLower(def)
+	return strings.Contains(d, "unique_rowid()") || strings.Contains(d, "gen_random_uuid()") || strings.HasPrefix(d, "nextval(")
+}
+
+// ---- CockroachDB 元数据 ----
+func extractCockroachMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
+	tables, err := extractPostgreSQLMeta(dsn, dbName, in)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open cockroachdb database %s failed: %w", dbName, err)
+	}
+	defer db.Close()
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT column_name, is_hidden, COALESCE(column_default, '')
+			FROM information_schema.columns
+			WHERE table_schema='public' AND table_name=$1
+		`, tables[i].Name)
+		if err != nil {
+			in.warn(tables[i].Name, metaStageColumns, err)
+			return nil
+		}
+		hidden := map[string]bool{}
+		generated := map[string]bool{}
+		for rows.Next() {
+			var name, isHidden, def string
+			if err := rows.Scan(&name, &isHidden, &def); err != nil {
+				rows.Close()
+				return err
+			}
+			hidden[name] = isHidden == "YES"
+			generated[name] = isGeneratedDefault(def)
+		}
+		rows.Close()
+		fields := tables[i].Fields[:0]
+		for _, f := range tables[i].Fields {
+			if hidden[f.Name] {
+				continue
+			}
+			if generated[f.Name] {
+				f.AutoInc = true
+				f.HasDefault = false
+				f.Default = nil
+			}
+			fields = append(fields, f)
+		}
+		tables[i].Fields = fields
+		if hidden[tables[i].PrimaryKey] {
+			tables[i].PrimaryKey = ""
+		}
+		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
+		return nil
+	})
+}
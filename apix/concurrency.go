@@ -0,0 +1,281 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// --------- 并发更新冲突策略 ---------
+//
+// 多个客户端同时修改同一条记录时的处理方式，按表配置：
+//
+//	concurrency:
+//	  strategy: version          # last_write_wins（默认）| version | merge
+//	  version_field: version     # version 策略使用的整数版本列，默认 version
+//
+// version：更新请求需带上读取时的版本（请求体中的版本字段，单条更新也可用 If-Match 头），
+// 版本不一致时返回 409 与当前值，缺少版本返回 428；更新成功后版本加一，update_where 同样会使版本加一。
+// 单条查询在记录带有版本字段时返回 ETag。
+//
+// merge：请求体中的 _original 给出客户端读取时的原值，只校验本次修改的字段：
+//
+//	PUT /api/rest/shop/orders/1   {"remark": "加急", "_original": {"remark": ""}}
+//
+// 其他客户端修改了别的字段不算冲突，本次修改的字段已被改动时返回 409 与冲突字段；
+// 未在 _original 中给出原值的字段直接覆盖。
+//
+// 单条更新与批量更新（含批量任务）生效，仅支持 SQL 与 MongoDB 适配器，其他适配器返回 501。
+
+const (
+	concurrencyLastWriteWins = "last_write_wins"
+	concurrencyVersion       = "version"
+	concurrencyMerge         = "merge"
+
+	defaultVersionField = "version"
+	mergeOriginalKey    = "_original"
+)
+
+type concurrencyConfig struct {
+	Strategy     string `mapstructure:"strategy"`
+	VersionField string `mapstructure:"version_field"`
+}
+
+var errVersionRequired = errors.New("version is required for this table")
+
+type concurrencyConflictError struct {
+	Fields  []string               // 冲突的字段（物理列名）
+	Current map[string]interface{} // 冲突字段的当前值
+}
+
+func (e *concurrencyConflictError) Error() string {
+	return fmt.Sprintf("Concurrent update conflict on %s", strings.Join(e.Fields, ","))
+}
+
+// concurrencyAware 支持并发策略的适配器
+type concurrencyAware interface {
+	supportsConcurrencyStrategy()
+}
+
+func (a *gormAdapter) supportsConcurrencyStrategy()    {}
+func (a *mongoAdapter) supportsConcurrencyStrategy()   {}
+func (a *shardedAdapter) supportsConcurrencyStrategy() {}
+
+func checkConcurrencyConfig(tc *tableConfig) error {
+	switch tc.Concurrency.Strategy {
+	case "", concurrencyLastWriteWins, concurrencyMerge:
+	case concurrencyVersion:
+		if tc.Concurrency.VersionField == "" {
+			tc.Concurrency.VersionField = defaultVersionField
+		}
+	default:
+		return fmt.Errorf("table %s: invalid concurrency strategy '%s'", tc.Name, tc.Concurrency.Strategy)
+	}
+	return nil
+}
+
+// checksConcurrency 表是否配置了需要校验的并发策略
+func (tc *tableConfig) checksConcurrency() bool {
+	return tc.Concurrency.Strategy == concurrencyVersion || tc.Concurrency.Strategy == concurrencyMerge
+}
+
+// versionField version 策略下的版本列，其他策略为空
+func (tc *tableConfig) versionField() string {
+	if tc.Concurrency.Strategy == concurrencyVersion {
+		return tc.Concurrency.VersionField
+	}
+	return ""
+}
+
+// concurrencyCondition 从更新内容中分离出并发条件：version 策略为 {版本列: 期望版本}，并将版本加一；
+// merge 策略为本次修改字段在 _original 中的原值。返回的 data 为副本，不含 _original。
+func (tc *tableConfig) concurrencyCondition(data map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	if !tc.checksConcurrency() {
+		if _, ok := data[mergeOriginalKey]; !ok {
+			return data, nil, nil
+		}
+	}
+	out := copyRecord(data)
+	original := out[mergeOriginalKey]
+	delete(out, mergeOriginalKey)
+	cond := map[string]interface{}{}
+	switch tc.Concurrency.Strategy {
+	case concurrencyVersion:
+		vf := tc.Concurrency.VersionField
+		v, ok := out[vf]
+		if !ok || v == nil {
+			return nil, nil, errVersionRequired
+		}
+		expected, err := strconv.ParseInt(keyValueString(v), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s '%v'", tc.apiFieldName(vf), v)
+		}
+		cond[vf] = expected
+		out[vf] = expected + 1
+	case concurrencyMerge:
+		orig, ok := original.(map[string]interface{})
+		if original != nil && !ok {
+			return nil, nil, fmt.Errorf("%s must be an object", mergeOriginalKey)
+		}
+		for k, v := range tc.physicalRecord(orig) {
+			if _, changing := out[k]; changing {
+				cond[k] = v
+			}
+		}
+	}
+	return out, cond, nil
+}
+
+// conflictFields 比较条件与当前值，返回冲突字段
+func conflictFields(cond, current map[string]interface{}) *concurrencyConflictError {
+	e := &concurrencyConflictError{Current: map[string]interface{}{}}
+	for k, v := range cond {
+		if keyValueString(current[k]) != keyValueString(v) {
+			e.Fields = append(e.Fields, k)
+			e.Current[k] = current[k]
+		}
+	}
+	sort.Strings(e.Fields)
+	return e
+}
+
+func isConcurrencyError(err error) bool {
+	var conflict *concurrencyConflictError
+	return errors.As(err, &conflict) || errors.Is(err, errVersionRequired)
+}
+
+// ---- gorm ----
+
+func gormWhereEquals(db *gorm.DB, m map[string]interface{}) *gorm.DB {
+	for k, v := range m {
+		if v == nil {
+			db = db.Where(fmt.Sprintf("%s IS NULL", k))
+			continue
+		}
+		db = db.Where(fmt.Sprintf("%s = ?", k), v)
+	}
+	return db
+}
+
+// gormCheckConflict 更新未命中时区分记录不存在、值未变化与并发冲突
+func gormCheckConflict(tx *gorm.DB, tc *tableConfig, filter, cond map[string]interface{}) error {
+	var count int64
+	if err := gormWhereEquals(tx.Table(tc.Name), filter).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if len(cond) == 0 {
+		return nil
+	}
+	if err := gormWhereEquals(gormWhereEquals(tx.Table(tc.Name), filter), cond).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		// MySQL 值未变化时影响行数为 0
+		return nil
+	}
+	fields := make([]string, 0, len(cond))
+	for k := range cond {
+		fields = append(fields, k)
+	}
+	var current map[string]interface{}
+	if err := gormWhereEquals(tx.Table(tc.Name).Select(fields), filter).Take(&current).Error; err != nil {
+		return err
+	}
+	return conflictFields(cond, current)
+}
+
+// ---- mongo ----
+
+func mongoCheckConflict(ctx context.Context, collection *mongo.Collection, filter bson.M, cond map[string]interface{}) error {
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	if len(cond) == 0 {
+		return nil
+	}
+	projection := bson.M{}
+	for k := range cond {
+		projection[k] = 1
+	}
+	var current map[string]interface{}
+	if err := collection.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&current); err != nil {
+		return err
+	}
+	return conflictFields(cond, current)
+}
+
+func withConcurrencyFilter(filter bson.M, cond map[string]interface{}) bson.M {
+	if len(cond) == 0 {
+		return filter
+	}
+	out := bson.M{}
+	for k, v := range filter {
+		out[k] = v
+	}
+	for k, v := range cond {
+		out[k] = v
+	}
+	return out
+}
+
+// ---- handler ----
+
+// requireConcurrencySupport 配置了并发策略但适配器不支持时返回 501
+func requireConcurrencySupport(c *gin.Context, adapter databaseAdapter, tc *tableConfig) bool {
+	if !tc.checksConcurrency() {
+		return true
+	}
+	if _, ok := adapter.(concurrencyAware); ok {
+		return true
+	}
+	c.JSON(http.StatusNotImplemented, gin.H{"error": "Concurrency strategy '" + tc.Concurrency.Strategy + "' is not supported by this database type"})
+	return false
+}
+
+// writeConcurrencyError 版本或原值不一致返回 409，缺少版本返回 428
+func writeConcurrencyError(c *gin.Context, tc *tableConfig, err error) {
+	var conflict *concurrencyConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "fields": tc.apiFieldNames(conflict.Fields), "current": tc.apiRecord(conflict.Current)})
+		return
+	}
+	c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+}
+
+// ifMatchVersion 单条更新未在请求体中给出版本时，使用 If-Match 头
+func (tc *tableConfig) ifMatchVersion(c *gin.Context, data map[string]interface{}) {
+	vf := tc.versionField()
+	if vf == "" {
+		return
+	}
+	if _, ok := data[vf]; ok {
+		return
+	}
+	if v := strings.Trim(strings.TrimPrefix(c.GetHeader("If-Match"), "W/"), `"`); v != "" {
+		data[vf] = v
+	}
+}
+
+// setVersionETag 单条查询返回版本对应的 ETag
+func (tc *tableConfig) setVersionETag(c *gin.Context, record map[string]interface{}) {
+	if vf := tc.versionField(); vf != "" && record[vf] != nil {
+		c.Header("ETag", `"`+keyValueString(record[vf])+`"`)
+	}
+}
@@ -0,0 +1,355 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+// --------- 配置热切换 ---------
+//
+// Handle.Reload、SIGHUP 或管理接口触发重新加载时，在后台按配置目录构建新的一代（连接、表配置及启动时的全部初始化），
+// 通过以下校验后才原子替换 REST 路由与直连 GraphQL schema：
+//
+//  1. 重新提取元数据，生成表配置与 swagger.yaml
+//  2. 构建新的管理器：与启动时相同的配置加载与校验（别名、校验规则、限流、行过滤、webhooks、定时任务等）
+//  3. 检查只能重启后生效的设置：job_queue、bulk_job.workers、metrics.enabled 与 metrics.path
+//  4. 每张表一次冒烟查询（取 1 条），汇总表跳过
+//  5. 执行配置目录下 tests/ 中的场景测试（见 scenario.go），在隔离的临时库中运行，不影响线上数据
+//  6. 按新的 swagger.yaml 构建直连 GraphQL schema
+//
+// 任一项失败时关闭新的一代，继续使用原配置并返回全部错误。切换后新请求由新的一代处理，进行中的请求与后台任务
+// 在旧的一代上完成，旧的一代等待它们结束（最长 drain_timeout）后关闭连接。任务队列与 KVStore 在各代之间共用，
+// 运行时的维护设置保留；指标计数、慢查询记录等内存中的统计从零开始。
+//
+//	reload:
+//	  run_tests: true       # 执行 tests/ 中的场景测试，目录不存在时跳过
+//	  smoke_timeout: 10s    # 每张表冒烟查询的超时
+//	  drain_timeout: 30s    # 旧的一代等待进行中的请求与任务的最长时间
+//
+// 管理接口（受 operation_roles.config_reload 控制，默认 admin）：
+//
+//	POST /api/admin/config/reload    重新加载，通过时返回 200，校验失败返回 422，均附带校验报告
+//	GET  /api/admin/config/reload    最近一次重新加载的报告
+
+const (
+	reloadStageExtract = "extract"
+	reloadStageBuild   = "build"
+	reloadStageRestart = "restart_required"
+	reloadStageSmoke   = "smoke"
+	reloadStageTests   = "tests"
+	reloadStageGraphql = "graphql"
+)
+
+type reloadConfig struct {
+	RunTests     bool          `mapstructure:"run_tests"`
+	SmokeTimeout time.Duration `mapstructure:"smoke_timeout"`
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+}
+
+type reloadError struct {
+	Stage  string `json:"stage"`
+	Target string `json:"target,omitempty"`
+	Error  string `json:"error"`
+}
+
+type reloadTests struct {
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+	Output string `json:"output,omitempty"` // 有失败时为测试输出
+}
+
+type reloadReport struct {
+	Applied       bool          `json:"applied"`
+	Generation    int           `json:"generation"` // 处理完成后生效的配置代数，启动时为 1
+	StartedAt     time.Time     `json:"started_at"`
+	DurationMs    int64         `json:"duration_ms"`
+	TablesChecked int           `json:"tables_checked"`
+	Tests         *reloadTests  `json:"tests,omitempty"`
+	Errors        []reloadError `json:"errors,omitempty"`
+}
+
+func (r *reloadReport) fail(stage, target string, err error) {
+	r.Errors = append(r.Errors, reloadError{Stage: stage, Target: target, Error: err.Error()})
+}
+
+// err 汇总校验错误，Handle.Reload 使用
+func (r *reloadReport) err() error {
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		if e.Target != "" {
+			errs[i] = fmt.Errorf("%s %s: %s", e.Stage, e.Target, e.Error)
+		} else {
+			errs[i] = fmt.Errorf("%s: %s", e.Stage, e.Error)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restGeneration 一代配置：管理器及注册了其路由的 handler
+type restGeneration struct {
+	id      int
+	dm      *databaseManager
+	handler http.Handler
+	active  sync.WaitGroup // 进行中的请求与后台任务
+}
+
+// liveManager 持有当前生效的一代，外层路由与任务队列通过它找到处理请求的管理器
+type liveManager struct {
+	mu        sync.RWMutex
+	current   *restGeneration
+	router    *gin.Engine
+	reloading sync.Mutex // 串行化重新加载
+	lastMu    sync.Mutex
+	last      *reloadReport
+}
+
+// acquire 返回当前一代并登记一个进行中的请求或任务，结束时调用 active.Done
+func (l *liveManager) acquire() *restGeneration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	g := l.current
+	g.active.Add(1)
+	return g
+}
+
+func (l *liveManager) manager() *databaseManager {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.current.dm
+}
+
+func (l *liveManager) serve(c *gin.Context) {
+	g := l.acquire()
+	defer g.active.Done()
+	g.handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// job 返回在执行时生效的管理器上运行的任务处理函数
+func (l *liveManager) job(fn func(*databaseManager, context.Context, utils.Job) error) utils.JobHandler {
+	return func(ctx context.Context, job utils.Job) error {
+		g := l.acquire()
+		defer g.active.Done()
+		return fn(g.dm, ctx, job)
+	}
+}
+
+func (l *liveManager) lastReport() *reloadReport {
+	l.lastMu.Lock()
+	defer l.lastMu.Unlock()
+	return l.last
+}
+
+func (l *liveManager) reload() *reloadReport {
+	l.reloading.Lock()
+	defer l.reloading.Unlock()
+	return l.reloadLocked()
+}
+
+// reloadLocked 构建并校验新的一代，全部通过后切换；调用方持有 reloading
+func (l *liveManager) reloadLocked() *reloadReport {
+	l.mu.RLock()
+	cur := l.current
+	l.mu.RUnlock()
+	report := &reloadReport{Generation: cur.id, StartedAt: time.Now()}
+	defer func() {
+		report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+		l.lastMu.Lock()
+		l.last = report
+		l.lastMu.Unlock()
+		if report.Applied {
+			log.Printf("[Reload] Switched to config generation %d in %dms", report.Generation, report.DurationMs)
+		} else {
+			log.Printf("[Reload] Rejected, keeping generation %d: %v", report.Generation, report.err())
+		}
+	}()
+
+	old := cur.dm
+	swaggerRegenMu.Lock()
+	err := ExtractDbMeta(old.configDir, old.restPrefix)
+	swaggerRegenMu.Unlock()
+	if err != nil {
+		report.fail(reloadStageExtract, "", err)
+		return report
+	}
+	next, err := newDatabaseManager(old.configDir, l)
+	if err != nil {
+		report.fail(reloadStageBuild, "", err)
+		return report
+	}
+	next.configDir, next.restPrefix, next.jobsPrefix, next.router = old.configDir, old.restPrefix, old.jobsPrefix, old.router
+	applied := false
+	defer func() {
+		if !applied {
+			next.release()
+		}
+	}()
+
+	for _, setting := range restartRequired(old.config, next.config) {
+		report.fail(reloadStageRestart, setting, errors.New("changed, takes effect after restart"))
+	}
+	report.TablesChecked = next.smokeTest(report)
+	if next.config.Reload.RunTests {
+		next.runReloadTests(report)
+	}
+	schemas := map[*graphqlEndpoint]*graphql.Schema{}
+	resolvers := nativeResolvers(next, l.router)
+	for _, ep := range listGraphqlEndpoints() {
+		if !ep.boundTo(old) {
+			continue
+		}
+		schema, err := buildGraphqlSchema(ep.cfgDir, resolvers, ep.maxPageSize)
+		if err != nil {
+			report.fail(reloadStageGraphql, ep.path, err)
+			continue
+		}
+		schemas[ep] = schema
+	}
+	if len(report.Errors) > 0 {
+		return report
+	}
+
+	g := &restGeneration{id: cur.id + 1, dm: next, handler: newRestEngine(l.router, next)}
+	l.mu.Lock()
+	l.current = g
+	l.mu.Unlock()
+	for ep, schema := range schemas {
+		ep.replace(resolvers, schema)
+	}
+	applied = true
+	report.Applied, report.Generation = true, g.id
+
+	// 旧的一代不再提交定时任务，新的一代开始调度
+	if old.cancelTableCounter != nil {
+		old.cancelTableCounter()
+	}
+	old.stopSchedules()
+	next.startJobs()
+	go cur.retire(next.config.Reload.DrainTimeout)
+	return report
+}
+
+// retire 等待进行中的请求与任务结束（最长 timeout）后释放旧的一代
+func (g *restGeneration) retire(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		g.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("[Reload] Generation %d still has requests or jobs in progress after %s, closing", g.id, timeout)
+	}
+	if err := g.dm.release(); err != nil {
+		log.Printf("[Reload] Closing generation %d: %v", g.id, err)
+	}
+}
+
+// restartRequired 返回有变化但只能重启后生效的设置
+func restartRequired(cur, next *dmConfig) []string {
+	var changed []string
+	if cur.JobQueue != next.JobQueue {
+		changed = append(changed, "job_queue")
+	}
+	if cur.BulkJob.Workers != next.BulkJob.Workers {
+		changed = append(changed, "bulk_job.workers")
+	}
+	if cur.Metrics.Enabled != next.Metrics.Enabled {
+		changed = append(changed, "metrics.enabled")
+	}
+	if cur.Metrics.Path != next.Metrics.Path {
+		changed = append(changed, "metrics.path")
+	}
+	return changed
+}
+
+// smokeTest 每张表查询 1 条记录，返回检查的表数
+func (dm *databaseManager) smokeTest(report *reloadReport) int {
+	checked := 0
+	for dbName, dbCfg := range dm.config.Databases {
+		adapter, ok := dm.adapters[dbName]
+		if !ok {
+			continue
+		}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if tc.isRollup() {
+				continue
+			}
+			checked++
+			ctx, cancel := context.WithTimeout(context.Background(), dm.config.Reload.SmokeTimeout)
+			_, _, err := adapter.List(ctx, tc, listParams{Page: 1, PageSize: 1})
+			cancel()
+			if err != nil {
+				report.fail(reloadStageSmoke, dbName+"."+tc.Alias, err)
+			}
+		}
+	}
+	return checked
+}
+
+// runReloadTests 执行配置目录下的场景测试
+func (dm *databaseManager) runReloadTests(report *reloadReport) {
+	dir := filepath.Join(dm.configDir, "tests")
+	if !dirExists(dir) {
+		return
+	}
+	var out bytes.Buffer
+	passed, failed, err := runScenarios(TestOptions{Cfgs: dm.configDir, Out: &out}, []string{dir})
+	report.Tests = &reloadTests{Passed: passed, Failed: failed}
+	if err != nil {
+		report.fail(reloadStageTests, "", err)
+		return
+	}
+	if failed > 0 {
+		report.Tests.Output = out.String()
+		report.fail(reloadStageTests, "", fmt.Errorf("%d of %d scenarios failed", failed, passed+failed))
+	}
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleConfigReload(c *gin.Context) {
+	if !dm.authorize(c, nil, opConfigReload) {
+		return
+	}
+	if !dirExists(dm.configDir) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Config reload requires a config directory"})
+		return
+	}
+	if !dm.live.reloading.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "A config reload is already in progress"})
+		return
+	}
+	report := dm.live.reloadLocked()
+	dm.live.reloading.Unlock()
+	if !report.Applied {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (dm *databaseManager) handleConfigReloadStatus(c *gin.Context) {
+	if !dm.authorize(c, nil, opConfigReload) {
+		return
+	}
+	report := dm.live.lastReport()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No config reload has run"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,214 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 创建前唯一键检查 ---------
+//
+// 表配置 conflict_check: true 时，批量创建前按主键与 unique_keys 查询已有记录，
+// 存在冲突则返回 409 并列出冲突的记录，而不是透出数据库的重复键错误：
+//
+//	{"error": "Unique key conflict", "conflicts": [{"index": 0, "key": {"email": "a@b.c"}, "id": "12"}]}
+//
+// 同一请求内互相重复的记录以 duplicate_of 指向先出现的序号。
+// 唯一键中的软删除字段不参与比对，查询本身已排除已删除的记录。
+// on_error=continue 时冲突记录计入 errors，其余照常写入。
+// 检查与写入之间仍可能有并发插入，数据库的唯一约束依然是最终保障。
+
+type uniqueConflict struct {
+	Index       int                    `json:"index"`
+	Key         map[string]interface{} `json:"key"`
+	ID          interface{}            `json:"id,omitempty"`
+	DuplicateOf *int                   `json:"duplicate_of,omitempty"`
+}
+
+type uniqueConflictError struct {
+	Conflicts []uniqueConflict
+}
+
+func (e *uniqueConflictError) Error() string {
+	return fmt.Sprintf("unique key conflict on %d record(s)", len(e.Conflicts))
+}
+
+func (e *uniqueConflictError) respond(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"error": "Unique key conflict", "conflicts": e.Conflicts})
+}
+
+// failures 把冲突转换为 on_error=continue 的失败记录
+func (e *uniqueConflictError) failures() []recordFailure {
+	failures := make([]recordFailure, len(e.Conflicts))
+	for i, cf := range e.Conflicts {
+		msg := fmt.Sprintf("unique key conflict on %s", strings.Join(sortedKeys(cf.Key), ","))
+		if cf.DuplicateOf != nil {
+			msg += fmt.Sprintf(" with record %d in the same request", *cf.DuplicateOf)
+		} else if cf.ID != nil {
+			msg += fmt.Sprintf(" with existing record %v", cf.ID)
+		}
+		failures[i] = recordFailure{Index: cf.Index, Error: msg}
+	}
+	return failures
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// conflictKeys 返回需要检查的字段组合：主键与全部唯一键，去掉软删除字段后去重
+func (tc *tableConfig) conflictKeys() [][]string {
+	var keys [][]string
+	seen := map[string]bool{}
+	add := func(fields []string) {
+		var kept []string
+		for _, f := range fields {
+			if f != tc.SoftDeleteKey {
+				kept = append(kept, f)
+			}
+		}
+		id := strings.Join(kept, ",")
+		if len(kept) == 0 || seen[id] {
+			return
+		}
+		seen[id] = true
+		keys = append(keys, kept)
+	}
+	if tc.PrimaryKey != "" {
+		add([]string{tc.PrimaryKey})
+	}
+	for _, uk := range tc.GetUniqueKeys() {
+		add(uk)
+	}
+	return keys
+}
+
+// checkUniqueConflicts 检查 records 与已有记录及彼此之间的唯一键冲突，无冲突返回 nil
+func checkUniqueConflicts(ctx context.Context, adapter databaseAdapter, tc *tableConfig, records []map[string]interface{}) (*uniqueConflictError, error) {
+	conflicted := map[int]bool{}
+	var conflicts []uniqueConflict
+	for _, fields := range tc.conflictKeys() {
+		var keys []lookupKey
+		var owners []int
+		first := map[string]int{}
+		for i, r := range records {
+			if conflicted[i] {
+				continue
+			}
+			values := make([]interface{}, len(fields))
+			complete := true
+			for j, f := range fields {
+				// 缺少字段或为 NULL 时数据库不会判定重复
+				if values[j] = r[f]; values[j] == nil {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+			k := lookupKey{Fields: fields, Values: values}
+			if prev, ok := first[k.String()]; ok {
+				dup := prev
+				conflicts = append(conflicts, uniqueConflict{Index: i, Key: tc.conflictKey(fields, values), DuplicateOf: &dup})
+				conflicted[i] = true
+				continue
+			}
+			first[k.String()] = i
+			keys = append(keys, k)
+			owners = append(owners, i)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		var lookupFields string
+		if tc.PrimaryKey != "" {
+			lookupFields = tc.PrimaryKey
+		}
+		found, err := batchGetRecords(ctx, adapter, tc, keys, lookupFields)
+		if err != nil {
+			return nil, err
+		}
+		for j, existing := range found {
+			if existing == nil {
+				continue
+			}
+			i := owners[j]
+			cf := uniqueConflict{Index: i, Key: tc.conflictKey(fields, keys[j].Values)}
+			if tc.PrimaryKey != "" {
+				cf.ID = recordID(tc, existing[tc.PrimaryKey])
+			}
+			conflicts = append(conflicts, cf)
+			conflicted[i] = true
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Index < conflicts[j].Index })
+	return &uniqueConflictError{Conflicts: conflicts}, nil
+}
+
+// conflictKey 以 API 字段名返回冲突的键值
+func (tc *tableConfig) conflictKey(fields []string, values []interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(fields))
+	for i, f := range fields {
+		key[tc.apiFieldName(f)] = values[i]
+	}
+	return key
+}
+
+// batchCreatePartialChecked 先剔除冲突记录再部分写入，冲突记录计入 failures
+func batchCreatePartialChecked(ctx context.Context, adapter databaseAdapter, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error) {
+	if !tc.ConflictCheck {
+		return batchCreatePartial(ctx, adapter, tc, records)
+	}
+	conflict, err := checkUniqueConflicts(ctx, adapter, tc, records)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if conflict == nil {
+		return batchCreatePartial(ctx, adapter, tc, records)
+	}
+	skip := make(map[int]bool, len(conflict.Conflicts))
+	for _, cf := range conflict.Conflicts {
+		skip[cf.Index] = true
+	}
+	var keep []int
+	var remaining []map[string]interface{}
+	for i, r := range records {
+		if !skip[i] {
+			keep = append(keep, i)
+			remaining = append(remaining, r)
+		}
+	}
+	ids := make([]interface{}, len(records))
+	created := make([]map[string]interface{}, len(records))
+	failures := conflict.failures()
+	if len(remaining) > 0 {
+		subIDs, subCreated, subFailures, err := batchCreatePartial(ctx, adapter, tc, remaining)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for j, i := range keep {
+			if j < len(subIDs) {
+				ids[i] = subIDs[j]
+			}
+			created[i] = subCreated[j]
+		}
+		for _, f := range subFailures {
+			failures = append(failures, recordFailure{Index: keep[f.Index], Error: f.Error})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	return ids, created, failures, nil
+}
@@ -0,0 +1,550 @@
+package apix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// --------- 接口契约校验 ---------
+//
+// 开发、预发环境中按生成的 swagger.yaml 在运行时校验 REST 请求与响应，发现生成器与处理函数之间的偏差。_base.yaml：
+//
+//	contract_validation:
+//	  mode: log          # off（默认）| log：只记录日志 | reject：请求不符合时返回 400
+//	  responses: true    # 同时校验响应，响应不符合时只记录日志
+//
+// 请求检查：接口是否在文档中声明、查询参数的必填与类型（integer/number/boolean/enum）、JSON 请求体是否符合 schema
+// （字段类型、nullable、未声明的字段；更新类接口——PUT 与 update_where——按部分更新处理，不检查 required）。
+// 响应检查：2xx 状态码是否声明、JSON 响应体是否符合 schema（不检查 required，fields 参数会裁剪字段；
+// 不能返回 writeOnly 字段）。主键在响应中总是字符串（见 fixPkFieldToString），按 x-primary-key 放宽。
+// 文档按库读取 swagger.yaml，文件更新（重新生成）后自动重新加载。
+//
+// 管理接口（受 operation_roles.contract 控制，默认 admin）：
+//
+//	GET /api/admin/contract   校验次数、不符合次数与最近的不符合记录
+
+const (
+	contractModeOff    = "off"
+	contractModeLog    = "log"
+	contractModeReject = "reject"
+
+	maxContractBodyBytes  = 1 << 20
+	maxContractErrors     = 20
+	maxContractViolations = 50
+)
+
+type contractValidationConfig struct {
+	Mode      string `mapstructure:"mode"`
+	Responses bool   `mapstructure:"responses"`
+}
+
+type contractViolation struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // request | response
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Operation string    `json:"operation"`
+	Status    int       `json:"status,omitempty"`
+	Errors    []string  `json:"errors"`
+}
+
+type contractSpec struct {
+	file    string
+	modTime time.Time
+	doc     *openapiDoc
+}
+
+// contractValidator 缓存各库的文档并保存校验统计
+type contractValidator struct {
+	mu                 sync.Mutex
+	specs              map[string]*contractSpec
+	requestsChecked    int64
+	responsesChecked   int64
+	requestViolations  int64
+	responseViolations int64
+	recent             []contractViolation
+}
+
+// setupContractValidation 校验 contract_validation 配置，开启时创建校验器
+func (dm *databaseManager) setupContractValidation() error {
+	switch dm.config.Contract.Mode {
+	case "", contractModeOff:
+		return nil
+	case contractModeLog, contractModeReject:
+		dm.contract = &contractValidator{specs: map[string]*contractSpec{}, recent: []contractViolation{}}
+		return nil
+	}
+	return fmt.Errorf("invalid contract_validation mode %q, expected off, log or reject", dm.config.Contract.Mode)
+}
+
+// spec 返回库的文档，swagger.yaml 有更新时重新读取
+func (v *contractValidator) spec(cfgsDir, alias string) (*openapiDoc, error) {
+	v.mu.Lock()
+	cached := v.specs[alias]
+	v.mu.Unlock()
+	var file string
+	if cached != nil {
+		file = cached.file
+	} else {
+		var err error
+		if file, err = swaggerSpecFile(cfgsDir, alias); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && info.ModTime().Equal(cached.modTime) {
+		return cached.doc, nil
+	}
+	doc, err := readOpenAPIFile(file)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	v.specs[alias] = &contractSpec{file: file, modTime: info.ModTime(), doc: doc}
+	v.mu.Unlock()
+	return doc, nil
+}
+
+// swaggerSpecFile 返回库生成的 swagger.yaml 路径
+func swaggerSpecFile(cfgsDir, alias string) (string, error) {
+	dbname := findFileByAlias(filepath.Join(cfgsDir, "database"), alias)
+	if dbname == "" {
+		return "", fmt.Errorf("database %s not found", alias)
+	}
+	return filepath.Join(cfgsDir, "table", dbname, "swagger.yaml"), nil
+}
+
+func readOpenAPIFile(file string) (*openapiDoc, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc := &openapiDoc{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", file, err)
+	}
+	return doc, nil
+}
+
+func (v *contractValidator) record(violation contractViolation) {
+	v.mu.Lock()
+	if violation.Direction == "request" {
+		v.requestViolations++
+	} else {
+		v.responseViolations++
+	}
+	v.recent = append(v.recent, violation)
+	if len(v.recent) > maxContractViolations {
+		v.recent = v.recent[1:]
+	}
+	v.mu.Unlock()
+	log.Printf("[contract] %s %s %s does not match %s: %s",
+		violation.Direction, violation.Method, violation.Path, violation.Operation, strings.Join(violation.Errors, "; "))
+}
+
+// bodyRecorder 在写出响应的同时保留一份响应体，超过 limit 时丢弃（契约校验与请求录制共用）
+type bodyRecorder struct {
+	gin.ResponseWriter
+	limit    int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyRecorder) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+// contractGuard 按文档校验 REST 请求与响应
+func (dm *databaseManager) contractGuard(c *gin.Context) {
+	cfg := dm.config.Contract
+	dbName, table := c.Param("database"), c.Param("table")
+	if dm.contract == nil || dbName == "" || table == "" {
+		c.Next()
+		return
+	}
+	doc, err := dm.contract.spec(dm.configDir, dbName)
+	if err != nil {
+		// 库不存在等错误交给处理函数返回
+		c.Next()
+		return
+	}
+	operation := strings.NewReplacer(":database", dbName, ":table", table, ":id", "{id}").Replace(c.FullPath())
+	method := strings.ToLower(c.Request.Method)
+	op := doc.operation(operation, method)
+	violation := contractViolation{Method: c.Request.Method, Path: c.Request.URL.Path, Operation: strings.ToUpper(method) + " " + operation}
+
+	var errs []string
+	if op == nil {
+		errs = []string{"operation is not documented"}
+	} else {
+		errs = doc.checkRequest(c, op, operation)
+	}
+	dm.contract.mu.Lock()
+	dm.contract.requestsChecked++
+	dm.contract.mu.Unlock()
+	if len(errs) > 0 {
+		violation.Time, violation.Direction, violation.Errors = time.Now(), "request", errs
+		dm.contract.record(violation)
+		if cfg.Mode == contractModeReject {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Request does not match the API contract", "violations": errs})
+			return
+		}
+	}
+	if !cfg.Responses || op == nil {
+		c.Next()
+		return
+	}
+	recorder := &bodyRecorder{ResponseWriter: c.Writer, limit: maxContractBodyBytes}
+	c.Writer = recorder
+	c.Next()
+	c.Writer = recorder.ResponseWriter
+	if recorder.overflow {
+		return
+	}
+	status := c.Writer.Status()
+	errs = doc.checkResponse(op, status, c.Writer.Header().Get("Content-Type"), recorder.body.Bytes())
+	dm.contract.mu.Lock()
+	dm.contract.responsesChecked++
+	dm.contract.mu.Unlock()
+	if len(errs) > 0 {
+		violation.Time, violation.Direction, violation.Status, violation.Errors = time.Now(), "response", status, errs
+		dm.contract.record(violation)
+	}
+}
+
+func (d *openapiDoc) operation(path, method string) *openapiOperation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+	switch method {
+	case "get":
+		return item.Get
+	case "post":
+		return item.Post
+	case "put":
+		return item.Put
+	case "patch":
+		return item.Patch
+	case "delete":
+		return item.Delete
+	}
+	return nil
+}
+
+// ---- 请求与响应 ----
+
+func (d *openapiDoc) checkRequest(c *gin.Context, op *openapiOperation, operation string) []string {
+	var errs []string
+	query := c.Request.URL.Query()
+	for _, p := range op.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		values, ok := query[p.Name]
+		if !ok {
+			if p.Required {
+				errs = append(errs, fmt.Sprintf("query parameter %s is required", p.Name))
+			}
+			continue
+		}
+		if s := d.resolve(p.Schema); s != nil && len(values) > 0 {
+			if msg := checkContractParam(s, values[0]); msg != "" {
+				errs = append(errs, fmt.Sprintf("query parameter %s: %s", p.Name, msg))
+			}
+		}
+	}
+	schema := op.RequestBody.Content.jsonSchema()
+	if schema == nil || !isJSONContentType(c.ContentType()) || c.Request.Body == nil {
+		return errs
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxContractBodyBytes+1))
+	if err != nil {
+		return errs
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), c.Request.Body))
+	if len(data) > maxContractBodyBytes {
+		return errs
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, "request body is required")
+		}
+		return errs
+	}
+	body, err := decodeContractJSON(data)
+	if err != nil {
+		return append(errs, "request body is not valid JSON: "+err.Error())
+	}
+	// 更新类接口接受部分字段
+	partial := c.Request.Method == http.MethodPut || c.Request.Method == http.MethodPatch || strings.HasSuffix(operation, "/update_where")
+	chk := &contractChecker{doc: d, request: true, partial: partial, errs: errs}
+	chk.check(schema, body, "body")
+	return chk.errs
+}
+
+func (d *openapiDoc) checkResponse(op *openapiOperation, status int, contentType string, body []byte) []string {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		// 生成的文档只声明部分错误状态码，只检查成功响应
+		if status >= 200 && status < 300 {
+			return []string{fmt.Sprintf("status %d is not documented", status)}
+		}
+		return nil
+	}
+	schema := resp.Content.jsonSchema()
+	if schema == nil || !isJSONContentType(contentType) || len(body) == 0 {
+		return nil
+	}
+	value, err := decodeContractJSON(body)
+	if err != nil {
+		return []string{"response body is not valid JSON: " + err.Error()}
+	}
+	chk := &contractChecker{doc: d, partial: true}
+	chk.check(schema, value, "body")
+	return chk.errs
+}
+
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func decodeContractJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func checkContractParam(s *openapiSchema, value string) string {
+	switch s.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Sprintf("%q is not an integer", value)
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("%q is not a boolean", value)
+		}
+	}
+	if len(s.Enum) > 0 && !contractEnumContains(s.Enum, value) {
+		return fmt.Sprintf("%q is not one of %v", value, s.Enum)
+	}
+	return ""
+}
+
+func contractEnumContains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---- schema 校验 ----
+
+type contractChecker struct {
+	doc     *openapiDoc
+	request bool // 请求体：忽略 readOnly 字段；响应体：不能出现 writeOnly 字段
+	partial bool // 不检查 required
+	errs    []string
+}
+
+func (k *contractChecker) fail(at, format string, args ...interface{}) {
+	if len(k.errs) < maxContractErrors {
+		k.errs = append(k.errs, at+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (k *contractChecker) check(s *openapiSchema, value interface{}, at string) {
+	s = k.doc.resolve(s)
+	if s == nil || len(k.errs) >= maxContractErrors {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			k.fail(at, "null is not allowed")
+		}
+		return
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			k.fail(at, "expected object, got %s", contractTypeName(value))
+			return
+		}
+		k.checkObject(s, obj, at)
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			k.fail(at, "expected array, got %s", contractTypeName(value))
+			return
+		}
+		for i, item := range arr {
+			k.check(s.Items, item, fmt.Sprintf("%s[%d]", at, i))
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			k.fail(at, "expected string, got %s", contractTypeName(value))
+		}
+	case "integer":
+		if n, ok := value.(json.Number); !ok || !isIntegerNumber(n) {
+			k.fail(at, "expected integer, got %s", contractTypeName(value))
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			k.fail(at, "expected number, got %s", contractTypeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			k.fail(at, "expected boolean, got %s", contractTypeName(value))
+		}
+	}
+	if len(s.Enum) > 0 && !contractEnumContains(s.Enum, value) {
+		k.fail(at, "%v is not one of %v", value, s.Enum)
+	}
+}
+
+func (k *contractChecker) checkObject(s *openapiSchema, obj map[string]interface{}, at string) {
+	if !k.partial {
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				k.fail(at, "missing required field %s", name)
+			}
+		}
+	}
+	if len(s.Properties) == 0 {
+		return
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := obj[name]
+		prop := k.doc.resolve(s.Properties[name])
+		switch {
+		case prop == nil:
+			k.fail(at, "field %s is not documented", name)
+		case k.request && prop.ReadOnly:
+		case !k.request && prop.WriteOnly:
+			k.fail(at, "write-only field %s is returned", name)
+		case !k.request && name == s.PrimaryKey && isNumericString(value):
+		default:
+			k.check(prop, value, at+"."+name)
+		}
+	}
+}
+
+func isIntegerNumber(n json.Number) bool {
+	_, err := n.Int64()
+	return err == nil
+}
+
+func isNumericString(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func contractTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleContractStatus(c *gin.Context) {
+	if !dm.authorize(c, nil, opContract) {
+		return
+	}
+	mode := dm.config.Contract.Mode
+	if mode == "" {
+		mode = contractModeOff
+	}
+	v := dm.contract
+	if v == nil {
+		c.JSON(http.StatusOK, gin.H{"mode": mode})
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{
+		"mode":                mode,
+		"responses":           dm.config.Contract.Responses,
+		"requests_checked":    v.requestsChecked,
+		"responses_checked":   v.responsesChecked,
+		"request_violations":  v.requestViolations,
+		"response_violations": v.responseViolations,
+		"recent_violations":   append([]contractViolation{}, v.recent...),
+	})
+}
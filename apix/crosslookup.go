@@ -0,0 +1,188 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 跨库关联查询 ---------
+//
+// 常见的 MySQL + MongoDB 拆分场景下，用另一个库中的表补充本表记录，不支持通用的跨库 join。
+// 在表配置中声明关联，请求时通过 lookup 参数选用：
+//
+//	cross_lookups:
+//	  profile:                  # GET /api/rest/shop/orders?lookup=profile
+//	    field: user_id          # 本表列
+//	    database: userdb        # 目标库别名
+//	    table: profiles         # 目标表名或别名
+//	    key: user_id            # 目标表匹配列，默认主键
+//	    fields: [nickname, avatar]   # 目标表返回的列，默认全部
+//	    many: false             # true 时结果为数组（一对多）
+//
+// 列表与单条查询都支持，多个关联用逗号分隔。一次请求中每个关联按 key__in 分批查询目标表
+// （每批 500 个键，many 时每批最多 max_page_size 条），结果写入以关联名命名的字段，匹配不到时为 null
+// （many 时为空数组）。目标记录按目标表的字段策略与字段别名输出，调用者需要有目标表的 list 权限。
+
+const (
+	queryParamLookup     = "lookup"
+	crossLookupBatchSize = 500
+)
+
+type crossLookupConfig struct {
+	Field    string   `mapstructure:"field"`
+	Database string   `mapstructure:"database"`
+	Table    string   `mapstructure:"table"`
+	Key      string   `mapstructure:"key"`
+	Fields   []string `mapstructure:"fields"`
+	Many     bool     `mapstructure:"many"`
+}
+
+// checkCrossLookups 在所有库加载完成后确认关联的目标库与目标表存在，并补全默认匹配列
+func checkCrossLookups(databases map[string]databaseConfig) error {
+	for dbAlias, dbCfg := range databases {
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			for name, l := range tc.CrossLookups {
+				if l.Field == "" {
+					return fmt.Errorf("table %s in db %s: cross lookup '%s' requires field", tc.Name, dbAlias, name)
+				}
+				target, ok := databases[l.Database]
+				if !ok {
+					return fmt.Errorf("table %s in db %s: cross lookup '%s' references unknown database '%s'", tc.Name, dbAlias, name, l.Database)
+				}
+				ref := findTableConfig(target.Tables, l.Table)
+				if ref == nil {
+					return fmt.Errorf("table %s in db %s: cross lookup '%s' references unknown table '%s.%s'", tc.Name, dbAlias, name, l.Database, l.Table)
+				}
+				if l.Key == "" {
+					l.Key = ref.PrimaryKey
+				}
+				if l.Key == "" {
+					return fmt.Errorf("table %s in db %s: cross lookup '%s' target table has no primary key, specify key", tc.Name, dbAlias, name)
+				}
+				tc.CrossLookups[name] = l
+			}
+		}
+	}
+	return nil
+}
+
+// parseLookups 解析 lookup 参数，未声明的关联返回错误
+func parseLookups(c *gin.Context, tc *tableConfig) ([]string, error) {
+	raw := c.Query(queryParamLookup)
+	if raw == "" {
+		return nil, nil
+	}
+	names := parseStringList(raw)
+	for _, name := range names {
+		if _, ok := tc.CrossLookups[name]; !ok {
+			return nil, fmt.Errorf("unknown lookup '%s'", name)
+		}
+	}
+	return names, nil
+}
+
+// applyCrossLookups 按 lookup 参数补充关联记录；出错时已写响应并返回 false
+func (dm *databaseManager) applyCrossLookups(c *gin.Context, tc *tableConfig, names []string, records []map[string]interface{}) bool {
+	for _, name := range names {
+		l := tc.CrossLookups[name]
+		adapter, target, err := dm.lookupTarget(l)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("lookup %s: %v", name, err)})
+			return false
+		}
+		if !dm.authorize(c, target, opList) {
+			return false
+		}
+		matches, err := dm.fetchLookupRecords(c.Request.Context(), adapter, target, l, records)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("lookup %s: %v", name, err)})
+			return false
+		}
+		for _, record := range records {
+			v := record[l.Field]
+			found := matches[fmt.Sprint(v)]
+			if v == nil {
+				found = nil
+			}
+			if l.Many {
+				if found == nil {
+					found = []map[string]interface{}{}
+				}
+				record[name] = found
+			} else if len(found) > 0 {
+				record[name] = found[0]
+			} else {
+				record[name] = nil
+			}
+		}
+	}
+	return true
+}
+
+func (dm *databaseManager) lookupTarget(l crossLookupConfig) (databaseAdapter, *tableConfig, error) {
+	dm.mutex.RLock()
+	adapter, ok := dm.adapters[l.Database]
+	dbCfg := dm.config.Databases[l.Database]
+	dm.mutex.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("database %s not available", l.Database)
+	}
+	target := findTableConfig(dbCfg.Tables, l.Table)
+	if target == nil {
+		return nil, nil, fmt.Errorf("table %s.%s not configured", l.Database, l.Table)
+	}
+	return adapter, target, nil
+}
+
+// fetchLookupRecords 分批查询目标表，返回 键 → 目标记录（已按目标表输出）
+func (dm *databaseManager) fetchLookupRecords(ctx context.Context, adapter databaseAdapter, target *tableConfig, l crossLookupConfig, records []map[string]interface{}) (map[string][]map[string]interface{}, error) {
+	seen := map[string]struct{}{}
+	var keys []string
+	for _, record := range records {
+		v := record[l.Field]
+		if v == nil {
+			continue
+		}
+		k := fmt.Sprint(v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+	}
+	fields := ""
+	if len(l.Fields) > 0 {
+		fields = strings.Join(l.Fields, ",")
+		if !contains(l.Fields, l.Key) {
+			fields += "," + l.Key
+		}
+	}
+	matches := map[string][]map[string]interface{}{}
+	for start := 0; start < len(keys); start += crossLookupBatchSize {
+		batch := keys[start:min(start+crossLookupBatchSize, len(keys))]
+		filters := url.Values{l.Key + "__in": {strings.Join(batch, ",")}}
+		pageSize := len(batch)
+		if l.Many {
+			pageSize = dm.config.MaxPageSize
+		}
+		rows, _, err := adapter.List(ctx, target, listParams{Page: 1, PageSize: pageSize, Fields: fields, QueryFilters: filters})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			k := fmt.Sprint(row[l.Key])
+			if len(l.Fields) > 0 && !contains(l.Fields, l.Key) {
+				delete(row, l.Key)
+			}
+			row = fixPkFieldToString(row, target.PrimaryKey).(map[string]interface{})
+			matches[k] = append(matches[k], target.renderRecord(row))
+		}
+	}
+	return matches, nil
+}
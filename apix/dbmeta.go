@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -36,18 +37,34 @@ type TableMeta struct {
 	SoftDelType string
 	AutoUpdate  map[string]interface{}
 	DefaultVals map[string]interface{}
+	TimeSeries  *TimeSeriesMeta // 时序表（TimescaleDB hypertable、InfluxDB measurement）
+	ReadOnly    bool            // 只读数据源，swagger 不生成写接口
+	Strict      bool            // SQLite STRICT 表，列类型严格校验
+	Docs        tableDocs       // 表配置中的文档覆盖，见 swaggerdocs.go
+	Computed    []string        // 输出转换生成的只读字段
+	Filterable  []string        // 可过滤字段，见 filterfields.go
+	Sortable    []string        // 可排序字段
+}
+type TimeSeriesMeta struct {
+	TimeField string   `yaml:"time_field"`
+	Tags      []string `yaml:"tags,omitempty"`
+	Retention string   `yaml:"retention,omitempty"`
 }
 type FieldMeta struct {
-	Name       string
-	Type       string
-	Nullable   bool
-	IsPrimary  bool
-	IsUnique   bool
-	AutoInc    bool
-	HasDefault bool
-	Default    interface{}
-	Comment    string
-	OnUpdate   bool
+	Name        string
+	Type        string
+	Nullable    bool
+	IsPrimary   bool
+	IsUnique    bool
+	AutoInc     bool
+	HasDefault  bool
+	Default     interface{}
+	Comment     string
+	OnUpdate    bool
+	Hidden      bool             // 不在响应中返回，见 fieldpolicy.go
+	Mask        string           // 脱敏方式
+	Transformed bool             // 输出转换后为字符串，见 transform.go
+	Validation  *fieldValidation // 校验规则，见 validation.go
 }
 
 // Config 基础结构
@@ -56,11 +73,38 @@ type DbBaseCfg struct {
 	DSN      string `yaml:"dsn"`
 	Database string `yaml:"database"`
 	Alias    string `yaml:"alias"`
-	Dir      string
+	// Snowflake
+	Warehouse string `yaml:"warehouse"`
+	Role      string `yaml:"role"`
+	// 分片库：未配置 dsn 时取第一个分片
+	Shards struct {
+		Nodes []struct {
+			DSN string `yaml:"dsn"`
+		} `yaml:"nodes"`
+	} `yaml:"shards"`
+	TableNaming *tableNaming `yaml:"table_naming"` // 未配置时使用 _base.yaml 中的策略
+	Dir         string
+}
+
+// UnmarshalYAML 兼容 dsn 配置为故障转移列表，元数据取自第一个（主库）
+func (c *DbBaseCfg) UnmarshalYAML(value *yaml.Node) error {
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "dsn" && value.Content[i+1].Kind == yaml.SequenceNode && len(value.Content[i+1].Content) > 0 {
+			value.Content[i+1] = value.Content[i+1].Content[0]
+		}
+	}
+	type plain DbBaseCfg
+	return value.Decode((*plain)(c))
 }
 
 // ====== 主入口：扫描 database 下的启用库，生成 table 配置和 swagger 文件 ======
 func ExtractDbMeta(cfgsDir string, apiPrefix string) error {
+	return ExtractDbMetaWithProgress(cfgsDir, apiPrefix, logMetaProgress)
+}
+
+// ExtractDbMetaWithProgress 同 ExtractDbMeta，各库并行提取，每个库完成时调用 progress（见 metaextract.go），
+// 返回所有失败库的错误
+func ExtractDbMetaWithProgress(cfgsDir string, apiPrefix string, progress func(MetaProgress)) error {
 	dbCfgDir := filepath.Join(cfgsDir, "database")
 	tableCfgDir := filepath.Join(cfgsDir, "table")
 
@@ -69,61 +113,109 @@ func ExtractDbMeta(cfgsDir string, apiPrefix string) error {
 		return err
 	}
 
+	if err := checkDatabaseAliases(dbCfgs); err != nil {
+		return err
+	}
+	return extractDbMetas(dbCfgs, tableCfgDir, apiPrefix, readSwaggerOptions(cfgsDir), readMetaExtractOptions(cfgsDir), progress)
+}
+
+var errDbConfigNotFound = errors.New("enabled database config not found")
+
+// RegenerateDbMeta 为单个库（别名或库名）重新生成表配置与 swagger.yaml，不使用元数据缓存
+func RegenerateDbMeta(cfgsDir string, apiPrefix string, database string) error {
+	dbCfgs, err := listEnableDbCfgs(filepath.Join(cfgsDir, "database"))
+	if err != nil {
+		return err
+	}
+	if err := checkDatabaseAliases(dbCfgs); err != nil {
+		return err
+	}
 	for _, dbcfg := range dbCfgs {
-		dbAlias := dbcfg.Alias
-		if dbAlias == "" {
-			dbAlias = dbcfg.Database
-		}
-		dbcfg.Alias = dbAlias
-		dbTableDir := filepath.Join(tableCfgDir, dbcfg.Database)
-		disableTables, err := listDisableTables(dbTableDir)
-		if err != nil {
+		if dbcfg.Alias == database || dbcfg.Database == database {
+			in := &introspection{database: dbcfg.Alias, refresh: true, tableTimeout: readMetaExtractOptions(cfgsDir).TableTimeout}
+			err := extractDbMetaFor(dbcfg, filepath.Join(cfgsDir, "table"), apiPrefix, readSwaggerOptions(cfgsDir), in)
+			in.record(filepath.Join(cfgsDir, "table", dbcfg.Database), err)
 			return err
 		}
-		tables, err := extractTableMetaWithDefaultAlias(dbcfg.Type, dbcfg.DSN, dbcfg.Database)
-		if err != nil {
-			log.Printf("extractTableMeta failed for %s: %v", dbcfg.Database, err)
-			continue
-		}
+	}
+	return fmt.Errorf("%w: %s", errDbConfigNotFound, database)
+}
 
-		// 生成表配置文件
-		for i, tbl := range tables {
-			// 跳过 disable 的表
-			if _, found := disableTables[tbl.Name]; found {
-				continue
-			}
-			tblYaml := fmt.Sprintf("%s.enable.yaml", tbl.Name)
-			oldAlias := getAliasFromYAML(filepath.Join(dbTableDir, tblYaml))
-			if oldAlias != "" {
-				tbl.Alias = oldAlias
-				tables[i].Alias = oldAlias
-			}
-			yamlContent, err := toConfigYamlSingleWithAlias(tbl)
-			if err != nil {
-				log.Printf("generate yaml for table %s failed: %v", tbl.Name, err)
-				continue
-			}
-			if err := writeConfigYamlToDir(yamlContent, dbTableDir, tbl.Name, "enable"); err != nil {
-				log.Printf("write config yaml failed for table %s: %v", tbl.Name, err)
-			}
+// extractDbMetaFor 提取单个库的元数据，生成 table 配置和 swagger 文件
+func extractDbMetaFor(dbcfg DbBaseCfg, tableCfgDir string, apiPrefix string, opts swaggerOptions, in *introspection) error {
+	dbAlias := dbcfg.Alias
+	if dbAlias == "" {
+		dbAlias = dbcfg.Database
+	}
+	dbcfg.Alias = dbAlias
+	dbTableDir := filepath.Join(tableCfgDir, dbcfg.Database)
+	disableTables, err := listDisableTables(dbTableDir)
+	if err != nil {
+		return err
+	}
+	// 审计表不生成表配置，不能经 REST 访问或改写
+	if t := readAuditTable(filepath.Dir(tableCfgDir)); t != "" {
+		disableTables[t] = struct{}{}
+	}
+	dsn := dbcfg.DSN
+	if strings.EqualFold(dbcfg.Type, "snowflake") {
+		if dsn, err = snowflakeDSN(dsn, dbcfg.Warehouse, dbcfg.Role); err != nil {
+			return fmt.Errorf("invalid snowflake dsn for %s: %w", dbcfg.Database, err)
 		}
+	}
+	tables, err := extractTableMetaWithDefaultAlias(dbcfg.Type, dsn, dbcfg.Database, dbcfg.TableNaming, dbTableDir, in)
+	if err != nil {
+		return fmt.Errorf("extractTableMeta failed for %s: %w", dbcfg.Database, err)
+	}
 
-		// 生成 swagger.yaml
-		enabledTables := make([]TableMeta, 0, len(tables))
-		for _, tbl := range tables {
-			if _, found := disableTables[tbl.Name]; !found {
-				enabledTables = append(enabledTables, tbl)
-			}
+	// 去掉 disable 的表，已有表配置的沿用其中的别名
+	enabledTables := make([]TableMeta, 0, len(tables))
+	explicit := map[string]bool{}
+	existing := map[string]*tableYAML{}
+	for _, tbl := range tables {
+		if _, found := disableTables[tbl.Name]; found {
+			continue
+		}
+		conf := readTableYAML(filepath.Join(dbTableDir, tbl.Name+".enable.yaml"))
+		existing[tbl.Name] = conf
+		if conf != nil && conf.Alias != "" {
+			tbl.Alias = conf.Alias
+			explicit[tbl.Name] = true
 		}
-		swaggerContent, err := toSwaggerYaml(enabledTables, dbcfg.Alias, apiPrefix)
+		enabledTables = append(enabledTables, tbl)
+	}
+	if err := resolveTableAliases(dbAlias, enabledTables, explicit, dbcfg.TableNaming.onCollision()); err != nil {
+		return err
+	}
+	tables = enabledTables
+	in.count = len(tables)
+	storeTableMetas(dbTableDir, tables)
+
+	// 生成表配置文件
+	for i, tbl := range tables {
+		yamlContent, err := toConfigYamlSingleWithAlias(tbl)
 		if err != nil {
-			log.Printf("generate swagger yaml failed for db %s: %v", dbcfg.Database, err)
+			log.Printf("generate yaml for table %s failed: %v", tbl.Name, err)
 			continue
 		}
-		if err := writeSwaggerYamlToDir(swaggerContent, dbTableDir); err != nil {
-			log.Printf("write swagger yaml failed for db %s: %v", dbcfg.Database, err)
+		conf := existing[tbl.Name]
+		if conf != nil {
+			yamlContent = keepCustomTableKeys(yamlContent, conf.doc)
+		}
+		tables[i] = applyTableYAML(tables[i], conf)
+		if err := writeConfigYamlToDir(yamlContent, dbTableDir, tbl.Name, "enable"); err != nil {
+			log.Printf("write config yaml failed for table %s: %v", tbl.Name, err)
 		}
 	}
+
+	// 生成 swagger.yaml
+	swaggerContent, err := toSwaggerYaml(tables, dbcfg.Alias, apiPrefix, opts)
+	if err != nil {
+		return fmt.Errorf("generate swagger yaml failed for db %s: %w", dbcfg.Database, err)
+	}
+	if err := writeSwaggerYamlToDir(swaggerContent, dbTableDir); err != nil {
+		return fmt.Errorf("write swagger yaml failed for db %s: %w", dbcfg.Database, err)
+	}
 	return nil
 }
 
@@ -134,6 +226,7 @@ func listEnableDbCfgs(dbCfgDir string) ([]DbBaseCfg, error) {
 		return nil, fmt.Errorf("read directory failed: %w", err)
 	}
 	enableRe := regexp.MustCompile(`^(.+)\.enable\.ya?ml$`)
+	baseNaming := readTableNaming(filepath.Dir(dbCfgDir))
 	var results []DbBaseCfg
 	for _, file := range files {
 		if file.IsDir() {
@@ -156,6 +249,12 @@ func listEnableDbCfgs(dbCfgDir string) ([]DbBaseCfg, error) {
 		if cfg.Alias == "" {
 			cfg.Alias = cfg.Database
 		}
+		if cfg.DSN == "" && len(cfg.Shards.Nodes) > 0 {
+			cfg.DSN = cfg.Shards.Nodes[0].DSN
+		}
+		if cfg.TableNaming == nil {
+			cfg.TableNaming = baseNaming
+		}
 		cfg.Dir = dbCfgDir
 		results = append(results, cfg)
 	}
@@ -195,6 +294,7 @@ func toConfigYamlSingleWithAlias(table TableMeta) (string, error) {
 		SoftDelKey    string                 `yaml:"softdel_key,omitempty"`
 		SoftDelType   string                 `yaml:"softdel_type,omitempty"`
 		AutoUpdate    map[string]interface{} `yaml:"auto_update,omitempty"`
+		TimeSeries    *TimeSeriesMeta        `yaml:"timeseries,omitempty"`
 	}
 	conf := tableConf{
 		Name:          table.Name,
@@ -205,6 +305,7 @@ func toConfigYamlSingleWithAlias(table TableMeta) (string, error) {
 		SoftDelKey:    table.SoftDelKey,
 		SoftDelType:   table.SoftDelType,
 		AutoUpdate:    table.AutoUpdate,
+		TimeSeries:    table.TimeSeries,
 	}
 	buf := &bytes.Buffer{}
 	yamlEncoder := yaml.NewEncoder(buf)
@@ -215,6 +316,44 @@ func toConfigYamlSingleWithAlias(table TableMeta) (string, error) {
 	return buf.String(), nil
 }
 
+// ====== 保留手工维护的表配置项 ======
+// 生成的 yaml 只覆盖元数据推断出的字段，旧文件中其它手工添加的配置项（如 id_resolution）原样追加保留
+func keepCustomTableKeys(yamlContent string, oldDoc *yaml.Node) string {
+	var newDoc yaml.Node
+	if yaml.Unmarshal([]byte(yamlContent), &newDoc) != nil {
+		return yamlContent
+	}
+	if len(oldDoc.Content) == 0 || len(newDoc.Content) == 0 {
+		return yamlContent
+	}
+	oldMap, newMap := oldDoc.Content[0], newDoc.Content[0]
+	if oldMap.Kind != yaml.MappingNode || newMap.Kind != yaml.MappingNode {
+		return yamlContent
+	}
+	generated := map[string]struct{}{}
+	for i := 0; i+1 < len(newMap.Content); i += 2 {
+		generated[newMap.Content[i].Value] = struct{}{}
+	}
+	kept := 0
+	for i := 0; i+1 < len(oldMap.Content); i += 2 {
+		if _, ok := generated[oldMap.Content[i].Value]; ok {
+			continue
+		}
+		newMap.Content = append(newMap.Content, oldMap.Content[i], oldMap.Content[i+1])
+		kept++
+	}
+	if kept == 0 {
+		return yamlContent
+	}
+	buf := &bytes.Buffer{}
+	yamlEncoder := yaml.NewEncoder(buf)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(&newDoc); err != nil {
+		return yamlContent
+	}
+	return buf.String()
+}
+
 // ====== 写入文件方法（带 enable/disable）=======
 func writeConfigYamlToDir(yamlContent, outputDir, tableAlias, suffix string) error {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -228,7 +367,7 @@ func writeConfigYamlToDir(yamlContent, outputDir, tableAlias, suffix string) err
 }
 
 // ====== swagger.yaml 生成（用 alias） ======
-func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error) {
+func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string, opts swaggerOptions) (string, error) {
 	sw := map[string]interface{}{
 		"openapi": "3.0.3",
 		"info": map[string]interface{}{
@@ -239,6 +378,7 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			【支持的查询操作符】：
 
 			- 字段=xxx：等于（默认操作）
+			- 字段__eq=xxx：等于（字段名与 page、order 等保留参数同名时使用）
 			- 字段__ne=xxx：不等于
 			- 字段__gt=xxx：大于
 			- 字段__gte=xxx：大于等于
@@ -250,12 +390,23 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			- 字段__isnull=true|false：判断字段是否为 NULL
 			- 字段__between=a,b：字段值在 a 与 b 之间（包含边界）
 
+			【OR 条件】：
+
+			- _or=字段__操作符:值,字段__操作符:值：组内条件为 OR，如 _or=username__like:foo%25,email__icontains:bar
+			- 多个 _or 参数之间、与其他过滤条件之间为 AND
+
 			【分页、排序、字段筛选参数】：
 
 			- page=1，page_size=10：分页参数（从 1 开始）
 			- order=字段 或 order=-字段：升序/降序
 			- fields=字段1,字段2：只返回指定字段
 
+			【filter 形式】：
+
+			- filter[字段__操作符]=xxx：与上面的过滤写法等价，不会与保留参数冲突
+			- 开启 strict_filters 的表只接受 filter[...] 形式的过滤参数
+			- 配置了 filterable_fields、sortable_fields 的表只能按列出的字段（及主键）过滤、排序，见表 schema 的 x-filterable-fields、x-sortable-fields
+
 			【示例】：
 
 			- 精确查询用户：username=alice
@@ -270,17 +421,41 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			"schemas": map[string]interface{}{},
 		},
 	}
+	if len(opts.Servers) > 0 {
+		sw["servers"] = swaggerServersFor(opts.Servers, dbAlias)
+	}
 	paths := sw["paths"].(map[string]interface{})
 	tags := sw["tags"].([]map[string]string)
 	schemas := sw["components"].(map[string]interface{})["schemas"].(map[string]interface{})
 
 	for _, t := range tables {
-		props, required := toSwaggerSchemaFields(t.Fields)
-		schemas[t.Alias] = map[string]interface{}{
+		props, required := toSwaggerSchemaFields(t.Fields, t.Strict)
+		for _, name := range t.Computed {
+			props[name] = map[string]interface{}{"type": "string", "readOnly": true}
+		}
+		schema := map[string]interface{}{
 			"type":       "object",
 			"properties": props,
 			"required":   required,
 		}
+		// 主键与唯一键扩展字段，供远端 ego 联邦（federation.go）还原表配置
+		if t.PrimaryKey != "" {
+			schema["x-primary-key"] = t.PrimaryKey
+		}
+		if uniques := dedupUniques(t.UniqueKeys); len(uniques) > 0 {
+			schema["x-unique-keys"] = uniques
+		}
+		if t.SoftDelKey != "" {
+			schema["x-softdel-key"] = t.SoftDelKey
+		}
+		// 可过滤、可排序字段（含主键），GraphQL 据此生成参数说明
+		if len(t.Filterable) > 0 {
+			schema["x-filterable-fields"] = withPrimaryKey(t.PrimaryKey, t.Filterable)
+		}
+		if len(t.Sortable) > 0 {
+			schema["x-sortable-fields"] = withPrimaryKey(t.PrimaryKey, t.Sortable)
+		}
+		schemas[t.Alias] = schema
 		// 生成batch_update模型时主键必填
 		batchProps := map[string]interface{}{}
 		for k, v := range props {
@@ -313,13 +488,40 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			"required":   batchRequired,
 		}
 
-		tags = append(tags, map[string]string{"name": t.Alias, "description": sanitizeSwaggerText(t.Comment)})
+		tagDescription := t.Comment
+		if t.Docs.Description != "" {
+			tagDescription = t.Docs.Description
+		}
+		tags = append(tags, map[string]string{"name": t.Alias, "description": sanitizeSwaggerText(tagDescription)})
 
 		basePath := fmt.Sprintf("%s/%s/%s", apiPrefix, dbAlias, t.Alias)
 		idPath := fmt.Sprintf("%s/{id}", basePath)
 		batchDeletePath := fmt.Sprintf("%s/batch_delete", basePath)
+		batchGetPath := fmt.Sprintf("%s/batch_get", basePath)
+		upsertPath := fmt.Sprintf("%s/upsert", basePath)
+		updateWherePath := fmt.Sprintf("%s/update_where", basePath)
+		deleteWherePath := fmt.Sprintf("%s/delete_where", basePath)
+		statsPath := fmt.Sprintf("%s/stats", basePath)
+		bulkJobsPath := fmt.Sprintf("%s/bulk_jobs", basePath)
+		importPath := fmt.Sprintf("%s/import", basePath)
+		timeSeriesPath := fmt.Sprintf("%s/timeseries", basePath)
+		aggregatePath := fmt.Sprintf("%s/aggregate", basePath)
+		distinctPath := fmt.Sprintf("%s/distinct", basePath)
+		exportPath := fmt.Sprintf("%s/export", basePath)
 
 		getParams := makeSwaggerQueryParameters()
+		listDescription := "支持等值、模糊、区间、in等各种字段过滤。字段类型和参数请参考开头说明部分。"
+		if len(t.Filterable) > 0 {
+			listDescription += "可过滤字段：" + strings.Join(withPrimaryKey(t.PrimaryKey, t.Filterable), ", ")
+		}
+		if len(t.Sortable) > 0 {
+			sortable := strings.Join(withPrimaryKey(t.PrimaryKey, t.Sortable), ", ")
+			for _, p := range getParams {
+				if p["name"] == queryParamOrder {
+					p["description"] = "排序，字段前加 - 降序，可选字段：" + sortable
+				}
+			}
+		}
 		idParam := map[string]interface{}{
 			"name":        "id",
 			"in":          "path",
@@ -333,6 +535,36 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			"schema":      map[string]string{"type": "string"},
 			"description": "返回字段，逗号分隔",
 		}
+		dryRunParam := map[string]interface{}{
+			"name":        "dry_run",
+			"in":          "query",
+			"schema":      map[string]string{"type": "boolean"},
+			"description": "试运行：SQL 数据库在事务中执行后回滚，返回将产生的结果",
+		}
+		returnParam := map[string]interface{}{
+			"name":        "return",
+			"in":          "query",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"minimal", "representation"}},
+			"description": "representation：在 data 中返回更新后的记录（也可用请求头 Prefer: return=representation）",
+		}
+		onErrorParam := map[string]interface{}{
+			"name":        "on_error",
+			"in":          "query",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"abort", "continue"}},
+			"description": "continue：跳过失败的记录，其余照常写入，响应中 errors 列出失败记录的序号与原因",
+		}
+		resultsParam := map[string]interface{}{
+			"name":        "results",
+			"in":          "query",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"per_record"}},
+			"description": "per_record：在 results 中按请求顺序返回每条记录的 status（created/updated/deleted/not_found/failed）、id 与 error（也可用请求头 Prefer: results=per_record）",
+		}
+		asOfParam := map[string]interface{}{
+			"name":        "as_of",
+			"in":          "query",
+			"schema":      map[string]string{"type": "string"},
+			"description": "读取指定时刻的版本（RFC3339 或 Unix 秒），需表配置开启 history",
+		}
 		keyParam := map[string]interface{}{
 			"name":        "key",
 			"in":          "query",
@@ -343,7 +575,7 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 			"get": map[string]interface{}{
 				"tags":        []string{t.Alias},
 				"summary":     fmt.Sprintf("List %s records", t.Alias),
-				"description": "支持等值、模糊、区间、in等各种字段过滤。字段类型和参数请参考开头说明部分。",
+				"description": listDescription,
 				"parameters":  getParams,
 				"responses": map[string]interface{}{
 					"200": map[string]interface{}{
@@ -353,21 +585,33 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 								"schema": map[string]interface{}{
 									"type": "object",
 									"properties": map[string]interface{}{
-										"total": map[string]interface{}{"type": "integer"},
+										"total":       map[string]interface{}{"type": "integer", "nullable": true, "description": "查询代价超出预算降级时为 null"},
+										"next_cursor": map[string]interface{}{"type": "string", "description": "带 cursor 参数且还有下一页时返回"},
 										"data": map[string]interface{}{
 											"type":  "array",
 											"items": map[string]interface{}{"$ref": "#/components/schemas/" + t.Alias},
 										},
+										"degraded": map[string]interface{}{
+											"type":        "object",
+											"description": "查询代价超出 query_budget 且配置为 degrade 时返回",
+											"properties": map[string]interface{}{
+												"estimated_rows": map[string]interface{}{"type": "integer"},
+												"max_rows":       map[string]interface{}{"type": "integer"},
+												"page_size":      map[string]interface{}{"type": "integer"},
+											},
+										},
 									},
 								},
 							},
 						},
 					},
+					"422": map[string]interface{}{"description": "预计扫描行数超出 query_budget"},
 				},
 			},
 			"post": map[string]interface{}{
-				"tags":    []string{t.Alias},
-				"summary": fmt.Sprintf("Batch create %s", t.Alias),
+				"tags":       []string{t.Alias},
+				"summary":    fmt.Sprintf("Batch create %s", t.Alias),
+				"parameters": []interface{}{dryRunParam, onErrorParam, resultsParam},
 				"requestBody": map[string]interface{}{
 					"required": true,
 					"content": map[string]interface{}{
@@ -381,11 +625,15 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 				},
 				"responses": map[string]interface{}{
 					"201": map[string]interface{}{"description": "Created"},
+					"207": map[string]interface{}{"description": "on_error=continue 时部分记录写入失败"},
+					"409": map[string]interface{}{"description": "表开启 conflict_check 时与已有记录或同批记录的唯一键冲突，conflicts 列出冲突记录"},
+					"422": map[string]interface{}{"description": "on_error=continue 时全部记录写入失败"},
 				},
 			},
 			"put": map[string]interface{}{
-				"tags":    []string{t.Alias},
-				"summary": fmt.Sprintf("Batch update %s", t.Alias),
+				"tags":       []string{t.Alias},
+				"summary":    fmt.Sprintf("Batch update %s", t.Alias),
+				"parameters": []interface{}{dryRunParam, returnParam, fieldsParam, resultsParam},
 				"requestBody": map[string]interface{}{
 					"required": true,
 					"content": map[string]interface{}{
@@ -404,29 +652,305 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 		}
 		paths[batchDeletePath] = map[string]interface{}{
 			"post": map[string]interface{}{
-				"tags":    []string{t.Alias},
-				"summary": fmt.Sprintf("Batch delete %s", t.Alias),
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Batch delete %s", t.Alias),
+				"description": "请求体可为主键数组，或携带主键/唯一键的对象数组；按唯一键未找到的记录不计入 deleted_count。",
+				"parameters":  []interface{}{dryRunParam, resultsParam},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{},
+							},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Deleted"},
+				},
+			},
+		}
+		paths[batchGetPath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Batch get %s by ids", t.Alias),
+				"description": "按输入顺序返回记录，未找到的位置为 null 并列入 not_found。请求体可为主键数组、?key= 指定唯一键的值/元组数组，或键对象数组。",
+				"parameters":  []interface{}{fieldsParam, keyParam},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{},
+							},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"found":     map[string]interface{}{"type": "integer"},
+										"not_found": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+										"data": map[string]interface{}{
+											"type": "array",
+											// 未找到的位置为 null，3.0 中 $ref 旁的 nullable 无效，需包一层 allOf
+											"items": map[string]interface{}{
+												"allOf":    []interface{}{map[string]interface{}{"$ref": "#/components/schemas/" + t.Alias}},
+												"nullable": true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		paths[upsertPath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Insert or update %s records by conflict key", t.Alias),
+				"description": "冲突键不存在时插入，已存在时只更新请求中出现的字段。冲突键默认取 unique_keys 中第一组不含软删除字段的唯一键。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "on_conflict", "in": "query", "schema": map[string]string{"type": "string"}, "description": "冲突键，主键或 unique_keys 中的一组，逗号分隔"},
+					map[string]interface{}{"name": "dry_run", "in": "query", "schema": map[string]string{"type": "boolean"}, "description": "在回滚的事务中执行"},
+				},
 				"requestBody": map[string]interface{}{
 					"required": true,
 					"content": map[string]interface{}{
 						"application/json": map[string]interface{}{
 							"schema": map[string]interface{}{
 								"type":  "array",
-								"items": map[string]interface{}{"type": "string"},
+								"items": map[string]interface{}{"$ref": "#/components/schemas/" + t.Alias},
 							},
 						},
 					},
 				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Upserted"},
+				},
+			},
+		}
+		paths[updateWherePath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Update %s records matching filters", t.Alias),
+				"description": "按查询参数中的过滤条件（与列表接口相同的字段__操作符语法，至少一个）批量设置请求体中的字段。dry_run=true 仅返回匹配数量；匹配数超过 max_affected 时拒绝执行。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "dry_run", "in": "query", "schema": map[string]string{"type": "boolean"}, "description": "只统计匹配数量，不执行更新"},
+					map[string]interface{}{"name": "max_affected", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "最大影响行数"},
+				},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/" + t.Alias},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Updated"},
+					"422": map[string]interface{}{"description": "Matched records exceed max_affected"},
+				},
+			},
+		}
+		paths[deleteWherePath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Delete %s records matching filters", t.Alias),
+				"description": "按查询参数中的过滤条件（至少一个）批量删除，配置了软删除字段的表执行软删除。dry_run=true 仅返回匹配数量；匹配数超过 max_affected 时拒绝执行。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "dry_run", "in": "query", "schema": map[string]string{"type": "boolean"}, "description": "只统计匹配数量，不执行删除"},
+					map[string]interface{}{"name": "max_affected", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "最大影响行数"},
+				},
 				"responses": map[string]interface{}{
 					"200": map[string]interface{}{"description": "Deleted"},
+					"403": map[string]interface{}{"description": "Forbidden"},
+					"422": map[string]interface{}{"description": "Matched records exceed max_affected"},
+				},
+			},
+		}
+		paths[statsPath] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Column statistics of %s", t.Alias),
+				"description": "返回各列的 min/max/avg/null_count/distinct_count，支持与列表接口相同的过滤条件。",
+				"parameters":  []interface{}{fieldsParam},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Statistics"},
+				},
+			},
+		}
+		timeFieldDesc := "时间字段，默认取表配置 timeseries.time_field"
+		if t.TimeSeries != nil {
+			timeFieldDesc += "（" + t.TimeSeries.TimeField + "）"
+		}
+		paths[timeSeriesPath] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Time-bucketed aggregation of %s", t.Alias),
+				"description": "按时间字段分桶聚合，支持与列表接口相同的过滤条件，最多返回 10000 个分桶。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "interval", "in": "query", "required": true, "schema": map[string]string{"type": "string"}, "description": "分桶间隔，如 30s、5m、1h、1d，最小 1s"},
+					map[string]interface{}{"name": "agg", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"avg", "sum", "min", "max", "count"}}, "description": "聚合函数，默认 avg"},
+					map[string]interface{}{"name": "fields", "in": "query", "schema": map[string]string{"type": "string"}, "description": "聚合字段，逗号分隔；agg=count 时可省略"},
+					map[string]interface{}{"name": "group_by", "in": "query", "schema": map[string]string{"type": "string"}, "description": "分组字段，逗号分隔"},
+					map[string]interface{}{"name": "time_field", "in": "query", "schema": map[string]string{"type": "string"}, "description": timeFieldDesc},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Buckets"},
+				},
+			},
+		}
+		paths[aggregatePath] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Grouped aggregation of %s", t.Alias),
+				"description": "按字段分组计算 count/sum/avg/min/max，支持与列表接口相同的过滤条件；未指定 limit 时最多返回 10000 个分组。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "group_by", "in": "query", "schema": map[string]string{"type": "string"}, "description": "分组字段，逗号分隔；省略时对全部匹配记录聚合"},
+					map[string]interface{}{"name": "metrics", "in": "query", "schema": map[string]string{"type": "string"}, "description": "指标，逗号分隔的 函数:字段，如 count,sum:amount,avg:score；默认 count"},
+					map[string]interface{}{"name": "order", "in": "query", "schema": map[string]string{"type": "string"}, "description": "按分组字段或指标名（如 sum_amount）排序，- 前缀降序"},
+					map[string]interface{}{"name": "limit", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "最多返回的分组数"},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Groups"},
+				},
+			},
+		}
+		paths[distinctPath] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Distinct values of a %s field", t.Alias),
+				"description": "返回字段的不同取值及记录数，支持与列表接口相同的过滤条件；取值超过 limit 时 truncated 为 true。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "field", "in": "query", "required": true, "schema": map[string]string{"type": "string"}, "description": "字段名"},
+					map[string]interface{}{"name": "order", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"-count", "count", "value", "-value"}}, "description": "排序，默认 -count"},
+					map[string]interface{}{"name": "limit", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "最多返回的取值数，默认 1000，最大 10000"},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"field": map[string]string{"type": "string"},
+										"data": map[string]interface{}{
+											"type": "array",
+											"items": map[string]interface{}{
+												"type": "object",
+												"properties": map[string]interface{}{
+													"value": map[string]interface{}{"nullable": true},
+													"count": map[string]string{"type": "integer"},
+												},
+											},
+										},
+										"truncated": map[string]string{"type": "boolean"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		paths[exportPath] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Export %s as CSV, NDJSON or Excel", t.Alias),
+				"description": "流式导出全部匹配的记录（不分页），过滤条件、fields、order 与列表接口相同；中途失败时在 trailer X-Export-Error 中给出原因。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "format", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"csv", "ndjson", "xlsx"}}, "description": "导出格式，默认 csv"},
+					map[string]interface{}{"name": "delimiter", "in": "query", "schema": map[string]string{"type": "string"}, "description": "CSV 分隔符，默认逗号"},
+					map[string]interface{}{"name": "chunk_size", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "每次查询的行数，默认 1000，最大 10000"},
+					fieldsParam,
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"text/csv":             map[string]interface{}{"schema": map[string]string{"type": "string"}},
+							"application/x-ndjson": map[string]interface{}{"schema": map[string]string{"type": "string"}},
+							"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": map[string]interface{}{
+								"schema": map[string]string{"type": "string", "format": "binary"},
+							},
+						},
+					},
+				},
+			},
+		}
+		paths[bulkJobsPath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Submit async bulk job for %s", t.Alias),
+				"description": "请求体为 JSON 数组或 NDJSON（或通过 source 指定数据地址），返回任务 ID，通过 GET /api/jobs/{id} 查询进度。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "operation", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"create", "update", "delete"}}, "description": "操作类型，默认 create"},
+					map[string]interface{}{"name": "source", "in": "query", "schema": map[string]string{"type": "string"}, "description": "数据地址（http/https，如 S3 预签名 URL），指定时忽略请求体"},
+					map[string]interface{}{"name": "priority", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "任务优先级，越大越先执行，默认 0"},
+				},
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"$ref": "#/components/schemas/" + t.Alias},
+							},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"202": map[string]interface{}{"description": "Accepted"},
+				},
+			},
+		}
+		paths[importPath] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Import CSV or NDJSON file into %s", t.Alias),
+				"description": "multipart 上传 file 字段，CSV 首行为字段名；按块在事务中写入，不通过校验或写入失败的行跳过，返回逐行错误报告。",
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "format", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"csv", "ndjson"}}, "description": "文件格式，默认按扩展名判断"},
+					map[string]interface{}{"name": "delimiter", "in": "query", "schema": map[string]string{"type": "string"}, "description": "CSV 分隔符，默认逗号（.tsv 为制表符）"},
+					map[string]interface{}{"name": "chunk_size", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "每个事务写入的行数，默认 bulk_job.chunk_size"},
+					dryRunParam,
+				},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"multipart/form-data": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":       "object",
+								"properties": map[string]interface{}{"file": map[string]string{"type": "string", "format": "binary"}},
+								"required":   []string{"file"},
+							},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"201": map[string]interface{}{"description": "全部导入"},
+					"207": map[string]interface{}{"description": "部分导入，errors 为失败的行"},
+					"422": map[string]interface{}{"description": "全部失败"},
 				},
 			},
 		}
 		paths[idPath] = map[string]interface{}{
 			"get": map[string]interface{}{
-				"tags":       []string{t.Alias},
-				"summary":    fmt.Sprintf("Get %s by id", t.Alias),
-				"parameters": []interface{}{idParam, fieldsParam, keyParam},
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Get %s by id", t.Alias),
+				"description": "可附带与列表接口相同的过滤参数缩小查找范围；按非主键查找命中多条时返回 409。",
+				"parameters":  []interface{}{idParam, fieldsParam, keyParam, asOfParam},
 				"responses": map[string]interface{}{
 					"200": map[string]interface{}{
 						"description": "OK",
@@ -438,12 +962,13 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 							},
 						},
 					},
+					"409": map[string]interface{}{"description": "命中多条记录"},
 				},
 			},
 			"put": map[string]interface{}{
 				"tags":       []string{t.Alias},
 				"summary":    fmt.Sprintf("Update %s by id", t.Alias),
-				"parameters": []interface{}{idParam},
+				"parameters": []interface{}{idParam, dryRunParam, returnParam, fieldsParam},
 				"requestBody": map[string]interface{}{
 					"required": true,
 					"content": map[string]interface{}{
@@ -458,33 +983,96 @@ func toSwaggerYaml(tables []TableMeta, dbAlias, apiPrefix string) (string, error
 					"200": map[string]interface{}{"description": "Updated"},
 				},
 			},
+			"patch": map[string]interface{}{
+				"tags":        []string{t.Alias},
+				"summary":     fmt.Sprintf("Partially update %s by id", t.Alias),
+				"description": "只更新请求体中的字段，表结构之外的字段返回 400；auto_update 字段由服务端写入。",
+				"parameters":  []interface{}{idParam, dryRunParam, returnParam, fieldsParam},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":                 "object",
+								"properties":           props,
+								"additionalProperties": false,
+								"minProperties":        1,
+							},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Updated"},
+					"400": map[string]interface{}{"description": "未知字段或没有可更新的字段"},
+				},
+			},
 			"delete": map[string]interface{}{
 				"tags":       []string{t.Alias},
 				"summary":    fmt.Sprintf("Delete %s by id", t.Alias),
-				"parameters": []interface{}{idParam},
+				"parameters": []interface{}{idParam, dryRunParam},
 				"responses": map[string]interface{}{
 					"200": map[string]interface{}{"description": "Deleted"},
 				},
 			},
 		}
+		if t.SoftDelKey != "" {
+			addIncludeDeletedParam(paths, basePath, idPath)
+		}
+		if t.ReadOnly {
+			stripSwaggerWrites(paths, basePath)
+		}
+		applyTableDocs(paths, basePath, t.Docs)
 	}
+	tags, tagGroups := orderSwaggerTags(tags, opts.TagGroups)
 	sw["tags"] = tags
+	if len(tagGroups) > 0 {
+		sw["x-tagGroups"] = tagGroups
+	}
+	var doc yaml.Node
+	if err := doc.Encode(sw); err != nil {
+		return "", err
+	}
+	orderSwaggerPaths(&doc, fmt.Sprintf("%s/%s/", apiPrefix, dbAlias), opts.OperationOrder)
 	buf := &bytes.Buffer{}
 	yamlEncoder := yaml.NewEncoder(buf)
 	yamlEncoder.SetIndent(2)
-	if err := yamlEncoder.Encode(sw); err != nil {
+	if err := yamlEncoder.Encode(&doc); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
 
+// stripSwaggerWrites 删除只读表的写接口（batch_get 只读取数据，保留）
+func stripSwaggerWrites(paths map[string]interface{}, basePath string) {
+	for p, item := range paths {
+		if p != basePath && !strings.HasPrefix(p, basePath+"/") || strings.HasSuffix(p, "/batch_get") {
+			continue
+		}
+		ops := item.(map[string]interface{})
+		for _, method := range []string{"post", "put", "patch", "delete"} {
+			delete(ops, method)
+		}
+		if len(ops) == 0 {
+			delete(paths, p)
+		}
+	}
+}
+
 // ====== 写入 swagger.yaml 到表目录 ======
 func writeSwaggerYamlToDir(yamlContent, outputDir string) error {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("create directory failed: %w", err)
 	}
+	if err := keepPreviousSwagger(outputDir, yamlContent); err != nil {
+		return fmt.Errorf("keep previous swagger failed: %w", err)
+	}
+	// 先写临时文件再改名，正在读取 swagger.yaml 的请求不会读到写了一半的内容
 	filename := filepath.Join(outputDir, "swagger.yaml")
-	if err := os.WriteFile(filename, []byte(yamlContent), 0644); err != nil {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, []byte(yamlContent), 0644); err != nil {
+		return fmt.Errorf("write file %s failed: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
 		return fmt.Errorf("write file %s failed: %w", filename, err)
 	}
 	return nil
@@ -492,39 +1080,105 @@ func writeSwaggerYamlToDir(yamlContent, outputDir string) error {
 
 // ========== 其余数据库元数据适配器和通用工具 ==========
 
-// extractTableMetaWithDefaultAlias 会给 TableMeta.Alias 默认赋值为表名
-func extractTableMetaWithDefaultAlias(dbType, dsn, dbName string) ([]TableMeta, error) {
-	tables, err := extractTableMeta(dbType, dsn, dbName)
+// extractTableMetaWithDefaultAlias 会给 TableMeta.Alias 默认赋值为按命名策略处理后的表名，提取结果缓存在 cacheDir
+func extractTableMetaWithDefaultAlias(dbType, dsn, dbName string, naming *tableNaming, cacheDir string, in *introspection) ([]TableMeta, error) {
+	tables, err := extractTableMetaCached(dbType, dsn, dbName, cacheDir, in)
 	if err != nil {
 		return nil, err
 	}
 	for i := range tables {
-		tables[i].Alias = tables[i].Name
+		tables[i].Alias = naming.alias(tables[i].Name)
 	}
 	return tables, nil
 }
 
-// 封装一个方法来获取 alias，失败时返回空字符串
-func getAliasFromYAML(filePath string) string {
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return ""
-	}
+// tableYAML 已有表配置中生成 swagger 要用到的配置项，每个表配置文件只解析一次
+type tableYAML struct {
+	Alias            string                      `yaml:"alias"`
+	HiddenFields     []string                    `yaml:"hidden_fields"`
+	MaskedFields     map[string]string           `yaml:"masked_fields"`
+	FieldTransforms  map[string][]interface{}    `yaml:"field_transforms"`
+	LabelJoins       []string                    `yaml:"label_joins"`
+	ValueLabels      map[string]valueLabelConfig `yaml:"value_labels"`
+	Validations      map[string]fieldValidation  `yaml:"validations"`
+	FilterableFields []string                    `yaml:"filterable_fields"`
+	SortableFields   []string                    `yaml:"sortable_fields"`
+	FieldAliases     map[string]string           `yaml:"field_aliases"` // 物理列名 → API 字段名
+	Docs             tableDocs                   `yaml:"docs"`
+	Rollup           rollupConfig                `yaml:"rollup"`
+
+	doc *yaml.Node // 原始文档，重新生成时保留手工添加的配置项
+}
 
-	// 读取 YAML 文件内容
+// readTableYAML 读取已有的表配置，文件不存在或无法解析时返回 nil；
+// 个别配置项类型不符时其余配置项照常使用
+func readTableYAML(filePath string) *tableYAML {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return ""
+		return nil
 	}
-
-	// 解析 YAML 内容到结构体
-	var tbl TableMeta
-	err = yaml.Unmarshal(data, &tbl)
-	if err != nil {
-		return ""
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil
 	}
+	t := &tableYAML{doc: &doc}
+	if err := doc.Decode(t); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil
+		}
+	}
+	return t
+}
 
-	return tbl.Alias
+// applyTableYAML 按表配置补充生成 swagger 用的元数据，字段别名最后处理
+func applyTableYAML(t TableMeta, conf *tableYAML) TableMeta {
+	if conf == nil {
+		return t
+	}
+	t = applyFieldPolicyMeta(t, conf.HiddenFields, conf.MaskedFields)
+	t = applyFieldTransformMeta(t, conf.FieldTransforms)
+	t = applyLabelJoinMeta(t, labelJoinFields(conf.LabelJoins))
+	t = applyLabelJoinMeta(t, valueLabelFields(conf.ValueLabels))
+	t = applyValidationMeta(t, conf.Validations)
+	t.Filterable, t.Sortable = conf.FilterableFields, conf.SortableFields
+	t = applyFieldAliases(t, conf.FieldAliases)
+	t.Docs = conf.Docs
+	if conf.Rollup.Source != "" {
+		// rollup 表由汇总任务写入，swagger 不生成写接口
+		t.ReadOnly = true
+	}
+	return t
+}
+
+// applyFieldAliases 返回按 API 字段名改写后的表元数据，仅用于生成 swagger
+func applyFieldAliases(t TableMeta, aliases map[string]string) TableMeta {
+	if len(aliases) == 0 {
+		return t
+	}
+	rename := func(name string) string {
+		if alias, ok := aliases[name]; ok && alias != "" {
+			return alias
+		}
+		return name
+	}
+	fields := make([]FieldMeta, len(t.Fields))
+	for i, f := range t.Fields {
+		f.Name = rename(f.Name)
+		fields[i] = f
+	}
+	t.Fields = fields
+	t.PrimaryKey = rename(t.PrimaryKey)
+	renameAll := func(names []string) []string {
+		renamed := make([]string, len(names))
+		for i, name := range names {
+			renamed[i] = rename(name)
+		}
+		return renamed
+	}
+	t.Filterable = renameAll(t.Filterable)
+	t.Sortable = renameAll(t.Sortable)
+	return t
 }
 
 // ====== 类型推断工具 ======
@@ -576,6 +1230,23 @@ func toSwaggerType(dbType string) string {
 	}
 }
 
+// sqliteStrictSchema STRICT 表的列类型只能是 INT、INTEGER、REAL、TEXT、BLOB、ANY，按存储类型精确生成
+func sqliteStrictSchema(dbType string) map[string]interface{} {
+	switch strings.ToUpper(strings.TrimSpace(dbType)) {
+	case "INT", "INTEGER":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "REAL":
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case "BLOB":
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case "ANY":
+		// ANY 列保留写入时的原始类型，不限定 type
+		return map[string]interface{}{}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
 func sanitizeSwaggerText(s string) string {
 	// 保证没有swagger特殊符号，防注入
 	return strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "\"", "'")
@@ -721,30 +1392,55 @@ func makeSwaggerQueryParameters() []map[string]interface{} {
 		{"name": "order", "in": "query", "schema": map[string]string{"type": "string"}, "description": "排序，格式如 id desc"},
 		{"name": "page", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "页码"},
 		{"name": "page_size", "in": "query", "schema": map[string]string{"type": "integer"}, "description": "每页条数"},
+		{"name": "cursor", "in": "query", "schema": map[string]string{"type": "string"}, "description": "游标分页：第一页传空值，之后传上一页返回的 next_cursor，此时忽略 page"},
+		{"name": "statement_timeout", "in": "query", "schema": map[string]string{"type": "string"}, "description": "查询超时，如 5s，不超过表配置的值"},
 	}
 }
 
 // ====== 字段属性生成（必填字段/默认值字段规则）=======
-func toSwaggerSchemaFields(fields []FieldMeta) (map[string]interface{}, []string) {
+func toSwaggerSchemaFields(fields []FieldMeta, strict bool) (map[string]interface{}, []string) {
 	props := map[string]interface{}{}
 	var required []string
 	for _, f := range fields {
-		fieldType := toSwaggerType(f.Type)
-		prop := map[string]interface{}{
-			"type": fieldType,
+		var prop map[string]interface{}
+		if strict {
+			prop = sqliteStrictSchema(f.Type)
+		} else {
+			prop = map[string]interface{}{"type": toSwaggerType(f.Type)}
 		}
 		if f.Comment != "" {
 			prop["description"] = sanitizeSwaggerText(f.Comment)
 		}
+		if f.Nullable && !f.IsPrimary {
+			prop["nullable"] = true
+		}
 		readOnly := f.AutoInc || f.OnUpdate || isAutoUpdateField(f.Name) || isSoftDelField(f.Name) || isResponseReadOnlyField(f.Name) || isCommonReadOnlyField(f.Name)
-		if readOnly {
+		if f.Hidden {
+			// 隐藏字段只写不读，writeOnly 与 readOnly 不能同时出现
+			prop["writeOnly"] = true
+		} else if readOnly {
 			prop["readOnly"] = true
 		}
+		if f.Mask != "" {
+			// 脱敏后的值总是字符串
+			prop["type"] = "string"
+			prop["x-masked"] = f.Mask
+			delete(prop, "format")
+		}
+		if f.Transformed {
+			prop["type"] = "string"
+			delete(prop, "format")
+		}
+		if f.Validation != nil {
+			validationSchema(prop, f.Validation)
+		}
 		props[f.Name] = prop
 
 		if !f.Nullable && !f.HasDefault && !f.AutoInc && !f.OnUpdate &&
 			!isAutoUpdateField(f.Name) && !isSoftDelField(f.Name) && !isResponseReadOnlyField(f.Name) && !isCommonReadOnlyField(f.Name) {
 			required = append(required, f.Name)
+		} else if f.Validation != nil && f.Validation.Required {
+			required = append(required, f.Name)
 		}
 	}
 	return props, required
@@ -814,18 +1510,34 @@ func dedupUniques(keys [][]string) [][]string {
 }
 
 // ====== 数据库元数据提取适配器 ======
-func extractTableMeta(dbType, dsn, dbName string) ([]TableMeta, error) {
+func extractTableMeta(dbType, dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	switch strings.ToLower(dbType) {
 	case "mysql":
-		return extractMySQLMeta(dsn, dbName)
+		return extractMySQLMeta(dsn, dbName, in)
+	case "tidb":
+		return extractTiDBMeta(dsn, dbName, in)
 	case "postgres", "postgresql":
-		return extractPostgreSQLMeta(dsn, dbName)
+		return extractPostgreSQLMeta(dsn, dbName, in)
+	case "cockroachdb":
+		return extractCockroachMeta(dsn, dbName, in)
 	case "sqlite":
-		return extractSQLiteMeta(dsn, dbName)
+		return extractSQLiteMeta(dsn, dbName, in)
 	case "sqlserver":
-		return extractSQLServerMeta(dsn, dbName)
+		return extractSQLServerMeta(dsn, dbName, in)
 	case "clickhouse":
-		return extractClickHouseMeta(dsn, dbName)
+		return extractClickHouseMeta(dsn, dbName, in)
+	case "snowflake":
+		return extractSnowflakeMeta(dsn, dbName, in)
+	case "bigquery":
+		return extractBigQueryMeta(dsn, dbName, in)
+	case "influxdb":
+		return extractInfluxMeta(dsn, dbName)
+	case "files":
+		return extractFileMeta(dsn, dbName)
+	case "ego":
+		return extractEgoMeta(dsn, dbName)
+	case "openapi":
+		return extractOpenAPIMeta(dsn, dbName)
 	case "mongodb":
 		return extractMongoDBMeta(dsn, dbName)
 	default:
@@ -834,7 +1546,7 @@ func extractTableMeta(dbType, dsn, dbName string) ([]TableMeta, error) {
 }
 
 // ---- MySQL ----
-func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
+func extractMySQLMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open mysql database %s failed: %w", dbName, err)
@@ -854,15 +1566,15 @@ func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
 		tables = append(tables, TableMeta{Name: name, Comment: comment})
 	}
 	rows.Close()
-	for i := range tables {
-		fieldsRows, err := db.Query(`
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		fieldsRows, err := db.QueryContext(ctx, `
 			SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA, COLUMN_DEFAULT, IFNULL(COLUMN_COMMENT,''), EXTRA
 			FROM information_schema.columns
 			WHERE TABLE_SCHEMA=? AND TABLE_NAME=?
 			ORDER BY ORDINAL_POSITION
 		`, dbName, tables[i].Name)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		var fields []FieldMeta
 		for fieldsRows.Next() {
@@ -870,7 +1582,7 @@ func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			var nullable, colKey, extra, defaultVal sql.NullString
 			if err := fieldsRows.Scan(&f.Name, &f.Type, &nullable, &colKey, &extra, &defaultVal, &f.Comment, &extra); err != nil {
 				fieldsRows.Close()
-				return nil, err
+				return err
 			}
 			f.Nullable = nullable.String == "YES"
 			f.IsPrimary = colKey.String == "PRI"
@@ -894,7 +1606,7 @@ func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			}
 		}
 		// 提取唯一索引（支持联合唯一）
-		idxRows, err := db.Query(`
+		idxRows, err := db.QueryContext(ctx, `
 			SELECT INDEX_NAME, COLUMN_NAME
 			FROM information_schema.statistics
 			WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND NON_UNIQUE=0 AND INDEX_NAME != 'PRIMARY'
@@ -914,6 +1626,8 @@ func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
 				uniques = append(uniques, cols)
 			}
 			tables[i].UniqueKeys = dedupUniques(uniques)
+		} else {
+			in.warn(tables[i].Name, metaStageUniqueKeys, err)
 		}
 		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
 		for _, f := range fields {
@@ -932,12 +1646,12 @@ func extractMySQLMeta(dsn, dbName string) ([]TableMeta, error) {
 		if len(autoUpdate) > 0 {
 			tables[i].AutoUpdate = autoUpdate
 		}
-	}
-	return tables, nil
+		return nil
+	})
 }
 
 // ---- PostgreSQL ----
-func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
+func extractPostgreSQLMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open postgresql database %s failed: %w", dbName, err)
@@ -962,15 +1676,15 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 		tables = append(tables, TableMeta{Name: name, Comment: comment})
 	}
 	rows.Close()
-	for i := range tables {
-		colsRows, err := db.Query(`
+	tables, err = in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		colsRows, err := db.QueryContext(ctx, `
 			SELECT column_name, data_type, is_nullable, column_default, col_description(('"'||table_schema||'"."'||table_name||'"')::regclass, ordinal_position)
 			FROM information_schema.columns
 			WHERE table_schema='public' AND table_name=$1
 			ORDER BY ordinal_position
 		`, tables[i].Name)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		var fields []FieldMeta
 		for colsRows.Next() {
@@ -978,7 +1692,7 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			var nullable, defaultVal, comment sql.NullString
 			if err := colsRows.Scan(&f.Name, &f.Type, &nullable, &defaultVal, &comment); err != nil {
 				colsRows.Close()
-				return nil, err
+				return err
 			}
 			f.Nullable = nullable.String == "YES"
 			f.HasDefault = defaultVal.Valid
@@ -993,7 +1707,7 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			fields = append(fields, f)
 		}
 		colsRows.Close()
-		pkRow := db.QueryRow(`
+		pkRow := db.QueryRowContext(ctx, `
 			SELECT kcu.column_name
 			FROM information_schema.table_constraints tc
 			JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name
@@ -1001,7 +1715,9 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			LIMIT 1
 		`, tables[i].Name)
 		var pk string
-		_ = pkRow.Scan(&pk)
+		if err := pkRow.Scan(&pk); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			in.warn(tables[i].Name, metaStagePrimaryKey, err)
+		}
 		for j := range fields {
 			if fields[j].Name == pk {
 				fields[j].IsPrimary = true
@@ -1009,7 +1725,7 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 			}
 		}
 		// 唯一索引（支持联合唯一）
-		uniqRows, err := db.Query(`
+		uniqRows, err := db.QueryContext(ctx, `
 			SELECT tc.constraint_name, kcu.column_name
 			FROM information_schema.table_constraints tc
 			JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name
@@ -1030,6 +1746,8 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 				uniques = append(uniques, cols)
 			}
 			tables[i].UniqueKeys = dedupUniques(uniques)
+		} else {
+			in.warn(tables[i].Name, metaStageUniqueKeys, err)
 		}
 		tables[i].Fields = fields
 		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
@@ -1049,25 +1767,31 @@ func extractPostgreSQLMeta(dsn, dbName string) ([]TableMeta, error) {
 		if len(autoUpdate) > 0 {
 			tables[i].AutoUpdate = autoUpdate
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	applyTimescaleMeta(db, tables)
 	return tables, nil
 }
 
 // ---- SQLite ----
-func extractSQLiteMeta(dsn, dbName string) ([]TableMeta, error) {
+func extractSQLiteMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite database %s failed: %w", dbName, err)
 	}
 	defer db.Close()
-	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type='table' LIMIT 500`)
+	rows, err := db.Query(`SELECT name, IFNULL(sql, '') FROM sqlite_master WHERE type='table' LIMIT 500`)
 	if err != nil {
 		return nil, err
 	}
 	var tables []TableMeta
+	var createSQLs []string
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+		var name, createSQL string
+		if err := rows.Scan(&name, &createSQL); err != nil {
 			rows.Close()
 			return nil, err
 		}
@@ -1075,15 +1799,27 @@ func extractSQLiteMeta(dsn, dbName string) ([]TableMeta, error) {
 			continue // 跳过系统表
 		}
 		tables = append(tables, TableMeta{Name: name})
+		createSQLs = append(createSQLs, createSQL)
 	}
 	rows.Close()
-	for i := range tables {
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		opts := parseSQLiteTableOptions(createSQLs[i])
+		tables[i].Strict = opts.strict
+		switch {
+		case opts.strict && opts.withoutRowid:
+			tables[i].Comment = "STRICT, WITHOUT ROWID"
+		case opts.strict:
+			tables[i].Comment = "STRICT"
+		case opts.withoutRowid:
+			tables[i].Comment = "WITHOUT ROWID"
+		}
 		stmt := fmt.Sprintf(`PRAGMA table_info('%s')`, tables[i].Name)
-		colsRows, err := db.Query(stmt)
+		colsRows, err := db.QueryContext(ctx, stmt)
 		if err != nil {
-			return nil, fmt.Errorf("exec %s: %w", stmt, err)
+			return fmt.Errorf("exec %s: %w", stmt, err)
 		}
 		var fields []FieldMeta
+		var pkCols []string
 		for colsRows.Next() {
 			var cid int
 			var f FieldMeta
@@ -1091,7 +1827,7 @@ func extractSQLiteMeta(dsn, dbName string) ([]TableMeta, error) {
 			var dflt_value sql.NullString
 			if err := colsRows.Scan(&cid, &f.Name, &f.Type, &notnull, &dflt_value, &pk); err != nil {
 				colsRows.Close()
-				return nil, err
+				return err
 			}
 			f.Nullable = notnull == 0
 			f.IsPrimary = pk > 0
@@ -1101,46 +1837,72 @@ func extractSQLiteMeta(dsn, dbName string) ([]TableMeta, error) {
 			} else {
 				f.Default = convertDefaultByType("", f.Type, f.Nullable)
 			}
+			if f.IsPrimary {
+				pkCols = append(pkCols, f.Name)
+			}
 			fields = append(fields, f)
 		}
 		colsRows.Close()
+		// rowid 表中单列 INTEGER 主键是 rowid 的别名，未指定时自动分配；
+		// 只有声明了 AUTOINCREMENT 才视为自增，否则按有默认值处理（可由调用方指定）
+		if len(pkCols) == 1 && !opts.withoutRowid {
+			for j := range fields {
+				if fields[j].IsPrimary && strings.EqualFold(strings.TrimSpace(fields[j].Type), "INTEGER") {
+					if opts.autoIncrement {
+						fields[j].AutoInc = true
+					} else {
+						fields[j].HasDefault = true
+						fields[j].Default = nil
+					}
+				}
+			}
+		}
 		// 唯一索引（支持联合唯一）
 		var uniques [][]string
-		idxRows, err := db.Query(fmt.Sprintf(`PRAGMA index_list('%s')`, tables[i].Name))
+		idxRows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA index_list('%s')`, tables[i].Name))
 		if err == nil {
+			var uniqueIdx []string
 			for idxRows.Next() {
 				var idxSeq int
-				var idxName string
-				var idxUnique int
-				var origin, partial interface{}
-				if err := idxRows.Scan(&idxSeq, &idxName, &idxUnique, &origin, &partial); err == nil && idxUnique == 1 {
-					colRows, err := db.Query(fmt.Sprintf(`PRAGMA index_info('%s')`, idxName))
-					if err == nil {
-						var cols []string
-						for colRows.Next() {
-							var seqno, cid int
-							var colName string
-							if err := colRows.Scan(&seqno, &cid, &colName); err == nil {
-								cols = append(cols, colName)
-							}
-						}
-						colRows.Close()
-						if len(cols) > 0 {
-							uniques = append(uniques, cols)
-						}
-					}
+				var idxName, origin string
+				var idxUnique, partial int
+				// 跳过部分索引（WHERE 条件下才唯一）与主键索引
+				if err := idxRows.Scan(&idxSeq, &idxName, &idxUnique, &origin, &partial); err == nil && idxUnique == 1 && partial == 0 && origin != "pk" {
+					uniqueIdx = append(uniqueIdx, idxName)
 				}
 			}
 			idxRows.Close()
-		}
-		var pk string
-		for _, f := range fields {
-			if f.IsPrimary {
-				pk = f.Name
-				break
+			for _, idxName := range uniqueIdx {
+				colRows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA index_info('%s')`, idxName))
+				if err != nil {
+					continue
+				}
+				var cols []string
+				expr := false
+				for colRows.Next() {
+					var seqno, cid int
+					var colName sql.NullString
+					if err := colRows.Scan(&seqno, &cid, &colName); err != nil || !colName.Valid {
+						// 表达式索引的列名为 NULL
+						expr = true
+						continue
+					}
+					cols = append(cols, colName.String)
+				}
+				colRows.Close()
+				if !expr && len(cols) > 0 {
+					uniques = append(uniques, cols)
+				}
 			}
+		} else {
+			in.warn(tables[i].Name, metaStageUniqueKeys, err)
+		}
+		// 联合主键无法作为单一主键使用，按联合唯一处理
+		if len(pkCols) == 1 {
+			tables[i].PrimaryKey = pkCols[0]
+		} else if len(pkCols) > 1 {
+			uniques = append(uniques, pkCols)
 		}
-		tables[i].PrimaryKey = pk
 		tables[i].Fields = fields
 		tables[i].UniqueKeys = dedupUniques(uniques)
 		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
@@ -1159,65 +1921,174 @@ func extractSQLiteMeta(dsn, dbName string) ([]TableMeta, error) {
 		if len(autoUpdate) > 0 {
 			tables[i].AutoUpdate = autoUpdate
 		}
+		return nil
+	})
+}
+
+type sqliteTableOptions struct {
+	strict        bool
+	withoutRowid  bool
+	autoIncrement bool // INTEGER PRIMARY KEY 声明了 AUTOINCREMENT
+}
+
+var sqliteAutoIncrementRe = regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`)
+
+// parseSQLiteTableOptions 解析建表语句末尾的表选项，如 ") STRICT, WITHOUT ROWID"，
+// 以及列定义（或 PRIMARY KEY 约束）中的 AUTOINCREMENT
+func parseSQLiteTableOptions(createSQL string) sqliteTableOptions {
+	var opts sqliteTableOptions
+	// SQLite 只允许 INTEGER PRIMARY KEY 声明 AUTOINCREMENT，去掉字符串、标识符与注释后按关键字判断
+	opts.autoIncrement = sqliteAutoIncrementRe.MatchString(stripSQLiteQuoted(createSQL))
+	idx := strings.LastIndex(createSQL, ")")
+	if idx < 0 {
+		return opts
 	}
-	return tables, nil
+	for _, opt := range strings.Split(createSQL[idx+1:], ",") {
+		switch strings.Join(strings.Fields(strings.ToUpper(opt)), " ") {
+		case "STRICT":
+			opts.strict = true
+		case "WITHOUT ROWID":
+			opts.withoutRowid = true
+		}
+	}
+	return opts
+}
+
+// stripSQLiteQuoted 去掉建表语句中的字符串、带引号的标识符与注释
+func stripSQLiteQuoted(createSQL string) string {
+	var b strings.Builder
+	for i := 0; i < len(createSQL); i++ {
+		c := createSQL[i]
+		var end string
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end = string(c)
+		case c == '[':
+			end = "]"
+		case c == '-' && strings.HasPrefix(createSQL[i:], "--"):
+			end = "\n"
+		case c == '/' && strings.HasPrefix(createSQL[i:], "/*"):
+			i++
+			end = "*/"
+		default:
+			b.WriteByte(c)
+			continue
+		}
+		// 引号内以连续两个引号转义，按相邻的两段处理结果相同
+		j := strings.Index(createSQL[i+1:], end)
+		if j < 0 {
+			break
+		}
+		i += j + len(end)
+		b.WriteByte(' ')
+	}
+	return b.String()
 }
 
 // ---- SQLServer ----
-func extractSQLServerMeta(dsn, dbName string) ([]TableMeta, error) {
+func extractSQLServerMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	db, err := sql.Open("sqlserver", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open sqlserver database %s failed: %w", dbName, err)
 	}
 	defer db.Close()
-	rows, err := db.Query(`SELECT name FROM sys.tables`)
+	// 表注释保存在扩展属性 MS_Description 中
+	rows, err := db.Query(`
+		SELECT TOP 500 t.object_id, t.name, CAST(ISNULL(ep.value, '') AS NVARCHAR(MAX))
+		FROM sys.tables t
+		LEFT JOIN sys.extended_properties ep ON ep.class = 1 AND ep.major_id = t.object_id AND ep.minor_id = 0 AND ep.name = 'MS_Description'
+		WHERE t.is_ms_shipped = 0
+		ORDER BY t.name
+	`)
 	if err != nil {
 		return nil, err
 	}
 	var tables []TableMeta
+	var objectIDs []int64
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+		var objectID int64
+		var name, comment string
+		if err := rows.Scan(&objectID, &name, &comment); err != nil {
 			rows.Close()
 			return nil, err
 		}
-		tables = append(tables, TableMeta{Name: name})
+		tables = append(tables, TableMeta{Name: name, Comment: comment})
+		objectIDs = append(objectIDs, objectID)
 	}
 	rows.Close()
-	for i := range tables {
-		stmt := fmt.Sprintf(`SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = '%s'`, tables[i].Name)
-		colsRows, err := db.Query(stmt)
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		colsRows, err := db.QueryContext(ctx, `
+			SELECT c.name, ty.name, c.is_nullable, c.is_identity, dc.definition, CAST(ISNULL(ep.value, '') AS NVARCHAR(MAX))
+			FROM sys.columns c
+			JOIN sys.types ty ON ty.user_type_id = c.user_type_id
+			LEFT JOIN sys.default_constraints dc ON dc.object_id = c.default_object_id
+			LEFT JOIN sys.extended_properties ep ON ep.class = 1 AND ep.major_id = c.object_id AND ep.minor_id = c.column_id AND ep.name = 'MS_Description'
+			WHERE c.object_id = @p1
+			ORDER BY c.column_id
+		`, objectIDs[i])
 		if err != nil {
-			return nil, fmt.Errorf("exec %s: %w", stmt, err)
+			return err
 		}
 		var fields []FieldMeta
 		for colsRows.Next() {
 			var f FieldMeta
-			var nullable string
 			var defaultVal sql.NullString
-			if err := colsRows.Scan(&f.Name, &f.Type, &nullable, &defaultVal); err != nil {
+			if err := colsRows.Scan(&f.Name, &f.Type, &f.Nullable, &f.AutoInc, &defaultVal, &f.Comment); err != nil {
 				colsRows.Close()
-				return nil, err
+				return err
 			}
-			f.Nullable = nullable == "YES"
 			f.HasDefault = defaultVal.Valid
 			if defaultVal.Valid {
-				f.Default = convertDefaultByType(defaultVal.String, f.Type, f.Nullable)
+				f.Default = convertDefaultByType(trimSQLServerDefault(defaultVal.String), f.Type, f.Nullable)
 			} else {
 				f.Default = convertDefaultByType("", f.Type, f.Nullable)
 			}
 			fields = append(fields, f)
 		}
 		colsRows.Close()
+		// 主键；联合主键无法作为单一主键使用，按联合唯一处理
+		var uniques [][]string
+		pkRows, err := db.QueryContext(ctx, `
+			SELECT c.name
+			FROM sys.key_constraints kc
+			JOIN sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+			JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+			WHERE kc.parent_object_id = @p1 AND kc.type = 'PK'
+			ORDER BY ic.key_ordinal
+		`, objectIDs[i])
+		if err == nil {
+			var pkCols []string
+			for pkRows.Next() {
+				var colName string
+				if err := pkRows.Scan(&colName); err == nil {
+					pkCols = append(pkCols, colName)
+				}
+			}
+			pkRows.Close()
+			for j := range fields {
+				for _, col := range pkCols {
+					if fields[j].Name == col {
+						fields[j].IsPrimary = true
+					}
+				}
+			}
+			if len(pkCols) == 1 {
+				tables[i].PrimaryKey = pkCols[0]
+			} else if len(pkCols) > 1 {
+				uniques = append(uniques, pkCols)
+			}
+		} else {
+			in.warn(tables[i].Name, metaStagePrimaryKey, err)
+		}
 		// 唯一索引（支持联合唯一）
-		idxRows, err := db.Query(fmt.Sprintf(`
+		idxRows, err := db.QueryContext(ctx, `
 			SELECT i.name, c.name
 			FROM sys.indexes i
 			JOIN sys.index_columns ic ON i.object_id = ic.object_id AND i.index_id = ic.index_id
 			JOIN sys.columns c ON ic.object_id = c.object_id AND ic.column_id = c.column_id
-			WHERE i.object_id = OBJECT_ID('%s') AND i.is_unique = 1 AND i.is_primary_key = 0
+			WHERE i.object_id = @p1 AND i.is_unique = 1 AND i.is_primary_key = 0 AND ic.is_included_column = 0
 			ORDER BY i.name, ic.key_ordinal
-		`, tables[i].Name))
+		`, objectIDs[i])
 		if err == nil {
 			idxMap := map[string][]string{}
 			for idxRows.Next() {
@@ -1227,67 +2098,183 @@ func extractSQLServerMeta(dsn, dbName string) ([]TableMeta, error) {
 				}
 			}
 			idxRows.Close()
-			var uniques [][]string
 			for _, cols := range idxMap {
 				uniques = append(uniques, cols)
 			}
-			tables[i].UniqueKeys = dedupUniques(uniques)
+		} else {
+			in.warn(tables[i].Name, metaStageUniqueKeys, err)
 		}
+		for _, cols := range uniques {
+			if len(cols) == 1 {
+				for j := range fields {
+					if fields[j].Name == cols[0] {
+						fields[j].IsUnique = true
+					}
+				}
+			}
+		}
+		tables[i].UniqueKeys = dedupUniques(uniques)
 		tables[i].Fields = fields
 		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
+		for _, f := range fields {
+			if isSoftDelField(f.Name) {
+				tables[i].SoftDelKey = f.Name
+				tables[i].SoftDelType = guessSoftDelType(f.Type)
+				break
+			}
+		}
+		autoUpdate := map[string]interface{}{}
+		for _, f := range fields {
+			if isAutoUpdateField(f.Name) && isTimeType(f.Type) {
+				autoUpdate[f.Name] = "{{now}}"
+			}
+		}
+		if len(autoUpdate) > 0 {
+			tables[i].AutoUpdate = autoUpdate
+		}
+		return nil
+	})
+}
+
+// trimSQLServerDefault 去掉 SQL Server 默认值定义外层的括号与 N 前缀，如 ((0)) -> 0, (N'abc') -> 'abc'
+func trimSQLServerDefault(def string) string {
+	def = strings.TrimSpace(def)
+	for len(def) >= 2 && def[0] == '(' && matchingParen(def) == len(def)-1 {
+		def = strings.TrimSpace(def[1 : len(def)-1])
 	}
-	return tables, nil
+	if strings.HasPrefix(def, "N'") {
+		def = def[1:]
+	}
+	return def
+}
+
+// matchingParen 返回与首字符 '(' 配对的右括号位置
+func matchingParen(s string) int {
+	depth := 0
+	for i, ch := range s {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
 }
 
 // ---- ClickHouse ----
-func extractClickHouseMeta(dsn, dbName string) ([]TableMeta, error) {
+func extractClickHouseMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
 	db, err := sql.Open("clickhouse", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open clickhouse database %s failed: %w", dbName, err)
 	}
 	defer db.Close()
-	rows, err := db.Query(`SELECT name FROM system.tables WHERE database=? LIMIT 500`, dbName)
+	rows, err := db.Query(`
+		SELECT name, comment, engine, sorting_key
+		FROM system.tables
+		WHERE database=? AND is_temporary=0 AND engine NOT IN ('View', 'MaterializedView')
+		ORDER BY name
+		LIMIT 500
+	`, dbName)
 	if err != nil {
 		return nil, err
 	}
 	var tables []TableMeta
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+		var name, comment, engine, sortingKey string
+		if err := rows.Scan(&name, &comment, &engine, &sortingKey); err != nil {
 			rows.Close()
 			return nil, err
 		}
-		tables = append(tables, TableMeta{Name: name})
+		if comment == "" && sortingKey != "" {
+			comment = fmt.Sprintf("%s ORDER BY (%s)", engine, sortingKey)
+		}
+		tables = append(tables, TableMeta{Name: name, Comment: comment})
 	}
 	rows.Close()
-	for i := range tables {
-		stmt := fmt.Sprintf("DESCRIBE TABLE `%s`.`%s`", dbName, tables[i].Name)
-		colsRows, err := db.Query(stmt)
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		colsRows, err := db.QueryContext(ctx, `
+			SELECT name, type, default_kind, default_expression, comment, is_in_primary_key
+			FROM system.columns
+			WHERE database=? AND table=?
+			ORDER BY position
+		`, dbName, tables[i].Name)
 		if err != nil {
-			return nil, fmt.Errorf("exec %s: %w", stmt, err)
+			return err
 		}
 		var fields []FieldMeta
+		var keyCols []string
 		for colsRows.Next() {
 			var f FieldMeta
-			var def, comment sql.NullString
-			var dummy1, dummy2 interface{}
-			if err := colsRows.Scan(&f.Name, &f.Type, &def, &dummy1, &comment, &dummy2); err != nil {
+			var defaultKind, defaultExpr string
+			var inPrimaryKey uint8
+			if err := colsRows.Scan(&f.Name, &f.Type, &defaultKind, &defaultExpr, &f.Comment, &inPrimaryKey); err != nil {
 				colsRows.Close()
-				return nil, err
+				return err
 			}
-			if def.Valid {
-				f.Default = convertDefaultByType(def.String, f.Type, f.Nullable)
+			// MATERIALIZED/ALIAS 列不能写入，SELECT * 也不返回
+			if defaultKind == "MATERIALIZED" || defaultKind == "ALIAS" {
+				continue
+			}
+			f.Type, f.Nullable = unwrapClickHouseType(f.Type)
+			f.HasDefault = defaultKind == "DEFAULT" && defaultExpr != ""
+			if f.HasDefault {
+				f.Default = convertDefaultByType(defaultExpr, f.Type, f.Nullable)
 			} else {
 				f.Default = convertDefaultByType("", f.Type, f.Nullable)
 			}
+			if inPrimaryKey == 1 {
+				f.IsPrimary = true
+				keyCols = append(keyCols, f.Name)
+			}
 			fields = append(fields, f)
 		}
 		colsRows.Close()
-		// ClickHouse 无唯一索引，略
+		// ClickHouse 无唯一索引，把主键（默认即 ORDER BY）作为逻辑主键；多列时作为联合唯一
+		if len(keyCols) == 1 {
+			tables[i].PrimaryKey = keyCols[0]
+		} else if len(keyCols) > 1 {
+			tables[i].UniqueKeys = [][]string{keyCols}
+		}
 		tables[i].Fields = fields
 		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
+		for _, f := range fields {
+			if isSoftDelField(f.Name) {
+				tables[i].SoftDelKey = f.Name
+				tables[i].SoftDelType = guessSoftDelType(f.Type)
+				break
+			}
+		}
+		autoUpdate := map[string]interface{}{}
+		for _, f := range fields {
+			if isAutoUpdateField(f.Name) && isTimeType(f.Type) {
+				autoUpdate[f.Name] = "{{now}}"
+			}
+		}
+		if len(autoUpdate) > 0 {
+			tables[i].AutoUpdate = autoUpdate
+		}
+		return nil
+	})
+}
+
+// unwrapClickHouseType 去掉 Nullable(...) 与 LowCardinality(...) 包装，返回基础类型与是否可空
+func unwrapClickHouseType(typ string) (string, bool) {
+	nullable := false
+	for {
+		switch {
+		case strings.HasPrefix(typ, "Nullable(") && strings.HasSuffix(typ, ")"):
+			typ = typ[len("Nullable(") : len(typ)-1]
+			nullable = true
+		case strings.HasPrefix(typ, "LowCardinality(") && strings.HasSuffix(typ, ")"):
+			typ = typ[len("LowCardinality(") : len(typ)-1]
+		default:
+			return typ, nullable
+		}
 	}
-	return tables, nil
 }
 
 // ---- MongoDB ----
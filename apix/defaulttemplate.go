@@ -0,0 +1,114 @@
+package apix
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// --------- 默认值模板 ---------
+//
+// default_values 中除 {{now}}、{{snowflake}} 等固定占位符外，也可以写 Go 模板，按请求体中的其他字段计算默认值：
+//
+//	default_values:
+//	  order_no: '{{concat .region "-" (snowflake)}}'
+//	  credit: '{{if .type == "vip"}}100{{else}}10{{end}}'
+//	  slug: '{{lower .name}}'
+//
+// 模板中 .字段 为请求体字段（物理列名，缺失时为空），可用函数：now、snowflake、ulid、uuidv4、uuidv7、
+// concat、lower、upper、trim、default（default "x" .a 在 .a 为空时取 "x"）、eq、ne（按字符串形式比较），
+// 以及模板自带的 and、or、not 等；if 条件中的 a == b、a != b 会改写为 eq a b、ne a b。
+// 所有默认值都基于原始请求体计算，互不引用；结果为字符串。模板在加载配置时解析，语法错误会导致启动失败。
+
+var defaultTemplateCompareRe = regexp.MustCompile(`\{\{(-?\s*(?:if|else if)\s+)(\S+)\s*(==|!=)\s*(\S+?)(\s*-?)\}\}`)
+
+var defaultTemplateFuncs = template.FuncMap{
+	"now":       func() string { return time.Now().Format(time.RFC3339) },
+	"snowflake": generateSnowflakeID,
+	"ulid":      generateULID,
+	"uuidv4":    generateUUIDv4,
+	"uuidv7":    generateUUIDv7,
+	"concat": func(parts ...interface{}) string {
+		var b strings.Builder
+		for _, p := range parts {
+			if p != nil {
+				b.WriteString(fmt.Sprint(p))
+			}
+		}
+		return b.String()
+	},
+	"lower": func(v interface{}) string { return strings.ToLower(templateString(v)) },
+	"upper": func(v interface{}) string { return strings.ToUpper(templateString(v)) },
+	"trim":  func(v interface{}) string { return strings.TrimSpace(templateString(v)) },
+	"default": func(def interface{}, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	// 请求体中的数字为 float64，按字符串形式比较，避免与模板中的整数常量类型不一致
+	"eq": func(a interface{}, bs ...interface{}) bool {
+		for _, b := range bs {
+			if templateString(a) == templateString(b) {
+				return true
+			}
+		}
+		return false
+	},
+	"ne": func(a, b interface{}) bool { return templateString(a) != templateString(b) },
+}
+
+func templateString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func isFixedDefaultValue(s string) bool {
+	switch s {
+	case defaultValueNow, defaultValueSnowflake, defaultValueULID, defaultValueUUIDv4, defaultValueUUIDv7:
+		return true
+	}
+	return false
+}
+
+// compileDefaultTemplates 解析 default_values 中的模板，固定占位符与普通值不在此列
+func compileDefaultTemplates(defaults map[string]interface{}) (map[string]*template.Template, error) {
+	var out map[string]*template.Template
+	for field, v := range defaults {
+		s, ok := v.(string)
+		if !ok || !strings.Contains(s, "{{") || isFixedDefaultValue(s) {
+			continue
+		}
+		src := defaultTemplateCompareRe.ReplaceAllStringFunc(s, func(m string) string {
+			p := defaultTemplateCompareRe.FindStringSubmatch(m)
+			op := "eq"
+			if p[3] == "!=" {
+				op = "ne"
+			}
+			return "{{" + p[1] + op + " " + p[2] + " " + p[4] + p[5] + "}}"
+		})
+		tpl, err := template.New(field).Funcs(defaultTemplateFuncs).Option("missingkey=zero").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("default_values.%s: %w", field, err)
+		}
+		if out == nil {
+			out = map[string]*template.Template{}
+		}
+		out[field] = tpl
+	}
+	return out, nil
+}
+
+// renderDefaultTemplate 以请求体为数据执行模板
+func renderDefaultTemplate(tpl *template.Template, payload map[string]interface{}) (string, error) {
+	var b strings.Builder
+	if err := tpl.Execute(&b, payload); err != nil {
+		return "", fmt.Errorf("default value for %s: %w", tpl.Name(), err)
+	}
+	// 缺失字段在 map 上输出为 <no value>
+	return strings.ReplaceAll(b.String(), "<no value>", ""), nil
+}
@@ -0,0 +1,107 @@
+package apix
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 字段取值分布 ---------
+//
+//	GET /api/rest/:database/:table/distinct?field=status&created_time__gte=2024-01-01
+//	  => {"field": "status", "data": [{"value": 1, "count": 120}, {"value": 2, "count": 8}], "truncated": false}
+//
+// 返回字段的不同取值及各自的记录数，用于生成过滤下拉框。过滤条件与列表接口相同；
+// order 为 -count（默认，按记录数降序）、count、value 或 -value；limit 默认 1000，最大 10000，
+// 取值超过 limit 时 truncated 为 true。基于分组聚合实现（见 aggregate.go），不支持分组聚合的库返回 501。
+// 隐藏字段不能查询，脱敏字段的取值照常脱敏。
+
+const (
+	distinctParamField = "field"
+	distinctValueKey   = "value"
+
+	defaultDistinctLimit = 1000
+)
+
+func (dm *databaseManager) handleDistinct(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opList) {
+		return
+	}
+	aggregator, ok := adapter.(groupAggregator)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "distinct is not supported by this database type"})
+		return
+	}
+	raw := c.Request.URL.Query()
+	apiField := strings.TrimSpace(raw.Get(distinctParamField))
+	if apiField == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field is required"})
+		return
+	}
+	field := tableConfig.physicalFieldName(apiField)
+	if slices.Contains(tableConfig.HiddenFields, field) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + apiField})
+		return
+	}
+	q := aggregateQuery{
+		GroupBy: []string{field},
+		Metrics: []aggregateMetric{{Func: "count", Name: aggregateCountKey}},
+	}
+	switch order := raw.Get(queryParamOrder); order {
+	case "", "-" + aggregateCountKey:
+		q.Order = []aggregateOrder{{Key: aggregateCountKey, Desc: true}, {Key: field}}
+	case aggregateCountKey:
+		q.Order = []aggregateOrder{{Key: aggregateCountKey}, {Key: field}}
+	case distinctValueKey, "-" + distinctValueKey:
+		q.Order = []aggregateOrder{{Key: field, Desc: order[0] == '-'}}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be count, -count, value or -value"})
+		return
+	}
+	limit := defaultDistinctLimit
+	if v := raw.Get(aggregateParamLimit); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAggregateGroups {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit, expected 1 to %d", maxAggregateGroups)})
+			return
+		}
+	}
+	// 多取一行判断是否截断
+	q.Limit = limit + 1
+	for _, p := range []string{distinctParamField, aggregateParamLimit, queryParamOrder, queryParamFields} {
+		raw.Del(p)
+	}
+	q.Filters = tableConfig.physicalQuery(raw)
+	rows, err := aggregator.Aggregate(c.Request.Context(), tableConfig, q)
+	if err != nil {
+		var unknown *unknownFieldError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(unknown.Field)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query distinct values: " + err.Error()})
+		return
+	}
+	truncated := len(rows) > limit
+	if truncated {
+		rows = rows[:limit]
+	}
+	data := make([]gin.H, len(rows))
+	for i, row := range rows {
+		tableConfig.applyFieldPolicy(row)
+		data[i] = gin.H{distinctValueKey: row[field], aggregateCountKey: row[aggregateCountKey]}
+	}
+	c.JSON(http.StatusOK, gin.H{"field": apiField, "data": data, "truncated": truncated})
+}
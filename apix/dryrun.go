@@ -0,0 +1,63 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- 写操作试运行 ?dry_run=true ---------
+//
+// 创建、更新、删除接口带 dry_run=true 时照常完成校验与默认值填充：
+//   - 支持事务的适配器（SQL）在事务中实际执行后回滚，返回真实的影响结果
+//   - 其它适配器不执行写入，响应中 executed=false
+//
+// 响应统一带 "dry_run": true，创建接口返回 200 而非 201。
+
+// rollbackRunner 为可选能力：在总是回滚的事务中执行写操作
+type rollbackRunner interface {
+	runInRollbackTx(ctx context.Context, fn func(databaseAdapter) error) error
+}
+
+var errDryRunRollback = errors.New("dry run rollback")
+
+func parseDryRun(c *gin.Context) (bool, error) {
+	v := c.Query(queryParamDryRun)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", queryParamDryRun, v)
+	}
+	return b, nil
+}
+
+// runMutation 执行写操作，返回是否实际执行（dry_run 且适配器不支持回滚时不执行）
+func runMutation(ctx context.Context, adapter databaseAdapter, dryRun bool, fn func(databaseAdapter) error) (bool, error) {
+	if !dryRun {
+		return true, fn(adapter)
+	}
+	runner, ok := adapter.(rollbackRunner)
+	if !ok {
+		return false, nil
+	}
+	return true, runner.runInRollbackTx(ctx, fn)
+}
+
+func (a *gormAdapter) runInRollbackTx(ctx context.Context, fn func(databaseAdapter) error) error {
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		if err := fn(&gormAdapter{db: tx, config: a.config}); err != nil {
+			return err
+		}
+		return errDryRunRollback
+	})
+	if errors.Is(err, errDryRunRollback) {
+		return nil
+	}
+	return err
+}
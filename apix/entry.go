@@ -10,39 +10,18 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func RegisterRestfulAndGraphql(router *gin.Engine, cfgs string, port int) {
-	dbCfgDir := filepath.Join(cfgs, "database")
-	tableCfgDir := filepath.Join(cfgs, "table")
-
-	// 解析数据元信息（多库）
-	ExtractDbMeta(cfgs, "/api/rest")
-
-	// 注册 REST API（多库）
-	RegisterRestAPI(router, "/api/rest", cfgs)
-
-	// 注册 Swagger UI（多库）
-	RegisterSwaggerUI(router, "/swagger", cfgs)
-
-	// 注册 Graphql API（多库）
-	entries, err := os.ReadDir(tableCfgDir)
+// RegisterRestfulAndGraphql 挂载 REST、Swagger、GraphQL 与数据浏览页面全部功能，需要按需挂载或管理生命周期时使用 New
+func RegisterRestfulAndGraphql(router *gin.Engine, cfgs string, port int) *Handle {
+	h, err := New(cfgs).
+		WithRest(RestOptions{}).
+		WithSwagger(SwaggerOptions{}).
+		WithAdminUI(AdminUIOptions{}).
+		WithGraphql(GraphqlOptions{RestBaseURL: fmt.Sprintf("http://localhost:%d", port)}).
+		Mount(router)
 	if err != nil {
 		panic(err)
 	}
-	for _, entry := range entries {
-		if entry.IsDir() {
-			dfName := entry.Name()
-			swaggerDir := filepath.Join(tableCfgDir, dfName)
-
-			dbAlias := findAliasByDatabase(dbCfgDir, dfName)
-			graphqlPath := fmt.Sprintf("/api/graphql/%s", dbAlias)
-
-			// 注册 Graphql API
-			RegisterGraphqlAPI(router, graphqlPath, swaggerDir, fmt.Sprintf("http://localhost:%d", port))
-
-			// 注册 GraphiQL
-			RegisterGraphiQL(router, fmt.Sprintf("/graphiql/%s", dbAlias), graphqlPath)
-		}
-	}
+	return h
 }
 
 // 遍历目录并查找匹配的文件
@@ -0,0 +1,718 @@
+package apix
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- 变更事件发布 ---------
+//
+// 把 SQL 表的行变更（CDC 风格，每条记录一个事件）发布到消息中间件，供下游服务订阅：
+//
+//	events:
+//	  enabled: true
+//	  broker: nats                     # nats | redis | kafka
+//	  url: nats://127.0.0.1:4222       # nats://[user:pass@]host:port、redis://[:password@]host:port[/db]，
+//	                                   # kafka 为 Kafka REST Proxy（v2 API）地址，如 http://kafka-rest:8082
+//	  topic: "ego.{database}.{table}"  # NATS subject、Redis stream key 或 Kafka topic
+//	  tables: []                       # 表别名或 库别名.表别名，为空时全部 SQL 表（需要 primary_key）
+//	  redis_max_len: 100000            # Redis stream 近似保留的条数，0 为不限
+//	  queue_size: 10000
+//	  batch_size: 100
+//	  timeout: 5s
+//
+// 写入通过 gorm 回调捕获，与 webhook 相同：覆盖批量创建、更新、删除及 upsert、update_where、delete_where、导入等，
+// 事务提交后入队，试运行与回滚不发布。消息体与 webhook 请求体相同：
+//
+//	{"id", "event": "created|updated|deleted|upserted", "time", "database", "table", "record_id",
+//	 "request_id", "actor", "old", "new"}
+//
+// MongoDB 的写入不经过 gorm 回调，不发布事件：events.tables 列出 MongoDB 的表，或 tables 为空而配置了
+// MongoDB 库时，加载配置即报错，需要在 tables 中显式列出要发布的 SQL 表。
+//
+// 字段按 API 名，hidden_fields 不发送，masked_fields 脱敏后发送。Kafka 消息以 record_id 为 key（同一记录进入同一分区），
+// Redis 以 XADD <topic> * event <json> 写入。后台按 topic 批量发布，失败重试 3 次；队列只在内存中，
+// 队列已满或重试失败的事件记为 dropped/failed 并写日志，进程重启时未发布的事件丢失——需要可靠投递时使用 webhooks。
+//
+// 管理接口（受 operation_roles.events 控制，默认 admin）：
+//
+//	GET /api/admin/events    各表的发布统计与最近的错误
+
+const (
+	changeCreated  = "created"
+	changeUpdated  = "updated"
+	changeDeleted  = "deleted"
+	changeUpserted = "upserted"
+
+	brokerNATS  = "nats"
+	brokerRedis = "redis"
+	brokerKafka = "kafka"
+
+	defaultEventTopic     = "ego.{database}.{table}"
+	defaultEventQueueSize = 10000
+	defaultEventBatchSize = 100
+	defaultEventTimeout   = 5 * time.Second
+	eventPublishAttempts  = 3
+)
+
+type eventsConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Broker      string        `mapstructure:"broker"`
+	URL         string        `mapstructure:"url"`
+	Topic       string        `mapstructure:"topic"`
+	Tables      []string      `mapstructure:"tables"`
+	RedisMaxLen int           `mapstructure:"redis_max_len"`
+	QueueSize   int           `mapstructure:"queue_size"`
+	BatchSize   int           `mapstructure:"batch_size"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+// changeEvent 一条记录的变更，webhook 请求体与发布的消息体
+type changeEvent struct {
+	ID        string                 `json:"id"`
+	Event     string                 `json:"event"`
+	Time      time.Time              `json:"time"`
+	Database  string                 `json:"database"`
+	Table     string                 `json:"table"`
+	RecordID  string                 `json:"record_id"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Actor     string                 `json:"actor,omitempty"`
+	Old       map[string]interface{} `json:"old,omitempty"`
+	New       map[string]interface{} `json:"new,omitempty"`
+}
+
+// changeEvents 把变更记录转为事件，字段改为 API 名
+func changeEvents(tc *tableConfig, entries []auditEntry) []changeEvent {
+	apiNames := func(record map[string]interface{}) map[string]interface{} {
+		if record == nil || len(tc.FieldAliases) == 0 {
+			return record
+		}
+		renamed := make(map[string]interface{}, len(record))
+		for k, v := range record {
+			renamed[tc.apiFieldName(k)] = v
+		}
+		return renamed
+	}
+	events := make([]changeEvent, len(entries))
+	for i, e := range entries {
+		event := changeDeleted
+		switch e.Op {
+		case auditOpCreate:
+			event = changeCreated
+		case auditOpUpsert:
+			event = changeUpserted
+		case auditOpUpdate:
+			event = changeUpdated
+		}
+		events[i] = changeEvent{
+			ID: globalSnowflakeNode.Generate().String(), Event: event, Time: e.Time, Database: e.Database, Table: e.Table,
+			RecordID: e.RecordID, RequestID: e.RequestID, Actor: e.Actor, Old: apiNames(e.Old), New: apiNames(e.New),
+		}
+	}
+	return events
+}
+
+// eventPublisher 把同一 topic 的一批事件发布到消息中间件
+type eventPublisher interface {
+	publish(ctx context.Context, topic string, events []changeEvent) error
+	close() error
+}
+
+type eventStats struct {
+	Database    string `json:"database"`
+	Table       string `json:"table"`
+	Topic       string `json:"topic"`
+	Published   int64  `json:"published"`
+	Failed      int64  `json:"failed"`  // 重试后仍发布失败的事件数
+	Dropped     int64  `json:"dropped"` // 队列已满未能入队的事件数
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt string `json:"last_error_at,omitempty"`
+}
+
+// eventBus 收集提交后的变更事件并在后台发布
+type eventBus struct {
+	config    eventsConfig
+	publisher eventPublisher
+	queue     chan changeEvent
+	mu        sync.Mutex
+	stats     map[string]*eventStats // 库别名.表别名
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// checkEventTables 加载配置时拒绝作用于 MongoDB 表的 events 配置
+func checkEventTables(cfg eventsConfig, databases map[string]databaseConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dbCfg := databases[name]
+		if dbCfg.Type != "mongodb" {
+			continue
+		}
+		if len(cfg.Tables) == 0 {
+			return fmt.Errorf("events: database %s is MongoDB, whose writes are not published; list the SQL tables to publish in events.tables", name)
+		}
+		for _, tc := range dbCfg.Tables {
+			if contains(cfg.Tables, tc.Alias) || contains(cfg.Tables, name+"."+tc.Alias) {
+				return fmt.Errorf("events.tables: %s.%s is a MongoDB table, events are only published for SQL tables", name, tc.Alias)
+			}
+		}
+	}
+	return nil
+}
+
+// setupEvents 校验配置，在发布的表上注册回调并启动后台发布
+func (dm *databaseManager) setupEvents() error {
+	cfg := dm.config.Events
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = defaultEventTopic
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultEventQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultEventBatchSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultEventTimeout
+	}
+	publisher, err := newEventPublisher(cfg)
+	if err != nil {
+		return err
+	}
+	b := &eventBus{config: cfg, publisher: publisher, queue: make(chan changeEvent, cfg.QueueSize), stats: map[string]*eventStats{}}
+	matched := map[string]bool{}
+	for name, dbCfg := range dm.config.Databases {
+		tables := map[string]*auditTable{}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if len(cfg.Tables) > 0 && !contains(cfg.Tables, tc.Alias) && !contains(cfg.Tables, name+"."+tc.Alias) {
+				continue
+			}
+			matched[tc.Alias], matched[name+"."+tc.Alias] = true, true
+			if tc.PrimaryKey == "" {
+				if len(cfg.Tables) > 0 {
+					return fmt.Errorf("table %s.%s: events require primary_key", name, tc.Alias)
+				}
+				continue
+			}
+			tables[tc.Name] = &auditTable{dbName: name, tc: tc}
+			b.stats[name+"."+tc.Alias] = &eventStats{Database: name, Table: tc.Alias, Topic: b.topic(name, tc.Alias)}
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		var dbs []*gorm.DB
+		switch a := dm.adapters[name].(type) {
+		case *gormAdapter:
+			dbs = []*gorm.DB{a.db}
+		case *shardedAdapter:
+			for _, s := range a.shards {
+				dbs = append(dbs, s.db)
+			}
+		default:
+			if len(cfg.Tables) > 0 {
+				return fmt.Errorf("events are only supported on SQL databases, %s is %s", name, dbCfg.Type)
+			}
+			for _, tc := range tables {
+				delete(b.stats, name+"."+tc.tc.Alias)
+			}
+			continue
+		}
+		for _, db := range dbs {
+			err := registerChangeFeed(db, "events", tables, func(tc *tableConfig, entries []auditEntry) {
+				b.enqueue(changeEvents(tc, entries))
+			})
+			if err != nil {
+				return fmt.Errorf("failed to set up events for %s: %w", name, err)
+			}
+		}
+	}
+	for _, t := range cfg.Tables {
+		if !matched[t] {
+			return fmt.Errorf("events.tables: unknown table %q", t)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel, b.done = cancel, make(chan struct{})
+	dm.events = b
+	go b.run(ctx)
+	return nil
+}
+
+func (b *eventBus) topic(dbName, table string) string {
+	return strings.NewReplacer("{database}", dbName, "{table}", table).Replace(b.config.Topic)
+}
+
+func (b *eventBus) enqueue(events []changeEvent) {
+	for _, e := range events {
+		select {
+		case b.queue <- e:
+		default:
+			b.mu.Lock()
+			b.stats[e.Database+"."+e.Table].Dropped++
+			b.mu.Unlock()
+			log.Printf("[events] %s.%s: queue full, event %s %s dropped", e.Database, e.Table, e.Event, e.RecordID)
+		}
+	}
+}
+
+// stop 发布队列中剩余的事件后关闭连接
+func (b *eventBus) stop() {
+	b.cancel()
+	<-b.done
+	b.publisher.close()
+}
+
+func (b *eventBus) run(ctx context.Context) {
+	defer close(b.done)
+	for {
+		var first changeEvent
+		select {
+		case <-ctx.Done():
+			// 退出前尽量发布已入队的事件
+			for len(b.queue) > 0 {
+				b.flush(context.Background(), b.drain(<-b.queue))
+			}
+			return
+		case first = <-b.queue:
+		}
+		b.flush(ctx, b.drain(first))
+	}
+}
+
+// drain 合并队列中已有的事件，按 topic 分组，保持各 topic 内的顺序
+func (b *eventBus) drain(first changeEvent) map[string][]changeEvent {
+	batch := map[string][]changeEvent{}
+	add := func(e changeEvent) {
+		topic := b.topic(e.Database, e.Table)
+		batch[topic] = append(batch[topic], e)
+	}
+	add(first)
+	for i := 1; i < b.config.BatchSize; i++ {
+		select {
+		case e := <-b.queue:
+			add(e)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (b *eventBus) flush(ctx context.Context, batch map[string][]changeEvent) {
+	for topic, events := range batch {
+		var err error
+		for attempt := 1; attempt <= eventPublishAttempts; attempt++ {
+			publishCtx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+			err = b.publisher.publish(publishCtx, topic, events)
+			cancel()
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			if attempt < eventPublishAttempts {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(attempt) * time.Second):
+				}
+			}
+		}
+		b.mu.Lock()
+		for _, e := range events {
+			st := b.stats[e.Database+"."+e.Table]
+			if err != nil {
+				st.Failed++
+				st.LastError, st.LastErrorAt = err.Error(), time.Now().Format(time.RFC3339)
+			} else {
+				st.Published++
+			}
+		}
+		b.mu.Unlock()
+		if err != nil {
+			log.Printf("[events] publish %d event(s) to %s failed: %v", len(events), topic, err)
+		}
+	}
+}
+
+func newEventPublisher(cfg eventsConfig) (eventPublisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid events.url %q", cfg.URL)
+	}
+	switch cfg.Broker {
+	case brokerNATS:
+		if u.Scheme != "nats" {
+			return nil, fmt.Errorf("events.url for nats must be nats://host:port, got %q", cfg.URL)
+		}
+		return &natsPublisher{url: u, timeout: cfg.Timeout}, nil
+	case brokerRedis:
+		if u.Scheme != "redis" {
+			return nil, fmt.Errorf("events.url for redis must be redis://host:port, got %q", cfg.URL)
+		}
+		db := 0
+		if p := strings.Trim(u.Path, "/"); p != "" {
+			if db, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("invalid redis database in events.url %q", cfg.URL)
+			}
+		}
+		return &redisPublisher{url: u, db: db, maxLen: cfg.RedisMaxLen, timeout: cfg.Timeout}, nil
+	case brokerKafka:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("events.url for kafka must be the Kafka REST Proxy http(s) address, got %q", cfg.URL)
+		}
+		return &kafkaRESTPublisher{url: strings.TrimRight(cfg.URL, "/"), client: &http.Client{Timeout: cfg.Timeout}}, nil
+	default:
+		return nil, fmt.Errorf("invalid events.broker %q, expected nats, redis or kafka", cfg.Broker)
+	}
+}
+
+// ---- 连接复用：NATS 与 Redis ----
+
+// brokerConn 保持一个 TCP 连接，出错后关闭，下次发布时重连
+type brokerConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *brokerConn) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+func (c *brokerConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return nil
+}
+
+// use 在连接上执行 fn，需要时先 dial 并 handshake；fn 或 handshake 出错时关闭连接
+func (c *brokerConn) use(ctx context.Context, address string, handshake, fn func(net.Conn, *bufio.Reader) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		c.conn, c.r = conn, bufio.NewReader(conn)
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if err := handshake(c.conn, c.r); err != nil {
+			c.reset()
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	}
+	if err := fn(c.conn, c.r); err != nil {
+		c.reset()
+		return err
+	}
+	return nil
+}
+
+// ---- NATS（文本协议 PUB） ----
+
+type natsPublisher struct {
+	brokerConn
+	url     *url.URL
+	timeout time.Duration
+}
+
+func (p *natsPublisher) handshake(conn net.Conn, r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "ego", "lang": "go", "version": "1", "protocol": 1}
+	if user := p.url.User; user != nil {
+		if pass, ok := user.Password(); ok {
+			opts["user"], opts["pass"] = user.Username(), pass
+		} else {
+			opts["auth_token"] = user.Username()
+		}
+	}
+	data, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", data); err != nil {
+		return err
+	}
+	return p.ping(conn, r)
+}
+
+// ping 发送 PING 并等待 PONG，确认此前的命令已被服务端处理
+func (p *natsPublisher) ping(conn net.Conn, r *bufio.Reader) error {
+	if _, err := io.WriteString(conn, "PING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		}
+	}
+}
+
+func (p *natsPublisher) publish(ctx context.Context, topic string, events []changeEvent) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "PUB %s %d\r\n", topic, len(data))
+		buf.Write(data)
+		buf.WriteString("\r\n")
+	}
+	return p.use(ctx, p.url.Host, p.handshake, func(conn net.Conn, r *bufio.Reader) error {
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		return p.ping(conn, r)
+	})
+}
+
+// ---- Redis Streams（RESP XADD） ----
+
+type redisPublisher struct {
+	brokerConn
+	url     *url.URL
+	db      int
+	maxLen  int
+	timeout time.Duration
+}
+
+func writeRESP(w io.Writer, args ...string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRESP 读取一个回复，错误回复返回 error，数组回复只校验其中的元素
+func readRESP(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("redis: invalid reply %q", line)
+		}
+		if n >= 0 {
+			_, err = io.CopyN(io.Discard, r, int64(n)+2)
+		}
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("redis: invalid reply %q", line)
+		}
+		for i := 0; i < n; i++ {
+			if err := readRESP(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("redis: invalid reply %q", line)
+	}
+}
+
+func (p *redisPublisher) handshake(conn net.Conn, r *bufio.Reader) error {
+	if user := p.url.User; user != nil {
+		args := []string{"AUTH"}
+		if pass, ok := user.Password(); ok {
+			if user.Username() != "" {
+				args = append(args, user.Username())
+			}
+			args = append(args, pass)
+		} else {
+			args = append(args, user.Username())
+		}
+		if err := writeRESP(conn, args...); err != nil {
+			return err
+		}
+		if err := readRESP(r); err != nil {
+			return err
+		}
+	}
+	if p.db > 0 {
+		if err := writeRESP(conn, "SELECT", strconv.Itoa(p.db)); err != nil {
+			return err
+		}
+		return readRESP(r)
+	}
+	return nil
+}
+
+func (p *redisPublisher) publish(ctx context.Context, topic string, events []changeEvent) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		args := []string{"XADD", topic}
+		if p.maxLen > 0 {
+			args = append(args, "MAXLEN", "~", strconv.Itoa(p.maxLen))
+		}
+		writeRESP(&buf, append(args, "*", "event", string(data))...)
+	}
+	return p.use(ctx, p.url.Host, p.handshake, func(conn net.Conn, r *bufio.Reader) error {
+		// 流水线写入，逐条读取回复
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		var firstErr error
+		for range events {
+			if err := readRESP(r); err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) || errors.Is(err, io.EOF) {
+					return err
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return firstErr
+	})
+}
+
+// ---- Kafka（REST Proxy v2） ----
+
+type kafkaRESTPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func (p *kafkaRESTPublisher) publish(ctx context.Context, topic string, events []changeEvent) error {
+	type record struct {
+		Key   string      `json:"key"`
+		Value changeEvent `json:"value"`
+	}
+	records := make([]record, len(events))
+	for i, e := range events {
+		records[i] = record{Key: e.RecordID, Value: e}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy responded %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &result); err == nil {
+		for _, o := range result.Offsets {
+			if o.ErrorCode != nil {
+				return fmt.Errorf("kafka: %s (error_code %d)", o.Error, *o.ErrorCode)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *kafkaRESTPublisher) close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleEventStats(c *gin.Context) {
+	if !dm.authorize(c, nil, opEvents) {
+		return
+	}
+	if dm.events == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event publishing is not enabled"})
+		return
+	}
+	b := dm.events
+	stats := []eventStats{}
+	b.mu.Lock()
+	for _, st := range b.stats {
+		stats = append(stats, *st)
+	}
+	b.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Database+"."+stats[i].Table < stats[j].Database+"."+stats[j].Table
+	})
+	c.JSON(http.StatusOK, gin.H{"broker": b.config.Broker, "queued": len(b.queue), "tables": stats})
+}
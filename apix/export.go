@@ -0,0 +1,378 @@
+package apix
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 流式导出 ---------
+//
+//	GET /api/rest/:database/:table/export?format=csv&status=1&order=-id
+//	GET /api/rest/:database/:table/export?format=ndjson&fields=id,name
+//	GET /api/rest/:database/:table/export?format=xlsx&chunk_size=5000
+//
+// 导出全部匹配的记录（不分页），过滤条件、fields、order 与列表接口相同；format 为 csv（默认）、ndjson 或 xlsx，
+// CSV 的分隔符由 delimiter 指定。按 chunk_size（默认 1000，最大 10000）分块查询并逐块写出响应，
+// 内存中只保留一块：支持游标分页的库（SQL、MongoDB）未指定 order 或按游标列排序时按游标翻页，其余按 page 翻页。
+// 列顺序为 fields 的顺序，未指定时按表结构，取值与列表接口一样经过字段别名、脱敏、时间格式等处理。
+//
+// 需要表的 list 与 export 权限（operation_roles.export，未配置时不限制）。开始写出后查询失败时响应已是 200，
+// 导出中止并在 HTTP trailer X-Export-Error 中给出原因；xlsx 超过工作表的 1048576 行上限时同样中止。
+
+const (
+	exportFormatCSV    = importFormatCSV
+	exportFormatNDJSON = importFormatNDJSON
+	exportFormatXLSX   = "xlsx"
+
+	exportParamFormat    = "format"
+	exportParamDelimiter = "delimiter"
+	exportErrorTrailer   = "X-Export-Error"
+
+	defaultExportChunkSize = 1000
+	maxExportChunkSize     = 10000
+)
+
+// exportWriter 一种导出格式：先写表头（API 字段名），再逐条写记录，Close 写出格式的结尾
+type exportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRecord(record map[string]interface{}) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, comma rune, sheet string) exportWriter {
+	switch format {
+	case exportFormatNDJSON:
+		return &ndjsonExportWriter{enc: json.NewEncoder(w)}
+	case exportFormatXLSX:
+		return newXLSXExportWriter(w, sheet)
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvExportWriter{w: cw}
+}
+
+func exportContentType(format string) string {
+	switch format {
+	case exportFormatNDJSON:
+		return "application/x-ndjson"
+	case exportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ---- CSV ----
+
+type csvExportWriter struct {
+	w       *csv.Writer
+	columns []string
+	row     []string
+}
+
+func (w *csvExportWriter) WriteHeader(columns []string) error {
+	w.columns, w.row = columns, make([]string, len(columns))
+	return w.w.Write(columns)
+}
+
+func (w *csvExportWriter) WriteRecord(record map[string]interface{}) error {
+	for i, col := range w.columns {
+		w.row[i] = exportCellString(record[col])
+	}
+	return w.w.Write(w.row)
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvExportWriter) Close() error { return w.Flush() }
+
+// ---- NDJSON ----
+
+type ndjsonExportWriter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (w *ndjsonExportWriter) WriteHeader(columns []string) error {
+	w.columns = columns
+	return nil
+}
+
+// WriteRecord 只输出表头中的字段，保持与 CSV 相同的字段集合
+func (w *ndjsonExportWriter) WriteRecord(record map[string]interface{}) error {
+	row := make(map[string]interface{}, len(w.columns))
+	for _, col := range w.columns {
+		row[col] = record[col]
+	}
+	return w.enc.Encode(row)
+}
+
+func (w *ndjsonExportWriter) Flush() error { return nil }
+func (w *ndjsonExportWriter) Close() error { return nil }
+
+// exportCellString 单元格文本：nil 为空，时间为 RFC 3339，对象与数组为 JSON
+func exportCellString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(val)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s
+	}
+	return string(b)
+}
+
+// ---- 分块查询 ----
+
+// exportPager 逐块读取全部匹配的记录
+type exportPager struct {
+	adapter databaseAdapter
+	tc      *tableConfig
+	params  listParams
+	added   []string // 为游标翻页补充查询、写出前去掉的列
+	done    bool
+}
+
+func newExportPager(adapter databaseAdapter, tc *tableConfig, query url.Values, chunkSize int) *exportPager {
+	p := &exportPager{adapter: adapter, tc: tc, params: listParams{
+		Page:         1,
+		PageSize:     chunkSize,
+		Fields:       query.Get(queryParamFields),
+		Order:        query.Get(queryParamOrder),
+		QueryFilters: query,
+		SkipCount:    true,
+	}}
+	if _, ok := adapter.(keysetLister); ok {
+		keyset, err := parseKeyset(tc, url.Values{queryParamCursor: {""}, queryParamOrder: {p.params.Order}})
+		if err == nil {
+			p.params.Order, p.params.Keyset = "", keyset
+			p.params.Fields, p.added = keyset.withColumns(p.params.Fields)
+			return p
+		}
+	}
+	if p.params.Order == "" && tc.PrimaryKey != "" {
+		// 按 page 翻页时需要稳定的顺序
+		p.params.Order = tc.PrimaryKey
+	}
+	return p
+}
+
+// next 返回下一块记录（物理列名），没有更多记录时返回 nil
+func (p *exportPager) next(ctx context.Context) ([]map[string]interface{}, error) {
+	if p.done {
+		return nil, nil
+	}
+	data, _, err := p.adapter.List(ctx, p.tc, p.params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < p.params.PageSize {
+		p.done = true
+	}
+	if k := p.params.Keyset; k != nil {
+		if len(data) > 0 {
+			last := data[len(data)-1]
+			after := make([]interface{}, len(k.Columns))
+			for i, col := range k.Columns {
+				after[i] = last[col]
+			}
+			p.params.Keyset = &keysetPage{Columns: k.Columns, Desc: k.Desc, After: after}
+		}
+		for _, rec := range data {
+			for _, col := range p.added {
+				delete(rec, col)
+			}
+		}
+	} else {
+		p.params.Page++
+	}
+	return data, nil
+}
+
+// exportColumns 导出的列（API 字段名）：fields 的顺序，未指定时按表结构，再补上首块记录中的其他字段
+func (dm *databaseManager) exportColumns(dbName string, tc *tableConfig, fields string, first []map[string]interface{}) []string {
+	var physical []string
+	if fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				physical = append(physical, f)
+			}
+		}
+	} else {
+		dm.mutex.RLock()
+		dbCfg, ok := dm.config.Databases[dbName]
+		dm.mutex.RUnlock()
+		if ok {
+			if metas, err := dm.tableMetas(dbName, dbCfg); err == nil {
+				if meta := findTableMeta(metas, tc.Name); meta != nil {
+					for _, f := range meta.Fields {
+						physical = append(physical, f.Name)
+					}
+				}
+			}
+		}
+	}
+	columns := make([]string, 0, len(physical))
+	seen := map[string]bool{}
+	for _, f := range physical {
+		if slices.Contains(tc.HiddenFields, f) || seen[tc.apiFieldName(f)] {
+			continue
+		}
+		seen[tc.apiFieldName(f)] = true
+		columns = append(columns, tc.apiFieldName(f))
+	}
+	if fields != "" {
+		return columns
+	}
+	var extra []string
+	for _, rec := range first {
+		for k := range rec {
+			if !seen[k] {
+				seen[k] = true
+				extra = append(extra, k)
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
+// ---- Handler ----
+
+func (dm *databaseManager) handleExport(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opList) || !dm.authorize(c, tableConfig, opExport) {
+		return
+	}
+	raw := c.Request.URL.Query()
+	format := strings.ToLower(raw.Get(exportParamFormat))
+	switch format {
+	case "":
+		format = exportFormatCSV
+	case exportFormatCSV, exportFormatXLSX:
+	case exportFormatNDJSON, "jsonl":
+		format = exportFormatNDJSON
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid format: %s, expected csv, ndjson or xlsx", format)})
+		return
+	}
+	comma := ','
+	if d := raw.Get(exportParamDelimiter); d != "" {
+		if d == `\t` {
+			d = "\t"
+		}
+		r := []rune(d)
+		if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "delimiter must be a single character"})
+			return
+		}
+		comma = r[0]
+	}
+	chunkSize := defaultExportChunkSize
+	if v := raw.Get(queryParamChunkSize); v != "" {
+		chunkSize, err = strconv.Atoi(v)
+		if err != nil || chunkSize < 1 || chunkSize > maxExportChunkSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid chunk_size, expected 1 to %d", maxExportChunkSize)})
+			return
+		}
+	}
+	for _, p := range []string{exportParamFormat, exportParamDelimiter, queryParamChunkSize, queryParamCursor, queryParamPage, queryParamPageSize} {
+		raw.Del(p)
+	}
+	query := tableConfig.physicalQuery(raw)
+	if err := tableConfig.checkSortFields(query.Get(queryParamOrder)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := query[queryParamOr]; ok {
+		if _, ok := adapter.(orFilterer); !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "OR conditions are not supported by this database type"})
+			return
+		}
+	}
+
+	// 首块在写出响应前查询，过滤条件等错误仍按普通错误返回
+	ctx := c.Request.Context()
+	pager := newExportPager(adapter, tableConfig, query, chunkSize)
+	data, err := pager.next(ctx)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	render := func(data []map[string]interface{}) []map[string]interface{} {
+		data = fixPkFieldToString(data, tableConfig.PrimaryKey).([]map[string]interface{})
+		return tableConfig.renderRecords(data)
+	}
+	data = render(data)
+	columns := dm.exportColumns(dbName, tableConfig, query.Get(queryParamFields), data)
+
+	c.Header("Content-Type", exportContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, tableAlias, format))
+	c.Header("Trailer", exportErrorTrailer)
+	c.Status(http.StatusOK)
+	w := newExportWriter(format, c.Writer, comma, tableAlias)
+	var rows int64
+	err = w.WriteHeader(columns)
+	for err == nil && len(data) > 0 {
+		for _, rec := range data {
+			if err = w.WriteRecord(rec); err != nil {
+				break
+			}
+			rows++
+		}
+		if err == nil {
+			if err = w.Flush(); err == nil {
+				c.Writer.Flush()
+				if data, err = pager.next(ctx); err == nil {
+					data = render(data)
+				}
+			}
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("[Export] %s.%s: aborted after %d rows: %v", dbName, tableAlias, rows, err)
+		c.Writer.Header().Set(exportErrorTrailer, err.Error())
+	}
+}
@@ -0,0 +1,201 @@
+package apix
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --------- xlsx 导出 ---------
+//
+// 只写一个工作表的最小 xlsx（zip 内的 SpreadsheetML），不依赖第三方库：工作簿等固定部分先写出，
+// 工作表逐行写入 zip 条目，不缓存整张表。数字与布尔值写为对应类型的单元格，其余为内联字符串。
+
+const (
+	xlsxMaxRows      = 1048576
+	xlsxMaxCellChars = 32767
+	xlsxMaxSheetName = 31
+)
+
+var errXLSXRowLimit = errors.New("xlsx row limit (1048576) exceeded")
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+type xlsxExportWriter struct {
+	zw      *zip.Writer
+	sheet   *bufio.Writer
+	name    string
+	columns []string
+	refs    []string // 列字母 A、B、…、AA
+	rows    int
+}
+
+func newXLSXExportWriter(w io.Writer, sheetName string) *xlsxExportWriter {
+	return &xlsxExportWriter{zw: zip.NewWriter(w), name: xlsxSheetName(sheetName)}
+}
+
+// xlsxSheetName 工作表名最长 31 个字符，不能包含 []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > xlsxMaxSheetName {
+		name = string(r[:xlsxMaxSheetName])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+func (w *xlsxExportWriter) WriteHeader(columns []string) error {
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xlsxEscape(w.name))},
+	}
+	for _, p := range parts {
+		f, err := w.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+	f, err := w.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	w.sheet = bufio.NewWriter(f)
+	w.sheet.WriteString(xlsxSheetStart)
+	w.columns = columns
+	w.refs = make([]string, len(columns))
+	for i := range columns {
+		w.refs[i] = xlsxColumnRef(i)
+	}
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = col
+	}
+	return w.writeRow(values)
+}
+
+func (w *xlsxExportWriter) WriteRecord(record map[string]interface{}) error {
+	values := make([]interface{}, len(w.columns))
+	for i, col := range w.columns {
+		values[i] = record[col]
+	}
+	return w.writeRow(values)
+}
+
+func (w *xlsxExportWriter) writeRow(values []interface{}) error {
+	if w.rows >= xlsxMaxRows {
+		return errXLSXRowLimit
+	}
+	w.rows++
+	row := strconv.Itoa(w.rows)
+	b := w.sheet
+	b.WriteString(`<row r="` + row + `">`)
+	for i, v := range values {
+		ref := w.refs[i] + row
+		switch val := v.(type) {
+		case nil:
+			continue
+		case bool:
+			n := "0"
+			if val {
+				n = "1"
+			}
+			b.WriteString(`<c r="` + ref + `" t="b"><v>` + n + `</v></c>`)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+			b.WriteString(`<c r="` + ref + `"><v>` + exportCellString(val) + `</v></c>`)
+		default:
+			s := exportCellString(val)
+			if utf8.RuneCountInString(s) > xlsxMaxCellChars {
+				s = string([]rune(s)[:xlsxMaxCellChars])
+			}
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xlsxEscape(s) + `</t></is></c>`)
+		}
+	}
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+func (w *xlsxExportWriter) Flush() error {
+	if w.sheet == nil {
+		return nil
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Flush()
+}
+
+func (w *xlsxExportWriter) Close() error {
+	if w.sheet != nil {
+		w.sheet.WriteString(xlsxSheetEnd)
+		if err := w.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.zw.Close()
+}
+
+// xlsxColumnRef 列下标转列字母：0 => A，26 => AA
+func xlsxColumnRef(i int) string {
+	ref := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		ref = string(rune('A'+(i-1)%26)) + ref
+	}
+	return ref
+}
+
+// xlsxEscape 转义 XML 特殊字符，去掉 XML 不允许的控制字符
+func xlsxEscape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
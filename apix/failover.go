@@ -0,0 +1,309 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/clickhouse"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// --------- 故障转移 DSN 列表 ---------
+//
+// dsn 可以配置为列表，第一个为主库，其余按顺序作为备库：
+//
+//	dsn:
+//	  - "root:pass@tcp(db-primary:3306)/shop"
+//	  - "root:pass@tcp(db-replica:3306)/shop"
+//	failover:
+//	  check_interval: 10s   # 已切到备库时探测主库的间隔，主库恢复后自动切回
+//
+// 连接类错误时切换到下一个可用 DSN 并重试一次；当前生效的目标在 stats 接口的 active_dsn 中返回。
+// 仅对基于 gorm 的 SQL 类型生效；mongodb 由驱动根据 URI 自行完成副本集切换。
+
+const defaultFailoverCheckInterval = 10 * time.Second
+
+type failoverConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// failoverTarget 由配置了 DSN 列表的适配器实现，返回当前生效的目标
+type failoverTarget interface {
+	activeTarget() (dsn string, index int, ok bool)
+}
+
+func (a *gormAdapter) activeTarget() (string, int, bool) {
+	pool, ok := a.db.ConnPool.(*failoverPool)
+	if !ok {
+		return "", 0, false
+	}
+	dsn, idx := pool.activeDSN()
+	return dsn, idx, true
+}
+
+// failoverPool 实现 gorm.ConnPool，把请求转发到当前生效的 sql.DB
+type failoverPool struct {
+	dsns   []string
+	dbs    []*sql.DB
+	mu     sync.RWMutex
+	active int
+	stop   chan struct{}
+}
+
+func newFailoverPool(driverName string, dsns []string, interval time.Duration) (*failoverPool, error) {
+	p := &failoverPool{dsns: dsns, stop: make(chan struct{})}
+	for _, dsn := range dsns {
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			p.closeAll()
+			return nil, err
+		}
+		p.dbs = append(p.dbs, db)
+	}
+	if interval <= 0 {
+		interval = defaultFailoverCheckInterval
+	}
+	go p.watchPrimary(interval)
+	return p, nil
+}
+
+// failoverDialector 把 dialector 的连接替换为故障转移连接池
+func failoverDialector(dbConfig databaseConfig, dialector gorm.Dialector) (gorm.Dialector, error) {
+	dsns := dbConfig.DSNs
+	if strings.ToLower(dbConfig.Type) == "tidb" {
+		dsns = make([]string, len(dbConfig.DSNs))
+		for i, dsn := range dbConfig.DSNs {
+			d, err := tidbDSN(dsn, dbConfig.TiDB)
+			if err != nil {
+				return nil, err
+			}
+			dsns[i] = d
+		}
+	}
+	interval := dbConfig.Failover.CheckInterval
+	switch d := dialector.(type) {
+	case *mysql.Dialector:
+		pool, err := newFailoverPool("mysql", dsns, interval)
+		d.Config.Conn = pool
+		return d, err
+	case *postgres.Dialector:
+		pool, err := newFailoverPool("pgx", dsns, interval)
+		d.Config.Conn = pool
+		return d, err
+	case *sqlserver.Dialector:
+		pool, err := newFailoverPool("sqlserver", dsns, interval)
+		d.Config.Conn = pool
+		return d, err
+	case *clickhouse.Dialector:
+		pool, err := newFailoverPool("clickhouse", dsns, interval)
+		d.Config.Conn = pool
+		return d, err
+	case *sqlite.Dialector:
+		pool, err := newFailoverPool(sqlite.DriverName, dsns, interval)
+		d.Conn = pool
+		return d, err
+	}
+	return nil, fmt.Errorf("dsn list is not supported for %s", dbConfig.Type)
+}
+
+func (p *failoverPool) current() (int, *sql.DB) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.active, p.dbs[p.active]
+}
+
+// activeDSN 返回当前生效的 DSN（已脱敏）及其序号
+func (p *failoverPool) activeDSN() (string, int) {
+	idx, _ := p.current()
+	return redactDSN(p.dsns[idx]), idx
+}
+
+// failover 从 from 切换到下一个能 ping 通的 DSN，返回是否已切换（包括被其它请求先行切换）
+func (p *failoverPool) failover(from int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active != from {
+		return true
+	}
+	for step := 1; step < len(p.dbs); step++ {
+		i := (from + step) % len(p.dbs)
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := p.dbs[i].PingContext(ctx)
+		cancel()
+		if err == nil {
+			log.Printf("failover: switched from %s to %s", redactDSN(p.dsns[from]), redactDSN(p.dsns[i]))
+			p.active = i
+			return true
+		}
+	}
+	return false
+}
+
+// watchPrimary 已切到备库时定期探测主库，恢复后切回
+func (p *failoverPool) watchPrimary(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		if idx, _ := p.current(); idx == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := p.dbs[0].PingContext(ctx)
+		cancel()
+		if err == nil {
+			p.mu.Lock()
+			log.Printf("failover: primary %s is healthy again, switching back", redactDSN(p.dsns[0]))
+			p.active = 0
+			p.mu.Unlock()
+		}
+	}
+}
+
+// isConnError 判断是否为连接层面的错误（SQL 执行错误不触发切换）
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection refused", "connection reset", "broken pipe", "no such host", "unable to open database", "server closed"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// withFailover 执行 fn，遇到连接错误时切换后重试一次
+func withFailover[T any](p *failoverPool, fn func(db *sql.DB) (T, error)) (T, error) {
+	idx, db := p.current()
+	res, err := fn(db)
+	if isConnError(err) && p.failover(idx) {
+		_, db = p.current()
+		return fn(db)
+	}
+	return res, err
+}
+
+func (p *failoverPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return withFailover(p, func(db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, query)
+	})
+}
+
+func (p *failoverPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return withFailover(p, func(db *sql.DB) (sql.Result, error) {
+		return db.ExecContext(ctx, query, args...)
+	})
+}
+
+func (p *failoverPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return withFailover(p, func(db *sql.DB) (*sql.Rows, error) {
+		return db.QueryContext(ctx, query, args...)
+	})
+}
+
+func (p *failoverPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row, _ := withFailover(p, func(db *sql.DB) (*sql.Row, error) {
+		row := db.QueryRowContext(ctx, query, args...)
+		return row, row.Err()
+	})
+	return row
+}
+
+func (p *failoverPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return withFailover(p, func(db *sql.DB) (*sql.Tx, error) {
+		return db.BeginTx(ctx, opts)
+	})
+}
+
+func (p *failoverPool) Ping() error {
+	_, err := withFailover(p, func(db *sql.DB) (struct{}, error) {
+		return struct{}{}, db.Ping()
+	})
+	return err
+}
+
+// GetDBConn 供 gorm.DB.DB() 使用，返回当前生效的连接
+func (p *failoverPool) GetDBConn() (*sql.DB, error) {
+	_, db := p.current()
+	return db, nil
+}
+
+// setPool 连接池参数应用到所有 DSN
+func (p *failoverPool) setPool(cfg poolConfig) {
+	for _, db := range p.dbs {
+		if cfg.MaxOpenConns > 0 {
+			db.SetMaxOpenConns(cfg.MaxOpenConns)
+		}
+		if cfg.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(cfg.MaxIdleConns)
+		}
+		if cfg.ConnMaxLifetime > 0 {
+			db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		}
+		if cfg.ConnMaxIdleTime > 0 {
+			db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+		}
+	}
+}
+
+func (p *failoverPool) closeAll() error {
+	var errs []error
+	for _, db := range p.dbs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *failoverPool) Close() error {
+	close(p.stop)
+	return p.closeAll()
+}
+
+// ---- DSN 脱敏 ----
+
+var (
+	dsnUserPassRe = regexp.MustCompile(`^([^:@/]+):[^@]*@`)
+	dsnKVPassRe   = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|[^\s;]*)`)
+)
+
+// redactDSN 隐藏 DSN 中的密码，兼容 URL、user:pass@tcp(...) 与 key=value 形式
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	dsn = dsnUserPassRe.ReplaceAllString(dsn, "$1:xxxxx@")
+	return dsnKVPassRe.ReplaceAllString(dsn, "${1}xxxxx")
+}
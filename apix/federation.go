@@ -0,0 +1,459 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// --------- 远端 ego 联邦 Adapter 实现 ---------
+//
+// 把另一个 ego 实例的数据库作为本地数据库提供，所有操作转发到远端 REST API，
+// 用于一个网关实例聚合多个区域部署：
+//
+//	type: ego
+//	dsn: "https://eu.example.com/api/rest/shop"   # 远端 REST 前缀 + 远端数据库别名
+//	ego:
+//	  token: "xxx"                 # 固定凭据（Authorization: Bearer），请求中没有可转发的 Authorization 时使用
+//	  forward_headers: [Authorization, X-User, X-Roles]  # 转发调用者的请求头，默认 Authorization 与 auth 中配置的身份头
+//	  timeout: 30s
+//
+// 表名为远端表别名，表结构取自远端 /swagger/{alias}/swagger.yaml。默认值、自动更新、
+// 软删除等由远端处理，本地生成的表配置不包含这些项。
+
+const defaultEgoTimeout = 30 * time.Second
+
+type egoConfig struct {
+	Token          string        `mapstructure:"token"`
+	ForwardHeaders []string      `mapstructure:"forward_headers"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+}
+
+type incomingHeaderKey struct{}
+
+// withIncomingHeader 把调用者的请求头放入 context，供远端 adapter 转发
+func withIncomingHeader(c *gin.Context) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), incomingHeaderKey{}, c.Request.Header))
+	c.Next()
+}
+
+// egoRemoteError 为远端返回的非 2xx 响应
+type egoRemoteError struct {
+	Status  int
+	Message string
+}
+
+func (e *egoRemoteError) Error() string {
+	return fmt.Sprintf("remote ego returned %d: %s", e.Status, e.Message)
+}
+
+type egoAdapter struct {
+	baseURL        string
+	token          string
+	forwardHeaders []string
+	client         *http.Client
+	dryRun         bool
+}
+
+func newEgoAdapter(cfg *databaseConfig, auth authConfig) (*egoAdapter, error) {
+	u, err := url.Parse(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("expected http(s)://host/api/rest/{database}")
+	}
+	headers := cfg.Ego.ForwardHeaders
+	if len(headers) == 0 {
+		headers = []string{"Authorization"}
+		for _, h := range []string{auth.ActorHeader, auth.RolesHeader} {
+			if h != "" {
+				headers = append(headers, h)
+			}
+		}
+	}
+	timeout := cfg.Ego.Timeout
+	if timeout <= 0 {
+		timeout = defaultEgoTimeout
+	}
+	return &egoAdapter{
+		baseURL:        strings.TrimRight(cfg.DSN, "/"),
+		token:          cfg.Ego.Token,
+		forwardHeaders: headers,
+		client:         &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (a *egoAdapter) do(ctx context.Context, method, p string, query url.Values, payload interface{}, out interface{}) error {
+	if a.dryRun {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set(queryParamDryRun, "true")
+	}
+	target := a.baseURL + p
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if incoming, ok := ctx.Value(incomingHeaderKey{}).(http.Header); ok {
+		for _, h := range a.forwardHeaders {
+			if v := incoming.Get(h); v != "" {
+				req.Header.Set(h, v)
+			}
+		}
+	}
+	if req.Header.Get("Authorization") == "" && a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errBody) == nil && errBody.Error != "" {
+			msg = errBody.Error
+		}
+		if resp.StatusCode == http.StatusNotFound && method != http.MethodPost {
+			return fmt.Errorf("%w: %s", gorm.ErrRecordNotFound, msg)
+		}
+		if resp.StatusCode == http.StatusUnprocessableEntity {
+			var tooMany struct {
+				Matched int64 `json:"matched_count"`
+				Max     int64 `json:"max_affected"`
+			}
+			if json.Unmarshal(data, &tooMany) == nil && tooMany.Max > 0 {
+				return &tooManyAffectedError{Matched: tooMany.Matched, Max: tooMany.Max}
+			}
+		}
+		return &egoRemoteError{Status: resp.StatusCode, Message: msg}
+	}
+	if out == nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// 保留整数精度
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+func tablePath(tc *tableConfig, parts ...string) string {
+	p := "/" + url.PathEscape(tc.Name)
+	for _, part := range parts {
+		p += "/" + url.PathEscape(part)
+	}
+	return p
+}
+
+// recordPath 把单条记录的过滤条件转换为远端路径：主键用 /{id}，唯一键用 /{v1,v2}?key=k1,k2
+func recordPath(tc *tableConfig, filter map[string]interface{}) (string, url.Values, error) {
+	if len(filter) == 0 {
+		return "", nil, fmt.Errorf("empty record filter")
+	}
+	if v, ok := filter[tc.PrimaryKey]; ok && len(filter) == 1 {
+		return tablePath(tc, fmt.Sprint(v)), url.Values{}, nil
+	}
+	keys := make([]string, 0, len(filter))
+	for k := range filter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vals := make([]string, len(keys))
+	for i, k := range keys {
+		vals[i] = fmt.Sprint(filter[k])
+	}
+	return tablePath(tc, strings.Join(vals, ",")), url.Values{queryParamKey: {strings.Join(keys, ",")}}, nil
+}
+
+// remoteFilterQuery 过滤条件以 filter[...] 形式转发，远端表开启 strict_filters 时同样可用
+func remoteFilterQuery(filters url.Values) url.Values {
+	query := url.Values{}
+	for k, v := range filters {
+		if isReservedQueryParam(k) || k == queryParamOr {
+			query[k] = v
+		} else {
+			query[filterParamPrefix+k+"]"] = v
+		}
+	}
+	return query
+}
+
+func (a *egoAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	query := remoteFilterQuery(params.QueryFilters)
+	query.Set(queryParamPage, strconv.Itoa(params.Page))
+	query.Set(queryParamPageSize, strconv.Itoa(params.PageSize))
+	var resp struct {
+		Total json.Number              `json:"total"`
+		Data  []map[string]interface{} `json:"data"`
+	}
+	if err := a.do(ctx, http.MethodGet, tablePath(tc), query, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+	total, _ := resp.Total.Int64()
+	return resp.Data, total, nil
+}
+
+func (a *egoAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
+	if a.dryRun {
+		var resp struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		err := a.do(ctx, http.MethodPost, tablePath(tc), nil, records, &resp)
+		return nil, resp.Data, err
+	}
+	var created []map[string]interface{}
+	if err := a.do(ctx, http.MethodPost, tablePath(tc), nil, records, &created); err != nil {
+		return nil, nil, err
+	}
+	return nil, created, nil
+}
+
+type egoMutationResult struct {
+	MatchedCount  int64 `json:"matched_count"`
+	ModifiedCount int64 `json:"modified_count"`
+	DeletedCount  int64 `json:"deleted_count"`
+}
+
+func (a *egoAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
+	var resp egoMutationResult
+	err := a.do(ctx, http.MethodPut, tablePath(tc), nil, records, &resp)
+	return resp.MatchedCount, resp.ModifiedCount, err
+}
+
+func (a *egoAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
+	var resp egoMutationResult
+	err := a.do(ctx, http.MethodPost, tablePath(tc, "batch_delete"), nil, ids, &resp)
+	return resp.DeletedCount, err
+}
+
+func (a *egoAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
+	p, query, err := recordPath(tc, filter)
+	if err != nil {
+		return nil, err
+	}
+	if fields != "" {
+		query.Set(queryParamFields, fields)
+	}
+	var record map[string]interface{}
+	if err := a.do(ctx, http.MethodGet, p, query, nil, &record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (a *egoAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
+	p, query, err := recordPath(tc, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	var resp egoMutationResult
+	err = a.do(ctx, http.MethodPut, p, query, data, &resp)
+	return resp.MatchedCount, resp.ModifiedCount, err
+}
+
+func (a *egoAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
+	p, query, err := recordPath(tc, filter)
+	if err != nil {
+		return 0, err
+	}
+	var resp egoMutationResult
+	err = a.do(ctx, http.MethodDelete, p, query, nil, &resp)
+	return resp.DeletedCount, err
+}
+
+func (a *egoAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
+	_, total, err := a.List(ctx, tc, listParams{Page: 1, PageSize: 1, QueryFilters: url.Values{queryParamFields: {tc.PrimaryKey}}})
+	return total, err
+}
+
+func (a *egoAdapter) Close() error {
+	a.client.CloseIdleConnections()
+	return nil
+}
+
+// runInRollbackTx 试运行交给远端执行：请求带上 dry_run=true
+func (a *egoAdapter) runInRollbackTx(ctx context.Context, fn func(databaseAdapter) error) error {
+	dry := *a
+	dry.dryRun = true
+	return fn(&dry)
+}
+
+func (a *egoAdapter) mutateWhere(ctx context.Context, p string, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (egoMutationResult, error) {
+	query := remoteFilterQuery(filters)
+	if opts.MaxAffected > 0 {
+		query.Set(queryParamMaxAffected, strconv.FormatInt(opts.MaxAffected, 10))
+	}
+	if opts.DryRun {
+		query.Set(queryParamDryRun, "true")
+	}
+	var payload interface{}
+	if data != nil {
+		payload = data
+	}
+	var resp egoMutationResult
+	err := a.do(ctx, http.MethodPost, p, query, payload, &resp)
+	return resp, err
+}
+
+func (a *egoAdapter) UpdateWhere(ctx context.Context, tc *tableConfig, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (int64, int64, error) {
+	resp, err := a.mutateWhere(ctx, tablePath(tc, "update_where"), filters, data, opts)
+	return resp.MatchedCount, resp.ModifiedCount, err
+}
+
+func (a *egoAdapter) DeleteWhere(ctx context.Context, tc *tableConfig, filters url.Values, opts mutateWhereOptions) (int64, int64, error) {
+	resp, err := a.mutateWhere(ctx, tablePath(tc, "delete_where"), filters, nil, opts)
+	return resp.MatchedCount, resp.DeletedCount, err
+}
+
+// ---- 远端 ego 元数据 ----
+
+// egoSwaggerURL 由 REST 地址推导远端 swagger.yaml 地址：{origin}/swagger/{alias}/swagger.yaml
+func egoSwaggerURL(dsn string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(dsn, "/"))
+	if err != nil {
+		return "", err
+	}
+	alias := path.Base(u.Path)
+	if alias == "" || alias == "/" || alias == "." {
+		return "", fmt.Errorf("dsn must end with the remote database alias")
+	}
+	u.Path = "/swagger/" + alias + "/swagger.yaml"
+	u.RawQuery = queryParamOpenAPI + "=" + openapiVersion30
+	return u.String(), nil
+}
+
+func extractEgoMeta(dsn, dbName string) ([]TableMeta, error) {
+	swaggerURL, err := egoSwaggerURL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open ego database %s failed: %w", dbName, err)
+	}
+	resp, err := (&http.Client{Timeout: defaultEgoTimeout}).Get(swaggerURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch remote swagger failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch remote swagger %s returned %s", swaggerURL, resp.Status)
+	}
+	var doc struct {
+		Tags []struct {
+			Name        string `yaml:"name"`
+			Description string `yaml:"description"`
+		} `yaml:"tags"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type        string `yaml:"type"`
+					Format      string `yaml:"format"`
+					Description string `yaml:"description"`
+					ReadOnly    bool   `yaml:"readOnly"`
+				} `yaml:"properties"`
+				Required   []string   `yaml:"required"`
+				PrimaryKey string     `yaml:"x-primary-key"`
+				UniqueKeys [][]string `yaml:"x-unique-keys"`
+			} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse remote swagger failed: %w", err)
+	}
+	var tables []TableMeta
+	for _, tag := range doc.Tags {
+		schema, ok := doc.Components.Schemas[tag.Name]
+		if !ok {
+			continue
+		}
+		tm := TableMeta{Name: tag.Name, Comment: tag.Description, PrimaryKey: schema.PrimaryKey, UniqueKeys: schema.UniqueKeys}
+		required := map[string]bool{}
+		for _, r := range schema.Required {
+			required[r] = true
+		}
+		// 旧版本远端没有 x-primary-key：batch_update 模型比普通模型多出的必填字段即主键
+		if batch, ok := doc.Components.Schemas[tag.Name+"_batch_update"]; ok && tm.PrimaryKey == "" {
+			for _, r := range batch.Required {
+				if !required[r] {
+					tm.PrimaryKey = r
+				}
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if tm.PrimaryKey == "" {
+			if _, ok := schema.Properties["id"]; ok {
+				tm.PrimaryKey = "id"
+			}
+		}
+		for _, name := range names {
+			prop := schema.Properties[name]
+			typ := egoFieldType(prop.Type, prop.Format)
+			tm.Fields = append(tm.Fields, FieldMeta{
+				Name:      name,
+				Type:      typ,
+				Nullable:  !required[name],
+				IsPrimary: name == tm.PrimaryKey,
+				AutoInc:   prop.ReadOnly && name == tm.PrimaryKey,
+				Default:   convertDefaultByType("", typ, !required[name]),
+				Comment:   prop.Description,
+			})
+		}
+		tables = append(tables, tm)
+	}
+	return tables, nil
+}
+
+// egoFieldType 把 swagger 类型还原为元数据类型名
+func egoFieldType(typ, format string) string {
+	switch typ {
+	case "integer":
+		return "bigint"
+	case "number":
+		return "double"
+	case "boolean":
+		return "boolean"
+	case "array", "object":
+		return "json"
+	}
+	if format == "date-time" {
+		return "timestamp"
+	}
+	return "text"
+}
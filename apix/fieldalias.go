@@ -0,0 +1,133 @@
+package apix
+
+import (
+	"net/url"
+	"strings"
+)
+
+// --------- 字段别名 ---------
+//
+// 表配置 field_aliases 把物理列名映射为对外的 API 字段名，请求中的过滤条件、fields、order、key
+// 参数与请求体按 API 名传入，响应记录按 API 名返回：
+//
+//	field_aliases:
+//	  usr_nm: username
+//	  crt_tm: created_time
+//
+// 其它表配置（primary_key、unique_keys、softdel_key、default_values 等）仍使用物理列名。
+// swagger 与 GraphQL schema 在生成时同样使用 API 名（见 dbmeta.go）。
+
+// apiFieldName 物理列名 → API 字段名
+func (tc *tableConfig) apiFieldName(physical string) string {
+	if alias, ok := tc.FieldAliases[physical]; ok && alias != "" {
+		return alias
+	}
+	return physical
+}
+
+// physicalFieldName API 字段名 → 物理列名，未配置别名的字段原样返回
+func (tc *tableConfig) physicalFieldName(name string) string {
+	for physical, alias := range tc.FieldAliases {
+		if alias == name {
+			return physical
+		}
+	}
+	return name
+}
+
+func (tc *tableConfig) physicalFieldNames(names []string) []string {
+	if len(tc.FieldAliases) == 0 {
+		return names
+	}
+	result := make([]string, len(names))
+	for i, n := range names {
+		result[i] = tc.physicalFieldName(n)
+	}
+	return result
+}
+
+func (tc *tableConfig) apiFieldNames(names []string) []string {
+	if len(tc.FieldAliases) == 0 {
+		return names
+	}
+	result := make([]string, len(names))
+	for i, n := range names {
+		result[i] = tc.apiFieldName(n)
+	}
+	return result
+}
+
+// physicalFieldList 转换逗号分隔的字段列表（fields、order 参数），保留 order 的 "-" 前缀
+func (tc *tableConfig) physicalFieldList(value string) string {
+	if len(tc.FieldAliases) == 0 || value == "" {
+		return value
+	}
+	parts := strings.Split(value, ",")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "-") {
+			parts[i] = "-" + tc.physicalFieldName(p[1:])
+		} else {
+			parts[i] = tc.physicalFieldName(p)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// physicalQuery 转换查询参数：过滤条件的字段名（字段__操作符）以及 fields、order 的值
+func (tc *tableConfig) physicalQuery(query url.Values) url.Values {
+	if len(tc.FieldAliases) == 0 {
+		return query
+	}
+	result := make(url.Values, len(query))
+	for key, values := range query {
+		switch {
+		case key == queryParamFields || key == queryParamOrder:
+			converted := make([]string, len(values))
+			for i, v := range values {
+				converted[i] = tc.physicalFieldList(v)
+			}
+			result[key] = converted
+		case key == queryParamOr:
+			result[key] = tc.physicalOrFilters(values)
+		case isReservedQueryParam(key) || key == queryParamKey:
+			result[key] = values
+		default:
+			field, op, hasOp := strings.Cut(key, "__")
+			field = tc.physicalFieldName(field)
+			if hasOp {
+				field += "__" + op
+			}
+			result[field] = values
+		}
+	}
+	return result
+}
+
+// physicalRecord 把请求体记录的字段名转换为物理列名
+func (tc *tableConfig) physicalRecord(record map[string]interface{}) map[string]interface{} {
+	return renameRecordFields(record, tc.physicalFieldName, len(tc.FieldAliases))
+}
+
+// apiRecord 把查询结果的字段名转换为 API 字段名
+func (tc *tableConfig) apiRecord(record map[string]interface{}) map[string]interface{} {
+	return renameRecordFields(record, tc.apiFieldName, len(tc.FieldAliases))
+}
+
+func (tc *tableConfig) apiRecords(records []map[string]interface{}) []map[string]interface{} {
+	for i := range records {
+		records[i] = tc.apiRecord(records[i])
+	}
+	return records
+}
+
+func renameRecordFields(record map[string]interface{}, rename func(string) string, aliasCount int) map[string]interface{} {
+	if aliasCount == 0 || record == nil {
+		return record
+	}
+	renamed := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		renamed[rename(k)] = v
+	}
+	return renamed
+}
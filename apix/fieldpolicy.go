@@ -0,0 +1,80 @@
+package apix
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --------- 字段隐藏与脱敏 ---------
+//
+// 表配置中声明不对外返回或需脱敏返回的字段（物理列名）：
+//
+//	hidden_fields: [password_hash]   # 响应中不返回，仍可写入；swagger 标记为 writeOnly
+//	masked_fields:
+//	  phone: partial                 # 保留前 3 位与后 4 位（各不超过长度的 1/3）：13812345678 → 138*****678
+//	  email: email                   # 保留首字符与域名：u***@example.com
+//	  id_card: full                  # 整体替换为 ****
+//
+// 所有经 renderRecord 输出的记录（列表、单条、批量、历史等）都会应用该策略。
+// GraphQL schema 由 swagger 生成：隐藏字段不出现在对象类型中，脱敏字段在 resolve 时再次脱敏，
+// 不会成为绕过 REST 字段策略的入口。未知的脱敏方式按 full 处理。
+
+const (
+	maskPartial = "partial"
+	maskEmail   = "email"
+	maskFull    = "full"
+)
+
+// applyFieldPolicy 去掉隐藏字段并对脱敏字段取值脱敏
+func (tc *tableConfig) applyFieldPolicy(record map[string]interface{}) map[string]interface{} {
+	if record == nil {
+		return record
+	}
+	for _, f := range tc.HiddenFields {
+		delete(record, f)
+	}
+	for f, kind := range tc.MaskedFields {
+		if v, ok := record[f]; ok {
+			record[f] = maskValue(kind, v)
+		}
+	}
+	return record
+}
+
+// maskValue 按脱敏方式处理取值，nil 原样返回；对已脱敏的值再次处理结果不变
+func maskValue(kind string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	s := fmt.Sprint(v)
+	switch kind {
+	case maskPartial:
+		r := []rune(s)
+		keep := len(r) / 3
+		prefix, suffix := min(3, keep), min(4, keep)
+		return string(r[:prefix]) + strings.Repeat("*", len(r)-prefix-suffix) + string(r[len(r)-suffix:])
+	case maskEmail:
+		at := strings.LastIndex(s, "@")
+		if at <= 0 {
+			return maskValue(maskPartial, s)
+		}
+		return string([]rune(s)[:1]) + "***" + s[at:]
+	default:
+		return "****"
+	}
+}
+
+// applyFieldPolicyMeta 在表元数据上标记隐藏与脱敏字段，需在 applyFieldAliases 之前调用
+func applyFieldPolicyMeta(t TableMeta, hidden []string, masked map[string]string) TableMeta {
+	if len(hidden) == 0 && len(masked) == 0 {
+		return t
+	}
+	fields := make([]FieldMeta, len(t.Fields))
+	for i, f := range t.Fields {
+		f.Hidden = contains(hidden, f.Name)
+		f.Mask = masked[f.Name]
+		fields[i] = f
+	}
+	t.Fields = fields
+	return t
+}
@@ -0,0 +1,123 @@
+package apix
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 可过滤、可排序字段 ---------
+//
+// 限定客户端可以用来过滤、排序的列，避免任意条件触发未建索引列的全表扫描：
+//
+//	filterable_fields: [status, created_time]   # 物理列名，为空时不限制
+//	sortable_fields: [created_time]
+//
+// 主键总是可以过滤和排序，隐藏字段（hidden_fields）总是不能用于过滤。filterable_fields 对查询类接口（列表、stats、timeseries、aggregate、distinct 等 GET 请求）
+// 与 update_where、delete_where 的过滤参数（含 _or 条件）生效，sortable_fields 对列表的 order 参数生效，
+// SQL 库与 MongoDB 一致，不满足时返回 400；aggregate_pipeline 中的 $match 不受限制（由 pipeline_stages 控制）。
+// 生成的 swagger 在表 schema 上给出 x-filterable-fields、x-sortable-fields，列表接口的说明与 order 参数列出可选字段；
+// GraphQL 列表查询的 filter、order 参数说明同样列出，order 在执行前校验。
+
+// filterable 判断物理列能否用于过滤
+func (tc *tableConfig) filterable(physical string) bool {
+	return len(tc.FilterableFields) == 0 || physical == tc.PrimaryKey || contains(tc.FilterableFields, physical)
+}
+
+// sortable 判断物理列能否用于排序
+func (tc *tableConfig) sortable(physical string) bool {
+	return len(tc.SortableFields) == 0 || physical == tc.PrimaryKey || contains(tc.SortableFields, physical)
+}
+
+// checkFilterFields 校验查询参数（API 字段名）中的过滤字段
+func (tc *tableConfig) checkFilterFields(query url.Values) error {
+	if len(tc.FilterableFields) == 0 && len(tc.HiddenFields) == 0 {
+		return nil
+	}
+	check := func(key string) error {
+		field, _, _ := strings.Cut(key, "__")
+		physical := tc.physicalFieldName(field)
+		// 隐藏字段的过滤条件（如 password_hash__like=a%）可以逐位试出取值，与不存在的字段一样拒绝
+		if contains(tc.HiddenFields, physical) {
+			return fmt.Errorf("unknown field: %s", field)
+		}
+		if !tc.filterable(physical) {
+			return fmt.Errorf("Field '%s' is not filterable", field)
+		}
+		return nil
+	}
+	for key, values := range query {
+		if key == queryParamOr {
+			for _, v := range values {
+				conds, err := parseOrFilter(v)
+				if err != nil {
+					return err
+				}
+				for _, cond := range conds {
+					if err := check(cond.Key); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if isNonFilterParam(key) {
+			continue
+		}
+		if err := check(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidFilterError 适配器不支持的过滤操作符或取值不合法，返回 400
+type invalidFilterError struct {
+	Key    string
+	Reason string
+}
+
+func (e *invalidFilterError) Error() string {
+	return fmt.Sprintf("invalid filter %s: %s", e.Key, e.Reason)
+}
+
+// listErrorStatus 列表查询错误的状态码：过滤条件错误为 400，其余为 500
+func listErrorStatus(err error) int {
+	var invalid *invalidFilterError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// checkSortFields 校验 order 参数（物理列名，逗号分隔，- 前缀降序）
+func (tc *tableConfig) checkSortFields(order string) error {
+	if len(tc.SortableFields) == 0 || order == "" {
+		return nil
+	}
+	for _, f := range strings.Split(order, ",") {
+		f = strings.TrimLeft(strings.TrimSpace(f), "-+")
+		if !tc.sortable(f) {
+			return fmt.Errorf("Field '%s' is not sortable", tc.apiFieldName(f))
+		}
+	}
+	return nil
+}
+
+// appliesFilters 请求的查询参数是否作为过滤条件：查询类接口与 update_where、delete_where，
+// 其余写接口的参数（如 bulk_jobs 的 source）不是过滤条件
+func appliesFilters(c *gin.Context) bool {
+	return c.Request.Method == http.MethodGet || strings.HasSuffix(c.FullPath(), "_where")
+}
+
+// withPrimaryKey 在字段列表前加上主键（已包含时不重复）
+func withPrimaryKey(pk string, fields []string) []string {
+	if len(fields) == 0 || pk == "" || contains(fields, pk) {
+		return fields
+	}
+	return append([]string{pk}, fields...)
+}
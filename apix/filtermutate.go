@@ -0,0 +1,422 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// --------- 按条件批量变更 ---------
+//
+// POST /:database/:table/update_where?status=1&age__lt=18
+// 请求体为要设置的字段，对所有匹配标准过滤条件的记录执行同一更新。
+//
+// POST /:database/:table/delete_where?status=0&created_time__lt=2024-01-01
+// 删除所有匹配的记录，配置了 softdel_key 的表执行软删除。
+//
+// 两者共同的约束：
+//   - 过滤条件不能为空，避免误操作全表；字段必须是表的列，操作符与取值必须有效（如 __isnull=true、
+//     __between=1,9），否则返回 400，不会忽略无效条件后变更更多的记录
+//   - max_affected=N 限制最大影响行数（不超过全局 max_affected_rows），超出时整体拒绝（422）。
+//     SQL 数据库在同一事务内计数并变更，限制是严格的；MongoDB 的计数与 UpdateMany/DeleteMany 不在同一事务，
+//     两者之间新写入的匹配文档也会被变更，限制只是尽力而为，响应中带 "max_affected_best_effort": true
+//   - dry_run=true 只返回匹配数量，不做变更
+//   - 权限独立于单条更新/删除，操作名分别为 update_where / delete_where（见 auth.go），未配置时默认要求 admin 角色
+
+// filterMutator 为可选能力：支持按过滤条件批量变更
+type filterMutator interface {
+	UpdateWhere(ctx context.Context, tc *tableConfig, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (matchedCount int64, modifiedCount int64, err error)
+	DeleteWhere(ctx context.Context, tc *tableConfig, filters url.Values, opts mutateWhereOptions) (matchedCount int64, affectedCount int64, err error)
+}
+
+type mutateWhereOptions struct {
+	MaxAffected int64
+	DryRun      bool
+}
+
+// bestEffortMutator 为可选能力：计数与批量变更不在同一事务内，max_affected 只是尽力而为
+type bestEffortMutator interface {
+	maxAffectedBestEffort() bool
+}
+
+// mutateWhereResult 在响应中标注 max_affected 是否只是尽力而为
+func mutateWhereResult(adapter databaseAdapter, opts mutateWhereOptions, result gin.H) gin.H {
+	if be, ok := adapter.(bestEffortMutator); ok && opts.MaxAffected > 0 && be.maxAffectedBestEffort() {
+		result["max_affected_best_effort"] = true
+	}
+	return result
+}
+
+type tooManyAffectedError struct {
+	Matched int64
+	Max     int64
+}
+
+func (e *tooManyAffectedError) Error() string {
+	return fmt.Sprintf("%d records matched, exceeds max_affected %d", e.Matched, e.Max)
+}
+
+// hasQueryFilter 判断请求是否带有字段过滤条件
+func hasQueryFilter(filters url.Values) bool {
+	for key, values := range filters {
+		if !isReservedQueryParam(key) && len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mutateFilterOps update_where、delete_where 接受的过滤操作符，"" 表示等值
+var mutateFilterOps = map[string]struct{}{
+	"": {}, "eq": {}, "ne": {}, "gt": {}, "gte": {}, "lt": {}, "lte": {},
+	"like": {}, "icontains": {}, "in": {}, "isnull": {}, "between": {},
+}
+
+// checkMutateFilters 校验过滤条件（物理列名，含 _or 条件）的字段、操作符与取值
+func (tc *tableConfig) checkMutateFilters(filters url.Values) error {
+	check := func(key, value string) error {
+		field, op, _ := strings.Cut(key, "__")
+		if !tc.hasColumn(field) {
+			return fmt.Errorf("unknown filter field: %s", tc.apiFieldName(field))
+		}
+		if _, ok := mutateFilterOps[op]; !ok {
+			return fmt.Errorf("unsupported filter operator: %s", key)
+		}
+		switch op {
+		case "isnull":
+			if _, ok := parseFilterValue(value).(bool); !ok {
+				return fmt.Errorf("invalid value for %s: %s, expected true or false", key, value)
+			}
+		case "between":
+			if len(parseFilterValues(value)) != 2 {
+				return fmt.Errorf("invalid value for %s: %s, expected min,max", key, value)
+			}
+		case "in":
+			if value == "" {
+				return fmt.Errorf("invalid value for %s: empty list", key)
+			}
+		}
+		return nil
+	}
+	for key, values := range filters {
+		if isReservedQueryParam(key) || len(values) == 0 {
+			continue
+		}
+		if key == queryParamOr {
+			for _, v := range values {
+				conds, err := parseOrFilter(v)
+				if err != nil {
+					return err
+				}
+				for _, cond := range conds {
+					if err := check(cond.Key, cond.Value); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if err := check(key, values[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mutateFilters 读取并校验 update_where、delete_where 的过滤条件，不通过时写错误并返回 false
+func mutateFilters(c *gin.Context, tc *tableConfig, op string) (url.Values, bool) {
+	filters := tc.physicalQuery(c.Request.URL.Query())
+	if !hasQueryFilter(filters) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": op + " requires at least one filter"})
+		return nil, false
+	}
+	if tc.columns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "table metadata unavailable: " + tc.Alias})
+		return nil, false
+	}
+	if err := tc.checkMutateFilters(filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return filters, true
+}
+
+func (dm *databaseManager) parseMutateWhereOptions(c *gin.Context) (mutateWhereOptions, error) {
+	opts := mutateWhereOptions{MaxAffected: int64(dm.config.MaxAffectedRows)}
+	if v := c.Query(queryParamMaxAffected); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid %s: %s", queryParamMaxAffected, v)
+		}
+		if opts.MaxAffected <= 0 || n < opts.MaxAffected {
+			opts.MaxAffected = n
+		}
+	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.DryRun = dryRun
+	return opts, nil
+}
+
+func (dm *databaseManager) handleUpdateWhere(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opUpdateWhere) {
+		return
+	}
+	mutator, ok := adapter.(filterMutator)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "update_where is not supported by this database type"})
+		return
+	}
+	filters, ok := mutateFilters(c, tableConfig, opUpdateWhere)
+	if !ok {
+		return
+	}
+	opts, err := dm.parseMutateWhereOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var updateData map[string]interface{}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload: " + err.Error()})
+		return
+	}
+	if updateData, err = tableConfig.inputRecord(updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	delete(updateData, mergeOriginalKey)
+	if _, ok := updateData[tableConfig.PrimaryKey]; ok && tableConfig.PrimaryKey != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Primary key '%s' cannot be updated by update_where", tableConfig.apiFieldName(tableConfig.PrimaryKey))})
+		return
+	}
+	if err := tableConfig.scopeRecords(c.Request.Context(), []map[string]interface{}{updateData}, false); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.validateUpdate(updateData); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.guardImmutable(c.Request.Context(), adapter, []map[string]interface{}{updateData}, nil); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.restrictTransitionFilters(filters, updateData); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if len(updateData) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update in payload"})
+		return
+	}
+	applyAutoUpdateFields(updateData, tableConfig)
+	applyAutoActorFields(updateData, tableConfig, dm.currentActor(c), false)
+	matchedCount, modifiedCount, err := mutator.UpdateWhere(c.Request.Context(), tableConfig, filters, updateData, opts)
+	if err != nil {
+		var tooMany *tooManyAffectedError
+		if errors.As(err, &tooMany) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "matched_count": tooMany.Matched, "max_affected": tooMany.Max})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update where: " + err.Error()})
+		return
+	}
+	if opts.DryRun {
+		c.JSON(http.StatusOK, mutateWhereResult(adapter, opts, gin.H{"dry_run": true, "matched_count": matchedCount}))
+		return
+	}
+	c.JSON(http.StatusOK, mutateWhereResult(adapter, opts, gin.H{"message": "Update where successful", "matched_count": matchedCount, "modified_count": modifiedCount}))
+}
+
+func (dm *databaseManager) handleDeleteWhere(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opDeleteWhere) {
+		return
+	}
+	mutator, ok := adapter.(filterMutator)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "delete_where is not supported by this database type"})
+		return
+	}
+	filters, ok := mutateFilters(c, tableConfig, opDeleteWhere)
+	if !ok {
+		return
+	}
+	opts, err := dm.parseMutateWhereOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	matchedCount, affectedCount, err := mutator.DeleteWhere(c.Request.Context(), tableConfig, filters, opts)
+	if err != nil {
+		var tooMany *tooManyAffectedError
+		if errors.As(err, &tooMany) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "matched_count": tooMany.Matched, "max_affected": tooMany.Max})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete where: " + err.Error()})
+		return
+	}
+	if opts.DryRun {
+		c.JSON(http.StatusOK, mutateWhereResult(adapter, opts, gin.H{"dry_run": true, "matched_count": matchedCount, "soft_delete": tableConfig.SoftDeleteKey != ""}))
+		return
+	}
+	c.JSON(http.StatusOK, mutateWhereResult(adapter, opts, gin.H{"message": "Delete where successful", "matched_count": matchedCount, "deleted_count": affectedCount}))
+}
+
+// softDeleteUpdateData 返回软删除时要写入的字段值
+func softDeleteUpdateData(tc *tableConfig) map[string]interface{} {
+	updateData := map[string]interface{}{}
+	switch tc.SoftDeleteType {
+	case softDeleteTypeBoolean:
+		updateData[tc.SoftDeleteKey] = true
+	case softDeleteTypeInt:
+		updateData[tc.SoftDeleteKey] = 1
+	default:
+		updateData[tc.SoftDeleteKey] = time.Now()
+	}
+	return updateData
+}
+
+func (a *gormAdapter) UpdateWhere(ctx context.Context, tc *tableConfig, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (int64, int64, error) {
+	var matched, modified int64
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		query := func() *gorm.DB {
+			q := applyGormSoftDeleteFilter(tx.Table(tc.Name), tc)
+			q, _ = applyGormQueryFilters(q, filters)
+			return q
+		}
+		if err := query().Count(&matched).Error; err != nil {
+			return err
+		}
+		if opts.MaxAffected > 0 && matched > opts.MaxAffected {
+			return &tooManyAffectedError{Matched: matched, Max: opts.MaxAffected}
+		}
+		if opts.DryRun || matched == 0 {
+			return nil
+		}
+		if vf := tc.versionField(); vf != "" {
+			data[vf] = gorm.Expr(vf + " + 1")
+		}
+		res := query().Updates(data)
+		if res.Error != nil {
+			return res.Error
+		}
+		modified = res.RowsAffected
+		return nil
+	})
+	return matched, modified, err
+}
+
+// maxAffectedBestEffort MongoDB 的 CountDocuments 与 UpdateMany/DeleteMany 之间没有事务保护
+func (a *mongoAdapter) maxAffectedBestEffort() bool { return true }
+
+func (a *mongoAdapter) UpdateWhere(ctx context.Context, tc *tableConfig, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (int64, int64, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, filters)
+	matched, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	if opts.MaxAffected > 0 && matched > opts.MaxAffected {
+		return matched, 0, &tooManyAffectedError{Matched: matched, Max: opts.MaxAffected}
+	}
+	if opts.DryRun || matched == 0 {
+		return matched, 0, nil
+	}
+	update := bson.M{"$set": data}
+	if vf := tc.versionField(); vf != "" {
+		update["$inc"] = bson.M{vf: 1}
+	}
+	res, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, 0, err
+	}
+	return res.MatchedCount, res.ModifiedCount, nil
+}
+
+func (a *gormAdapter) DeleteWhere(ctx context.Context, tc *tableConfig, filters url.Values, opts mutateWhereOptions) (int64, int64, error) {
+	var matched, affected int64
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		query := func() *gorm.DB {
+			q := applyGormSoftDeleteFilter(tx.Table(tc.Name), tc)
+			q, _ = applyGormQueryFilters(q, filters)
+			return q
+		}
+		if err := query().Count(&matched).Error; err != nil {
+			return err
+		}
+		if opts.MaxAffected > 0 && matched > opts.MaxAffected {
+			return &tooManyAffectedError{Matched: matched, Max: opts.MaxAffected}
+		}
+		if opts.DryRun || matched == 0 {
+			return nil
+		}
+		var res *gorm.DB
+		if tc.SoftDeleteKey != "" {
+			res = query().Updates(softDeleteUpdateData(tc))
+		} else {
+			res = query().Delete(nil)
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		affected = res.RowsAffected
+		return nil
+	})
+	return matched, affected, err
+}
+
+func (a *mongoAdapter) DeleteWhere(ctx context.Context, tc *tableConfig, filters url.Values, opts mutateWhereOptions) (int64, int64, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, filters)
+	matched, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	if opts.MaxAffected > 0 && matched > opts.MaxAffected {
+		return matched, 0, &tooManyAffectedError{Matched: matched, Max: opts.MaxAffected}
+	}
+	if opts.DryRun || matched == 0 {
+		return matched, 0, nil
+	}
+	if tc.SoftDeleteKey != "" {
+		res, err := collection.UpdateMany(ctx, filter, bson.M{"$set": softDeleteUpdateData(tc)})
+		if err != nil {
+			return matched, 0, err
+		}
+		return matched, res.ModifiedCount, nil
+	}
+	res, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return matched, 0, err
+	}
+	return matched, res.DeletedCount, nil
+}
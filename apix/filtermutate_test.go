@@ -0,0 +1,88 @@
+package apix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMutateFilters(t *testing.T) {
+	tbl := &tableConfig{
+		Alias:        "user",
+		FieldAliases: map[string]string{"user_name": "name"},
+		columns:      map[string]struct{}{"id": {}, "status": {}, "age": {}, "user_name": {}},
+	}
+	cases := []struct {
+		query string
+		ok    bool
+	}{
+		{"status=1", true},
+		{"age__between=18,30&status__ne=0", true},
+		{"id__in=1,2,3", true},
+		{"age__isnull=true", true},
+		{"user_name__like=a%25", true},
+		{"_or=status:1,age__lt:18", true},
+		{"page=1&status=1", true},
+		{"stauts=1", false},
+		{"status__regex=.*", false},
+		{"age__isnull=yes", false},
+		{"age__between=18", false},
+		{"id__in=", false},
+		{"_or=status:1,nope:2", false},
+		{"_or=status__foo:1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tc.query)
+			assert.NoError(t, err)
+			err = tbl.checkMutateFilters(q)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestMutateFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	columns := map[string]struct{}{"id": {}, "status": {}}
+	cases := []struct {
+		name    string
+		query   string
+		columns map[string]struct{}
+		status  int
+	}{
+		{"filter", "status=1", columns, http.StatusOK},
+		{"no filter", "", columns, http.StatusBadRequest},
+		{"only reserved params", "page=1&page_size=10&dry_run=true", columns, http.StatusBadRequest},
+		{"unknown field", "nope=1", columns, http.StatusBadRequest},
+		{"unknown operator", "status__foo=1", columns, http.StatusBadRequest},
+		{"metadata unavailable", "status=1", nil, http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/rest/test/user/delete_where?"+tc.query, nil)
+			tbl := &tableConfig{Alias: "user", columns: tc.columns}
+			_, ok := mutateFilters(c, tbl, "delete_where")
+			assert.Equal(t, tc.status == http.StatusOK, ok)
+			if !ok {
+				assert.Equal(t, tc.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestMutateWhereResult(t *testing.T) {
+	limited := mutateWhereOptions{MaxAffected: 10}
+	assert.Equal(t, true, mutateWhereResult(&mongoAdapter{}, limited, gin.H{})["max_affected_best_effort"])
+	assert.NotContains(t, mutateWhereResult(&mongoAdapter{}, mutateWhereOptions{}, gin.H{}), "max_affected_best_effort")
+	assert.NotContains(t, mutateWhereResult(&gormAdapter{}, limited, gin.H{}), "max_affected_best_effort")
+}
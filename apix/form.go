@@ -0,0 +1,315 @@
+package apix
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 表单描述 ---------
+//
+// 为低代码前端生成新增、编辑表单所需的字段描述：
+//
+//	GET /api/meta/:database/:table/form
+//	  => {"database": "test", "table": "user", "title": "用户", "primary_key": "id",
+//	      "operations": {"create": true, "update": true, "delete": false},
+//	      "fields": [{"name": "status", "label": "状态", "type": "integer", "widget": "select", "required": true,
+//	                  "options": [{"value": "1", "label": "active"}], ...}]}
+//
+// 字段信息来自生成的 swagger.yaml 与表配置：label 取字段注释（没有注释时由字段名生成），
+// required 与创建接口的 swagger 一致，校验规则（validations）原样给出，immutable_fields 为 immutable，
+// value_labels 按 Accept-Language 生成下拉选项，label_joins 与 belongs_to 关联给出参照表（reference）。
+// widget 按类型推断：select、checkbox、number、datetime、date、textarea、password（隐藏字段）、text。
+// 字段顺序为主键在前、其余按名称排序，可在表配置中调整：
+//
+//	form:
+//	  fields: [username, email, age]   # 物理列名，未列出的字段排在后面
+//	  widgets: {bio: textarea}
+//	  labels: {age: 年龄}
+//
+// 需要 list 权限；operations 表示当前调用者可执行的写操作。
+
+type formConfig struct {
+	Fields  []string          `mapstructure:"fields"`
+	Widgets map[string]string `mapstructure:"widgets"`
+	Labels  map[string]string `mapstructure:"labels"`
+}
+
+type formField struct {
+	Name      string       `json:"name"`
+	Label     string       `json:"label"`
+	Type      string       `json:"type"`
+	Widget    string       `json:"widget"`
+	Required  bool         `json:"required,omitempty"`
+	ReadOnly  bool         `json:"read_only,omitempty"`
+	WriteOnly bool         `json:"write_only,omitempty"`
+	Immutable bool         `json:"immutable,omitempty"`
+	Nullable  bool         `json:"nullable,omitempty"`
+	Masked    bool         `json:"masked,omitempty"`
+	Default   interface{}  `json:"default,omitempty"`
+	Options   []formOption `json:"options,omitempty"`
+	Min       *float64     `json:"min,omitempty"`
+	Max       *float64     `json:"max,omitempty"`
+	MaxLength int          `json:"max_length,omitempty"`
+	Pattern   string       `json:"pattern,omitempty"`
+	Reference *formRef     `json:"reference,omitempty"`
+	physical  string
+	enum      []interface{}
+}
+
+type formOption struct {
+	Value interface{} `json:"value"`
+	Label interface{} `json:"label"`
+}
+
+// formRef 参照表，前端可据此从 REST 接口加载候选记录
+type formRef struct {
+	Table      string `json:"table"`
+	Field      string `json:"field"`
+	LabelField string `json:"label_field,omitempty"`
+}
+
+// 超过该长度的字符串使用多行输入
+const formTextareaLength = 255
+
+func (dm *databaseManager) handleTableForm(c *gin.Context) {
+	dbName := c.Param("database")
+	adapter, tc, err := dm.getAdapterAndTableConfig(dbName, c.Param("table"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tc, opList) {
+		return
+	}
+	doc, err := dm.browserSpec(dbName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	schema := doc.resolve(doc.Components.Schemas[tc.Alias])
+	if schema == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schema not found for table: " + tc.Alias})
+		return
+	}
+	dm.mutex.RLock()
+	tables := dm.config.Databases[dbName].Tables
+	dm.mutex.RUnlock()
+
+	fields := tc.formFields(schema, tables)
+	if len(tc.valueLabels) > 0 {
+		c.Header("Vary", "Accept-Language")
+		dm.formOptions(c, dbName, tc, fields)
+	}
+	readOnly := tc.isRollup()
+	if ro, ok := adapter.(readOnlyAdapter); ok && ro.ReadOnly() {
+		readOnly = true
+	}
+	title := schema.Description
+	if title == "" {
+		title = tc.Alias
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"database":    dbName,
+		"table":       tc.Alias,
+		"title":       title,
+		"primary_key": tc.apiFieldName(tc.PrimaryKey),
+		"operations": gin.H{
+			opCreate: !readOnly && dm.allowed(c, tc, opCreate),
+			opUpdate: !readOnly && tc.PrimaryKey != "" && dm.allowed(c, tc, opUpdate),
+			opDelete: !readOnly && tc.PrimaryKey != "" && dm.allowed(c, tc, opDelete),
+		},
+		"fields": fields,
+	})
+}
+
+// formFields 按 swagger 中的表 schema 与表配置生成字段描述，跳过 label_joins、value_labels 生成的字段
+func (tc *tableConfig) formFields(schema *openapiSchema, tables []tableConfig) []formField {
+	generated := map[string]bool{}
+	for _, j := range tc.labelJoins {
+		generated[tc.apiFieldName(j.As)] = true
+	}
+	for _, vl := range tc.valueLabels {
+		generated[tc.apiFieldName(vl.As)] = true
+	}
+	refs := tc.formReferences(tables)
+	pk := tc.apiFieldName(tc.PrimaryKey)
+	fields := []formField{}
+	for name, prop := range schema.Properties {
+		if prop == nil || generated[name] {
+			continue
+		}
+		physical := tc.physicalFieldName(name)
+		f := formField{
+			Name:      name,
+			Label:     tc.Form.Labels[physical],
+			Type:      string(prop.Type),
+			ReadOnly:  prop.ReadOnly,
+			WriteOnly: prop.WriteOnly,
+			Immutable: contains(tc.ImmutableFields, physical),
+			Nullable:  prop.Nullable,
+			Required:  contains(schema.Required, name),
+			Reference: refs[physical],
+			physical:  physical,
+			enum:      prop.Enum,
+		}
+		_, f.Masked = tc.MaskedFields[physical]
+		if f.Label == "" {
+			f.Label = prop.Description
+		}
+		if f.Label == "" {
+			f.Label = formLabel(name)
+		}
+		if v, ok := tc.DefaultValues[physical]; ok && !strings.Contains(fmt.Sprint(v), "{{") {
+			f.Default = v
+		}
+		if v, ok := tc.Validations[physical]; ok {
+			f.Min, f.Max, f.MaxLength, f.Pattern = v.Min, v.Max, v.MaxLength, v.Regex
+			if len(v.Enum) > 0 {
+				f.enum = v.Enum
+			}
+		}
+		for _, e := range f.enum {
+			f.Options = append(f.Options, formOption{Value: e, Label: keyValueString(e)})
+		}
+		f.Widget = tc.Form.Widgets[physical]
+		if f.Widget == "" {
+			f.Widget = tc.formWidget(f, prop.Format)
+		}
+		fields = append(fields, f)
+	}
+	order := make(map[string]int, len(tc.Form.Fields))
+	for i, name := range tc.Form.Fields {
+		order[name] = i
+	}
+	rank := func(f formField) int {
+		if f.Name == pk {
+			return -1
+		}
+		if i, ok := order[f.physical]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		ri, rj := rank(fields[i]), rank(fields[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// formReferences 返回外键列 → 参照表，label_joins 优先于 belongs_to 关联
+func (tc *tableConfig) formReferences(tables []tableConfig) map[string]*formRef {
+	refs := map[string]*formRef{}
+	for _, rel := range tc.Relations {
+		target := findTableConfig(tables, rel.Table)
+		if rel.Type != relationBelongsTo || target == nil {
+			continue
+		}
+		refs[rel.local] = &formRef{Table: target.Alias, Field: target.apiFieldName(rel.remote)}
+	}
+	for _, j := range tc.labelJoins {
+		target := findTableConfig(tables, j.Table)
+		if target == nil {
+			continue
+		}
+		key := j.Key
+		if key == "" {
+			key = target.PrimaryKey
+		}
+		refs[j.Field] = &formRef{Table: target.Alias, Field: target.apiFieldName(key), LabelField: target.apiFieldName(j.Label)}
+	}
+	return refs
+}
+
+// formOptions 为配置了 value_labels 的字段生成当前语言的下拉选项
+func (dm *databaseManager) formOptions(c *gin.Context, dbName string, tc *tableConfig, fields []formField) {
+	requested := parseAcceptLanguage(c.GetHeader("Accept-Language"))
+	for i := range tc.valueLabels {
+		vl := &tc.valueLabels[i]
+		locale := vl.negotiate(requested)
+		labels := map[string]interface{}{}
+		if vl.Table != "" {
+			for code, name := range dm.referenceLabels(c.Request.Context(), dbName, labelJoin{Table: vl.Table, Key: vl.Key, Label: vl.Locales[vl.DefaultLocale]}) {
+				labels[code] = name
+			}
+			if locale != vl.DefaultLocale {
+				for code, name := range dm.referenceLabels(c.Request.Context(), dbName, labelJoin{Table: vl.Table, Key: vl.Key, Label: vl.Locales[locale]}) {
+					if name != nil {
+						labels[code] = name
+					}
+				}
+			}
+		} else {
+			for code, names := range vl.Values {
+				if name, ok := names[locale]; ok {
+					labels[code] = name
+				} else if name, ok := names[vl.DefaultLocale]; ok {
+					labels[code] = name
+				} else {
+					labels[code] = nil
+				}
+			}
+		}
+		for j := range fields {
+			f := &fields[j]
+			if f.physical != vl.Field {
+				continue
+			}
+			f.Options = make([]formOption, 0, len(labels))
+			for _, code := range sortedKeys(labels) {
+				f.Options = append(f.Options, formOption{Value: code, Label: labels[code]})
+			}
+			if tc.Form.Widgets[f.physical] == "" {
+				f.Widget = "select"
+			}
+		}
+	}
+}
+
+// formWidget 按字段类型与配置推断输入控件
+func (tc *tableConfig) formWidget(f formField, format string) string {
+	switch {
+	case f.WriteOnly:
+		return "password"
+	case len(f.Options) > 0 || f.Reference != nil:
+		return "select"
+	case f.Type == "boolean":
+		return "checkbox"
+	case f.Type == "integer" || f.Type == "number":
+		return "number"
+	case format == "date" || strings.HasSuffix(f.physical, "_date"):
+		return "date"
+	case format == "date-time" || tc.isTimeField(f.physical):
+		return "datetime"
+	case f.MaxLength > formTextareaLength:
+		return "textarea"
+	}
+	return "text"
+}
+
+// isTimeField 判断是否为时间字段：配置了 time_fields、自动更新或软删除时间，或按命名约定
+func (tc *tableConfig) isTimeField(field string) bool {
+	if _, ok := tc.TimeFields[field]; ok {
+		return true
+	}
+	if contains(tc.GetAutoUpdateFields(), field) || (field == tc.SoftDeleteKey && tc.SoftDeleteType == "timestamp") {
+		return true
+	}
+	return strings.HasSuffix(field, "_time") || strings.HasSuffix(field, "_at")
+}
+
+// formLabel 由字段名生成标签，如 created_time => Created time
+func formLabel(name string) string {
+	s := strings.TrimSpace(strings.ReplaceAll(name, "_", " "))
+	if s == "" {
+		return name
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
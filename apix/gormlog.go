@@ -0,0 +1,451 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// --------- GORM 日志输出 ---------
+//
+// 除 gorm_log.filename（lumberjack 文件，为空则不写文件）外，可配置多个结构化输出，每条日志为一个 JSON 对象：
+//
+//	gorm_log:
+//	  sinks:
+//	    - type: stdout
+//	    - type: syslog
+//	      address: "udp://127.0.0.1:514"   # 也可为 tcp://host:port、unixgram:///dev/log
+//	      tag: ego
+//	    - type: loki
+//	      url: "http://loki:3100/loki/api/v1/push"
+//	      labels: {app: ego}                # 另自动附加 database、level 标签
+//	      tenant: ""                        # X-Scope-OrgID
+//	      batch_size: 100
+//	      flush_interval: 1s
+//
+// 库配置中的 log_level 覆盖 gorm_log.log_level，例如只为分析库开启 info 级别。
+
+type logSinkConfig struct {
+	Type          string            `mapstructure:"type"`
+	Address       string            `mapstructure:"address"`
+	Tag           string            `mapstructure:"tag"`
+	URL           string            `mapstructure:"url"`
+	Labels        map[string]string `mapstructure:"labels"`
+	Tenant        string            `mapstructure:"tenant"`
+	BatchSize     int               `mapstructure:"batch_size"`
+	FlushInterval time.Duration     `mapstructure:"flush_interval"`
+}
+
+type gormLogEntry struct {
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"`
+	Database   string    `json:"database,omitempty"`
+	Message    string    `json:"msg,omitempty"`
+	SQL        string    `json:"sql,omitempty"`
+	DurationMs float64   `json:"duration_ms,omitempty"`
+	Rows       *int64    `json:"rows,omitempty"`
+	Slow       bool      `json:"slow,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+}
+
+type logSink interface {
+	write(entry gormLogEntry)
+}
+
+func parseGormLogLevel(s string) (logger.LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "warn":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	}
+	return 0, fmt.Errorf("invalid gorm log level: %s", s)
+}
+
+// gormLogger 同时写 lumberjack 文件（与 gorm 默认日志格式一致）与结构化输出
+type gormLogger struct {
+	file     *log.Logger
+	sinks    []logSink
+	config   logger.Config
+	database string
+}
+
+func newGormLogger(cfg gormLogConfig) (*gormLogger, error) {
+	slowThreshold, err := time.ParseDuration(cfg.SlowThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slow_threshold: %w", err)
+	}
+	logLevel, err := parseGormLogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	l := &gormLogger{config: logger.Config{
+		SlowThreshold:             slowThreshold,
+		LogLevel:                  logLevel,
+		IgnoreRecordNotFoundError: true,
+	}}
+	if cfg.Filename != "" {
+		l.file = log.New(&lumberjack.Logger{
+			Filename:   cfg.Filename,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+		}, "\r\n", log.LstdFlags)
+	}
+	for i, sc := range cfg.Sinks {
+		sink, err := newLogSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("gorm_log.sinks[%d]: %w", i, err)
+		}
+		l.sinks = append(l.sinks, sink)
+	}
+	return l, nil
+}
+
+func newLogSink(cfg logSinkConfig) (logSink, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "stdout":
+		return &stdoutSink{}, nil
+	case "syslog":
+		return newSyslogSink(cfg)
+	case "loki":
+		return newLokiSink(cfg)
+	}
+	return nil, fmt.Errorf("unsupported sink type: %s", cfg.Type)
+}
+
+// forDatabase 返回带库名的日志，level 非空时覆盖全局级别
+func (l *gormLogger) forDatabase(name, level string) (logger.Interface, error) {
+	n := *l
+	n.database = name
+	if level == "" {
+		return &n, nil
+	}
+	lv, err := parseGormLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return n.LogMode(lv), nil
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	n := *l
+	n.config.LogLevel = level
+	return &n
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Info {
+		l.output(gormLogEntry{Level: "info", Message: fmt.Sprintf(msg, data...)})
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Warn {
+		l.output(gormLogEntry{Level: "warn", Message: fmt.Sprintf(msg, data...)})
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= logger.Error {
+		l.output(gormLogEntry{Level: "error", Message: fmt.Sprintf(msg, data...)})
+	}
+}
+
+// Trace 与 gorm 默认日志的判断一致：错误 > 慢查询 > info 级别的全部 SQL
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.config.LogLevel <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	var entry gormLogEntry
+	switch {
+	case err != nil && l.config.LogLevel >= logger.Error && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.config.IgnoreRecordNotFoundError):
+		entry = gormLogEntry{Level: "error", Error: err.Error()}
+	case elapsed > l.config.SlowThreshold && l.config.SlowThreshold != 0 && l.config.LogLevel >= logger.Warn:
+		entry = gormLogEntry{Level: "warn", Slow: true}
+	case l.config.LogLevel == logger.Info:
+		entry = gormLogEntry{Level: "info"}
+	default:
+		return
+	}
+	sql, rows := fc()
+	entry.Time = begin
+	entry.SQL = sql
+	entry.DurationMs = float64(elapsed.Nanoseconds()) / 1e6
+	if rows >= 0 {
+		entry.Rows = &rows
+	}
+	l.output(entry)
+}
+
+func (l *gormLogger) output(entry gormLogEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Caller = gormCaller()
+	entry.Database = l.database
+	if l.file != nil {
+		l.file.Print(formatGormLogLine(entry, l.config.SlowThreshold))
+	}
+	for _, s := range l.sinks {
+		s.write(entry)
+	}
+}
+
+// formatGormLogLine 生成与 gorm 默认日志相同的文本，索引建议依赖此格式解析
+func formatGormLogLine(e gormLogEntry, slowThreshold time.Duration) string {
+	if e.SQL == "" {
+		return fmt.Sprintf("%s\n[%s] %s", e.Caller, e.Level, e.Message)
+	}
+	rows := "-"
+	if e.Rows != nil {
+		rows = strconv.FormatInt(*e.Rows, 10)
+	}
+	head := e.Caller
+	switch {
+	case e.Error != "":
+		head += " " + e.Error
+	case e.Slow:
+		head += fmt.Sprintf(" SLOW SQL >= %v", slowThreshold)
+	}
+	return fmt.Sprintf("%s\n[%.3fms] [rows:%s] %s", head, e.DurationMs, rows, e.SQL)
+}
+
+// gormCaller 取第一个不在 gorm 及日志包装内的调用位置
+func gormCaller() string {
+	pcs := [20]uintptr{}
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "gorm.io/") &&
+			!strings.HasPrefix(frame.Function, "ego/apix.(*gormLogger)") &&
+			!strings.HasPrefix(frame.Function, "ego/apix.(*slowQueryLogger)") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// ---- stdout ----
+
+type stdoutSink struct {
+	mu sync.Mutex
+}
+
+func (s *stdoutSink) write(entry gormLogEntry) {
+	b, _ := json.Marshal(entry)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Stdout.Write(append(b, '\n'))
+}
+
+// ---- syslog（RFC 5424） ----
+
+type syslogSink struct {
+	network  string
+	address  string
+	tag      string
+	hostname string
+	mu       sync.Mutex
+	conn     net.Conn
+}
+
+func newSyslogSink(cfg logSinkConfig) (*syslogSink, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("syslog address is required")
+	}
+	u, err := url.Parse(cfg.Address)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("invalid syslog address: %s", cfg.Address)
+	}
+	s := &syslogSink{network: u.Scheme, address: u.Host, tag: cfg.Tag}
+	if strings.HasPrefix(u.Scheme, "unix") {
+		s.address = u.Path
+	}
+	if s.tag == "" {
+		s.tag = "ego"
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	return s, nil
+}
+
+func (s *syslogSink) write(entry gormLogEntry) {
+	// facility local0
+	severity := 6
+	switch entry.Level {
+	case "error":
+		severity = 3
+	case "warn":
+		severity = 4
+	}
+	b, _ := json.Marshal(entry)
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s\n", 16*8+severity, entry.Time.Format(time.RFC3339Nano), s.hostname, s.tag, os.Getpid(), b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 连接断开时重连一次，仍失败则丢弃
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.address, 3*time.Second)
+			if err != nil {
+				return
+			}
+			s.conn = conn
+		}
+		if _, err := s.conn.Write([]byte(msg)); err == nil {
+			return
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// ---- Loki push ----
+
+const lokiQueueSize = 10000
+
+type lokiSink struct {
+	url       string
+	labels    map[string]string
+	tenant    string
+	batchSize int
+	interval  time.Duration
+	client    *http.Client
+	ch        chan gormLogEntry
+}
+
+func newLokiSink(cfg logSinkConfig) (*lokiSink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("loki url is required")
+	}
+	s := &lokiSink{
+		url:       cfg.URL,
+		labels:    cfg.Labels,
+		tenant:    cfg.Tenant,
+		batchSize: cfg.BatchSize,
+		interval:  cfg.FlushInterval,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		ch:        make(chan gormLogEntry, lokiQueueSize),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 100
+	}
+	if s.interval <= 0 {
+		s.interval = time.Second
+	}
+	go s.run()
+	return s, nil
+}
+
+// write 不阻塞查询，队列满时丢弃
+func (s *lokiSink) write(entry gormLogEntry) {
+	select {
+	case s.ch <- entry:
+	default:
+	}
+}
+
+func (s *lokiSink) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	var batch []gormLogEntry
+	for {
+		select {
+		case e := <-s.ch:
+			batch = append(batch, e)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := s.push(batch); err != nil {
+			log.Printf("push gorm log to loki failed: %v", err)
+		}
+		batch = nil
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) push(batch []gormLogEntry) error {
+	streams := map[string]*lokiStream{}
+	var order []string
+	for _, e := range batch {
+		key := e.Database + "|" + e.Level
+		st, ok := streams[key]
+		if !ok {
+			labels := make(map[string]string, len(s.labels)+2)
+			for k, v := range s.labels {
+				labels[k] = v
+			}
+			if e.Database != "" {
+				labels["database"] = e.Database
+			}
+			labels["level"] = e.Level
+			st = &lokiStream{Stream: labels}
+			streams[key] = st
+			order = append(order, key)
+		}
+		line, _ := json.Marshal(e)
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(line)})
+	}
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range order {
+		payload.Streams = append(payload.Streams, streams[key])
+	}
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenant)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("loki responded %s", resp.Status)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package apix
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,16 +16,40 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
 	"github.com/graphql-go/graphql/language/ast"
 	"github.com/graphql-go/handler"
 	"gopkg.in/yaml.v3"
 )
 
-// 用于记录批量更新schema的主键字段（支持复合主键）
-var batchUpdatePkMap = make(map[string][]string)
+const defaultMaxPageSize = 1000
 
 // RegisterGraphqlAPI registers /api/graphql as a proxy to all parsed RESTful endpoints from swagger yamls.
-func RegisterGraphqlAPI(router *gin.Engine, path string, cfgDir string, restBaseURL string) error {
+// Resolvers call the REST API at restBaseURL over HTTP; mounting through the Builder together with WithRest
+// resolves queries against the database adapters directly instead, see graphqlnative.go.
+// List queries reject page_size above maxPageSize instead of letting the REST layer clamp it.
+// The schema can be rebuilt later without restarting, see graphqlreload.go.
+func RegisterGraphqlAPI(router *gin.Engine, path string, cfgDir string, restBaseURL string, maxPageSize int) error {
+	_, err := mountGraphqlAPI(router, path, cfgDir, proxyResolvers(restBaseURL), maxPageSize)
+	return err
+}
+
+func mountGraphqlAPI(router *gin.Engine, path string, cfgDir string, resolvers graphqlResolvers, maxPageSize int) (*graphqlEndpoint, error) {
+	ep := &graphqlEndpoint{path: path, cfgDir: cfgDir, resolvers: resolvers, maxPageSize: maxPageSize}
+	if err := ep.reload(); err != nil {
+		return nil, err
+	}
+	registerGraphqlEndpoint(ep)
+
+	router.POST(path, gin.WrapH(ep))
+	router.GET(path, gin.WrapH(ep))
+	router.GET(path+"/schema.graphql", ep.serveSDL)
+	log.Printf("[GraphQL] Registered at %s", path)
+	return ep, nil
+}
+
+// buildGraphqlSchema 解析 cfgDir 下的 swagger.yaml 生成 schema
+func buildGraphqlSchema(cfgDir string, resolvers graphqlResolvers, maxPageSize int) (*graphql.Schema, error) {
 	types, inputTypes, queries, mutations := map[string]*graphql.Object{}, map[string]*graphql.InputObject{}, graphql.Fields{}, graphql.Fields{}
 
 	// 1. Parse all _swagger.yaml
@@ -39,13 +64,13 @@ func RegisterGraphqlAPI(router *gin.Engine, path string, cfgDir string, restBase
 				log.Printf("failed to read %s: %v", p, readErr)
 				return nil
 			}
-			mergeSwaggerToGraphql(data, types, inputTypes, queries, mutations, restBaseURL)
+			mergeSwaggerToGraphql(data, types, inputTypes, queries, mutations, resolvers, maxPageSize)
 		}
 		return nil
 	})
 	if err != nil {
 		log.Printf("WalkDir failed: %v", err)
-		return err
+		return nil, err
 	}
 
 	// 2. Ensure queries is not empty
@@ -73,19 +98,17 @@ func RegisterGraphqlAPI(router *gin.Engine, path string, cfgDir string, restBase
 	})
 	if err != nil {
 		log.Printf("GraphQL schema build failed: %v", err)
-		return err
+		return nil, err
 	}
+	return &schema, nil
+}
 
-	h := handler.New(&handler.Config{
-		Schema:   &schema,
+func newGraphqlHandler(schema *graphql.Schema) http.Handler {
+	return handler.New(&handler.Config{
+		Schema:   schema,
 		Pretty:   true,
 		GraphiQL: false, // set true for dev env
 	})
-
-	router.POST(path, gin.WrapH(h))
-	router.GET(path, gin.WrapH(h))
-	log.Printf("[GraphQL] Registered at %s", path)
-	return nil
 }
 
 // 转为匈牙利风格：user_batch_update => InputUserBatchUpdate
@@ -109,12 +132,18 @@ func mergeSwaggerToGraphql(
 	inputTypes map[string]*graphql.InputObject,
 	queries graphql.Fields,
 	mutations graphql.Fields,
-	restBaseURL string,
+	resolvers graphqlResolvers,
+	maxPageSize int,
 ) {
 	type swaggerSchema struct {
 		Type       string                            `yaml:"type"`
 		Properties map[string]map[string]interface{} `yaml:"properties"`
 		Required   []string                          `yaml:"required"`
+		PrimaryKey string                            `yaml:"x-primary-key"`
+		UniqueKeys [][]string                        `yaml:"x-unique-keys"`
+		SoftDelKey string                            `yaml:"x-softdel-key"`
+		Filterable []string                          `yaml:"x-filterable-fields"`
+		Sortable   []string                          `yaml:"x-sortable-fields"`
 	}
 	type swagger struct {
 		Components struct {
@@ -134,7 +163,13 @@ func mergeSwaggerToGraphql(
 		inFields := graphql.InputObjectConfigFieldMap{}
 		for fname, prop := range sch.Properties {
 			ftype := graphqlTypeBySwagger(prop, fname, types)
-			fields[fname] = &graphql.Field{Type: ftype}
+			// 与 REST 一致：隐藏字段不出现在对象类型中，脱敏字段在 resolve 时脱敏
+			if wo, _ := prop["writeOnly"].(bool); !wo {
+				fields[fname] = &graphql.Field{Type: ftype}
+				if kind, _ := prop["x-masked"].(string); kind != "" {
+					fields[fname].Resolve = maskedFieldResolver(fname, kind)
+				}
+			}
 			if ro, _ := prop["readOnly"].(bool); !ro {
 				inFields[fname] = &graphql.InputObjectFieldConfig{Type: graphqlInputTypeBySwagger(prop, fname, types, inputTypes)}
 			}
@@ -148,14 +183,27 @@ func mergeSwaggerToGraphql(
 			Name:   inputTypeName,
 			Fields: inFields,
 		})
-		// 记录所有 batch_update schema 的主键 required 字段
-		if (strings.Contains(name, "batch_update") || strings.Contains(name, "BatchUpdate")) && len(sch.Required) > 0 {
-			batchUpdatePkMap[name] = sch.Required
+		// 主键与唯一键字段组成的键对象，供 batchDelete 按任一键定位记录
+		keyFields := graphql.InputObjectConfigFieldMap{}
+		for _, f := range append([]string{sch.PrimaryKey}, flattenKeys(sch.UniqueKeys)...) {
+			if prop, ok := sch.Properties[f]; ok {
+				keyFields[f] = &graphql.InputObjectFieldConfig{Type: graphqlInputTypeBySwagger(prop, f, types, inputTypes)}
+			}
+		}
+		if len(keyFields) > 0 {
+			keyTypeName := toHungarianInputTypeName(name + "_key")
+			inputTypes[keyTypeName] = graphql.NewInputObject(graphql.InputObjectConfig{
+				Name:   keyTypeName,
+				Fields: keyFields,
+			})
 		}
 	}
 
 	// 2. Parse paths to generate query/mutation
 	for path, methods := range sw.Paths {
+		if isRestActionPath(path) {
+			continue
+		}
 		for m := range methods {
 			method := strings.ToLower(m)
 			base := getBaseNameFromPath(path)
@@ -173,8 +221,9 @@ func mergeSwaggerToGraphql(
 							Args: graphql.FieldConfigArgument{
 								"id": &graphql.ArgumentConfig{Type: graphql.String},
 							},
-							Resolve: restGetByIDResolver(restBaseURL+path, typ),
+							Resolve: resolvers.getByID(path, typ),
 						}
+						addUniqueKeyQueries(queries, sw.Components.Schemas[base].Properties, sw.Components.Schemas[base].UniqueKeys, sw.Components.Schemas[base].SoftDelKey, base, resolvers, path, typ, types, inputTypes)
 					}
 				} else {
 					if typ != nil {
@@ -186,19 +235,23 @@ func mergeSwaggerToGraphql(
 									"total": &graphql.Field{Type: graphql.Int},
 								},
 							}),
-							Args:    buildGraphqlFieldConfigArgument(),
-							Resolve: restListResolver(restBaseURL+path, typ),
+							Args:    buildGraphqlFieldConfigArgument(sw.Components.Schemas[base].Filterable, sw.Components.Schemas[base].Sortable),
+							Resolve: resolvers.list(path, typ, listArgLimits{MaxPageSize: maxPageSize, Fields: sw.Components.Schemas[base].Properties, Sortable: sw.Components.Schemas[base].Sortable}),
 						}
 					}
 				}
 			case "post":
 				if strings.HasSuffix(path, "batch_delete") {
+					args := graphql.FieldConfigArgument{
+						"ids": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+					}
+					if keyTyp := inputTypes[toHungarianInputTypeName(base+"_key")]; keyTyp != nil {
+						args["input"] = &graphql.ArgumentConfig{Type: graphql.NewList(keyTyp)}
+					}
 					mutations["batchDelete"+upperFirst(base)] = &graphql.Field{
-						Type: graphql.Boolean,
-						Args: graphql.FieldConfigArgument{
-							"ids": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
-						},
-						Resolve: restBatchDeleteResolver(restBaseURL + path),
+						Type:    batchDeleteResultType(base),
+						Args:    args,
+						Resolve: restBatchDeleteResolver(resolvers.client, resolvers.baseURL+path, sw.Components.Schemas[base].PrimaryKey),
 					}
 				} else {
 					if typ != nil && inTyp != nil {
@@ -207,7 +260,7 @@ func mergeSwaggerToGraphql(
 							Args: graphql.FieldConfigArgument{
 								"input": &graphql.ArgumentConfig{Type: graphql.NewList(inTyp)},
 							},
-							Resolve: restBatchCreateResolver(restBaseURL+path, typ),
+							Resolve: restBatchCreateResolver(resolvers.client, resolvers.baseURL+path, typ),
 						}
 					}
 				}
@@ -220,7 +273,7 @@ func mergeSwaggerToGraphql(
 								"id":    &graphql.ArgumentConfig{Type: graphql.String},
 								"input": &graphql.ArgumentConfig{Type: inTyp},
 							},
-							Resolve: restUpdateByIDResolver(restBaseURL+path, typ),
+							Resolve: restUpdateByIDResolver(resolvers.client, resolvers.baseURL+path, typ),
 						}
 					} else {
 						// 用 batch_update 的 inputType，如果不存在则 fallback
@@ -233,7 +286,7 @@ func mergeSwaggerToGraphql(
 								Args: graphql.FieldConfigArgument{
 									"input": &graphql.ArgumentConfig{Type: graphql.NewList(batchInTyp)},
 								},
-								Resolve: restBatchUpdateResolver(restBaseURL+path, typ),
+								Resolve: restBatchUpdateResolver(resolvers.client, resolvers.baseURL+path, typ),
 							}
 						} else {
 							mutations["batchUpdate"+upperFirst(base)] = &graphql.Field{
@@ -241,7 +294,7 @@ func mergeSwaggerToGraphql(
 								Args: graphql.FieldConfigArgument{
 									"input": &graphql.ArgumentConfig{Type: graphql.NewList(inTyp)},
 								},
-								Resolve: restBatchUpdateResolver(restBaseURL+path, typ),
+								Resolve: restBatchUpdateResolver(resolvers.client, resolvers.baseURL+path, typ),
 							}
 						}
 					}
@@ -253,7 +306,7 @@ func mergeSwaggerToGraphql(
 						Args: graphql.FieldConfigArgument{
 							"id": &graphql.ArgumentConfig{Type: graphql.String},
 						},
-						Resolve: restDeleteByIDResolver(restBaseURL + path),
+						Resolve: restDeleteByIDResolver(resolvers.client, resolvers.baseURL+path),
 					}
 				}
 			}
@@ -261,20 +314,27 @@ func mergeSwaggerToGraphql(
 	}
 }
 
-// 支持通用参数和filter字符串
-func buildGraphqlFieldConfigArgument() graphql.FieldConfigArgument {
-	return graphql.FieldConfigArgument{
+// 支持通用参数和filter字符串；表限定了可过滤、可排序字段时在参数说明中列出
+func buildGraphqlFieldConfigArgument(filterable, sortable []string) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
 		"page":      &graphql.ArgumentConfig{Type: graphql.Int},
 		"page_size": &graphql.ArgumentConfig{Type: graphql.Int},
 		"order":     &graphql.ArgumentConfig{Type: graphql.String},
 		"fields":    &graphql.ArgumentConfig{Type: graphql.String},
 		"filter":    &graphql.ArgumentConfig{Type: graphql.String}, // 直接字符串
 	}
+	if len(filterable) > 0 {
+		args["filter"].Description = "Filterable fields: " + strings.Join(filterable, ", ")
+	}
+	if len(sortable) > 0 {
+		args["order"].Description = "Sortable fields: " + strings.Join(sortable, ", ")
+	}
+	return args
 }
 
 // ===== RESTful 代理 resolver（核心入口） =====
 
-func restGetByIDResolver(urlTemplate string, typ *graphql.Object) graphql.FieldResolveFn {
+func restGetByIDResolver(client *http.Client, urlTemplate string, typ *graphql.Object) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 		id, ok := p.Args["id"]
 		if !ok {
@@ -293,7 +353,7 @@ func restGetByIDResolver(urlTemplate string, typ *graphql.Object) graphql.FieldR
 				urlStr += "?" + query.Encode()
 			}
 		}
-		resp, err := http.Get(urlStr)
+		resp, err := restRequest(p, client, http.MethodGet, urlStr, nil)
 		if err != nil {
 			log.Printf("restGetByIDResolver failed: %v", err)
 			return nil, err
@@ -310,9 +370,137 @@ func restGetByIDResolver(urlTemplate string, typ *graphql.Object) graphql.FieldR
 	}
 }
 
+// 为每个唯一键生成 userByEmail(email: String!) 形式的查询，映射到 REST 的 ?key=
+func addUniqueKeyQueries(
+	queries graphql.Fields,
+	props map[string]map[string]interface{},
+	uniqueKeys [][]string,
+	softDelKey string,
+	base string,
+	resolvers graphqlResolvers,
+	path string,
+	typ *graphql.Object,
+	types map[string]*graphql.Object,
+	inputTypes map[string]*graphql.InputObject,
+) {
+OUTER:
+	for _, uk := range uniqueKeys {
+		fields := withoutField(uk, softDelKey)
+		if len(fields) == 0 {
+			continue
+		}
+		args := graphql.FieldConfigArgument{}
+		names := make([]string, len(fields))
+		for i, f := range fields {
+			prop, ok := props[f]
+			if !ok {
+				continue OUTER
+			}
+			args[f] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphqlInputTypeBySwagger(prop, f, types, inputTypes))}
+			names[i] = toUpperCamel(f)
+		}
+		name := base + "By" + strings.Join(names, "And")
+		if _, exists := queries[name]; exists {
+			continue
+		}
+		queries[name] = &graphql.Field{
+			Type:    typ,
+			Args:    args,
+			Resolve: resolvers.getByKey(path, fields),
+		}
+	}
+}
+
+func restGetByKeyResolver(client *http.Client, urlTemplate string, keyFields []string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		vals := make([]string, len(keyFields))
+		for i, f := range keyFields {
+			vals[i] = fmt.Sprint(p.Args[f])
+			if len(keyFields) > 1 && strings.Contains(vals[i], ",") {
+				return nil, fmt.Errorf("value of %s must not contain ','", f)
+			}
+		}
+		urlStr := strings.Replace(urlTemplate, "{id}", url.PathEscape(strings.Join(vals, ",")), 1)
+		query := url.Values{queryParamKey: {strings.Join(keyFields, ",")}}
+		if fieldsStr := getLeafFieldsFromResolveParams(p); fieldsStr != "" {
+			query.Set("fields", fieldsStr)
+		}
+		resp, err := restRequest(p, client, http.MethodGet, urlStr+"?"+query.Encode(), nil)
+		if err != nil {
+			log.Printf("restGetByKeyResolver failed: %v", err)
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			b, _ := io.ReadAll(resp.Body)
+			errMsg := resp.Status
+			if len(b) > 0 {
+				errMsg = string(b)
+			}
+			return nil, fmt.Errorf("rest error: %s", errMsg)
+		}
+		var out map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("json decode error: %w", err)
+		}
+		return out, nil
+	}
+}
+
+// graphqlArgError 参数校验错误，errors[].extensions 中带上 code 与参数名
+type graphqlArgError struct {
+	Arg     string
+	Message string
+}
+
+var _ gqlerrors.ExtendedError = (*graphqlArgError)(nil)
+
+func (e *graphqlArgError) Error() string {
+	return e.Message
+}
+
+func (e *graphqlArgError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": "BAD_USER_INPUT", "argument": e.Arg}
+}
+
+// listArgLimits 列表查询的参数约束：分页上限与可排序字段
+type listArgLimits struct {
+	MaxPageSize int
+	Fields      map[string]map[string]interface{}
+	Sortable    []string // 为空时不限制，见 filterfields.go
+}
+
+// validate 校验 page、page_size 与 order，而不是交给 REST 层静默修正或忽略
+func (l listArgLimits) validate(args map[string]interface{}) error {
+	if v, ok := args["page"].(int); ok && v < 1 {
+		return &graphqlArgError{Arg: "page", Message: fmt.Sprintf("page must be >= 1, got %d", v)}
+	}
+	if v, ok := args["page_size"].(int); ok && (v < 1 || (l.MaxPageSize > 0 && v > l.MaxPageSize)) {
+		return &graphqlArgError{Arg: "page_size", Message: fmt.Sprintf("page_size must be between 1 and %d, got %d", l.MaxPageSize, v)}
+	}
+	if v, ok := args["order"].(string); ok && v != "" {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimPrefix(strings.TrimSpace(f), "-")
+			if _, known := l.Fields[f]; !known {
+				return &graphqlArgError{Arg: "order", Message: fmt.Sprintf("unknown order field %q", f)}
+			}
+			if len(l.Sortable) > 0 && !contains(l.Sortable, f) {
+				return &graphqlArgError{Arg: "order", Message: fmt.Sprintf("field %q is not sortable", f)}
+			}
+		}
+	}
+	return nil
+}
+
 // 关键：filter参数直接拆分并与主参数并列拼接
-func restListResolver(urlStr string, typ *graphql.Object) graphql.FieldResolveFn {
+func restListResolver(client *http.Client, urlStr string, typ *graphql.Object, limits listArgLimits) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
+		if err := limits.validate(p.Args); err != nil {
+			return nil, err
+		}
 		// 自动合成 fields 字段
 		if _, ok := p.Args["fields"]; !ok {
 			fieldsStr := getDataLeafFieldsFromResolveParams(p)
@@ -349,7 +537,7 @@ func restListResolver(urlStr string, typ *graphql.Object) graphql.FieldResolveFn
 			finalURL += "?" + strings.Join(params, "&")
 		}
 
-		resp, err := http.Get(finalURL)
+		resp, err := restRequest(p, client, http.MethodGet, finalURL, nil)
 		if err != nil {
 			log.Printf("restListResolver failed: %v", err)
 			return nil, err
@@ -401,7 +589,7 @@ func getDataLeafFieldsFromResolveParams(p graphql.ResolveParams) string {
 	return ""
 }
 
-func restBatchCreateResolver(url string, typ *graphql.Object) graphql.FieldResolveFn {
+func restBatchCreateResolver(client *http.Client, url string, typ *graphql.Object) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 		input, ok := p.Args["input"]
 		if !ok {
@@ -411,7 +599,7 @@ func restBatchCreateResolver(url string, typ *graphql.Object) graphql.FieldResol
 		if err != nil {
 			return nil, fmt.Errorf("marshal input error: %w", err)
 		}
-		resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+		resp, err := restRequest(p, client, http.MethodPost, url, body)
 		if err != nil {
 			log.Printf("restBatchCreateResolver failed: %v", err)
 			return nil, err
@@ -428,8 +616,8 @@ func restBatchCreateResolver(url string, typ *graphql.Object) graphql.FieldResol
 	}
 }
 
-// 批量更新后自动主键in回查最新对象
-func restBatchUpdateResolver(burl string, typ *graphql.Object) graphql.FieldResolveFn {
+// 批量更新，return=representation 由服务端返回更新后的记录
+func restBatchUpdateResolver(client *http.Client, burl string, typ *graphql.Object) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 		input, ok := p.Args["input"]
 		if !ok {
@@ -439,12 +627,11 @@ func restBatchUpdateResolver(burl string, typ *graphql.Object) graphql.FieldReso
 		if err != nil {
 			return nil, fmt.Errorf("marshal input error: %w", err)
 		}
-		req, err := http.NewRequest("PUT", burl, bytes.NewReader(body))
-		if err != nil {
-			return nil, fmt.Errorf("create request error: %w", err)
+		query := url.Values{queryParamReturn: {returnRepresentation}}
+		if fieldsStr := getLeafFieldsFromResolveParams(p); fieldsStr != "" {
+			query.Set("fields", fieldsStr)
 		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := restRequest(p, client, http.MethodPut, burl+"?"+query.Encode(), body)
 		if err != nil {
 			log.Printf("restBatchUpdateResolver failed: %v", err)
 			return nil, err
@@ -459,106 +646,106 @@ func restBatchUpdateResolver(burl string, typ *graphql.Object) graphql.FieldReso
 			}
 			return nil, fmt.Errorf("rest error: %s", errMsg)
 		}
+		var out struct {
+			Data []interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("json decode error: %w", err)
+		}
+		if out.Data == nil {
+			return []interface{}{}, nil
+		}
+		return out.Data, nil
+	}
+}
 
-		// 推断 batch_update schema 名
-		paths := strings.Split(burl, "/")
-		baseName := ""
-		for i := len(paths) - 1; i >= 0; i-- {
-			s := paths[i]
-			if s == "" || s == "batch_update" || strings.HasPrefix(s, "batch") {
-				continue
-			}
-			baseName = s
-			break
-		}
-		batchSchemaName := baseName + "_batch_update"
-		pkList, hasPk := batchUpdatePkMap[batchSchemaName]
-
-		if hasPk && len(pkList) > 0 {
-			// 构造 url param: id_in=1,2,3 或 id_in=...&code_in=...
-			var paramParts []string
-			var inputArr []interface{}
-			switch arr := input.(type) {
-			case []interface{}:
-				inputArr = arr
-			default:
-				return nil, fmt.Errorf("batch update input must be array")
-			}
-			for _, pk := range pkList {
-				var vals []string
-				for _, item := range inputArr {
-					if mp, ok := item.(map[string]interface{}); ok {
-						if val, ok := mp[pk]; ok {
-							vals = append(vals, fmt.Sprintf("%v", val))
-						}
-					}
-				}
-				if len(vals) == 0 {
-					return nil, fmt.Errorf("no values for primary key %s found in input", pk)
-				}
-				paramParts = append(paramParts, pk+"__in="+url.QueryEscape(strings.Join(vals, ",")))
-			}
-			paramStr := strings.Join(paramParts, "&")
-
-			// 推断list url（去掉结尾 /batch_update，用 TrimSuffix，避免 staticcheck S1017）
-			listURL := strings.TrimSuffix(burl, "/batch_update")
-			listURL = strings.TrimSuffix(listURL, "batch_update")
-			listURL = strings.TrimSuffix(listURL, "/")
-
-			queryURL := listURL + "?" + paramStr
+func maskedFieldResolver(name, kind string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		src, _ := p.Source.(map[string]interface{})
+		return maskValue(kind, src[name]), nil
+	}
+}
 
-			getResp, err := http.Get(queryURL)
-			if err != nil {
-				return nil, fmt.Errorf("get after batch update failed: %w", err)
-			}
-			defer getResp.Body.Close()
-			if getResp.StatusCode < 200 || getResp.StatusCode >= 300 {
-				return nil, fmt.Errorf("rest error: %s", getResp.Status)
+// 去重展开唯一键字段
+func flattenKeys(keys [][]string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		for _, f := range k {
+			if !seen[f] {
+				seen[f] = true
+				out = append(out, f)
 			}
-			var out map[string]interface{}
-			if err := json.NewDecoder(getResp.Body).Decode(&out); err != nil {
-				return nil, fmt.Errorf("json decode error: %w", err)
-			}
-			if data, ok := out["data"]; ok {
-				return data, nil
-			}
-			return out, nil
 		}
-
-		// fallback 老逻辑
-		var out []map[string]interface{}
-		outDecodeErr := json.NewDecoder(resp.Body).Decode(&out)
-		if outDecodeErr == nil {
-			return out, nil
-		}
-		return []map[string]interface{}{}, nil
 	}
+	return out
+}
+
+// 批量删除结果：删除条数与逐条结果
+func batchDeleteResultType(base string) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: base + "BatchDeleteResult",
+		Fields: graphql.Fields{
+			"deleted_count": &graphql.Field{Type: graphql.Int},
+			"results": &graphql.Field{Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+				Name: base + "BatchDeleteRecordResult",
+				Fields: graphql.Fields{
+					"index":  &graphql.Field{Type: graphql.Int},
+					"status": &graphql.Field{Type: graphql.String},
+					"id":     &graphql.Field{Type: graphql.String},
+					"error":  &graphql.Field{Type: graphql.String},
+				},
+			}))},
+		},
+	})
 }
 
-func restBatchDeleteResolver(url string) graphql.FieldResolveFn {
+// 批量删除，ids 与 input（主键或唯一键对象）可同时提供，选择了 results 时请求逐条结果
+func restBatchDeleteResolver(client *http.Client, burl string, pk string) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
-		ids, ok := p.Args["ids"]
-		if !ok {
-			return false, fmt.Errorf("missing ids argument")
+		ids, _ := p.Args["ids"].([]interface{})
+		keys, _ := p.Args["input"].([]interface{})
+		items := append([]interface{}{}, ids...)
+		if len(keys) > 0 {
+			// REST 接口不接受主键与对象混排，主键统一包装成对象
+			for i, id := range items {
+				items[i] = map[string]interface{}{pk: id}
+			}
+			items = append(items, keys...)
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("missing ids or input argument")
 		}
-		body, err := json.Marshal(ids)
+		body, err := json.Marshal(items)
 		if err != nil {
-			return false, fmt.Errorf("marshal ids error: %w", err)
+			return nil, fmt.Errorf("marshal input error: %w", err)
+		}
+		if strings.Contains(","+getLeafFieldsFromResolveParams(p)+",", ",results,") {
+			burl += "?" + url.Values{queryParamResults: {resultsPerRecord}}.Encode()
 		}
-		resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+		resp, err := restRequest(p, client, http.MethodPost, burl, body)
 		if err != nil {
 			log.Printf("restBatchDeleteResolver failed: %v", err)
-			return false, err
+			return nil, err
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return false, fmt.Errorf("rest error: %s", resp.Status)
+			b, _ := io.ReadAll(resp.Body)
+			errMsg := resp.Status
+			if len(b) > 0 {
+				errMsg = string(b)
+			}
+			return nil, fmt.Errorf("rest error: %s", errMsg)
 		}
-		return true, nil
+		var out map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("json decode error: %w", err)
+		}
+		return out, nil
 	}
 }
 
-func restUpdateByIDResolver(urlTemplate string, typ *graphql.Object) graphql.FieldResolveFn {
+func restUpdateByIDResolver(client *http.Client, urlTemplate string, typ *graphql.Object) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 		id, ok := p.Args["id"]
 		if !ok {
@@ -575,17 +762,12 @@ func restUpdateByIDResolver(urlTemplate string, typ *graphql.Object) graphql.Fie
 			query.Set("fields", fieldsStr)
 		}
 
-		// 1. 执行 PUT 更新
+		query.Set(queryParamReturn, returnRepresentation)
 		body, err := json.Marshal(input)
 		if err != nil {
 			return nil, fmt.Errorf("marshal input error: %w", err)
 		}
-		req, err := http.NewRequest("PUT", urlStr, bytes.NewReader(body))
-		if err != nil {
-			return nil, fmt.Errorf("create request error: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := restRequest(p, client, http.MethodPut, urlStr+"?"+query.Encode(), body)
 		if err != nil {
 			log.Printf("restUpdateByIDResolver failed: %v", err)
 			return nil, err
@@ -599,44 +781,24 @@ func restUpdateByIDResolver(urlTemplate string, typ *graphql.Object) graphql.Fie
 			}
 			return nil, fmt.Errorf("rest error: %s", errMsg)
 		}
-
-		// 2. 再 GET 一次最新对象
-		getUrl := urlStr
-		// 如果 urlStr 已经有 query 参数，去掉，重组 GET url
-		if idx := strings.Index(getUrl, "?"); idx > -1 {
-			getUrl = getUrl[:idx]
-		}
-		if fieldsStr != "" {
-			getUrl += "?fields=" + url.QueryEscape(fieldsStr)
-		}
-		getResp, err := http.Get(getUrl)
-		if err != nil {
-			return nil, fmt.Errorf("get after update failed: %w", err)
-		}
-		defer getResp.Body.Close()
-		if getResp.StatusCode < 200 || getResp.StatusCode >= 300 {
-			return nil, fmt.Errorf("rest error: %s", getResp.Status)
+		var out struct {
+			Data map[string]interface{} `json:"data"`
 		}
-		var out map[string]interface{}
-		if err := json.NewDecoder(getResp.Body).Decode(&out); err != nil {
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 			return nil, fmt.Errorf("json decode error: %w", err)
 		}
-		return out, nil
+		return out.Data, nil
 	}
 }
 
-func restDeleteByIDResolver(urlTemplate string) graphql.FieldResolveFn {
+func restDeleteByIDResolver(client *http.Client, urlTemplate string) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 		id, ok := p.Args["id"]
 		if !ok {
 			return false, fmt.Errorf("missing id argument")
 		}
 		urlStr := strings.Replace(urlTemplate, "{id}", fmt.Sprintf("%v", id), 1)
-		req, err := http.NewRequest("DELETE", urlStr, nil)
-		if err != nil {
-			return false, fmt.Errorf("create request error: %w", err)
-		}
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := restRequest(p, client, http.MethodDelete, urlStr, nil)
 		if err != nil {
 			log.Printf("restDeleteByIDResolver failed: %v", err)
 			return false, err
@@ -649,6 +811,26 @@ func restDeleteByIDResolver(urlTemplate string) graphql.FieldResolveFn {
 	}
 }
 
+// restRequest 以 GraphQL 请求的 context 调用 REST 接口
+func restRequest(p graphql.ResolveParams, client *http.Client, method, urlStr string, body []byte) (*http.Response, error) {
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return client.Do(req)
+}
+
 // ===== 工具函数 =====
 
 // graphqlTypeBySwagger 支持基础类型、$ref
@@ -749,6 +931,18 @@ func extractTypeFromRef(ref string) string {
 	return parts[len(parts)-1]
 }
 
+// REST 扩展动作路径（非标准 CRUD），不自动生成 GraphQL 字段
+var restActionSuffixes = []string{"/batch_get", "/upsert", "/update_where", "/delete_where", "/stats", "/bulk_jobs", "/import", "/timeseries", "/aggregate", "/aggregate_pipeline", "/export"}
+
+func isRestActionPath(path string) bool {
+	for _, suffix := range restActionSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func getBaseNameFromPath(path string) string {
 	parts := strings.Split(path, "/")
 	for i := len(parts) - 1; i >= 0; i-- {
@@ -761,6 +955,15 @@ func getBaseNameFromPath(path string) string {
 	return ""
 }
 
+// user_name => UserName
+func toUpperCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i, p := range parts {
+		parts[i] = upperFirst(p)
+	}
+	return strings.Join(parts, "")
+}
+
 func upperFirst(s string) string {
 	if len(s) == 0 {
 		return s
@@ -0,0 +1,255 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// --------- GraphQL 直连 resolver ---------
+//
+// 与 REST 一起挂载时，GraphQL 查询不再经 localhost HTTP 回调 REST 接口，而是直接调用数据库适配器：
+//
+//	graphql:
+//	  resolver: native      # native（默认，需同时挂载 REST）| proxy（通过 HTTP 调用 RestBaseURL）
+//
+// 单条查询、唯一键查询与列表查询直接访问适配器，沿用 REST 的字段别名、字段策略、strict_filters、
+// 会话设置、参照表名称与编码值名称等处理，结果与 REST 一致（不含请求合并与 lookup）。
+// 写操作仍交给 REST 处理函数以保持默认值、校验与钩子等逻辑一致，但在进程内调用，不经过网络。
+// 调用者的请求头在两种调用中都会带上；配置了 auth.jwt、auth.api_keys、auth.roles 时直连查询同样校验 token、key 与角色授权并按 row_filter 限定。未挂载 REST 或配置为 proxy 时使用 HTTP 代理。
+
+const (
+	graphqlResolverNative = "native"
+	graphqlResolverProxy  = "proxy"
+
+	// 进程内调用 REST 时使用的地址，只用于拼接请求，不会发出网络请求
+	inProcessBaseURL = "http://in-process"
+)
+
+// graphqlResolvers 决定 schema 中各操作的 resolver：dm 非空时查询直连适配器，写操作通过 client 调用 REST
+type graphqlResolvers struct {
+	baseURL string
+	client  *http.Client
+	dm      *databaseManager
+}
+
+func proxyResolvers(restBaseURL string) graphqlResolvers {
+	return graphqlResolvers{baseURL: restBaseURL, client: http.DefaultClient}
+}
+
+func nativeResolvers(dm *databaseManager, router http.Handler) graphqlResolvers {
+	return graphqlResolvers{baseURL: inProcessBaseURL, client: &http.Client{Transport: inProcessTransport{router}}, dm: dm}
+}
+
+func (r graphqlResolvers) getByID(path string, typ *graphql.Object) graphql.FieldResolveFn {
+	if r.dm == nil {
+		return restGetByIDResolver(r.client, r.baseURL+path, typ)
+	}
+	dbName, table := restPathTable(path)
+	return r.dm.nativeGetResolver(dbName, table, nil)
+}
+
+func (r graphqlResolvers) getByKey(path string, keyFields []string) graphql.FieldResolveFn {
+	if r.dm == nil {
+		return restGetByKeyResolver(r.client, r.baseURL+path, keyFields)
+	}
+	dbName, table := restPathTable(path)
+	return r.dm.nativeGetResolver(dbName, table, keyFields)
+}
+
+func (r graphqlResolvers) list(path string, typ *graphql.Object, limits listArgLimits) graphql.FieldResolveFn {
+	if r.dm == nil {
+		return restListResolver(r.client, r.baseURL+path, typ, limits)
+	}
+	dbName, table := restPathTable(path)
+	return r.dm.nativeListResolver(dbName, table, limits)
+}
+
+// restPathTable 从 swagger 路径 {prefix}/{库别名}/{表别名}[/{id}] 中取出库别名与表别名
+func restPathTable(path string) (string, string) {
+	parts := strings.Split(strings.TrimSuffix(path, "/{id}"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// inProcessTransport 把请求直接交给 gin 路由处理，并带上 GraphQL 调用者的请求头
+type inProcessTransport struct {
+	handler http.Handler
+}
+
+func (t inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if incoming, ok := req.Context().Value(incomingHeaderKey{}).(http.Header); ok {
+		for k, v := range incoming {
+			if _, set := req.Header[k]; !set && k != "Content-Length" && k != "Accept-Encoding" {
+				req.Header[k] = v
+			}
+		}
+	}
+	req.RequestURI = req.URL.RequestURI()
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func incomingHeader(ctx context.Context, name string) string {
+	if h, ok := ctx.Value(incomingHeaderKey{}).(http.Header); ok {
+		return h.Get(name)
+	}
+	return ""
+}
+
+// nativeGetResolver 单条查询；keyFields 为空时按 id 参数解析（同 REST 的 :id），否则按唯一键查询，找不到时返回 null
+func (dm *databaseManager) nativeGetResolver(dbName, table string, keyFields []string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		adapter, tc, err := dm.getAdapterAndTableConfig(dbName, table)
+		if err != nil {
+			return nil, err
+		}
+		if status, _, msg := dm.maintenanceError(dbName, tc, false); status != 0 {
+			return nil, errors.New(msg)
+		}
+		ctx, err := dm.graphqlRowScope(resolveContext(p), dbName, tc)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel, err := dm.sessionContext(ctx, dbName, tc, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		fields := tc.physicalFieldList(getLeafFieldsFromResolveParams(p))
+		var record map[string]interface{}
+		if len(keyFields) == 0 {
+			id, ok := p.Args["id"]
+			if !ok {
+				return nil, fmt.Errorf("missing id argument")
+			}
+			record, _, err = getOneByResolvedID(ctx, adapter, tc, fmt.Sprint(id), fields, nil)
+		} else {
+			filter := make(map[string]interface{}, len(keyFields))
+			for i, f := range tc.physicalFieldNames(keyFields) {
+				filter[f] = p.Args[keyFields[i]]
+			}
+			record, err = getOneScoped(ctx, adapter, tc, filter, fields, nil)
+			if isNotFoundErr(err) {
+				return nil, nil
+			}
+		}
+		if err != nil {
+			if isNotFoundErr(err) {
+				return nil, fmt.Errorf("Record not found")
+			}
+			return nil, err
+		}
+		dm.applyLabelJoins(ctx, dbName, tc, record)
+		dm.labelCodedValues(ctx, incomingHeader(ctx, "Accept-Language"), dbName, tc, record)
+		record = fixPkFieldToString(record, tc.PrimaryKey).(map[string]interface{})
+		return tc.renderRecord(record), nil
+	}
+}
+
+// nativeListResolver 列表查询，参数与 REST 列表一致：filter 为查询字符串
+func (dm *databaseManager) nativeListResolver(dbName, table string, limits listArgLimits) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if err := limits.validate(p.Args); err != nil {
+			return nil, err
+		}
+		adapter, tc, err := dm.getAdapterAndTableConfig(dbName, table)
+		if err != nil {
+			return nil, err
+		}
+		if status, _, msg := dm.maintenanceError(dbName, tc, false); status != 0 {
+			return nil, errors.New(msg)
+		}
+		query := url.Values{}
+		if v, _ := p.Args["filter"].(string); strings.Trim(v, "&") != "" {
+			if query, err = url.ParseQuery(strings.Trim(v, "&")); err != nil {
+				return nil, &graphqlArgError{Arg: "filter", Message: fmt.Sprintf("invalid filter: %v", err)}
+			}
+		}
+		// 与 REST 中间件一致：filter[...] 改写为内部形式，strict_filters 的表只接受这种形式
+		query, _, bad := normalizeFilterParams(query, dm.strictFilters(tc))
+		if bad != "" {
+			return nil, &graphqlArgError{Arg: "filter", Message: "Unknown query parameter " + bad + ", filters must be passed as filter[field__op]=value"}
+		}
+		if err := tc.checkFilterFields(query); err != nil {
+			return nil, &graphqlArgError{Arg: "filter", Message: err.Error()}
+		}
+		page, pageSize := dm.config.DefaultPage, dm.config.DefaultPageSize
+		if v, ok := p.Args["page"].(int); ok {
+			page = v
+		}
+		if v, ok := p.Args["page_size"].(int); ok {
+			pageSize = min(v, dm.config.MaxPageSize)
+		}
+		for _, k := range []string{queryParamOrder, queryParamFields} {
+			if v, ok := p.Args[k].(string); ok && v != "" {
+				query.Set(k, v)
+			}
+		}
+		if query.Get(queryParamFields) == "" {
+			if fields := getDataLeafFieldsFromResolveParams(p); fields != "" {
+				query.Set(queryParamFields, fields)
+			}
+		}
+		query.Set(queryParamPage, strconv.Itoa(page))
+		query.Set(queryParamPageSize, strconv.Itoa(pageSize))
+		ctx, err := dm.graphqlRowScope(resolveContext(p), dbName, tc)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel, err := dm.sessionContext(ctx, dbName, tc, query)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		query = tc.physicalQuery(query)
+		if err := tc.checkSortFields(query.Get(queryParamOrder)); err != nil {
+			return nil, &graphqlArgError{Arg: "order", Message: err.Error()}
+		}
+		params := listParams{
+			Page:         page,
+			PageSize:     pageSize,
+			Fields:       query.Get(queryParamFields),
+			Order:        query.Get(queryParamOrder),
+			QueryFilters: query,
+		}
+		degraded, costErr := dm.checkListCost(ctx, adapter, tc, &params, false)
+		if costErr != nil {
+			return nil, costErr
+		}
+		data, total, err := adapter.List(ctx, tc, params)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			data = []map[string]interface{}{}
+		}
+		total = dm.listTotal(ctx, dbName, table, query, total)
+		dm.applyLabelJoins(ctx, dbName, tc, data...)
+		dm.labelCodedValues(ctx, incomingHeader(ctx, "Accept-Language"), dbName, tc, data...)
+		data = fixPkFieldToString(data, tc.PrimaryKey).([]map[string]interface{})
+		result := map[string]interface{}{"total": total, "data": tc.renderRecords(data)}
+		if degraded != nil {
+			// 降级时不统计 total
+			result["total"] = nil
+		}
+		return result, nil
+	}
+}
+
+func resolveContext(p graphql.ResolveParams) context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
+}
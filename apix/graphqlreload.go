@@ -0,0 +1,229 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"gopkg.in/yaml.v3"
+)
+
+// --------- GraphQL schema 热更新 ---------
+//
+// GraphQL schema 由各库的 swagger.yaml 生成，重新生成 swagger（如新增表、字段别名）后无需重启即可刷新：
+//
+//	graphql:
+//	  watch_interval: 10s   # 定期检查 swagger.yaml 是否变化，变化后自动重建；为空时不检查
+//
+// 管理接口（受 operation_roles.graphql_reload 控制，默认 admin）：
+//
+//	POST /api/admin/graphql/reload             重建全部库的 schema
+//	POST /api/admin/graphql/reload?database=test   只重建指定库
+//
+// 新 schema 构建成功后才原子替换 handler，进行中的请求继续使用旧 schema；构建失败时保留旧 schema。
+
+type graphqlOptions struct {
+	MaxPageSize   int
+	WatchInterval time.Duration
+	Resolver      string
+}
+
+// readGraphqlOptions 读取 _base.yaml 中的 max_page_size（与 REST 层使用同一上限）、graphql.watch_interval 与 graphql.resolver
+func readGraphqlOptions(cfgs string) graphqlOptions {
+	var conf struct {
+		MaxPageSize int `yaml:"max_page_size"`
+		Graphql     struct {
+			WatchInterval string `yaml:"watch_interval"`
+			Resolver      string `yaml:"resolver"`
+		} `yaml:"graphql"`
+	}
+	if data, err := os.ReadFile(filepath.Join(cfgs, "_base.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &conf)
+	}
+	opts := graphqlOptions{MaxPageSize: conf.MaxPageSize, Resolver: conf.Graphql.Resolver}
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = defaultMaxPageSize
+	}
+	if conf.Graphql.WatchInterval != "" {
+		d, err := time.ParseDuration(conf.Graphql.WatchInterval)
+		if err != nil {
+			log.Printf("invalid graphql.watch_interval %q: %v", conf.Graphql.WatchInterval, err)
+		} else {
+			opts.WatchInterval = d
+		}
+	}
+	return opts
+}
+
+type graphqlEndpoint struct {
+	path        string
+	cfgDir      string
+	resolvers   graphqlResolvers
+	maxPageSize int
+
+	mu        sync.Mutex // 串行化重建
+	handler   atomic.Value
+	schema    atomic.Pointer[graphql.Schema] // 导出 SDL 使用，见 schemaexport.go
+	signature string
+}
+
+var (
+	graphqlEndpointsMu sync.Mutex
+	graphqlEndpoints   []*graphqlEndpoint
+)
+
+func registerGraphqlEndpoint(ep *graphqlEndpoint) {
+	graphqlEndpointsMu.Lock()
+	defer graphqlEndpointsMu.Unlock()
+	graphqlEndpoints = append(graphqlEndpoints, ep)
+}
+
+func unregisterGraphqlEndpoint(ep *graphqlEndpoint) {
+	graphqlEndpointsMu.Lock()
+	defer graphqlEndpointsMu.Unlock()
+	for i, e := range graphqlEndpoints {
+		if e == ep {
+			graphqlEndpoints = append(graphqlEndpoints[:i], graphqlEndpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+func listGraphqlEndpoints() []*graphqlEndpoint {
+	graphqlEndpointsMu.Lock()
+	defer graphqlEndpointsMu.Unlock()
+	return append([]*graphqlEndpoint{}, graphqlEndpoints...)
+}
+
+func (ep *graphqlEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 调用者的请求头随 context 传给 resolver，直连查询的 gorm scope 与进程内 REST 调用使用
+	r = r.WithContext(context.WithValue(r.Context(), incomingHeaderKey{}, r.Header))
+	ep.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// reload 重建 schema，成功后替换 handler
+func (ep *graphqlEndpoint) reload() error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	sig := swaggerSignature(ep.cfgDir)
+	schema, err := buildGraphqlSchema(ep.cfgDir, ep.resolvers, ep.maxPageSize)
+	if err != nil {
+		return err
+	}
+	ep.schema.Store(schema)
+	ep.handler.Store(newGraphqlHandler(schema))
+	ep.signature = sig
+	return nil
+}
+
+// boundTo 端点的直连 resolver 是否使用 dm
+func (ep *graphqlEndpoint) boundTo(dm *databaseManager) bool {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return ep.resolvers.dm == dm
+}
+
+// replace 换用新的 resolver 与已构建的 schema，配置热切换时使用，见 configswap.go
+func (ep *graphqlEndpoint) replace(resolvers graphqlResolvers, schema *graphql.Schema) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.resolvers = resolvers
+	ep.schema.Store(schema)
+	ep.handler.Store(newGraphqlHandler(schema))
+	ep.signature = swaggerSignature(ep.cfgDir)
+}
+
+// reloadIfChanged swagger.yaml 有变化时重建
+func (ep *graphqlEndpoint) reloadIfChanged() {
+	ep.mu.Lock()
+	changed := swaggerSignature(ep.cfgDir) != ep.signature
+	ep.mu.Unlock()
+	if !changed {
+		return
+	}
+	if err := ep.reload(); err != nil {
+		log.Printf("[GraphQL] reload %s failed, keeping previous schema: %v", ep.path, err)
+		return
+	}
+	log.Printf("[GraphQL] Reloaded %s", ep.path)
+}
+
+// swaggerSignature 汇总目录下 swagger.yaml 的路径、大小与修改时间
+func swaggerSignature(cfgDir string) string {
+	var parts []string
+	_ = filepath.WalkDir(cfgDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, "swagger.yaml") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			parts = append(parts, fmt.Sprintf("%s:%d:%d", p, info.Size(), info.ModTime().UnixNano()))
+		}
+		return nil
+	})
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
+
+// watchGraphqlSchemas 按间隔检查所有已注册的 GraphQL 端点，返回停止函数
+func watchGraphqlSchemas(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, ep := range listGraphqlEndpoints() {
+					ep.reloadIfChanged()
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (dm *databaseManager) handleGraphqlReload(c *gin.Context) {
+	if !dm.authorize(c, nil, opGraphqlReload) {
+		return
+	}
+	database := c.Query("database")
+	var reloaded []string
+	failed := map[string]string{}
+	for _, ep := range listGraphqlEndpoints() {
+		if database != "" && path.Base(ep.path) != database {
+			continue
+		}
+		if err := ep.reload(); err != nil {
+			failed[ep.path] = err.Error()
+			continue
+		}
+		reloaded = append(reloaded, ep.path)
+	}
+	if len(reloaded) == 0 && len(failed) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No GraphQL endpoint found for database: " + database})
+		return
+	}
+	if len(failed) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"reloaded": reloaded, "errors": failed})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reloaded": reloaded})
+}
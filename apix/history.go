@@ -0,0 +1,442 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 行版本历史 ---------
+//
+// 表配置开启后，每次更新/删除前把受影响记录的旧版本写入影子表（SQL 库）：
+//
+//	history:
+//	  enabled: true
+//	  table: ""            # 默认 <表名>_history，不存在时按原表结构自动创建
+//	  created_field: ""    # 记录创建时间列，为空时按 created_time、created_at 等常见列名识别
+//
+// 影子表在原表列之外增加 history_op（update/delete，软删除记为 delete）与 history_at（被替换的时间），
+// 写入通过 gorm 回调完成，与更新/删除处于同一事务，试运行回滚时历史一并回滚。
+//
+//	GET /api/rest/:database/:table/:id/history          旧版本列表，按 history_at 倒序，支持 page/page_size
+//	GET /api/rest/:database/:table/:id?as_of=2025-06-01T00:00:00Z   读取指定时刻的版本
+//
+// as_of 取该时刻之后第一次被替换前的版本，之后没有变更则返回当前记录；as_of 早于该版本的创建时间
+// （created_field）时记录尚不存在，返回 404。影子表只记录旧版本，表中没有创建时间列时无法判断，返回最早的已知版本。
+// as_of 只用于单条读取，列表请求带 as_of 返回 400。
+// :id 按 id_resolution 解析，当前记录不存在或不在调用者的 row_filter 范围内时返回 404；
+// 影子表的读取同样按原表的 row_filter 过滤。
+
+const (
+	queryParamAsOf = "as_of"
+
+	historyColumnOp = "history_op"
+	historyColumnAt = "history_at"
+	historyOpUpdate = "update"
+	historyOpDelete = "delete"
+)
+
+type tableHistoryConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Table        string `mapstructure:"table"`
+	CreatedField string `mapstructure:"created_field"`
+}
+
+// 未配置 created_field 时识别的创建时间列
+var historyCreatedFieldCandidates = []string{"created_time", "created_at", "create_time", "create_at", "gmt_create", "gmt_created"}
+
+func (tc *tableConfig) historyTable() string {
+	if tc.History.Table != "" {
+		return tc.History.Table
+	}
+	return tc.Name + "_history"
+}
+
+// historyReader 为可选能力：读取行版本历史
+type historyReader interface {
+	History(ctx context.Context, tc *tableConfig, pk interface{}, page, pageSize int) ([]map[string]interface{}, int64, error)
+	// VersionAfter 返回 t 之后第一次被替换前的版本，没有则返回 nil
+	VersionAfter(ctx context.Context, tc *tableConfig, pk interface{}, t time.Time) (map[string]interface{}, error)
+}
+
+// ---- 写入：gorm 回调 ----
+
+type historyTable struct {
+	tc      *tableConfig
+	columns []string
+}
+
+// historyRecorder 按表名记录开启了历史的表，注册在单个 gorm.DB 上
+type historyRecorder struct {
+	tables map[string]historyTable
+}
+
+// setupHistory 为开启历史的表创建影子表并注册回调
+func (dm *databaseManager) setupHistory() error {
+	for name, dbCfg := range dm.config.Databases {
+		var tables []*tableConfig
+		for i := range dbCfg.Tables {
+			if dbCfg.Tables[i].History.Enabled {
+				tables = append(tables, &dbCfg.Tables[i])
+			}
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		var dbs []*gorm.DB
+		switch a := dm.adapters[name].(type) {
+		case *gormAdapter:
+			dbs = []*gorm.DB{a.db}
+		case *shardedAdapter:
+			for _, s := range a.shards {
+				dbs = append(dbs, s.db)
+			}
+		default:
+			return fmt.Errorf("history is only supported on SQL databases, %s is %s", name, dbCfg.Type)
+		}
+		for _, db := range dbs {
+			if err := registerHistory(db, tables); err != nil {
+				return fmt.Errorf("failed to set up history for %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func registerHistory(db *gorm.DB, tables []*tableConfig) error {
+	h := &historyRecorder{tables: map[string]historyTable{}}
+	for _, tc := range tables {
+		if tc.PrimaryKey == "" {
+			return fmt.Errorf("table %s: history requires primary_key", tc.Alias)
+		}
+		columnTypes, err := db.Migrator().ColumnTypes(tc.Name)
+		if err != nil {
+			return err
+		}
+		columns := make([]string, len(columnTypes))
+		for i, ct := range columnTypes {
+			columns[i] = ct.Name()
+		}
+		if tc.History.CreatedField == "" {
+			for _, cand := range historyCreatedFieldCandidates {
+				if slices.Contains(columns, cand) {
+					tc.History.CreatedField = cand
+					break
+				}
+			}
+		} else if !slices.Contains(columns, tc.History.CreatedField) {
+			return fmt.Errorf("table %s: history created_field %s is not a column", tc.Alias, tc.History.CreatedField)
+		}
+		if err := ensureHistoryTable(db, tc); err != nil {
+			return fmt.Errorf("table %s: %w", tc.Alias, err)
+		}
+		h.tables[tc.Name] = historyTable{tc: tc, columns: columns}
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("ego:history_update", h.record(historyOpUpdate)); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("ego:history_delete", h.record(historyOpDelete))
+}
+
+// ensureHistoryTable 按原表结构创建影子表（不带约束），并在主键与 history_at 上建索引
+func ensureHistoryTable(db *gorm.DB, tc *tableConfig) error {
+	name := tc.historyTable()
+	if db.Migrator().HasTable(name) {
+		return nil
+	}
+	stmt := db.Session(&gorm.Session{NewDB: true}).Statement
+	table, source := stmt.Quote(name), stmt.Quote(tc.Name)
+	var stmts []string
+	switch db.Dialector.Name() {
+	case "sqlserver":
+		stmts = []string{
+			fmt.Sprintf("SELECT * INTO %s FROM %s WHERE 1 = 0", table, source),
+			fmt.Sprintf("ALTER TABLE %s ADD %s VARCHAR(16), %s DATETIME2", table, stmt.Quote(historyColumnOp), stmt.Quote(historyColumnAt)),
+		}
+	case "mysql", "postgres", "sqlite", "snowflake":
+		timeType := map[string]string{"mysql": "DATETIME(6)", "postgres": "TIMESTAMP", "sqlite": "DATETIME", "snowflake": "TIMESTAMP_NTZ"}[db.Dialector.Name()]
+		stmts = []string{
+			fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0", table, source),
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s VARCHAR(16)", table, stmt.Quote(historyColumnOp)),
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, stmt.Quote(historyColumnAt), timeType),
+		}
+	default:
+		return fmt.Errorf("history is not supported on %s", db.Dialector.Name())
+	}
+	if db.Dialector.Name() != "snowflake" {
+		stmts = append(stmts, createIndexStatement(db, name, []string{tc.PrimaryKey, historyColumnAt}))
+	}
+	for _, s := range stmts {
+		if err := db.Exec(s).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record 在 UPDATE/DELETE 执行前，用同一 WHERE 条件把旧版本复制到影子表
+func (h *historyRecorder) record(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		t, ok := h.tables[db.Statement.Table]
+		if !ok {
+			return
+		}
+		where, ok := db.Statement.Clauses["WHERE"]
+		if !ok {
+			// 无条件的更新/删除由 gorm 拒绝
+			return
+		}
+		kind := op
+		if op == historyOpUpdate && t.tc.SoftDeleteKey != "" {
+			if data, ok := db.Statement.Dest.(map[string]interface{}); ok {
+				if _, set := data[t.tc.SoftDeleteKey]; set {
+					kind = historyOpDelete
+				}
+			}
+		}
+		tx := db.Session(&gorm.Session{NewDB: true})
+		quoted := make([]string, len(t.columns))
+		for i, col := range t.columns {
+			quoted[i] = tx.Statement.Quote(col)
+		}
+		cols := strings.Join(quoted, ", ")
+		source := tx.Table(t.tc.Name).Select(cols+", ?, ?", kind, time.Now().UTC()).Clauses(where.Expression)
+		sql := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) ?", tx.Statement.Quote(t.tc.historyTable()), cols,
+			tx.Statement.Quote(historyColumnOp), tx.Statement.Quote(historyColumnAt))
+		if err := tx.Exec(sql, source).Error; err != nil {
+			db.AddError(fmt.Errorf("write history failed: %w", err))
+		}
+	}
+}
+
+// ---- 读取 ----
+
+func (a *gormAdapter) History(ctx context.Context, tc *tableConfig, pk interface{}, page, pageSize int) ([]map[string]interface{}, int64, error) {
+	var rows []map[string]interface{}
+	var total int64
+	err := a.read(ctx, func(db *gorm.DB) error {
+		versions := func() *gorm.DB {
+			return db.Table(tc.historyTable()).Where(fmt.Sprintf("%s = ?", tc.PrimaryKey), pk)
+		}
+		if err := versions().Count(&total).Error; err != nil {
+			return err
+		}
+		return versions().Order(clause.OrderByColumn{Column: clause.Column{Name: historyColumnAt}, Desc: true}).
+			Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error
+	})
+	return rows, total, err
+}
+
+func (a *gormAdapter) VersionAfter(ctx context.Context, tc *tableConfig, pk interface{}, t time.Time) (map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := a.read(ctx, func(db *gorm.DB) error {
+		return db.Table(tc.historyTable()).
+			Where(fmt.Sprintf("%s = ? AND %s > ?", tc.PrimaryKey, historyColumnAt), pk, t.UTC()).
+			Order(historyColumnAt).Limit(1).Find(&rows).Error
+	})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+func (a *shardedAdapter) History(ctx context.Context, tc *tableConfig, pk interface{}, page, pageSize int) ([]map[string]interface{}, int64, error) {
+	var all []map[string]interface{}
+	var total int64
+	for _, s := range a.shards {
+		rows, n, err := s.History(ctx, tc, pk, 1, page*pageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		all = append(all, rows...)
+		total += n
+	}
+	sort.SliceStable(all, func(i, j int) bool { return historyTime(all[i]).After(historyTime(all[j])) })
+	start := (page - 1) * pageSize
+	if start >= len(all) {
+		return nil, total, nil
+	}
+	end := start + pageSize
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], total, nil
+}
+
+func (a *shardedAdapter) VersionAfter(ctx context.Context, tc *tableConfig, pk interface{}, t time.Time) (map[string]interface{}, error) {
+	var earliest map[string]interface{}
+	for _, s := range a.shards {
+		row, err := s.VersionAfter(ctx, tc, pk, t)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil && (earliest == nil || historyTime(row).Before(historyTime(earliest))) {
+			earliest = row
+		}
+	}
+	return earliest, nil
+}
+
+func historyTime(row map[string]interface{}) time.Time {
+	t, _ := asTime(row[historyColumnAt], true)
+	return t
+}
+
+// recordAsOf 返回记录在 t 时刻的版本，t 早于记录创建时间时返回 not-found
+func recordAsOf(ctx context.Context, hr historyReader, adapter databaseAdapter, tc *tableConfig, pk interface{}, t time.Time, fields string) (map[string]interface{}, error) {
+	version, err := hr.VersionAfter(ctx, tc, pk, t)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		// 取整条记录，判断创建时间后再按 fields 裁剪
+		if version, err = adapter.GetOne(ctx, tc, map[string]interface{}{tc.PrimaryKey: pk}, ""); err != nil {
+			return nil, err
+		}
+	}
+	if cf := tc.History.CreatedField; cf != "" {
+		if created, ok := asTime(version[cf], true); ok && created.After(t) {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+	delete(version, historyColumnOp)
+	delete(version, historyColumnAt)
+	if fields != "" {
+		selected := map[string]interface{}{}
+		for _, f := range strings.Split(fields, ",") {
+			f = strings.TrimSpace(f)
+			if v, ok := version[f]; ok {
+				selected[f] = v
+			}
+		}
+		version = selected
+	}
+	return version, nil
+}
+
+func parseAsOf(v string) (time.Time, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, ok := asTime(v, true); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %s, expected RFC3339 time or unix seconds", queryParamAsOf, v)
+}
+
+// historyKey 把 :id 解析为主键值；当前记录不可见（不存在或被 row_filter 排除）时返回 not-found，
+// 不按原始 id 读取影子表，避免读到其他租户的历史
+func historyKey(ctx context.Context, adapter databaseAdapter, tc *tableConfig, id string) (interface{}, error) {
+	record, _, err := getOneByResolvedID(ctx, adapter, tc, id, tc.PrimaryKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return record[tc.PrimaryKey], nil
+}
+
+// writeHistoryKeyError 记录不可见时返回 404，缺少 row_filter 所需的 claim 时返回 403
+func writeHistoryKeyError(c *gin.Context, err error) {
+	var scopeErr *rowFilterError
+	switch {
+	case isNotFoundErr(err):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	case errors.As(err, &scopeErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": scopeErr.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve record: " + err.Error()})
+	}
+}
+
+func (dm *databaseManager) historyAdapter(c *gin.Context) (databaseAdapter, historyReader, *tableConfig, bool) {
+	adapter, tc, err := dm.getAdapterAndTableConfig(c.Param("database"), c.Param("table"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, nil, nil, false
+	}
+	hr, ok := adapter.(historyReader)
+	if !ok || !tc.History.Enabled {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "History is not enabled for this table"})
+		return nil, nil, nil, false
+	}
+	return adapter, hr, tc, true
+}
+
+func (dm *databaseManager) handleHistory(c *gin.Context) {
+	adapter, hr, tc, ok := dm.historyAdapter(c)
+	if !ok || !dm.authorize(c, tc, opGet) {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery(queryParamPage, strconv.Itoa(dm.config.DefaultPage)))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery(queryParamPageSize, strconv.Itoa(dm.config.DefaultPageSize)))
+	if page <= 0 {
+		page = dm.config.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = dm.config.DefaultPageSize
+	}
+	if pageSize > dm.config.MaxPageSize {
+		pageSize = dm.config.MaxPageSize
+	}
+	pk, err := historyKey(c.Request.Context(), adapter, tc, c.Param("id"))
+	if err != nil {
+		writeHistoryKeyError(c, err)
+		return
+	}
+	rows, total, err := hr.History(c.Request.Context(), tc, pk, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read history: " + err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	for i := range rows {
+		rows[i] = fixPkFieldToString(rows[i], tc.PrimaryKey).(map[string]interface{})
+		if t, ok := asTime(rows[i][historyColumnAt], true); ok {
+			rows[i][historyColumnAt] = t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "data": tc.renderRecords(rows)})
+}
+
+// handleGetOneAsOf 处理 GET /:id?as_of=...
+func (dm *databaseManager) handleGetOneAsOf(c *gin.Context, asOf string) {
+	adapter, hr, tc, ok := dm.historyAdapter(c)
+	if !ok || !dm.authorize(c, tc, opGet) {
+		return
+	}
+	t, err := parseAsOf(asOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pk, err := historyKey(c.Request.Context(), adapter, tc, c.Param("id"))
+	if err != nil {
+		writeHistoryKeyError(c, err)
+		return
+	}
+	record, err := recordAsOf(c.Request.Context(), hr, adapter, tc, pk, t, tc.physicalFieldList(c.Query(queryParamFields)))
+	if err != nil {
+		if isNotFoundErr(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get record: " + err.Error()})
+		}
+		return
+	}
+	record = fixPkFieldToString(record, tc.PrimaryKey).(map[string]interface{})
+	c.JSON(http.StatusOK, tc.renderRecord(record))
+}
@@ -0,0 +1,182 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// --------- ID 解析层 ---------
+//
+// GET /:database/:table/:id 未携带 key 参数时，:id 按表配置 id_resolution 中的字段顺序依次尝试：
+//
+//	id_resolution: [id, email, phone]  # 先按主键，再按 email、phone 查找
+//	id_resolution: [auto]              # 主键 + unique_keys 中全部单字段唯一键（按配置顺序）
+//
+// 未配置时只按主键查找。最终命中的字段通过响应头 X-Matched-Key 返回。
+//
+// 可附带与列表接口相同的过滤参数缩小查找范围，如 GET /test/user/alice@x.com?key=email&tenant_id=3；
+// 按非主键查找（或带过滤条件）时命中多条返回 409，而不是任取其一。
+//
+// 另外，适配器或上游返回的记录中若带有 "@id" 占位字段，会被改写为主键字段名（见 resolveIDPlaceholder）。
+
+const (
+	idPlaceholder    = "@id"
+	idResolutionAuto = "auto"
+	headerMatchedKey = "X-Matched-Key"
+)
+
+var (
+	errNoIdentifiableKey = errors.New("no identifiable key (primary or unique) configured for table")
+	errAmbiguousRecord   = errors.New("more than one record matches")
+)
+
+// GetIDResolutionKeys 返回 :id 查找时依次尝试的字段
+func (tc *tableConfig) GetIDResolutionKeys() []string {
+	var keys []string
+	add := func(k string) {
+		k = strings.TrimSpace(k)
+		if k != "" && !contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(tc.IDResolution) == 0 {
+		add(tc.PrimaryKey)
+		return keys
+	}
+	for _, k := range tc.IDResolution {
+		if k != idResolutionAuto {
+			add(k)
+			continue
+		}
+		add(tc.PrimaryKey)
+		for _, uk := range tc.GetUniqueKeys() {
+			if len(uk) == 1 {
+				add(uk[0])
+			}
+		}
+	}
+	return keys
+}
+
+// resolveIDPlaceholder 把记录中的 "@id" 占位字段改写为主键字段
+func resolveIDPlaceholder(record map[string]interface{}, pk string) {
+	if pk == "" {
+		return
+	}
+	if val, ok := record[idPlaceholder]; ok {
+		record[pk] = val
+		delete(record, idPlaceholder)
+	}
+}
+
+func isNotFoundErr(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments)
+}
+
+// isKeyMismatchErr 判断查询错误是否因 :id 无法转换为该字段的类型（如按整数主键查找 alice@x.com）
+func isKeyMismatchErr(err error) bool {
+	var filterErr *invalidFilterError // 适配器在本地转换取值失败（如 BigQuery）
+	if errors.As(err, &filterErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "22P02", "22003", "22007", "22008": // 无效文本表示、数值越界、日期时间格式与越界
+			return true
+		}
+	}
+	var msErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &msErr) {
+		switch msErr.SQLErrorNumber() {
+		case 241, 242, 245, 8114, 8115: // 日期、字符串、数值转换失败与溢出
+			return true
+		}
+	}
+	var chErr *clickhouse.Exception
+	if errors.As(err, &chErr) {
+		switch chErr.Code {
+		case 6, 27, 38, 41, 53, 72: // CANNOT_PARSE_*、TYPE_MISMATCH
+			return true
+		}
+	}
+	return false
+}
+
+// getOneByResolvedID 按 id_resolution 顺序查找记录，返回命中的字段名。
+// 类型不匹配（:id 无法转换为该字段的类型）与 not-found 一样视为未命中，全部未命中时返回 not-found；
+// 其他查询错误在全部候选字段尝试完后返回首个。
+func getOneByResolvedID(ctx context.Context, adapter databaseAdapter, tc *tableConfig, idVal string, fields string, extra url.Values) (map[string]interface{}, string, error) {
+	keys := tc.GetIDResolutionKeys()
+	if len(keys) == 0 {
+		return nil, "", errNoIdentifiableKey
+	}
+	var firstErr error
+	for _, k := range keys {
+		record, err := getOneScoped(ctx, adapter, tc, map[string]interface{}{k: idVal}, fields, extra)
+		if err == nil {
+			return record, k, nil
+		}
+		if errors.Is(err, errAmbiguousRecord) {
+			return nil, k, err
+		}
+		if !isNotFoundErr(err) && !isKeyMismatchErr(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, "", firstErr
+	}
+	return nil, "", gorm.ErrRecordNotFound
+}
+
+// getOneScoped 按键值与额外过滤条件查找单条记录。
+// 只按主键且无额外条件时直接走 GetOne；否则通过 List 取两条，非主键查找命中多条时返回 errAmbiguousRecord。
+func getOneScoped(ctx context.Context, adapter databaseAdapter, tc *tableConfig, filter map[string]interface{}, fields string, extra url.Values) (map[string]interface{}, error) {
+	_, byPK := filter[tc.PrimaryKey]
+	byPK = byPK && len(filter) == 1
+	if byPK && len(extra) == 0 {
+		return adapter.GetOne(ctx, tc, filter, fields)
+	}
+	query := make(url.Values, len(extra)+len(filter))
+	for k, v := range extra {
+		query[k] = v
+	}
+	for k, v := range filter {
+		// 与保留参数同名的键用 __eq 表达，避免被当作分页等参数忽略
+		if isNonFilterParam(k) {
+			k += "__eq"
+		}
+		query.Set(k, fmt.Sprint(v))
+	}
+	records, _, err := adapter.List(ctx, tc, listParams{Page: 1, PageSize: 2, Fields: fields, QueryFilters: query})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(records) == 0:
+		return nil, gorm.ErrRecordNotFound
+	case len(records) > 1 && !byPK:
+		return nil, errAmbiguousRecord
+	}
+	return records[0], nil
+}
+
+// getOneFilters 取出 GetOne 请求中除 key、fields 等保留参数外的过滤条件
+func getOneFilters(tc *tableConfig, query url.Values) url.Values {
+	result := url.Values{}
+	for k, v := range tc.physicalQuery(query) {
+		if !isNonFilterParam(k) && len(v) > 0 {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 只写一次的字段 ---------
+//
+// 创建时可以写入、之后不允许修改的字段（订单号、租户 ID、创建信息等）：
+//
+//	immutable_fields: [order_no, tenant_id]
+//	immutable_mode: reject     # reject（默认）拒绝请求；drop 从更新内容中去掉这些字段
+//
+// 单条更新、批量更新与批量任务中，按主键（或单条更新的唯一键）读取当前值，与当前值相同的字段视为未修改，
+// 直接从更新内容中去掉，方便客户端整条回写；update_where 无法逐条比较，出现即视为修改。
+// reject 时返回 422 与被修改的字段列表。
+
+const (
+	immutableModeReject = "reject"
+	immutableModeDrop   = "drop"
+)
+
+type immutableFieldError struct {
+	Fields []string // API 字段名
+}
+
+func (e *immutableFieldError) Error() string {
+	return fmt.Sprintf("Immutable fields cannot be changed: %s", strings.Join(e.Fields, ","))
+}
+
+func checkImmutableMode(tc *tableConfig) error {
+	switch tc.ImmutableMode {
+	case "", immutableModeReject, immutableModeDrop:
+		return nil
+	}
+	return fmt.Errorf("table %s: invalid immutable_mode '%s'", tc.Name, tc.ImmutableMode)
+}
+
+// immutablePresent 返回记录中出现的只写一次字段（物理列名）
+func (tc *tableConfig) immutablePresent(record map[string]interface{}) []string {
+	var present []string
+	for _, f := range tc.ImmutableFields {
+		if _, ok := record[f]; ok {
+			present = append(present, f)
+		}
+	}
+	return present
+}
+
+// guardImmutable 处理更新内容中的只写一次字段。keys 与 records 一一对应，用于读取当前值；
+// keys 为 nil 时不读取，出现即视为修改。
+func (tc *tableConfig) guardImmutable(ctx context.Context, adapter databaseAdapter, records []map[string]interface{}, keys []lookupKey) error {
+	if len(tc.ImmutableFields) == 0 {
+		return nil
+	}
+	fields := map[string]struct{}{}
+	for _, rec := range records {
+		for _, f := range tc.immutablePresent(rec) {
+			fields[f] = struct{}{}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if tc.ImmutableMode == immutableModeDrop {
+		for _, rec := range records {
+			for f := range fields {
+				delete(rec, f)
+			}
+		}
+		return nil
+	}
+	var current []map[string]interface{}
+	if keys != nil {
+		names := make([]string, 0, len(fields))
+		for f := range fields {
+			names = append(names, f)
+		}
+		var err error
+		if current, err = batchGetRecords(ctx, adapter, tc, keys, strings.Join(names, ",")); err != nil {
+			return fmt.Errorf("failed to read current values: %w", err)
+		}
+	}
+	changed := map[string]struct{}{}
+	for i, rec := range records {
+		for _, f := range tc.immutablePresent(rec) {
+			if current != nil && current[i] == nil {
+				// 记录不存在，更新不会生效
+				continue
+			}
+			if current != nil && keyValueString(current[i][f]) == keyValueString(rec[f]) {
+				delete(rec, f)
+				continue
+			}
+			changed[tc.apiFieldName(f)] = struct{}{}
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(changed))
+	for f := range changed {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	return &immutableFieldError{Fields: names}
+}
+
+// primaryKeyLookups 按主键生成 guardImmutable 使用的键
+func (tc *tableConfig) primaryKeyLookups(records []map[string]interface{}) []lookupKey {
+	keys := make([]lookupKey, len(records))
+	for i, rec := range records {
+		keys[i] = lookupKey{Fields: []string{tc.PrimaryKey}, Values: []interface{}{rec[tc.PrimaryKey]}}
+	}
+	return keys
+}
+
+// filterLookup 将单条更新的过滤条件转为 guardImmutable 使用的键
+func filterLookup(filter map[string]interface{}) lookupKey {
+	var k lookupKey
+	for f := range filter {
+		k.Fields = append(k.Fields, f)
+	}
+	sort.Strings(k.Fields)
+	for _, f := range k.Fields {
+		k.Values = append(k.Values, filter[f])
+	}
+	return k
+}
+
+// writeGuardError 修改了只写一次字段、状态流转不合法或字段校验失败时返回 422，写入 row_filter 范围之外的值时返回 403，其他错误返回 500
+func writeGuardError(c *gin.Context, err error) {
+	var immErr *immutableFieldError
+	var stateErr *stateTransitionError
+	var validErr *validationError
+	var scopeErr *rowFilterError
+	switch {
+	case errors.As(err, &immErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": immErr.Error(), "fields": immErr.Fields})
+	case errors.As(err, &stateErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": stateErr.Error(), "field": stateErr.Field, "current": stateErr.From, "allowed": stateErr.Allowed})
+	case errors.As(err, &scopeErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": scopeErr.Error()})
+	case errors.As(err, &validErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": validErr.Error(), "errors": validErr.Failures})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,379 @@
+package apix
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 文件导入 ---------
+//
+//	POST /api/rest/:database/:table/import                         multipart 上传，文件字段名为 file
+//	POST /api/rest/:database/:table/import?format=csv&chunk_size=500&dry_run=true
+//
+// 格式由 format（csv、ndjson）指定，未指定时按文件扩展名（.csv/.tsv、.ndjson/.jsonl/.json）或 Content-Type 判断；
+// CSV 首行为字段名（可用字段别名），delimiter 指定分隔符，空单元格视为未提供（应用 default_values），
+// 其余值按字符串写入由数据库转换类型，MongoDB 等无模式的库建议使用 NDJSON。
+// 每条记录与批量创建接口一样经过字段别名、时间解析、默认值、auto_actor_fields 与状态初始值校验，
+// 不通过的记录跳过；其余按 chunk_size（默认 bulk_job.chunk_size）分块，每块在一个事务中写入，
+// 写入失败的记录同 on_error=continue 逐条定位后跳过。返回逐行的错误报告，row 为数据行号（从 1 开始，不含表头）：
+//
+//	{"format": "csv", "total": 10, "created": 9, "failed": 1, "errors": [{"row": 3, "error": "..."}]}
+//
+// 状态码与 on_error=continue 相同：全部成功 201，部分成功 207，全部失败 422。
+// 数据量大或需要后台执行时使用 bulk_jobs（见 bulkjob.go）。
+
+const (
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+
+	queryParamChunkSize = "chunk_size"
+	maxImportChunkSize  = 10000
+	maxImportErrors     = 1000
+)
+
+type importRowError struct {
+	Row   int64  `json:"row"`
+	Error string `json:"error"`
+}
+
+type importReport struct {
+	Format          string           `json:"format"`
+	Total           int64            `json:"total"`
+	Created         int64            `json:"created"`
+	Failed          int64            `json:"failed"`
+	Errors          []importRowError `json:"errors"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+	DryRun          bool             `json:"dry_run,omitempty"`
+}
+
+func (r *importReport) fail(row int64, err string) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, importRowError{Row: row, Error: err})
+	} else {
+		r.ErrorsTruncated = true
+	}
+}
+
+// importWriteError 为写入数据库时中止导入的错误（非单条记录的失败）
+type importWriteError struct {
+	row int64
+	err error
+}
+
+func (e *importWriteError) Error() string {
+	return fmt.Sprintf("import aborted at row %d: %v", e.row, e.err)
+}
+
+func (e *importWriteError) Unwrap() error { return e.err }
+
+// importRowReader 逐行读取上传文件，返回 API 字段名的记录；单行格式错误时 rowErr 非空，可继续读取
+type importRowReader interface {
+	Next() (record map[string]interface{}, rowErr error, err error)
+}
+
+// importFormat 按参数、文件扩展名、Content-Type 的顺序确定文件格式
+func importFormat(param, filename, contentType string) (string, rune, error) {
+	switch strings.ToLower(param) {
+	case importFormatCSV:
+		return importFormatCSV, ',', nil
+	case importFormatNDJSON, "jsonl", "json":
+		return importFormatNDJSON, 0, nil
+	case "":
+	default:
+		return "", 0, fmt.Errorf("invalid format: %s, expected csv or ndjson", param)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return importFormatCSV, ',', nil
+	case ".tsv":
+		return importFormatCSV, '\t', nil
+	case ".ndjson", ".jsonl", ".json":
+		return importFormatNDJSON, 0, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return importFormatCSV, ',', nil
+	case "text/tab-separated-values":
+		return importFormatCSV, '\t', nil
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return importFormatNDJSON, 0, nil
+	}
+	return "", 0, fmt.Errorf("cannot determine file format of %q, specify format=csv or format=ndjson", filename)
+}
+
+// ---- CSV ----
+
+type csvRowReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVRowReader(r io.Reader, comma rune) (*csvRowReader, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty CSV file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	seen := map[string]bool{}
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		if header[i] == "" || seen[header[i]] {
+			return nil, fmt.Errorf("invalid CSV header: empty or duplicate column %q", h)
+		}
+		seen[header[i]] = true
+	}
+	return &csvRowReader{r: cr, header: header}, nil
+}
+
+func (r *csvRowReader) Next() (map[string]interface{}, error, error) {
+	rec, err := r.r.Read()
+	if err == io.EOF {
+		return nil, nil, io.EOF
+	}
+	if err != nil {
+		if _, ok := err.(*csv.ParseError); ok {
+			return nil, err, nil
+		}
+		return nil, nil, err
+	}
+	if len(rec) > len(r.header) {
+		return nil, fmt.Errorf("row has %d columns, header has %d", len(rec), len(r.header)), nil
+	}
+	row := make(map[string]interface{}, len(rec))
+	for i, v := range rec {
+		if v != "" {
+			row[r.header[i]] = v
+		}
+	}
+	return row, nil, nil
+}
+
+// ---- NDJSON ----
+
+type ndjsonRowReader struct {
+	r *recordReader
+}
+
+func (r *ndjsonRowReader) Next() (map[string]interface{}, error, error) {
+	item, err := r.r.Next()
+	if err != nil {
+		// JSON 语法错误后无法定位下一行，整个文件中止
+		return nil, nil, err
+	}
+	rec, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("record is not an object"), nil
+	}
+	return rec, nil, nil
+}
+
+// ---- Handler ----
+
+func (dm *databaseManager) handleImport(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opCreate) {
+		return
+	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	chunkSize := dm.config.BulkJob.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	if v := c.Query(queryParamChunkSize); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxImportChunkSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %s, expected 1-%d", queryParamChunkSize, v, maxImportChunkSize)})
+			return
+		}
+		chunkSize = n
+	}
+	var comma rune
+	if v := c.Query("delimiter"); v != "" {
+		if v == `\t` {
+			v = "\t"
+		}
+		if utf8.RuneCountInString(v) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "delimiter must be a single character"})
+			return
+		}
+		comma, _ = utf8.DecodeRuneInString(v)
+	}
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart/form-data upload: " + err.Error()})
+		return
+	}
+	// 流式读取 file 字段，不落盘
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file field in upload"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart upload: " + err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		format, defaultComma, err := importFormat(c.Query("format"), part.FileName(), part.Header.Get("Content-Type"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if comma == 0 {
+			comma = defaultComma
+		}
+		var rows importRowReader
+		if format == importFormatCSV {
+			rows, err = newCSVRowReader(part, comma)
+		} else {
+			var rr *recordReader
+			rr, err = newRecordReader(part)
+			rows = &ndjsonRowReader{r: rr}
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file: " + err.Error()})
+			return
+		}
+		report := importReport{Format: format, Errors: []importRowError{}, DryRun: dryRun}
+		if err := dm.importRows(c, adapter, tableConfig, rows, chunkSize, dryRun, &report); err != nil {
+			status := http.StatusBadRequest
+			var writeErr *importWriteError
+			if errors.As(err, &writeErr) {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		if report.Total == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No records in file"})
+			return
+		}
+		status := partialCreateStatus(int(report.Created), int(report.Failed))
+		if dryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, report)
+		return
+	}
+}
+
+// importRows 逐行校验并分块写入；返回的错误为无法继续读取文件或写入中止，此前已提交的块不回滚
+func (dm *databaseManager) importRows(c *gin.Context, adapter databaseAdapter, tc *tableConfig, rows importRowReader, chunkSize int, dryRun bool, report *importReport) error {
+	ctx := c.Request.Context()
+	actor := dm.currentActor(c)
+	chunk := make([]map[string]interface{}, 0, chunkSize)
+	chunkRows := make([]int64, 0, chunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		var failures []recordFailure
+		var created []map[string]interface{}
+		_, err := runMutation(ctx, adapter, dryRun, func(a databaseAdapter) error {
+			var err error
+			_, created, failures, err = batchCreatePartialChecked(ctx, a, tc, chunk)
+			return err
+		})
+		if err != nil {
+			return &importWriteError{row: chunkRows[0], err: err}
+		}
+		for _, f := range failures {
+			report.fail(chunkRows[f.Index], f.Error)
+		}
+		for _, r := range created {
+			if r != nil {
+				report.Created++
+			}
+		}
+		chunk, chunkRows = chunk[:0], chunkRows[:0]
+		return nil
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, rowErr, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w", report.Total+1, err)
+		}
+		report.Total++
+		if rowErr == nil {
+			rec, rowErr = prepareImportRecord(ctx, tc, rec, actor)
+		}
+		if rowErr != nil {
+			report.fail(report.Total, rowErr.Error())
+			continue
+		}
+		chunk = append(chunk, rec)
+		chunkRows = append(chunkRows, report.Total)
+		if len(chunk) >= chunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	return nil
+}
+
+// prepareImportRecord 按批量创建接口的规则处理一条记录
+func prepareImportRecord(ctx context.Context, tc *tableConfig, rec map[string]interface{}, actor string) (map[string]interface{}, error) {
+	rec, err := tc.inputRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.scopeRecords(ctx, []map[string]interface{}{rec}, true); err != nil {
+		return nil, err
+	}
+	if err := applyDefaultValues(rec, tc); err != nil {
+		return nil, err
+	}
+	applyAutoActorFields(rec, tc, actor, true)
+	if err := tc.validateRecords([]map[string]interface{}{rec}, false); err != nil {
+		return nil, err
+	}
+	if err := tc.guardInitialStates([]map[string]interface{}{rec}); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
@@ -0,0 +1,401 @@
+package apix
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- 索引建议 ---------
+//
+// 分析 gorm 查询日志（gorm_log.filename，info 级别记录全部 SQL，warn 级别只记录慢查询），
+// 统计各表 WHERE 中频繁出现的列组合，与数据库中已有索引对照，给出 CREATE INDEX 建议：
+//
+//	index_advisor:
+//	  log: ""                    # 默认取 gorm_log.filename
+//	  min_count: 20              # 同一查询形态至少出现的次数
+//	  max_scan_mb: 64            # 只分析日志末尾的这部分内容
+//	  schedule: "0 0 3 * * *"    # 定时分析（秒级 cron），为空时只能手动触发
+//
+// 管理接口（受 operation_roles.index_advisor 控制，默认 admin）：
+//
+//	POST /api/admin/index_suggestions   提交分析任务，返回任务 ID
+//	GET  /api/admin/index_suggestions   最近一次分析结果
+
+const jobTypeIndexAdvice = "index_advice"
+
+type indexAdvisorConfig struct {
+	Log       string `mapstructure:"log"`
+	MinCount  int    `mapstructure:"min_count"`
+	MaxScanMB int    `mapstructure:"max_scan_mb"`
+	Schedule  string `mapstructure:"schedule"`
+}
+
+type indexSuggestion struct {
+	Database    string   `json:"database"`
+	Table       string   `json:"table"`
+	Columns     []string `json:"columns"`
+	Occurrences int      `json:"occurrences"`
+	TotalMs     float64  `json:"total_ms"`
+	MaxMs       float64  `json:"max_ms"`
+	Sample      string   `json:"sample"`
+	Statement   string   `json:"statement"`
+}
+
+type indexReport struct {
+	GeneratedAt  time.Time         `json:"generated_at"`
+	ScannedLines int               `json:"scanned_lines"`
+	Queries      int               `json:"queries"`
+	Suggestions  []indexSuggestion `json:"suggestions"`
+}
+
+// ---- 日志解析 ----
+
+// gorm 日志的 SQL 行：[12.345ms] [rows:10] SELECT ...
+var (
+	gormLogSQLRe   = regexp.MustCompile(`^\[([\d.]+)ms\] \[rows:[-\d]+\] (.+)$`)
+	sqlStringRe    = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"\\]|\\.)*"`)
+	sqlTableRe     = regexp.MustCompile(`(?i)\b(?:FROM|UPDATE)\s+[` + "`" + `"\[]?(\w+)[` + "`" + `"\]]?`)
+	sqlWhereRe     = regexp.MustCompile(`(?is)\bWHERE\b(.*?)(?:\bGROUP BY\b|\bORDER BY\b|\bLIMIT\b|\bOFFSET\b|\bFETCH\b|$)`)
+	sqlConditionRe = regexp.MustCompile("(?i)(?:[`\"\\[]?\\w+[`\"\\]]?\\.)?[`\"\\[]?([A-Za-z_]\\w*)[`\"\\]]?\\s*(<>|!=|>=|<=|=|>|<|\\bNOT IN\\b|\\bIN\\b|\\bIS\\b|\\bBETWEEN\\b|\\bLIKE\\b)")
+	sqlLeadingOpRe = regexp.MustCompile(`(?i)^\s*(SELECT|UPDATE|DELETE)\b`)
+)
+
+// queryShape 是一条查询可利用索引的列：等值列在前，范围列在后
+type queryShape struct {
+	table string
+	eq    []string
+	rng   []string
+}
+
+func (s queryShape) key() string {
+	return s.table + "|" + strings.Join(s.eq, ",") + "|" + strings.Join(s.rng, ",")
+}
+
+func parseQueryShape(sql string) (queryShape, bool) {
+	if !sqlLeadingOpRe.MatchString(stripSQLComments(sql)) {
+		return queryShape{}, false
+	}
+	clean := sqlStringRe.ReplaceAllString(sql, "?")
+	m := sqlTableRe.FindStringSubmatch(clean)
+	if m == nil {
+		return queryShape{}, false
+	}
+	shape := queryShape{table: m[1]}
+	w := sqlWhereRe.FindStringSubmatch(clean)
+	if w == nil {
+		return shape, false
+	}
+	seen := map[string]bool{}
+	for _, c := range sqlConditionRe.FindAllStringSubmatch(w[1], -1) {
+		col, op := c[1], strings.ToUpper(c[2])
+		if seen[col] || isSQLKeyword(col) {
+			continue
+		}
+		switch op {
+		case "=", "IN", "IS":
+			shape.eq = append(shape.eq, col)
+		case ">", "<", ">=", "<=", "BETWEEN":
+			shape.rng = append(shape.rng, col)
+		default:
+			continue
+		}
+		seen[col] = true
+	}
+	sort.Strings(shape.eq)
+	sort.Strings(shape.rng)
+	return shape, len(shape.eq)+len(shape.rng) > 0
+}
+
+func stripSQLComments(sql string) string {
+	for strings.HasPrefix(strings.TrimSpace(sql), "/*") {
+		sql = strings.TrimSpace(sql)
+		end := strings.Index(sql, "*/")
+		if end < 0 {
+			return sql
+		}
+		sql = sql[end+2:]
+	}
+	return sql
+}
+
+func isSQLKeyword(s string) bool {
+	switch strings.ToUpper(s) {
+	case "AND", "OR", "NOT", "NULL", "WHERE", "ON", "AS":
+		return true
+	}
+	return false
+}
+
+type shapeStats struct {
+	shape   queryShape
+	count   int
+	totalMs float64
+	maxMs   float64
+	sample  string
+}
+
+// scanQueryLog 读取日志末尾 maxBytes 字节，按查询形态聚合
+func scanQueryLog(path string, maxBytes int64) (map[string]*shapeStats, int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > maxBytes {
+		if _, err := f.Seek(info.Size()-maxBytes, io.SeekStart); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	shapes := map[string]*shapeStats{}
+	lines, queries := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		lines++
+		m := gormLogSQLRe.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		shape, ok := parseQueryShape(m[2])
+		if !ok {
+			continue
+		}
+		queries++
+		ms, _ := strconv.ParseFloat(m[1], 64)
+		st := shapes[shape.key()]
+		if st == nil {
+			st = &shapeStats{shape: shape, sample: m[2]}
+			shapes[shape.key()] = st
+		}
+		st.count++
+		st.totalMs += ms
+		if ms > st.maxMs {
+			st.maxMs = ms
+			st.sample = m[2]
+		}
+	}
+	return shapes, lines, queries, scanner.Err()
+}
+
+// ---- 建议生成 ----
+
+func gormDBOf(adapter databaseAdapter) *gorm.DB {
+	switch a := adapter.(type) {
+	case *gormAdapter:
+		return a.db
+	case *shardedAdapter:
+		if len(a.shards) > 0 {
+			return a.shards[0].db
+		}
+	}
+	return nil
+}
+
+// indexCovers 已有索引的首列命中查询的等值列或第一个范围列时视为可用
+func indexCovers(leading []string, shape queryShape) bool {
+	for _, l := range leading {
+		if contains(shape.eq, l) || len(shape.rng) > 0 && shape.rng[0] == l {
+			return true
+		}
+	}
+	return false
+}
+
+func (dm *databaseManager) buildIndexReport(ctx context.Context) (*indexReport, error) {
+	cfg := dm.config.IndexAdvisor
+	logPath := cfg.Log
+	if logPath == "" {
+		logPath = dm.config.GormLog.Filename
+	}
+	shapes, lines, queries, err := scanQueryLog(logPath, int64(cfg.MaxScanMB)<<20)
+	if err != nil {
+		return nil, fmt.Errorf("scan query log %s: %w", logPath, err)
+	}
+	report := &indexReport{GeneratedAt: time.Now(), ScannedLines: lines, Queries: queries, Suggestions: []indexSuggestion{}}
+
+	dm.mutex.RLock()
+	adapters := make(map[string]databaseAdapter, len(dm.adapters))
+	for name, a := range dm.adapters {
+		adapters[name] = a
+	}
+	databases := dm.config.Databases
+	dm.mutex.RUnlock()
+
+	for dbName, dbCfg := range databases {
+		db := gormDBOf(adapters[dbName])
+		if db == nil {
+			continue
+		}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			var candidates []*shapeStats
+			for _, st := range shapes {
+				if st.shape.table == tc.Name {
+					candidates = append(candidates, st)
+				}
+			}
+			if len(candidates) == 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			columns := map[string]bool{}
+			if cts, err := db.WithContext(ctx).Migrator().ColumnTypes(tc.Name); err == nil {
+				for _, ct := range cts {
+					columns[ct.Name()] = true
+				}
+			}
+			leading := []string{}
+			if tc.PrimaryKey != "" {
+				leading = append(leading, tc.PrimaryKey)
+			}
+			if indexes, err := db.WithContext(ctx).Migrator().GetIndexes(tc.Name); err == nil {
+				for _, idx := range indexes {
+					if cols := idx.Columns(); len(cols) > 0 {
+						leading = append(leading, cols[0])
+					}
+				}
+			}
+			// 软删除列区分度低，不参与建议；去掉后相同的形态合并统计
+			merged := map[string]*shapeStats{}
+			for _, st := range candidates {
+				shape := queryShape{table: st.shape.table}
+				for _, c := range st.shape.eq {
+					if c != tc.SoftDeleteKey && columns[c] {
+						shape.eq = append(shape.eq, c)
+					}
+				}
+				for _, c := range st.shape.rng {
+					if c != tc.SoftDeleteKey && columns[c] {
+						shape.rng = append(shape.rng, c)
+					}
+				}
+				if len(shape.eq)+len(shape.rng) == 0 || indexCovers(leading, shape) {
+					continue
+				}
+				m := merged[shape.key()]
+				if m == nil {
+					m = &shapeStats{shape: shape}
+					merged[shape.key()] = m
+				}
+				m.count += st.count
+				m.totalMs += st.totalMs
+				if st.maxMs >= m.maxMs {
+					m.maxMs, m.sample = st.maxMs, st.sample
+				}
+			}
+			for _, st := range merged {
+				if st.count < cfg.MinCount {
+					continue
+				}
+				// 多个范围条件只有第一个能用上索引
+				cols := append(append([]string{}, st.shape.eq...), st.shape.rng...)
+				if len(st.shape.rng) > 1 {
+					cols = cols[:len(st.shape.eq)+1]
+				}
+				report.Suggestions = append(report.Suggestions, indexSuggestion{
+					Database:    dbName,
+					Table:       tc.Alias,
+					Columns:     tc.apiFieldNames(cols),
+					Occurrences: st.count,
+					TotalMs:     st.totalMs,
+					MaxMs:       st.maxMs,
+					Sample:      st.sample,
+					Statement:   createIndexStatement(db, tc.Name, cols),
+				})
+			}
+		}
+	}
+	sort.Slice(report.Suggestions, func(i, j int) bool {
+		return report.Suggestions[i].TotalMs > report.Suggestions[j].TotalMs
+	})
+	return report, nil
+}
+
+func createIndexStatement(db *gorm.DB, table string, cols []string) string {
+	stmt := db.Session(&gorm.Session{NewDB: true}).Statement
+	name := "idx_" + table + "_" + strings.Join(cols, "_")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = stmt.Quote(c)
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", stmt.Quote(name), stmt.Quote(table), strings.Join(quoted, ", "))
+}
+
+// ---- 任务与接口 ----
+
+func (dm *databaseManager) runIndexAdviceJob(ctx context.Context, job utils.Job) error {
+	report, err := dm.buildIndexReport(ctx)
+	if err != nil {
+		return err
+	}
+	dm.indexAdviceMu.Lock()
+	dm.indexAdvice = report
+	dm.indexAdviceMu.Unlock()
+	return dm.jobQueue.SetProgress(job.ID, gin.H{"queries": report.Queries, "suggestions": len(report.Suggestions)})
+}
+
+// scheduleIndexAdvisor 按 index_advisor.schedule 定时提交分析任务
+func (dm *databaseManager) scheduleIndexAdvisor() error {
+	spec := dm.config.IndexAdvisor.Schedule
+	if spec == "" {
+		return nil
+	}
+	s := utils.NewScheduler()
+	err := s.AddJob(jobTypeIndexAdvice, spec, func() {
+		if _, err := dm.jobQueue.Enqueue(jobTypeIndexAdvice, nil, utils.EnqueueOptions{}); err != nil {
+			log.Printf("enqueue index advice job failed: %v", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("invalid index_advisor.schedule: %w", err)
+	}
+	dm.schedulers = append(dm.schedulers, s)
+	return nil
+}
+
+func (dm *databaseManager) handleIndexAdviceRun(c *gin.Context) {
+	if !dm.authorize(c, nil, opIndexAdvisor) {
+		return
+	}
+	job, err := dm.jobQueue.Enqueue(jobTypeIndexAdvice, nil, utils.EnqueueOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", dm.jobsPrefix+"/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": job.Status})
+}
+
+func (dm *databaseManager) handleIndexAdviceGet(c *gin.Context) {
+	if !dm.authorize(c, nil, opIndexAdvisor) {
+		return
+	}
+	dm.indexAdviceMu.RLock()
+	report := dm.indexAdvice
+	dm.indexAdviceMu.RUnlock()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No index advice yet, POST to run the analysis"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,192 @@
+package apix
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// --------- 执行中的查询 ---------
+//
+// 记录各 SQL 库正在执行的读查询（语句与已耗时），出现异常查询时可在线取消（通过取消查询的 context）：
+//
+//	GET    /api/admin/queries?database=test   按已耗时从高到低列出，并返回各库的执行数与累计取消数
+//	DELETE /api/admin/queries/:id             取消查询，调用方收到 context canceled 错误
+//
+// 仅跟踪基于 gorm 的库（不含分片库与 mongodb）的查询与原生 SQL，写操作在事务中执行，不在此列。
+// 受 operation_roles.inflight_queries 控制，默认 admin。
+
+const inflightInstanceKey = "ego:inflight_id"
+
+type inflightQuery struct {
+	ID        int64     `json:"id"`
+	Database  string    `json:"database"`
+	Table     string    `json:"table,omitempty"`
+	Statement string    `json:"statement"`
+	Started   time.Time `json:"started"`
+	ElapsedMs float64   `json:"elapsed_ms"`
+
+	cancel context.CancelFunc
+	parent context.Context
+}
+
+type inflightRegistry struct {
+	mu        sync.Mutex
+	seq       int64
+	queries   map[int64]*inflightQuery
+	cancelled map[string]int64
+}
+
+func newInflightRegistry() *inflightRegistry {
+	return &inflightRegistry{queries: map[int64]*inflightQuery{}, cancelled: map[string]int64{}}
+}
+
+func (r *inflightRegistry) add(q *inflightQuery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	q.ID = r.seq
+	r.queries[q.ID] = q
+}
+
+func (r *inflightRegistry) remove(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queries, id)
+}
+
+func (r *inflightRegistry) cancel(id int64) bool {
+	r.mu.Lock()
+	q, ok := r.queries[id]
+	if ok {
+		delete(r.queries, id)
+		r.cancelled[q.Database]++
+	}
+	r.mu.Unlock()
+	if ok {
+		q.cancel()
+	}
+	return ok
+}
+
+func (r *inflightRegistry) snapshot(database string) ([]inflightQuery, map[string]gin.H) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	list := []inflightQuery{}
+	stats := map[string]gin.H{}
+	running := map[string]int{}
+	for _, q := range r.queries {
+		running[q.Database]++
+		if database != "" && q.Database != database {
+			continue
+		}
+		item := *q
+		item.ElapsedMs = float64(now.Sub(q.Started).Microseconds()) / 1000
+		list = append(list, item)
+	}
+	for db, n := range running {
+		stats[db] = gin.H{"running": n, "cancelled_total": r.cancelled[db]}
+	}
+	for db, n := range r.cancelled {
+		if _, ok := stats[db]; !ok {
+			stats[db] = gin.H{"running": 0, "cancelled_total": n}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list, stats
+}
+
+// trackInflight 在 gorm 的查询回调前后登记与注销查询
+func (dm *databaseManager) trackInflight(name string, db *gorm.DB) error {
+	register := func(db *gorm.DB) {
+		parent := db.Statement.Context
+		ctx, cancel := context.WithCancel(parent)
+		db.Statement.Context = ctx
+		sql := db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...)
+		if len(sql) > maxSlowQuerySQLLen {
+			sql = sql[:maxSlowQuerySQLLen] + "..."
+		}
+		q := &inflightQuery{Database: name, Table: sqlTableName(sql), Statement: sql, Started: time.Now(), cancel: cancel, parent: parent}
+		dm.inflight.add(q)
+		db.InstanceSet(inflightInstanceKey, q)
+	}
+	begin := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		// 提前生成语句，gorm 的查询回调中不会重复生成
+		callbacks.BuildQuerySQL(db)
+		register(db)
+	}
+	rawBegin := func(db *gorm.DB) {
+		if db.Error == nil && !db.DryRun {
+			register(db)
+		}
+	}
+	// 结束后恢复原 context：同一个 *gorm.DB 上可能继续执行下一条语句
+	finish := func(db *gorm.DB, cancel bool) {
+		v, ok := db.InstanceGet(inflightInstanceKey)
+		if !ok {
+			return
+		}
+		q := v.(*inflightQuery)
+		dm.inflight.remove(q.ID)
+		// 部分驱动在取消后只是提前结束读取而不报错，避免把截断的结果当作正常返回
+		if err := db.Statement.Context.Err(); err != nil && db.Error == nil {
+			db.AddError(err)
+		}
+		db.Statement.Context = q.parent
+		if cancel {
+			q.cancel()
+		}
+	}
+	end := func(db *gorm.DB) { finish(db, true) }
+	// Rows() 返回后调用方仍在读取，只注销，不取消 context
+	rowEnd := func(db *gorm.DB) { finish(db, false) }
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Before("gorm:query").Register("ego:inflight_begin", begin),
+		cb.Query().After("gorm:query").Register("ego:inflight_end", end),
+		cb.Row().Before("gorm:row").Register("ego:inflight_begin", begin),
+		cb.Row().After("gorm:row").Register("ego:inflight_end", rowEnd),
+		cb.Raw().Before("gorm:raw").Register("ego:inflight_begin", rawBegin),
+		cb.Raw().After("gorm:raw").Register("ego:inflight_end", end),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dm *databaseManager) handleInflightQueries(c *gin.Context) {
+	if !dm.authorize(c, nil, opInflightQueries) {
+		return
+	}
+	list, stats := dm.inflight.snapshot(c.Query("database"))
+	c.JSON(http.StatusOK, gin.H{"queries": list, "databases": stats})
+}
+
+func (dm *databaseManager) handleInflightCancel(c *gin.Context) {
+	if !dm.authorize(c, nil, opInflightQueries) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query id"})
+		return
+	}
+	if !dm.inflight.cancel(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Query not found or already finished"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "cancelled": true})
+}
@@ -0,0 +1,919 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// --------- InfluxDB v2 Adapter 实现 ---------
+//
+// 数据库配置：
+//
+//	type: influxdb
+//	dsn: "http://localhost:8086?org=my-org&token=my-token"
+//	database: my_bucket
+//
+// 表对应 measurement，记录为按 _time 透视（pivot）后的点：_time、tag 列与各 field 列。
+// 表配置中 timeseries.tags 指定写入时作为 tag 的字段，其余字段写为 field；主键为 _time。
+// 查询通过 Flux 执行，创建使用 line protocol，删除使用 /api/v2/delete（按 _time 与 tag 条件）。
+// InfluxDB 的点不可修改，更新接口返回错误，需以相同 _time 与 tag 重新写入覆盖。
+
+const influxTimeField = "_time"
+
+var errInfluxUpdateUnsupported = errors.New("influxdb points are immutable, write the point again with the same _time and tags to overwrite it")
+
+type influxAdapter struct {
+	baseURL string
+	org     string
+	token   string
+	bucket  string
+	client  *http.Client
+	config  *databaseConfig
+}
+
+// parseInfluxDSN 解析 http(s)://host:8086?org=..&token=..
+func parseInfluxDSN(dsn string) (baseURL, org, token string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", "", fmt.Errorf("expected http(s)://host:port?org=..&token=..")
+	}
+	q := u.Query()
+	org, token = q.Get("org"), q.Get("token")
+	if org == "" {
+		return "", "", "", fmt.Errorf("influxdb dsn requires org")
+	}
+	u.RawQuery = ""
+	return strings.TrimRight(u.String(), "/"), org, token, nil
+}
+
+func newInfluxAdapter(dsn, bucket string, cfg *databaseConfig) (*influxAdapter, error) {
+	baseURL, org, token, err := parseInfluxDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &influxAdapter{
+		baseURL: baseURL,
+		org:     org,
+		token:   token,
+		bucket:  bucket,
+		client:  &http.Client{Timeout: 60 * time.Second},
+		config:  cfg,
+	}, nil
+}
+
+func (a *influxAdapter) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("org", a.org)
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Token "+a.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("influxdb %s: %s", apiErr.Code, apiErr.Message)
+		}
+		return nil, fmt.Errorf("influxdb returned %s", resp.Status)
+	}
+	return data, nil
+}
+
+// query 执行 Flux 并按注解 CSV 的 datatype 转换值类型
+func (a *influxAdapter) query(ctx context.Context, flux string) ([]map[string]interface{}, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":   flux,
+		"type":    "flux",
+		"dialect": map[string]interface{}{"header": true, "annotations": []string{"datatype"}},
+	})
+	data, err := a.do(ctx, http.MethodPost, "/api/v2/query", nil, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return parseFluxCSV(data)
+}
+
+func parseFluxCSV(data []byte) ([]map[string]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+	results := []map[string]interface{}{}
+	var types, header []string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 每个结果表以 #datatype 注解开始，随后一行为表头
+		if len(row) > 0 && row[0] == "#datatype" {
+			types, header = row, nil
+			continue
+		}
+		if header == nil {
+			header = row
+			continue
+		}
+		if len(row) > 1 && row[1] == "error" && len(header) > 1 && header[1] == "error" {
+			return nil, fmt.Errorf("flux error: %s", row[1:])
+		}
+		record := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			// 第一列为注解占位，result/table 为 Flux 内部列
+			if i == 0 || i >= len(row) || name == "result" || name == "table" {
+				continue
+			}
+			typ := ""
+			if i < len(types) {
+				typ = types[i]
+			}
+			record[name] = fluxValue(row[i], typ)
+		}
+		results = append(results, record)
+	}
+	return results, nil
+}
+
+func fluxValue(s, typ string) interface{} {
+	if s == "" && typ != "string" {
+		return nil
+	}
+	switch {
+	case typ == "long":
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case typ == "unsignedLong":
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+	case typ == "double":
+		if v, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(v, 0) && !math.IsNaN(v) {
+			return v
+		}
+		return nil
+	case typ == "boolean":
+		return s == "true"
+	case strings.HasPrefix(typ, "dateTime"):
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t
+		}
+	}
+	return s
+}
+
+// fluxString 生成 Flux 字符串字面量
+func fluxString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", `\${`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func fluxColumn(name string) string {
+	return "r[" + fluxString(name) + "]"
+}
+
+// parseInfluxTime 接受 RFC3339、日期或 Unix 秒/毫秒/纳秒时间戳
+func parseInfluxTime(v interface{}) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case float64:
+		return unixTime(int64(val)), nil
+	case int64:
+		return unixTime(val), nil
+	case json.Number:
+		i, err := val.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return unixTime(i), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, nil
+			}
+		}
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return unixTime(i), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time value: %v", v)
+}
+
+// unixTime 按数量级判断时间戳精度
+func unixTime(i int64) time.Time {
+	switch {
+	case i > 1e17 || i < -1e17:
+		return time.Unix(0, i)
+	case i > 1e11 || i < -1e11:
+		return time.UnixMilli(i)
+	default:
+		return time.Unix(i, 0)
+	}
+}
+
+func fluxTime(t time.Time) string {
+	return "time(v: " + fluxString(t.UTC().Format(time.RFC3339Nano)) + ")"
+}
+
+func fluxLiteral(field string, v interface{}) (string, error) {
+	if field == influxTimeField {
+		t, err := parseInfluxTime(v)
+		if err != nil {
+			return "", err
+		}
+		return fluxTime(t), nil
+	}
+	switch val := v.(type) {
+	case nil:
+		return "", fmt.Errorf("null value is not supported in influxdb filters")
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case float64:
+		s := strconv.FormatFloat(val, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	case json.Number:
+		return val.String(), nil
+	default:
+		return fluxString(fmt.Sprint(v)), nil
+	}
+}
+
+// likeToRegex 把 SQL LIKE 模式转换为正则
+func likeToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// fluxFilter 累积 filter 条件与时间范围
+type fluxFilter struct {
+	conds   []string
+	start   *time.Time
+	stop    *time.Time
+	imports map[string]struct{}
+}
+
+func (f *fluxFilter) add(cond string) {
+	f.conds = append(f.conds, cond)
+}
+
+func (f *fluxFilter) use(pkg string) {
+	if f.imports == nil {
+		f.imports = map[string]struct{}{}
+	}
+	f.imports[pkg] = struct{}{}
+}
+
+func (f *fluxFilter) equals(filter map[string]interface{}) error {
+	for k, v := range filter {
+		if v == nil {
+			f.add("not exists " + fluxColumn(k))
+			continue
+		}
+		lit, err := fluxLiteral(k, v)
+		if err != nil {
+			return err
+		}
+		f.add(fmt.Sprintf("%s == %s", fluxColumn(k), lit))
+	}
+	return nil
+}
+
+// queryFilters 与 applyGormQueryFilters 支持相同的操作符，_time 的上下界同时用于 range
+func (f *fluxFilter) queryFilters(filters url.Values) (bool, error) {
+	hasFilter := false
+	for key, values := range filters {
+		if isReservedQueryParam(key) || len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		hasFilter = true
+		fieldName, op := key, "="
+		if strings.Contains(key, "__") {
+			parts := strings.SplitN(key, "__", 2)
+			fieldName, op = parts[0], "__"+parts[1]
+		}
+		col := fluxColumn(fieldName)
+		literal := func(v interface{}) (string, error) {
+			return fluxLiteral(fieldName, v)
+		}
+		compare := func(sym string, v interface{}) error {
+			lit, err := literal(v)
+			if err != nil {
+				return err
+			}
+			f.add(fmt.Sprintf("%s %s %s", col, sym, lit))
+			return nil
+		}
+		parsedVal := parseFilterValue(value)
+		if fieldName == influxTimeField {
+			parsedVal = value
+		}
+		var err error
+		switch op {
+		case "=", "__eq":
+			err = compare("==", parsedVal)
+		case "__gte", "__gt":
+			if err = compare(map[string]string{"__gte": ">=", "__gt": ">"}[op], parsedVal); err == nil && fieldName == influxTimeField {
+				t, _ := parseInfluxTime(value)
+				f.start = &t
+			}
+		case "__lt", "__lte":
+			if err = compare(map[string]string{"__lt": "<", "__lte": "<="}[op], parsedVal); err == nil && fieldName == influxTimeField {
+				t, _ := parseInfluxTime(value)
+				stop := t.Add(time.Nanosecond)
+				f.stop = &stop
+			}
+		case "__ne":
+			err = compare("!=", parsedVal)
+		case "__like":
+			f.add(fmt.Sprintf("%s =~ regexp.compile(v: %s)", col, fluxString(likeToRegex(normalizeLikeValue(value)))))
+			f.use("regexp")
+		case "__icontains":
+			f.add(fmt.Sprintf("strings.containsStr(v: strings.toLower(v: string(v: %s)), substr: %s)", col, fluxString(strings.ToLower(normalizeLikeValue(value)))))
+			f.use("strings")
+		case "__in":
+			vals := parseFilterValues(value)
+			lits := make([]string, len(vals))
+			for i, v := range vals {
+				if fieldName == influxTimeField {
+					v = fmt.Sprint(v)
+				}
+				if lits[i], err = literal(v); err != nil {
+					return false, err
+				}
+			}
+			f.add(fmt.Sprintf("contains(value: %s, set: [%s])", col, strings.Join(lits, ", ")))
+		case "__isnull":
+			if bVal, ok := parsedVal.(bool); ok {
+				if bVal {
+					f.add("not exists " + col)
+				} else {
+					f.add("exists " + col)
+				}
+			}
+		case "__between":
+			if vals := strings.Split(value, ","); len(vals) == 2 {
+				lo, hi := parseFilterValue(strings.TrimSpace(vals[0])), parseFilterValue(strings.TrimSpace(vals[1]))
+				if fieldName == influxTimeField {
+					lo, hi = strings.TrimSpace(vals[0]), strings.TrimSpace(vals[1])
+				}
+				if err = compare(">=", lo); err == nil {
+					err = compare("<=", hi)
+				}
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return hasFilter, nil
+}
+
+func (f *fluxFilter) header() string {
+	var b strings.Builder
+	pkgs := make([]string, 0, len(f.imports))
+	for pkg := range f.imports {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		fmt.Fprintf(&b, "import %s\n", fluxString(pkg))
+	}
+	return b.String()
+}
+
+// source 生成 from |> range |> measurement 过滤部分，未指定起始时间时查询全部数据
+func (a *influxAdapter) source(tc *tableConfig, f *fluxFilter) string {
+	start, stop := "time(v: 0)", ""
+	if f.start != nil {
+		start = fluxTime(*f.start)
+	}
+	if f.stop != nil {
+		stop = ", stop: " + fluxTime(*f.stop)
+	}
+	return fmt.Sprintf("from(bucket: %s)\n  |> range(start: %s%s)\n  |> filter(fn: (r) => r._measurement == %s)\n",
+		fluxString(a.bucket), start, stop, fluxString(tc.Name))
+}
+
+// points 返回透视后的点集合（每个 _time + tag 组合一行）
+func (a *influxAdapter) points(tc *tableConfig, f *fluxFilter) string {
+	flux := f.header() + a.source(tc, f) +
+		"  |> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")\n" +
+		"  |> drop(columns: [\"_start\", \"_stop\", \"_measurement\"])\n" +
+		"  |> group()\n"
+	if len(f.conds) > 0 {
+		flux += "  |> filter(fn: (r) => " + strings.Join(f.conds, " and ") + ")\n"
+	}
+	return flux
+}
+
+func (a *influxAdapter) count(ctx context.Context, tc *tableConfig, f *fluxFilter) (int64, error) {
+	rows, err := a.query(ctx, a.points(tc, f)+"  |> count(column: \"_time\")\n")
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	cnt, _ := rows[0][influxTimeField].(int64)
+	return cnt, nil
+}
+
+func keepColumns(fields string) string {
+	if fields == "" {
+		return ""
+	}
+	cols := make([]string, 0)
+	for _, f := range strings.Split(fields, ",") {
+		cols = append(cols, fluxString(strings.TrimSpace(f)))
+	}
+	return "  |> keep(columns: [" + strings.Join(cols, ", ") + "])\n"
+}
+
+func (a *influxAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	f := &fluxFilter{}
+	hasFilter, err := f.queryFilters(params.QueryFilters)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if hasFilter {
+		if total, err = a.count(ctx, tc, f); err != nil {
+			return nil, 0, fmt.Errorf("failed to count records: %w", err)
+		}
+	}
+	order, desc := params.Order, false
+	if order == "" {
+		// 默认最新的点在前
+		order, desc = influxTimeField, true
+	} else if strings.HasPrefix(order, "-") {
+		order, desc = order[1:], true
+	}
+	flux := a.points(tc, f) +
+		fmt.Sprintf("  |> sort(columns: [%s], desc: %t)\n", fluxString(order), desc) +
+		fmt.Sprintf("  |> limit(n: %d, offset: %d)\n", params.PageSize, (params.Page-1)*params.PageSize) +
+		keepColumns(params.Fields)
+	results, err := a.query(ctx, flux)
+	if err != nil {
+		return nil, total, fmt.Errorf("failed to query database: %w", err)
+	}
+	return results, total, nil
+}
+
+// 按 line protocol 规则转义 measurement、tag/field 键与 tag 值、字符串 field 值
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	fieldStrEscaper    = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+func influxFieldValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case bool:
+		return strconv.FormatBool(val), true
+	case int:
+		return strconv.Itoa(val) + "i", true
+	case int64:
+		return strconv.FormatInt(val, 10) + "i", true
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), true
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return strconv.FormatInt(i, 10) + "i", true
+		}
+		return val.String(), true
+	case string:
+		return `"` + fieldStrEscaper.Replace(val) + `"`, true
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return `"` + fieldStrEscaper.Replace(string(data)) + `"`, true
+	default:
+		return `"` + fieldStrEscaper.Replace(fmt.Sprint(val)) + `"`, true
+	}
+}
+
+// lineProtocol 把记录转换为一行 line protocol，_time（或 timeseries.time_field）缺省时使用当前时间
+func lineProtocol(tc *tableConfig, record map[string]interface{}, now time.Time) (string, error) {
+	timeField := tc.TimeSeries.TimeField
+	if timeField == "" {
+		timeField = influxTimeField
+	}
+	ts := now
+	if v, ok := record[timeField]; ok && v != nil {
+		t, err := parseInfluxTime(v)
+		if err != nil {
+			return "", err
+		}
+		ts = t
+	}
+	isTag := map[string]bool{}
+	for _, t := range tc.TimeSeries.Tags {
+		isTag[t] = true
+	}
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		if k != timeField && k != influxTimeField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(tc.Name))
+	for _, k := range keys {
+		if v := record[k]; isTag[k] && v != nil && fmt.Sprint(v) != "" {
+			fmt.Fprintf(&b, ",%s=%s", tagEscaper.Replace(k), tagEscaper.Replace(fmt.Sprint(v)))
+		}
+	}
+	n := 0
+	for _, k := range keys {
+		if isTag[k] {
+			continue
+		}
+		val, ok := influxFieldValue(record[k])
+		if !ok {
+			continue
+		}
+		sep := ","
+		if n == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s=%s", sep, tagEscaper.Replace(k), val)
+		n++
+	}
+	if n == 0 {
+		return "", fmt.Errorf("record has no field values")
+	}
+	fmt.Fprintf(&b, " %d", ts.UnixNano())
+	return b.String(), nil
+}
+
+func (a *influxAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
+	now := time.Now()
+	var body strings.Builder
+	ids := make([]interface{}, len(records))
+	for i, record := range records {
+		// 同一批次未指定时间的点依次偏移 1ns，避免互相覆盖
+		line, err := lineProtocol(tc, record, now.Add(time.Duration(i)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+		ts := line[strings.LastIndexByte(line, ' ')+1:]
+		nanos, _ := strconv.ParseInt(ts, 10, 64)
+		ids[i] = time.Unix(0, nanos).UTC().Format(time.RFC3339Nano)
+	}
+	query := url.Values{"bucket": {a.bucket}, "precision": {"ns"}}
+	if _, err := a.do(ctx, http.MethodPost, "/api/v2/write", query, "text/plain; charset=utf-8", strings.NewReader(body.String())); err != nil {
+		return nil, nil, err
+	}
+	return ids, records, nil
+}
+
+func (a *influxAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
+	return 0, 0, errInfluxUpdateUnsupported
+}
+
+func (a *influxAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
+	return 0, 0, errInfluxUpdateUnsupported
+}
+
+// deletePoints 删除指定时刻的点，predicate 只支持 tag 等值条件
+func (a *influxAdapter) deletePoints(ctx context.Context, tc *tableConfig, t time.Time, tags map[string]interface{}) error {
+	preds := []string{"_measurement=" + fluxString(tc.Name)}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		preds = append(preds, fmt.Sprintf("%s=%s", k, fluxString(fmt.Sprint(tags[k]))))
+	}
+	body, _ := json.Marshal(map[string]string{
+		"start":     t.UTC().Format(time.RFC3339Nano),
+		"stop":      t.Add(time.Nanosecond).UTC().Format(time.RFC3339Nano),
+		"predicate": strings.Join(preds, " AND "),
+	})
+	_, err := a.do(ctx, http.MethodPost, "/api/v2/delete", url.Values{"bucket": {a.bucket}}, "application/json", bytes.NewReader(body))
+	return err
+}
+
+func (a *influxAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
+	var affected int64
+	for _, id := range ids {
+		t, err := parseInfluxTime(id)
+		if err != nil {
+			return affected, err
+		}
+		if err := a.deletePoints(ctx, tc, t, nil); err != nil {
+			return affected, err
+		}
+		affected++
+	}
+	return affected, nil
+}
+
+func (a *influxAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
+	f := &fluxFilter{}
+	if err := f.equals(filter); err != nil {
+		return nil, err
+	}
+	if v, ok := filter[influxTimeField]; ok {
+		// 精确时间点，缩小 range 避免全量扫描
+		t, err := parseInfluxTime(v)
+		if err != nil {
+			return nil, err
+		}
+		stop := t.Add(time.Nanosecond)
+		f.start, f.stop = &t, &stop
+	}
+	rows, err := a.query(ctx, a.points(tc, f)+"  |> limit(n: 1)\n"+keepColumns(fields))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+func (a *influxAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
+	v, ok := filter[influxTimeField]
+	if !ok {
+		return 0, fmt.Errorf("influxdb delete requires %s", influxTimeField)
+	}
+	t, err := parseInfluxTime(v)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := a.GetOne(ctx, tc, filter, ""); err != nil {
+		return 0, err
+	}
+	tags := make(map[string]interface{}, len(filter))
+	for k, val := range filter {
+		if k != influxTimeField {
+			tags[k] = val
+		}
+	}
+	if err := a.deletePoints(ctx, tc, t, tags); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (a *influxAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
+	return a.count(ctx, tc, &fluxFilter{})
+}
+
+func (a *influxAdapter) Close() error {
+	a.client.CloseIdleConnections()
+	return nil
+}
+
+var influxAggFuncs = map[string]string{"avg": "mean", "sum": "sum", "min": "min", "max": "max", "count": "count"}
+
+// TimeSeries 使用 aggregateWindow 分桶；field 值在透视前聚合，因此过滤条件只支持 tag 与 _time
+func (a *influxAdapter) TimeSeries(ctx context.Context, tc *tableConfig, q timeSeriesQuery) ([]map[string]interface{}, error) {
+	if len(q.Fields) == 0 {
+		return nil, fmt.Errorf("fields is required for influxdb timeseries")
+	}
+	if q.TimeField != influxTimeField && q.TimeField != tc.TimeSeries.TimeField {
+		return nil, &unknownFieldError{Field: q.TimeField}
+	}
+	isTag := map[string]bool{influxTimeField: true}
+	for _, t := range tc.TimeSeries.Tags {
+		isTag[t] = true
+	}
+	for key := range q.Filters {
+		if isReservedQueryParam(key) {
+			continue
+		}
+		if name, _, _ := strings.Cut(key, "__"); !isTag[name] {
+			return nil, fmt.Errorf("influxdb timeseries filters support only tags and %s: %s", influxTimeField, name)
+		}
+	}
+	f := &fluxFilter{}
+	if _, err := f.queryFilters(q.Filters); err != nil {
+		return nil, err
+	}
+	fieldConds := make([]string, len(q.Fields))
+	for i, field := range q.Fields {
+		fieldConds[i] = "r._field == " + fluxString(field)
+	}
+	groupCols := []string{fluxString("_field")}
+	rowKey := []string{fluxString("_time")}
+	for _, g := range q.GroupBy {
+		if !isTag[g] {
+			return nil, fmt.Errorf("influxdb timeseries group_by supports only tags: %s", g)
+		}
+		groupCols = append(groupCols, fluxString(g))
+		rowKey = append(rowKey, fluxString(g))
+	}
+	flux := f.header() + a.source(tc, f) +
+		"  |> filter(fn: (r) => " + strings.Join(fieldConds, " or ") + ")\n"
+	if len(f.conds) > 0 {
+		flux += "  |> filter(fn: (r) => " + strings.Join(f.conds, " and ") + ")\n"
+	}
+	flux += fmt.Sprintf("  |> group(columns: [%s])\n", strings.Join(groupCols, ", ")) +
+		fmt.Sprintf("  |> aggregateWindow(every: %ds, fn: %s, timeSrc: \"_start\", createEmpty: false)\n", int64(q.Interval/time.Second), influxAggFuncs[q.Agg]) +
+		fmt.Sprintf("  |> pivot(rowKey: [%s], columnKey: [\"_field\"], valueColumn: \"_value\")\n", strings.Join(rowKey, ", ")) +
+		"  |> group()\n" +
+		"  |> sort(columns: [\"_time\"])\n" +
+		fmt.Sprintf("  |> limit(n: %d)\n", q.Limit)
+	rows, err := a.query(ctx, flux)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = timeSeriesResult(q, func(key string) interface{} {
+			if key == timeSeriesBucketKey {
+				return row[influxTimeField]
+			}
+			idx, _ := strconv.Atoi(key[1:])
+			if key[0] == 'f' {
+				return row[q.Fields[idx]]
+			}
+			return row[q.GroupBy[idx]]
+		})
+	}
+	return result, nil
+}
+
+// ---- InfluxDB 元数据 ----
+func extractInfluxMeta(dsn, dbName string) ([]TableMeta, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	a, err := newInfluxAdapter(dsn, dbName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open influxdb bucket %s failed: %w", dbName, err)
+	}
+	defer a.Close()
+	retention, err := a.bucketRetention(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bucket := fluxString(dbName)
+	measurements, err := a.query(ctx, "import \"influxdata/influxdb/schema\"\nschema.measurements(bucket: "+bucket+", start: time(v: 0))")
+	if err != nil {
+		return nil, err
+	}
+	var tables []TableMeta
+	for _, m := range measurements {
+		name, _ := m["_value"].(string)
+		if name == "" || len(tables) >= 500 {
+			continue
+		}
+		measurement := fluxString(name)
+		tagRows, err := a.query(ctx, fmt.Sprintf("import \"influxdata/influxdb/schema\"\nschema.measurementTagKeys(bucket: %s, measurement: %s, start: time(v: 0))", bucket, measurement))
+		if err != nil {
+			return nil, err
+		}
+		tm := TableMeta{
+			Name:       name,
+			PrimaryKey: influxTimeField,
+			Fields:     []FieldMeta{{Name: influxTimeField, Type: "timestamp", IsPrimary: true, Comment: "point time"}},
+			TimeSeries: &TimeSeriesMeta{TimeField: influxTimeField, Retention: retention},
+		}
+		for _, r := range tagRows {
+			tag, _ := r["_value"].(string)
+			// schema 函数会返回内部列
+			if tag == "" || strings.HasPrefix(tag, "_") {
+				continue
+			}
+			tm.Fields = append(tm.Fields, FieldMeta{Name: tag, Type: "tag", Nullable: true, Default: convertDefaultByType("", "string", true)})
+			tm.TimeSeries.Tags = append(tm.TimeSeries.Tags, tag)
+		}
+		// 字段类型取自最后一个点的值类型
+		lastRows, err := a.query(ctx, fmt.Sprintf("from(bucket: %s)\n  |> range(start: time(v: 0))\n  |> filter(fn: (r) => r._measurement == %s)\n  |> last()\n  |> group(columns: [\"_field\"])\n  |> limit(n: 1)", bucket, measurement))
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(lastRows, func(i, j int) bool {
+			return fmt.Sprint(lastRows[i]["_field"]) < fmt.Sprint(lastRows[j]["_field"])
+		})
+		seen := map[string]bool{}
+		for _, r := range lastRows {
+			field, _ := r["_field"].(string)
+			if field == "" || seen[field] {
+				continue
+			}
+			seen[field] = true
+			typ := influxFieldType(r["_value"])
+			tm.Fields = append(tm.Fields, FieldMeta{Name: field, Type: typ, Nullable: true, Default: convertDefaultByType("", typ, true)})
+		}
+		tables = append(tables, tm)
+	}
+	return tables, nil
+}
+
+func influxFieldType(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "integer"
+	case uint64:
+		return "unsigned"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// bucketRetention 读取 bucket 的过期规则，0 表示永久保存
+func (a *influxAdapter) bucketRetention(ctx context.Context) (string, error) {
+	data, err := a.do(ctx, http.MethodGet, "/api/v2/buckets", url.Values{"name": {a.bucket}}, "", nil)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Buckets []struct {
+			RetentionRules []struct {
+				EverySeconds int64 `json:"everySeconds"`
+			} `json:"retentionRules"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Buckets) == 0 {
+		return "", fmt.Errorf("bucket %s not found", a.bucket)
+	}
+	for _, rule := range resp.Buckets[0].RetentionRules {
+		if rule.EverySeconds > 0 {
+			return formatRetention(time.Duration(rule.EverySeconds) * time.Second), nil
+		}
+	}
+	return "", nil
+}
+
+// formatRetention 按天输出整天数的保留期，与 timeseries.retention 的写法一致
+func formatRetention(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
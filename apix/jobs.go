@@ -0,0 +1,210 @@
+package apix
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 后台任务队列 ---------
+//
+// 异步批量、导出、同步、Webhook 等后台任务统一提交到 utils.JobQueue，
+// 支持优先级、失败重试（指数退避）、全局与按类型的并发限制：
+//
+//	job_queue:
+//	  store: data/queue        # KVStore 目录，任务持久化，重启后继续执行；为空则只保存在内存。表计数也保存在这里
+//	  workers: 4               # 全局并发上限
+//	  retention: 24h           # 已结束任务的保留时长
+//	  retry_delay: 5s          # 重试基础间隔
+//
+// 管理接口（受 operation_roles.manage_jobs 控制，默认 admin；其余调用者只能查看、取消、重试自己提交的 bulk 任务）：
+//
+//	GET    /api/jobs?type=bulk&status=failed   任务列表
+//	GET    /api/jobs/:id                       任务详情与进度
+//	DELETE /api/jobs/:id                       取消任务
+//	POST   /api/jobs/:id/retry                 重试失败或已取消的任务
+
+const jobTypeBulk = "bulk"
+
+type jobQueueConfig struct {
+	Store      string        `mapstructure:"store"`
+	Workers    int           `mapstructure:"workers"`
+	Retention  time.Duration `mapstructure:"retention"`
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+}
+
+func (dm *databaseManager) setupJobQueue() error {
+	// 定时任务先创建（校验计划表达式），队列启动后再开始调度
+	if err := dm.scheduleIndexAdvisor(); err != nil {
+		return err
+	}
+	if err := dm.scheduleArchive(); err != nil {
+		return err
+	}
+	if err := dm.setupRollups(); err != nil {
+		return err
+	}
+	if prev := dm.live.manager(); prev != dm {
+		// 配置切换时沿用当前的任务队列与 KVStore（KVStore 不能重复打开），定时任务在切换后启动，见 configswap.go
+		dm.store, dm.jobQueue = prev.store, prev.jobQueue
+		return nil
+	}
+	cfg := dm.config.JobQueue
+	var store *utils.KVStore
+	if cfg.Store != "" {
+		kv, err := utils.Open(cfg.Store)
+		if err != nil {
+			return err
+		}
+		store = kv
+		dm.store = kv
+	}
+	q := utils.NewJobQueue(store, cfg.Workers)
+	q.SetRetention(cfg.Retention)
+	if cfg.RetryDelay > 0 {
+		q.SetRetryDelay(cfg.RetryDelay)
+	}
+	// 注册需在 Start 之前完成，持久化的任务才能恢复执行；任务在执行时生效的管理器上运行
+	live := dm.live
+	q.Register(jobTypeBulk, live.job((*databaseManager).runBulkJob), dm.config.BulkJob.Workers)
+	q.Register(jobTypeIndexAdvice, live.job((*databaseManager).runIndexAdviceJob), 1)
+	q.Register(jobTypeArchive, live.job((*databaseManager).runArchiveJob), 1)
+	q.Register(jobTypeRollup, live.job((*databaseManager).runRollupJob), 1)
+	q.Register(jobTypeMirrorVerify, live.job((*databaseManager).runMirrorVerifyJob), 1)
+	q.Register(jobTypeWebhook, live.job((*databaseManager).runWebhookJob), 0)
+	dm.jobQueue = q
+	if err := q.Start(); err != nil {
+		return err
+	}
+	dm.startJobs()
+	return nil
+}
+
+// startJobs 清理批量任务暂存文件，为缺失的汇总表提交首次刷新并开始定时提交任务
+func (dm *databaseManager) startJobs() {
+	dm.sweepBulkSpool()
+	dm.enqueueMissingRollups()
+	for _, s := range dm.schedulers {
+		s.Start()
+	}
+}
+
+// stopSchedules 停止定时提交任务
+func (dm *databaseManager) stopSchedules() {
+	for _, s := range dm.schedulers {
+		s.Stop()
+	}
+	dm.schedulers = nil
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, utils.ErrJobNotCancellable), errors.Is(err, utils.ErrJobNotRetryable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// jobOwner 返回任务的提交者，bulk 任务记录提交时的调用者，其余任务为空
+func jobOwner(job utils.Job) string {
+	if job.Type != jobTypeBulk {
+		return ""
+	}
+	var payload struct {
+		Actor string `json:"actor"`
+	}
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return ""
+	}
+	return payload.Actor
+}
+
+// jobTarget 返回 bulk 任务的目标库与表，任务不存在或不是 bulk 任务时为空
+func (dm *databaseManager) jobTarget(id string) (string, string) {
+	if id == "" || dm.jobQueue == nil {
+		return "", ""
+	}
+	job, ok := dm.jobQueue.Get(id)
+	if !ok || job.Type != jobTypeBulk {
+		return "", ""
+	}
+	var payload bulkJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return "", ""
+	}
+	return payload.Database, payload.Table
+}
+
+// authorizeJob 有 manage_jobs 权限时可操作任意任务，否则只能操作自己提交的任务，不通过时写 403
+func (dm *databaseManager) authorizeJob(c *gin.Context, id string) bool {
+	if dm.allowed(c, nil, opManageJobs) {
+		return true
+	}
+	if actor := dm.currentActor(c); actor != "" {
+		if job, ok := dm.jobQueue.Get(id); ok && jobOwner(job) == actor {
+			return true
+		}
+	}
+	return dm.authorize(c, nil, opManageJobs)
+}
+
+func (dm *databaseManager) handleJobList(c *gin.Context) {
+	jobs := dm.jobQueue.List(c.Query("type"), c.Query("status"))
+	if !dm.allowed(c, nil, opManageJobs) {
+		actor := dm.currentActor(c)
+		if actor == "" {
+			dm.authorize(c, nil, opManageJobs)
+			return
+		}
+		own := jobs[:0]
+		for _, job := range jobs {
+			if jobOwner(job) == actor {
+				own = append(own, job)
+			}
+		}
+		jobs = own
+	}
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+func (dm *databaseManager) handleJobGet(c *gin.Context) {
+	if !dm.authorizeJob(c, c.Param("id")) {
+		return
+	}
+	job, ok := dm.jobQueue.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (dm *databaseManager) handleJobCancel(c *gin.Context) {
+	if !dm.authorizeJob(c, c.Param("id")) {
+		return
+	}
+	if err := dm.jobQueue.Cancel(c.Param("id")); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cancel requested"})
+}
+
+func (dm *databaseManager) handleJobRetry(c *gin.Context) {
+	if !dm.authorizeJob(c, c.Param("id")) {
+		return
+	}
+	if err := dm.jobQueue.Retry(c.Param("id")); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job requeued"})
+}
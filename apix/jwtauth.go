@@ -0,0 +1,314 @@
+package apix
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// --------- JWT 认证 ---------
+//
+// 配置后，REST（含 admin、jobs、meta）接口从 Authorization: Bearer <token> 中解析调用者，
+// GraphQL 直连查询同样校验调用者转发的 Authorization：
+//
+//	auth:
+//	  jwt:
+//	    secret: "..."                       # HS256/HS384/HS512
+//	    jwks_url: https://idp/.well-known/jwks.json   # RS*/PS*/ES*，按 kid 选择公钥
+//	    jwks_refresh: 1h                    # 公钥刷新间隔，遇到未知 kid 时也会刷新（最多每分钟一次）
+//	    issuer: https://idp                 # 校验 iss，可选
+//	    audience: ego                       # 校验 aud，可选
+//	    leeway: 30s                         # exp/nbf 的时钟误差
+//	    actor_claim: sub                    # 调用者标识，默认 sub
+//	    roles_claim: roles                  # 角色，数组或逗号分隔的字符串，默认 roles；支持 realm_access.roles 形式的路径
+//	    allow_anonymous: false              # true 时没有 token 的请求按匿名处理，默认返回 401
+//
+// secret 与 jwks_url 至少配置一个。token 无效时返回 401。开启后 auth.actor_header、auth.roles_header 不再生效，
+// 调用者身份与角色只来自 token；token 中的 claims 可用于表配置中的 row_filter（见 rowfilter.go）。
+
+const (
+	defaultJWTActorClaim  = "sub"
+	defaultJWTRolesClaim  = "roles"
+	defaultJWKSRefresh    = time.Hour
+	jwksMinRefreshBackoff = time.Minute
+)
+
+var errNoBearerToken = errors.New("missing bearer token")
+
+type jwtConfig struct {
+	Secret         string        `mapstructure:"secret"`
+	JWKSURL        string        `mapstructure:"jwks_url"`
+	JWKSRefresh    time.Duration `mapstructure:"jwks_refresh"`
+	Issuer         string        `mapstructure:"issuer"`
+	Audience       string        `mapstructure:"audience"`
+	Leeway         time.Duration `mapstructure:"leeway"`
+	ActorClaim     string        `mapstructure:"actor_claim"`
+	RolesClaim     string        `mapstructure:"roles_claim"`
+	AllowAnonymous bool          `mapstructure:"allow_anonymous"`
+}
+
+func (c jwtConfig) enabled() bool {
+	return c.Secret != "" || c.JWKSURL != ""
+}
+
+type jwtVerifier struct {
+	cfg     jwtConfig
+	methods []string
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{} // kid → 公钥
+	fetchedAt time.Time
+}
+
+func newJWTVerifier(cfg jwtConfig) *jwtVerifier {
+	if cfg.ActorClaim == "" {
+		cfg.ActorClaim = defaultJWTActorClaim
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = defaultJWTRolesClaim
+	}
+	if cfg.JWKSRefresh <= 0 {
+		cfg.JWKSRefresh = defaultJWKSRefresh
+	}
+	v := &jwtVerifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+	if cfg.Secret != "" {
+		v.methods = append(v.methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.JWKSURL != "" {
+		v.methods = append(v.methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+	return v
+}
+
+// authenticate 校验 Authorization 头中的 token 并返回 claims；没有 token 时返回 errNoBearerToken
+func (v *jwtVerifier) authenticate(ctx context.Context, authorization string) (map[string]interface{}, error) {
+	scheme, token, _ := strings.Cut(strings.TrimSpace(authorization), " ")
+	if authorization == "" || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, errNoBearerToken
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(v.methods), jwt.WithLeeway(v.cfg.Leeway)}
+	if v.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.cfg.Issuer))
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.Audience))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, func(t *jwt.Token) (interface{}, error) {
+		if strings.HasPrefix(t.Method.Alg(), "HS") {
+			return []byte(v.cfg.Secret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.publicKey(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// principal 按配置的 claim 取出调用者标识与角色
+func (v *jwtVerifier) principal(claims map[string]interface{}) *principal {
+	p := &principal{}
+	if id, ok := claimValue(claims, v.cfg.ActorClaim); ok && id != nil {
+		p.ID = fmt.Sprint(id)
+	}
+	switch roles, _ := claimValue(claims, v.cfg.RolesClaim); r := roles.(type) {
+	case []interface{}:
+		for _, role := range r {
+			p.Roles = append(p.Roles, fmt.Sprint(role))
+		}
+	case string:
+		p.Roles = strings.FieldsFunc(r, func(c rune) bool { return c == ',' || c == ' ' })
+	}
+	return p
+}
+
+// claimValue 按 a.b.c 形式的路径取 claim
+func claimValue(claims map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// ---- JWKS ----
+
+func (v *jwtVerifier) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	if v.cfg.JWKSURL == "" {
+		return nil, errors.New("no jwks_url configured for asymmetric tokens")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) > v.cfg.JWKSRefresh
+	if ok && !stale {
+		return key, nil
+	}
+	// 未知 kid 时刷新，但限制频率，避免伪造的 kid 打满 IdP
+	if stale || time.Since(v.fetchedAt) > jwksMinRefreshBackoff {
+		keys, err := v.fetchJWKS(ctx)
+		if err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		v.keys, v.fetchedAt = keys, time.Now()
+		key, ok = v.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *jwtVerifier) fetchJWKS(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks failed: status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("parse jwks failed: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// ---- 中间件 ----
+
+type jwtClaimsKey struct{}
+
+// requestClaims 返回当前请求 token 中的 claims，未认证时为 nil
+func requestClaims(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(jwtClaimsKey{}).(map[string]interface{})
+	return claims
+}
+
+// withJWT 校验 token，写入调用者身份与 claims；未配置 jwt 或已由 API Key 认证时直接放行
+func (dm *databaseManager) withJWT(c *gin.Context) {
+	if dm.jwt == nil || authenticatedByAPIKey(c) {
+		c.Next()
+		return
+	}
+	claims, err := dm.jwt.authenticate(c.Request.Context(), c.GetHeader("Authorization"))
+	if errors.Is(err, errNoBearerToken) && dm.jwt.cfg.AllowAnonymous {
+		// 匿名请求不回退到 actor_header、roles_header
+		setPrincipal(c, nil)
+		c.Next()
+		return
+	}
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+		return
+	}
+	setPrincipal(c, dm.jwt.principal(claims))
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jwtClaimsKey{}, claims))
+	c.Next()
+}
+
+// graphqlClaims GraphQL 直连查询按转发的 Authorization 校验 token，返回带 claims 的 context
+func (dm *databaseManager) graphqlClaims(ctx context.Context) (context.Context, error) {
+	if dm.jwt == nil {
+		return ctx, nil
+	}
+	claims, err := dm.jwt.authenticate(ctx, incomingHeader(ctx, "Authorization"))
+	if errors.Is(err, errNoBearerToken) && dm.jwt.cfg.AllowAnonymous {
+		return ctx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unauthorized: %w", err)
+	}
+	return context.WithValue(ctx, jwtClaimsKey{}, claims), nil
+}
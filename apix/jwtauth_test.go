@@ -0,0 +1,152 @@
+package apix
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTSecret = "test-secret"
+
+func signHS(t *testing.T, method jwt.SigningMethod, key []byte, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestJWTAuthenticateSecret(t *testing.T) {
+	now := time.Now()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rsToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "u1"}).SignedString(rsaKey)
+	assert.NoError(t, err)
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "u1"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+
+	cfg := jwtConfig{Secret: testJWTSecret, Issuer: "https://idp", Audience: "ego", Leeway: 30 * time.Second}
+	cases := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{"valid", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"sub": "u1", "iss": "https://idp", "aud": "ego", "exp": now.Add(time.Minute).Unix()}), true},
+		{"lowercase scheme", "bearer " + signHS(t, jwt.SigningMethodHS512, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp", "aud": []string{"other", "ego"}}), true},
+		{"expired", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp", "aud": "ego", "exp": now.Add(-time.Minute).Unix()}), false},
+		{"expired within leeway", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp", "aud": "ego", "exp": now.Add(-10 * time.Second).Unix()}), true},
+		{"not yet valid", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp", "aud": "ego", "nbf": now.Add(time.Hour).Unix()}), false},
+		{"wrong audience", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp", "aud": "other"}), false},
+		{"missing audience", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://idp"}), false},
+		{"wrong issuer", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte(testJWTSecret), jwt.MapClaims{
+			"iss": "https://evil", "aud": "ego"}), false},
+		{"wrong secret", "Bearer " + signHS(t, jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{
+			"iss": "https://idp", "aud": "ego"}), false},
+		{"alg none", "Bearer " + noneToken, false},
+		{"asymmetric without jwks", "Bearer " + rsToken, false},
+		{"no token", "", false},
+		{"basic scheme", "Basic dXNlcjpwYXNz", false},
+	}
+	v := newJWTVerifier(cfg)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.authenticate(context.Background(), tc.header)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticateJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	pub := &rsaKey.PublicKey
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	}))
+	defer srv.Close()
+
+	signRS := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(rsaKey)
+		assert.NoError(t, err)
+		return s
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	assert.NoError(t, err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	cases := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", signRS("k1", jwt.MapClaims{"sub": "u1", "aud": "ego"}), true},
+		{"unknown kid", signRS("k2", jwt.MapClaims{"sub": "u1", "aud": "ego"}), false},
+		{"wrong audience", signRS("k1", jwt.MapClaims{"sub": "u1", "aud": "other"}), false},
+		{"expired", signRS("k1", jwt.MapClaims{"sub": "u1", "aud": "ego", "exp": time.Now().Add(-time.Hour).Unix()}), false},
+		// 用公钥作为 HMAC 密钥签名（算法混淆），只配置 jwks_url 时不接受 HS*
+		{"hs256 signed with public key", signHS(t, jwt.SigningMethodHS256, pubPEM, jwt.MapClaims{"sub": "u1", "aud": "ego"}), false},
+		{"hs256 signed with empty secret", signHS(t, jwt.SigningMethodHS256, []byte{}, jwt.MapClaims{"sub": "u1", "aud": "ego"}), false},
+	}
+	v := newJWTVerifier(jwtConfig{JWKSURL: srv.URL, Audience: "ego"})
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.authenticate(context.Background(), "Bearer "+tc.token)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTPrincipal(t *testing.T) {
+	cases := []struct {
+		name   string
+		cfg    jwtConfig
+		claims map[string]interface{}
+		id     string
+		roles  []string
+	}{
+		{"defaults", jwtConfig{}, map[string]interface{}{"sub": "u1", "roles": []interface{}{"admin", "reader"}}, "u1", []string{"admin", "reader"}},
+		{"comma separated roles", jwtConfig{}, map[string]interface{}{"sub": "u1", "roles": "admin, reader"}, "u1", []string{"admin", "reader"}},
+		{"nested roles claim", jwtConfig{ActorClaim: "email", RolesClaim: "realm_access.roles"},
+			map[string]interface{}{"email": "a@b.c", "realm_access": map[string]interface{}{"roles": []interface{}{"editor"}}}, "a@b.c", []string{"editor"}},
+		{"missing claims", jwtConfig{}, map[string]interface{}{}, "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newJWTVerifier(tc.cfg).principal(tc.claims)
+			assert.Equal(t, tc.id, p.ID)
+			assert.Equal(t, tc.roles, p.Roles)
+		})
+	}
+}
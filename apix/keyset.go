@@ -0,0 +1,264 @@
+package apix
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+)
+
+// --------- 游标（keyset）分页 ---------
+//
+// 大表使用 page 翻到后面时 OFFSET 需要扫描并丢弃前面所有行，越翻越慢。列表接口带上 cursor 参数时改为按排序列定位：
+//
+//	GET /api/rest/test/user?cursor=&page_size=100              第一页
+//	GET /api/rest/test/user?cursor=<next_cursor>&page_size=100 下一页
+//	GET /api/rest/test/user?cursor=&order=-id                  倒序
+//
+// 响应在还有下一页时带 next_cursor，最后一页没有。默认按主键排序，表配置可指定其他排序列，
+// 此时以主键作为第二排序列保证顺序稳定（排序列不应为 NULL）：
+//
+//	cursor_field: created_time
+//
+// 游标模式下忽略 page，order 只能是排序列本身（升序或降序）；游标与排序列、方向绑定，不能混用。
+// 过滤条件照常生效，total 与普通列表相同。仅支持 SQL 与 MongoDB 适配器，其他适配器返回 501。
+
+// keysetPage 为游标分页的参数，Columns 的最后一列为主键（排序列即主键时只有一列）
+type keysetPage struct {
+	Columns []string
+	Desc    bool
+	After   []interface{} // 上一页最后一条记录的排序列值，第一页为 nil
+}
+
+// keysetLister 支持游标分页的适配器
+type keysetLister interface {
+	supportsKeysetPagination()
+}
+
+func (a *gormAdapter) supportsKeysetPagination()  {}
+func (a *mongoAdapter) supportsKeysetPagination() {}
+
+type keysetCursor struct {
+	Key    string        `json:"k"`
+	Values []interface{} `json:"v"`
+	Types  []string      `json:"t,omitempty"`
+}
+
+const (
+	keysetTypeTime     = "time"
+	keysetTypeObjectID = "oid"
+)
+
+func (k *keysetPage) signature() string {
+	sig := strings.Join(k.Columns, ",")
+	if k.Desc {
+		sig = "-" + sig
+	}
+	return sig
+}
+
+// nextCursor 用一页中最后一条记录生成下一页的游标
+func (k *keysetPage) nextCursor(last map[string]interface{}) string {
+	cur := keysetCursor{Key: k.signature(), Values: make([]interface{}, len(k.Columns)), Types: make([]string, len(k.Columns))}
+	typed := false
+	for i, col := range k.Columns {
+		switch v := last[col].(type) {
+		case time.Time:
+			cur.Values[i], cur.Types[i] = v.Format(time.RFC3339Nano), keysetTypeTime
+		case primitive.DateTime:
+			cur.Values[i], cur.Types[i] = v.Time().Format(time.RFC3339Nano), keysetTypeTime
+		case primitive.ObjectID:
+			cur.Values[i], cur.Types[i] = v.Hex(), keysetTypeObjectID
+		case []byte:
+			cur.Values[i] = string(v)
+		default:
+			cur.Values[i] = v
+		}
+		typed = typed || cur.Types[i] != ""
+	}
+	if !typed {
+		cur.Types = nil
+	}
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor 解析游标并还原排序列值的类型
+func (k *keysetPage) decodeCursor(s string) error {
+	invalid := fmt.Errorf("invalid cursor for order %s", k.signature())
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return invalid
+	}
+	var cur keysetCursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&cur); err != nil || cur.Key != k.signature() || len(cur.Values) != len(k.Columns) {
+		return invalid
+	}
+	if cur.Types != nil && len(cur.Types) != len(cur.Values) {
+		return invalid
+	}
+	for i, v := range cur.Values {
+		typ := ""
+		if cur.Types != nil {
+			typ = cur.Types[i]
+		}
+		switch typ {
+		case keysetTypeTime:
+			t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v))
+			if err != nil {
+				return invalid
+			}
+			cur.Values[i] = t
+		case keysetTypeObjectID:
+			oid, err := primitive.ObjectIDFromHex(fmt.Sprint(v))
+			if err != nil {
+				return invalid
+			}
+			cur.Values[i] = oid
+		default:
+			if n, ok := v.(json.Number); ok {
+				if n64, err := n.Int64(); err == nil {
+					cur.Values[i] = n64
+				} else if f, err := n.Float64(); err == nil {
+					cur.Values[i] = f
+				}
+			}
+		}
+	}
+	k.After = cur.Values
+	return nil
+}
+
+// parseKeyset 读取列表请求中的 cursor 参数，未带该参数时返回 nil；query 为物理列名的查询参数
+func parseKeyset(tc *tableConfig, query url.Values) (*keysetPage, error) {
+	values, ok := query[queryParamCursor]
+	if !ok {
+		return nil, nil
+	}
+	column := tc.CursorField
+	if column == "" {
+		column = tc.PrimaryKey
+	}
+	if column == "" {
+		return nil, fmt.Errorf("cursor pagination requires a primary key or cursor_field")
+	}
+	k := &keysetPage{Columns: []string{column}}
+	if tc.PrimaryKey != "" && column != tc.PrimaryKey {
+		k.Columns = append(k.Columns, tc.PrimaryKey)
+	}
+	switch order := query.Get(queryParamOrder); order {
+	case "", column:
+	case "-" + column:
+		k.Desc = true
+	default:
+		return nil, fmt.Errorf("cursor pagination only supports order=%s or order=-%s", tc.apiFieldName(column), tc.apiFieldName(column))
+	}
+	if len(values) > 0 && values[0] != "" {
+		if err := k.decodeCursor(values[0]); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// withColumns 确保 fields 包含排序列，返回新的 fields 与需要在响应中去掉的列
+func (k *keysetPage) withColumns(fields string) (string, []string) {
+	if fields == "" {
+		return fields, nil
+	}
+	selected := map[string]bool{}
+	for _, f := range strings.Split(fields, ",") {
+		selected[strings.TrimSpace(f)] = true
+	}
+	var added []string
+	for _, col := range k.Columns {
+		if !selected[col] {
+			added = append(added, col)
+			fields += "," + col
+		}
+	}
+	return fields, added
+}
+
+// ---- gorm ----
+
+func applyGormKeyset(db *gorm.DB, k *keysetPage) *gorm.DB {
+	dir, op := "ASC", ">"
+	if k.Desc {
+		dir, op = "DESC", "<"
+	}
+	if k.After != nil {
+		after := k.After
+		if db.Dialector.Name() == "sqlite" {
+			// SQLite 以文本保存时间，按常见的存储格式比较，驱动默认格式带时区后缀
+			after = make([]interface{}, len(k.After))
+			for i, v := range k.After {
+				if t, ok := v.(time.Time); ok {
+					v = t.UTC().Format("2006-01-02 15:04:05.999999999")
+				}
+				after[i] = v
+			}
+		}
+		// (c1 > v1) OR (c1 = v1 AND c2 > v2)
+		var terms []string
+		var args []interface{}
+		for i, col := range k.Columns {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, fmt.Sprintf("%s = ?", k.Columns[j]))
+				args = append(args, after[j])
+			}
+			parts = append(parts, fmt.Sprintf("%s %s ?", col, op))
+			args = append(args, after[i])
+			terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		}
+		db = db.Where(strings.Join(terms, " OR "), args...)
+	}
+	for _, col := range k.Columns {
+		db = db.Order(fmt.Sprintf("%s %s", col, dir))
+	}
+	return db
+}
+
+// ---- mongo ----
+
+func applyMongoKeyset(filter bson.M, k *keysetPage) (bson.M, bson.D) {
+	dir, op := 1, "$gt"
+	if k.Desc {
+		dir, op = -1, "$lt"
+	}
+	sort := bson.D{}
+	for _, col := range k.Columns {
+		sort = append(sort, bson.E{Key: col, Value: dir})
+	}
+	if k.After == nil {
+		return filter, sort
+	}
+	var or bson.A
+	for i, col := range k.Columns {
+		term := bson.M{}
+		for j := 0; j < i; j++ {
+			term[k.Columns[j]] = k.After[j]
+		}
+		term[col] = bson.M{op: k.After[i]}
+		or = append(or, term)
+	}
+	cond := bson.M{"$or": or}
+	if and, ok := filter["$and"].(bson.A); ok {
+		filter["$and"] = append(and, cond)
+	} else if len(filter) == 0 {
+		filter = cond
+	} else {
+		filter = bson.M{"$and": bson.A{filter, cond}}
+	}
+	return filter, sort
+}
@@ -0,0 +1,216 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------- 参照表名称关联 ---------
+//
+// 列表与单条查询的响应中，按外键补充参照表（同库）的名称字段，不支持通用的 join：
+//
+//	label_joins:
+//	  - "status_id -> statuses.name as status_name"   # 按 statuses 主键匹配
+//	  - "owner_code -> users(code).nickname"          # 指定匹配列；省略 as 时字段名为 owner_code_nickname
+//
+// 参照表按表名或表别名查找，整表的 键 → 名称 映射缓存在内存中，过期后在下一次使用时重新加载：
+//
+//	label_join_cache:
+//	  ttl: "5m"
+//	  max_rows: 10000    # 参照表最多加载的行数，超出部分取不到名称
+//
+// 找不到对应名称时字段为 null；参照表读取失败只记录日志，不影响主查询。
+// 生成的字段在字段别名之前加入记录，swagger 中为只读字段。
+
+type labelJoinCacheConfig struct {
+	TTL     time.Duration `mapstructure:"ttl"`
+	MaxRows int           `mapstructure:"max_rows"`
+}
+
+type labelJoin struct {
+	Field string // 本表外键列
+	Table string // 参照表名或别名
+	Key   string // 参照表匹配列，为空时使用主键
+	Label string // 参照表名称列
+	As    string // 输出字段
+}
+
+var labelJoinRe = regexp.MustCompile(`^(\w+)\s*(?:->|→)\s*(\w+)(?:\((\w+)\))?\.(\w+)(?:\s+as\s+(\w+))?$`)
+
+func parseLabelJoin(s string) (labelJoin, error) {
+	m := labelJoinRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return labelJoin{}, fmt.Errorf("invalid label join %q, expected \"field -> table.label as name\"", s)
+	}
+	j := labelJoin{Field: m[1], Table: m[2], Key: m[3], Label: m[4], As: m[5]}
+	if j.As == "" {
+		j.As = j.Field + "_" + j.Label
+	}
+	return j, nil
+}
+
+// checkLabelJoins 解析表配置中的 label_joins，并确认参照表在同一个库中
+func checkLabelJoins(dbAlias string, tables []tableConfig) error {
+	for i := range tables {
+		tc := &tables[i]
+		tc.labelJoins = nil
+		for _, s := range tc.LabelJoins {
+			j, err := parseLabelJoin(s)
+			if err != nil {
+				return fmt.Errorf("table %s in db %s: %w", tc.Name, dbAlias, err)
+			}
+			ref := findTableConfig(tables, j.Table)
+			if ref == nil {
+				return fmt.Errorf("table %s in db %s: label join references unknown table '%s'", tc.Name, dbAlias, j.Table)
+			}
+			if j.Key == "" {
+				j.Key = ref.PrimaryKey
+			}
+			if j.Key == "" {
+				return fmt.Errorf("table %s in db %s: reference table '%s' has no primary key, specify table(key)", tc.Name, dbAlias, j.Table)
+			}
+			tc.labelJoins = append(tc.labelJoins, j)
+		}
+	}
+	return nil
+}
+
+func findTableConfig(tables []tableConfig, nameOrAlias string) *tableConfig {
+	for i := range tables {
+		if tables[i].Name == nameOrAlias {
+			return &tables[i]
+		}
+	}
+	for i := range tables {
+		if tables[i].Alias == nameOrAlias {
+			return &tables[i]
+		}
+	}
+	return nil
+}
+
+type labelMap struct {
+	mu       sync.Mutex
+	labels   map[string]interface{}
+	loadedAt time.Time
+}
+
+type labelCache struct {
+	mu   sync.Mutex
+	maps map[string]*labelMap
+}
+
+func (c *labelCache) get(key string) *labelMap {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maps == nil {
+		c.maps = map[string]*labelMap{}
+	}
+	m, ok := c.maps[key]
+	if !ok {
+		m = &labelMap{}
+		c.maps[key] = m
+	}
+	return m
+}
+
+// referenceLabels 返回参照表的 键 → 名称 映射，过期时重新加载；加载失败时沿用旧数据
+func (dm *databaseManager) referenceLabels(ctx context.Context, dbName string, j labelJoin) map[string]interface{} {
+	m := dm.labelCache.get(strings.Join([]string{dbName, j.Table, j.Key, j.Label}, "\x00"))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg := dm.config.LabelJoinCache
+	if m.labels != nil && (cfg.TTL <= 0 || time.Since(m.loadedAt) < cfg.TTL) {
+		return m.labels
+	}
+	labels, err := dm.loadReferenceLabels(ctx, dbName, j, cfg.MaxRows)
+	if err != nil {
+		log.Printf("[LabelJoin] load %s.%s failed: %v", dbName, j.Table, err)
+		return m.labels
+	}
+	m.labels, m.loadedAt = labels, time.Now()
+	return m.labels
+}
+
+func (dm *databaseManager) loadReferenceLabels(ctx context.Context, dbName string, j labelJoin, maxRows int) (map[string]interface{}, error) {
+	dm.mutex.RLock()
+	adapter, ok := dm.adapters[dbName]
+	dbCfg := dm.config.Databases[dbName]
+	dm.mutex.RUnlock()
+	ref := findTableConfig(dbCfg.Tables, j.Table)
+	if !ok || ref == nil {
+		return nil, fmt.Errorf("reference table not configured")
+	}
+	if maxRows <= 0 {
+		maxRows = 10000
+	}
+	rows, _, err := adapter.List(ctx, ref, listParams{Page: 1, PageSize: maxRows, Fields: j.Key + "," + j.Label})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) >= maxRows {
+		log.Printf("[LabelJoin] %s.%s reached max_rows %d, remaining rows are not cached", dbName, j.Table, maxRows)
+	}
+	labels := make(map[string]interface{}, len(rows))
+	for _, row := range rows {
+		if k := row[j.Key]; k != nil {
+			labels[fmt.Sprint(k)] = row[j.Label]
+		}
+	}
+	return labels, nil
+}
+
+// applyLabelJoins 为记录补充参照表名称字段
+func (dm *databaseManager) applyLabelJoins(ctx context.Context, dbName string, tc *tableConfig, records ...map[string]interface{}) {
+	for _, j := range tc.labelJoins {
+		labels := dm.referenceLabels(ctx, dbName, j)
+		for _, record := range records {
+			if record == nil {
+				continue
+			}
+			v, ok := record[j.Field]
+			if !ok {
+				// 指定了 fields 且未包含外键列
+				continue
+			}
+			if v == nil {
+				record[j.As] = nil
+				continue
+			}
+			record[j.As] = labels[fmt.Sprint(v)]
+		}
+	}
+}
+
+// ---- swagger ----
+
+// labelJoinFields 返回表配置中 label_joins 生成的字段，用于生成 swagger
+func labelJoinFields(specs []string) []string {
+	var fields []string
+	for _, s := range specs {
+		if j, err := parseLabelJoin(s); err == nil {
+			fields = append(fields, j.As)
+		}
+	}
+	return fields
+}
+
+// applyLabelJoinMeta 将名称字段加入表元数据的只读字段
+func applyLabelJoinMeta(t TableMeta, fields []string) TableMeta {
+	if len(fields) == 0 {
+		return t
+	}
+	for _, f := range fields {
+		if !contains(t.Computed, f) {
+			t.Computed = append(t.Computed, f)
+		}
+	}
+	sort.Strings(t.Computed)
+	return t
+}
@@ -0,0 +1,317 @@
+package apix
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 维护模式 ---------
+//
+// 计划维护期间把整个服务、某个库或某张表切换为只读或停用，无需重新部署。
+// 可在 _base.yaml（全局）、库配置与表配置中声明：
+//
+//	maintenance:
+//	  mode: read_only            # read_only：写请求返回 405；disabled：所有请求返回 503；off：不限制
+//	  message: "订单表迁移中，预计 22:00 恢复"
+//	  retry_after: 30m           # disabled 时通过 Retry-After 头告知客户端
+//
+// 也可在运行时通过管理接口切换（受 operation_roles.maintenance 控制，默认 admin），只保存在内存中，重启后以配置为准：
+//
+//	GET    /api/admin/maintenance                                   列出配置与运行时设置
+//	PUT    /api/admin/maintenance  {"database": "test", "table": "user", "mode": "disabled", "message": "..."}
+//	DELETE /api/admin/maintenance?database=test&table=user          撤销运行时设置，恢复配置
+//
+// database 与 table 均为别名，都为空时作用于全局。表、库、全局三级中取最严格的设置（disabled 优先于 read_only）；
+// 同一级运行时设置优先于配置，因此可以用 off 临时解除该级配置中的维护。
+// batch_get 与 aggregate_pipeline 虽为 POST 但只读取数据，只读模式下不受限制；GraphQL 查询同样遵循该设置。
+
+const (
+	maintenanceReadOnly = "read_only"
+	maintenanceDisabled = "disabled"
+	maintenanceOff      = "off"
+)
+
+type maintenanceConfig struct {
+	Mode       string        `mapstructure:"mode" json:"mode"`
+	Message    string        `mapstructure:"message" json:"message,omitempty"`
+	RetryAfter time.Duration `mapstructure:"retry_after" json:"-"`
+}
+
+type maintenanceEntry struct {
+	Database   string `json:"database,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Mode       string `json:"mode"`
+	Message    string `json:"message,omitempty"`
+	RetryAfter string `json:"retry_after,omitempty"`
+	Source     string `json:"source"` // config | runtime
+	Since      string `json:"since,omitempty"`
+}
+
+// maintenanceOverrides 运行时设置，键为 ""（全局）、库别名或 库别名/表别名
+type maintenanceOverrides struct {
+	mu    sync.RWMutex
+	modes map[string]maintenanceConfig
+	since map[string]time.Time
+}
+
+func newMaintenanceOverrides() *maintenanceOverrides {
+	return &maintenanceOverrides{modes: map[string]maintenanceConfig{}, since: map[string]time.Time{}}
+}
+
+func maintenanceKey(database, table string) string {
+	if table == "" {
+		return database
+	}
+	return database + "/" + table
+}
+
+func checkMaintenanceConfig(scope string, m maintenanceConfig) error {
+	switch m.Mode {
+	case "", maintenanceReadOnly, maintenanceDisabled, maintenanceOff:
+		return nil
+	}
+	return fmt.Errorf("%s: invalid maintenance mode '%s', expected read_only, disabled or off", scope, m.Mode)
+}
+
+// maintenanceFor 返回库或表当前生效的维护设置（各级中最严格的一个），未处于维护时 Mode 为空
+func (dm *databaseManager) maintenanceFor(dbName string, tc *tableConfig) (maintenanceConfig, string) {
+	dm.mutex.RLock()
+	dbCfg := dm.config.Databases[dbName]
+	dm.mutex.RUnlock()
+	type level struct {
+		key    string
+		config maintenanceConfig
+		scope  string
+	}
+	levels := make([]level, 0, 3)
+	if tc != nil {
+		levels = append(levels, level{maintenanceKey(dbName, tc.Alias), tc.Maintenance, maintenanceScopeName(dbName, tc.Alias)})
+	}
+	levels = append(levels,
+		level{dbName, dbCfg.Maintenance, maintenanceScopeName(dbName, "")},
+		level{"", dm.config.Maintenance, maintenanceScopeName("", "")})
+
+	dm.maintenance.mu.RLock()
+	defer dm.maintenance.mu.RUnlock()
+	var effective maintenanceConfig
+	var scope string
+	for _, l := range levels {
+		m, ok := dm.maintenance.modes[l.key]
+		if !ok {
+			m = l.config
+		}
+		if m.Mode == maintenanceDisabled || (m.Mode == maintenanceReadOnly && effective.Mode == "") {
+			effective, scope = m, l.scope
+		}
+	}
+	return effective, scope
+}
+
+// maintenanceError 判断请求是否被维护模式拒绝，返回状态码与提示
+func (dm *databaseManager) maintenanceError(dbName string, tc *tableConfig, write bool) (int, maintenanceConfig, string) {
+	m, scope := dm.maintenanceFor(dbName, tc)
+	switch {
+	case m.Mode == maintenanceDisabled:
+		if m.Message == "" {
+			m.Message = scope + " is under maintenance"
+		}
+		return http.StatusServiceUnavailable, m, m.Message
+	case m.Mode == maintenanceReadOnly && write:
+		if m.Message == "" {
+			m.Message = scope + " is read-only for maintenance"
+		}
+		return http.StatusMethodNotAllowed, m, m.Message
+	}
+	return 0, m, ""
+}
+
+// maintenanceGuard 按维护设置拒绝请求：停用返回 503，只读时写请求返回 405
+func (dm *databaseManager) maintenanceGuard(c *gin.Context) {
+	dbName := c.Param("database")
+	dm.mutex.RLock()
+	_, known := dm.config.Databases[dbName]
+	dm.mutex.RUnlock()
+	if !known {
+		c.Next()
+		return
+	}
+	_, tc, err := dm.getAdapterAndTableConfig(dbName, c.Param("table"))
+	if err != nil {
+		tc = nil
+	}
+	method := c.Request.Method
+	write := method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions &&
+		!strings.HasSuffix(c.FullPath(), "/batch_get") && !strings.HasSuffix(c.FullPath(), "/aggregate_pipeline")
+	status, m, msg := dm.maintenanceError(dbName, tc, write)
+	if status == 0 {
+		c.Next()
+		return
+	}
+	if status == http.StatusServiceUnavailable && m.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(m.RetryAfter.Seconds()+0.5)))
+	}
+	if status == http.StatusMethodNotAllowed {
+		c.Header("Allow", "GET, HEAD")
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": msg, "maintenance": m.Mode})
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleMaintenanceList(c *gin.Context) {
+	if !dm.authorize(c, nil, opMaintenance) {
+		return
+	}
+	entry := func(database, table string, m maintenanceConfig, source string) maintenanceEntry {
+		e := maintenanceEntry{Database: database, Table: table, Mode: m.Mode, Message: m.Message, Source: source}
+		if m.RetryAfter > 0 {
+			e.RetryAfter = m.RetryAfter.String()
+		}
+		return e
+	}
+	var entries []maintenanceEntry
+	dm.mutex.RLock()
+	if m := dm.config.Maintenance; m.Mode != "" {
+		entries = append(entries, entry("", "", m, "config"))
+	}
+	for name, dbCfg := range dm.config.Databases {
+		if dbCfg.Maintenance.Mode != "" {
+			entries = append(entries, entry(name, "", dbCfg.Maintenance, "config"))
+		}
+		for _, tc := range dbCfg.Tables {
+			if tc.Maintenance.Mode != "" {
+				entries = append(entries, entry(name, tc.Alias, tc.Maintenance, "config"))
+			}
+		}
+	}
+	dm.mutex.RUnlock()
+	dm.maintenance.mu.RLock()
+	for key, m := range dm.maintenance.modes {
+		database, table, _ := strings.Cut(key, "/")
+		e := entry(database, table, m, "runtime")
+		e.Since = dm.maintenance.since[key].Format(time.RFC3339)
+		entries = append(entries, e)
+	}
+	dm.maintenance.mu.RUnlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Database != entries[j].Database {
+			return entries[i].Database < entries[j].Database
+		}
+		if entries[i].Table != entries[j].Table {
+			return entries[i].Table < entries[j].Table
+		}
+		return entries[i].Source < entries[j].Source
+	})
+	if entries == nil {
+		entries = []maintenanceEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"maintenance": entries})
+}
+
+// maintenanceTarget 校验库与表别名，返回运行时设置的键
+func (dm *databaseManager) maintenanceTarget(database, table string) (string, error) {
+	if database == "" {
+		if table != "" {
+			return "", fmt.Errorf("table requires database")
+		}
+		return "", nil
+	}
+	dm.mutex.RLock()
+	_, ok := dm.config.Databases[database]
+	dm.mutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("database %s not found", database)
+	}
+	if table != "" {
+		if _, _, err := dm.getAdapterAndTableConfig(database, table); err != nil {
+			return "", err
+		}
+	}
+	return maintenanceKey(database, table), nil
+}
+
+func (dm *databaseManager) handleMaintenanceSet(c *gin.Context) {
+	if !dm.authorize(c, nil, opMaintenance) {
+		return
+	}
+	var req struct {
+		Database   string `json:"database"`
+		Table      string `json:"table"`
+		Mode       string `json:"mode"`
+		Message    string `json:"message"`
+		RetryAfter string `json:"retry_after"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	m := maintenanceConfig{Mode: req.Mode, Message: req.Message}
+	if req.Mode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode is required"})
+		return
+	}
+	if err := checkMaintenanceConfig("maintenance", m); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RetryAfter != "" {
+		d, err := time.ParseDuration(req.RetryAfter)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid retry_after: " + req.RetryAfter})
+			return
+		}
+		m.RetryAfter = d
+	}
+	key, err := dm.maintenanceTarget(req.Database, req.Table)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	dm.maintenance.mu.Lock()
+	dm.maintenance.modes[key] = m
+	dm.maintenance.since[key] = time.Now()
+	dm.maintenance.mu.Unlock()
+	log.Printf("[maintenance] %s set to %s by %s", maintenanceScopeName(req.Database, req.Table), m.Mode, dm.currentActor(c))
+	c.JSON(http.StatusOK, gin.H{"database": req.Database, "table": req.Table, "mode": m.Mode, "message": m.Message})
+}
+
+func (dm *databaseManager) handleMaintenanceClear(c *gin.Context) {
+	if !dm.authorize(c, nil, opMaintenance) {
+		return
+	}
+	database, table := c.Query("database"), c.Query("table")
+	key, err := dm.maintenanceTarget(database, table)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	dm.maintenance.mu.Lock()
+	_, ok := dm.maintenance.modes[key]
+	delete(dm.maintenance.modes, key)
+	delete(dm.maintenance.since, key)
+	dm.maintenance.mu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No runtime maintenance setting for " + maintenanceScopeName(database, table)})
+		return
+	}
+	log.Printf("[maintenance] runtime setting for %s cleared by %s", maintenanceScopeName(database, table), dm.currentActor(c))
+	c.JSON(http.StatusOK, gin.H{"database": database, "table": table, "cleared": true})
+}
+
+func maintenanceScopeName(database, table string) string {
+	switch {
+	case database == "":
+		return "service"
+	case table == "":
+		return "database " + database
+	}
+	return "table " + database + "/" + table
+}
@@ -0,0 +1,263 @@
+package apix
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 运行时元数据 ---------
+//
+// 客户端与 GraphQL 层无需读取 YAML 即可获取库、表结构与生效的表配置：
+//
+//	GET /api/meta/:database
+//	  => {"database": "test", "type": "sqlite", "read_only": false,
+//	      "tables": [{"table": "user", "name": "user", "comment": "用户", "primary_key": "id", "read_only": false}]}
+//	GET /api/meta/:database/:table
+//	  => {"database": "test", "table": "user", "name": "user", "primary_key": "id", "unique_keys": [["email"]],
+//	      "soft_delete": {"field": "deleted_time", "type": "timestamp"}, "auto_update": ["updated_time"],
+//	      "filterable_fields": null, "sortable_fields": null, ...,
+//	      "fields": [{"name": "id", "column": "id", "type": "INTEGER", "api_type": "integer", "primary_key": true, ...}]}
+//
+// 列信息（数据库类型、可空、默认值、注释等）来自启动或重新生成 swagger 时提取的元数据，未提取过时现场读取并缓存；
+// 字段名为 API 字段名（field_aliases），column 为物理列名，filterable_fields、sortable_fields 为 null 时不限制。
+// 表配置只给出客户端需要的部分，不含连接串等内部设置。
+// 需要对应表的 list 权限，库的表列表只包含调用者可访问的表。
+
+// tableMetaCache 按表配置目录（cfgs/table/<database>）缓存提取的表结构
+var tableMetaCache = struct {
+	sync.RWMutex
+	m map[string][]TableMeta
+}{m: map[string][]TableMeta{}}
+
+func storeTableMetas(dir string, tables []TableMeta) {
+	copied := make([]TableMeta, len(tables))
+	for i, t := range tables {
+		t.Fields = append([]FieldMeta(nil), t.Fields...)
+		copied[i] = t
+	}
+	tableMetaCache.Lock()
+	tableMetaCache.m[filepath.Clean(dir)] = copied
+	tableMetaCache.Unlock()
+}
+
+// tableMetas 返回库的表结构，缓存中没有时按库配置提取
+func (dm *databaseManager) tableMetas(dbName string, dbCfg databaseConfig) ([]TableMeta, error) {
+	dir := filepath.Join(dm.configDir, "table", dbCfg.Database)
+	tableMetaCache.RLock()
+	tables, ok := tableMetaCache.m[filepath.Clean(dir)]
+	tableMetaCache.RUnlock()
+	if ok {
+		return tables, nil
+	}
+	cfgs, err := listEnableDbCfgs(filepath.Join(dm.configDir, "database"))
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		if cfg.Alias != dbName {
+			continue
+		}
+		dsn := cfg.DSN
+		if strings.EqualFold(cfg.Type, "snowflake") {
+			if dsn, err = snowflakeDSN(dsn, cfg.Warehouse, cfg.Role); err != nil {
+				return nil, err
+			}
+		}
+		if tables, err = extractTableMeta(cfg.Type, dsn, cfg.Database, nil); err != nil {
+			return nil, err
+		}
+		storeTableMetas(dir, tables)
+		return tables, nil
+	}
+	return nil, fmt.Errorf("metadata not available for database %s", dbName)
+}
+
+type metaField struct {
+	Name          string           `json:"name"`
+	Column        string           `json:"column"`
+	Type          string           `json:"type"`
+	APIType       string           `json:"api_type"`
+	Nullable      bool             `json:"nullable"`
+	PrimaryKey    bool             `json:"primary_key,omitempty"`
+	Unique        bool             `json:"unique,omitempty"`
+	AutoIncrement bool             `json:"auto_increment,omitempty"`
+	Default       interface{}      `json:"default,omitempty"`
+	Comment       string           `json:"comment,omitempty"`
+	AutoUpdate    bool             `json:"auto_update,omitempty"`
+	SoftDelete    bool             `json:"soft_delete,omitempty"`
+	Hidden        bool             `json:"hidden,omitempty"`
+	Masked        string           `json:"masked,omitempty"`
+	Immutable     bool             `json:"immutable,omitempty"`
+	Validation    *fieldValidation `json:"validation,omitempty"`
+}
+
+type metaRelation struct {
+	Type  string `json:"type"`
+	Table string `json:"table"`
+}
+
+func (dm *databaseManager) handleDatabaseMeta(c *gin.Context) {
+	dbName := c.Param("database")
+	dm.mutex.RLock()
+	dbCfg, ok := dm.config.Databases[dbName]
+	adapter := dm.adapters[dbName]
+	dm.mutex.RUnlock()
+	if !ok || adapter == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "database not found: " + dbName})
+		return
+	}
+	metas, err := dm.tableMetas(dbName, dbCfg)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	readOnly := false
+	if ro, ok := adapter.(readOnlyAdapter); ok && ro.ReadOnly() {
+		readOnly = true
+	}
+	key, _ := c.Get(ctxKeyAPIKey)
+	tables := []gin.H{}
+	for i := range dbCfg.Tables {
+		tc := &dbCfg.Tables[i]
+		if !dm.allowed(c, tc, opList) {
+			continue
+		}
+		if k, ok := key.(*apiKeyConfig); ok && k.permits(http.MethodGet, dbName, tc.Alias) != nil {
+			continue
+		}
+		if dm.rbac.check(dm.currentPrincipal(c), http.MethodGet, dbName, tc.Alias) != nil {
+			continue
+		}
+		comment := ""
+		if meta := findTableMeta(metas, tc.Name); meta != nil {
+			comment = meta.Comment
+		}
+		tables = append(tables, gin.H{
+			"table":       tc.Alias,
+			"name":        tc.Name,
+			"comment":     comment,
+			"primary_key": tc.apiFieldName(tc.PrimaryKey),
+			"read_only":   readOnly || tc.isRollup(),
+		})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i]["table"].(string) < tables[j]["table"].(string) })
+	c.JSON(http.StatusOK, gin.H{
+		"database":  dbName,
+		"type":      dbCfg.Type,
+		"read_only": readOnly,
+		"tables":    tables,
+	})
+}
+
+func (dm *databaseManager) handleTableMeta(c *gin.Context) {
+	dbName := c.Param("database")
+	adapter, tc, err := dm.getAdapterAndTableConfig(dbName, c.Param("table"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tc, opList) {
+		return
+	}
+	dm.mutex.RLock()
+	dbCfg := dm.config.Databases[dbName]
+	dm.mutex.RUnlock()
+	metas, err := dm.tableMetas(dbName, dbCfg)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	meta := findTableMeta(metas, tc.Name)
+	if meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "metadata not found for table: " + tc.Alias})
+		return
+	}
+	readOnly := tc.isRollup()
+	if ro, ok := adapter.(readOnlyAdapter); ok && ro.ReadOnly() {
+		readOnly = true
+	}
+	var softDelete gin.H
+	if tc.SoftDeleteKey != "" {
+		softDelete = gin.H{"field": tc.apiFieldName(tc.SoftDeleteKey), "type": tc.SoftDeleteType}
+	}
+	uniqueKeys := [][]string{}
+	for _, uk := range tc.GetUniqueKeys() {
+		uniqueKeys = append(uniqueKeys, tc.apiFieldNames(uk))
+	}
+	relations := map[string]metaRelation{}
+	for name, rel := range tc.Relations {
+		relations[name] = metaRelation{Type: rel.Type, Table: rel.Table}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"database":          dbName,
+		"table":             tc.Alias,
+		"name":              tc.Name,
+		"comment":           meta.Comment,
+		"primary_key":       tc.apiFieldName(tc.PrimaryKey),
+		"unique_keys":       uniqueKeys,
+		"soft_delete":       softDelete,
+		"auto_update":       tc.apiFieldNames(tc.GetAutoUpdateFields()),
+		"id_resolution":     tc.IDResolution,
+		"immutable_fields":  tc.apiFieldNames(tc.ImmutableFields),
+		"cursor_field":      tc.apiFieldName(tc.CursorField),
+		"filterable_fields": tc.apiFieldNames(withPrimaryKey(tc.PrimaryKey, tc.FilterableFields)),
+		"sortable_fields":   tc.apiFieldNames(withPrimaryKey(tc.PrimaryKey, tc.SortableFields)),
+		"relations":         relations,
+		"read_only":         readOnly,
+		"fields":            tc.metaFields(meta),
+	})
+}
+
+// metaFields 合并提取的列信息与表配置
+func (tc *tableConfig) metaFields(meta *TableMeta) []metaField {
+	unique := map[string]bool{}
+	for _, uk := range tc.GetUniqueKeys() {
+		if len(uk) == 1 {
+			unique[uk[0]] = true
+		}
+	}
+	autoUpdate := tc.GetAutoUpdateFields()
+	fields := make([]metaField, 0, len(meta.Fields))
+	for _, f := range meta.Fields {
+		mf := metaField{
+			Name:          tc.apiFieldName(f.Name),
+			Column:        f.Name,
+			Type:          f.Type,
+			APIType:       toSwaggerType(f.Type),
+			Nullable:      f.Nullable,
+			PrimaryKey:    f.Name == tc.PrimaryKey,
+			Unique:        f.IsUnique || unique[f.Name],
+			AutoIncrement: f.AutoInc,
+			Default:       f.Default,
+			Comment:       f.Comment,
+			AutoUpdate:    f.OnUpdate || contains(autoUpdate, f.Name),
+			SoftDelete:    f.Name == tc.SoftDeleteKey,
+			Hidden:        contains(tc.HiddenFields, f.Name),
+			Masked:        tc.MaskedFields[f.Name],
+			Immutable:     contains(tc.ImmutableFields, f.Name),
+		}
+		if v, ok := tc.DefaultValues[f.Name]; ok && !strings.Contains(fmt.Sprint(v), "{{") {
+			mf.Default = v
+		}
+		if v, ok := tc.Validations[f.Name]; ok {
+			mf.Validation = &v
+		}
+		fields = append(fields, mf)
+	}
+	return fields
+}
+
+func findTableMeta(tables []TableMeta, name string) *TableMeta {
+	for i := range tables {
+		if tables[i].Name == name {
+			return &tables[i]
+		}
+	}
+	return nil
+}
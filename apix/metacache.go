@@ -0,0 +1,226 @@
+package apix
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- 元数据缓存 ---------
+//
+// 提取表结构需要逐表查询，库较慢或表较多时启动明显变慢。提取结果与库结构指纹一起写入
+// cfgs/table/<database>/.meta_cache.yaml，之后启动（及重新加载配置）时只执行几条查询表、列、约束与注释定义的
+// 指纹查询，指纹未变化时直接使用缓存：
+//
+//	ego --refresh-meta    # 删除缓存，重新提取全部库
+//
+// 支持的库：mysql、tidb、postgresql、cockroachdb、sqlite、sqlserver、clickhouse、snowflake，其余类型每次提取。
+// 指纹包含数据源类型、库名与 dsn，连接配置变化时同样重新提取；指纹查询失败时按未缓存处理。
+// TimescaleDB hypertable 等不在指纹范围内的变化需要 --refresh-meta 或 POST /api/admin/swagger/regenerate（不使用缓存）。
+
+const (
+	metaCacheFile    = ".meta_cache.yaml"
+	metaCacheVersion = 1 // 提取逻辑变化时递增，使旧缓存失效
+)
+
+type metaCache struct {
+	Version     int         `yaml:"version"`
+	Fingerprint string      `yaml:"fingerprint"`
+	CreatedAt   time.Time   `yaml:"created_at"`
+	Tables      []TableMeta `yaml:"tables"`
+}
+
+// schemaFingerprintQueries 各类型库的指纹查询，结果按固定顺序返回
+var schemaFingerprintQueries = map[string][]string{
+	"mysql": {
+		`SELECT TABLE_NAME, IFNULL(TABLE_COMMENT,'') FROM information_schema.tables WHERE TABLE_SCHEMA=? ORDER BY TABLE_NAME`,
+		`SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA, COLUMN_DEFAULT, IFNULL(COLUMN_COMMENT,'')
+			FROM information_schema.columns WHERE TABLE_SCHEMA=? ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+		`SELECT TABLE_NAME, INDEX_NAME, COLUMN_NAME, NON_UNIQUE
+			FROM information_schema.statistics WHERE TABLE_SCHEMA=? ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`,
+	},
+	"postgresql": {
+		`SELECT table_name, column_name, data_type, is_nullable, column_default
+			FROM information_schema.columns WHERE table_schema='public' ORDER BY table_name, ordinal_position`,
+		`SELECT tc.table_name, tc.constraint_name, tc.constraint_type, kcu.column_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name
+			WHERE tc.table_schema='public' ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`,
+		`SELECT c.relname, d.objsubid, d.description
+			FROM pg_description d JOIN pg_class c ON c.oid = d.objoid JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname='public' ORDER BY c.relname, d.objsubid`,
+	},
+	"sqlite": {
+		`SELECT type, name, tbl_name, IFNULL(sql, '') FROM sqlite_master ORDER BY type, name`,
+	},
+	"sqlserver": {
+		`SELECT o.name, o.type, CONVERT(VARCHAR(33), o.modify_date, 126)
+			FROM sys.objects o WHERE o.type IN ('U', 'PK', 'UQ', 'D') AND o.is_ms_shipped = 0 ORDER BY o.name`,
+		`SELECT ep.major_id, ep.minor_id, CAST(ep.value AS NVARCHAR(MAX))
+			FROM sys.extended_properties ep WHERE ep.class = 1 AND ep.name = 'MS_Description' ORDER BY ep.major_id, ep.minor_id`,
+	},
+	"clickhouse": {
+		`SELECT name, engine, create_table_query FROM system.tables WHERE database=? AND is_temporary=0 ORDER BY name`,
+	},
+	"snowflake": {
+		`SELECT TABLE_NAME, TABLE_TYPE, COALESCE(COMMENT, '') FROM INFORMATION_SCHEMA.TABLES
+			WHERE TABLE_SCHEMA = CURRENT_SCHEMA() ORDER BY TABLE_NAME`,
+		`SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COALESCE(COMMENT, ''), IS_IDENTITY
+			FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = CURRENT_SCHEMA() ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+		`SELECT TABLE_NAME, CONSTRAINT_NAME, CONSTRAINT_TYPE FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS
+			WHERE TABLE_SCHEMA = CURRENT_SCHEMA() ORDER BY TABLE_NAME, CONSTRAINT_NAME`,
+	},
+}
+
+// schemaFingerprintDriver 返回库类型对应的驱动与指纹查询，不支持时 ok 为 false
+func schemaFingerprintDriver(dbType string) (driver string, queries []string, ok bool) {
+	switch strings.ToLower(dbType) {
+	case "mysql", "tidb":
+		return "mysql", schemaFingerprintQueries["mysql"], true
+	case "postgres", "postgresql", "cockroachdb":
+		return "postgres", schemaFingerprintQueries["postgresql"], true
+	case "sqlite":
+		return "sqlite", schemaFingerprintQueries["sqlite"], true
+	case "sqlserver":
+		return "sqlserver", schemaFingerprintQueries["sqlserver"], true
+	case "clickhouse":
+		return "clickhouse", schemaFingerprintQueries["clickhouse"], true
+	case "snowflake":
+		return "snowflake", schemaFingerprintQueries["snowflake"], true
+	}
+	return "", nil, false
+}
+
+// schemaFingerprint 计算库结构指纹：数据源类型、库名、dsn 与各指纹查询结果的 SHA-256
+func schemaFingerprint(dbType, dsn, dbName string) (string, error) {
+	driver, queries, ok := schemaFingerprintDriver(dbType)
+	if !ok {
+		return "", fmt.Errorf("schema fingerprint not supported for %s", dbType)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00", metaCacheVersion, strings.ToLower(dbType), dbName, dsn)
+	for _, q := range queries {
+		var args []interface{}
+		if strings.Contains(q, "?") {
+			args = append(args, dbName)
+		}
+		rows, err := db.Query(q, args...)
+		if err != nil {
+			return "", err
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return "", err
+			}
+			for _, v := range vals {
+				if v.Valid {
+					fmt.Fprintf(h, "%q\x1f", v.String)
+				} else {
+					h.Write([]byte("NULL\x1f"))
+				}
+			}
+			h.Write([]byte{'\n'})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// extractTableMetaCached 指纹与缓存一致时返回缓存的表结构，否则提取并更新缓存；in.refresh 为 true 时不读取缓存
+func extractTableMetaCached(dbType, dsn, dbName, dir string, in *introspection) ([]TableMeta, error) {
+	fingerprint, err := schemaFingerprint(dbType, dsn, dbName)
+	if err != nil {
+		if _, _, ok := schemaFingerprintDriver(dbType); ok {
+			log.Printf("[MetaCache] %s: fingerprint query failed, extracting: %v", dbName, err)
+		}
+		return extractTableMeta(dbType, dsn, dbName, in)
+	}
+	cacheFile := filepath.Join(dir, metaCacheFile)
+	if in == nil || !in.refresh {
+		if cache, err := readMetaCache(cacheFile); err == nil && cache.Version == metaCacheVersion && cache.Fingerprint == fingerprint {
+			return cache.Tables, nil
+		}
+	}
+	tables, err := extractTableMeta(dbType, dsn, dbName, in)
+	if err != nil {
+		return nil, err
+	}
+	// 有表被跳过或有告警时结果不完整，不写入缓存
+	if len(in.warningList()) > 0 {
+		return tables, nil
+	}
+	if err := writeMetaCache(cacheFile, metaCache{Version: metaCacheVersion, Fingerprint: fingerprint, CreatedAt: time.Now(), Tables: tables}); err != nil {
+		log.Printf("[MetaCache] %s: %v", dbName, err)
+	}
+	return tables, nil
+}
+
+func readMetaCache(file string) (*metaCache, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cache metaCache
+	if err := yaml.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+func writeMetaCache(file string, cache metaCache) error {
+	data, err := yaml.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// ClearMetaCache 删除配置目录下全部库的元数据缓存，下次提取时重新读取表结构（--refresh-meta）
+func ClearMetaCache(cfgsDir string) error {
+	files, err := filepath.Glob(filepath.Join(cfgsDir, "table", "*", metaCacheFile))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- 并行提取元数据 ---------
+//
+// 启动与重新加载时按库并行提取表结构，库之间互不等待；每张表的结构查询单独限时，被锁或很慢的表跳过，
+// 该库其余的表照常生成（有表被跳过或有告警时不写入元数据缓存，下次启动重新提取，见 metawarnings.go）：
+//
+//	meta_extract:
+//	  workers: 4            # 同时提取的库数
+//	  table_timeout: 30s    # 单表结构查询的超时，适用于 SQL 数据库
+//
+// 每个库完成时输出进度日志：
+//
+//	[Meta] 2/5 test: 12 tables in 340ms
+//	[Meta] 3/5 orders: 40 tables in 5.2s, 2 warnings, skipped: audit_log
+//
+// 嵌入方可调用 ExtractDbMetaWithProgress 自行接收进度。有库提取失败时其余库照常完成，
+// 最后返回各库的错误（errors.Join），启动、重新加载配置与 --refresh-meta 随之失败。
+
+const (
+	defaultMetaWorkers      = 4
+	defaultMetaTableTimeout = 30 * time.Second
+)
+
+type metaExtractOptions struct {
+	Workers      int           `yaml:"workers"`
+	TableTimeout time.Duration `yaml:"table_timeout"`
+}
+
+func readMetaExtractOptions(cfgs string) metaExtractOptions {
+	var conf struct {
+		MetaExtract metaExtractOptions `yaml:"meta_extract"`
+	}
+	if data, err := os.ReadFile(filepath.Join(cfgs, "_base.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &conf)
+	}
+	opts := conf.MetaExtract
+	if opts.Workers <= 0 {
+		opts.Workers = defaultMetaWorkers
+	}
+	if opts.TableTimeout <= 0 {
+		opts.TableTimeout = defaultMetaTableTimeout
+	}
+	return opts
+}
+
+// MetaProgress 一个库提取完成时的进度
+type MetaProgress struct {
+	Database string // 库别名
+	Done     int    // 已完成的库数（含本库）
+	Total    int
+	Tables   int      // 生成配置的表数
+	Skipped  []string // 结构查询超时或失败而跳过的表
+	Warnings int      // 告警数（含跳过的表），明细见 GET /api/admin/meta/warnings
+	Duration time.Duration
+	Err      error
+}
+
+// introspection 一个库的一次提取：选项与逐表查询的结果，为 nil 时不限时、使用缓存、不记录告警
+type introspection struct {
+	database     string // 库别名
+	refresh      bool   // 不使用元数据缓存，见 metacache.go
+	tableTimeout time.Duration
+
+	mu       sync.Mutex
+	count    int
+	warnings []metaWarning
+}
+
+// tables 逐表执行 fn（i 为表在 tables 中的下标），每张表限时 tableTimeout；超时或出错的表记录告警并从结果中去掉
+func (in *introspection) tables(dbName string, tables []TableMeta, fn func(ctx context.Context, i int) error) ([]TableMeta, error) {
+	kept := tables[:0:0]
+	for i := range tables {
+		ctx, cancel := in.tableContext()
+		err := fn(ctx, i)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("introspection timed out after %s", in.tableTimeout)
+		}
+		cancel()
+		if err != nil {
+			in.skipTable(tables[i].Name, err)
+			continue
+		}
+		kept = append(kept, tables[i])
+	}
+	return kept, nil
+}
+
+func (in *introspection) tableContext() (context.Context, context.CancelFunc) {
+	if in == nil || in.tableTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), in.tableTimeout)
+}
+
+// extractDbMetas 以 workers 个并发提取各库，每个库完成时回调 progress（串行调用）；返回各库失败原因的合并（按库别名排序）
+func extractDbMetas(dbCfgs []DbBaseCfg, tableCfgDir, apiPrefix string, opts swaggerOptions, mopts metaExtractOptions, progress func(MetaProgress)) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		done   int
+		failed = map[string]error{}
+	)
+	sem := make(chan struct{}, mopts.Workers)
+	for _, dbcfg := range dbCfgs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(dbcfg DbBaseCfg) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			alias := dbcfg.Alias
+			if alias == "" {
+				alias = dbcfg.Database
+			}
+			in := &introspection{database: alias, tableTimeout: mopts.TableTimeout}
+			err := extractDbMetaFor(dbcfg, tableCfgDir, apiPrefix, opts, in)
+			in.record(filepath.Join(tableCfgDir, dbcfg.Database), err)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				failed[alias] = err
+			}
+			if progress != nil {
+				progress(MetaProgress{Database: alias, Done: done, Total: len(dbCfgs), Tables: in.count,
+					Skipped: in.skippedTables(), Warnings: len(in.warningList()), Duration: time.Since(start), Err: err})
+			}
+		}(dbcfg)
+	}
+	wg.Wait()
+	aliases := make([]string, 0, len(failed))
+	for alias := range failed {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	errs := make([]error, len(aliases))
+	for i, alias := range aliases {
+		errs[i] = fmt.Errorf("%s: %w", alias, failed[alias])
+	}
+	return errors.Join(errs...)
+}
+
+func logMetaProgress(p MetaProgress) {
+	if p.Err != nil {
+		log.Printf("[Meta] %d/%d %s: %v", p.Done, p.Total, p.Database, p.Err)
+		return
+	}
+	msg := fmt.Sprintf("[Meta] %d/%d %s: %d tables in %s", p.Done, p.Total, p.Database, p.Tables, p.Duration.Round(time.Millisecond))
+	if p.Warnings > 0 {
+		msg += fmt.Sprintf(", %d warnings", p.Warnings)
+	}
+	if len(p.Skipped) > 0 {
+		msg += ", skipped: " + strings.Join(p.Skipped, ", ")
+	}
+	log.Print(msg)
+}
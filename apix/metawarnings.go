@@ -0,0 +1,141 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 元数据提取告警 ---------
+//
+// 只读或权限受限的账号常常查不到部分系统视图（约束、索引、SHOW CREATE TABLE 等）。提取时按表降级：
+//   - 主键、唯一键、自增等细节查询失败时记录告警，该表照常生成配置，缺少的信息按未知处理
+//   - 表的列查询失败（或超时，见 metaextract.go）时跳过该表，该库其余的表照常生成
+//
+// 查看最近一次提取（启动、重新加载配置或重新生成 swagger）的告警：
+//
+//	GET /api/admin/meta/warnings[?database=test]
+//	  => [{"database": "test", "extracted_at": "...", "warnings": [
+//	       {"table": "audit_log", "stage": "unique_keys", "error": "permission denied for ..."},
+//	       {"table": "secrets", "stage": "table", "error": "...", "skipped": true}]}]
+//
+// table 为空表示整个库提取失败。需要 meta_warnings 权限（默认 admin）。
+
+const (
+	metaStageDatabase      = "database"
+	metaStageTable         = "table"
+	metaStageColumns       = "columns"
+	metaStagePrimaryKey    = "primary_key"
+	metaStageUniqueKeys    = "unique_keys"
+	metaStageAutoIncrement = "auto_increment"
+)
+
+type metaWarning struct {
+	Table   string `json:"table,omitempty"`
+	Stage   string `json:"stage"`
+	Error   string `json:"error"`
+	Skipped bool   `json:"skipped,omitempty"` // 表未生成配置
+}
+
+type metaWarningReport struct {
+	Database    string        `json:"database"`
+	ExtractedAt time.Time     `json:"extracted_at"`
+	Warnings    []metaWarning `json:"warnings"`
+}
+
+// metaWarningStore 按表配置目录（cfgs/table/<database>）保存最近一次提取的告警
+var metaWarningStore = struct {
+	sync.RWMutex
+	m map[string]metaWarningReport
+}{m: map[string]metaWarningReport{}}
+
+// warn 记录表的细节查询失败，该表照常生成；超时由 tables 统一处理
+func (in *introspection) warn(table, stage string, err error) {
+	if in == nil || err == nil || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	log.Printf("[Meta] %s.%s: %s unavailable: %v", in.database, table, stage, err)
+	in.mu.Lock()
+	in.warnings = append(in.warnings, metaWarning{Table: table, Stage: stage, Error: err.Error()})
+	in.mu.Unlock()
+}
+
+func (in *introspection) skipTable(table string, err error) {
+	if in == nil {
+		return
+	}
+	log.Printf("[Meta] %s.%s: %v, skipped", in.database, table, err)
+	in.mu.Lock()
+	in.warnings = append(in.warnings, metaWarning{Table: table, Stage: metaStageTable, Error: err.Error(), Skipped: true})
+	in.mu.Unlock()
+}
+
+func (in *introspection) warningList() []metaWarning {
+	if in == nil {
+		return nil
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return append([]metaWarning(nil), in.warnings...)
+}
+
+func (in *introspection) skippedTables() []string {
+	var tables []string
+	for _, w := range in.warningList() {
+		if w.Skipped {
+			tables = append(tables, w.Table)
+		}
+	}
+	return tables
+}
+
+// record 以本次提取的告警替换库的告警记录，err 为整个库提取失败的原因
+func (in *introspection) record(dir string, err error) {
+	warnings := in.warningList()
+	if err != nil {
+		warnings = append(warnings, metaWarning{Stage: metaStageDatabase, Error: err.Error()})
+	}
+	if warnings == nil {
+		warnings = []metaWarning{}
+	}
+	metaWarningStore.Lock()
+	metaWarningStore.m[filepath.Clean(dir)] = metaWarningReport{ExtractedAt: time.Now(), Warnings: warnings}
+	metaWarningStore.Unlock()
+}
+
+func (dm *databaseManager) handleMetaWarnings(c *gin.Context) {
+	if !dm.authorize(c, nil, opMetaWarnings) {
+		return
+	}
+	filter := c.Query("database")
+	dm.mutex.RLock()
+	dirs := make(map[string]string, len(dm.config.Databases))
+	for alias, db := range dm.config.Databases {
+		if filter == "" || filter == alias {
+			dirs[alias] = filepath.Join(dm.configDir, "table", db.Database)
+		}
+	}
+	dm.mutex.RUnlock()
+	if filter != "" && len(dirs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "database not found: " + filter})
+		return
+	}
+	reports := make([]metaWarningReport, 0, len(dirs))
+	metaWarningStore.RLock()
+	for alias, dir := range dirs {
+		if r, ok := metaWarningStore.m[filepath.Clean(dir)]; ok {
+			r.Database = alias
+			reports = append(reports, r)
+		}
+	}
+	metaWarningStore.RUnlock()
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Database < reports[j].Database })
+	c.JSON(http.StatusOK, reports)
+}
@@ -0,0 +1,472 @@
+package apix
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// --------- Prometheus 指标与 SLO ---------
+//
+// 按表统计 REST 接口的请求数、耗时分布与 SLO（可用率、延迟）达成情况，以 Prometheus 文本格式暴露：
+//
+//	metrics:
+//	  enabled: true
+//	  path: /metrics                 # 抓取地址
+//	  token: ""                      # 非空时抓取需带 Authorization: Bearer <token>
+//	  buckets: [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10]   # 耗时分布的桶（秒）
+//	  slo:                           # 表配置中的 slo 可覆盖
+//	    availability: 0.999          # 可用率目标：非 5xx 响应的占比
+//	    latency: 500ms               # 延迟阈值
+//	    latency_target: 0.99         # 延迟目标：耗时不超过阈值的请求占比
+//
+// 指标（database、table 为库、表别名，只统计已配置的表）：
+//
+//	ego_http_requests_total{database,table,route,method,code}            请求数
+//	ego_http_request_duration_seconds{database,table,route,method}       耗时分布（histogram）
+//	ego_slo_requests_total / ego_slo_errors_total / ego_slo_slow_requests_total{database,table}
+//	ego_slo_objective{database,table,slo}                                SLO 目标，slo 为 availability 或 latency
+//	ego_slo_burn_rate{database,table,slo,window}                         进程内按 5m、30m、1h、6h 窗口计算的预算消耗速率
+//
+// 消耗速率 = 窗口内不达标请求的占比 / (1 - 目标)，为 1 时恰好在 SLO 周期末用完错误预算。
+// 进程内的速率只反映单个实例，多实例部署时以生成的告警规则（按 Prometheus 聚合后的计数计算）为准：
+//
+//	GET /api/admin/slo/rules      Prometheus 告警规则文件（YAML，受 operation_roles.metrics 控制，默认 admin）
+//
+// 规则按 30 天周期采用多窗口多消耗速率告警：1h 与 5m 超过 14.4 倍、6h 与 30m 超过 6 倍时 severity 为 page，
+// 1d 与 2h 超过 3 倍、3d 与 6h 超过 1 倍时为 ticket。
+
+const (
+	defaultMetricsPath      = "/metrics"
+	defaultSLOAvailability  = 0.999
+	defaultSLOLatency       = 500 * time.Millisecond
+	defaultSLOLatencyTarget = 0.99
+
+	sloAvailability = "availability"
+	sloLatency      = "latency"
+
+	// 进程内消耗速率按分钟统计，保留最长窗口的分钟数
+	sloRingMinutes = 360
+)
+
+var defaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sloBurnWindows 进程内计算消耗速率的窗口
+var sloBurnWindows = []struct {
+	name    string
+	minutes int64
+}{{"5m", 5}, {"30m", 30}, {"1h", 60}, {"6h", 360}}
+
+// sloAlertWindows 告警规则的长、短窗口与消耗速率阈值（30 天周期）
+var sloAlertWindows = []struct {
+	long, short string
+	factor      float64
+	severity    string
+}{
+	{"1h", "5m", 14.4, "page"},
+	{"6h", "30m", 6, "page"},
+	{"1d", "2h", 3, "ticket"},
+	{"3d", "6h", 1, "ticket"},
+}
+
+type metricsConfig struct {
+	Enabled bool      `mapstructure:"enabled"`
+	Path    string    `mapstructure:"path"`
+	Token   string    `mapstructure:"token"`
+	Buckets []float64 `mapstructure:"buckets"`
+	SLO     sloConfig `mapstructure:"slo"`
+}
+
+type sloConfig struct {
+	Availability  float64       `mapstructure:"availability"`
+	Latency       time.Duration `mapstructure:"latency"`
+	LatencyTarget float64       `mapstructure:"latency_target"`
+}
+
+// merge 用 o 中非零的值覆盖
+func (s sloConfig) merge(o sloConfig) sloConfig {
+	if o.Availability != 0 {
+		s.Availability = o.Availability
+	}
+	if o.Latency != 0 {
+		s.Latency = o.Latency
+	}
+	if o.LatencyTarget != 0 {
+		s.LatencyTarget = o.LatencyTarget
+	}
+	return s
+}
+
+func (s sloConfig) validate() error {
+	for name, v := range map[string]float64{"availability": s.Availability, "latency_target": s.LatencyTarget} {
+		if v != 0 && (v <= 0 || v >= 1) {
+			return fmt.Errorf("slo.%s must be between 0 and 1 (exclusive), got %v", name, v)
+		}
+	}
+	if s.Latency < 0 {
+		return fmt.Errorf("slo.latency must not be negative")
+	}
+	return nil
+}
+
+type requestSeries struct {
+	database, table, route, method, code string
+}
+
+type durationSeries struct {
+	database, table, route, method string
+}
+
+type histogram struct {
+	counts []int64 // 各桶（不累计）的计数，最后一个为 +Inf
+	sum    float64
+	count  int64
+}
+
+// sloMinute 一分钟内的计数，minute 为 Unix 分钟数
+type sloMinute struct {
+	minute              int64
+	total, errors, slow int64
+}
+
+type tableSLO struct {
+	database, table     string
+	config              sloConfig
+	total, errors, slow int64
+	ring                [sloRingMinutes]sloMinute
+}
+
+func (t *tableSLO) record(now time.Time, isError, isSlow bool) {
+	m := now.Unix() / 60
+	slot := &t.ring[m%sloRingMinutes]
+	if slot.minute != m {
+		*slot = sloMinute{minute: m}
+	}
+	slot.total++
+	t.total++
+	if isError {
+		slot.errors++
+		t.errors++
+	}
+	if isSlow {
+		slot.slow++
+		t.slow++
+	}
+}
+
+// burnRates 返回最近 minutes 分钟的可用率、延迟消耗速率
+func (t *tableSLO) burnRates(now time.Time, minutes int64) (availability, latency float64) {
+	current := now.Unix() / 60
+	var total, errors, slow int64
+	for m := current - minutes + 1; m <= current; m++ {
+		if slot := t.ring[m%sloRingMinutes]; slot.minute == m {
+			total, errors, slow = total+slot.total, errors+slot.errors, slow+slot.slow
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	availability = float64(errors) / float64(total) / errorBudget(t.config.Availability)
+	latency = float64(slow) / float64(total) / errorBudget(t.config.LatencyTarget)
+	return availability, latency
+}
+
+// errorBudget 允许不达标的请求占比，去掉 1 - 0.999 之类的浮点误差
+func errorBudget(objective float64) float64 {
+	return math.Round((1-objective)*1e9) / 1e9
+}
+
+type metricsRegistry struct {
+	config    metricsConfig
+	mu        sync.Mutex
+	requests  map[requestSeries]int64
+	durations map[durationSeries]*histogram
+	tables    map[string]*tableSLO // 库别名.表别名
+}
+
+// setupMetrics 按配置创建指标，各表的 SLO 目标为全局配置与表配置合并的结果
+func (dm *databaseManager) setupMetrics() error {
+	cfg := dm.config.Metrics
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultMetricsPath
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = defaultMetricsBuckets
+	}
+	if !sort.Float64sAreSorted(cfg.Buckets) {
+		return fmt.Errorf("metrics.buckets must be in increasing order")
+	}
+	if err := cfg.SLO.validate(); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	base := sloConfig{Availability: defaultSLOAvailability, Latency: defaultSLOLatency, LatencyTarget: defaultSLOLatencyTarget}.merge(cfg.SLO)
+	m := &metricsRegistry{
+		config:    cfg,
+		requests:  map[requestSeries]int64{},
+		durations: map[durationSeries]*histogram{},
+		tables:    map[string]*tableSLO{},
+	}
+	for dbName, db := range dm.config.Databases {
+		for _, tc := range db.Tables {
+			if err := tc.SLO.validate(); err != nil {
+				return fmt.Errorf("%s.%s: %w", dbName, tc.Alias, err)
+			}
+			m.tables[dbName+"."+tc.Alias] = &tableSLO{database: dbName, table: tc.Alias, config: base.merge(tc.SLO)}
+		}
+	}
+	dm.metrics = m
+	return nil
+}
+
+// withMetrics 统计请求数、耗时与 SLO，需放在 REST 路由组的最前面以计入全部耗时
+func (dm *databaseManager) withMetrics(c *gin.Context) {
+	m := dm.metrics
+	if m == nil {
+		c.Next()
+		return
+	}
+	start := time.Now()
+	c.Next()
+	t := m.tables[c.Param("database")+"."+c.Param("table")]
+	if t == nil {
+		// 未配置的库、表不统计，避免任意路径撑大指标
+		return
+	}
+	elapsed := time.Since(start)
+	status := c.Writer.Status()
+	route := strings.TrimPrefix(c.FullPath(), dm.restPrefix)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestSeries{t.database, t.table, route, c.Request.Method, strconv.Itoa(status)}]++
+	key := durationSeries{t.database, t.table, route, c.Request.Method}
+	h := m.durations[key]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(m.config.Buckets)+1)}
+		m.durations[key] = h
+	}
+	seconds := elapsed.Seconds()
+	h.counts[sort.SearchFloat64s(m.config.Buckets, seconds)]++
+	h.sum += seconds
+	h.count++
+	t.record(start, status >= http.StatusInternalServerError, elapsed > t.config.Latency)
+}
+
+// ---- 暴露 ----
+
+func (dm *databaseManager) handleMetrics(c *gin.Context) {
+	m := dm.metrics
+	if token := m.config.Token; token != "" {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: invalid metrics token"})
+			return
+		}
+	}
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	m.write(c.Writer, time.Now())
+}
+
+// write 按 Prometheus 文本格式输出，序列按标签排序
+func (m *metricsRegistry) write(w io.Writer, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(w, "ego_http_requests_total", "counter", "REST requests by table, route, method and status code.")
+	requests := make([]requestSeries, 0, len(m.requests))
+	for s := range m.requests {
+		requests = append(requests, s)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		return strings.Join([]string{a.database, a.table, a.route, a.method, a.code}, "\x00") < strings.Join([]string{b.database, b.table, b.route, b.method, b.code}, "\x00")
+	})
+	for _, s := range requests {
+		writeSample(w, "ego_http_requests_total", promLabels("database", s.database, "table", s.table, "route", s.route, "method", s.method, "code", s.code), float64(m.requests[s]))
+	}
+
+	writeHeader(w, "ego_http_request_duration_seconds", "histogram", "REST request latency by table, route and method.")
+	durations := make([]durationSeries, 0, len(m.durations))
+	for s := range m.durations {
+		durations = append(durations, s)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		a, b := durations[i], durations[j]
+		return strings.Join([]string{a.database, a.table, a.route, a.method}, "\x00") < strings.Join([]string{b.database, b.table, b.route, b.method}, "\x00")
+	})
+	for _, s := range durations {
+		h := m.durations[s]
+		labels := []string{"database", s.database, "table", s.table, "route", s.route, "method", s.method}
+		var cumulative int64
+		for i, upper := range m.config.Buckets {
+			cumulative += h.counts[i]
+			writeSample(w, "ego_http_request_duration_seconds_bucket", promLabels(append(labels, "le", formatPromFloat(upper))...), float64(cumulative))
+		}
+		writeSample(w, "ego_http_request_duration_seconds_bucket", promLabels(append(labels, "le", "+Inf")...), float64(h.count))
+		writeSample(w, "ego_http_request_duration_seconds_sum", promLabels(labels...), h.sum)
+		writeSample(w, "ego_http_request_duration_seconds_count", promLabels(labels...), float64(h.count))
+	}
+
+	tables := make([]*tableSLO, 0, len(m.tables))
+	for _, t := range m.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].database+"."+tables[i].table < tables[j].database+"."+tables[j].table
+	})
+	counters := []struct {
+		name, help string
+		value      func(*tableSLO) int64
+	}{
+		{"ego_slo_requests_total", "Requests counted towards the table SLOs.", func(t *tableSLO) int64 { return t.total }},
+		{"ego_slo_errors_total", "Requests that failed the availability SLO (5xx).", func(t *tableSLO) int64 { return t.errors }},
+		{"ego_slo_slow_requests_total", "Requests that exceeded the latency SLO threshold.", func(t *tableSLO) int64 { return t.slow }},
+	}
+	for _, counter := range counters {
+		writeHeader(w, counter.name, "counter", counter.help)
+		for _, t := range tables {
+			writeSample(w, counter.name, promLabels("database", t.database, "table", t.table), float64(counter.value(t)))
+		}
+	}
+	writeHeader(w, "ego_slo_objective", "gauge", "SLO objective: the target ratio of good requests.")
+	for _, t := range tables {
+		writeSample(w, "ego_slo_objective", promLabels("database", t.database, "table", t.table, "slo", sloAvailability), t.config.Availability)
+		writeSample(w, "ego_slo_objective", promLabels("database", t.database, "table", t.table, "slo", sloLatency), t.config.LatencyTarget)
+	}
+	writeHeader(w, "ego_slo_latency_threshold_seconds", "gauge", "Latency SLO threshold.")
+	for _, t := range tables {
+		writeSample(w, "ego_slo_latency_threshold_seconds", promLabels("database", t.database, "table", t.table), t.config.Latency.Seconds())
+	}
+	writeHeader(w, "ego_slo_burn_rate", "gauge", "Error budget burn rate of this instance over the window.")
+	for _, t := range tables {
+		for _, win := range sloBurnWindows {
+			availability, latency := t.burnRates(now, win.minutes)
+			writeSample(w, "ego_slo_burn_rate", promLabels("database", t.database, "table", t.table, "slo", sloAvailability, "window", win.name), availability)
+			writeSample(w, "ego_slo_burn_rate", promLabels("database", t.database, "table", t.table, "slo", sloLatency, "window", win.name), latency)
+		}
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatPromFloat(value))
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels 按 name, value 成对生成 {name="value",...}
+func promLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], promLabelEscaper.Replace(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatPromFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ---- 告警规则 ----
+
+type promRuleFile struct {
+	Groups []promRuleGroup `yaml:"groups"`
+}
+
+type promRuleGroup struct {
+	Name  string     `yaml:"name"`
+	Rules []promRule `yaml:"rules"`
+}
+
+type promRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// sloRules 为每张表生成可用率与延迟的多窗口消耗速率告警
+func (m *metricsRegistry) sloRules() promRuleFile {
+	keys := make([]string, 0, len(m.tables))
+	for k := range m.tables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	file := promRuleFile{Groups: []promRuleGroup{}}
+	for _, k := range keys {
+		t := m.tables[k]
+		selector := promLabels("database", t.database, "table", t.table)
+		ratio := func(bad, window string) string {
+			return fmt.Sprintf("sum(rate(%s%s[%s])) / sum(rate(ego_slo_requests_total%s[%s]))", bad, selector, window, selector, window)
+		}
+		group := promRuleGroup{Name: "ego-slo-" + t.database + "-" + t.table}
+		for _, slo := range []struct {
+			name, bad, alert, summary string
+			objective                 float64
+		}{
+			{sloAvailability, "ego_slo_errors_total", "EgoTableAvailabilityBudgetBurn",
+				fmt.Sprintf("%s.%s is burning its availability error budget (objective %v)", t.database, t.table, t.config.Availability), t.config.Availability},
+			{sloLatency, "ego_slo_slow_requests_total", "EgoTableLatencyBudgetBurn",
+				fmt.Sprintf("%s.%s is burning its latency error budget (%v of requests within %s)", t.database, t.table, t.config.LatencyTarget, t.config.Latency), t.config.LatencyTarget},
+		} {
+			budget := errorBudget(slo.objective)
+			for _, win := range sloAlertWindows {
+				threshold := formatPromFloat(math.Round(win.factor*budget*1e9) / 1e9)
+				group.Rules = append(group.Rules, promRule{
+					Alert: slo.alert,
+					Expr:  fmt.Sprintf("(%s) > %s\nand\n(%s) > %s", ratio(slo.bad, win.long), threshold, ratio(slo.bad, win.short), threshold),
+					Labels: map[string]string{
+						"severity": win.severity, "slo": slo.name, "database": t.database, "table": t.table,
+						"window": win.long,
+					},
+					Annotations: map[string]string{
+						"summary":     slo.summary,
+						"description": fmt.Sprintf("Burn rate over %s and %s is above %v.", win.long, win.short, win.factor),
+					},
+				})
+			}
+		}
+		file.Groups = append(file.Groups, group)
+	}
+	return file
+}
+
+func (dm *databaseManager) handleSLORules(c *gin.Context) {
+	if !dm.authorize(c, nil, opMetrics) {
+		return
+	}
+	if dm.metrics == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metrics are not enabled"})
+		return
+	}
+	data, err := yaml.Marshal(dm.metrics.sloRules())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
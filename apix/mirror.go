@@ -0,0 +1,703 @@
+package apix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 双写迁移 ---------
+//
+// 在线迁移（如 MySQL → PostgreSQL）期间，把表的写入镜像到另一个已配置的库，应用无需改动。表配置：
+//
+//	mirror:
+//	  database: pg_shop      # 目标库别名，须在 databases 中配置
+//	  table: user            # 目标表别名，默认与本表别名相同
+//
+// 源表（SQL 库）上的每次创建、更新、删除在事务提交后按主键入队，后台从源表读取最新状态（含已软删除的记录）
+// 写入目标表：存在则整行 upsert，已不存在则删除。镜像不阻塞也不影响主写入，写入失败与队列溢出记为不一致并写日志，
+// 重启时尚未同步的记录同样丢失，由校验任务补齐。目标表按源表的物理列名写入，软删除列作为普通列同步，
+// 忽略目标表的软删除配置（源表中已物理删除的记录在目标表也物理删除）。
+//
+// 管理接口（受 operation_roles.mirror 控制，默认 admin）：
+//
+//	GET  /api/admin/mirror                                                   各镜像表的同步统计与最近的错误
+//	POST /api/admin/mirror/verify?database=shop&table=user&backfill=true     提交校验任务，返回任务 ID
+//
+// 校验任务按主键分批比对两边的数据，统计目标表缺失、不一致与多余的记录；backfill=true 时同时修复目标表。
+
+const (
+	jobTypeMirrorVerify = "mirror_verify"
+
+	mirrorQueueSize  = 10000
+	mirrorBatchSize  = 500
+	maxMirrorSamples = 20
+	mirrorKeysKey    = "ego:mirror_keys"
+)
+
+type tableMirrorConfig struct {
+	Database string `mapstructure:"database"`
+	Table    string `mapstructure:"table"`
+}
+
+type mirrorStats struct {
+	Database       string `json:"database"`
+	Table          string `json:"table"`
+	TargetDatabase string `json:"target_database"`
+	TargetTable    string `json:"target_table"`
+	Synced         int64  `json:"synced"`
+	Deleted        int64  `json:"deleted"`
+	Failed         int64  `json:"failed"`  // 写入目标表失败的记录数
+	Dropped        int64  `json:"dropped"` // 队列已满未能入队的记录数
+	LastError      string `json:"last_error,omitempty"`
+	LastErrorAt    string `json:"last_error_at,omitempty"`
+}
+
+type mirrorTable struct {
+	database string
+	tc       *tableConfig
+	stats    mirrorStats
+}
+
+type mirrorTask struct {
+	table *mirrorTable
+	keys  []interface{}
+}
+
+// mirrorer 收集镜像表的写入并在后台同步到目标库
+type mirrorer struct {
+	dm     *databaseManager
+	queue  chan mirrorTask
+	mu     sync.Mutex
+	tables map[string]*mirrorTable // 键为 库别名/表别名
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// setupMirrors 校验镜像配置，在源库上注册回调并启动同步
+func (dm *databaseManager) setupMirrors() error {
+	m := &mirrorer{dm: dm, queue: make(chan mirrorTask, mirrorQueueSize), tables: map[string]*mirrorTable{}}
+	for name, dbCfg := range dm.config.Databases {
+		byName := map[string]*mirrorTable{}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if tc.Mirror.Database == "" {
+				continue
+			}
+			target := tc.Mirror.Table
+			if target == "" {
+				target = tc.Alias
+			}
+			if tc.PrimaryKey == "" {
+				return fmt.Errorf("table %s: mirror requires primary_key", tc.Alias)
+			}
+			if tc.Mirror.Database == name && target == tc.Alias {
+				return fmt.Errorf("table %s: mirror target is the table itself", tc.Alias)
+			}
+			if _, _, err := dm.getAdapterAndTableConfig(tc.Mirror.Database, target); err != nil {
+				return fmt.Errorf("table %s: mirror target: %w", tc.Alias, err)
+			}
+			t := &mirrorTable{database: name, tc: tc, stats: mirrorStats{
+				Database: name, Table: tc.Alias, TargetDatabase: tc.Mirror.Database, TargetTable: target,
+			}}
+			byName[tc.Name] = t
+			m.tables[maintenanceKey(name, tc.Alias)] = t
+		}
+		if len(byName) == 0 {
+			continue
+		}
+		a, ok := dm.adapters[name].(*gormAdapter)
+		if !ok {
+			return fmt.Errorf("mirror is only supported on SQL databases, %s is %s", name, dbCfg.Type)
+		}
+		if err := m.register(a.db, byName); err != nil {
+			return fmt.Errorf("failed to set up mirror for %s: %w", name, err)
+		}
+	}
+	if len(m.tables) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+	dm.mirror = m
+	go m.run(ctx)
+	return nil
+}
+
+func (m *mirrorer) stop() {
+	m.cancel()
+	<-m.done
+}
+
+// ---- 捕获写入：gorm 回调 ----
+
+// register 在 UPDATE/DELETE 前用同一 WHERE 条件读取受影响的主键，CREATE 后从写入的记录中取主键，
+// 语句提交后入队；处于 gormAdapter.transaction 中时推迟到外层事务提交后
+func (m *mirrorer) register(db *gorm.DB, tables map[string]*mirrorTable) error {
+	affected := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		t, ok := tables[db.Statement.Table]
+		if !ok {
+			return
+		}
+		where, ok := db.Statement.Clauses["WHERE"]
+		if !ok {
+			return
+		}
+		var rows []map[string]interface{}
+		tx := db.Session(&gorm.Session{NewDB: true})
+		if err := tx.Table(t.tc.Name).Select(t.tc.PrimaryKey).Clauses(where.Expression).Find(&rows).Error; err != nil {
+			m.fail(t, 0, fmt.Errorf("read affected keys: %w", err))
+			return
+		}
+		keys := make([]interface{}, len(rows))
+		for i, r := range rows {
+			keys[i] = r[t.tc.PrimaryKey]
+		}
+		db.InstanceSet(mirrorKeysKey, keys)
+	}
+	created := func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			return
+		}
+		if t, ok := tables[db.Statement.Table]; ok {
+			db.InstanceSet(mirrorKeysKey, m.createdKeys(db, t))
+		}
+	}
+	committed := func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		v, ok := db.InstanceGet(mirrorKeysKey)
+		keys, _ := v.([]interface{})
+		if !ok || len(keys) == 0 {
+			return
+		}
+		task := mirrorTask{table: tables[db.Statement.Table], keys: keys}
+		if hooks := afterCommitFrom(db.Statement.Context); hooks != nil {
+			hooks.add(func() { m.enqueue(task) })
+			return
+		}
+		m.enqueue(task)
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register("ego:mirror_capture", created),
+		cb.Update().Before("gorm:update").Register("ego:mirror_capture", affected),
+		cb.Delete().Before("gorm:delete").Register("ego:mirror_capture", affected),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("ego:mirror_enqueue", committed),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("ego:mirror_enqueue", committed),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("ego:mirror_enqueue", committed),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createdKeys 返回新写入记录的主键；ON CONFLICT 写入时驱动回填的主键不可靠，按冲突列读回
+func (m *mirrorer) createdKeys(db *gorm.DB, t *mirrorTable) []interface{} {
+	var records []map[string]interface{}
+	switch d := db.Statement.Dest.(type) {
+	case map[string]interface{}:
+		records = []map[string]interface{}{d}
+	case *map[string]interface{}:
+		records = []map[string]interface{}{*d}
+	case []map[string]interface{}:
+		records = d
+	case *[]map[string]interface{}:
+		records = *d
+	}
+	var conflictColumns []string
+	if c, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+		if oc, ok := c.Expression.(clause.OnConflict); ok {
+			for _, col := range oc.Columns {
+				conflictColumns = append(conflictColumns, col.Name)
+			}
+		}
+	}
+	pk := t.tc.PrimaryKey
+	keys := make([]interface{}, 0, len(records))
+	for _, r := range records {
+		if len(conflictColumns) > 0 && !(len(conflictColumns) == 1 && conflictColumns[0] == pk) {
+			cond := make(map[string]interface{}, len(conflictColumns))
+			for _, col := range conflictColumns {
+				cond[col] = r[col]
+			}
+			var rows []map[string]interface{}
+			tx := db.Session(&gorm.Session{NewDB: true})
+			if err := tx.Table(t.tc.Name).Select(pk).Where(cond).Limit(1).Find(&rows).Error; err != nil {
+				m.fail(t, 1, fmt.Errorf("read upserted key: %w", err))
+				continue
+			}
+			if len(rows) > 0 {
+				keys = append(keys, rows[0][pk])
+			}
+			continue
+		}
+		if v := r[pk]; v != nil {
+			keys = append(keys, v)
+		} else if v := r[idPlaceholder]; v != nil {
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+func (m *mirrorer) enqueue(task mirrorTask) {
+	select {
+	case m.queue <- task:
+	default:
+		m.mu.Lock()
+		task.table.stats.Dropped += int64(len(task.keys))
+		m.mu.Unlock()
+		log.Printf("[mirror] %s/%s: queue full, %d record(s) not mirrored, run mirror verify with backfill", task.table.database, task.table.tc.Alias, len(task.keys))
+	}
+}
+
+func (m *mirrorer) fail(t *mirrorTable, n int, err error) {
+	m.mu.Lock()
+	t.stats.Failed += int64(n)
+	t.stats.LastError, t.stats.LastErrorAt = err.Error(), time.Now().Format(time.RFC3339)
+	m.mu.Unlock()
+	log.Printf("[mirror] %s/%s -> %s/%s: %d record(s) diverged: %v", t.database, t.tc.Alias, t.stats.TargetDatabase, t.stats.TargetTable, n, err)
+}
+
+// ---- 事务提交后执行 ----
+
+type afterCommitKey struct{}
+
+// afterCommitHooks 收集事务中需要在提交后执行的操作，嵌套事务归属最外层
+type afterCommitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// withAfterCommit 在 ctx 中没有外层事务时创建新的收集器，否则返回 nil
+func withAfterCommit(ctx context.Context) (context.Context, *afterCommitHooks) {
+	if afterCommitFrom(ctx) != nil {
+		return ctx, nil
+	}
+	hooks := &afterCommitHooks{}
+	return context.WithValue(ctx, afterCommitKey{}, hooks), hooks
+}
+
+func afterCommitFrom(ctx context.Context) *afterCommitHooks {
+	if ctx == nil {
+		return nil
+	}
+	hooks, _ := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	return hooks
+}
+
+func (h *afterCommitHooks) add(fn func()) {
+	h.mu.Lock()
+	h.fns = append(h.fns, fn)
+	h.mu.Unlock()
+}
+
+// reset 丢弃未提交的操作（事务回滚或重试）
+func (h *afterCommitHooks) reset() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.fns = nil
+	h.mu.Unlock()
+}
+
+func (h *afterCommitHooks) run() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// ---- 同步 ----
+
+func (m *mirrorer) run(ctx context.Context) {
+	defer close(m.done)
+	for {
+		var task mirrorTask
+		select {
+		case <-ctx.Done():
+			return
+		case task = <-m.queue:
+		}
+		// 合并队列中已有的任务，按表批量同步
+		batch := map[*mirrorTable][]interface{}{task.table: task.keys}
+	drain:
+		for i := 0; i < mirrorBatchSize; i++ {
+			select {
+			case t := <-m.queue:
+				batch[t.table] = append(batch[t.table], t.keys...)
+			default:
+				break drain
+			}
+		}
+		for t, keys := range batch {
+			keys = dedupMirrorKeys(keys)
+			for start := 0; start < len(keys); start += mirrorBatchSize {
+				m.sync(ctx, t, keys[start:min(start+mirrorBatchSize, len(keys))])
+			}
+		}
+	}
+}
+
+func dedupMirrorKeys(keys []interface{}) []interface{} {
+	seen := make(map[string]bool, len(keys))
+	result := keys[:0]
+	for _, k := range keys {
+		s := keyValueString(k)
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// sync 从源表读取主键对应的最新记录写入目标表，源表中已不存在的从目标表删除
+func (m *mirrorer) sync(ctx context.Context, t *mirrorTable, keys []interface{}) {
+	src, tc, err := m.dm.getAdapterAndTableConfig(t.database, t.tc.Alias)
+	if err != nil {
+		m.fail(t, len(keys), err)
+		return
+	}
+	target, ttc, err := m.dm.mirrorTarget(t)
+	if err != nil {
+		m.fail(t, len(keys), err)
+		return
+	}
+	found, err := batchGetRecords(context.WithValue(ctx, includeDeletedKey{}, true), src, tc, pkLookupKeys(tc.PrimaryKey, keys), "")
+	if err != nil {
+		m.fail(t, len(keys), fmt.Errorf("read source: %w", err))
+		return
+	}
+	var rows []map[string]interface{}
+	var deleted []interface{}
+	for i, r := range found {
+		if r == nil {
+			deleted = append(deleted, keys[i])
+		} else {
+			rows = append(rows, r)
+		}
+	}
+	if err := writeMirror(ctx, target, ttc, rows, deleted); err != nil {
+		m.fail(t, len(keys), err)
+		return
+	}
+	m.mu.Lock()
+	t.stats.Synced += int64(len(rows))
+	t.stats.Deleted += int64(len(deleted))
+	m.mu.Unlock()
+}
+
+// mirrorTarget 返回目标表的适配器与去掉软删除配置的表配置
+func (dm *databaseManager) mirrorTarget(t *mirrorTable) (databaseAdapter, *tableConfig, error) {
+	adapter, tc, err := dm.getAdapterAndTableConfig(t.stats.TargetDatabase, t.stats.TargetTable)
+	if err != nil {
+		return nil, nil, err
+	}
+	target := *tc
+	target.SoftDeleteKey, target.SoftDeleteType = "", ""
+	return adapter, &target, nil
+}
+
+func pkLookupKeys(pk string, keys []interface{}) []lookupKey {
+	lookups := make([]lookupKey, len(keys))
+	for i, k := range keys {
+		lookups[i] = lookupKey{Fields: []string{pk}, Values: []interface{}{k}}
+	}
+	return lookups
+}
+
+// writeMirror 把整行写入目标表并删除 deleted 中的主键；目标适配器不支持 upsert 时逐条先更新后插入
+func writeMirror(ctx context.Context, adapter databaseAdapter, tc *tableConfig, rows []map[string]interface{}, deleted []interface{}) error {
+	pk := tc.PrimaryKey
+	if len(rows) > 0 {
+		if u, ok := adapter.(upserter); ok {
+			fields := map[string]bool{}
+			for _, r := range rows {
+				for k := range r {
+					fields[k] = k != pk
+				}
+			}
+			var updateFields []string
+			for f, update := range fields {
+				if update {
+					updateFields = append(updateFields, f)
+				}
+			}
+			sort.Strings(updateFields)
+			if _, err := u.Upsert(ctx, tc, []string{pk}, updateFields, rows); err != nil {
+				return fmt.Errorf("write target: %w", err)
+			}
+		} else {
+			for _, r := range rows {
+				data := copyRecord(r)
+				delete(data, pk)
+				matched, _, err := adapter.UpdateOne(ctx, tc, map[string]interface{}{pk: r[pk]}, data)
+				if err == nil && matched == 0 {
+					_, _, err = adapter.BatchCreate(ctx, tc, []map[string]interface{}{r})
+				}
+				if err != nil {
+					return fmt.Errorf("write target: %w", err)
+				}
+			}
+		}
+	}
+	if len(deleted) > 0 {
+		if _, err := adapter.BatchDelete(ctx, tc, deleted); err != nil {
+			return fmt.Errorf("delete from target: %w", err)
+		}
+	}
+	return nil
+}
+
+// mirrorRowEqual 比较源记录的各列在目标记录中的取值
+func mirrorRowEqual(src, dst map[string]interface{}) bool {
+	for k, v := range src {
+		w, ok := dst[k]
+		if !ok || !mirrorValueEqual(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// mirrorValueEqual 忽略不同数据库驱动的类型差异（时间精度与时区、数值与字符串、布尔与 0/1）
+func mirrorValueEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ta, ok := asTime(a, true); ok {
+		if tb, ok := asTime(b, true); ok {
+			return ta.Truncate(time.Microsecond).Equal(tb.Truncate(time.Microsecond))
+		}
+	}
+	str := func(v interface{}) string {
+		if b, ok := v.(bool); ok {
+			if b {
+				return "1"
+			}
+			return "0"
+		}
+		return keyValueString(normalizeStatsValue(v))
+	}
+	sa, sb := str(a), str(b)
+	if sa == sb {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(sa, 64)
+	fb, errB := strconv.ParseFloat(sb, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+// ---- 校验任务 ----
+
+type mirrorVerifyPayload struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Backfill bool   `json:"backfill"`
+}
+
+type mirrorVerifyProgress struct {
+	Scanned   int64    `json:"scanned"`
+	Missing   int64    `json:"missing"`   // 目标表缺少的记录
+	Different int64    `json:"different"` // 两边取值不一致的记录
+	Extra     int64    `json:"extra"`     // 目标表多出的记录
+	Fixed     int64    `json:"fixed"`
+	Samples   []string `json:"samples"` // 前若干条不一致记录，形如 missing:42
+}
+
+func (p *mirrorVerifyProgress) sample(kind string, key interface{}) {
+	if len(p.Samples) < maxMirrorSamples {
+		p.Samples = append(p.Samples, kind+":"+keyValueString(key))
+	}
+}
+
+// runMirrorVerifyJob 为 mirror_verify 类型任务的处理函数：先按主键顺序扫描源表，再扫描目标表查找多余的记录
+func (dm *databaseManager) runMirrorVerifyJob(ctx context.Context, job utils.Job) error {
+	var payload mirrorVerifyPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid mirror verify payload: %w", err)
+	}
+	if dm.mirror == nil || dm.mirror.tables[maintenanceKey(payload.Database, payload.Table)] == nil {
+		return fmt.Errorf("mirror is not configured for %s/%s", payload.Database, payload.Table)
+	}
+	t := dm.mirror.tables[maintenanceKey(payload.Database, payload.Table)]
+	srcAdapter, tc, err := dm.getAdapterAndTableConfig(t.database, t.tc.Alias)
+	if err != nil {
+		return err
+	}
+	src, ok := srcAdapter.(*gormAdapter)
+	if !ok {
+		return fmt.Errorf("mirror source %s is not a SQL database", t.database)
+	}
+	target, ttc, err := dm.mirrorTarget(t)
+	if err != nil {
+		return err
+	}
+	progress := mirrorVerifyProgress{Samples: []string{}}
+	pk := tc.PrimaryKey
+	keyset := &keysetPage{Columns: []string{pk}}
+	for {
+		var rows []map[string]interface{}
+		err := src.read(ctx, func(db *gorm.DB) error {
+			return applyGormKeyset(db.Table(tc.Name), keyset).Limit(mirrorBatchSize).Find(&rows).Error
+		})
+		if err != nil {
+			return fmt.Errorf("read source: %w", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		keys := make([]interface{}, len(rows))
+		for i, r := range rows {
+			keys[i] = r[pk]
+		}
+		found, err := batchGetRecords(ctx, target, ttc, pkLookupKeys(ttc.PrimaryKey, keys), "")
+		if err != nil {
+			return fmt.Errorf("read target: %w", err)
+		}
+		var fix []map[string]interface{}
+		for i, r := range rows {
+			switch {
+			case found[i] == nil:
+				progress.Missing++
+				progress.sample("missing", keys[i])
+			case !mirrorRowEqual(r, found[i]):
+				progress.Different++
+				progress.sample("different", keys[i])
+			default:
+				continue
+			}
+			fix = append(fix, r)
+		}
+		if payload.Backfill && len(fix) > 0 {
+			if err := writeMirror(ctx, target, ttc, fix, nil); err != nil {
+				return err
+			}
+			progress.Fixed += int64(len(fix))
+		}
+		progress.Scanned += int64(len(rows))
+		keyset.After = []interface{}{keys[len(keys)-1]}
+		if err := dm.jobQueue.SetProgress(job.ID, progress); err != nil {
+			return err
+		}
+	}
+	// 目标表不支持按主键顺序扫描时跳过多余记录的检查
+	if _, ok := target.(keysetLister); ok {
+		keyset := &keysetPage{Columns: []string{ttc.PrimaryKey}}
+		for {
+			rows, _, err := target.List(ctx, ttc, listParams{Page: 1, PageSize: mirrorBatchSize, Fields: ttc.PrimaryKey, Keyset: keyset})
+			if err != nil {
+				return fmt.Errorf("read target: %w", err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			keys := make([]interface{}, len(rows))
+			for i, r := range rows {
+				keys[i] = r[ttc.PrimaryKey]
+			}
+			found, err := batchGetRecords(context.WithValue(ctx, includeDeletedKey{}, true), src, tc, pkLookupKeys(pk, keys), pk)
+			if err != nil {
+				return fmt.Errorf("read source: %w", err)
+			}
+			var extra []interface{}
+			for i := range keys {
+				if found[i] == nil {
+					progress.Extra++
+					progress.sample("extra", keys[i])
+					extra = append(extra, keys[i])
+				}
+			}
+			if payload.Backfill && len(extra) > 0 {
+				if err := writeMirror(ctx, target, ttc, nil, extra); err != nil {
+					return err
+				}
+				progress.Fixed += int64(len(extra))
+			}
+			keyset.After = []interface{}{keys[len(keys)-1]}
+			if err := dm.jobQueue.SetProgress(job.ID, progress); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("[mirror] verify %s/%s: scanned %d, missing %d, different %d, extra %d, fixed %d",
+		t.database, t.tc.Alias, progress.Scanned, progress.Missing, progress.Different, progress.Extra, progress.Fixed)
+	return dm.jobQueue.SetProgress(job.ID, progress)
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleMirrorStatus(c *gin.Context) {
+	if !dm.authorize(c, nil, opMirror) {
+		return
+	}
+	stats := []mirrorStats{}
+	if m := dm.mirror; m != nil {
+		m.mu.Lock()
+		for _, t := range m.tables {
+			stats = append(stats, t.stats)
+		}
+		m.mu.Unlock()
+		sort.Slice(stats, func(i, j int) bool {
+			return maintenanceKey(stats[i].Database, stats[i].Table) < maintenanceKey(stats[j].Database, stats[j].Table)
+		})
+	}
+	queued := 0
+	if dm.mirror != nil {
+		queued = len(dm.mirror.queue)
+	}
+	c.JSON(http.StatusOK, gin.H{"queued": queued, "tables": stats})
+}
+
+func (dm *databaseManager) handleMirrorVerify(c *gin.Context) {
+	if !dm.authorize(c, nil, opMirror) {
+		return
+	}
+	payload := mirrorVerifyPayload{Database: c.Query("database"), Table: c.Query("table")}
+	if v := c.Query("backfill"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid backfill: " + v})
+			return
+		}
+		payload.Backfill = b
+	}
+	if dm.mirror == nil || dm.mirror.tables[maintenanceKey(payload.Database, payload.Table)] == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mirror is not configured for " + maintenanceScopeName(payload.Database, payload.Table)})
+		return
+	}
+	job, err := dm.jobQueue.Enqueue(jobTypeMirrorVerify, payload, utils.EnqueueOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", dm.jobsPrefix+"/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,141 @@
+package apix
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- OpenAPI 输出格式 ---------
+//
+// swagger.yaml 始终按 OpenAPI 3.0 生成，对外提供时可转换格式与版本：
+//
+//	GET /swagger/test/swagger.yaml
+//	GET /swagger/test/swagger.json
+//	GET /swagger/test/swagger.json?openapi=3.1   # 单次请求指定版本，覆盖部署默认值
+//
+// 部署默认版本在 _base.yaml 中配置：
+//
+//	swagger:
+//	  openapi_version: "3.1"   # 3.0（默认）或 3.1
+//
+// 转换为 3.1 时：nullable 改为类型数组（type: [string, "null"]），schema 中的 example 改为 examples 数组，
+// 只有一个取值的 enum 改为 const。Swagger UI 页面与远端 ego 联邦固定读取 3.0。
+
+const (
+	openapiVersion30 = "3.0"
+	openapiVersion31 = "3.1"
+
+	queryParamOpenAPI = "openapi"
+)
+
+type swaggerOptions struct {
+	OpenAPIVersion string            `yaml:"openapi_version"`
+	Servers        []swaggerServer   `yaml:"servers"`    // 见 swagger.go
+	TagGroups      []swaggerTagGroup `yaml:"tag_groups"` // 见 swaggerdocs.go
+	OperationOrder []string          `yaml:"operation_order"`
+}
+
+// readSwaggerOptions 读取 _base.yaml 中的 swagger 配置
+func readSwaggerOptions(cfgs string) swaggerOptions {
+	var conf struct {
+		Swagger swaggerOptions `yaml:"swagger"`
+	}
+	if data, err := os.ReadFile(filepath.Join(cfgs, "_base.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &conf)
+	}
+	if conf.Swagger.OpenAPIVersion != openapiVersion31 {
+		conf.Swagger.OpenAPIVersion = openapiVersion30
+	}
+	return conf.Swagger
+}
+
+// renderOpenAPI 把 3.0 的 swagger.yaml 转为指定版本与格式（yaml 或 json）
+func renderOpenAPI(data []byte, version string, asJSON bool) ([]byte, error) {
+	if version == openapiVersion30 && !asJSON {
+		return data, nil
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse swagger failed: %w", err)
+	}
+	if version == openapiVersion31 {
+		convertOpenAPI31(doc)
+	}
+	if asJSON {
+		return json.MarshalIndent(doc, "", "  ")
+	}
+	return yaml.Marshal(doc)
+}
+
+func convertOpenAPI31(doc map[string]interface{}) {
+	doc["openapi"] = "3.1.0"
+	walkOpenAPI(doc)
+}
+
+// walkOpenAPI 在文档中查找 schema 对象（参数、请求体、响应与 components.schemas）
+func walkOpenAPI(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			switch k {
+			case "schema":
+				convertSchema31(child)
+			case "schemas":
+				if m, ok := child.(map[string]interface{}); ok {
+					for _, s := range m {
+						convertSchema31(s)
+					}
+				}
+			default:
+				walkOpenAPI(child)
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			walkOpenAPI(child)
+		}
+	}
+}
+
+func convertSchema31(v interface{}) {
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if nullable, _ := s["nullable"].(bool); nullable {
+		if t, ok := s["type"].(string); ok {
+			s["type"] = []interface{}{t, "null"}
+		} else if allOf, ok := s["allOf"]; ok {
+			s["anyOf"] = []interface{}{map[string]interface{}{"allOf": allOf}, map[string]interface{}{"type": "null"}}
+			delete(s, "allOf")
+		}
+	}
+	delete(s, "nullable")
+	if ex, ok := s["example"]; ok {
+		s["examples"] = []interface{}{ex}
+		delete(s, "example")
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) == 1 {
+		s["const"] = enum[0]
+		delete(s, "enum")
+	}
+	for _, k := range []string{"items", "additionalProperties", "not"} {
+		convertSchema31(s[k])
+	}
+	if props, ok := s["properties"].(map[string]interface{}); ok {
+		for _, p := range props {
+			convertSchema31(p)
+		}
+	}
+	for _, k := range []string{"allOf", "oneOf", "anyOf"} {
+		if list, ok := s[k].([]interface{}); ok {
+			for _, item := range list {
+				convertSchema31(item)
+			}
+		}
+	}
+}
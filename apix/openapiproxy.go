@@ -0,0 +1,632 @@
+package apix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- 第三方 REST 后端代理 Adapter 实现 ---------
+//
+// 把已有的 REST 服务按其 OpenAPI 3 文档映射为表，ego 只做转发，在其上提供鉴权、限流、缓存与 GraphQL：
+//
+//	type: openapi
+//	dsn: "https://petstore.example.com/openapi.yaml"   # OpenAPI 文档地址或本地文件
+//	openapi:
+//	  base_url: "https://petstore.example.com/v1"     # 默认取文档 servers 中的第一个
+//	  token: "xxx"                 # 固定凭据（Authorization: Bearer），同 type: ego
+//	  forward_headers: [Authorization]
+//	  timeout: 30s
+//	  page_param: page             # 分页参数，默认 page / page_size
+//	  page_size_param: page_size
+//	  offset_param: ""             # 设置后按偏移分页（如 offset + limit），不再发送 page_param
+//	  sort_param: ""               # 排序参数，未设置时不支持 order
+//	  data_field: ""               # 列表响应为对象时数据所在字段，默认依次尝试 data、items、results
+//	  total_field: ""              # 总数所在字段，默认依次尝试 total、count、total_count
+//
+// 文档中成对出现的 /pets 与 /pets/{petId} 视为一张表，表名取集合路径的最后一段，路径参数为主键；
+// 字段取自单条 GET 的响应、集合 POST 的请求体或列表响应中的 schema。单条读写、更新（优先 PATCH）与删除
+// 转发到 /pets/{petId}，列表与创建转发到 /pets，批量操作逐条转发。过滤条件按普通查询参数转发，
+// 不支持 __ 运算符；后端未声明的操作返回错误。不支持试运行、update_where 与 delete_where。
+
+const (
+	defaultOpenAPIPageParam     = "page"
+	defaultOpenAPIPageSizeParam = "page_size"
+)
+
+var (
+	openapiItemPathRe    = regexp.MustCompile(`^(/[^{}]*?)/\{([^{}/]+)\}$`)
+	openapiNameInvalidRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+	defaultOpenAPIDataFields  = []string{"data", "items", "results"}
+	defaultOpenAPITotalFields = []string{"total", "count", "total_count"}
+)
+
+type openapiConfig struct {
+	egoConfig     `mapstructure:",squash"`
+	BaseURL       string `mapstructure:"base_url"`
+	PageParam     string `mapstructure:"page_param"`
+	PageSizeParam string `mapstructure:"page_size_param"`
+	OffsetParam   string `mapstructure:"offset_param"`
+	SortParam     string `mapstructure:"sort_param"`
+	DataField     string `mapstructure:"data_field"`
+	TotalField    string `mapstructure:"total_field"`
+}
+
+// ---- OpenAPI 文档 ----
+
+type openapiDoc struct {
+	Servers []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths      map[string]openapiPathItem `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*openapiSchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type openapiPathItem struct {
+	Get    *openapiOperation `yaml:"get"`
+	Post   *openapiOperation `yaml:"post"`
+	Put    *openapiOperation `yaml:"put"`
+	Patch  *openapiOperation `yaml:"patch"`
+	Delete *openapiOperation `yaml:"delete"`
+}
+
+type openapiMediaTypes map[string]struct {
+	Schema *openapiSchema `yaml:"schema"`
+}
+
+type openapiOperation struct {
+	Summary     string             `yaml:"summary"`
+	Description string             `yaml:"description"`
+	Parameters  []openapiParameter `yaml:"parameters"`
+	RequestBody struct {
+		Required bool              `yaml:"required"`
+		Content  openapiMediaTypes `yaml:"content"`
+	} `yaml:"requestBody"`
+	Responses map[string]struct {
+		Content openapiMediaTypes `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+type openapiParameter struct {
+	Name     string         `yaml:"name"`
+	In       string         `yaml:"in"`
+	Required bool           `yaml:"required"`
+	Schema   *openapiSchema `yaml:"schema"`
+}
+
+type openapiSchema struct {
+	Ref         string                    `yaml:"$ref"`
+	Type        openapiType               `yaml:"type"`
+	Format      string                    `yaml:"format"`
+	Description string                    `yaml:"description"`
+	ReadOnly    bool                      `yaml:"readOnly"`
+	WriteOnly   bool                      `yaml:"writeOnly"`
+	Nullable    bool                      `yaml:"nullable"`
+	Enum        []interface{}             `yaml:"enum"`
+	Items       *openapiSchema            `yaml:"items"`
+	Properties  map[string]*openapiSchema `yaml:"properties"`
+	Required    []string                  `yaml:"required"`
+	AllOf       []*openapiSchema          `yaml:"allOf"`
+	PrimaryKey  string                    `yaml:"x-primary-key"`
+}
+
+// openapiType 兼容 3.1 中 type 为数组的写法（如 [string, "null"]），取第一个非 null 类型
+type openapiType string
+
+func (t *openapiType) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = openapiType(value.Value)
+		return nil
+	}
+	var types []string
+	if err := value.Decode(&types); err != nil {
+		return err
+	}
+	for _, s := range types {
+		if s != "null" {
+			*t = openapiType(s)
+			break
+		}
+	}
+	return nil
+}
+
+// jsonSchema 返回 JSON 内容的 schema，没有 application/json 时取任意一个
+func (m openapiMediaTypes) jsonSchema() *openapiSchema {
+	if mt, ok := m["application/json"]; ok {
+		return mt.Schema
+	}
+	for _, mt := range m {
+		if mt.Schema != nil {
+			return mt.Schema
+		}
+	}
+	return nil
+}
+
+// successSchema 返回 2xx 响应的 schema
+func (op *openapiOperation) successSchema() *openapiSchema {
+	if op == nil {
+		return nil
+	}
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			if s := op.Responses[code].Content.jsonSchema(); s != nil {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 展开 $ref 与 allOf，返回合并后的 schema
+func (d *openapiDoc) resolve(s *openapiSchema) *openapiSchema {
+	for depth := 0; s != nil && s.Ref != "" && depth < 16; depth++ {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if s == nil || len(s.AllOf) == 0 {
+		return s
+	}
+	merged := *s
+	merged.AllOf = nil
+	merged.Properties = map[string]*openapiSchema{}
+	for k, v := range s.Properties {
+		merged.Properties[k] = v
+	}
+	for _, part := range s.AllOf {
+		part = d.resolve(part)
+		if part == nil {
+			continue
+		}
+		for k, v := range part.Properties {
+			merged.Properties[k] = v
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+	return &merged
+}
+
+// recordSchema 从列表响应中取出单条记录的 schema：数组元素，或包装对象中数组字段的元素
+func (d *openapiDoc) recordSchema(s *openapiSchema) *openapiSchema {
+	s = d.resolve(s)
+	if s == nil {
+		return nil
+	}
+	if s.Type == "array" {
+		return d.resolve(s.Items)
+	}
+	for _, f := range defaultOpenAPIDataFields {
+		if p := d.resolve(s.Properties[f]); p != nil && p.Type == "array" {
+			return d.resolve(p.Items)
+		}
+	}
+	return nil
+}
+
+// openapiResource 文档中的一组集合 / 单条路径
+type openapiResource struct {
+	Name           string
+	CollectionPath string
+	ItemPath       string
+	IDParam        string
+	Comment        string
+	Schema         *openapiSchema
+	Collection     openapiPathItem
+	Item           openapiPathItem
+}
+
+func (r *openapiResource) primaryKey() string {
+	if r.Schema != nil {
+		if _, ok := r.Schema.Properties[r.IDParam]; ok {
+			return r.IDParam
+		}
+		if _, ok := r.Schema.Properties["id"]; ok {
+			return "id"
+		}
+	}
+	return r.IDParam
+}
+
+func loadOpenAPIDoc(location string) (*openapiDoc, error) {
+	var data []byte
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		resp, err := (&http.Client{Timeout: defaultEgoTimeout}).Get(location)
+		if err != nil {
+			return nil, fmt.Errorf("fetch openapi spec failed: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch openapi spec %s returned %s", location, resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("read openapi spec failed: %w", err)
+		}
+	}
+	// JSON 是 YAML 的子集，两种格式都按 YAML 解析
+	var doc openapiDoc
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi spec failed: %w", err)
+	}
+	return &doc, nil
+}
+
+// openapiResources 按路径推导表；嵌套资源（集合路径中带参数）不映射
+func openapiResources(doc *openapiDoc) []*openapiResource {
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var resources []*openapiResource
+	names := map[string]int{}
+	for _, p := range paths {
+		m := openapiItemPathRe.FindStringSubmatch(p)
+		if m == nil {
+			continue
+		}
+		r := &openapiResource{
+			CollectionPath: m[1],
+			ItemPath:       p,
+			IDParam:        m[2],
+			Item:           doc.Paths[p],
+			Collection:     doc.Paths[m[1]],
+		}
+		r.Schema = doc.resolve(r.Item.Get.successSchema())
+		if r.Schema == nil && r.Collection.Post != nil {
+			r.Schema = doc.resolve(r.Collection.Post.RequestBody.Content.jsonSchema())
+		}
+		if r.Schema == nil {
+			r.Schema = doc.recordSchema(r.Collection.Get.successSchema())
+		}
+		if r.Item.Get != nil {
+			r.Comment = r.Item.Get.Summary
+		}
+		if r.Comment == "" && r.Schema != nil {
+			r.Comment = r.Schema.Description
+		}
+		segments := strings.Split(strings.Trim(r.CollectionPath, "/"), "/")
+		r.Name = openapiNameInvalidRe.ReplaceAllString(segments[len(segments)-1], "_")
+		names[r.Name]++
+		resources = append(resources, r)
+	}
+	// 最后一段重名时（/v1/users 与 /admin/users）使用完整路径
+	for _, r := range resources {
+		if names[r.Name] > 1 {
+			r.Name = strings.Trim(openapiNameInvalidRe.ReplaceAllString(r.CollectionPath, "_"), "_")
+		}
+	}
+	return resources
+}
+
+// ---- Adapter ----
+
+type openapiAdapter struct {
+	remote    *egoAdapter
+	cfg       openapiConfig
+	resources map[string]*openapiResource
+}
+
+func newOpenAPIAdapter(cfg *databaseConfig, auth authConfig) (*openapiAdapter, error) {
+	doc, err := loadOpenAPIDoc(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := cfg.OpenAPI.BaseURL
+	if baseURL == "" && len(doc.Servers) > 0 {
+		baseURL = doc.Servers[0].URL
+		// servers 中的相对地址以文档地址为基准
+		if specURL, err := url.Parse(cfg.DSN); err == nil && specURL.IsAbs() {
+			if u, err := specURL.Parse(baseURL); err == nil {
+				baseURL = u.String()
+			}
+		}
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("openapi.base_url is required when the spec has no servers")
+	}
+	remote, err := newEgoAdapter(&databaseConfig{DSN: baseURL, Ego: cfg.OpenAPI.egoConfig}, auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url %s: %w", baseURL, err)
+	}
+	a := &openapiAdapter{remote: remote, cfg: cfg.OpenAPI, resources: map[string]*openapiResource{}}
+	if a.cfg.PageParam == "" {
+		a.cfg.PageParam = defaultOpenAPIPageParam
+	}
+	if a.cfg.PageSizeParam == "" {
+		a.cfg.PageSizeParam = defaultOpenAPIPageSizeParam
+	}
+	for _, r := range openapiResources(doc) {
+		a.resources[r.Name] = r
+	}
+	return a, nil
+}
+
+func (a *openapiAdapter) resource(tc *tableConfig, op string, supported func(*openapiResource) bool) (*openapiResource, error) {
+	r, ok := a.resources[tc.Name]
+	if !ok {
+		return nil, fmt.Errorf("table %s is not described by the openapi spec", tc.Name)
+	}
+	if !supported(r) {
+		return nil, fmt.Errorf("%s is not supported by the backend for table %s", op, tc.Name)
+	}
+	return r, nil
+}
+
+func (r *openapiResource) recordPath(tc *tableConfig, filter map[string]interface{}) (string, error) {
+	v, ok := filter[tc.PrimaryKey]
+	if !ok || len(filter) != 1 {
+		return "", fmt.Errorf("openapi backend only supports lookup by primary key %s", tc.PrimaryKey)
+	}
+	return strings.Replace(r.ItemPath, "{"+r.IDParam+"}", url.PathEscape(fmt.Sprint(v)), 1), nil
+}
+
+// projectFields 后端不支持字段选择，在本地裁剪
+func projectFields(record map[string]interface{}, fields string) map[string]interface{} {
+	if fields == "" || record == nil {
+		return record
+	}
+	out := map[string]interface{}{}
+	for _, f := range parseStringList(fields) {
+		if v, ok := record[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+func (a *openapiAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	r, err := a.resource(tc, "list", func(r *openapiResource) bool { return r.Collection.Get != nil })
+	if err != nil {
+		return nil, 0, err
+	}
+	query := url.Values{}
+	for k, v := range params.QueryFilters {
+		if isReservedQueryParam(k) {
+			continue
+		}
+		if strings.Contains(k, "__") {
+			return nil, 0, fmt.Errorf("filter '%s' is not supported by the openapi backend, only equality filters are forwarded", k)
+		}
+		query[k] = v
+	}
+	if params.Order != "" {
+		if a.cfg.SortParam == "" {
+			return nil, 0, fmt.Errorf("order is not supported by the openapi backend")
+		}
+		query.Set(a.cfg.SortParam, params.Order)
+	}
+	if a.cfg.OffsetParam != "" {
+		query.Set(a.cfg.OffsetParam, strconv.Itoa((params.Page-1)*params.PageSize))
+	} else {
+		query.Set(a.cfg.PageParam, strconv.Itoa(params.Page))
+	}
+	query.Set(a.cfg.PageSizeParam, strconv.Itoa(params.PageSize))
+	var resp interface{}
+	if err := a.remote.do(ctx, http.MethodGet, r.CollectionPath, query, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+	rows, total := a.listResponse(resp)
+	if total < 0 {
+		// 后端不返回总数时，总数为截至本页的条数
+		total = int64((params.Page-1)*params.PageSize + len(rows))
+	}
+	out := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if m, ok := row.(map[string]interface{}); ok {
+			out = append(out, projectFields(m, params.Fields))
+		}
+	}
+	return out, total, nil
+}
+
+// listResponse 解析列表响应：数组，或包含数据与总数字段的对象；未找到总数时返回 -1
+func (a *openapiAdapter) listResponse(resp interface{}) ([]interface{}, int64) {
+	if rows, ok := resp.([]interface{}); ok {
+		return rows, -1
+	}
+	obj, ok := resp.(map[string]interface{})
+	if !ok {
+		return nil, -1
+	}
+	dataFields, totalFields := defaultOpenAPIDataFields, defaultOpenAPITotalFields
+	if a.cfg.DataField != "" {
+		dataFields = []string{a.cfg.DataField}
+	}
+	if a.cfg.TotalField != "" {
+		totalFields = []string{a.cfg.TotalField}
+	}
+	var rows []interface{}
+	for _, f := range dataFields {
+		if v, ok := obj[f].([]interface{}); ok {
+			rows = v
+			break
+		}
+	}
+	for _, f := range totalFields {
+		if n, ok := obj[f].(json.Number); ok {
+			if total, err := n.Int64(); err == nil {
+				return rows, total
+			}
+		}
+	}
+	return rows, -1
+}
+
+func (a *openapiAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
+	r, err := a.resource(tc, "create", func(r *openapiResource) bool { return r.Collection.Post != nil })
+	if err != nil {
+		return nil, nil, err
+	}
+	created := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		var resp interface{}
+		if err := a.remote.do(ctx, http.MethodPost, r.CollectionPath, nil, record, &resp); err != nil {
+			return nil, created, err
+		}
+		// 后端不返回创建结果时使用请求内容
+		if m, ok := resp.(map[string]interface{}); ok {
+			created = append(created, m)
+		} else {
+			created = append(created, record)
+		}
+	}
+	return nil, created, nil
+}
+
+func (a *openapiAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
+	var matched, modified int64
+	for _, record := range records {
+		data := copyRecord(record)
+		delete(data, tc.PrimaryKey)
+		m, n, err := a.UpdateOne(ctx, tc, map[string]interface{}{tc.PrimaryKey: record[tc.PrimaryKey]}, data)
+		if err != nil && !isNotFoundErr(err) {
+			return matched, modified, err
+		}
+		matched += m
+		modified += n
+	}
+	return matched, modified, nil
+}
+
+func (a *openapiAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
+	var deleted int64
+	for _, id := range ids {
+		n, err := a.DeleteOne(ctx, tc, map[string]interface{}{tc.PrimaryKey: id})
+		if err != nil && !isNotFoundErr(err) {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func (a *openapiAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
+	r, err := a.resource(tc, "get", func(r *openapiResource) bool { return r.Item.Get != nil })
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.recordPath(tc, filter)
+	if err != nil {
+		return nil, err
+	}
+	var record map[string]interface{}
+	if err := a.remote.do(ctx, http.MethodGet, p, nil, nil, &record); err != nil {
+		return nil, err
+	}
+	return projectFields(record, fields), nil
+}
+
+func (a *openapiAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
+	r, err := a.resource(tc, "update", func(r *openapiResource) bool { return r.Item.Patch != nil || r.Item.Put != nil })
+	if err != nil {
+		return 0, 0, err
+	}
+	p, err := r.recordPath(tc, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	method := http.MethodPatch
+	if r.Item.Patch == nil {
+		method = http.MethodPut
+	}
+	if err := a.remote.do(ctx, method, p, nil, data, nil); err != nil {
+		return 0, 0, err
+	}
+	return 1, 1, nil
+}
+
+func (a *openapiAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
+	r, err := a.resource(tc, "delete", func(r *openapiResource) bool { return r.Item.Delete != nil })
+	if err != nil {
+		return 0, err
+	}
+	p, err := r.recordPath(tc, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.remote.do(ctx, http.MethodDelete, p, nil, nil, nil); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (a *openapiAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
+	_, total, err := a.List(ctx, tc, listParams{Page: 1, PageSize: 1})
+	return total, err
+}
+
+func (a *openapiAdapter) Close() error {
+	return a.remote.Close()
+}
+
+// ---- OpenAPI 元数据 ----
+
+func extractOpenAPIMeta(dsn, dbName string) ([]TableMeta, error) {
+	doc, err := loadOpenAPIDoc(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open openapi database %s failed: %w", dbName, err)
+	}
+	var tables []TableMeta
+	for _, r := range openapiResources(doc) {
+		tm := TableMeta{Name: r.Name, Comment: r.Comment, PrimaryKey: r.primaryKey()}
+		props := map[string]*openapiSchema{}
+		required := map[string]bool{}
+		if r.Schema != nil {
+			props = r.Schema.Properties
+			for _, name := range r.Schema.Required {
+				required[name] = true
+			}
+		}
+		names := make([]string, 0, len(props)+1)
+		for name := range props {
+			names = append(names, name)
+		}
+		if _, ok := props[tm.PrimaryKey]; !ok {
+			// schema 中没有路径参数对应的字段，按字符串主键补上
+			names = append(names, tm.PrimaryKey)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := doc.resolve(props[name])
+			if prop == nil {
+				prop = &openapiSchema{Type: "string"}
+			}
+			typ := egoFieldType(string(prop.Type), prop.Format)
+			nullable := !required[name] && name != tm.PrimaryKey
+			tm.Fields = append(tm.Fields, FieldMeta{
+				Name:      name,
+				Type:      typ,
+				Nullable:  nullable,
+				IsPrimary: name == tm.PrimaryKey,
+				AutoInc:   prop.ReadOnly && name == tm.PrimaryKey,
+				Default:   convertDefaultByType("", typ, nullable),
+				Comment:   prop.Description,
+			})
+		}
+		tables = append(tables, tm)
+	}
+	return tables, nil
+}
@@ -0,0 +1,163 @@
+package apix
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// --------- OR 条件 ---------
+//
+// 普通过滤参数之间都是 AND，_or 参数中的条件之间为 OR，写法为 字段__操作符:值，多个条件用逗号分隔：
+//
+//	GET /api/rest/test/user?_or=username__like:foo%25,email__icontains:bar&age__gte=18
+//	  => age >= 18 AND (username LIKE 'foo%' OR LOWER(email) LIKE LOWER('%bar%'))
+//
+// 多个 _or 参数各自为一组，组与组之间、组与其他过滤条件之间为 AND：
+//
+//	?_or=status:1,status:2&_or=age__lt:18,age__gt:60
+//
+// 操作符与普通过滤参数相同；__in、__between 的值本身含逗号，逗号后不是 字段:、字段__操作符: 形式的部分
+// 归入前一个条件的值，如 _or=id__in:1,2,3,username:root。
+// 支持 SQL 类库、MongoDB 与 ego 联邦库，其他类型的库返回 501；语法错误返回 400。
+
+const queryParamOr = "_or"
+
+// orFilterer 支持 _or 条件的适配器
+type orFilterer interface {
+	supportsOrFilters()
+}
+
+func (a *gormAdapter) supportsOrFilters()    {}
+func (a *mongoAdapter) supportsOrFilters()   {}
+func (a *shardedAdapter) supportsOrFilters() {}
+func (a *egoAdapter) supportsOrFilters()     {}
+
+var orConditionKeyRe = regexp.MustCompile(`^[A-Za-z_][\w.]*(__[a-z]+)?:`)
+
+// orCondition 为 _or 中的一个条件，Key 与普通过滤参数的键相同（字段__操作符）
+type orCondition struct {
+	Key   string
+	Value string
+}
+
+// parseOrFilter 解析一个 _or 参数
+func parseOrFilter(value string) ([]orCondition, error) {
+	var conds []orCondition
+	for _, part := range strings.Split(value, ",") {
+		if orConditionKeyRe.MatchString(part) {
+			key, v, _ := strings.Cut(part, ":")
+			if key == queryParamOr || strings.HasPrefix(key, queryParamOr+"__") {
+				return nil, fmt.Errorf("invalid %s: nested %s is not supported", queryParamOr, queryParamOr)
+			}
+			conds = append(conds, orCondition{Key: key, Value: v})
+			continue
+		}
+		if len(conds) == 0 {
+			return nil, fmt.Errorf("invalid %s condition %q, expected field__op:value", queryParamOr, part)
+		}
+		conds[len(conds)-1].Value += "," + part
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("invalid %s: no conditions", queryParamOr)
+	}
+	return conds, nil
+}
+
+func formatOrFilter(conds []orCondition) string {
+	parts := make([]string, len(conds))
+	for i, c := range conds {
+		parts[i] = c.Key + ":" + c.Value
+	}
+	return strings.Join(parts, ",")
+}
+
+// validateOrFilters 检查请求中全部 _or 参数的语法
+func validateOrFilters(filters url.Values) error {
+	for _, v := range filters[queryParamOr] {
+		if _, err := parseOrFilter(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// physicalOrFilters 把 _or 条件中的字段别名转换为物理列名
+func (tc *tableConfig) physicalOrFilters(values []string) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		conds, err := parseOrFilter(v)
+		if err != nil {
+			result[i] = v
+			continue
+		}
+		for j, c := range conds {
+			field, op, hasOp := strings.Cut(c.Key, "__")
+			conds[j].Key = tc.physicalFieldName(field)
+			if hasOp {
+				conds[j].Key += "__" + op
+			}
+		}
+		result[i] = formatOrFilter(conds)
+	}
+	return result
+}
+
+// applyGormOrFilters 把每个 _or 参数转换为一组 OR 条件
+func applyGormOrFilters(db *gorm.DB, values []string) *gorm.DB {
+	for _, v := range values {
+		conds, err := parseOrFilter(v)
+		if err != nil {
+			db.AddError(err)
+			continue
+		}
+		var group *gorm.DB
+		for _, c := range conds {
+			// Initialized 立即创建不含已有条件的新 Statement
+			cond, _ := applyGormQueryFilters(db.Session(&gorm.Session{NewDB: true, Initialized: true}), url.Values{c.Key: {c.Value}})
+			// 与普通过滤参数一样忽略无效的值（如 __isnull:abc），否则空条件会让整组恒为真
+			if _, ok := cond.Statement.Clauses["WHERE"]; !ok {
+				continue
+			}
+			if group == nil {
+				group = db.Session(&gorm.Session{NewDB: true}).Where(cond)
+			} else {
+				group = group.Or(cond)
+			}
+		}
+		if group != nil {
+			db = db.Where(group)
+		}
+	}
+	return db
+}
+
+// buildMongoOrFilters 把每个 _or 参数转换为 $or，放在 $and 中以免与软删除条件的 $or 冲突
+func buildMongoOrFilters(filter bson.M, values []string) bson.M {
+	and, _ := filter["$and"].([]bson.M)
+	for _, v := range values {
+		conds, err := parseOrFilter(v)
+		if err != nil {
+			// 语法已在 withFilterParams 中校验，这里只会是内部调用传入的错误参数，不匹配任何记录
+			and = append(and, bson.M{"$expr": false})
+			continue
+		}
+		or := make([]bson.M, 0, len(conds))
+		for _, c := range conds {
+			if sub, _ := buildMongoQueryFilter(bson.M{}, url.Values{c.Key: {c.Value}}); len(sub) > 0 {
+				or = append(or, sub)
+			}
+		}
+		if len(or) > 0 {
+			and = append(and, bson.M{"$or": or})
+		}
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
@@ -0,0 +1,288 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// --------- 批量创建部分成功 POST /:database/:table?on_error=continue ---------
+//
+// 默认批量创建要么全部成功要么全部失败；on_error=continue 时跳过失败的记录，其余照常提交：
+//
+//	{"data": [...成功的记录], "errors": [{"index": 3, "error": "..."}], "created": 9, "failed": 1}
+//
+// 全部成功返回 201，部分成功返回 207，全部失败返回 422。
+// SQL 库按块插入，块失败时回滚到保存点再逐条插入定位失败记录；Mongo 使用无序 InsertMany；
+// 其余适配器逐条调用 BatchCreate。
+
+const (
+	queryParamOnError = "on_error"
+	onErrorAbort      = "abort"
+	onErrorContinue   = "continue"
+
+	partialCreateChunkSize = 200
+)
+
+type recordFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// partialCreator 为可选能力：批量创建时跳过失败记录。
+// 返回的 ids/created 与 records 等长，失败位置为 nil。
+type partialCreator interface {
+	BatchCreatePartial(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error)
+}
+
+func parseOnError(c *gin.Context) (bool, error) {
+	switch v := c.Query(queryParamOnError); v {
+	case "", onErrorAbort:
+		return false, nil
+	case onErrorContinue:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s: %s, expected %s or %s", queryParamOnError, v, onErrorAbort, onErrorContinue)
+	}
+}
+
+// batchCreatePartial 优先使用适配器的原生实现，否则逐条创建
+func batchCreatePartial(ctx context.Context, adapter databaseAdapter, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error) {
+	if pc, ok := adapter.(partialCreator); ok {
+		return pc.BatchCreatePartial(ctx, tc, records)
+	}
+	ids := make([]interface{}, len(records))
+	created := make([]map[string]interface{}, len(records))
+	var failures []recordFailure
+	for i, r := range records {
+		rowIDs, rows, err := adapter.BatchCreate(ctx, tc, []map[string]interface{}{r})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, nil, ctx.Err()
+			}
+			failures = append(failures, recordFailure{Index: i, Error: err.Error()})
+			continue
+		}
+		if len(rowIDs) > 0 {
+			ids[i] = rowIDs[0]
+		}
+		created[i] = r
+		if len(rows) > 0 {
+			created[i] = rows[0]
+		}
+	}
+	return ids, created, failures, nil
+}
+
+func (dm *databaseManager) handleBatchCreatePartial(c *gin.Context, adapter databaseAdapter, tc *tableConfig, records []map[string]interface{}, dryRun, perRecord bool) {
+	var ids []interface{}
+	var created []map[string]interface{}
+	var failures []recordFailure
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		ids, created, failures, err = batchCreatePartialChecked(c.Request.Context(), a, tc, records)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to batch create: " + err.Error()})
+		return
+	}
+	data := make([]map[string]interface{}, 0, len(records))
+	for i, r := range created {
+		if r == nil {
+			continue
+		}
+		if i < len(ids) && ids[i] != nil {
+			r[tc.PrimaryKey] = ids[i]
+		}
+		data = append(data, r)
+	}
+	if failures == nil {
+		failures = []recordFailure{}
+	}
+	data = fixPkFieldToString(data, tc.PrimaryKey).([]map[string]interface{})
+	var results []recordResult
+	if perRecord {
+		// created 与 data 共用同一批 map，此时主键已回填
+		results = createResults(tc, ids, created, failures)
+	}
+	resp := gin.H{"data": tc.renderRecords(data), "errors": failures, "created": len(data), "failed": len(failures)}
+	if perRecord {
+		resp["results"] = results
+	}
+	if dryRun {
+		resp["dry_run"] = true
+		resp["executed"] = executed
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.JSON(partialCreateStatus(len(data), len(failures)), resp)
+}
+
+// partialCreateStatus 按成功与失败数量选择响应状态码
+func partialCreateStatus(created, failed int) int {
+	switch {
+	case failed == 0:
+		return http.StatusCreated
+	case created == 0:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusMultiStatus
+	}
+}
+
+// ---- GORM：分块 + 保存点 ----
+
+func (a *gormAdapter) BatchCreatePartial(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error) {
+	created := make([]map[string]interface{}, len(records))
+	var failures []recordFailure
+	insert := func(db *gorm.DB, start, end int, savepoints bool) error {
+		chunk := records[start:end]
+		chunkErr, err := a.tryCreate(db, tc, chunk, fmt.Sprintf("ego_chunk_%d", start), savepoints)
+		if err != nil {
+			return err
+		}
+		if chunkErr == nil {
+			copy(created[start:end], chunk)
+			return nil
+		}
+		// 块失败后逐条插入，定位失败的记录
+		for i := start; i < end; i++ {
+			row := []map[string]interface{}{records[i]}
+			rowErr, err := a.tryCreate(db, tc, row, fmt.Sprintf("ego_row_%d", i), savepoints)
+			if err != nil {
+				return err
+			}
+			if rowErr != nil {
+				failures = append(failures, recordFailure{Index: i, Error: rowErr.Error()})
+				continue
+			}
+			created[i] = records[i]
+		}
+		return nil
+	}
+	run := func(db *gorm.DB, savepoints bool) error {
+		for i := range created {
+			created[i] = nil
+		}
+		failures = nil
+		for start := 0; start < len(records); start += partialCreateChunkSize {
+			end := start + partialCreateChunkSize
+			if end > len(records) {
+				end = len(records)
+			}
+			if err := insert(db, start, end, savepoints); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if _, ok := a.db.Dialector.(gorm.SavePointerDialectorInterface); ok {
+		err = a.transaction(ctx, func(tx *gorm.DB) error { return run(tx, true) })
+	} else {
+		// 不支持保存点（如 ClickHouse）时不开事务，单条 INSERT 语句本身是原子的
+		err = run(a.db.WithContext(ctx), false)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return make([]interface{}, len(records)), created, failures, nil
+}
+
+// tryCreate 插入一批记录，插入失败时回滚到保存点并作为第一个返回值；
+// 第二个返回值为需要中止整个请求的错误（保存点失败、请求取消）
+func (a *gormAdapter) tryCreate(db *gorm.DB, tc *tableConfig, rows []map[string]interface{}, savepoint string, savepoints bool) (createErr error, err error) {
+	if savepoints {
+		if err := db.SavePoint(savepoint).Error; err != nil {
+			return nil, err
+		}
+	}
+	createErr = db.Table(tc.Name).Create(&rows).Error
+	if createErr == nil {
+		return nil, nil
+	}
+	if errors.Is(createErr, context.Canceled) || errors.Is(createErr, context.DeadlineExceeded) {
+		return nil, createErr
+	}
+	if savepoints {
+		if err := db.RollbackTo(savepoint).Error; err != nil {
+			return nil, err
+		}
+	}
+	return createErr, nil
+}
+
+// ---- Mongo：无序 InsertMany ----
+
+func (a *mongoAdapter) BatchCreatePartial(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error) {
+	collection := a.collection(ctx, tc)
+	docs := make([]interface{}, len(records))
+	for i, rec := range records {
+		docs[i] = rec
+	}
+	res, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	failed := map[int]string{}
+	if err != nil {
+		var bwe mongo.BulkWriteException
+		if !errors.As(err, &bwe) || len(bwe.WriteErrors) == 0 {
+			return nil, nil, nil, err
+		}
+		for _, we := range bwe.WriteErrors {
+			failed[we.Index] = we.Message
+		}
+	}
+	ids := make([]interface{}, len(records))
+	created := make([]map[string]interface{}, len(records))
+	var failures []recordFailure
+	for i := range records {
+		if msg, ok := failed[i]; ok {
+			failures = append(failures, recordFailure{Index: i, Error: msg})
+			continue
+		}
+		if res != nil && i < len(res.InsertedIDs) {
+			ids[i] = res.InsertedIDs[i]
+		}
+		created[i] = records[i]
+	}
+	return ids, created, failures, nil
+}
+
+// ---- 分片：各分片分别执行后按原顺序合并 ----
+
+func (a *shardedAdapter) BatchCreatePartial(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, []recordFailure, error) {
+	groups, err := a.groupRecords(tc, records)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ids := make([]interface{}, len(records))
+	created := make([]map[string]interface{}, len(records))
+	var failures []recordFailure
+	for s, idx := range groups {
+		batch := make([]map[string]interface{}, len(idx))
+		for j, i := range idx {
+			batch[j] = records[i]
+		}
+		batchIDs, batchCreated, batchFailures, err := s.BatchCreatePartial(ctx, tc, batch)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for j, i := range idx {
+			ids[i] = batchIDs[j]
+			created[i] = batchCreated[j]
+		}
+		for _, f := range batchFailures {
+			failures = append(failures, recordFailure{Index: idx[f.Index], Error: f.Error})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	return ids, created, failures, nil
+}
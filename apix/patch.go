@@ -0,0 +1,78 @@
+package apix
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 单条记录部分更新 ---------
+//
+// PATCH 与 PUT 使用相同的查找方式（:id、key 参数）与校验，只写入请求体中出现的字段，值为 null 时置空：
+//
+//	PATCH /api/rest/test/user/1
+//	{"nickname": "tom"}
+//	  => {"message": "Update successful", "matched_count": 1, "modified_count": 1}
+//
+// 与 PUT 的区别：
+//   - 请求体中有表结构之外的字段时返回 400（unknown field），表结构在加载配置时从提取的元数据读取，取不到时返回 503
+//   - auto_update 字段由服务端写入，请求体中的同名字段忽略；只含这些字段时返回 400（No fields to update）
+//
+// return=representation、dry_run、If-Match 等参数与 PUT 相同。
+
+func (dm *databaseManager) handlePatchOne(c *gin.Context) {
+	dm.updateOne(c, true)
+}
+
+// checkPatchFields 拒绝表结构之外的字段并移除 auto_update 字段，不通过时写 400（表结构不可用时 503）并返回 false
+func (dm *databaseManager) checkPatchFields(c *gin.Context, tc *tableConfig, record map[string]interface{}) bool {
+	if tc.columns == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "table metadata unavailable: " + tc.Alias})
+		return false
+	}
+	fields := make([]string, 0, len(record))
+	for k := range record {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		if !tc.hasColumn(f) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tc.apiFieldName(f)})
+			return false
+		}
+	}
+	for _, f := range tc.GetAutoUpdateFields() {
+		delete(record, f)
+	}
+	return true
+}
+
+// loadTableColumns 加载配置时读取各表的列（物理列名），取不到元数据的表 columns 为 nil
+func (dm *databaseManager) loadTableColumns() {
+	for name, dbCfg := range dm.config.Databases {
+		metas, err := dm.tableMetas(name, dbCfg)
+		if err != nil {
+			log.Printf("[Meta] columns of %s unavailable: %v", name, err)
+			continue
+		}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			meta := findTableMeta(metas, tc.Name)
+			if meta == nil {
+				continue
+			}
+			tc.columns = make(map[string]struct{}, len(meta.Fields))
+			for _, f := range meta.Fields {
+				tc.columns[f.Name] = struct{}{}
+			}
+		}
+	}
+}
+
+// hasColumn 判断物理列是否在表结构中
+func (tc *tableConfig) hasColumn(name string) bool {
+	_, ok := tc.columns[name]
+	return ok
+}
@@ -0,0 +1,296 @@
+package apix
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --------- Mongo 聚合管道透传 POST /:database/:table/aggregate_pipeline ---------
+//
+// 请求体为 {"pipeline": [...]}，支持 Extended JSON（如 {"$oid": "..."}、{"$date": "..."}）。
+// 仅允许白名单内的阶段，$out/$merge 始终禁止；嵌套在 $lookup/$facet/$unionWith 中的子管道同样校验。
+// 表配置 pipeline_stages 可覆盖默认白名单。默认白名单不含 $lookup、$graphLookup、$unionWith：它们读取其他集合，
+// 不经过目标表的 row_filter、软删除与字段隐藏、脱敏，需要时在表配置中显式列出。
+//
+// hidden_fields 在用户阶段之前用 $unset 从文档中移除，$project/$addFields/$group 等阶段无法再引用或改名输出；
+// 唯一先于 $unset 执行的 $geoNear 阶段引用隐藏字段时返回 400。
+// masked_fields 只按原字段名在输出中脱敏，改名或参与计算后的值不再脱敏，需要时将字段配置为隐藏。
+// 默认仅 admin 角色可调用（operation_roles.aggregate_pipeline 可覆盖）。
+//
+// 分页：?page_size=100&cursor=<上次返回的 next_cursor>，游标与管道内容绑定。
+
+const queryParamCursor = "cursor"
+
+var defaultPipelineStages = []string{
+	"$match", "$project", "$addFields", "$set", "$unset", "$group", "$sort", "$limit", "$skip",
+	"$unwind", "$count", "$facet", "$bucket", "$bucketAuto", "$sortByCount",
+	"$replaceRoot", "$replaceWith", "$sample", "$geoNear",
+	"$setWindowFields", "$densify", "$fill", "$redact",
+}
+
+// 写入类阶段，不受白名单配置影响
+var blockedPipelineStages = map[string]struct{}{"$out": {}, "$merge": {}}
+
+// pipelineAggregator 由支持原生聚合管道的适配器实现
+type pipelineAggregator interface {
+	AggregatePipeline(ctx context.Context, tc *tableConfig, pipeline []bson.D, skip, limit int64) ([]map[string]interface{}, error)
+}
+
+type pipelineCursor struct {
+	Offset int64  `json:"o"`
+	Hash   string `json:"h"`
+}
+
+func encodePipelineCursor(cur pipelineCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePipelineCursor(s string) (pipelineCursor, error) {
+	var cur pipelineCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &cur)
+	}
+	if err != nil || cur.Offset < 0 {
+		return cur, fmt.Errorf("invalid cursor")
+	}
+	return cur, nil
+}
+
+// validatePipeline 校验每个阶段都在白名单内，并递归检查子管道
+func validatePipeline(pipeline []bson.D, allowed []string) error {
+	for i, stage := range pipeline {
+		if len(stage) != 1 {
+			return fmt.Errorf("stage %d must have exactly one operator", i)
+		}
+		op := stage[0].Key
+		if _, ok := blockedPipelineStages[op]; ok {
+			return fmt.Errorf("stage %s is not allowed", op)
+		}
+		if !contains(allowed, op) {
+			return fmt.Errorf("stage %s is not allowed", op)
+		}
+		for _, sub := range subPipelines(op, stage[0].Value) {
+			if err := validatePipeline(sub, allowed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkGeoNearHiddenFields 拒绝引用隐藏字段的 $geoNear（它先于隐藏字段的 $unset 执行）
+func checkGeoNearHiddenFields(pipeline []bson.D, hidden []string) error {
+	if len(pipeline) == 0 || pipeline[0][0].Key != "$geoNear" {
+		return nil
+	}
+	for _, f := range hidden {
+		if referencesField(pipeline[0][0].Value, f) {
+			return fmt.Errorf("stage $geoNear references hidden field %s", f)
+		}
+	}
+	return nil
+}
+
+// referencesField 判断阶段定义中的键或字符串取值是否引用了字段（含 "$field" 与 "field.sub" 形式）
+func referencesField(v interface{}, field string) bool {
+	isField := func(s string) bool {
+		s = strings.TrimPrefix(s, "$")
+		return s == field || strings.HasPrefix(s, field+".")
+	}
+	switch x := v.(type) {
+	case bson.D:
+		for _, e := range x {
+			if isField(e.Key) || referencesField(e.Value, field) {
+				return true
+			}
+		}
+	case bson.M:
+		for k, val := range x {
+			if isField(k) || referencesField(val, field) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, val := range x {
+			if referencesField(val, field) {
+				return true
+			}
+		}
+	case string:
+		return isField(x)
+	}
+	return false
+}
+
+// subPipelines 取出 $lookup/$unionWith 的 pipeline 与 $facet 的各分支
+func subPipelines(op string, spec interface{}) [][]bson.D {
+	doc, ok := spec.(bson.D)
+	if !ok {
+		return nil
+	}
+	var result [][]bson.D
+	for _, e := range doc {
+		if (op == "$lookup" || op == "$unionWith") && e.Key == "pipeline" || op == "$facet" {
+			if p, ok := toPipeline(e.Value); ok {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+func toPipeline(v interface{}) ([]bson.D, bool) {
+	arr, ok := v.(bson.A)
+	if !ok {
+		return nil, false
+	}
+	pipeline := make([]bson.D, 0, len(arr))
+	for _, s := range arr {
+		d, ok := s.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		pipeline = append(pipeline, d)
+	}
+	return pipeline, true
+}
+
+func (dm *databaseManager) handleAggregatePipeline(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opAggregatePipeline) {
+		return
+	}
+	aggregator, ok := adapter.(pipelineAggregator)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "aggregate_pipeline is only supported by mongodb"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	var req struct {
+		Pipeline bson.A `bson:"pipeline"`
+	}
+	if err := bson.UnmarshalExtJSON(body, false, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pipeline: " + err.Error()})
+		return
+	}
+	pipeline, ok := toPipeline(req.Pipeline)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pipeline must be an array of stage documents"})
+		return
+	}
+	allowed := tableConfig.PipelineStages
+	if len(allowed) == 0 {
+		allowed = defaultPipelineStages
+	}
+	if err := validatePipeline(pipeline, allowed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkGeoNearHiddenFields(pipeline, tableConfig.HiddenFields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pageSize := dm.config.DefaultPageSize
+	if v := c.Query(queryParamPageSize); v != "" {
+		if pageSize, err = strconv.Atoi(v); err != nil || pageSize < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page_size"})
+			return
+		}
+	}
+	if pageSize > dm.config.MaxPageSize {
+		pageSize = dm.config.MaxPageSize
+	}
+	// 游标与管道绑定，管道变化后旧游标失效
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:8])
+	var offset int64
+	if s := c.Query(queryParamCursor); s != "" {
+		cur, err := decodePipelineCursor(s)
+		if err != nil || cur.Hash != hash {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor for this pipeline"})
+			return
+		}
+		offset = cur.Offset
+	}
+
+	// 多取一条判断是否还有下一页
+	rows, err := aggregator.AggregatePipeline(c.Request.Context(), tableConfig, pipeline, offset, int64(pageSize)+1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to run pipeline: " + err.Error()})
+		return
+	}
+	resp := gin.H{}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		resp["next_cursor"] = encodePipelineCursor(pipelineCursor{Offset: offset + int64(pageSize), Hash: hash})
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	resp["data"] = tableConfig.renderRecords(rows)
+	c.JSON(http.StatusOK, resp)
+}
+
+// AggregatePipeline 在软删除过滤与隐藏字段移除之后执行用户管道（$geoNear 必须是第一个阶段，两者插在其后）
+func (a *mongoAdapter) AggregatePipeline(ctx context.Context, tc *tableConfig, pipeline []bson.D, skip, limit int64) ([]map[string]interface{}, error) {
+	insertAt := 0
+	if len(pipeline) > 0 && pipeline[0][0].Key == "$geoNear" {
+		insertAt = 1
+	}
+	stages := make(bson.A, 0, len(pipeline)+4)
+	for _, stage := range pipeline[:insertAt] {
+		stages = append(stages, stage)
+	}
+	if softDelete := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc); len(softDelete) > 0 {
+		stages = append(stages, bson.D{{Key: "$match", Value: softDelete}})
+	}
+	if len(tc.HiddenFields) > 0 {
+		stages = append(stages, bson.D{{Key: "$unset", Value: tc.HiddenFields}})
+	}
+	for _, stage := range pipeline[insertAt:] {
+		stages = append(stages, stage)
+	}
+	stages = append(stages, bson.D{{Key: "$skip", Value: skip}}, bson.D{{Key: "$limit", Value: limit}})
+
+	// 嵌套文档解码为 map，便于 JSON 输出
+	collection := a.collection(ctx, tc, options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+	cur, err := collection.Aggregate(ctx, stages)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var results []map[string]interface{}
+	for cur.Next(ctx) {
+		var doc map[string]interface{}
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		results = append(results, doc)
+	}
+	return results, cur.Err()
+}
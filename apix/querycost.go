@@ -0,0 +1,323 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 查询代价预算 ---------
+//
+// 列表查询执行前估算需要扫描的行数，超出预算时拒绝或降级，避免未建索引的过滤、排序拖垮数据库：
+//
+//	query_budget:              # 全局，表配置中可覆盖
+//	  max_rows: 100000         # 预计扫描行数上限，0 为不限制；表配置设为 -1 时该表不限制
+//	  action: reject           # reject：返回 422；degrade：缩小 page_size 并不统计 total
+//	  degrade_page_size: 20    # degrade 时的 page_size 上限，默认 20
+//
+// 只有带过滤条件或按非主键排序的查询需要估算：MySQL、TiDB、PostgreSQL、CockroachDB 取 EXPLAIN 的预计行数，
+// SQLite 与 MongoDB 按查询计划判断是否全表扫描，全表扫描时取表的行数（SQLite 的 max(rowid)、MongoDB 集合统计的文档数）；
+// 其他数据库不估算。页码分页跳过的行同样计入，跳过的行超出预算时 degrade 也会拒绝。估算失败时不限制，只记录日志。
+//
+// 拒绝时返回 422：{"error": "...", "estimated_rows": 250000, "max_rows": 100000}；
+// 降级时响应中 total 为 null，并返回 "degraded": {"estimated_rows": 250000, "max_rows": 100000, "page_size": 20}。
+// GraphQL 直连列表查询同样适用，降级时 total 为 null。
+
+const (
+	queryBudgetReject  = "reject"
+	queryBudgetDegrade = "degrade"
+
+	defaultDegradePageSize = 20
+)
+
+type queryBudgetConfig struct {
+	MaxRows         int64  `mapstructure:"max_rows"`
+	Action          string `mapstructure:"action"`
+	DegradePageSize int    `mapstructure:"degrade_page_size"`
+}
+
+func (b queryBudgetConfig) validate() error {
+	switch b.Action {
+	case "", queryBudgetReject, queryBudgetDegrade:
+		return nil
+	}
+	return fmt.Errorf("invalid query_budget.action %q, expected reject or degrade", b.Action)
+}
+
+// validateQueryBudgets 校验全局与各表的 query_budget
+func (dm *databaseManager) validateQueryBudgets() error {
+	if err := dm.config.QueryBudget.validate(); err != nil {
+		return err
+	}
+	for dbName, db := range dm.config.Databases {
+		for _, tc := range db.Tables {
+			if err := tc.QueryBudget.validate(); err != nil {
+				return fmt.Errorf("%s.%s: %w", dbName, tc.Alias, err)
+			}
+		}
+	}
+	return nil
+}
+
+// queryBudget 返回表的代价预算，表配置的 max_rows 非 0 时覆盖全局配置
+func (dm *databaseManager) queryBudget(tc *tableConfig) queryBudgetConfig {
+	if tc.QueryBudget.MaxRows != 0 {
+		return tc.QueryBudget
+	}
+	return dm.config.QueryBudget
+}
+
+// listCostEstimator 由支持估算的适配器实现；ok 为 false 表示无法估算
+type listCostEstimator interface {
+	EstimateList(ctx context.Context, tc *tableConfig, params listParams) (rows int64, ok bool, err error)
+}
+
+type queryCostError struct {
+	EstimatedRows int64
+	MaxRows       int64
+}
+
+func (e *queryCostError) Error() string {
+	return fmt.Sprintf("Query is too expensive: about %d rows would be scanned, exceeding the budget of %d rows; filter or sort on indexed fields, or use cursor pagination", e.EstimatedRows, e.MaxRows)
+}
+
+// queryDegradation 降级后的说明，写入响应的 degraded
+type queryDegradation struct {
+	EstimatedRows int64 `json:"estimated_rows"`
+	MaxRows       int64 `json:"max_rows"`
+	PageSize      int   `json:"page_size"`
+}
+
+// checkListCost 估算列表查询的代价（params 为物理字段名）。超出预算时按配置返回 *queryCostError，
+// 或缩小 params.PageSize、设置 params.SkipCount 并返回降级说明
+func (dm *databaseManager) checkListCost(ctx context.Context, adapter databaseAdapter, tc *tableConfig, params *listParams, keyset bool) (*queryDegradation, *queryCostError) {
+	budget := dm.queryBudget(tc)
+	if budget.MaxRows <= 0 {
+		return nil, nil
+	}
+	var skipped int64
+	if !keyset {
+		skipped = int64(params.Page-1) * int64(params.PageSize)
+	}
+	rows := skipped + int64(params.PageSize)
+	if estimator, ok := adapter.(listCostEstimator); ok && tc.needsCostEstimate(*params) {
+		estimated, ok, err := estimator.EstimateList(ctx, tc, *params)
+		if err != nil {
+			log.Printf("estimate list cost of %s failed: %v", tc.Alias, err)
+		} else if ok {
+			rows = max(rows, estimated)
+		}
+	}
+	if rows <= budget.MaxRows {
+		return nil, nil
+	}
+	if budget.Action != queryBudgetDegrade || skipped > budget.MaxRows {
+		return nil, &queryCostError{EstimatedRows: rows, MaxRows: budget.MaxRows}
+	}
+	pageSize := budget.DegradePageSize
+	if pageSize <= 0 {
+		pageSize = defaultDegradePageSize
+	}
+	params.PageSize = min(params.PageSize, pageSize)
+	params.SkipCount = true
+	return &queryDegradation{EstimatedRows: rows, MaxRows: budget.MaxRows, PageSize: params.PageSize}, nil
+}
+
+// needsCostEstimate 只有带过滤条件或按非主键排序时需要估算
+func (tc *tableConfig) needsCostEstimate(params listParams) bool {
+	for key, values := range params.QueryFilters {
+		if !isReservedQueryParam(key) && len(values) > 0 {
+			return true
+		}
+	}
+	for _, f := range strings.Split(params.Order, ",") {
+		if f = strings.TrimLeft(strings.TrimSpace(f), "-+"); f != "" && f != tc.PrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// writeQueryCostError 写入 422 响应
+func writeQueryCostError(c *gin.Context, err *queryCostError) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "estimated_rows": err.EstimatedRows, "max_rows": err.MaxRows})
+}
+
+// ---- SQL ----
+
+var cockroachRowCountRe = regexp.MustCompile(`estimated row count: ([\d,]+)`)
+
+func (a *gormAdapter) EstimateList(ctx context.Context, tc *tableConfig, params listParams) (int64, bool, error) {
+	dbType := ""
+	if a.config != nil {
+		dbType = strings.ToLower(a.config.Type)
+	}
+	var rows int64
+	ok := true
+	err := a.read(ctx, func(db *gorm.DB) error {
+		query, _ := a.listQuery(db, tc, params)
+		query = a.listOrder(query, tc, params)
+		var plan []map[string]interface{}
+		switch dbType {
+		case "mysql", "tidb":
+			if err := query.Clauses(explainPrefix("EXPLAIN")).Find(&plan).Error; err != nil {
+				return err
+			}
+			for _, step := range plan {
+				// MySQL 为 rows，TiDB 为 estRows
+				rows = max(rows, toInt64(step["rows"]), toInt64(step["estRows"]))
+			}
+		case "postgresql":
+			if err := query.Clauses(explainPrefix("EXPLAIN (FORMAT JSON)")).Find(&plan).Error; err != nil {
+				return err
+			}
+			for _, step := range plan {
+				rows = max(rows, postgresPlanRows(step["QUERY PLAN"]))
+			}
+		case "cockroachdb":
+			if err := query.Clauses(explainPrefix("EXPLAIN")).Find(&plan).Error; err != nil {
+				return err
+			}
+			for _, step := range plan {
+				if m := cockroachRowCountRe.FindStringSubmatch(fmt.Sprint(step["info"])); m != nil {
+					rows = max(rows, toInt64(strings.ReplaceAll(m[1], ",", "")))
+				}
+			}
+		case "sqlite":
+			if err := query.Clauses(explainPrefix("EXPLAIN QUERY PLAN")).Find(&plan).Error; err != nil {
+				return err
+			}
+			// SCAN 为全表（或全索引）扫描，SEARCH 为按索引查找，不估算
+			for _, step := range plan {
+				if strings.HasPrefix(fmt.Sprint(step["detail"]), "SCAN ") {
+					var maxRowID sql.NullInt64
+					if err := db.Table(tc.Name).Select("MAX(rowid)").Row().Scan(&maxRowID); err != nil {
+						return err
+					}
+					rows = maxRowID.Int64
+					break
+				}
+			}
+		default:
+			ok = false
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return rows, ok, nil
+}
+
+// postgresPlanRows 取 EXPLAIN (FORMAT JSON) 中各节点 Plan Rows 的最大值
+func postgresPlanRows(raw interface{}) int64 {
+	var data []byte
+	switch v := raw.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		data, _ = json.Marshal(v)
+	}
+	var plans []struct {
+		Plan map[string]interface{} `json:"Plan"`
+	}
+	if json.Unmarshal(data, &plans) != nil {
+		return 0
+	}
+	var walk func(node map[string]interface{}) int64
+	walk = func(node map[string]interface{}) int64 {
+		rows := toInt64(node["Plan Rows"])
+		children, _ := node["Plans"].([]interface{})
+		for _, child := range children {
+			if m, ok := child.(map[string]interface{}); ok {
+				rows = max(rows, walk(m))
+			}
+		}
+		return rows
+	}
+	var rows int64
+	for _, p := range plans {
+		rows = max(rows, walk(p.Plan))
+	}
+	return rows
+}
+
+// explainPrefix 在 SELECT 前加上 EXPLAIN，保留 gorm_scopes 插入的注释与提示
+type explainPrefix string
+
+func (e explainPrefix) ModifyStatement(stmt *gorm.Statement) {
+	c := stmt.Clauses["SELECT"]
+	if c.BeforeExpression != nil {
+		c.BeforeExpression = clause.Expr{SQL: string(e) + " ?", Vars: []interface{}{c.BeforeExpression}}
+	} else {
+		c.BeforeExpression = clause.Expr{SQL: string(e)}
+	}
+	stmt.Clauses["SELECT"] = c
+}
+
+func (e explainPrefix) Build(clause.Builder) {}
+
+// ---- MongoDB ----
+
+func (a *mongoAdapter) EstimateList(ctx context.Context, tc *tableConfig, params listParams) (int64, bool, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, params.QueryFilters)
+	find := bson.D{{Key: "find", Value: collection.Name()}, {Key: "filter", Value: filter}}
+	if params.Order != "" {
+		find = append(find, bson.E{Key: "sort", Value: mongoSort(params.Order)})
+	}
+	var explain bson.M
+	cmd := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "queryPlanner"}}
+	if err := collection.Database().RunCommand(ctx, cmd).Decode(&explain); err != nil {
+		return 0, false, err
+	}
+	if !mongoPlanHasStage(explain["queryPlanner"], "COLLSCAN") {
+		return 0, true, nil
+	}
+	n, err := collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return n, true, nil
+}
+
+// mongoPlanHasStage 在选中的查询计划（含分片的 shards）中查找指定阶段，不看 rejectedPlans
+func mongoPlanHasStage(node interface{}, stage string) bool {
+	switch v := node.(type) {
+	case bson.M:
+		if v["stage"] == stage {
+			return true
+		}
+		for key, child := range v {
+			if key != "rejectedPlans" && mongoPlanHasStage(child, stage) {
+				return true
+			}
+		}
+	case bson.D:
+		for _, e := range v {
+			if (e.Key == "stage" && e.Value == stage) || (e.Key != "rejectedPlans" && mongoPlanHasStage(e.Value, stage)) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, child := range v {
+			if mongoPlanHasStage(child, stage) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package apix
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 限流 ---------
+//
+// 令牌桶限流，按 API Key 或客户端 IP 分桶：
+//
+//	rate_limit:
+//	  rate: 10                 # 每秒补充的令牌数，0 为不限流
+//	  burst: 20                # 桶容量（允许的突发请求数），默认等于 rate 向上取整
+//	  key_by: api_key          # api_key：带 API Key 时按 key 名分桶，否则按客户端 IP；ip：始终按客户端 IP
+//	  trusted_proxies: [10.0.0.0/8]   # 可信的反向代理（IP 或 CIDR），为空时客户端 IP 为连接的对端地址
+//	  routes:                  # 按路由覆盖，path 为完整的路由模板
+//	    - path: /api/rest/:database/:table/import
+//	      method: POST         # 为空时匹配所有方法
+//	      rate: 0.2
+//	      burst: 2
+//
+// 表配置中的 rate_limit（rate、burst）覆盖该表的接口，优先级：路由 > 表 > 全局，rate 为 -1 时不限流。
+// 不同的路由、表覆盖各自计数，未覆盖的请求共用全局的桶。REST、jobs、meta、admin 接口在认证之前扣减令牌，
+// 凭证无效的请求同样计数；令牌不足时返回 429 与 Retry-After；响应带 X-RateLimit-Limit、X-RateLimit-Remaining。
+// key_by 为 api_key 时只有请求头中的 key 有效才按 key 名分桶，无效的 key 与未带 key 的请求按客户端 IP 分桶。
+// 配置了 job_queue.store 时桶的状态写入同一个 KVStore，重启后限流继续生效，否则只保存在内存。
+// 只有对端是 trusted_proxies 中的代理时才读取 X-Forwarded-For，从右往左取第一个不可信的地址，
+// 客户端自行伪造的 X-Forwarded-For 不能换桶绕过限流。
+
+const (
+	rateLimitKeyPrefix = "rate_limit:"
+
+	rateLimitByAPIKey = "api_key"
+	rateLimitByIP     = "ip"
+
+	// 内存中的桶超过该数量时清理已补满的桶
+	rateLimitSweepSize = 10000
+)
+
+type rateLimitConfig struct {
+	Rate   float64            `mapstructure:"rate"`
+	Burst  int                `mapstructure:"burst"`
+	KeyBy  string             `mapstructure:"key_by"`
+	Routes []routeLimitConfig `mapstructure:"routes"`
+
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+type routeLimitConfig struct {
+	Path   string  `mapstructure:"path"`
+	Method string  `mapstructure:"method"`
+	Rate   float64 `mapstructure:"rate"`
+	Burst  int     `mapstructure:"burst"`
+}
+
+// tableRateLimitConfig 表配置中的 rate_limit
+type tableRateLimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// rateLimit 生效的限流参数，scope 区分各自计数的桶
+type rateLimit struct {
+	scope string
+	rate  float64
+	burst int
+}
+
+func newRateLimit(scope string, rate float64, burst int) rateLimit {
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return rateLimit{scope: scope, rate: rate, burst: burst}
+}
+
+// tokenBucket 桶的状态，持久化为 JSON
+type tokenBucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+
+	full time.Time // 补满的时间，内存中的桶据此清理
+}
+
+// take 按经过的时间补充令牌后尝试扣减一个，返回是否放行
+func (b *tokenBucket) take(l rateLimit, now time.Time) bool {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(l.burst)
+	} else if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(l.burst), b.Tokens+elapsed*l.rate)
+	}
+	b.Updated = now
+	if b.Tokens < 1 {
+		return false
+	}
+	b.Tokens--
+	return true
+}
+
+// retryAfter 补充到一个令牌需要等待的时间
+func (b *tokenBucket) retryAfter(l rateLimit) time.Duration {
+	return time.Duration((1 - b.Tokens) / l.rate * float64(time.Second))
+}
+
+// refillTime 从当前状态补满需要的时间，用作持久化的 TTL
+func (b *tokenBucket) refillTime(l rateLimit) time.Duration {
+	return time.Duration((float64(l.burst)-b.Tokens)/l.rate*float64(time.Second)) + time.Second
+}
+
+type rateLimiter struct {
+	config  rateLimitConfig
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket // 未配置 KVStore 时使用
+	proxies []*net.IPNet
+}
+
+func newRateLimiter(cfg rateLimitConfig) (*rateLimiter, error) {
+	switch cfg.KeyBy {
+	case "":
+		cfg.KeyBy = rateLimitByAPIKey
+	case rateLimitByAPIKey, rateLimitByIP:
+	default:
+		return nil, fmt.Errorf("invalid rate_limit.key_by %q, expected api_key or ip", cfg.KeyBy)
+	}
+	if cfg.Rate < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("rate_limit: rate and burst must not be negative")
+	}
+	for i, r := range cfg.Routes {
+		if r.Path == "" {
+			return nil, fmt.Errorf("rate_limit.routes[%d]: path is required", i)
+		}
+		if r.Burst < 0 {
+			return nil, fmt.Errorf("rate_limit.routes[%d]: burst must not be negative", i)
+		}
+		cfg.Routes[i].Method = strings.ToUpper(r.Method)
+	}
+	rl := &rateLimiter{config: cfg, buckets: map[string]*tokenBucket{}}
+	for _, p := range cfg.TrustedProxies {
+		cidr := p
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit.trusted_proxies entry %q", p)
+		}
+		rl.proxies = append(rl.proxies, ipnet)
+	}
+	return rl, nil
+}
+
+// validateRateLimits 校验各表的 rate_limit
+func (dm *databaseManager) validateRateLimits() error {
+	for dbName, db := range dm.config.Databases {
+		for _, tc := range db.Tables {
+			if tc.RateLimit.Burst < 0 {
+				return fmt.Errorf("%s.%s: rate_limit.burst must not be negative", dbName, tc.Alias)
+			}
+		}
+	}
+	return nil
+}
+
+// limitFor 返回请求适用的限流参数，ok 为 false 表示不限流
+func (dm *databaseManager) limitFor(c *gin.Context) (rateLimit, bool) {
+	cfg := dm.rateLimiter.config
+	method, route := c.Request.Method, c.FullPath()
+	for _, r := range cfg.Routes {
+		if r.Path == route && (r.Method == "" || r.Method == method) {
+			return newRateLimit("route:"+r.Method+" "+r.Path, r.Rate, r.Burst), r.Rate > 0
+		}
+	}
+	if dbName, table := c.Param("database"), c.Param("table"); table != "" {
+		if _, tc, err := dm.getAdapterAndTableConfig(dbName, table); err == nil && tc.RateLimit.Rate != 0 {
+			return newRateLimit("table:"+dbName+"."+table, tc.RateLimit.Rate, tc.RateLimit.Burst), tc.RateLimit.Rate > 0
+		}
+	}
+	return newRateLimit("global", cfg.Rate, cfg.Burst), cfg.Rate > 0
+}
+
+// rateLimitClient 分桶的调用者标识；在认证之前执行，直接按请求头查找 API Key
+func (dm *databaseManager) rateLimitClient(c *gin.Context) string {
+	if dm.rateLimiter.config.KeyBy == rateLimitByAPIKey && dm.apiKeys != nil {
+		if raw := strings.TrimSpace(c.GetHeader(dm.apiKeys.header)); raw != "" {
+			if key := dm.apiKeys.lookup(raw); key != nil {
+				return "key:" + key.Name
+			}
+		}
+	}
+	return "ip:" + dm.rateLimiter.clientIP(c)
+}
+
+// clientIP 对端不是可信代理时即为客户端；否则从右往左取 X-Forwarded-For 中第一个不可信的地址
+func (rl *rateLimiter) clientIP(c *gin.Context) string {
+	ip := c.RemoteIP()
+	if !rl.trustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !rl.trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (rl *rateLimiter) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range rl.proxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// takeToken 扣减一个令牌，返回是否放行与扣减后的桶状态
+func (dm *databaseManager) takeToken(key string, l rateLimit) (bool, tokenBucket) {
+	now := time.Now()
+	if dm.store == nil {
+		rl := dm.rateLimiter
+		rl.mutex.Lock()
+		defer rl.mutex.Unlock()
+		if len(rl.buckets) > rateLimitSweepSize {
+			for k, b := range rl.buckets {
+				if now.After(b.full) {
+					delete(rl.buckets, k)
+				}
+			}
+		}
+		b, ok := rl.buckets[key]
+		if !ok {
+			b = &tokenBucket{}
+			rl.buckets[key] = b
+		}
+		allowed := b.take(l, now)
+		b.full = now.Add(b.refillTime(l))
+		return allowed, *b
+	}
+	var b tokenBucket
+	allowed := true
+	err := dm.store.Update([]byte(rateLimitKeyPrefix+key), func(old []byte, found bool) ([]byte, time.Duration, error) {
+		b = tokenBucket{}
+		if found {
+			_ = json.Unmarshal(old, &b)
+		}
+		allowed = b.take(l, now)
+		data, err := json.Marshal(b)
+		return data, b.refillTime(l), err
+	})
+	if err != nil {
+		// 存储异常时放行，避免限流拖垮整个服务
+		log.Printf("rate limit %s failed: %v", key, err)
+		return true, tokenBucket{Tokens: float64(l.burst)}
+	}
+	return allowed, b
+}
+
+// withRateLimit 按调用者扣减令牌，需在认证中间件之前，避免无效凭证的请求绕过限流
+func (dm *databaseManager) withRateLimit(c *gin.Context) {
+	if dm.rateLimiter == nil {
+		c.Next()
+		return
+	}
+	l, ok := dm.limitFor(c)
+	if !ok {
+		c.Next()
+		return
+	}
+	allowed, b := dm.takeToken(l.scope+":"+dm.rateLimitClient(c), l)
+	c.Header("X-RateLimit-Limit", strconv.Itoa(l.burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(int(b.Tokens)))
+	if !allowed {
+		wait := int(math.Ceil(b.retryAfter(l).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(wait))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("Too many requests, retry after %d seconds", wait)})
+		return
+	}
+	c.Next()
+}
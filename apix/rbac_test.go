@@ -0,0 +1,60 @@
+package apix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRBACPolicyCheck(t *testing.T) {
+	policy, err := newRBACPolicy(map[string][]roleGrant{
+		"Reader": {{Databases: []string{"test"}, Methods: []string{"get"}}},
+		"editor": {{Tables: []string{"user", "shop.orders"}, Methods: []string{"GET", "POST", "PUT", "DELETE"}}},
+		"admin":  {{}},
+	})
+	assert.NoError(t, err)
+	cases := []struct {
+		name          string
+		roles         []string
+		method, db, t string
+		ok            bool
+	}{
+		{"reader get", []string{"reader"}, "GET", "test", "user", true},
+		{"reader role case", []string{"READER"}, "GET", "test", "user", true},
+		{"reader post", []string{"reader"}, "POST", "test", "user", false},
+		{"reader other database", []string{"reader"}, "GET", "shop", "orders", false},
+		{"editor table", []string{"editor"}, "DELETE", "test", "user", true},
+		{"editor qualified table", []string{"editor"}, "PUT", "shop", "orders", true},
+		{"editor qualified other database", []string{"editor"}, "PUT", "test", "orders", false},
+		{"editor method", []string{"editor"}, "PATCH", "test", "user", false},
+		{"any role grants", []string{"unknown", "reader"}, "GET", "test", "orders", true},
+		{"admin", []string{"admin"}, "DELETE", "shop", "orders", true},
+		{"admin route without table", []string{"admin"}, "POST", "", "", true},
+		{"reader route without database", []string{"reader"}, "GET", "", "", false},
+		{"editor route without database", []string{"editor"}, "GET", "", "", false},
+		{"undeclared role", []string{"guest"}, "GET", "test", "user", false},
+		{"no roles", nil, "GET", "test", "user", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.check(&principal{ID: "u1", Roles: tc.roles}, tc.method, tc.db, tc.t)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	assert.Error(t, policy.check(nil, "GET", "test", "user"))
+	var unset *rbacPolicy
+	assert.NoError(t, unset.check(nil, "DELETE", "test", "user"))
+}
+
+func TestNewRBACPolicy(t *testing.T) {
+	p, err := newRBACPolicy(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	_, err = newRBACPolicy(map[string][]roleGrant{"reader": {{Methods: []string{"FETCH"}}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,619 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 请求录制与回放 ---------
+//
+// 排查“返回的行不对”一类问题时，录制指定表的 REST 请求与响应，之后按当前配置回放并比较结果：
+//
+//	recording:
+//	  enabled: true
+//	  tables: [user, test.orders]    # 表别名或 库别名.表别名，为空时全部
+//	  methods: [GET]                 # 只录制这些方法，为空时全部
+//	  ttl: 24h                       # 录制的保留时长
+//	  max_body_bytes: 65536          # 请求体、响应体超过该大小时不记录
+//	  redact_fields: [password]      # 请求体、响应体与查询参数中替换为 *** 的字段
+//	  redact_headers: [X-Token]      # 额外不记录取值的请求头
+//
+// 录制写入 job_queue.store 的 KVStore（需要配置 store），到期自动删除。记录前脱敏：Authorization、Cookie、
+// API Key 请求头与 redact_headers 的取值记为 ***；JSON 请求体、响应体与查询参数中 redact_fields 与表的 hidden_fields
+// 字段替换为 ***，masked_fields 字段按脱敏方式处理；非 JSON 的请求体、响应体（如 CSV 导入）不记录。
+// 按敏感字段过滤的查询参数同样替换，并列在 redacted_params 中，回放结果会因此不同。
+//
+// 管理接口（受 operation_roles.recordings 控制，默认 admin）：
+//
+//	GET  /api/admin/recordings?database=test&table=user&method=GET&status=200&actor=alice&request_id=...&since=...&until=...
+//	GET  /api/admin/recordings/:id
+//	POST /api/admin/recordings/:id/replay
+//
+// 列表按时间倒序返回 {"total", "data"}，不含请求体与响应体。回放使用回放调用者自己的凭据（Authorization、Cookie、
+// API Key 请求头），其余请求头按录制时的值，经完整的中间件与处理函数执行；写接口以 dry_run=true 回放，
+// 不会再次写入（bulk_jobs 不支持试运行，不能回放）。响应 {"recording", "dry_run", "replay", "match", "diff"}，
+// diff 列出状态码与响应体的差异（如 "body.data.3.name: recorded a, replay b"），回放请求本身不会被录制。
+
+const (
+	recordingKeyPrefix = "recording:"
+
+	defaultRecordingTTL          = 24 * time.Hour
+	defaultRecordingMaxBodyBytes = 64 << 10
+
+	recordingRedacted = "***"
+
+	// 未记录请求体、响应体的原因
+	recordingOmitTooLarge = "too_large"
+	recordingOmitNotJSON  = "not_json"
+
+	maxRecordingDiffs = 20
+)
+
+// 总是不记录取值的请求头
+var recordingSecretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type recordingConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Tables        []string      `mapstructure:"tables"`
+	Methods       []string      `mapstructure:"methods"`
+	TTL           time.Duration `mapstructure:"ttl"`
+	MaxBodyBytes  int           `mapstructure:"max_body_bytes"`
+	RedactFields  []string      `mapstructure:"redact_fields"`
+	RedactHeaders []string      `mapstructure:"redact_headers"`
+}
+
+// recordedMessage 录制的请求或响应
+type recordedMessage struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	Omitted string            `json:"omitted,omitempty"` // 未记录 body 的原因：too_large、not_json
+}
+
+type recording struct {
+	ID         string          `json:"id"`
+	Time       time.Time       `json:"time"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Actor      string          `json:"actor,omitempty"`
+	ClientIP   string          `json:"client_ip"`
+	Database   string          `json:"database"`
+	Table      string          `json:"table"`
+	Method     string          `json:"method"`
+	Route      string          `json:"route"`
+	Path       string          `json:"path"`
+	Query      string          `json:"query,omitempty"`
+	Redacted   []string        `json:"redacted_params,omitempty"` // 取值已替换的查询参数，回放时按 *** 过滤
+	DurationMs float64         `json:"duration_ms"`
+	Request    recordedMessage `json:"request"`
+	Response   recordedMessage `json:"response"`
+}
+
+// summary 去掉请求体与响应体，用于列表
+func (r recording) summary() recording {
+	r.Request.Body, r.Response.Body = nil, nil
+	return r
+}
+
+type requestRecorder struct {
+	config        recordingConfig
+	store         *utils.KVStore
+	tables        map[string]*tableConfig // 库别名.表别名 → 表配置
+	redactHeaders map[string]bool         // 规范化的请求头名
+}
+
+// recordingReplayKey 回放请求的 context 标记，带该标记的请求不录制
+type recordingReplayKey struct{}
+
+// 不支持 dry_run 的写接口，不能回放
+var recordingNoReplayRoutes = []string{"/:database/:table/bulk_jobs"}
+
+func (dm *databaseManager) setupRecording() error {
+	cfg := dm.config.Recording
+	if !cfg.Enabled {
+		return nil
+	}
+	if dm.store == nil {
+		return fmt.Errorf("recording requires job_queue.store")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultRecordingTTL
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultRecordingMaxBodyBytes
+	}
+	for i, m := range cfg.Methods {
+		cfg.Methods[i] = strings.ToUpper(m)
+	}
+	r := &requestRecorder{config: cfg, store: dm.store, tables: map[string]*tableConfig{}, redactHeaders: map[string]bool{}}
+	headers := append(append([]string{}, recordingSecretHeaders...), cfg.RedactHeaders...)
+	if dm.apiKeys != nil {
+		headers = append(headers, dm.apiKeys.header)
+	} else {
+		headers = append(headers, defaultAPIKeyHeader)
+	}
+	for _, h := range headers {
+		r.redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	for name, dbCfg := range dm.config.Databases {
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if len(cfg.Tables) > 0 && !contains(cfg.Tables, tc.Alias) && !contains(cfg.Tables, name+"."+tc.Alias) {
+				continue
+			}
+			r.tables[name+"."+tc.Alias] = tc
+		}
+	}
+	dm.recorder = r
+	return nil
+}
+
+// ---- 脱敏 ----
+
+// redactedFields 返回需替换为 *** 的字段与需脱敏的字段（物理列名与 API 名）
+func (r *requestRecorder) redactedFields(tc *tableConfig) (map[string]bool, map[string]string) {
+	redact := map[string]bool{}
+	for _, f := range r.config.RedactFields {
+		redact[f] = true
+	}
+	masked := map[string]string{}
+	if tc != nil {
+		for _, f := range tc.HiddenFields {
+			redact[f], redact[tc.apiFieldName(f)] = true, true
+		}
+		for f, kind := range tc.MaskedFields {
+			masked[f], masked[tc.apiFieldName(f)] = kind, kind
+		}
+	}
+	return redact, masked
+}
+
+// sanitizeJSON 递归替换 JSON 值中的敏感字段
+func sanitizeJSON(v interface{}, redact map[string]bool, masked map[string]string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if redact[k] {
+				val[k] = recordingRedacted
+			} else if kind, ok := masked[k]; ok {
+				val[k] = maskValue(kind, item)
+			} else {
+				val[k] = sanitizeJSON(item, redact, masked)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = sanitizeJSON(item, redact, masked)
+		}
+	}
+	return v
+}
+
+// sanitizeQuery 替换查询参数中敏感字段的过滤值（filter[name]、name__op 按 name 判断），返回替换的参数名
+func (r *requestRecorder) sanitizeQuery(tc *tableConfig, raw string) (string, []string) {
+	values, err := url.ParseQuery(raw)
+	if err != nil || len(values) == 0 {
+		return raw, nil
+	}
+	redact, masked := r.redactedFields(tc)
+	var params []string
+	for key, vs := range values {
+		field := strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]")
+		field, _, _ = strings.Cut(field, "__")
+		if _, ok := masked[field]; !redact[field] && !ok {
+			continue
+		}
+		for i := range vs {
+			vs[i] = recordingRedacted
+		}
+		params = append(params, key)
+	}
+	if len(params) == 0 {
+		return raw, nil
+	}
+	sort.Strings(params)
+	return values.Encode(), params
+}
+
+// body 解析并脱敏请求体或响应体，返回记录的值与未记录的原因
+func (r *requestRecorder) body(tc *tableConfig, contentType string, data []byte, overflow bool) (interface{}, string) {
+	if overflow {
+		return nil, recordingOmitTooLarge
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ""
+	}
+	if !isJSONContentType(contentType) {
+		return nil, recordingOmitNotJSON
+	}
+	v, err := decodeContractJSON(data)
+	if err != nil {
+		return nil, recordingOmitNotJSON
+	}
+	redact, masked := r.redactedFields(tc)
+	return sanitizeJSON(v, redact, masked), ""
+}
+
+// headers 记录请求头（每个取第一个值），敏感请求头记为 ***
+func (r *requestRecorder) headers(h http.Header) map[string]string {
+	result := make(map[string]string, len(h))
+	for k, vs := range h {
+		if len(vs) == 0 || k == "Content-Length" || k == "Set-Cookie" {
+			continue
+		}
+		if r.redactHeaders[k] {
+			result[k] = recordingRedacted
+		} else {
+			result[k] = vs[0]
+		}
+	}
+	return result
+}
+
+// ---- 录制 ----
+
+// withRecording 录制配置的表的请求与响应，需在其它 REST 中间件之前，以便记录被拒绝的请求
+func (dm *databaseManager) withRecording(c *gin.Context) {
+	r := dm.recorder
+	if r == nil || c.Request.Context().Value(recordingReplayKey{}) != nil {
+		c.Next()
+		return
+	}
+	dbName, table := c.Param("database"), c.Param("table")
+	tc := r.tables[dbName+"."+table]
+	if tc == nil || (len(r.config.Methods) > 0 && !contains(r.config.Methods, c.Request.Method)) {
+		c.Next()
+		return
+	}
+	start := time.Now()
+	rec := recording{
+		ID: globalSnowflakeNode.Generate().String(), Time: start, ClientIP: c.ClientIP(),
+		Database: dbName, Table: table, Method: c.Request.Method,
+		Route: strings.TrimPrefix(c.FullPath(), dm.restPrefix), Path: c.Request.URL.Path,
+	}
+	rec.Query, rec.Redacted = r.sanitizeQuery(tc, c.Request.URL.RawQuery)
+	rec.Request.Headers = r.headers(c.Request.Header)
+	if c.Request.Body != nil {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(r.config.MaxBodyBytes)+1))
+		if err == nil {
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), c.Request.Body))
+			rec.Request.Body, rec.Request.Omitted = r.body(tc, c.ContentType(), data, len(data) > r.config.MaxBodyBytes)
+		}
+	}
+	writer := &bodyRecorder{ResponseWriter: c.Writer, limit: r.config.MaxBodyBytes}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	rec.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	rec.Actor = dm.currentActor(c)
+	if dm.audit != nil {
+		rec.RequestID = c.Writer.Header().Get(dm.audit.config.RequestIDHeader)
+	}
+	rec.Response.Status = c.Writer.Status()
+	rec.Response.Headers = r.headers(c.Writer.Header())
+	rec.Response.Body, rec.Response.Omitted = r.body(tc, c.Writer.Header().Get("Content-Type"), writer.body.Bytes(), writer.overflow)
+	r.save(rec)
+}
+
+func (r *requestRecorder) save(rec recording) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("encode recording failed: %v", err)
+		return
+	}
+	if err := r.store.Set([]byte(recordingKeyPrefix+rec.ID), data, r.config.TTL); err != nil {
+		log.Printf("write recording failed: %v", err)
+	}
+}
+
+// get 读取一条录制，不存在时返回 nil
+func (r *requestRecorder) get(id string) (*recording, error) {
+	key := []byte(recordingKeyPrefix + id)
+	if ok, err := r.store.Has(key); err != nil || !ok {
+		return nil, err
+	}
+	data, err := r.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ---- 查询 ----
+
+type recordingQuery struct {
+	Database  string
+	Table     string
+	Method    string
+	Status    int
+	Actor     string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (q recordingQuery) match(r recording) bool {
+	return (q.Database == "" || r.Database == q.Database) &&
+		(q.Table == "" || r.Table == q.Table) &&
+		(q.Method == "" || r.Method == q.Method) &&
+		(q.Status == 0 || r.Response.Status == q.Status) &&
+		(q.Actor == "" || r.Actor == q.Actor) &&
+		(q.RequestID == "" || r.RequestID == q.RequestID) &&
+		(q.Since.IsZero() || !r.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || r.Time.Before(q.Until))
+}
+
+func (dm *databaseManager) handleRecordingList(c *gin.Context) {
+	if !dm.authorize(c, nil, opRecordings) {
+		return
+	}
+	if dm.recorder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording is not enabled"})
+		return
+	}
+	q := recordingQuery{
+		Database: c.Query("database"), Table: c.Query("table"), Method: strings.ToUpper(c.Query("method")),
+		Actor: c.Query("actor"), RequestID: c.Query("request_id"),
+	}
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status: " + v})
+			return
+		}
+		q.Status = status
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(name); v != "" {
+			parsed, ok := asTime(v, true)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %s, expected RFC3339 time", name, v)})
+				return
+			}
+			*t = parsed
+		}
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery(queryParamPage, strconv.Itoa(dm.config.DefaultPage)))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery(queryParamPageSize, strconv.Itoa(dm.config.DefaultPageSize)))
+	if page <= 0 {
+		page = dm.config.DefaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = dm.config.DefaultPageSize
+	}
+	if pageSize > dm.config.MaxPageSize {
+		pageSize = dm.config.MaxPageSize
+	}
+	var all []recording
+	err := dm.recorder.store.Scan([]byte(recordingKeyPrefix), func(_, v []byte) error {
+		var rec recording
+		if json.Unmarshal(v, &rec) == nil && q.match(rec) {
+			all = append(all, rec.summary())
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read recordings: " + err.Error()})
+		return
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.After(all[j].Time) })
+	data := []recording{}
+	if start := (page - 1) * pageSize; start < len(all) {
+		data = all[start:min(start+pageSize, len(all))]
+	}
+	c.JSON(http.StatusOK, gin.H{"total": len(all), "data": data})
+}
+
+// recordingFor 读取路径参数 id 对应的录制，失败时写响应并返回 nil
+func (dm *databaseManager) recordingFor(c *gin.Context) *recording {
+	if !dm.authorize(c, nil, opRecordings) {
+		return nil
+	}
+	if dm.recorder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording is not enabled"})
+		return nil
+	}
+	rec, err := dm.recorder.get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read recording: " + err.Error()})
+		return nil
+	}
+	if rec == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording not found or expired"})
+		return nil
+	}
+	return rec
+}
+
+func (dm *databaseManager) handleRecordingGet(c *gin.Context) {
+	if rec := dm.recordingFor(c); rec != nil {
+		c.JSON(http.StatusOK, rec)
+	}
+}
+
+// ---- 回放 ----
+
+type recordingReplay struct {
+	recordedMessage
+	DurationMs float64 `json:"duration_ms"`
+}
+
+func (dm *databaseManager) handleRecordingReplay(c *gin.Context) {
+	rec := dm.recordingFor(c)
+	if rec == nil {
+		return
+	}
+	if rec.Request.Omitted != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Request body was not recorded (%s), cannot replay", rec.Request.Omitted)})
+		return
+	}
+	query, err := url.ParseQuery(rec.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recorded query: " + err.Error()})
+		return
+	}
+	dryRun := rec.Method != http.MethodGet && !strings.HasSuffix(rec.Route, "/batch_get")
+	if dryRun {
+		if contains(recordingNoReplayRoutes, rec.Route) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s %s does not support dry_run, cannot replay", rec.Method, rec.Route)})
+			return
+		}
+		query.Set(queryParamDryRun, "true")
+	}
+	var body io.Reader
+	if rec.Request.Body != nil {
+		data, err := json.Marshal(rec.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		body = bytes.NewReader(data)
+	}
+	target := rec.Path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	r := dm.recorder
+	ctx := context.WithValue(c.Request.Context(), recordingReplayKey{}, rec.ID)
+	req := httptest.NewRequest(rec.Method, target, body).WithContext(ctx)
+	for k, v := range rec.Request.Headers {
+		if !r.redactHeaders[k] {
+			req.Header.Set(k, v)
+		}
+	}
+	// 凭据取自回放调用者，请求 ID 重新生成
+	for k := range r.redactHeaders {
+		if v := c.GetHeader(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	if dm.audit != nil {
+		req.Header.Del(dm.audit.config.RequestIDHeader)
+	}
+	req.RemoteAddr = c.Request.RemoteAddr
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	dm.router.ServeHTTP(w, req)
+	replay := recordingReplay{DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	replay.Status = w.Code
+	replay.Headers = r.headers(w.Header())
+	_, tc, _ := dm.getAdapterAndTableConfig(rec.Database, rec.Table)
+	replay.Body, replay.Omitted = r.body(tc, w.Header().Get("Content-Type"), w.Body.Bytes(), w.Body.Len() > r.config.MaxBodyBytes)
+
+	diff := []string{}
+	if rec.Response.Status != replay.Status {
+		diff = append(diff, fmt.Sprintf("status: recorded %d, replay %d", rec.Response.Status, replay.Status))
+	}
+	if rec.Response.Omitted != "" || replay.Omitted != "" {
+		if rec.Response.Omitted != replay.Omitted {
+			diff = append(diff, fmt.Sprintf("body: recorded %s, replay %s", omittedReason(rec.Response.Omitted), omittedReason(replay.Omitted)))
+		}
+	} else {
+		diff = diffRecordedJSON(diff, "body", rec.Response.Body, replay.Body)
+	}
+	c.JSON(http.StatusOK, gin.H{"recording": rec.summary(), "dry_run": dryRun, "replay": replay, "match": len(diff) == 0, "diff": diff})
+}
+
+func omittedReason(omitted string) string {
+	if omitted == "" {
+		return "recorded"
+	}
+	return "omitted (" + omitted + ")"
+}
+
+// diffRecordedJSON 逐层比较录制与回放的 JSON 值，追加差异描述，最多 maxRecordingDiffs 条
+func diffRecordedJSON(diff []string, path string, recorded, replayed interface{}) []string {
+	if len(diff) >= maxRecordingDiffs {
+		return diff
+	}
+	switch a := recorded.(type) {
+	case map[string]interface{}:
+		b, ok := replayed.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, inA := a[k]
+			bv, inB := b[k]
+			switch {
+			case !inB:
+				diff = append(diff, fmt.Sprintf("%s.%s: missing in replay", path, k))
+			case !inA:
+				diff = append(diff, fmt.Sprintf("%s.%s: only in replay", path, k))
+			default:
+				diff = diffRecordedJSON(diff, path+"."+k, av, bv)
+			}
+			if len(diff) >= maxRecordingDiffs {
+				return diff
+			}
+		}
+		return diff
+	case []interface{}:
+		b, ok := replayed.([]interface{})
+		if !ok {
+			break
+		}
+		if len(a) != len(b) {
+			diff = append(diff, fmt.Sprintf("%s: length recorded %d, replay %d", path, len(a), len(b)))
+		}
+		for i := 0; i < min(len(a), len(b)); i++ {
+			diff = diffRecordedJSON(diff, path+"."+strconv.Itoa(i), a[i], b[i])
+		}
+		return diff
+	default:
+		if _, ok := replayed.(map[string]interface{}); ok {
+			break
+		}
+		if _, ok := replayed.([]interface{}); ok {
+			break
+		}
+		if !mirrorValueEqual(recorded, replayed) {
+			diff = append(diff, fmt.Sprintf("%s: recorded %v, replay %v", path, recorded, replayed))
+		}
+		return diff
+	}
+	return append(diff, fmt.Sprintf("%s: recorded %s, replay %s", path, jsonKind(recorded), jsonKind(replayed)))
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	default:
+		return "value"
+	}
+}
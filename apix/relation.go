@@ -0,0 +1,345 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// --------- 关联展开 ---------
+//
+// 在表配置中声明同库表之间的外键关联，列表与单条查询通过 expand 参数把关联记录嵌入结果：
+//
+//	relations:
+//	  author:                   # GET /api/rest/blog/post?expand=author,comments
+//	    type: belongs_to        # 本表 foreign_key 指向目标表 references（默认目标表主键），结果为对象或 null
+//	    table: user             # 同库目标表名或别名
+//	    foreign_key: author_id
+//	    fields: [id, username]  # 目标表返回的列，默认全部
+//	  comments:
+//	    type: has_many          # 目标表 foreign_key 指向本表 references（默认本表主键），结果为数组
+//	    table: comment
+//	    foreign_key: post_id
+//	    order: -created_time    # 关联记录的排序，默认目标表主键升序
+//	  detail:
+//	    type: has_one           # 同 has_many，结果为第一条记录或 null
+//	    table: post_detail
+//	    foreign_key: post_id
+//
+// 列名均为物理列名。每个关联按 500 个键一批查询：SQL 库用 IN 查询，MongoDB 在本表集合上用 $lookup；
+// 目标表的软删除、查询范围（gorm_scopes）照常生效，记录按目标表的字段策略与字段别名输出。
+// has_one、has_many 的关联记录最多 max_page_size 条：SQL 库按每批父记录合计，MongoDB 按每条父记录。
+// 调用者需要有目标表的 list 权限；其他类型的库返回 501。跨库关联见 cross_lookups（crosslookup.go）。
+
+const (
+	queryParamExpand  = "expand"
+	relationBatchSize = 500
+
+	relationBelongsTo = "belongs_to"
+	relationHasOne    = "has_one"
+	relationHasMany   = "has_many"
+)
+
+type relationConfig struct {
+	Type       string   `mapstructure:"type"`
+	Table      string   `mapstructure:"table"`
+	ForeignKey string   `mapstructure:"foreign_key"`
+	References string   `mapstructure:"references"`
+	Fields     []string `mapstructure:"fields"`
+	Order      string   `mapstructure:"order"`
+
+	local  string // 本表匹配列
+	remote string // 目标表匹配列
+}
+
+func (r relationConfig) many() bool {
+	return r.Type == relationHasMany
+}
+
+// relationExpander 为可选能力：按本表的键批量查询关联记录，返回 键 → 目标记录
+type relationExpander interface {
+	ExpandRelation(ctx context.Context, tc, target *tableConfig, rel relationConfig, keys []interface{}, limit int) (map[string][]map[string]interface{}, error)
+}
+
+// checkRelations 确认关联的目标表存在，并确定两侧的匹配列
+func checkRelations(dbAlias string, tables []tableConfig) error {
+	for i := range tables {
+		tc := &tables[i]
+		for name, r := range tc.Relations {
+			if r.Type == "" {
+				r.Type = relationBelongsTo
+			}
+			if r.Type != relationBelongsTo && r.Type != relationHasOne && r.Type != relationHasMany {
+				return fmt.Errorf("table %s in db %s: relation '%s' has invalid type '%s', expected belongs_to, has_one or has_many", tc.Name, dbAlias, name, r.Type)
+			}
+			if r.ForeignKey == "" {
+				return fmt.Errorf("table %s in db %s: relation '%s' requires foreign_key", tc.Name, dbAlias, name)
+			}
+			target := findTableConfig(tables, r.Table)
+			if target == nil {
+				return fmt.Errorf("table %s in db %s: relation '%s' references unknown table '%s'", tc.Name, dbAlias, name, r.Table)
+			}
+			if r.Type == relationBelongsTo {
+				r.local, r.remote = r.ForeignKey, r.References
+				if r.remote == "" {
+					r.remote = target.PrimaryKey
+				}
+			} else {
+				r.local, r.remote = r.References, r.ForeignKey
+				if r.local == "" {
+					r.local = tc.PrimaryKey
+				}
+			}
+			if r.local == "" || r.remote == "" {
+				return fmt.Errorf("table %s in db %s: relation '%s' needs a primary key on both tables, specify references", tc.Name, dbAlias, name)
+			}
+			tc.Relations[name] = r
+		}
+	}
+	return nil
+}
+
+// parseExpand 解析 expand 参数，未声明的关联返回错误
+func parseExpand(c *gin.Context, tc *tableConfig) ([]string, error) {
+	raw := c.Query(queryParamExpand)
+	if raw == "" {
+		return nil, nil
+	}
+	names := parseStringList(raw)
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if _, ok := tc.Relations[names[i]]; !ok {
+			return nil, fmt.Errorf("unknown relation '%s'", names[i])
+		}
+	}
+	return names, nil
+}
+
+// withRelationColumns 指定了 fields 时补上关联需要的本表列，返回补充的列（输出前删除）
+func withRelationColumns(fields string, tc *tableConfig, names []string) (string, []string) {
+	if fields == "" {
+		return fields, nil
+	}
+	selected := parseStringList(fields)
+	var added []string
+	for _, name := range names {
+		col := tc.Relations[name].local
+		if !contains(selected, col) && !contains(added, col) {
+			added = append(added, col)
+		}
+	}
+	if len(added) > 0 {
+		fields += "," + strings.Join(added, ",")
+	}
+	return fields, added
+}
+
+// applyRelations 按 expand 参数嵌入关联记录；出错时已写响应并返回 false
+func (dm *databaseManager) applyRelations(c *gin.Context, dbName string, adapter databaseAdapter, tc *tableConfig, names []string, records []map[string]interface{}) bool {
+	if len(names) == 0 {
+		return true
+	}
+	expander, ok := adapter.(relationExpander)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Relation expansion is not supported by this database type"})
+		return false
+	}
+	dm.mutex.RLock()
+	tables := dm.config.Databases[dbName].Tables
+	dm.mutex.RUnlock()
+	for _, name := range names {
+		rel := tc.Relations[name]
+		target := findTableConfig(tables, rel.Table)
+		if target == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("expand %s: table %s not configured", name, rel.Table)})
+			return false
+		}
+		if !dm.authorize(c, target, opList) {
+			return false
+		}
+		seen := map[string]struct{}{}
+		var keys []interface{}
+		for _, record := range records {
+			v := record[rel.local]
+			if v == nil {
+				continue
+			}
+			if _, ok := seen[fmt.Sprint(v)]; ok {
+				continue
+			}
+			seen[fmt.Sprint(v)] = struct{}{}
+			keys = append(keys, v)
+		}
+		matches := map[string][]map[string]interface{}{}
+		for start := 0; start < len(keys); start += relationBatchSize {
+			batch := keys[start:min(start+relationBatchSize, len(keys))]
+			limit := len(batch)
+			if rel.Type != relationBelongsTo {
+				limit = dm.config.MaxPageSize
+			}
+			found, err := expander.ExpandRelation(c.Request.Context(), tc, target, rel, batch, limit)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("expand %s: %v", name, err)})
+				return false
+			}
+			for k, rows := range found {
+				for _, row := range rows {
+					if len(rel.Fields) > 0 && !contains(rel.Fields, rel.remote) {
+						delete(row, rel.remote)
+					}
+					row = fixPkFieldToString(row, target.PrimaryKey).(map[string]interface{})
+					matches[k] = append(matches[k], target.renderRecord(row))
+				}
+			}
+		}
+		for _, record := range records {
+			var found []map[string]interface{}
+			if v := record[rel.local]; v != nil {
+				found = matches[fmt.Sprint(v)]
+			}
+			switch {
+			case rel.many():
+				if found == nil {
+					found = []map[string]interface{}{}
+				}
+				record[name] = found
+			case len(found) > 0:
+				record[name] = found[0]
+			default:
+				record[name] = nil
+			}
+		}
+	}
+	return true
+}
+
+// relationSelect 返回查询目标表的列，指定 fields 时补上匹配列
+func relationSelect(rel relationConfig) []string {
+	if len(rel.Fields) == 0 {
+		return nil
+	}
+	fields := append([]string{}, rel.Fields...)
+	if !contains(fields, rel.remote) {
+		fields = append(fields, rel.remote)
+	}
+	return fields
+}
+
+func (a *gormAdapter) ExpandRelation(ctx context.Context, tc, target *tableConfig, rel relationConfig, keys []interface{}, limit int) (map[string][]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := a.read(ctx, func(db *gorm.DB) error {
+		db = a.applyListScopes(db.Table(target.Name), target)
+		db = applyGormSoftDeleteFilter(db, target)
+		db = db.Where(fmt.Sprintf("%s IN ?", rel.remote), keys)
+		if fields := relationSelect(rel); fields != nil {
+			db = db.Select(fields)
+		}
+		if order := relationOrder(rel, target); order != "" {
+			if strings.HasPrefix(order, "-") {
+				db = db.Order(order[1:] + " DESC")
+			} else {
+				db = db.Order(order + " ASC")
+			}
+		}
+		return db.Limit(limit).Find(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groupRelationRows(rows, rel.remote), nil
+}
+
+func (a *shardedAdapter) ExpandRelation(ctx context.Context, tc, target *tableConfig, rel relationConfig, keys []interface{}, limit int) (map[string][]map[string]interface{}, error) {
+	results := make([]map[string][]map[string]interface{}, len(a.shards))
+	err := a.fanOut(func(i int, s *gormAdapter) error {
+		var err error
+		results[i], err = s.ExpandRelation(ctx, tc, target, rel, keys, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string][]map[string]interface{}{}
+	for _, r := range results {
+		for k, rows := range r {
+			merged[k] = append(merged[k], rows...)
+		}
+	}
+	return merged, nil
+}
+
+// ExpandRelation 在本表集合上按匹配列分组后 $lookup 目标集合
+func (a *mongoAdapter) ExpandRelation(ctx context.Context, tc, target *tableConfig, rel relationConfig, keys []interface{}, limit int) (map[string][]map[string]interface{}, error) {
+	lookup := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$expr": bson.M{"$eq": bson.A{"$" + rel.remote, "$$key"}}}}},
+	}
+	if filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, target); len(filter) > 0 {
+		lookup = append(lookup, bson.D{{Key: "$match", Value: filter}})
+	}
+	if order := relationOrder(rel, target); order != "" {
+		dir := 1
+		if strings.HasPrefix(order, "-") {
+			order, dir = order[1:], -1
+		}
+		lookup = append(lookup, bson.D{{Key: "$sort", Value: bson.D{{Key: order, Value: dir}}}})
+	}
+	if fields := relationSelect(rel); fields != nil {
+		projection := bson.M{}
+		for _, f := range fields {
+			projection[f] = 1
+		}
+		lookup = append(lookup, bson.D{{Key: "$project", Value: projection}})
+	}
+	lookup = append(lookup, bson.D{{Key: "$limit", Value: limit}})
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{rel.local: bson.M{"$in": keys}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + rel.local}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":     target.Name,
+			"let":      bson.M{"key": "$_id"},
+			"pipeline": lookup,
+			"as":       "related",
+		}}},
+	}
+	cur, err := a.collection(ctx, tc).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	matches := map[string][]map[string]interface{}{}
+	for cur.Next(ctx) {
+		var group struct {
+			Key     interface{}              `bson:"_id"`
+			Related []map[string]interface{} `bson:"related"`
+		}
+		if err := cur.Decode(&group); err != nil {
+			return nil, err
+		}
+		if len(group.Related) > 0 {
+			matches[fmt.Sprint(group.Key)] = group.Related
+		}
+	}
+	return matches, cur.Err()
+}
+
+func relationOrder(rel relationConfig, target *tableConfig) string {
+	if rel.Order != "" {
+		return rel.Order
+	}
+	return target.PrimaryKey
+}
+
+func groupRelationRows(rows []map[string]interface{}, key string) map[string][]map[string]interface{} {
+	matches := map[string][]map[string]interface{}{}
+	for _, row := range rows {
+		k := fmt.Sprint(row[key])
+		matches[k] = append(matches[k], row)
+	}
+	return matches
+}
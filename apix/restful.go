@@ -12,13 +12,17 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
+	"ego/utils"
+
 	"github.com/bwmarrin/snowflake"
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
@@ -29,7 +33,6 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
-	"gopkg.in/natefinch/lumberjack.v2"
 	"gorm.io/driver/clickhouse"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -55,15 +58,67 @@ const (
 	queryParamFields   = "fields"
 	queryParamOrder    = "order"
 	queryParamKey      = "key"
+
+	queryParamDryRun      = "dry_run"
+	queryParamMaxAffected = "max_affected"
 )
 
+// 保留参数不参与字段过滤
+var reservedQueryParams = map[string]struct{}{
+	queryParamPage:        {},
+	queryParamPageSize:    {},
+	queryParamFields:      {},
+	queryParamOrder:       {},
+	queryParamDryRun:      {},
+	queryParamMaxAffected: {},
+	queryParamReturn:      {},
+	queryParamOnError:     {},
+	queryParamResults:     {},
+	queryParamAsOf:        {},
+	queryParamLookup:      {},
+	queryParamExpand:      {},
+
+	queryParamIncludeDeleted: {},
+
+	queryParamStatementTimeout: {},
+	queryParamIsolation:        {},
+	queryParamReadPreference:   {},
+	queryParamReadConcern:      {},
+}
+
+func isReservedQueryParam(key string) bool {
+	_, ok := reservedQueryParams[key]
+	return ok
+}
+
 type dmConfig struct {
 	DefaultPage      int                       `mapstructure:"default_page"`
 	DefaultPageSize  int                       `mapstructure:"default_page_size"`
 	MaxPageSize      int                       `mapstructure:"max_page_size"`
 	SnowflakeNodeID  int64                     `mapstructure:"snowflake_node_id"`
 	TotalCntInterval int64                     `mapstructure:"total_cnt_interval"`
+	MaxAffectedRows  int                       `mapstructure:"max_affected_rows"`
+	StrictFilters    bool                      `mapstructure:"strict_filters"`
+	Auth             authConfig                `mapstructure:"auth"`
+	BulkJob          bulkJobConfig             `mapstructure:"bulk_job"`
+	JobQueue         jobQueueConfig            `mapstructure:"job_queue"`
 	GormLog          gormLogConfig             `mapstructure:"gorm_log"`
+	IndexAdvisor     indexAdvisorConfig        `mapstructure:"index_advisor"`
+	SlowQuery        slowQueryConfig           `mapstructure:"slow_query"`
+	Archive          archiveConfig             `mapstructure:"archive"`
+	Warmup           warmupConfig              `mapstructure:"warmup"`
+	Coalesce         coalesceConfig            `mapstructure:"request_coalescing"`
+	LabelJoinCache   labelJoinCacheConfig      `mapstructure:"label_join_cache"`
+	Maintenance      maintenanceConfig         `mapstructure:"maintenance"`
+	Contract         contractValidationConfig  `mapstructure:"contract_validation"`
+	QueryBudget      queryBudgetConfig         `mapstructure:"query_budget"` // 见 querycost.go
+	RateLimit        rateLimitConfig           `mapstructure:"rate_limit"`   // 见 ratelimit.go
+	Audit            auditConfig               `mapstructure:"audit"`        // 见 audit.go
+	Metrics          metricsConfig             `mapstructure:"metrics"`      // 见 metrics.go
+	Recording        recordingConfig           `mapstructure:"recording"`    // 见 recording.go
+	Webhooks         webhookConfig             `mapstructure:"webhooks"`     // 见 webhook.go
+	Events           eventsConfig              `mapstructure:"events"`       // 见 events.go
+	Reload           reloadConfig              `mapstructure:"reload"`       // 见 configswap.go
 	Databases        map[string]databaseConfig `mapstructure:"databases"`
 }
 
@@ -78,14 +133,32 @@ type gormLogConfig struct {
 	LogLevel                  string `mapstructure:"log_level"`
 	IgnoreRecordNotFoundError bool   `mapstructure:"ignore_record_not_found_error"`
 	Colorful                  bool   `mapstructure:"colorful"`
+
+	Sinks []logSinkConfig `mapstructure:"sinks"`
 }
 
 type databaseConfig struct {
-	Alias    string        `mapstructure:"alias"`
-	Type     string        `mapstructure:"type"`
-	DSN      string        `mapstructure:"dsn"`
-	Database string        `mapstructure:"database"`
-	Pool     poolConfig    `mapstructure:"pool"`
+	Alias string `mapstructure:"alias"`
+	Type  string `mapstructure:"type"`
+	DSN   string `mapstructure:"dsn"`
+	// dsn 配置为列表时的全部 DSN，DSN 为其中第一个（主库）
+	DSNs     []string       `mapstructure:"-"`
+	Failover failoverConfig `mapstructure:"failover"`
+	Database string         `mapstructure:"database"`
+	// Snowflake：覆盖 DSN 中的 warehouse/role
+	Warehouse   string            `mapstructure:"warehouse"`
+	Role        string            `mapstructure:"role"`
+	TiDB        tidbConfig        `mapstructure:"tidb"`
+	CockroachDB cockroachConfig   `mapstructure:"cockroachdb"`
+	Ego         egoConfig         `mapstructure:"ego"`
+	OpenAPI     openapiConfig     `mapstructure:"openapi"`
+	Shards      shardsConfig      `mapstructure:"shards"`
+	Session     sessionConfig     `mapstructure:"session"`
+	Pool        poolConfig        `mapstructure:"pool"`
+	Maintenance maintenanceConfig `mapstructure:"maintenance"` // 维护模式，见 maintenance.go
+	ShadowRead  shadowReadConfig  `mapstructure:"shadow_read"` // 影子读，见 shadowread.go
+	// 覆盖 gorm_log.log_level
+	LogLevel string        `mapstructure:"log_level"`
 	Tables   []tableConfig `mapstructure:"tables"`
 }
 
@@ -97,14 +170,71 @@ type poolConfig struct {
 }
 
 type tableConfig struct {
-	Name             string                 `mapstructure:"name"`
-	Alias            string                 `mapstructure:"alias"`
-	PrimaryKey       string                 `mapstructure:"primary_key"`
-	UniqueKeys       interface{}            `mapstructure:"unique_keys"` // 支持多级结构
-	DefaultValues    map[string]interface{} `mapstructure:"default_values"`
-	SoftDeleteKey    string                 `mapstructure:"softdel_key"`
-	SoftDeleteType   string                 `mapstructure:"softdel_type"`
-	AutoUpdateFields interface{}            `mapstructure:"auto_update"`
+	Name             string                       `mapstructure:"name"`
+	Alias            string                       `mapstructure:"alias"`
+	PrimaryKey       string                       `mapstructure:"primary_key"`
+	UniqueKeys       interface{}                  `mapstructure:"unique_keys"` // 支持多级结构
+	DefaultValues    map[string]interface{}       `mapstructure:"default_values"`
+	SoftDeleteKey    string                       `mapstructure:"softdel_key"`
+	SoftDeleteType   string                       `mapstructure:"softdel_type"`
+	AutoUpdateFields interface{}                  `mapstructure:"auto_update"`
+	IDResolution     []string                     `mapstructure:"id_resolution"`   // :id 查找顺序，见 idresolver.go
+	OperationRoles   map[string][]string          `mapstructure:"operation_roles"` // 操作 → 允许的角色，见 auth.go
+	FieldAliases     map[string]string            `mapstructure:"field_aliases"`   // 物理列名 → API 字段名，见 fieldalias.go
+	Timezone         string                       `mapstructure:"timezone"`        // 时间格式与时区，见 timefmt.go
+	TimeFormat       string                       `mapstructure:"time_format"`
+	TimeFields       map[string]timeFieldConfig   `mapstructure:"time_fields"`
+	AutoActorFields  autoActorFields              `mapstructure:"auto_actor_fields"`
+	TimeSeries       timeSeriesConfig             `mapstructure:"timeseries"`       // 时序查询，见 timeseries.go
+	Session          sessionConfig                `mapstructure:"session"`          // 会话设置，见 session.go
+	PipelineStages   []string                     `mapstructure:"pipeline_stages"`  // 聚合管道阶段白名单，见 pipeline.go
+	GormScopes       gormScopesConfig             `mapstructure:"gorm_scopes"`      // 列表查询的索引提示与注释，见 scopes.go
+	StrictFilters    *bool                        `mapstructure:"strict_filters"`   // 只接受 filter[...] 过滤参数，见 strictfilter.go
+	ConflictCheck    bool                         `mapstructure:"conflict_check"`   // 创建前检查唯一键冲突，见 conflictcheck.go
+	History          tableHistoryConfig           `mapstructure:"history"`          // 行版本历史，见 history.go
+	Rollup           rollupConfig                 `mapstructure:"rollup"`           // 定时聚合生成的只读汇总表，见 rollup.go
+	Archive          tableArchiveConfig           `mapstructure:"archive"`          // 软删除记录归档，见 archive.go
+	HiddenFields     []string                     `mapstructure:"hidden_fields"`    // 不对外返回的字段，见 fieldpolicy.go
+	MaskedFields     map[string]string            `mapstructure:"masked_fields"`    // 字段 → 脱敏方式
+	ImmutableFields  []string                     `mapstructure:"immutable_fields"` // 创建后不允许修改的字段，见 immutable.go
+	ImmutableMode    string                       `mapstructure:"immutable_mode"`
+	StateMachine     stateMachineConfig           `mapstructure:"state_machine"`     // 状态流转校验，见 statemachine.go
+	Validations      map[string]fieldValidation   `mapstructure:"validations"`       // 字段校验规则，见 validation.go
+	Concurrency      concurrencyConfig            `mapstructure:"concurrency"`       // 并发更新冲突策略，见 concurrency.go
+	Warmup           bool                         `mapstructure:"warmup"`            // 启动时预热，见 warmup.go
+	FieldTransforms  map[string][]interface{}     `mapstructure:"field_transforms"`  // 字段输出转换，见 transform.go
+	LabelJoins       []string                     `mapstructure:"label_joins"`       // 参照表名称字段，见 labeljoin.go
+	CrossLookups     map[string]crossLookupConfig `mapstructure:"cross_lookups"`     // 跨库关联，见 crosslookup.go
+	Relations        map[string]relationConfig    `mapstructure:"relations"`         // 同库关联展开，见 relation.go
+	ValueLabels      map[string]valueLabelConfig  `mapstructure:"value_labels"`      // 编码值多语言名称，见 valuelabel.go
+	CursorField      string                       `mapstructure:"cursor_field"`      // 游标分页的排序列，见 keyset.go
+	Maintenance      maintenanceConfig            `mapstructure:"maintenance"`       // 维护模式，见 maintenance.go
+	Mirror           tableMirrorConfig            `mapstructure:"mirror"`            // 双写迁移，见 mirror.go
+	Form             formConfig                   `mapstructure:"form"`              // 表单字段顺序与控件，见 form.go
+	RowFilter        string                       `mapstructure:"row_filter"`        // 按 JWT claims 限定可访问的行，见 rowfilter.go
+	FilterableFields []string                     `mapstructure:"filterable_fields"` // 可用于过滤的字段，见 filterfields.go
+	SortableFields   []string                     `mapstructure:"sortable_fields"`   // 可用于排序的字段
+	QueryBudget      queryBudgetConfig            `mapstructure:"query_budget"`      // 列表查询的代价预算，见 querycost.go
+	RateLimit        tableRateLimitConfig         `mapstructure:"rate_limit"`        // 该表接口的限流，见 ratelimit.go
+	SLO              sloConfig                    `mapstructure:"slo"`               // 该表的 SLO 目标，见 metrics.go
+	Webhooks         []tableWebhookConfig         `mapstructure:"webhooks"`          // 增删改事件回调，见 webhook.go
+
+	transforms       []fieldTransform
+	defaultTemplates map[string]*template.Template
+	labelJoins       []labelJoin
+	valueLabels      []valueLabel
+	rowFilter        []rowCondition
+	columns          map[string]struct{} // 表结构中的物理列名，加载配置时读取，见 patch.go
+}
+
+// 自动写入调用者标识的字段，如：
+//
+//	auto_actor_fields:
+//	  on_create: [created_by, updated_by]
+//	  on_update: [updated_by]
+type autoActorFields struct {
+	OnCreate []string `mapstructure:"on_create"`
+	OnUpdate []string `mapstructure:"on_update"`
 }
 
 // 新增：解析 unique_keys 为 [][]string
@@ -170,6 +300,12 @@ func (tc *tableConfig) GetUniqueKeys() [][]string {
 // 校验字段组合是否在 unique_keys 配置中
 func (tc *tableConfig) IsValidKeyCombination(fields []string) bool {
 	keys := tc.GetUniqueKeys()
+	// 唯一键中的软删除字段可省略，查询本身只看未删除的记录
+	for _, k := range tc.GetUniqueKeys() {
+		if kept := withoutField(k, tc.SoftDeleteKey); len(kept) > 0 && len(kept) < len(k) {
+			keys = append(keys, kept)
+		}
+	}
 OUTER:
 	for _, k := range keys {
 		if len(k) != len(fields) {
@@ -185,6 +321,16 @@ OUTER:
 	return false
 }
 
+func withoutField(fields []string, name string) []string {
+	kept := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f != name {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
 // 解析 key 参数如 "phone,email" 为 []string
 func parseKeyFields(keyParam string) []string {
 	keyParam = strings.TrimSpace(keyParam)
@@ -204,6 +350,8 @@ type listParams struct {
 	Fields       string
 	Order        string
 	QueryFilters url.Values
+	Keyset       *keysetPage // 游标分页，见 keyset.go
+	SkipCount    bool        // 不统计 total（查询代价超出预算时降级），见 querycost.go
 }
 
 type databaseAdapter interface {
@@ -219,7 +367,8 @@ type databaseAdapter interface {
 }
 
 var (
-	globalSnowflakeNode *snowflake.Node
+	globalSnowflakeNode   *snowflake.Node
+	globalSnowflakeNodeID int64
 )
 
 // --------- 主管理器 ---------
@@ -230,8 +379,35 @@ type databaseManager struct {
 	adapters           map[string]databaseAdapter
 	mutex              sync.RWMutex
 	tableCounts        map[string]int64
+	tableCountsAt      map[string]time.Time // 计数的统计时间，见 tablecount.go
 	countMutex         sync.RWMutex
 	cancelTableCounter context.CancelFunc
+	jobQueue           *utils.JobQueue
+	store              *utils.KVStore // job_queue.store，任务队列、表计数与限流共用
+	rateLimiter        *rateLimiter
+	audit              *auditLog
+	metrics            *metricsRegistry
+	webhooks           *webhookDispatcher
+	events             *eventBus        // 未开启 events 时为 nil
+	recorder           *requestRecorder // 未开启 recording 时为 nil
+	router             http.Handler     // 回放录制的请求时使用
+	jobsPrefix         string
+	configDir          string // 配置目录与 REST 前缀，重新生成 swagger 时使用
+	restPrefix         string
+	indexAdvice        *indexReport
+	indexAdviceMu      sync.RWMutex
+	slowQueries        map[string]*slowQueryRing
+	inflight           *inflightRegistry
+	labelCache         labelCache
+	maintenance        *maintenanceOverrides
+	mirror             *mirrorer
+	shadowReads        *shadowReader
+	contract           *contractValidator
+	jwt                *jwtVerifier // 未配置 auth.jwt 时为 nil
+	apiKeys            *apiKeyStore // 未配置 auth.api_keys 时为 nil
+	rbac               *rbacPolicy  // 未配置 auth.roles 时为 nil
+	schedulers         []*utils.Scheduler
+	live               *liveManager // 配置热切换时各代共用，见 configswap.go
 }
 
 // --------- RegisterRestAPI 及初始化 ---------
@@ -249,20 +425,135 @@ func RegisterRestAPI(router *gin.Engine, prefix string, config ...string) {
 			configPath = ""
 		}
 	}
-	dbManager, err := newDatabaseManager(configPath)
-	if err != nil {
+	if _, err := mountRestAPI(router, prefix, configPath); err != nil {
 		log.Fatalf("Failed to initialize database manager: %v", err)
 	}
-	api := router.Group(prefix)
+}
+
+func mountRestAPI(router *gin.Engine, prefix string, configPath string) (*databaseManager, error) {
+	dbManager, err := newDatabaseManager(configPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	dbManager.configDir, dbManager.restPrefix = configPath, prefix
+	dbManager.router = router
+	dbManager.jobsPrefix = path.Join(path.Dir(prefix), "jobs")
+	live := dbManager.live
+	live.router = router
+	live.current.handler = newRestEngine(router, dbManager)
+	// 路由转发到当前生效的管理器，配置热切换时整体替换，见 configswap.go
+	prefixes := dbManager.routePrefixes()
+	for _, p := range prefixes {
+		router.Any(p, live.serve)
+		router.Any(p+"/*path", live.serve)
+	}
+	if dbManager.metrics != nil && !hasPathPrefix(dbManager.metrics.config.Path, prefixes) {
+		router.GET(dbManager.metrics.config.Path, live.serve)
+	}
+	return dbManager, nil
+}
+
+// routePrefixes REST 及任务、审计、元数据、管理接口的路由前缀
+func (dm *databaseManager) routePrefixes() []string {
+	base := path.Dir(dm.restPrefix)
+	return []string{dm.restPrefix, dm.jobsPrefix, path.Join(base, "audit"), path.Join(base, "meta"), path.Join(base, "admin")}
+}
+
+func hasPathPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newRestEngine 注册 dm 的全部路由，router 的路径与客户端 IP 设置沿用到新的 engine
+func newRestEngine(router *gin.Engine, dbManager *databaseManager) *gin.Engine {
+	r := gin.New()
+	r.RedirectTrailingSlash, r.RedirectFixedPath = router.RedirectTrailingSlash, router.RedirectFixedPath
+	r.HandleMethodNotAllowed, r.ForwardedByClientIP = router.HandleMethodNotAllowed, router.ForwardedByClientIP
+	r.RemoteIPHeaders, r.TrustedPlatform = router.RemoteIPHeaders, router.TrustedPlatform
+	r.UseRawPath, r.UnescapePathValues, r.RemoveExtraSlash = router.UseRawPath, router.UnescapePathValues, router.RemoveExtraSlash
+	r.MaxMultipartMemory = router.MaxMultipartMemory
+	prefix := dbManager.restPrefix
+	api := r.Group(prefix, dbManager.withMetrics, dbManager.withRecording, dbManager.withRateLimit, dbManager.withAPIKey, dbManager.withJWT, dbManager.withRBAC, dbManager.contractGuard, dbManager.maintenanceGuard, dbManager.readOnlyGuard, withIncomingHeader, dbManager.withAuditContext, dbManager.withFilterParams, dbManager.withSession, dbManager.withIncludeDeleted, dbManager.withRowFilter)
 	{
 		api.GET("/:database/:table", dbManager.handleList)
 		api.POST("/:database/:table", dbManager.handleBatchCreate)
 		api.PUT("/:database/:table", dbManager.handleBatchUpdate)
 		api.POST("/:database/:table/batch_delete", dbManager.handleBatchDelete)
+		api.POST("/:database/:table/batch_get", dbManager.handleBatchGet)
+		api.POST("/:database/:table/upsert", dbManager.handleUpsert)
+		api.POST("/:database/:table/update_where", dbManager.handleUpdateWhere)
+		api.POST("/:database/:table/delete_where", dbManager.handleDeleteWhere)
+		api.GET("/:database/:table/stats", dbManager.handleStats)
+		api.GET("/:database/:table/timeseries", dbManager.handleTimeSeries)
+		api.GET("/:database/:table/aggregate", dbManager.handleAggregate)
+		api.GET("/:database/:table/distinct", dbManager.handleDistinct)
+		api.GET("/:database/:table/export", dbManager.handleExport)
+		api.POST("/:database/:table/aggregate_pipeline", dbManager.handleAggregatePipeline)
+		api.POST("/:database/:table/bulk_jobs", dbManager.handleBulkJobSubmit)
+		api.POST("/:database/:table/import", dbManager.handleImport)
 		api.GET("/:database/:table/:id", dbManager.handleGetOne)
+		api.GET("/:database/:table/:id/history", dbManager.handleHistory)
 		api.PUT("/:database/:table/:id", dbManager.handleUpdateOne)
+		api.PATCH("/:database/:table/:id", dbManager.handlePatchOne)
 		api.DELETE("/:database/:table/:id", dbManager.handleDeleteOne)
 	}
+	jobs := r.Group(dbManager.jobsPrefix, dbManager.withRateLimit, dbManager.withAPIKey, dbManager.withJWT, dbManager.withRBAC)
+	{
+		jobs.GET("", dbManager.handleJobList)
+		jobs.GET("/:id", dbManager.handleJobGet)
+		jobs.DELETE("/:id", dbManager.handleJobCancel)
+		jobs.POST("/:id/retry", dbManager.handleJobRetry)
+	}
+	audit := r.Group(path.Join(path.Dir(prefix), "audit"), dbManager.withRateLimit, dbManager.withAPIKey, dbManager.withJWT, dbManager.withRBAC)
+	{
+		audit.GET("", dbManager.handleAuditList)
+	}
+	if dbManager.metrics != nil {
+		r.GET(dbManager.metrics.config.Path, dbManager.handleMetrics)
+	}
+	meta := r.Group(path.Join(path.Dir(prefix), "meta"), dbManager.withRateLimit, dbManager.withAPIKey, dbManager.withJWT, dbManager.withRBAC)
+	{
+		meta.GET("/:database", dbManager.handleDatabaseMeta)
+		meta.GET("/:database/:table", dbManager.handleTableMeta)
+		meta.GET("/:database/:table/form", dbManager.handleTableForm)
+	}
+	admin := r.Group(path.Join(path.Dir(prefix), "admin"), dbManager.withRateLimit, dbManager.withAPIKey, dbManager.withJWT, dbManager.withRBAC)
+	{
+		admin.GET("/index_suggestions", dbManager.handleIndexAdviceGet)
+		admin.POST("/index_suggestions", dbManager.handleIndexAdviceRun)
+		admin.GET("/slow-queries", dbManager.handleSlowQueries)
+		admin.GET("/slo/rules", dbManager.handleSLORules)
+		admin.GET("/recordings", dbManager.handleRecordingList)
+		admin.GET("/recordings/:id", dbManager.handleRecordingGet)
+		admin.POST("/recordings/:id/replay", dbManager.handleRecordingReplay)
+		admin.GET("/webhooks/dead_letters", dbManager.handleWebhookDeadLetters)
+		admin.POST("/webhooks/dead_letters/:id/retry", dbManager.handleWebhookDeadLetterRetry)
+		admin.DELETE("/webhooks/dead_letters/:id", dbManager.handleWebhookDeadLetterDelete)
+		admin.GET("/events", dbManager.handleEventStats)
+		admin.POST("/archive", dbManager.handleArchiveRun)
+		admin.POST("/rollup", dbManager.handleRollupRefresh)
+		admin.POST("/graphql/reload", dbManager.handleGraphqlReload)
+		admin.GET("/config/reload", dbManager.handleConfigReloadStatus)
+		admin.POST("/config/reload", dbManager.handleConfigReload)
+		admin.POST("/swagger/regenerate", dbManager.handleSwaggerRegenerate)
+		admin.GET("/meta/warnings", dbManager.handleMetaWarnings)
+		admin.GET("/aliases", dbManager.handleAliasReport)
+		admin.GET("/queries", dbManager.handleInflightQueries)
+		admin.DELETE("/queries/:id", dbManager.handleInflightCancel)
+		admin.GET("/maintenance", dbManager.handleMaintenanceList)
+		admin.PUT("/maintenance", dbManager.handleMaintenanceSet)
+		admin.DELETE("/maintenance", dbManager.handleMaintenanceClear)
+		admin.GET("/mirror", dbManager.handleMirrorStatus)
+		admin.POST("/mirror/verify", dbManager.handleMirrorVerify)
+		admin.GET("/shadow-reads", dbManager.handleShadowReads)
+		admin.GET("/contract", dbManager.handleContractStatus)
+		admin.GET("/browser", dbManager.handleBrowserTables)
+	}
+	return r
 }
 
 func fileExists(path string) bool {
@@ -284,9 +575,25 @@ func loadConfigFromDir(configDir string) (*dmConfig, error) {
 	mainV.SetConfigFile(configPath)
 	mainV.SetDefault("default_page", 1)
 	mainV.SetDefault("default_page_size", 10)
-	mainV.SetDefault("max_page_size", 1000)
+	mainV.SetDefault("max_page_size", defaultMaxPageSize)
 	mainV.SetDefault("snowflake_node_id", 1)
 	mainV.SetDefault("total_cnt_interval", 30)
+	mainV.SetDefault("max_affected_rows", 1000)
+	mainV.SetDefault("bulk_job.dir", "data/jobs")
+	mainV.SetDefault("bulk_job.chunk_size", 1000)
+	mainV.SetDefault("bulk_job.workers", 2)
+	mainV.SetDefault("bulk_job.max_retries", 0)
+	mainV.SetDefault("bulk_job.source_timeout", "30m")
+	mainV.SetDefault("job_queue.store", "data/queue")
+	mainV.SetDefault("job_queue.workers", 4)
+	mainV.SetDefault("job_queue.retention", "24h")
+	mainV.SetDefault("job_queue.retry_delay", "5s")
+	mainV.SetDefault("webhooks.timeout", "10s")
+	mainV.SetDefault("webhooks.max_retries", 5)
+	mainV.SetDefault("webhooks.dead_letter_retention", "168h")
+	mainV.SetDefault("reload.run_tests", true)
+	mainV.SetDefault("reload.smoke_timeout", "10s")
+	mainV.SetDefault("reload.drain_timeout", "30s")
 	mainV.SetDefault("gorm_log.filename", "logs/gorm.log")
 	mainV.SetDefault("gorm_log.max_size", 100)
 	mainV.SetDefault("gorm_log.max_backups", 3)
@@ -296,6 +603,13 @@ func loadConfigFromDir(configDir string) (*dmConfig, error) {
 	mainV.SetDefault("gorm_log.log_level", "info")
 	mainV.SetDefault("gorm_log.ignore_record_not_found_error", true)
 	mainV.SetDefault("gorm_log.colorful", false)
+	mainV.SetDefault("index_advisor.min_count", 20)
+	mainV.SetDefault("index_advisor.max_scan_mb", 64)
+	mainV.SetDefault("warmup.rows", 100)
+	mainV.SetDefault("warmup.concurrency", 4)
+	mainV.SetDefault("warmup.timeout", "30s")
+	mainV.SetDefault("label_join_cache.ttl", "5m")
+	mainV.SetDefault("label_join_cache.max_rows", 10000)
 	if err := mainV.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read main config: %w", err)
 	}
@@ -328,10 +642,25 @@ func loadConfigFromDir(configDir string) (*dmConfig, error) {
 		if err := dsV.ReadInConfig(); err != nil {
 			return nil, fmt.Errorf("failed to read config for datasource %s: %w", dfName, err)
 		}
+		// dsn 列表：第一个为主库，其余为故障转移备库
+		var dsns []string
+		if list, ok := dsV.Get("dsn").([]interface{}); ok {
+			for _, v := range list {
+				dsns = append(dsns, fmt.Sprint(v))
+			}
+			if len(dsns) == 0 {
+				return nil, fmt.Errorf("empty dsn list for datasource %s", dfName)
+			}
+			dsV.Set("dsn", dsns[0])
+		}
 		dsConf := databaseConfig{}
 		if err := dsV.Unmarshal(&dsConf); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal yaml for datasource %s: %w", dfName, err)
 		}
+		dsConf.DSNs = dsns
+		if err := checkMaintenanceConfig("datasource "+dfName, dsConf.Maintenance); err != nil {
+			return nil, err
+		}
 
 		// 遍历表配置文件
 		tbPath := filepath.Join(tableDir, dfName)
@@ -350,19 +679,71 @@ func loadConfigFromDir(configDir string) (*dmConfig, error) {
 			if err := tblV.Unmarshal(&tblConf); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal table config %s: %w", f.Name(), err)
 			}
+			if tblConf.transforms, err = compileFieldTransforms(tblConf.FieldTransforms); err != nil {
+				return nil, fmt.Errorf("invalid table config %s: %w", f.Name(), err)
+			}
+			if err := checkImmutableMode(&tblConf); err != nil {
+				return nil, err
+			}
+			if err := checkTimeSettings(&tblConf); err != nil {
+				return nil, err
+			}
+			if err := compileValidations(&tblConf); err != nil {
+				return nil, err
+			}
+			if err := compileRowFilter(&tblConf); err != nil {
+				return nil, err
+			}
+			if err := checkConcurrencyConfig(&tblConf); err != nil {
+				return nil, err
+			}
+			if err := checkMaintenanceConfig("table config "+f.Name(), tblConf.Maintenance); err != nil {
+				return nil, err
+			}
+			if tblConf.defaultTemplates, err = compileDefaultTemplates(tblConf.DefaultValues); err != nil {
+				return nil, fmt.Errorf("invalid table config %s: %w", f.Name(), err)
+			}
 			tables = append(tables, tblConf)
 		}
 		dsConf.Tables = tables
+		if err := checkTableConfigAliases(dsConf.Alias, tables); err != nil {
+			return nil, err
+		}
+		if err := checkLabelJoins(dsConf.Alias, tables); err != nil {
+			return nil, err
+		}
+		if err := checkRelations(dsConf.Alias, tables); err != nil {
+			return nil, err
+		}
+		if err := checkValueLabels(dsConf.Alias, tables); err != nil {
+			return nil, err
+		}
+		if prev, ok := config.Databases[dsConf.Alias]; ok {
+			return nil, fmt.Errorf("alias collision: databases %s and %s both map to '%s'", prev.Database, dsConf.Database, dsConf.Alias)
+		}
 		config.Databases[dsConf.Alias] = dsConf
 	}
+	if err := checkCrossLookups(config.Databases); err != nil {
+		return nil, err
+	}
+	if err := checkEventTables(config.Events, config.Databases); err != nil {
+		return nil, err
+	}
+	if err := checkBulkSourcePrefixes(config.BulkJob.SourcePrefixes); err != nil {
+		return nil, err
+	}
+	if err := checkMaintenanceConfig("_base.yaml", config.Maintenance); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-func newDatabaseManager(configPath string) (*databaseManager, error) {
+// newDatabaseManager 加载配置并初始化；live 非空时为配置热切换构建新的一代，沿用其中当前管理器的任务队列等，见 configswap.go
+func newDatabaseManager(configPath string, live *liveManager) (_ *databaseManager, err error) {
 	if configPath == "" {
 		return nil, errors.New("config path is empty")
 	}
-	_, err := os.Stat(configPath)
+	_, err = os.Stat(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("read config failed: %w", err)
 	}
@@ -371,87 +752,154 @@ func newDatabaseManager(configPath string) (*databaseManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config file: %w", err)
 	}
-	node, err := snowflake.NewNode(cfg.SnowflakeNodeID)
+	// 节点编号不变时沿用原节点（重新加载配置、场景测试），新建节点可能在同一毫秒内生成重复 ID
+	if globalSnowflakeNode == nil || globalSnowflakeNodeID != cfg.SnowflakeNodeID {
+		node, err := snowflake.NewNode(cfg.SnowflakeNodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create snowflake node: %w", err)
+		}
+		globalSnowflakeNode, globalSnowflakeNodeID = node, cfg.SnowflakeNodeID
+	}
+	gormLogger, err := newGormLogger(cfg.GormLog)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create snowflake node: %w", err)
+		return nil, err
 	}
-	globalSnowflakeNode = node
-	slowThreshold, err := time.ParseDuration(cfg.GormLog.SlowThreshold)
+	slowQueryThreshold, err := cfg.slowQueryThreshold()
 	if err != nil {
-		return nil, fmt.Errorf("invalid slow_threshold: %w", err)
-	}
-	var logLevel logger.LogLevel
-	switch strings.ToLower(cfg.GormLog.LogLevel) {
-	case "silent":
-		logLevel = logger.Silent
-	case "error":
-		logLevel = logger.Error
-	case "warn":
-		logLevel = logger.Warn
-	case "info":
-		logLevel = logger.Info
-	default:
-		return nil, fmt.Errorf("invalid gorm log level: %s", cfg.GormLog.LogLevel)
-	}
-	gormLogger := logger.New(
-		log.New(&lumberjack.Logger{
-			Filename:   cfg.GormLog.Filename,
-			MaxSize:    cfg.GormLog.MaxSize,
-			MaxBackups: cfg.GormLog.MaxBackups,
-			MaxAge:     cfg.GormLog.MaxAge,
-			Compress:   cfg.GormLog.Compress,
-		}, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             slowThreshold,
-			LogLevel:                  logLevel,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		},
-	)
+		return nil, err
+	}
 	dm := &databaseManager{
-		config:       cfg,
-		gormDBs:      make(map[string]*gorm.DB),
-		mongoClients: make(map[string]*mongo.Client),
-		adapters:     make(map[string]databaseAdapter),
-		tableCounts:  make(map[string]int64),
+		config:        cfg,
+		gormDBs:       make(map[string]*gorm.DB),
+		mongoClients:  make(map[string]*mongo.Client),
+		adapters:      make(map[string]databaseAdapter),
+		tableCounts:   make(map[string]int64),
+		tableCountsAt: make(map[string]time.Time),
+		slowQueries:   make(map[string]*slowQueryRing),
+		inflight:      newInflightRegistry(),
+		maintenance:   newMaintenanceOverrides(),
+		configDir:     configPath,
+		live:          live,
+	}
+	if live == nil {
+		dm.live = &liveManager{current: &restGeneration{dm: dm, id: 1}}
+	} else {
+		// 运行时的维护设置在切换后保留
+		dm.maintenance = live.manager().maintenance
 	}
+	defer func() {
+		if err != nil {
+			dm.discard()
+		}
+	}()
 	for name, dbConfig := range cfg.Databases {
+		dbLogger, err := gormLogger.forDatabase(name, dbConfig.LogLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log_level for %s: %w", name, err)
+		}
+		if len(dbConfig.Shards.Nodes) > 0 {
+			adapter, err := newShardedAdapter(&dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold))
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to shards of %s: %w", name, err)
+			}
+			dm.adapters[name] = adapter
+			continue
+		}
 		switch strings.ToLower(dbConfig.Type) {
 		case "mysql":
-			db, err := setupGormDB(dbConfig, gormLogger, mysql.Open(dbConfig.DSN))
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), mysql.Open(dbConfig.DSN))
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to MySQL %s: %w", name, err)
 			}
 			dm.gormDBs[name] = db
 			dm.adapters[name] = newGormAdapter(db, &dbConfig)
+		case "tidb":
+			dsn, err := tidbDSN(dbConfig.DSN, dbConfig.TiDB)
+			if err != nil {
+				return nil, fmt.Errorf("invalid TiDB DSN for %s: %w", name, err)
+			}
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), mysql.Open(dsn))
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to TiDB %s: %w", name, err)
+			}
+			dm.gormDBs[name] = db
+			dm.adapters[name] = newGormAdapter(db, &dbConfig)
 		case "postgresql":
-			db, err := setupGormDB(dbConfig, gormLogger, postgres.Open(dbConfig.DSN))
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), postgres.Open(dbConfig.DSN))
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to PostgreSQL %s: %w", name, err)
 			}
 			dm.gormDBs[name] = db
 			dm.adapters[name] = newGormAdapter(db, &dbConfig)
+		case "cockroachdb":
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), postgres.Open(dbConfig.DSN))
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to CockroachDB %s: %w", name, err)
+			}
+			dm.gormDBs[name] = db
+			dm.adapters[name] = newGormAdapter(db, &dbConfig)
 		case "sqlite":
-			db, err := setupGormDB(dbConfig, gormLogger, sqlite.Open(dbConfig.DSN))
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), sqlite.Open(dbConfig.DSN))
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to SQLite %s: %w", name, err)
 			}
 			dm.gormDBs[name] = db
 			dm.adapters[name] = newGormAdapter(db, &dbConfig)
 		case "sqlserver":
-			db, err := setupGormDB(dbConfig, gormLogger, sqlserver.Open(dbConfig.DSN))
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), sqlserver.Open(dbConfig.DSN))
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to SQL Server %s: %w", name, err)
 			}
 			dm.gormDBs[name] = db
 			dm.adapters[name] = newGormAdapter(db, &dbConfig)
 		case "clickhouse":
-			db, err := setupGormDB(dbConfig, gormLogger, clickhouse.Open(dbConfig.DSN))
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), clickhouse.Open(dbConfig.DSN))
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to ClickHouse %s: %w", name, err)
 			}
 			dm.gormDBs[name] = db
 			dm.adapters[name] = newGormAdapter(db, &dbConfig)
+		case "snowflake":
+			dsn, err := snowflakeDSN(dbConfig.DSN, dbConfig.Warehouse, dbConfig.Role)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Snowflake DSN for %s: %w", name, err)
+			}
+			db, err := setupGormDB(dbConfig, dm.slowQueryLogger(name, dbLogger, slowQueryThreshold), openSnowflake(dsn))
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to Snowflake %s: %w", name, err)
+			}
+			dm.gormDBs[name] = db
+			dm.adapters[name] = newGormAdapter(db, &dbConfig)
+		case "bigquery":
+			client, err := newBigQueryClient(context.Background(), dbConfig.DSN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to BigQuery %s: %w", name, err)
+			}
+			dm.adapters[name] = newBigQueryAdapter(client, dbConfig.Database, &dbConfig)
+		case "ego":
+			adapter, err := newEgoAdapter(&dbConfig, cfg.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ego dsn for %s: %w", name, err)
+			}
+			dm.adapters[name] = adapter
+		case "openapi":
+			adapter, err := newOpenAPIAdapter(&dbConfig, cfg.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load openapi backend %s: %w", name, err)
+			}
+			dm.adapters[name] = adapter
+		case "files":
+			adapter, err := newFileAdapter(dbConfig.DSN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load files for %s: %w", name, err)
+			}
+			dm.adapters[name] = adapter
+		case "influxdb":
+			adapter, err := newInfluxAdapter(dbConfig.DSN, dbConfig.Database, &dbConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to InfluxDB %s: %w", name, err)
+			}
+			dm.adapters[name] = adapter
 		case "mongodb":
 			clientOptions := options.Client().ApplyURI(dbConfig.DSN)
 			if dbConfig.Pool.MaxOpenConns > 0 {
@@ -476,6 +924,65 @@ func newDatabaseManager(configPath string) (*databaseManager, error) {
 			return nil, fmt.Errorf("unsupported database type for %s: %s", name, dbConfig.Type)
 		}
 	}
+	for name, db := range dm.gormDBs {
+		if err := dm.trackInflight(name, db); err != nil {
+			return nil, fmt.Errorf("failed to register query tracking for %s: %w", name, err)
+		}
+	}
+	if cfg.Auth.JWT.enabled() {
+		dm.jwt = newJWTVerifier(cfg.Auth.JWT)
+	}
+	if dm.apiKeys, err = newAPIKeyStore(cfg.Auth.APIKeys); err != nil {
+		return nil, err
+	}
+	if dm.rbac, err = newRBACPolicy(cfg.Auth.Roles); err != nil {
+		return nil, err
+	}
+	if err := dm.setupRowFilters(); err != nil {
+		return nil, err
+	}
+	dm.loadTableColumns()
+	if err := dm.validateQueryBudgets(); err != nil {
+		return nil, err
+	}
+	if dm.rateLimiter, err = newRateLimiter(cfg.RateLimit); err != nil {
+		return nil, err
+	}
+	if err := dm.validateRateLimits(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupMetrics(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupHistory(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupMirrors(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupShadowReads(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupContractValidation(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupWebhooks(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupJobQueue(); err != nil {
+		return nil, fmt.Errorf("failed to start job queue: %w", err)
+	}
+	if err := dm.setupAudit(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupRecording(); err != nil {
+		return nil, err
+	}
+	if err := dm.setupEvents(); err != nil {
+		return nil, err
+	}
+	dm.loadTableCounts()
+	dm.warmUp()
 	ctx, cancel := context.WithCancel(context.Background())
 	dm.cancelTableCounter = cancel
 	go dm.startTableCounter(ctx, time.Duration(cfg.TotalCntInterval)*time.Second)
@@ -486,10 +993,25 @@ func setupGormDB(dbConfig databaseConfig, gormLogger logger.Interface, dialector
 	gormConfig := &gorm.Config{
 		Logger: gormLogger,
 	}
+	if len(dbConfig.DSNs) > 1 {
+		var err error
+		if dialector, err = failoverDialector(dbConfig, dialector); err != nil {
+			return nil, err
+		}
+	}
 	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
+		if db != nil {
+			if pool, ok := db.ConnPool.(*failoverPool); ok {
+				pool.Close()
+			}
+		}
 		return nil, err
 	}
+	if pool, ok := db.ConnPool.(*failoverPool); ok {
+		pool.setPool(dbConfig.Pool)
+		return db, nil
+	}
 	sqlDB, _ := db.DB()
 	if dbConfig.Pool.MaxOpenConns > 0 {
 		sqlDB.SetMaxOpenConns(dbConfig.Pool.MaxOpenConns)
@@ -545,9 +1067,12 @@ func (dm *databaseManager) updateAllTableCounts(ctx context.Context) {
 			if err != nil {
 				continue
 			}
+			now := time.Now()
 			dm.countMutex.Lock()
 			dm.tableCounts[key] = count
+			dm.tableCountsAt[key] = now
 			dm.countMutex.Unlock()
+			dm.saveTableCount(key, count, now)
 		}
 	}
 }
@@ -574,7 +1099,7 @@ func (dm *databaseManager) getAdapterAndTableConfig(dbName, tableAlias string) (
 // --------- 通用辅助函数 ---------
 
 func applyGormSoftDeleteFilter(db *gorm.DB, tc *tableConfig) *gorm.DB {
-	if tc.SoftDeleteKey != "" {
+	if tc.SoftDeleteKey != "" && !includeDeleted(db.Statement.Context) {
 		switch tc.SoftDeleteType {
 		case softDeleteTypeTimestamp:
 			return db.Where(fmt.Sprintf("%s IS NULL OR %s = ?", tc.SoftDeleteKey, tc.SoftDeleteKey), time.Time{})
@@ -589,8 +1114,10 @@ func applyGormSoftDeleteFilter(db *gorm.DB, tc *tableConfig) *gorm.DB {
 	return db
 }
 
-func applyMongoSoftDeleteFilter(filter bson.M, tc *tableConfig) bson.M {
-	if tc.SoftDeleteKey != "" {
+func applyMongoSoftDeleteFilter(ctx context.Context, filter bson.M, tc *tableConfig) bson.M {
+	// 软删除条件所在的查询同样按调用者的 row_filter 限定
+	filter = applyMongoRowFilter(ctx, filter, tc)
+	if tc.SoftDeleteKey != "" && !includeDeleted(ctx) {
 		if filter == nil {
 			filter = bson.M{}
 		}
@@ -652,13 +1179,24 @@ func normalizeLikeValue(value string) string {
 	return decoded
 }
 
-func applyDefaultValues(record map[string]interface{}, tc *tableConfig) {
+// applyDefaultValues 填充缺失字段的默认值，模板默认值见 defaulttemplate.go
+func applyDefaultValues(record map[string]interface{}, tc *tableConfig) error {
 	if tc.DefaultValues == nil {
-		return
+		return nil
+	}
+	payload := record
+	if len(tc.defaultTemplates) > 0 {
+		payload = copyRecord(record)
 	}
 	for field, defaultValue := range tc.DefaultValues {
 		if val, exists := record[field]; !exists || val == nil || val == "" {
-			if strVal, ok := defaultValue.(string); ok {
+			if tpl, ok := tc.defaultTemplates[field]; ok {
+				v, err := renderDefaultTemplate(tpl, payload)
+				if err != nil {
+					return err
+				}
+				record[field] = v
+			} else if strVal, ok := defaultValue.(string); ok {
 				switch strVal {
 				case defaultValueNow:
 					record[field] = time.Now()
@@ -682,6 +1220,7 @@ func applyDefaultValues(record map[string]interface{}, tc *tableConfig) {
 			}
 		}
 	}
+	return nil
 }
 
 func applyAutoUpdateFields(record map[string]interface{}, tc *tableConfig) {
@@ -694,6 +1233,21 @@ func applyAutoUpdateFields(record map[string]interface{}, tc *tableConfig) {
 	}
 }
 
+// applyAutoActorFields 写入调用者标识；请求体中的同名字段一律覆盖，未认证时移除，防止伪造
+func applyAutoActorFields(record map[string]interface{}, tc *tableConfig, actor string, isCreate bool) {
+	fields := tc.AutoActorFields.OnUpdate
+	if isCreate {
+		fields = tc.AutoActorFields.OnCreate
+	}
+	for _, field := range fields {
+		if actor == "" {
+			delete(record, field)
+			continue
+		}
+		record[field] = actor
+	}
+}
+
 func generateSnowflakeID() (string, error) {
 	if globalSnowflakeNode == nil {
 		return "", fmt.Errorf("snowflake node not initialized")
@@ -750,11 +1304,7 @@ func fixPkFieldToString(obj interface{}, keys ...string) interface{} {
 	case map[string]interface{}:
 		// 自动把 "@id" 替换成指定主键名
 		if len(keys) > 0 {
-			pk := keys[0]
-			if val, ok := v["@id"]; ok {
-				v[pk] = val
-				delete(v, "@id")
-			}
+			resolveIDPlaceholder(v, keys[0])
 		}
 		for k, val := range v {
 			if contains(keys, k) {
@@ -786,6 +1336,27 @@ func fixPkFieldToString(obj interface{}, keys ...string) interface{} {
 	}
 }
 
+// renderRecord 响应输出前的转换：字段策略、时间格式化、字段转换、字段别名
+func (tc *tableConfig) renderRecord(record map[string]interface{}) map[string]interface{} {
+	return tc.apiRecord(tc.applyFieldTransforms(tc.formatRecordTimes(tc.applyFieldPolicy(record))))
+}
+
+func (tc *tableConfig) renderRecords(records []map[string]interface{}) []map[string]interface{} {
+	for i := range records {
+		records[i] = tc.renderRecord(records[i])
+	}
+	return records
+}
+
+// inputRecord 请求体写入前的转换：字段别名、时间解析
+func (tc *tableConfig) inputRecord(record map[string]interface{}) (map[string]interface{}, error) {
+	record = tc.physicalRecord(record)
+	if err := tc.parseRecordTimes(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
 func contains(arr []string, target string) bool {
 	for _, s := range arr {
 		if s == target {
@@ -795,6 +1366,28 @@ func contains(arr []string, target string) bool {
 	return false
 }
 
+// listTotal 未带过滤条件时使用定期统计的总数；统计不含已软删除的记录，include_deleted 或按 row_filter 限定时使用查询的总数
+func (dm *databaseManager) listTotal(ctx context.Context, dbName, tableAlias string, filters url.Values, total int64) int64 {
+	if includeDeleted(ctx) {
+		return total
+	}
+	if _, tc, err := dm.getAdapterAndTableConfig(dbName, tableAlias); err == nil && rowFiltered(ctx, tc) {
+		return total
+	}
+	for key := range filters {
+		if !isReservedQueryParam(key) {
+			return total
+		}
+	}
+	dm.countMutex.RLock()
+	cachedCount, ok := dm.tableCounts[fmt.Sprintf("%s_%s", dbName, tableAlias)]
+	dm.countMutex.RUnlock()
+	if ok {
+		return cachedCount
+	}
+	return total
+}
+
 // --------- Gin Handler 实现部分 ---------
 
 func (dm *databaseManager) handleList(c *gin.Context) {
@@ -805,6 +1398,10 @@ func (dm *databaseManager) handleList(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	if _, ok := c.GetQuery(queryParamAsOf); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "as_of is only supported on single-record reads"})
+		return
+	}
 	pageStr := c.DefaultQuery(queryParamPage, strconv.Itoa(dm.config.DefaultPage))
 	pageSizeStr := c.DefaultQuery(queryParamPageSize, strconv.Itoa(dm.config.DefaultPageSize))
 	page, _ := strconv.Atoi(pageStr)
@@ -818,39 +1415,111 @@ func (dm *databaseManager) handleList(c *gin.Context) {
 	if pageSize > dm.config.MaxPageSize {
 		pageSize = dm.config.MaxPageSize
 	}
+	lookups, err := parseLookups(c, tableConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expand, err := parseExpand(c, tableConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query := tableConfig.physicalQuery(c.Request.URL.Query())
+	if err := tableConfig.checkSortFields(query.Get(queryParamOrder)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	keyset, err := parseKeyset(tableConfig, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Del(queryParamCursor)
 	listParams := listParams{
 		Page:         page,
 		PageSize:     pageSize,
-		Fields:       c.Query(queryParamFields),
-		Order:        c.Query(queryParamOrder),
-		QueryFilters: c.Request.URL.Query(),
+		Fields:       query.Get(queryParamFields),
+		Order:        query.Get(queryParamOrder),
+		QueryFilters: query,
 	}
-	isFiltered := false
-	for key := range listParams.QueryFilters {
-		if key != queryParamPage && key != queryParamPageSize && key != queryParamOrder && key != queryParamFields {
-			isFiltered = true
-			break
+	if _, ok := query[queryParamOr]; ok {
+		if _, ok := adapter.(orFilterer); !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "OR conditions are not supported by this database type"})
+			return
 		}
 	}
-	data, totalFromAdapter, err := adapter.List(c.Request.Context(), tableConfig, listParams)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 查询代价预算，见 querycost.go
+	degraded, costErr := dm.checkListCost(c.Request.Context(), adapter, tableConfig, &listParams, keyset != nil)
+	if costErr != nil {
+		writeQueryCostError(c, costErr)
 		return
 	}
-	finalTotal := totalFromAdapter
-	if !isFiltered {
-		dm.countMutex.RLock()
-		cachedCount, ok := dm.tableCounts[fmt.Sprintf("%s_%s", dbName, tableAlias)]
-		dm.countMutex.RUnlock()
-		if ok {
-			finalTotal = cachedCount
+	pageSize = listParams.PageSize
+	var relationAdded []string
+	listParams.Fields, relationAdded = withRelationColumns(listParams.Fields, tableConfig, expand)
+	var keysetAdded []string
+	if keyset != nil {
+		if _, ok := adapter.(keysetLister); !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Cursor pagination is not supported by this database type"})
+			return
 		}
+		// 多取一条判断是否还有下一页
+		listParams.Page, listParams.PageSize, listParams.Order, listParams.Keyset = 1, pageSize+1, "", keyset
+		listParams.Fields, keysetAdded = keyset.withColumns(listParams.Fields)
+	}
+	type listResult struct {
+		data  []map[string]interface{}
+		total int64
+	}
+	res, shared, err := coalesce(dm, c, func(ctx context.Context) (listResult, error) {
+		start := time.Now()
+		data, total, err := adapter.List(ctx, tableConfig, listParams)
+		dm.shadowList(ctx, dbName, tableConfig, listParams, data, total, err, time.Since(start))
+		return listResult{data, total}, err
+	})
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	data, totalFromAdapter := res.data, res.total
+	if shared {
+		data = copyRecords(data)
 	}
 	if data == nil {
 		data = []map[string]interface{}{}
 	}
+	resp := gin.H{"total": dm.listTotal(c.Request.Context(), dbName, tableAlias, listParams.QueryFilters, totalFromAdapter)}
+	if degraded != nil {
+		resp["total"], resp["degraded"] = nil, degraded
+	}
+	if keyset != nil {
+		if len(data) > pageSize {
+			data = data[:pageSize]
+			resp["next_cursor"] = keyset.nextCursor(data[pageSize-1])
+		}
+		for _, rec := range data {
+			for _, col := range keysetAdded {
+				delete(rec, col)
+			}
+		}
+	}
+	dm.applyLabelJoins(c.Request.Context(), dbName, tableConfig, data...)
+	dm.applyValueLabels(c, dbName, tableConfig, data...)
+	if !dm.applyCrossLookups(c, tableConfig, lookups, data) {
+		return
+	}
+	if !dm.applyRelations(c, dbName, adapter, tableConfig, expand, data) {
+		return
+	}
+	for _, rec := range data {
+		for _, col := range relationAdded {
+			delete(rec, col)
+		}
+	}
 	data = fixPkFieldToString(data, tableConfig.PrimaryKey).([]map[string]interface{})
-	c.JSON(http.StatusOK, gin.H{"total": finalTotal, "data": data})
+	resp["data"] = tableConfig.renderRecords(data)
+	c.JSON(http.StatusOK, resp)
 }
 
 func (dm *databaseManager) handleBatchCreate(c *gin.Context) {
@@ -861,6 +1530,21 @@ func (dm *databaseManager) handleBatchCreate(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	continueOnError, err := parseOnError(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	perRecord, err := wantsPerRecordResults(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var records []map[string]interface{}
 	if err := c.ShouldBindJSON(&records); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload: " + err.Error()})
@@ -871,9 +1555,54 @@ func (dm *databaseManager) handleBatchCreate(c *gin.Context) {
 		return
 	}
 	for i := range records {
-		applyDefaultValues(records[i], tableConfig)
+		if records[i], err = tableConfig.inputRecord(records[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// row_filter 的值优先于默认值
+		if err := tableConfig.scopeRecords(c.Request.Context(), records[i:i+1], true); err != nil {
+			writeGuardError(c, err)
+			return
+		}
+		if err := applyDefaultValues(records[i], tableConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		applyAutoActorFields(records[i], tableConfig, dm.currentActor(c), true)
+	}
+	if err := tableConfig.validateRecords(records, false); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.guardInitialStates(records); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if continueOnError {
+		dm.handleBatchCreatePartial(c, adapter, tableConfig, records, dryRun, perRecord)
+		return
+	}
+	var insertedIDs []interface{}
+	updatedRecords := records
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		if tableConfig.ConflictCheck {
+			conflict, err := checkUniqueConflicts(c.Request.Context(), a, tableConfig, records)
+			if err != nil {
+				return err
+			}
+			if conflict != nil {
+				return conflict
+			}
+		}
+		var err error
+		insertedIDs, updatedRecords, err = a.BatchCreate(c.Request.Context(), tableConfig, records)
+		return err
+	})
+	var conflict *uniqueConflictError
+	if errors.As(err, &conflict) {
+		conflict.respond(c)
+		return
 	}
-	insertedIDs, updatedRecords, err := adapter.BatchCreate(c.Request.Context(), tableConfig, records)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to batch create: " + err.Error()})
 		return
@@ -885,7 +1614,23 @@ func (dm *databaseManager) handleBatchCreate(c *gin.Context) {
 		}
 	}
 	updatedRecords = fixPkFieldToString(updatedRecords, tableConfig.PrimaryKey).([]map[string]interface{})
-	c.JSON(http.StatusCreated, updatedRecords)
+	var results []recordResult
+	if perRecord {
+		results = createResults(tableConfig, nil, updatedRecords, nil)
+	}
+	if dryRun {
+		resp := gin.H{"dry_run": true, "executed": executed, "data": tableConfig.renderRecords(updatedRecords)}
+		if perRecord {
+			resp["results"] = results
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if perRecord {
+		c.JSON(http.StatusCreated, gin.H{"data": tableConfig.renderRecords(updatedRecords), "results": results})
+		return
+	}
+	c.JSON(http.StatusCreated, tableConfig.renderRecords(updatedRecords))
 }
 
 func (dm *databaseManager) handleBatchUpdate(c *gin.Context) {
@@ -900,6 +1645,24 @@ func (dm *databaseManager) handleBatchUpdate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Primary key not defined for table, batch update requires primary key."})
 		return
 	}
+	if !requireConcurrencySupport(c, adapter, tableConfig) {
+		return
+	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	representation, err := wantsRepresentation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	perRecord, err := wantsPerRecordResults(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var records []map[string]interface{}
 	if err := c.ShouldBindJSON(&records); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload: " + err.Error()})
@@ -910,14 +1673,74 @@ func (dm *databaseManager) handleBatchUpdate(c *gin.Context) {
 		return
 	}
 	for i := range records {
+		if records[i], err = tableConfig.inputRecord(records[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		applyAutoUpdateFields(records[i], tableConfig)
+		applyAutoActorFields(records[i], tableConfig, dm.currentActor(c), false)
+	}
+	if err := tableConfig.scopeRecords(c.Request.Context(), records, false); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.validateRecords(records, true); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	keys := tableConfig.primaryKeyLookups(records)
+	if err := tableConfig.guardImmutable(c.Request.Context(), adapter, records, keys); err != nil {
+		writeGuardError(c, err)
+		return
 	}
-	matchedCount, modifiedCount, err := adapter.BatchUpdate(c.Request.Context(), tableConfig, records)
+	if err := tableConfig.guardTransitions(c.Request.Context(), adapter, records, keys); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	var matchedCount, modifiedCount int64
+	var updated []interface{}
+	var ids []interface{}
+	var exists []bool
+	if perRecord {
+		ids = make([]interface{}, len(records))
+		for i, r := range records {
+			ids[i] = r[tableConfig.PrimaryKey]
+		}
+	}
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		if perRecord {
+			if exists, err = existingRecords(c.Request.Context(), a, tableConfig, ids); err != nil {
+				return err
+			}
+		}
+		matchedCount, modifiedCount, err = a.BatchUpdate(c.Request.Context(), tableConfig, records)
+		if err == nil && representation {
+			updated, err = updatedRecords(c.Request.Context(), a, tableConfig, records, tableConfig.physicalFieldList(c.Query(queryParamFields)))
+		}
+		return err
+	})
 	if err != nil {
+		if isConcurrencyError(err) {
+			writeConcurrencyError(c, tableConfig, err)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to batch update: " + err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Batch update successful", "matched_count": matchedCount, "modified_count": modifiedCount})
+	var resp gin.H
+	if dryRun {
+		resp = gin.H{"dry_run": true, "executed": executed, "matched_count": matchedCount, "modified_count": modifiedCount}
+	} else {
+		resp = gin.H{"message": "Batch update successful", "matched_count": matchedCount, "modified_count": modifiedCount}
+	}
+	if updated != nil {
+		resp["data"] = updated
+	}
+	if perRecord {
+		resp["results"] = existenceResults(tableConfig, ids, exists, recordStatusUpdated)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (dm *databaseManager) handleBatchDelete(c *gin.Context) {
@@ -932,6 +1755,16 @@ func (dm *databaseManager) handleBatchDelete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Primary key not defined for table, batch delete requires primary key."})
 		return
 	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	perRecord, err := wantsPerRecordResults(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Read body failed"})
@@ -940,13 +1773,35 @@ func (dm *databaseManager) handleBatchDelete(c *gin.Context) {
 	var idsToDelete []interface{}
 	var recordsToDelete []map[string]interface{}
 	if errObj := json.Unmarshal(body, &recordsToDelete); errObj == nil && len(recordsToDelete) > 0 {
+		// 不带主键的对象按唯一键查出主键，未找到的位置保留 nil
+		var keyed []int
+		var keys []lookupKey
 		for _, rec := range recordsToDelete {
-			if idVal, ok := rec[tableConfig.PrimaryKey]; ok {
+			rec = tableConfig.physicalRecord(rec)
+			if idVal, ok := rec[tableConfig.PrimaryKey]; ok && idVal != nil {
 				idsToDelete = append(idsToDelete, idVal)
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Record in array missing primary key '%s'", tableConfig.PrimaryKey)})
+				continue
+			}
+			key, ok := tableConfig.recordLookupKey(rec)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Record in array missing primary key '%s' or a configured unique key", tableConfig.apiFieldName(tableConfig.PrimaryKey))})
+				return
+			}
+			keyed = append(keyed, len(idsToDelete))
+			keys = append(keys, key)
+			idsToDelete = append(idsToDelete, nil)
+		}
+		if len(keys) > 0 {
+			found, err := batchGetRecords(c.Request.Context(), adapter, tableConfig, keys, tableConfig.PrimaryKey)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve unique keys: " + err.Error()})
 				return
 			}
+			for j, r := range found {
+				if r != nil {
+					idsToDelete[keyed[j]] = r[tableConfig.PrimaryKey]
+				}
+			}
 		}
 	} else {
 		var plainIds []interface{}
@@ -965,12 +1820,41 @@ func (dm *databaseManager) handleBatchDelete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No IDs provided for deletion"})
 		return
 	}
-	affectedCount, err := adapter.BatchDelete(c.Request.Context(), tableConfig, idsToDelete)
+	var affectedCount int64
+	var exists []bool
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		if perRecord {
+			if exists, err = existingRecords(c.Request.Context(), a, tableConfig, idsToDelete); err != nil {
+				return err
+			}
+		}
+		ids := make([]interface{}, 0, len(idsToDelete))
+		for _, id := range idsToDelete {
+			if id != nil {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		affectedCount, err = a.BatchDelete(c.Request.Context(), tableConfig, ids)
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to batch delete: " + err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Batch delete successful", "deleted_count": affectedCount})
+	var resp gin.H
+	if dryRun {
+		resp = gin.H{"dry_run": true, "executed": executed, "deleted_count": affectedCount}
+	} else {
+		resp = gin.H{"message": "Batch delete successful", "deleted_count": affectedCount}
+	}
+	if perRecord {
+		resp["results"] = existenceResults(tableConfig, idsToDelete, exists, recordStatusDeleted)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (dm *databaseManager) handleGetOne(c *gin.Context) {
@@ -978,17 +1862,31 @@ func (dm *databaseManager) handleGetOne(c *gin.Context) {
 	tableAlias := c.Param("table")
 	idValStr := c.Param("id")
 	keyFieldParam := c.Query(queryParamKey)
-	fields := c.Query(queryParamFields)
+	if asOf := c.Query(queryParamAsOf); asOf != "" {
+		dm.handleGetOneAsOf(c, asOf)
+		return
+	}
 	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	keyFields := parseKeyFields(keyFieldParam)
+	lookups, err := parseLookups(c, tableConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expand, err := parseExpand(c, tableConfig)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fields, relationAdded := withRelationColumns(tableConfig.physicalFieldList(c.Query(queryParamFields)), tableConfig, expand)
+	keyFields := tableConfig.physicalFieldNames(parseKeyFields(keyFieldParam))
 	var filter map[string]interface{}
 	if len(keyFields) > 0 {
 		if !tableConfig.IsValidKeyCombination(keyFields) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", keyFields)})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", tableConfig.apiFieldNames(keyFields))})
 			return
 		}
 		vals := parseStringList(idValStr)
@@ -1000,27 +1898,67 @@ func (dm *databaseManager) handleGetOne(c *gin.Context) {
 		for i, f := range keyFields {
 			filter[f] = vals[i]
 		}
-	} else {
-		if tableConfig.PrimaryKey == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No identifiable key (primary or unique) configured for table"})
-			return
+	}
+	extra := getOneFilters(tableConfig, c.Request.URL.Query())
+	type getOneResult struct {
+		record     map[string]interface{}
+		matchedKey string
+	}
+	lookup := func(ctx context.Context, t readTarget) (getOneResult, error) {
+		if filter != nil {
+			record, err := getOneScoped(ctx, t.adapter, t.tc, filter, fields, extra)
+			return getOneResult{record, strings.Join(keyFields, ",")}, err
 		}
-		filter = map[string]interface{}{tableConfig.PrimaryKey: idValStr}
+		record, matchedKey, err := getOneByResolvedID(ctx, t.adapter, t.tc, idValStr, fields, extra)
+		return getOneResult{record, matchedKey}, err
+	}
+	res, shared, err := coalesce(dm, c, func(ctx context.Context) (getOneResult, error) {
+		start := time.Now()
+		res, err := lookup(ctx, readTarget{adapter, tableConfig})
+		shadowRead(dm, ctx, dbName, tableConfig, "get",
+			func() getOneResult { return getOneResult{copyRecord(res.record), res.matchedKey} }, err, time.Since(start),
+			lookup, func(primary, shadow getOneResult) string { return diffShadowRecord(primary.record, shadow.record) })
+		return res, err
+	})
+	record, matchedKey := res.record, res.matchedKey
+	if shared {
+		record = copyRecord(record)
 	}
-	record, err := adapter.GetOne(c.Request.Context(), tableConfig, filter, fields)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
+		if errors.Is(err, errNoIdentifiableKey) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No identifiable key (primary or unique) configured for table"})
+		} else if errors.Is(err, errAmbiguousRecord) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("More than one record matches %v, add filters to narrow the lookup", tableConfig.apiFieldNames(parseKeyFields(matchedKey)))})
+		} else if isNotFoundErr(err) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get record: " + err.Error()})
 		}
 		return
 	}
+	dm.applyLabelJoins(c.Request.Context(), dbName, tableConfig, record)
+	dm.applyValueLabels(c, dbName, tableConfig, record)
+	if !dm.applyCrossLookups(c, tableConfig, lookups, []map[string]interface{}{record}) {
+		return
+	}
+	if !dm.applyRelations(c, dbName, adapter, tableConfig, expand, []map[string]interface{}{record}) {
+		return
+	}
+	for _, col := range relationAdded {
+		delete(record, col)
+	}
 	record = fixPkFieldToString(record, tableConfig.PrimaryKey).(map[string]interface{})
-	c.JSON(http.StatusOK, record)
+	c.Header(headerMatchedKey, strings.Join(tableConfig.apiFieldNames(parseKeyFields(matchedKey)), ","))
+	tableConfig.setVersionETag(c, record)
+	c.JSON(http.StatusOK, tableConfig.renderRecord(record))
 }
 
 func (dm *databaseManager) handleUpdateOne(c *gin.Context) {
+	dm.updateOne(c, false)
+}
+
+// updateOne 按 :id 更新单条记录，partial 为 PATCH 语义（见 patch.go）
+func (dm *databaseManager) updateOne(c *gin.Context, partial bool) {
 	dbName := c.Param("database")
 	tableAlias := c.Param("table")
 	idValStr := c.Param("id")
@@ -1030,11 +1968,19 @@ func (dm *databaseManager) handleUpdateOne(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	keyFields := parseKeyFields(keyFieldParam)
+	if !requireConcurrencySupport(c, adapter, tableConfig) {
+		return
+	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	keyFields := tableConfig.physicalFieldNames(parseKeyFields(keyFieldParam))
 	var filter map[string]interface{}
 	if len(keyFields) > 0 {
 		if !tableConfig.IsValidKeyCombination(keyFields) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", keyFields)})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", tableConfig.apiFieldNames(keyFields))})
 			return
 		}
 		vals := parseStringList(idValStr)
@@ -1053,30 +1999,81 @@ func (dm *databaseManager) handleUpdateOne(c *gin.Context) {
 		}
 		filter = map[string]interface{}{tableConfig.PrimaryKey: idValStr}
 	}
+	representation, err := wantsRepresentation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload: " + err.Error()})
 		return
 	}
+	if updateData, err = tableConfig.inputRecord(updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if partial && !dm.checkPatchFields(c, tableConfig, updateData) {
+		return
+	}
 	// 移除所有filter字段
 	for k := range filter {
 		delete(updateData, k)
 	}
+	tableConfig.ifMatchVersion(c, updateData)
+	if err := tableConfig.scopeRecords(c.Request.Context(), []map[string]interface{}{updateData}, false); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.validateUpdate(updateData); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	keys := []lookupKey{filterLookup(filter)}
+	if err := tableConfig.guardImmutable(c.Request.Context(), adapter, []map[string]interface{}{updateData}, keys); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.guardTransitions(c.Request.Context(), adapter, []map[string]interface{}{updateData}, keys); err != nil {
+		writeGuardError(c, err)
+		return
+	}
 	if len(updateData) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update in payload"})
 		return
 	}
 	applyAutoUpdateFields(updateData, tableConfig)
-	matchedCount, modifiedCount, err := adapter.UpdateOne(c.Request.Context(), tableConfig, filter, updateData)
+	applyAutoActorFields(updateData, tableConfig, dm.currentActor(c), false)
+	var matchedCount, modifiedCount int64
+	var updated map[string]interface{}
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		matchedCount, modifiedCount, err = a.UpdateOne(c.Request.Context(), tableConfig, filter, updateData)
+		if err == nil && representation && matchedCount > 0 {
+			updated, err = a.GetOne(c.Request.Context(), tableConfig, filter, tableConfig.physicalFieldList(c.Query(queryParamFields)))
+		}
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found to update"})
+		} else if isConcurrencyError(err) {
+			writeConcurrencyError(c, tableConfig, err)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update record: " + err.Error()})
 		}
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Update successful", "matched_count": matchedCount, "modified_count": modifiedCount})
+	var resp gin.H
+	if dryRun {
+		resp = gin.H{"dry_run": true, "executed": executed, "matched_count": matchedCount, "modified_count": modifiedCount}
+	} else {
+		resp = gin.H{"message": "Update successful", "matched_count": matchedCount, "modified_count": modifiedCount}
+	}
+	if updated != nil {
+		resp["data"] = tableConfig.renderRecord(fixPkFieldToString(updated, tableConfig.PrimaryKey).(map[string]interface{}))
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (dm *databaseManager) handleDeleteOne(c *gin.Context) {
@@ -1089,11 +2086,16 @@ func (dm *databaseManager) handleDeleteOne(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	keyFields := parseKeyFields(keyFieldParam)
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	keyFields := tableConfig.physicalFieldNames(parseKeyFields(keyFieldParam))
 	var filter map[string]interface{}
 	if len(keyFields) > 0 {
 		if !tableConfig.IsValidKeyCombination(keyFields) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", keyFields)})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Key combination '%v' is not a configured unique key", tableConfig.apiFieldNames(keyFields))})
 			return
 		}
 		vals := parseStringList(idValStr)
@@ -1112,7 +2114,12 @@ func (dm *databaseManager) handleDeleteOne(c *gin.Context) {
 		}
 		filter = map[string]interface{}{tableConfig.PrimaryKey: idValStr}
 	}
-	affectedCount, err := adapter.DeleteOne(c.Request.Context(), tableConfig, filter)
+	var affectedCount int64
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		affectedCount, err = a.DeleteOne(c.Request.Context(), tableConfig, filter)
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found to delete"})
@@ -1121,33 +2128,29 @@ func (dm *databaseManager) handleDeleteOne(c *gin.Context) {
 		}
 		return
 	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "executed": executed, "deleted_count": affectedCount})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Delete successful", "deleted_count": affectedCount})
 }
 
 // --------- GORM Adapter 实现 ---------
 
-type gormAdapter struct {
-	db     *gorm.DB
-	config *databaseConfig
-}
-
-func newGormAdapter(db *gorm.DB, cfg *databaseConfig) *gormAdapter {
-	return &gormAdapter{db: db, config: cfg}
-}
-
-func (a *gormAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
-	var results []map[string]interface{}
-	var total int64
-	db := a.db.WithContext(ctx).Table(tc.Name)
-	db = applyGormSoftDeleteFilter(db, tc)
+// applyGormQueryFilters 把 URL 查询参数中的过滤条件（字段__操作符=值）转换为 Where 条件
+func applyGormQueryFilters(db *gorm.DB, filters url.Values) (*gorm.DB, bool) {
 	hasFilter := false
-	for key, values := range params.QueryFilters {
-		if key == queryParamPage || key == queryParamPageSize || key == queryParamFields || key == queryParamOrder {
+	for key, values := range filters {
+		if isReservedQueryParam(key) {
 			continue
 		}
 		if len(values) == 0 {
 			continue
 		}
+		if key == queryParamOr {
+			db, hasFilter = applyGormOrFilters(db, values), true
+			continue
+		}
 		value := values[0]
 		hasFilter = true
 		var fieldName, op string
@@ -1163,7 +2166,7 @@ func (a *gormAdapter) List(ctx context.Context, tc *tableConfig, params listPara
 		stringVals := parseStringList(value)
 		parsedVals := parseFilterValues(value)
 		switch op {
-		case "=":
+		case "=", "__eq":
 			db = db.Where(fmt.Sprintf("%s = ?", fieldName), parsedVal)
 		case "__gte":
 			db = db.Where(fmt.Sprintf("%s >= ?", fieldName), parsedVal)
@@ -1196,30 +2199,74 @@ func (a *gormAdapter) List(ctx context.Context, tc *tableConfig, params listPara
 		default:
 		}
 	}
-	if hasFilter {
-		if err := db.Count(&total).Error; err != nil {
-			return nil, 0, fmt.Errorf("failed to count records: %w", err)
+	return db, hasFilter
+}
+
+type gormAdapter struct {
+	db     *gorm.DB
+	config *databaseConfig
+}
+
+func newGormAdapter(db *gorm.DB, cfg *databaseConfig) *gormAdapter {
+	return &gormAdapter{db: db, config: cfg}
+}
+
+func (a *gormAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	var results []map[string]interface{}
+	var total int64
+	err := a.read(ctx, func(db *gorm.DB) error {
+		db, hasFilter := a.listQuery(db, tc, params)
+		if !params.SkipCount && (hasFilter || includeDeleted(ctx) || rowFiltered(ctx, tc)) {
+			if err := db.Count(&total).Error; err != nil {
+				return fmt.Errorf("failed to count records: %w", err)
+			}
+		}
+		db = a.listOrder(db, tc, params)
+		if params.Fields != "" {
+			db = db.Select(params.Fields)
 		}
+		offset := (params.Page - 1) * params.PageSize
+		if params.Keyset != nil {
+			offset = 0
+		}
+		if err := db.Offset(offset).Limit(params.PageSize).Find(&results).Error; err != nil {
+			return fmt.Errorf("failed to query database: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, total, err
+	}
+	return results, total, nil
+}
+
+// listQuery 列表查询的表、scopes、软删除与过滤条件，不含排序与分页
+func (a *gormAdapter) listQuery(db *gorm.DB, tc *tableConfig, params listParams) (*gorm.DB, bool) {
+	db = a.applyListScopes(db.Table(tc.Name), tc)
+	db = applyGormSoftDeleteFilter(db, tc)
+	return applyGormQueryFilters(db, params.QueryFilters)
+}
+
+// listOrder 列表查询的排序，游标分页时按游标列排序
+func (a *gormAdapter) listOrder(db *gorm.DB, tc *tableConfig, params listParams) *gorm.DB {
+	if params.Keyset != nil {
+		return applyGormKeyset(db, params.Keyset)
 	}
 	if params.Order != "" {
 		if strings.HasPrefix(params.Order, "-") {
-			db = db.Order(fmt.Sprintf("%s DESC", params.Order[1:]))
-		} else {
-			db = db.Order(fmt.Sprintf("%s ASC", params.Order))
+			return db.Order(fmt.Sprintf("%s DESC", params.Order[1:]))
 		}
+		return db.Order(fmt.Sprintf("%s ASC", params.Order))
 	}
-	if params.Fields != "" {
-		db = db.Select(params.Fields)
-	}
-	offset := (params.Page - 1) * params.PageSize
-	if err := db.Offset(offset).Limit(params.PageSize).Find(&results).Error; err != nil {
-		return nil, total, fmt.Errorf("failed to query database: %w", err)
+	if tc.PrimaryKey != "" && a.db.Dialector.Name() == "snowflake" {
+		// Snowflake 无 ORDER BY 时分页结果不稳定
+		return db.Order(fmt.Sprintf("%s ASC", tc.PrimaryKey))
 	}
-	return results, total, nil
+	return db
 }
 
 func (a *gormAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
-	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
 		if err_create := tx.Table(tc.Name).Create(&records).Error; err_create != nil {
 			return err_create
 		}
@@ -1234,7 +2281,8 @@ func (a *gormAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records
 func (a *gormAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
 	var totalAffected int64 = 0
 	pkField := tc.PrimaryKey
-	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		totalAffected = 0
 		for _, record := range records {
 			idVal, ok := record[pkField]
 			if !ok {
@@ -1249,10 +2297,19 @@ func (a *gormAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records
 			if len(updateData) == 0 {
 				continue
 			}
-			res := tx.Table(tc.Name).Where(fmt.Sprintf("%s = ?", pkField), idVal).Updates(updateData)
+			updateData, cond, err := tc.concurrencyCondition(updateData)
+			if err != nil {
+				return err
+			}
+			res := gormWhereEquals(tx.Table(tc.Name).Where(fmt.Sprintf("%s = ?", pkField), idVal), cond).Updates(updateData)
 			if res.Error != nil {
 				return res.Error
 			}
+			if res.RowsAffected == 0 && len(cond) > 0 {
+				if err := gormCheckConflict(tx, tc, map[string]interface{}{pkField: idVal}, cond); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			}
 			totalAffected += res.RowsAffected
 		}
 		return nil
@@ -1263,7 +2320,7 @@ func (a *gormAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records
 func (a *gormAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
 	var affectedRows int64 = 0
 	pkField := tc.PrimaryKey
-	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
 		targetDB := tx.Table(tc.Name).Where(fmt.Sprintf("%s IN (?)", pkField), ids)
 		var res *gorm.DB
 		if tc.SoftDeleteKey != "" {
@@ -1293,44 +2350,42 @@ func (a *gormAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []in
 
 func (a *gormAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
 	var result map[string]interface{}
-	db := a.db.WithContext(ctx).Table(tc.Name)
-	db = applyGormSoftDeleteFilter(db, tc)
-	if fields != "" {
-		db = db.Select(fields)
-	}
-	for k, v := range filter {
-		db = db.Where(fmt.Sprintf("%s = ?", k), v)
-	}
-	err := db.Take(&result).Error
+	err := a.read(ctx, func(db *gorm.DB) error {
+		db = applyGormSoftDeleteFilter(db.Table(tc.Name), tc)
+		if fields != "" {
+			db = db.Select(fields)
+		}
+		for k, v := range filter {
+			if v == nil {
+				db = db.Where(fmt.Sprintf("%s IS NULL", k))
+				continue
+			}
+			db = db.Where(fmt.Sprintf("%s = ?", k), v)
+		}
+		return db.Take(&result).Error
+	})
 	return result, err
 }
 
 func (a *gormAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
 	var affectedRows int64 = 0
-	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	data, cond, err := tc.concurrencyCondition(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = a.transaction(ctx, func(tx *gorm.DB) error {
 		query := tx.Table(tc.Name)
 		query = applyGormSoftDeleteFilter(query, tc)
 		for k, v := range filter {
 			query = query.Where(fmt.Sprintf("%s = ?", k), v)
 		}
-		res := query.Updates(data)
+		res := gormWhereEquals(query, cond).Updates(data)
 		if res.Error != nil {
 			return res.Error
 		}
 		affectedRows = res.RowsAffected
 		if affectedRows == 0 {
-			var count int64
-			existQ := tx.Table(tc.Name)
-			for k, v := range filter {
-				existQ = existQ.Where(fmt.Sprintf("%s = ?", k), v)
-			}
-			errExists := existQ.Count(&count).Error
-			if errExists != nil {
-				return errExists
-			}
-			if count == 0 {
-				return gorm.ErrRecordNotFound
-			}
+			return gormCheckConflict(tx, tc, filter, cond)
 		}
 		return nil
 	})
@@ -1339,7 +2394,7 @@ func (a *gormAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map
 
 func (a *gormAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
 	var affectedRows int64 = 0
-	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
 		query := tx.Table(tc.Name)
 		for k, v := range filter {
 			query = query.Where(fmt.Sprintf("%s = ?", k), v)
@@ -1386,15 +2441,19 @@ func (a *gormAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map
 
 func (a *gormAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
 	var count int64
-	db := a.db.WithContext(ctx).Table(tc.Name)
-	db = applyGormSoftDeleteFilter(db, tc)
-	if err := db.Count(&count).Error; err != nil {
+	err := a.read(ctx, func(db *gorm.DB) error {
+		return applyGormSoftDeleteFilter(db.Table(tc.Name), tc).Count(&count).Error
+	})
+	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
 func (a *gormAdapter) Close() error {
+	if pool, ok := a.db.ConnPool.(*failoverPool); ok {
+		return pool.Close()
+	}
 	sqlDB, err := a.db.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get underlying sql.DB for GORM adapter: %w", err)
@@ -1404,28 +2463,20 @@ func (a *gormAdapter) Close() error {
 
 // --------- Mongo Adapter 实现 ---------
 
-type mongoAdapter struct {
-	client   *mongo.Client
-	database string
-	config   *databaseConfig
-}
-
-func newMongoAdapter(client *mongo.Client, dbName string, cfg *databaseConfig) *mongoAdapter {
-	return &mongoAdapter{client: client, database: dbName, config: cfg}
-}
-
-func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
-	filter := bson.M{}
-	filter = applyMongoSoftDeleteFilter(filter, tc)
+// buildMongoQueryFilter 把 URL 查询参数中的过滤条件合并进 filter
+func buildMongoQueryFilter(filter bson.M, filters url.Values) (bson.M, bool) {
 	isFiltered := false
-	for key, values := range params.QueryFilters {
-		if key == queryParamPage || key == queryParamPageSize || key == queryParamFields || key == queryParamOrder {
+	for key, values := range filters {
+		if isReservedQueryParam(key) {
 			continue
 		}
 		if len(values) == 0 {
 			continue
 		}
+		if key == queryParamOr {
+			filter, isFiltered = buildMongoOrFilters(filter, values), true
+			continue
+		}
 		value := values[0]
 		isFiltered = true
 		var fieldName, op string
@@ -1438,8 +2489,13 @@ func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listPar
 			op = "="
 		}
 		switch op {
-		case "=":
+		case "=", "__eq":
 			filter[fieldName] = parseFilterValue(value)
+			if fieldName == "_id" && len(value) == 24 {
+				if oid, err := primitive.ObjectIDFromHex(value); err == nil {
+					filter[fieldName] = oid
+				}
+			}
 		case "__gte":
 			filter[fieldName] = bson.M{"$gte": parseFilterValue(value)}
 		case "__lte":
@@ -1481,22 +2537,27 @@ func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listPar
 		default:
 		}
 	}
+	return filter, isFiltered
+}
+
+type mongoAdapter struct {
+	client   *mongo.Client
+	database string
+	config   *databaseConfig
+}
+
+func newMongoAdapter(client *mongo.Client, dbName string, cfg *databaseConfig) *mongoAdapter {
+	return &mongoAdapter{client: client, database: dbName, config: cfg}
+}
+
+func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	collection := a.collection(ctx, tc)
+	filter := bson.M{}
+	filter = applyMongoSoftDeleteFilter(ctx, filter, tc)
+	filter, isFiltered := buildMongoQueryFilter(filter, params.QueryFilters)
 	opts := options.Find()
 	if params.Order != "" {
-		sort := bson.D{}
-		fields := strings.Split(params.Order, ",")
-		for _, field := range fields {
-			field = strings.TrimSpace(field)
-			if field == "" {
-				continue
-			}
-			if strings.HasPrefix(field, "-") {
-				sort = append(sort, bson.E{Key: field[1:], Value: -1})
-			} else {
-				sort = append(sort, bson.E{Key: field, Value: 1})
-			}
-		}
-		opts.SetSort(sort)
+		opts.SetSort(mongoSort(params.Order))
 	}
 	if params.Fields != "" {
 		projection := bson.M{}
@@ -1506,6 +2567,12 @@ func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listPar
 		opts.SetProjection(projection)
 	}
 	skip := int64((params.Page - 1) * params.PageSize)
+	if params.Keyset != nil {
+		var sort bson.D
+		filter, sort = applyMongoKeyset(filter, params.Keyset)
+		opts.SetSort(sort)
+		skip = 0
+	}
 	opts.SetSkip(skip)
 	opts.SetLimit(int64(params.PageSize))
 	cur, err := collection.Find(ctx, filter, opts)
@@ -1522,7 +2589,7 @@ func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listPar
 		results = append(results, doc)
 	}
 	var total int64
-	if isFiltered {
+	if !params.SkipCount && (isFiltered || includeDeleted(ctx) || rowFiltered(ctx, tc)) {
 		total, err = collection.CountDocuments(ctx, filter)
 		if err != nil {
 			return nil, 0, err
@@ -1531,8 +2598,25 @@ func (a *mongoAdapter) List(ctx context.Context, tc *tableConfig, params listPar
 	return results, total, nil
 }
 
+// mongoSort 把逗号分隔的 order 参数转换为排序条件，- 前缀降序
+func mongoSort(order string) bson.D {
+	sort := bson.D{}
+	for _, field := range strings.Split(order, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			sort = append(sort, bson.E{Key: field[1:], Value: -1})
+		} else {
+			sort = append(sort, bson.E{Key: field, Value: 1})
+		}
+	}
+	return sort
+}
+
 func (a *mongoAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	docs := make([]interface{}, len(records))
 	for i, rec := range records {
 		docs[i] = rec
@@ -1545,7 +2629,7 @@ func (a *mongoAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records
 }
 
 func (a *mongoAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	var matched, modified int64
 	for _, record := range records {
 		idVal, ok := record[tc.PrimaryKey]
@@ -1568,11 +2652,20 @@ func (a *mongoAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records
 		if len(updateData) == 0 {
 			continue
 		}
-		filter := bson.M{tc.PrimaryKey: idVal}
-		res, err := collection.UpdateOne(ctx, filter, bson.M{"$set": updateData})
+		updateData, cond, err := tc.concurrencyCondition(updateData)
+		if err != nil {
+			return matched, modified, err
+		}
+		filter := applyMongoRowFilter(ctx, bson.M{tc.PrimaryKey: idVal}, tc)
+		res, err := collection.UpdateOne(ctx, withConcurrencyFilter(filter, cond), bson.M{"$set": updateData})
 		if err != nil {
 			return matched, modified, err
 		}
+		if res.MatchedCount == 0 && len(cond) > 0 {
+			if err := mongoCheckConflict(ctx, collection, filter, cond); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return matched, modified, err
+			}
+		}
 		matched += res.MatchedCount
 		modified += res.ModifiedCount
 	}
@@ -1580,7 +2673,7 @@ func (a *mongoAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records
 }
 
 func (a *mongoAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	convertedIds := make([]interface{}, 0, len(ids))
 	for _, id := range ids {
 		if tc.PrimaryKey == "_id" {
@@ -1592,7 +2685,7 @@ func (a *mongoAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []i
 		}
 		convertedIds = append(convertedIds, id)
 	}
-	filter := bson.M{tc.PrimaryKey: bson.M{"$in": convertedIds}}
+	filter := applyMongoRowFilter(ctx, bson.M{tc.PrimaryKey: bson.M{"$in": convertedIds}}, tc)
 	var res *mongo.UpdateResult
 	var err error
 	if tc.SoftDeleteKey != "" {
@@ -1621,7 +2714,7 @@ func (a *mongoAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []i
 }
 
 func (a *mongoAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	// mongo主键类型自动转换
 	if len(filter) == 1 {
 		for k, v := range filter {
@@ -1638,7 +2731,7 @@ func (a *mongoAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[s
 	for k, v := range filter {
 		filterBson[k] = v
 	}
-	filterBson = applyMongoSoftDeleteFilter(filterBson, tc)
+	filterBson = applyMongoSoftDeleteFilter(ctx, filterBson, tc)
 	opts := options.FindOne()
 	if fields != "" {
 		projection := bson.M{}
@@ -1653,7 +2746,7 @@ func (a *mongoAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[s
 }
 
 func (a *mongoAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	filterBson := bson.M{}
 	for k, v := range filter {
 		if k == "_id" {
@@ -1665,26 +2758,26 @@ func (a *mongoAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter ma
 		}
 		filterBson[k] = v
 	}
-	filterBson = applyMongoSoftDeleteFilter(filterBson, tc)
+	filterBson = applyMongoSoftDeleteFilter(ctx, filterBson, tc)
+	data, cond, err := tc.concurrencyCondition(data)
+	if err != nil {
+		return 0, 0, err
+	}
 	update := bson.M{"$set": data}
-	res, err := collection.UpdateOne(ctx, filterBson, update)
+	res, err := collection.UpdateOne(ctx, withConcurrencyFilter(filterBson, cond), update)
 	if err != nil {
 		return 0, 0, err
 	}
 	if res.MatchedCount == 0 {
-		count, err := collection.CountDocuments(ctx, filterBson)
-		if err != nil {
+		if err := mongoCheckConflict(ctx, collection, filterBson, cond); err != nil {
 			return 0, 0, err
 		}
-		if count == 0 {
-			return 0, 0, mongo.ErrNoDocuments
-		}
 	}
 	return res.MatchedCount, res.ModifiedCount, nil
 }
 
 func (a *mongoAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	filterBson := bson.M{}
 	for k, v := range filter {
 		if k == "_id" {
@@ -1696,6 +2789,7 @@ func (a *mongoAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter ma
 		}
 		filterBson[k] = v
 	}
+	filterBson = applyMongoRowFilter(ctx, filterBson, tc)
 	var res *mongo.UpdateResult
 	var err error
 	if tc.SoftDeleteKey != "" {
@@ -1742,9 +2836,9 @@ func (a *mongoAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter ma
 }
 
 func (a *mongoAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
-	collection := a.client.Database(a.database).Collection(tc.Name)
+	collection := a.collection(ctx, tc)
 	filter := bson.M{}
-	filter = applyMongoSoftDeleteFilter(filter, tc)
+	filter = applyMongoSoftDeleteFilter(ctx, filter, tc)
 	return collection.CountDocuments(ctx, filter)
 }
 
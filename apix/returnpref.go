@@ -0,0 +1,69 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 更新后返回记录 ---------
+//
+// PUT /:database/:table/:id 与 PUT /:database/:table 支持 ?return=representation
+// （或请求头 Prefer: return=representation），在响应的 data 中返回更新后的记录，
+// 可配合 fields 只取部分字段。试运行时返回回滚前事务内看到的记录。
+
+const (
+	queryParamReturn     = "return"
+	returnRepresentation = "representation"
+	returnMinimal        = "minimal"
+
+	headerPrefer            = "Prefer"
+	headerPreferenceApplied = "Preference-Applied"
+)
+
+// preference 读取查询参数 name，未传时读取 Prefer 请求头中的同名项
+func preference(c *gin.Context, name string) string {
+	if v := c.Query(name); v != "" {
+		return v
+	}
+	for _, pref := range strings.Split(c.GetHeader(headerPrefer), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(pref), "="); ok && strings.EqualFold(k, name) {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// wantsRepresentation 解析 return 参数，未传时读取 Prefer 请求头
+func wantsRepresentation(c *gin.Context) (bool, error) {
+	v := preference(c, queryParamReturn)
+	switch v {
+	case "", returnMinimal:
+		return false, nil
+	case returnRepresentation:
+		c.Header(headerPreferenceApplied, queryParamReturn+"="+returnRepresentation)
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid %s: %s, expected %s or %s", queryParamReturn, v, returnRepresentation, returnMinimal)
+}
+
+// updatedRecords 按主键取回批量更新后的记录，顺序与 records 一致，未找到的位置为 nil
+func updatedRecords(ctx context.Context, adapter databaseAdapter, tc *tableConfig, records []map[string]interface{}, fields string) ([]interface{}, error) {
+	keys := make([]lookupKey, len(records))
+	for i, r := range records {
+		keys[i] = lookupKey{Fields: []string{tc.PrimaryKey}, Values: []interface{}{r[tc.PrimaryKey]}}
+	}
+	results, err := batchGetRecords(ctx, adapter, tc, keys, fields)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]interface{}, len(results))
+	for i, r := range results {
+		if r != nil {
+			data[i] = tc.renderRecord(fixPkFieldToString(r, tc.PrimaryKey).(map[string]interface{}))
+		}
+	}
+	return data, nil
+}
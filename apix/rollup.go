@@ -0,0 +1,225 @@
+package apix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- 汇总表 ---------
+//
+// 在表配置中声明 rollup，由后台按计划从源表聚合重建，适用于不支持物化视图的数据库（SQL 库）：
+//
+//	name: user_by_age
+//	alias: user_by_age
+//	rollup:
+//	  source: user                       # 同库源表（物理表名），源表配置了软删除时自动排除已删除记录
+//	  group_by: {age: age}               # 结果列 → 分组表达式
+//	  aggregates: {users: "count(*)", last_signup: "max(created_time)"}
+//	  where: "age IS NOT NULL"           # 可选过滤条件
+//	  schedule: "0 */10 * * * *"         # 刷新计划（秒级 cron），为空时只能手动刷新
+//
+// 汇总表不存在时启动后立即生成；刷新在事务中清空后重新写入。
+// 汇总表通过同样的接口只读访问，写请求返回 405。
+//
+// 管理接口（受 operation_roles.rollup 控制，默认 admin）：
+//
+//	POST /api/admin/rollup?database=test&table=user_by_age   提交刷新任务，返回任务 ID
+
+const jobTypeRollup = "rollup"
+
+type rollupConfig struct {
+	Source     string            `mapstructure:"source" yaml:"source"`
+	GroupBy    map[string]string `mapstructure:"group_by" yaml:"group_by"`
+	Aggregates map[string]string `mapstructure:"aggregates" yaml:"aggregates"`
+	Where      string            `mapstructure:"where" yaml:"where"`
+	Schedule   string            `mapstructure:"schedule" yaml:"schedule"`
+}
+
+type rollupJobPayload struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+func (tc *tableConfig) isRollup() bool {
+	return tc.Rollup.Source != ""
+}
+
+// rollupColumns 返回汇总表的列（分组列在前，各自按名称排序）及对应表达式
+func (r rollupConfig) rollupColumns() ([]string, []string) {
+	var cols, exprs []string
+	for _, m := range []map[string]string{r.GroupBy, r.Aggregates} {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cols = append(cols, name)
+			exprs = append(exprs, m[name])
+		}
+	}
+	return cols, exprs
+}
+
+// setupRollups 校验汇总表配置并创建定时刷新，首次刷新见 enqueueMissingRollups
+func (dm *databaseManager) setupRollups() error {
+	var s *utils.Scheduler
+	for name, dbCfg := range dm.config.Databases {
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if !tc.isRollup() {
+				continue
+			}
+			if _, ok := dm.adapters[name].(*gormAdapter); !ok {
+				return fmt.Errorf("rollup table %s: only supported on unsharded SQL databases", tc.Alias)
+			}
+			if len(tc.Rollup.Aggregates) == 0 {
+				return fmt.Errorf("rollup table %s: aggregates is required", tc.Alias)
+			}
+			if tc.Rollup.Schedule == "" {
+				continue
+			}
+			if s == nil {
+				s = utils.NewScheduler()
+			}
+			payload := rollupJobPayload{Database: name, Table: tc.Alias}
+			err := s.AddJob(name+"."+tc.Alias, tc.Rollup.Schedule, func() {
+				if _, err := dm.jobQueue.Enqueue(jobTypeRollup, payload, utils.EnqueueOptions{}); err != nil {
+					log.Printf("enqueue rollup job for %s.%s failed: %v", payload.Database, payload.Table, err)
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("invalid rollup schedule for %s: %w", tc.Alias, err)
+			}
+		}
+	}
+	if s != nil {
+		dm.schedulers = append(dm.schedulers, s)
+	}
+	return nil
+}
+
+// enqueueMissingRollups 为尚未创建的汇总表提交首次刷新
+func (dm *databaseManager) enqueueMissingRollups() {
+	for name, dbCfg := range dm.config.Databases {
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			a, ok := dm.adapters[name].(*gormAdapter)
+			if !ok || !tc.isRollup() || a.db.Migrator().HasTable(tc.Name) {
+				continue
+			}
+			payload := rollupJobPayload{Database: name, Table: tc.Alias}
+			if _, err := dm.jobQueue.Enqueue(jobTypeRollup, payload, utils.EnqueueOptions{}); err != nil {
+				log.Printf("enqueue rollup job for %s.%s failed: %v", name, tc.Alias, err)
+			}
+		}
+	}
+}
+
+func (dm *databaseManager) runRollupJob(ctx context.Context, job utils.Job) error {
+	var payload rollupJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid rollup job payload: %w", err)
+	}
+	adapter, tc, err := dm.getAdapterAndTableConfig(payload.Database, payload.Table)
+	if err != nil {
+		return err
+	}
+	a, ok := adapter.(*gormAdapter)
+	if !ok || !tc.isRollup() {
+		return fmt.Errorf("%s.%s is not a rollup table", payload.Database, payload.Table)
+	}
+	var source *tableConfig
+	for i, t := range dm.config.Databases[payload.Database].Tables {
+		if t.Name == tc.Rollup.Source {
+			source = &dm.config.Databases[payload.Database].Tables[i]
+			break
+		}
+	}
+	rows, err := a.refreshRollup(ctx, tc, source)
+	if err != nil {
+		return err
+	}
+	return dm.jobQueue.SetProgress(job.ID, gin.H{"rows": rows})
+}
+
+// refreshRollup 重建汇总表，返回写入的行数；source 为源表配置（可能为 nil）
+func (a *gormAdapter) refreshRollup(ctx context.Context, tc *tableConfig, source *tableConfig) (int64, error) {
+	r := tc.Rollup
+	db := a.db.WithContext(ctx)
+	stmt := db.Session(&gorm.Session{NewDB: true}).Statement
+	cols, exprs := r.rollupColumns()
+	selects := make([]string, len(cols))
+	quotedCols := make([]string, len(cols))
+	for i, col := range cols {
+		quotedCols[i] = stmt.Quote(col)
+		selects[i] = exprs[i] + " AS " + quotedCols[i]
+	}
+	query := func(tx *gorm.DB) *gorm.DB {
+		q := tx.Session(&gorm.Session{NewDB: true}).Table(r.Source).Select(strings.Join(selects, ", "))
+		if source != nil {
+			q = applyGormSoftDeleteFilter(q, source)
+		}
+		if r.Where != "" {
+			q = q.Where(r.Where)
+		}
+		if len(r.GroupBy) > 0 {
+			q = q.Group(strings.Join(exprs[:len(r.GroupBy)], ", "))
+		}
+		return q
+	}
+	table := stmt.Quote(tc.Name)
+	if !db.Migrator().HasTable(tc.Name) {
+		var sql string
+		if db.Dialector.Name() == "sqlserver" {
+			sql = fmt.Sprintf("SELECT * INTO %s FROM (?) AS %s", table, stmt.Quote("rollup_src"))
+		} else {
+			sql = fmt.Sprintf("CREATE TABLE %s AS ?", table)
+		}
+		res := db.Exec(sql, query(db))
+		return res.RowsAffected, res.Error
+	}
+	var rows int64
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table)).Error; err != nil {
+			return err
+		}
+		res := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) ?", table, strings.Join(quotedCols, ", ")), query(tx))
+		rows = res.RowsAffected
+		return res.Error
+	})
+	return rows, err
+}
+
+func (dm *databaseManager) handleRollupRefresh(c *gin.Context) {
+	if !dm.authorize(c, nil, opRollup) {
+		return
+	}
+	payload := rollupJobPayload{Database: c.Query("database"), Table: c.Query("table")}
+	_, tc, err := dm.getAdapterAndTableConfig(payload.Database, payload.Table)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !tc.isRollup() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": payload.Table + " is not a rollup table"})
+		return
+	}
+	job, err := dm.jobQueue.Enqueue(jobTypeRollup, payload, utils.EnqueueOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", dm.jobsPrefix+"/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,357 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 按 claims 限定可访问的行 ---------
+//
+// 表配置中的 row_filter 把 JWT（见 jwtauth.go）中的 claim 注入该表的每一次查询、更新与删除：
+//
+//	row_filter: "tenant_id = {{claims.tid}}"
+//	row_filter: "org_id in {{claims.orgs}} and level <= {{claims.level}}"
+//
+// 条件之间用 and 连接，运算符为 = != > >= < <= in，值为 {{claims.路径}} 或字面量（数字、true/false、带引号的字符串）。
+// claim 的值作为参数绑定，不会拼接进 SQL。SQL 库通过 gorm 回调注入条件（含关联展开、聚合、统计等经过 gorm 的查询），
+// MongoDB 与软删除条件一起加入过滤；其他类型的库不支持 row_filter，启动时报错。
+//
+// 创建时 = 条件的字段未提供则填入 claim 的值（优先于 default_values），提供的值与 claim 不一致（in 条件不在列表中）时返回 403；
+// 更新时同样不能把这些字段改为范围之外的值。请求中缺少所需 claim 时返回 403。
+// 配置了 row_filter 的表不支持 upsert；表总数缓存不用于这些表的列表查询。
+// 后台任务（批量任务沿用提交时的 claims）之外的内部维护任务（归档、汇总、计数）不受限制。
+
+var rowConditionRe = regexp.MustCompile(`(?i)^(\w+)\s*(!=|>=|<=|=|>|<|\s+in\s+)\s*(.+)$`)
+var rowClaimRe = regexp.MustCompile(`^\{\{\s*claims\.([\w.]+)\s*\}\}$`)
+var rowAndRe = regexp.MustCompile(`(?i)\s+and\s+`)
+
+type rowCondition struct {
+	Field string
+	Op    string
+	Claim string      // claim 路径，为空时使用 Value
+	Value interface{} // 字面量
+}
+
+// rowFilterError 请求不满足 row_filter（缺少 claim 或写入范围之外的值）
+type rowFilterError struct {
+	Message string
+}
+
+func (e *rowFilterError) Error() string {
+	return e.Message
+}
+
+// compileRowFilter 解析表配置中的 row_filter
+func compileRowFilter(tc *tableConfig) error {
+	tc.rowFilter = nil
+	if strings.TrimSpace(tc.RowFilter) == "" {
+		return nil
+	}
+	for _, part := range rowAndRe.Split(strings.TrimSpace(tc.RowFilter), -1) {
+		m := rowConditionRe.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return fmt.Errorf("table %s: invalid row_filter condition %q, expected \"field op value\"", tc.Name, part)
+		}
+		cond := rowCondition{Field: m[1], Op: strings.ToLower(strings.TrimSpace(m[2]))}
+		value := strings.TrimSpace(m[3])
+		if cm := rowClaimRe.FindStringSubmatch(value); cm != nil {
+			cond.Claim = cm[1]
+		} else if cond.Op == "in" {
+			return fmt.Errorf("table %s: row_filter condition %q: in requires a claim", tc.Name, part)
+		} else {
+			cond.Value = rowLiteral(value)
+		}
+		tc.rowFilter = append(tc.rowFilter, cond)
+	}
+	return nil
+}
+
+func rowLiteral(s string) interface{} {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+// ---- 请求范围 ----
+
+// rowScopeKey 标记请求来自外部调用者：带有该标记的查询按 row_filter 限定，内部任务不带标记
+type rowScopeKey struct{}
+
+type rowScope struct {
+	claims map[string]interface{}
+}
+
+func withRowScope(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, rowScopeKey{}, &rowScope{claims: claims})
+}
+
+func requestRowScope(ctx context.Context) *rowScope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(rowScopeKey{}).(*rowScope)
+	return s
+}
+
+// rowFiltered 当前请求是否按 row_filter 限定该表
+func rowFiltered(ctx context.Context, tc *tableConfig) bool {
+	return len(tc.rowFilter) > 0 && requestRowScope(ctx) != nil
+}
+
+// resolve 按 claims 计算条件的值，缺少 claim 时返回 rowFilterError
+func (s *rowScope) resolve(tc *tableConfig) ([]rowCondition, error) {
+	conds := make([]rowCondition, len(tc.rowFilter))
+	for i, cond := range tc.rowFilter {
+		if cond.Claim != "" {
+			v, ok := claimValue(s.claims, cond.Claim)
+			if !ok || v == nil {
+				return nil, &rowFilterError{Message: "Access to " + tc.Alias + " requires claim '" + cond.Claim + "'"}
+			}
+			v = normalizeClaimValue(v)
+			_, isList := v.([]interface{})
+			switch {
+			case cond.Op == "in" && !isList:
+				v = []interface{}{v}
+			case cond.Op != "in" && isList:
+				return nil, &rowFilterError{Message: "claim '" + cond.Claim + "' must be a single value"}
+			}
+			cond.Value = v
+		}
+		conds[i] = cond
+	}
+	return conds, nil
+}
+
+// normalizeClaimValue JSON 中的整数解析为 float64，转回 int64 以便与整数列比较
+func normalizeClaimValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n)
+		}
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			out[i] = normalizeClaimValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// withRowFilter 为 REST 请求标记调用者范围；表配置了 row_filter 而缺少所需 claim 时返回 403
+func (dm *databaseManager) withRowFilter(c *gin.Context) {
+	ctx := withRowScope(c.Request.Context(), requestClaims(c.Request.Context()))
+	c.Request = c.Request.WithContext(ctx)
+	_, tc, err := dm.getAdapterAndTableConfig(c.Param("database"), c.Param("table"))
+	if err == nil && len(tc.rowFilter) > 0 {
+		if _, err := requestRowScope(ctx).resolve(tc); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.Next()
+}
+
+// graphqlRowScope GraphQL 直连查询校验转发的 API Key 或 token、角色授权并标记调用者范围，
+// 与 REST 的 withAPIKey、withJWT、withRBAC、withRowFilter 一致
+func (dm *databaseManager) graphqlRowScope(ctx context.Context, dbName string, tc *tableConfig) (context.Context, error) {
+	ctx, key, err := dm.graphqlAPIKey(ctx, dbName, tc)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if ctx, err = dm.graphqlClaims(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := dm.rbac.check(dm.graphqlPrincipal(ctx, key), http.MethodGet, dbName, tc.Alias); err != nil {
+		return nil, err
+	}
+	ctx = withRowScope(ctx, requestClaims(ctx))
+	if len(tc.rowFilter) > 0 {
+		if _, err := requestRowScope(ctx).resolve(tc); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// scopeRecords 按 row_filter 检查待写入的记录；create 时为缺少的 = 条件字段填入 claim 的值
+func (tc *tableConfig) scopeRecords(ctx context.Context, records []map[string]interface{}, create bool) error {
+	if !rowFiltered(ctx, tc) {
+		return nil
+	}
+	conds, err := requestRowScope(ctx).resolve(tc)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		for _, cond := range conds {
+			v, present := rec[cond.Field]
+			switch {
+			case !present && create && cond.Op == "=":
+				rec[cond.Field] = cond.Value
+			case !present:
+			case cond.Op == "=" && keyValueString(v) != keyValueString(cond.Value):
+				return &rowFilterError{Message: fmt.Sprintf("Field '%s' must be %v", tc.apiFieldName(cond.Field), cond.Value)}
+			case cond.Op == "in" && !rowValueIn(v, cond.Value.([]interface{})):
+				return &rowFilterError{Message: fmt.Sprintf("Field '%s' is outside the allowed values", tc.apiFieldName(cond.Field))}
+			}
+		}
+	}
+	return nil
+}
+
+func rowValueIn(v interface{}, list []interface{}) bool {
+	s := keyValueString(v)
+	for _, item := range list {
+		if keyValueString(item) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ---- gorm ----
+
+// setupRowFilters 为配置了 row_filter 的库注册 gorm 回调；不支持的库类型返回错误
+func (dm *databaseManager) setupRowFilters() error {
+	for name, dbCfg := range dm.config.Databases {
+		filtered := false
+		for i := range dbCfg.Tables {
+			if len(dbCfg.Tables[i].rowFilter) > 0 {
+				filtered = true
+				break
+			}
+		}
+		if !filtered {
+			continue
+		}
+		var dbs []*gorm.DB
+		switch a := dm.adapters[name].(type) {
+		case *gormAdapter:
+			dbs = append(dbs, a.db)
+		case *shardedAdapter:
+			for _, shard := range a.shards {
+				dbs = append(dbs, shard.db)
+			}
+		case *mongoAdapter:
+		default:
+			return fmt.Errorf("row_filter is not supported by database %s (%s)", name, dbCfg.Type)
+		}
+		for _, db := range dbs {
+			if err := dm.registerRowFilter(name, db); err != nil {
+				return fmt.Errorf("failed to register row_filter for %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rowFilterTable 按语句的表名找到表配置；历史影子表沿用原表的 row_filter
+func rowFilterTable(tables []tableConfig, table string) *tableConfig {
+	for i := range tables {
+		if tables[i].Name == table || tables[i].History.Enabled && tables[i].historyTable() == table {
+			return &tables[i]
+		}
+	}
+	return nil
+}
+
+func (dm *databaseManager) registerRowFilter(dbName string, db *gorm.DB) error {
+	apply := func(db *gorm.DB) {
+		scope := requestRowScope(db.Statement.Context)
+		if scope == nil || db.Statement.Table == "" {
+			return
+		}
+		dm.mutex.RLock()
+		tables := dm.config.Databases[dbName].Tables
+		dm.mutex.RUnlock()
+		tc := rowFilterTable(tables, db.Statement.Table)
+		if tc == nil || len(tc.rowFilter) == 0 {
+			return
+		}
+		conds, err := scope.resolve(tc)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		exprs := make([]clause.Expression, len(conds))
+		for i, cond := range conds {
+			col := clause.Column{Name: cond.Field}
+			switch cond.Op {
+			case "=":
+				exprs[i] = clause.Eq{Column: col, Value: cond.Value}
+			case "!=":
+				exprs[i] = clause.Neq{Column: col, Value: cond.Value}
+			case ">":
+				exprs[i] = clause.Gt{Column: col, Value: cond.Value}
+			case ">=":
+				exprs[i] = clause.Gte{Column: col, Value: cond.Value}
+			case "<":
+				exprs[i] = clause.Lt{Column: col, Value: cond.Value}
+			case "<=":
+				exprs[i] = clause.Lte{Column: col, Value: cond.Value}
+			case "in":
+				exprs[i] = clause.IN{Column: col, Values: cond.Value.([]interface{})}
+			}
+		}
+		db.Statement.AddClause(clause.Where{Exprs: exprs})
+	}
+	// 排在所有回调之前：inflight 会提前生成语句，history、mirror 按语句条件读取受影响的行
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Before("*").Register("ego:row_filter", apply),
+		cb.Row().Before("*").Register("ego:row_filter", apply),
+		cb.Update().Before("*").Register("ego:row_filter", apply),
+		cb.Delete().Before("*").Register("ego:row_filter", apply),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---- Mongo ----
+
+var mongoRowOps = map[string]string{"=": "$eq", "!=": "$ne", ">": "$gt", ">=": "$gte", "<": "$lt", "<=": "$lte", "in": "$in"}
+
+// applyMongoRowFilter 在过滤条件上加上 row_filter；缺少 claim 时不匹配任何记录
+func applyMongoRowFilter(ctx context.Context, filter bson.M, tc *tableConfig) bson.M {
+	if !rowFiltered(ctx, tc) {
+		return filter
+	}
+	conds, err := requestRowScope(ctx).resolve(tc)
+	if err != nil {
+		return bson.M{"$expr": false}
+	}
+	scoped := make([]bson.M, 0, len(conds)+1)
+	if len(filter) > 0 {
+		scoped = append(scoped, filter)
+	}
+	for _, cond := range conds {
+		scoped = append(scoped, bson.M{cond.Field: bson.M{mongoRowOps[cond.Op]: cond.Value}})
+	}
+	return bson.M{"$and": scoped}
+}
@@ -0,0 +1,112 @@
+package apix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRowFilter(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter string
+		want   []rowCondition
+		err    bool
+	}{
+		{"empty", "  ", nil, false},
+		{"claim", "tenant_id = {{claims.tid}}", []rowCondition{{Field: "tenant_id", Op: "=", Claim: "tid"}}, false},
+		{"in and literal", "org_id IN {{ claims.org.ids }} AND level <= 3", []rowCondition{
+			{Field: "org_id", Op: "in", Claim: "org.ids"},
+			{Field: "level", Op: "<=", Value: int64(3)},
+		}, false},
+		{"literals", "name != 'root' and score > 1.5 and active = true", []rowCondition{
+			{Field: "name", Op: "!=", Value: "root"},
+			{Field: "score", Op: ">", Value: 1.5},
+			{Field: "active", Op: "=", Value: true},
+		}, false},
+		{"in literal", "org_id in (1,2)", nil, true},
+		{"missing operator", "tenant_id {{claims.tid}}", nil, true},
+		// 值作为参数绑定，不是 SQL 片段
+		{"expression as literal", "tenant_id = 1 or 1 = 1", []rowCondition{{Field: "tenant_id", Op: "=", Value: "1 or 1 = 1"}}, false},
+		{"bad field", "t.id = 1", nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tbl := &tableConfig{Name: "user", RowFilter: tc.filter}
+			err := compileRowFilter(tbl)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, tbl.rowFilter)
+		})
+	}
+}
+
+func TestRowScopeResolve(t *testing.T) {
+	tbl := &tableConfig{Name: "user", Alias: "user", RowFilter: "tenant_id = {{claims.tid}} and org_id in {{claims.org.ids}} and level < 5"}
+	assert.NoError(t, compileRowFilter(tbl))
+	cases := []struct {
+		name   string
+		claims map[string]interface{}
+		want   []interface{}
+		err    bool
+	}{
+		{"list claim", map[string]interface{}{"tid": float64(7), "org": map[string]interface{}{"ids": []interface{}{float64(1), "x"}}},
+			[]interface{}{int64(7), []interface{}{int64(1), "x"}, int64(5)}, false},
+		{"scalar for in", map[string]interface{}{"tid": "t1", "org": map[string]interface{}{"ids": 2.5}},
+			[]interface{}{"t1", []interface{}{2.5}, int64(5)}, false},
+		{"missing claim", map[string]interface{}{"org": map[string]interface{}{"ids": []interface{}{}}}, nil, true},
+		{"null claim", map[string]interface{}{"tid": nil, "org": map[string]interface{}{"ids": []interface{}{}}}, nil, true},
+		{"missing nested claim", map[string]interface{}{"tid": "t1", "org": "o1"}, nil, true},
+		{"list for equality", map[string]interface{}{"tid": []interface{}{"t1", "t2"}, "org": map[string]interface{}{"ids": []interface{}{}}}, nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conds, err := (&rowScope{claims: tc.claims}).resolve(tbl)
+			if tc.err {
+				var rfErr *rowFilterError
+				assert.ErrorAs(t, err, &rfErr)
+				return
+			}
+			assert.NoError(t, err)
+			values := make([]interface{}, len(conds))
+			for i, cond := range conds {
+				values[i] = cond.Value
+			}
+			assert.Equal(t, tc.want, values)
+		})
+	}
+}
+
+func TestRowFilterTable(t *testing.T) {
+	tables := []tableConfig{
+		{Name: "user", History: tableHistoryConfig{Enabled: true}},
+		{Name: "order", History: tableHistoryConfig{Enabled: true, Table: "order_versions"}},
+		{Name: "log"},
+	}
+	cases := []struct {
+		table string
+		want  string
+	}{
+		{"user", "user"},
+		{"user_history", "user"},
+		{"order_versions", "order"},
+		{"order_history", ""},
+		{"log_history", ""},
+		{"other", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.table, func(t *testing.T) {
+			got := rowFilterTable(tables, tc.table)
+			if tc.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tc.want, got.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,891 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// --------- 场景测试 ---------
+//
+// ego test 按 YAML 场景文件依次发送请求并断言响应，用于回归测试表配置：
+//
+//	ego test                                  # 执行 cfgs/tests 下的全部场景
+//	ego test -cfgs ./cfgs -v tests/user.yaml  # 指定配置目录与场景文件（或目录）
+//	ego test -mongo mongodb://localhost:27017 # MongoDB 库使用该服务中的临时库
+//
+// 每个场景在隔离的环境中执行：复制配置目录，SQL 类库替换为只有表结构（不含数据）的临时 SQLite 库——
+// SQLite 库复制原库的建表语句，其他 SQL 库按元数据提取结果建表；MongoDB 库在 -mongo 指定时改用随机命名的
+// 临时库并在结束后删除，未指定时与其他类型（files 除外）的库一样在测试中禁用。任务队列、日志等写到临时目录。
+// 场景文件（一个文件可用 --- 分隔多个场景）：
+//
+//	name: user crud
+//	setup:                                   # 执行步骤前准备数据
+//	  - database: test
+//	    sql: ["INSERT INTO user (username, email) VALUES ('seed', 'seed@example.com')"]
+//	    records:                             # 按表别名直接写入（物理列名），MongoDB 也可用
+//	      user: [{username: bob, email: bob@example.com}]
+//	steps:
+//	  - name: create
+//	    request: POST /api/rest/test/user    # 方法与路径（可含查询参数）
+//	    headers: {X-Roles: admin}
+//	    body: [{username: alice, email: alice@example.com}]
+//	    expect:
+//	      status: 201
+//	      headers: {Content-Type: application/json; charset=utf-8}
+//	      body:                              # 路径用 . 分隔，数组用下标，$ 为整个响应体
+//	        0.username: alice
+//	        0.id: {$exists: true}            # 另有 $len $contains $matches $ne $gt $gte $lt $lte
+//	    save: {user_id: 0.id}                # 保存响应中的值，之后用 {{user_id}} 引用
+//	  - request: GET /api/rest/test/user/{{user_id}}
+//	    expect: {status: 200, body: {email: alice@example.com}}
+//
+// 字符串中的 {{变量}} 会被替换，整个字符串只有一个变量时保留原值类型。值按 JSON 比较，数字不区分整数与浮点。
+
+// TestOptions 为 RunTests 的参数
+type TestOptions struct {
+	Cfgs     string    // 配置目录
+	MongoURI string    // MongoDB 库使用的测试服务，为空时禁用 MongoDB 库
+	Verbose  bool      // 输出每个步骤与服务日志
+	Out      io.Writer // 结果输出，默认 os.Stdout
+}
+
+type testScenario struct {
+	Name  string                 `yaml:"name"`
+	Vars  map[string]interface{} `yaml:"vars"`
+	Setup []testSetup            `yaml:"setup"`
+	Steps []testStep             `yaml:"steps"`
+}
+
+type testSetup struct {
+	Database string                              `yaml:"database"`
+	SQL      []string                            `yaml:"sql"`
+	Records  map[string][]map[string]interface{} `yaml:"records"`
+}
+
+type testStep struct {
+	Name    string            `yaml:"name"`
+	Request string            `yaml:"request"`
+	Headers map[string]string `yaml:"headers"`
+	Body    interface{}       `yaml:"body"`
+	Expect  testExpect        `yaml:"expect"`
+	Save    map[string]string `yaml:"save"`
+}
+
+type testExpect struct {
+	Status  int                    `yaml:"status"`
+	Headers map[string]string      `yaml:"headers"`
+	Body    map[string]interface{} `yaml:"body"`
+}
+
+// RunTests 执行 paths 中的场景文件（目录时包含其中全部 .yaml/.yml），返回通过与失败的场景数
+func RunTests(opts TestOptions, paths []string) (passed, failed int, err error) {
+	if !opts.Verbose {
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(io.Discard)
+	}
+	defer gin.SetMode(gin.Mode())
+	gin.SetMode(gin.ReleaseMode)
+	return runScenarios(opts, paths)
+}
+
+// runScenarios 执行场景，不改变日志输出与 gin 模式，运行中的服务校验新配置时使用（见 configswap.go）
+func runScenarios(opts TestOptions, paths []string) (passed, failed int, err error) {
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	files, err := collectScenarioFiles(paths)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(files) == 0 {
+		return 0, 0, fmt.Errorf("no scenario files found in %s", strings.Join(paths, ", "))
+	}
+
+	tmp, err := os.MkdirTemp("", "ego-test-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(tmp)
+	env, err := prepareTestEnv(opts, filepath.Join(tmp, "template"))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, w := range env.warnings {
+		fmt.Fprintf(opts.Out, "warning: %s\n", w)
+	}
+	run := 0
+	for _, file := range files {
+		scenarios, err := readScenarios(file)
+		if err != nil {
+			fmt.Fprintf(opts.Out, "--- FAIL: %s\n    %v\n", file, err)
+			failed++
+			continue
+		}
+		for _, sc := range scenarios {
+			run++
+			name := file
+			if sc.Name != "" {
+				name += ": " + sc.Name
+			}
+			start := time.Now()
+			var report bytes.Buffer
+			err := env.run(filepath.Join(tmp, fmt.Sprintf("run-%d", run)), sc, &report, opts.Verbose)
+			elapsed := time.Since(start).Round(time.Millisecond)
+			if err != nil {
+				failed++
+				fmt.Fprintf(opts.Out, "--- FAIL: %s (%s)\n%s    %v\n", name, elapsed, report.String(), err)
+				continue
+			}
+			passed++
+			if opts.Verbose {
+				fmt.Fprintf(opts.Out, "--- PASS: %s (%s)\n%s", name, elapsed, report.String())
+			}
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(opts.Out, "FAIL\t%d failed, %d passed\n", failed, passed)
+	} else {
+		fmt.Fprintf(opts.Out, "ok\t%d passed\n", passed)
+	}
+	return passed, failed, nil
+}
+
+func collectScenarioFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func readScenarios(file string) ([]testScenario, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	var scenarios []testScenario
+	for {
+		var sc testScenario
+		if err := dec.Decode(&sc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(sc.Steps) == 0 {
+			return nil, fmt.Errorf("scenario %q has no steps", sc.Name)
+		}
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios, nil
+}
+
+// ---- 隔离环境 ----
+
+// testDatabase 为测试中一个库配置的处理方式
+type testDatabase struct {
+	file     string                 // 库配置文件名
+	config   map[string]interface{} // 改写后的库配置
+	template string                 // 只有表结构的 SQLite 库，非 SQL 库为空
+	mongo    bool
+}
+
+type testEnv struct {
+	cfgs      string
+	mongoURI  string
+	databases []*testDatabase
+	warnings  []string
+}
+
+var testSQLTypes = map[string]bool{"mysql": true, "tidb": true, "postgres": true, "postgresql": true, "cockroachdb": true, "sqlserver": true, "clickhouse": true}
+
+// prepareTestEnv 为每个启用的库生成只有表结构的 SQLite 模板库，执行场景时复制使用
+func prepareTestEnv(opts TestOptions, dir string) (*testEnv, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	env := &testEnv{cfgs: opts.Cfgs, mongoURI: opts.MongoURI}
+	dbDir := filepath.Join(opts.Cfgs, "database")
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database config dir: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".enable.yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dbDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		cfg := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		db := &testDatabase{file: entry.Name(), config: cfg}
+		typ := strings.ToLower(fmt.Sprint(cfg["type"]))
+		dsn := fmt.Sprint(cfg["dsn"])
+		if list, ok := cfg["dsn"].([]interface{}); ok && len(list) > 0 {
+			dsn = fmt.Sprint(list[0])
+		}
+		if shards, ok := cfg["shards"].(map[string]interface{}); ok {
+			if nodes, ok := shards["nodes"].([]interface{}); ok && len(nodes) > 0 {
+				if node, ok := nodes[0].(map[string]interface{}); ok {
+					dsn = fmt.Sprint(node["dsn"])
+				}
+			}
+		}
+		name := strings.TrimSuffix(entry.Name(), ".enable.yaml")
+		switch {
+		case typ == "sqlite" || testSQLTypes[typ]:
+			db.template = filepath.Join(dir, name+".db")
+			if err := createTestSchema(typ, dsn, fmt.Sprint(cfg["database"]), db.template); err != nil {
+				return nil, fmt.Errorf("failed to copy schema of %s: %w", name, err)
+			}
+		case typ == "mongodb" && opts.MongoURI != "":
+			db.mongo = true
+		case typ == "files":
+		default:
+			db.config = nil
+			env.warnings = append(env.warnings, fmt.Sprintf("database %s (%s) is disabled during tests", name, typ))
+		}
+		env.databases = append(env.databases, db)
+	}
+	return env, nil
+}
+
+// createTestSchema 在 target 创建与源库相同的表结构
+func createTestSchema(typ, dsn, dbName, target string) error {
+	tdb, err := gorm.Open(sqlite.Open(target), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return err
+	}
+	defer closeGormDB(tdb)
+	var statements []string
+	if typ == "sqlite" {
+		src, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			return err
+		}
+		defer closeGormDB(src)
+		// 先建表，再建索引、视图与触发器
+		err = src.Raw(`SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+			ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END`).Scan(&statements).Error
+		if err != nil {
+			return err
+		}
+	} else {
+		tables, err := extractTableMeta(typ, dsn, dbName, nil)
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			statements = append(statements, sqliteCreateTable(t))
+		}
+	}
+	for _, stmt := range statements {
+		if err := tdb.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+func closeGormDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+var sqliteTypeSanitizer = regexp.MustCompile(`[^A-Za-z0-9_ (),]`)
+
+// sqliteCreateTable 按提取的元数据生成 SQLite 建表语句，列类型保留原名由 SQLite 按名称推断类型亲和性
+func sqliteCreateTable(t TableMeta) string {
+	var pks []string
+	for _, f := range t.Fields {
+		if f.IsPrimary {
+			pks = append(pks, f.Name)
+		}
+	}
+	cols := make([]string, 0, len(t.Fields)+len(t.UniqueKeys)+1)
+	for _, f := range t.Fields {
+		typ := strings.TrimSpace(sqliteTypeSanitizer.ReplaceAllString(f.Type, ""))
+		if typ == "" || strings.Count(typ, "(") != strings.Count(typ, ")") {
+			typ = "TEXT"
+		}
+		col := fmt.Sprintf("%q %s", f.Name, typ)
+		if len(pks) == 1 && f.IsPrimary {
+			if f.AutoInc {
+				col = fmt.Sprintf("%q INTEGER PRIMARY KEY AUTOINCREMENT", f.Name)
+			} else {
+				col += " PRIMARY KEY"
+			}
+		} else if f.IsUnique {
+			col += " UNIQUE"
+		}
+		if def, ok := sqliteDefault(f); ok {
+			col += " DEFAULT " + def
+		} else if !f.Nullable && !f.IsPrimary && !f.HasDefault {
+			col += " NOT NULL"
+		}
+		cols = append(cols, col)
+	}
+	if len(pks) > 1 {
+		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", quoteIdentList(pks)))
+	}
+	for _, uk := range t.UniqueKeys {
+		if len(uk) > 1 {
+			cols = append(cols, fmt.Sprintf("UNIQUE (%s)", quoteIdentList(uk)))
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %q (\n  %s\n)", t.Name, strings.Join(cols, ",\n  "))
+}
+
+func quoteIdentList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = strconv.Quote(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// sqliteDefault 只保留字面量与当前时间两类默认值，其余（函数、序列）在测试库中忽略
+func sqliteDefault(f FieldMeta) (string, bool) {
+	if !f.HasDefault || f.Default == nil {
+		return "", false
+	}
+	switch v := f.Default.(type) {
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	case int, int32, int64, float32, float64:
+		return fmt.Sprint(v), true
+	case string:
+		lv := strings.ToLower(v)
+		if strings.Contains(lv, "current_timestamp") || strings.HasPrefix(lv, "now(") || strings.Contains(lv, "getdate(") {
+			return "CURRENT_TIMESTAMP", true
+		}
+		if strings.Contains(v, "(") || strings.Contains(v, "::") {
+			return "", false
+		}
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", true
+	}
+	return "", false
+}
+
+// prepare 在 dir 中生成本次执行使用的配置目录，返回需要在结束后删除的 MongoDB 临时库（别名 → 库名）
+func (e *testEnv) prepare(dir string) (string, map[string]string, error) {
+	cfgs := filepath.Join(dir, "cfgs")
+	if err := copyDir(e.cfgs, cfgs); err != nil {
+		return "", nil, err
+	}
+	mongoDBs := map[string]string{}
+	for _, db := range e.databases {
+		file := filepath.Join(cfgs, "database", db.file)
+		if db.config == nil {
+			if err := os.Rename(file, strings.TrimSuffix(file, ".enable.yaml")+".disable.yaml"); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+		cfg := make(map[string]interface{}, len(db.config))
+		for k, v := range db.config {
+			cfg[k] = v
+		}
+		switch {
+		case db.template != "":
+			target := filepath.Join(dir, filepath.Base(db.template))
+			if err := copyFile(db.template, target); err != nil {
+				return "", nil, err
+			}
+			if strings.ToLower(fmt.Sprint(cfg["type"])) != "sqlite" {
+				// 其他类型专用的连接与会话设置在 SQLite 上无效
+				for _, k := range []string{"shards", "failover", "session", "tidb", "cockroachdb"} {
+					delete(cfg, k)
+				}
+			}
+			cfg["type"], cfg["dsn"] = "sqlite", target
+		case db.mongo:
+			// 库名决定表配置目录，改名时一并移动
+			name := fmt.Sprint(cfg["database"])
+			testName := fmt.Sprintf("%s_egotest_%d", name, time.Now().UnixNano())
+			tableDir := filepath.Join(cfgs, "table", strings.TrimSuffix(db.file, ".enable.yaml"))
+			if _, err := os.Stat(tableDir); err == nil {
+				if err := os.Rename(tableDir, filepath.Join(cfgs, "table", testName)); err != nil {
+					return "", nil, err
+				}
+			}
+			if err := os.Remove(file); err != nil {
+				return "", nil, err
+			}
+			file = filepath.Join(cfgs, "database", testName+".enable.yaml")
+			alias := fmt.Sprint(cfg["alias"])
+			if cfg["alias"] == nil {
+				alias = name
+			}
+			cfg["alias"], cfg["database"], cfg["dsn"] = alias, testName, e.mongoURI
+			mongoDBs[alias] = testName
+		}
+		if err := writeYAMLFile(file, cfg); err != nil {
+			return "", nil, err
+		}
+	}
+	// 任务队列、暂存文件与日志写到临时目录
+	basePath := filepath.Join(cfgs, "_base.yaml")
+	base := map[string]interface{}{}
+	if data, err := os.ReadFile(basePath); err == nil {
+		if err := yaml.Unmarshal(data, &base); err != nil {
+			return "", nil, fmt.Errorf("_base.yaml: %w", err)
+		}
+	}
+	setYAMLPath(base, filepath.Join(dir, "data", "jobs"), "bulk_job", "dir")
+	setYAMLPath(base, "", "job_queue", "store")
+	audit, _ := base["audit"].(map[string]interface{})
+	recording, _ := base["recording"].(map[string]interface{})
+	if (audit != nil && audit["sink"] == auditSinkStore) || (recording != nil && recording["enabled"] == true) {
+		// 审计写入 KVStore、请求录制时需要 store，同样放到临时目录
+		setYAMLPath(base, filepath.Join(dir, "data", "queue"), "job_queue", "store")
+	}
+	setYAMLPath(base, filepath.Join(dir, "logs", "gorm.log"), "gorm_log", "filename")
+	setYAMLPath(base, []interface{}{}, "gorm_log", "sinks")
+	setYAMLPath(base, "", "index_advisor", "schedule")
+	if err := writeYAMLFile(basePath, base); err != nil {
+		return "", nil, err
+	}
+	return cfgs, mongoDBs, nil
+}
+
+func setYAMLPath(m map[string]interface{}, value interface{}, keys ...string) {
+	for _, k := range keys[:len(keys)-1] {
+		child, ok := m[k].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[k] = child
+		}
+		m = child
+	}
+	m[keys[len(keys)-1]] = value
+}
+
+func writeYAMLFile(path string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
+
+// ---- 执行 ----
+
+func (e *testEnv) run(dir string, sc testScenario, report io.Writer, verbose bool) error {
+	defer os.RemoveAll(dir)
+	cfgs, mongoDBs, err := e.prepare(dir)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	router := gin.New()
+	h, err := New(cfgs).
+		WithRest(RestOptions{}).
+		WithGraphql(GraphqlOptions{NoGraphiQL: true}).
+		Mount(router)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	defer h.Close()
+	defer func() {
+		for alias, name := range mongoDBs {
+			if client, ok := h.dm.mongoClients[alias]; ok {
+				client.Database(name).Drop(context.Background())
+			}
+		}
+	}()
+
+	vars := map[string]interface{}{}
+	for k, v := range sc.Vars {
+		vars[k] = v
+	}
+	for i, s := range sc.Setup {
+		if err := h.dm.runTestSetup(s, vars); err != nil {
+			return fmt.Errorf("setup %d: %w", i+1, err)
+		}
+	}
+	for i, step := range sc.Steps {
+		label := fmt.Sprintf("step %d", i+1)
+		if step.Name != "" {
+			label += fmt.Sprintf(" %q", step.Name)
+		}
+		request, err := runTestStep(router, step, vars)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", label, request, err)
+		}
+		if verbose {
+			fmt.Fprintf(report, "    %s: %s ok\n", label, request)
+		}
+	}
+	return nil
+}
+
+func (dm *databaseManager) runTestSetup(s testSetup, vars map[string]interface{}) error {
+	if len(s.SQL) > 0 {
+		db, ok := dm.gormDBs[s.Database]
+		if !ok {
+			return fmt.Errorf("database %s is not a SQL database", s.Database)
+		}
+		for _, stmt := range s.SQL {
+			if err := db.Exec(fmt.Sprint(substituteTestVars(stmt, vars))).Error; err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+	}
+	tables := make([]string, 0, len(s.Records))
+	for t := range s.Records {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		adapter, tc, err := dm.getAdapterAndTableConfig(s.Database, table)
+		if err != nil {
+			return err
+		}
+		records := make([]map[string]interface{}, len(s.Records[table]))
+		for i, r := range s.Records[table] {
+			records[i] = substituteTestVars(r, vars).(map[string]interface{})
+		}
+		if _, _, err := adapter.BatchCreate(context.Background(), tc, records); err != nil {
+			return fmt.Errorf("insert %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// runTestStep 发送请求并检查响应，返回请求行用于报告
+func runTestStep(router http.Handler, step testStep, vars map[string]interface{}) (string, error) {
+	line := fmt.Sprint(substituteTestVars(strings.TrimSpace(step.Request), vars))
+	method, target, ok := strings.Cut(line, " ")
+	if !ok {
+		return line, errors.New("request must be \"METHOD /path\"")
+	}
+	var body io.Reader
+	if step.Body != nil {
+		data, err := json.Marshal(substituteTestVars(step.Body, vars))
+		if err != nil {
+			return line, fmt.Errorf("encode body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(strings.ToUpper(method), strings.TrimSpace(target), body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range step.Headers {
+		req.Header.Set(k, fmt.Sprint(substituteTestVars(v, vars)))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var actual interface{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			actual = w.Body.String()
+		}
+	}
+	fail := func(format string, args ...interface{}) (string, error) {
+		return line, fmt.Errorf("%s\n        status: %d\n        body: %s", fmt.Sprintf(format, args...), w.Code, truncateTestBody(w.Body.String()))
+	}
+	if step.Expect.Status != 0 && w.Code != step.Expect.Status {
+		return fail("status %d, expected %d", w.Code, step.Expect.Status)
+	}
+	for k, v := range step.Expect.Headers {
+		expected := fmt.Sprint(substituteTestVars(v, vars))
+		if got := w.Header().Get(k); got != expected {
+			return fail("header %s is %q, expected %q", k, got, expected)
+		}
+	}
+	paths := make([]string, 0, len(step.Expect.Body))
+	for p := range step.Expect.Body {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		value, found := testJSONPath(actual, p)
+		if err := checkTestAssertion(value, found, substituteTestVars(step.Expect.Body[p], vars)); err != nil {
+			return fail("body %s: %v", p, err)
+		}
+	}
+	for name, p := range step.Save {
+		value, found := testJSONPath(actual, p)
+		if !found {
+			return fail("save %s: %s not found in response", name, p)
+		}
+		vars[name] = value
+	}
+	return line, nil
+}
+
+func truncateTestBody(s string) string {
+	const max = 2000
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
+
+var testVarRe = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// substituteTestVars 替换 v 中字符串里的 {{变量}}
+func substituteTestVars(v interface{}, vars map[string]interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if m := testVarRe.FindStringSubmatch(val); m != nil && m[0] == val {
+			if x, ok := vars[m[1]]; ok {
+				return x
+			}
+		}
+		return testVarRe.ReplaceAllStringFunc(val, func(s string) string {
+			name := testVarRe.FindStringSubmatch(s)[1]
+			if x, ok := vars[name]; ok {
+				return fmt.Sprint(x)
+			}
+			return s
+		})
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, x := range val {
+			out[k] = substituteTestVars(x, vars)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, x := range val {
+			out[i] = substituteTestVars(x, vars)
+		}
+		return out
+	}
+	return v
+}
+
+// testJSONPath 按 a.0.b 形式的路径取值，$ 为整个值
+func testJSONPath(v interface{}, path string) (interface{}, bool) {
+	if path == "$" || path == "" {
+		return v, true
+	}
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch cur := v.(type) {
+		case map[string]interface{}:
+			x, ok := cur[seg]
+			if !ok {
+				return nil, false
+			}
+			v = x
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(cur) {
+				return nil, false
+			}
+			v = cur[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// normalizeTestValue 把 YAML 中的期望值转成与 JSON 解码结果相同的类型
+func normalizeTestValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func checkTestAssertion(actual interface{}, found bool, expected interface{}) error {
+	ops, isOps := expected.(map[string]interface{})
+	if isOps {
+		for k := range ops {
+			if !strings.HasPrefix(k, "$") {
+				isOps = false
+				break
+			}
+		}
+	}
+	if !isOps || len(ops) == 0 {
+		if !found {
+			return errors.New("not found")
+		}
+		if want := normalizeTestValue(expected); !reflect.DeepEqual(actual, want) {
+			return fmt.Errorf("got %s, expected %s", testJSON(actual), testJSON(want))
+		}
+		return nil
+	}
+	names := make([]string, 0, len(ops))
+	for k := range ops {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, op := range names {
+		arg := normalizeTestValue(ops[op])
+		if op == "$exists" {
+			if want, _ := arg.(bool); want != found {
+				return fmt.Errorf("exists is %v, expected %v", found, want)
+			}
+			continue
+		}
+		if !found {
+			return errors.New("not found")
+		}
+		if err := checkTestOperator(op, actual, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkTestOperator(op string, actual, arg interface{}) error {
+	switch op {
+	case "$ne":
+		if reflect.DeepEqual(actual, arg) {
+			return fmt.Errorf("got %s, expected a different value", testJSON(actual))
+		}
+	case "$len":
+		n := -1
+		switch v := actual.(type) {
+		case []interface{}:
+			n = len(v)
+		case map[string]interface{}:
+			n = len(v)
+		case string:
+			n = len(v)
+		}
+		if want, ok := arg.(float64); !ok || float64(n) != want {
+			return fmt.Errorf("length is %d, expected %s", n, testJSON(arg))
+		}
+	case "$contains":
+		switch v := actual.(type) {
+		case string:
+			if !strings.Contains(v, fmt.Sprint(arg)) {
+				return fmt.Errorf("%q does not contain %q", v, fmt.Sprint(arg))
+			}
+		case []interface{}:
+			for _, x := range v {
+				if reflect.DeepEqual(x, arg) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s does not contain %s", testJSON(v), testJSON(arg))
+		default:
+			return fmt.Errorf("$contains requires a string or array, got %s", testJSON(actual))
+		}
+	case "$matches":
+		re, err := regexp.Compile(fmt.Sprint(arg))
+		if err != nil {
+			return fmt.Errorf("invalid $matches: %w", err)
+		}
+		if !re.MatchString(fmt.Sprint(actual)) {
+			return fmt.Errorf("%s does not match %s", testJSON(actual), re)
+		}
+	case "$gt", "$gte", "$lt", "$lte":
+		a, ok1 := toTestNumber(actual)
+		b, ok2 := toTestNumber(arg)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("%s requires numbers, got %s and %s", op, testJSON(actual), testJSON(arg))
+		}
+		if ok := map[string]bool{"$gt": a > b, "$gte": a >= b, "$lt": a < b, "$lte": a <= b}[op]; !ok {
+			return fmt.Errorf("%v is not %s %v", a, strings.TrimPrefix(op, "$"), b)
+		}
+	default:
+		return fmt.Errorf("unknown assertion %s", op)
+	}
+	return nil
+}
+
+func toTestNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func testJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
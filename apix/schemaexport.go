@@ -0,0 +1,301 @@
+package apix
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"gopkg.in/yaml.v3"
+)
+
+// --------- schema 导出 ---------
+//
+// 供前端表单、校验库等代码生成工具直接使用 ego 的模型：
+//
+//	GET /api/graphql/test/schema.graphql      # 当前 GraphQL schema 的 SDL
+//	GET /swagger/test/jsonschema              # 各表的 JSON Schema（draft 2020-12），表名 → schema
+//	GET /swagger/test/jsonschema/user         # 单表的 JSON Schema
+//
+// 也可以不启动服务直接导出到目录（会先按表配置重新生成 swagger.yaml）：
+//
+//	ego export [-cfgs ./cfgs] [-out ./schemas]
+//	  => schemas/test/schema.graphql、schemas/test/user.schema.json ...
+//
+// JSON Schema 由 swagger.yaml 中表的 schema 按 OpenAPI 3.1 规则转换（nullable 改为类型数组等），
+// 保留 readOnly、writeOnly 与 x-primary-key，表单生成时可据此区分新增与编辑。
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ---- GraphQL SDL ----
+
+func (ep *graphqlEndpoint) serveSDL(c *gin.Context) {
+	schema := ep.schema.Load()
+	if schema == nil {
+		c.String(http.StatusServiceUnavailable, "graphql schema is not ready")
+		return
+	}
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.String(http.StatusOK, printGraphqlSDL(schema))
+}
+
+// printGraphqlSDL 按类型名排序输出 schema，内置标量与内省类型除外
+func printGraphqlSDL(schema *graphql.Schema) string {
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + schema.QueryType().Name() + "\n")
+	if m := schema.MutationType(); m != nil && len(m.Fields()) > 0 {
+		b.WriteString("  mutation: " + m.Name() + "\n")
+	}
+	b.WriteString("}\n")
+	typeMap := schema.TypeMap()
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		if strings.HasPrefix(name, "__") || isBuiltinGraphqlScalar(name) {
+			continue
+		}
+		// 没有变更时的空根类型
+		if obj, ok := typeMap[name].(*graphql.Object); ok && len(obj.Fields()) == 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("\n")
+		switch t := typeMap[name].(type) {
+		case *graphql.Object:
+			writeSDLDescription(&b, t.Description(), "")
+			b.WriteString("type " + name)
+			if ifaces := t.Interfaces(); len(ifaces) > 0 {
+				parts := make([]string, len(ifaces))
+				for i, iface := range ifaces {
+					parts[i] = iface.Name()
+				}
+				b.WriteString(" implements " + strings.Join(parts, " & "))
+			}
+			writeSDLFields(&b, t.Fields())
+		case *graphql.Interface:
+			writeSDLDescription(&b, t.Description(), "")
+			b.WriteString("interface " + name)
+			writeSDLFields(&b, t.Fields())
+		case *graphql.Union:
+			writeSDLDescription(&b, t.Description(), "")
+			parts := make([]string, len(t.Types()))
+			for i, member := range t.Types() {
+				parts[i] = member.Name()
+			}
+			b.WriteString("union " + name + " = " + strings.Join(parts, " | ") + "\n")
+		case *graphql.InputObject:
+			writeSDLDescription(&b, t.Description(), "")
+			b.WriteString("input " + name + " {\n")
+			fields := t.Fields()
+			for _, fname := range slices.Sorted(maps.Keys(fields)) {
+				f := fields[fname]
+				writeSDLDescription(&b, f.Description(), "  ")
+				b.WriteString("  " + fname + ": " + f.Type.String() + sdlDefault(f.DefaultValue) + "\n")
+			}
+			b.WriteString("}\n")
+		case *graphql.Enum:
+			writeSDLDescription(&b, t.Description(), "")
+			b.WriteString("enum " + name + " {\n")
+			for _, v := range t.Values() {
+				writeSDLDescription(&b, v.Description, "  ")
+				b.WriteString("  " + v.Name + "\n")
+			}
+			b.WriteString("}\n")
+		case *graphql.Scalar:
+			writeSDLDescription(&b, t.Description(), "")
+			b.WriteString("scalar " + name + "\n")
+		}
+	}
+	return b.String()
+}
+
+func writeSDLFields(b *strings.Builder, fields graphql.FieldDefinitionMap) {
+	b.WriteString(" {\n")
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		f := fields[name]
+		writeSDLDescription(b, f.Description, "  ")
+		b.WriteString("  " + name)
+		if len(f.Args) > 0 {
+			args := make([]string, len(f.Args))
+			for i, a := range f.Args {
+				args[i] = a.Name() + ": " + a.Type.String() + sdlDefault(a.DefaultValue)
+				if a.Description() != "" {
+					quoted, _ := json.Marshal(a.Description())
+					args[i] = string(quoted) + " " + args[i]
+				}
+			}
+			b.WriteString("(" + strings.Join(args, ", ") + ")")
+		}
+		b.WriteString(": " + f.Type.String())
+		if f.DeprecationReason != "" {
+			reason, _ := json.Marshal(f.DeprecationReason)
+			b.WriteString(" @deprecated(reason: " + string(reason) + ")")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+}
+
+func writeSDLDescription(b *strings.Builder, desc, indent string) {
+	if desc == "" {
+		return
+	}
+	quoted, _ := json.Marshal(desc)
+	b.WriteString(indent + string(quoted) + "\n")
+}
+
+func sdlDefault(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	literal, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return " = " + string(literal)
+}
+
+func isBuiltinGraphqlScalar(name string) bool {
+	switch name {
+	case "String", "Int", "Float", "Boolean", "ID":
+		return true
+	}
+	return false
+}
+
+// ---- JSON Schema ----
+
+// tableJSONSchemas 把 swagger.yaml 中各表的 schema 转为 JSON Schema，键为表别名
+func tableJSONSchemas(swaggerData []byte) (map[string]interface{}, error) {
+	var doc struct {
+		Tags []struct {
+			Name string `yaml:"name"`
+		} `yaml:"tags"`
+		Components struct {
+			Schemas map[string]interface{} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(swaggerData, &doc); err != nil {
+		return nil, fmt.Errorf("parse swagger failed: %w", err)
+	}
+	result := map[string]interface{}{}
+	for _, tag := range doc.Tags {
+		s, ok := doc.Components.Schemas[tag.Name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		convertSchema31(s)
+		s["$schema"] = jsonSchemaDialect
+		s["title"] = tag.Name
+		result[tag.Name] = s
+	}
+	return result, nil
+}
+
+// handleJSONSchema 返回库中全部表或单表的 JSON Schema
+func handleJSONSchema(cfgsDir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := swaggerSpecFile(cfgsDir, c.Param("dbalias"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "swagger.yaml not found for db: " + c.Param("dbalias")})
+			return
+		}
+		schemas, err := tableJSONSchemas(data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		table := c.Param("table")
+		if table == "" {
+			c.JSON(http.StatusOK, schemas)
+			return
+		}
+		s, ok := schemas[table]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "table not found: " + table})
+			return
+		}
+		c.Header("Content-Type", "application/schema+json")
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// ---- 导出到目录 ----
+
+// ExportSchemas 重新生成 swagger.yaml 后把每个库的 GraphQL SDL 与各表 JSON Schema 写入 outDir/{库别名}/，返回写入的文件
+func ExportSchemas(cfgs, outDir, restPrefix string) ([]string, error) {
+	if restPrefix == "" {
+		restPrefix = defaultRestPrefix
+	}
+	if err := ExtractDbMeta(cfgs, restPrefix); err != nil {
+		return nil, err
+	}
+	tableCfgDir := filepath.Join(cfgs, "table")
+	entries, err := os.ReadDir(tableCfgDir)
+	if err != nil {
+		return nil, err
+	}
+	maxPageSize := readGraphqlOptions(cfgs).MaxPageSize
+	var written []string
+	write := func(file string, data []byte) error {
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return err
+		}
+		written = append(written, file)
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dbDir := filepath.Join(tableCfgDir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dbDir, "swagger.yaml"))
+		if err != nil {
+			continue
+		}
+		alias := findAliasByDatabase(filepath.Join(cfgs, "database"), entry.Name())
+		if alias == "" {
+			alias = entry.Name()
+		}
+		dir := filepath.Join(outDir, alias)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return written, err
+		}
+		// 只生成类型，不执行查询，resolver 不会被调用
+		schema, err := buildGraphqlSchema(dbDir, proxyResolvers(""), maxPageSize)
+		if err != nil {
+			return written, fmt.Errorf("graphql %s: %w", alias, err)
+		}
+		if err := write(filepath.Join(dir, "schema.graphql"), []byte(printGraphqlSDL(schema))); err != nil {
+			return written, err
+		}
+		schemas, err := tableJSONSchemas(data)
+		if err != nil {
+			return written, fmt.Errorf("json schema %s: %w", alias, err)
+		}
+		for _, table := range sortedKeys(schemas) {
+			out, err := json.MarshalIndent(schemas[table], "", "  ")
+			if err != nil {
+				return written, err
+			}
+			if err := write(filepath.Join(dir, table+".schema.json"), append(out, '\n')); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
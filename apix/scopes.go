@@ -0,0 +1,192 @@
+package apix
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 列表查询的 GORM scopes ---------
+//
+// gormAdapter.List 执行前依次应用表配置中的 gorm_scopes 与 Go 代码注册的 scopes：
+//
+//	gorm_scopes:
+//	  force_index: [idx_user_age]     # MySQL/TiDB FORCE INDEX，SQL Server WITH (INDEX(...))，SQLite INDEXED BY
+//	  use_index: [idx_user_age]       # MySQL/TiDB USE INDEX
+//	  ignore_index: [idx_user_phone]  # MySQL/TiDB IGNORE INDEX
+//	  optimizer_hints: ["MAX_EXECUTION_TIME(1000)"]   # /*+ ... */，PostgreSQL 需安装 pg_hint_plan
+//	  comment: "ego {database}.{table} user={header:X-User}"   # 查询注释，便于在慢日志中归因
+//
+// 占位符的值只保留字母、数字与 _.:@ -，其余字符替换为 _。
+//
+// Go 代码中注册（table 为 "*" 时作用于库内所有表）：
+//
+//	apix.RegisterGormScope("test", "user", func(db *gorm.DB) *gorm.DB {
+//		return db.Where("tenant_id = ?", tenantOf(db.Statement.Context))
+//	})
+
+type gormScopesConfig struct {
+	ForceIndex     []string `mapstructure:"force_index"`
+	UseIndex       []string `mapstructure:"use_index"`
+	IgnoreIndex    []string `mapstructure:"ignore_index"`
+	OptimizerHints []string `mapstructure:"optimizer_hints"`
+	Comment        string   `mapstructure:"comment"`
+}
+
+var (
+	gormScopesMu sync.RWMutex
+	gormScopes   = map[string][]func(*gorm.DB) *gorm.DB{}
+)
+
+// RegisterGormScope 为 database/table（均为别名）注册列表查询的 scope，需在 RegisterRestAPI 之前调用
+func RegisterGormScope(database, table string, scopes ...func(*gorm.DB) *gorm.DB) {
+	gormScopesMu.Lock()
+	defer gormScopesMu.Unlock()
+	key := database + "/" + table
+	gormScopes[key] = append(gormScopes[key], scopes...)
+}
+
+func registeredGormScopes(database, table string) []func(*gorm.DB) *gorm.DB {
+	gormScopesMu.RLock()
+	defer gormScopesMu.RUnlock()
+	return append(append([]func(*gorm.DB) *gorm.DB{}, gormScopes[database+"/*"]...), gormScopes[database+"/"+table]...)
+}
+
+// applyListScopes 作用于已设置 Table 的查询
+func (a *gormAdapter) applyListScopes(db *gorm.DB, tc *tableConfig) *gorm.DB {
+	cfg := tc.GormScopes
+	if hint := a.indexHint(db, cfg); hint != "" {
+		db.Statement.TableExpr = &clause.Expr{SQL: db.Statement.Quote(tc.Name) + " " + hint}
+	}
+	var before, after []string
+	if cfg.Comment != "" {
+		before = append(before, "/* "+sanitizeSQLComment(a.renderScopeComment(db, tc, cfg.Comment))+" */")
+	}
+	if len(cfg.OptimizerHints) > 0 {
+		hints := "/*+ " + sanitizeSQLComment(strings.Join(cfg.OptimizerHints, " ")) + " */"
+		switch db.Dialector.Name() {
+		case "mysql":
+			after = append(after, hints)
+		case "postgres":
+			// pg_hint_plan 只识别语句开头的提示注释
+			before = append([]string{hints}, before...)
+		}
+	}
+	if len(before) > 0 || len(after) > 0 {
+		db = db.Clauses(selectDecoration{before: before, after: after})
+	}
+	database := ""
+	if a.config != nil {
+		database = a.config.Alias
+	}
+	for _, scope := range registeredGormScopes(database, tc.Alias) {
+		db = scope(db)
+	}
+	return db
+}
+
+// indexHint 按方言生成表名后的索引提示
+func (a *gormAdapter) indexHint(db *gorm.DB, cfg gormScopesConfig) string {
+	quoteList := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, n := range names {
+			quoted[i] = db.Statement.Quote(n)
+		}
+		return strings.Join(quoted, ", ")
+	}
+	var parts []string
+	switch db.Dialector.Name() {
+	case "mysql":
+		if len(cfg.ForceIndex) > 0 {
+			parts = append(parts, "FORCE INDEX ("+quoteList(cfg.ForceIndex)+")")
+		}
+		if len(cfg.UseIndex) > 0 {
+			parts = append(parts, "USE INDEX ("+quoteList(cfg.UseIndex)+")")
+		}
+		if len(cfg.IgnoreIndex) > 0 {
+			parts = append(parts, "IGNORE INDEX ("+quoteList(cfg.IgnoreIndex)+")")
+		}
+	case "sqlserver":
+		if idx := append(append([]string{}, cfg.ForceIndex...), cfg.UseIndex...); len(idx) > 0 {
+			parts = append(parts, "WITH (INDEX("+quoteList(idx)+"))")
+		}
+	case "sqlite":
+		// SQLite 只能指定一个索引
+		if len(cfg.ForceIndex) > 0 {
+			parts = append(parts, "INDEXED BY "+db.Statement.Quote(cfg.ForceIndex[0]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var scopeCommentPlaceholderRe = regexp.MustCompile(`\{(database|table|header:[A-Za-z0-9-]+)\}`)
+
+// renderScopeComment 替换注释中的 {database}、{table}、{header:Name} 占位符
+func (a *gormAdapter) renderScopeComment(db *gorm.DB, tc *tableConfig, tmpl string) string {
+	headers, _ := db.Statement.Context.Value(incomingHeaderKey{}).(http.Header)
+	return scopeCommentPlaceholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1 : len(m)-1]
+		switch {
+		case name == "database":
+			if a.config != nil {
+				return scopeCommentValue(a.config.Alias)
+			}
+			return ""
+		case name == "table":
+			return scopeCommentValue(tc.Alias)
+		case headers != nil:
+			return scopeCommentValue(headers.Get(strings.TrimPrefix(name, "header:")))
+		}
+		return ""
+	})
+}
+
+// scopeCommentValue 占位符的值只保留 [A-Za-z0-9_.:@ -]，其余字符替换为 _
+func scopeCommentValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("_.:@ -", r):
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// sanitizeSQLComment 去掉可能提前结束注释的字符；反复替换直到不再出现，避免 **// 之类拼出新的 */
+func sanitizeSQLComment(s string) string {
+	for strings.Contains(s, "*/") || strings.Contains(s, "/*") {
+		s = strings.ReplaceAll(s, "*/", "")
+		s = strings.ReplaceAll(s, "/*", "")
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == 0 {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// selectDecoration 在 SELECT 关键字前后插入注释
+type selectDecoration struct {
+	before []string
+	after  []string
+}
+
+func (d selectDecoration) ModifyStatement(stmt *gorm.Statement) {
+	c := stmt.Clauses["SELECT"]
+	if len(d.before) > 0 {
+		c.BeforeExpression = clause.Expr{SQL: strings.Join(d.before, " ")}
+	}
+	if len(d.after) > 0 {
+		c.AfterNameExpression = clause.Expr{SQL: strings.Join(d.after, " ")}
+	}
+	stmt.Clauses["SELECT"] = c
+}
+
+func (d selectDecoration) Build(clause.Builder) {}
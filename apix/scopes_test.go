@@ -0,0 +1,43 @@
+package apix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeSQLComment(t *testing.T) {
+	cases := []struct {
+		name, in string
+	}{
+		{"plain", "tenant 42"},
+		{"close", "x */ DROP TABLE users; /* y"},
+		{"nested close", "**//"},
+		{"nested open", "//**"},
+		{"interleaved", "*/*/*//"},
+		{"newline", "a\n-- b\r\x00c"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := sanitizeSQLComment(tc.in)
+			assert.NotContains(t, out, "*/")
+			assert.NotContains(t, out, "/*")
+			assert.False(t, strings.ContainsAny(out, "\n\r\x00"))
+		})
+	}
+}
+
+func TestScopeCommentValue(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"req-1:a.b@c d_e", "req-1:a.b@c d_e"},
+		{"**//", "____"},
+		{"x */ DROP TABLE t; /*", "x __ DROP TABLE t_ __"},
+		{"中文", "__"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, scopeCommentValue(tc.in), tc.in)
+	}
+}
@@ -0,0 +1,234 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"gorm.io/gorm"
+)
+
+// --------- 会话设置 ---------
+//
+// 库级或表级配置查询会话参数，表级覆盖库级，请求参数再覆盖表级，
+// 使分析类的重查询与 OLTP 接口互不影响：
+//
+//	session:
+//	  statement_timeout: 30s       # 请求级只能调小
+//	  isolation: read_committed    # read_uncommitted|read_committed|repeatable_read|snapshot|serializable
+//	  clickhouse_settings:         # 仅表级/库级配置
+//	    max_threads: 4
+//	  read_preference: secondaryPreferred
+//	  read_concern: majority
+//
+// 请求参数：?statement_timeout=5s&isolation=repeatable_read&read_preference=secondary&read_concern=local
+
+const (
+	queryParamStatementTimeout = "statement_timeout"
+	queryParamIsolation        = "isolation"
+	queryParamReadPreference   = "read_preference"
+	queryParamReadConcern      = "read_concern"
+)
+
+type sessionConfig struct {
+	StatementTimeout   time.Duration          `mapstructure:"statement_timeout"`
+	Isolation          string                 `mapstructure:"isolation"`
+	ClickHouseSettings map[string]interface{} `mapstructure:"clickhouse_settings"`
+	ReadPreference     string                 `mapstructure:"read_preference"`
+	ReadConcern        string                 `mapstructure:"read_concern"`
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read_uncommitted": sql.LevelReadUncommitted,
+	"read_committed":   sql.LevelReadCommitted,
+	"repeatable_read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelSnapshot,
+	"serializable":     sql.LevelSerializable,
+}
+
+// sessionSettings 是解析后的会话参数，随请求 context 传给适配器
+type sessionSettings struct {
+	isolation      *sql.IsolationLevel
+	readPreference *readpref.ReadPref
+	readConcern    *readconcern.ReadConcern
+}
+
+type sessionSettingsKey struct{}
+
+func sessionFromContext(ctx context.Context) sessionSettings {
+	s, _ := ctx.Value(sessionSettingsKey{}).(sessionSettings)
+	return s
+}
+
+// merge 以 o 中非空项覆盖 s
+func (s sessionConfig) merge(o sessionConfig) sessionConfig {
+	if o.StatementTimeout > 0 {
+		s.StatementTimeout = o.StatementTimeout
+	}
+	if o.Isolation != "" {
+		s.Isolation = o.Isolation
+	}
+	if len(o.ClickHouseSettings) > 0 {
+		merged := make(map[string]interface{}, len(s.ClickHouseSettings)+len(o.ClickHouseSettings))
+		for k, v := range s.ClickHouseSettings {
+			merged[k] = v
+		}
+		for k, v := range o.ClickHouseSettings {
+			merged[k] = v
+		}
+		s.ClickHouseSettings = merged
+	}
+	if o.ReadPreference != "" {
+		s.ReadPreference = o.ReadPreference
+	}
+	if o.ReadConcern != "" {
+		s.ReadConcern = o.ReadConcern
+	}
+	return s
+}
+
+// requestSession 合并库级、表级配置与请求参数
+func requestSession(dbCfg databaseConfig, tc *tableConfig, query url.Values) (sessionConfig, error) {
+	s := dbCfg.Session.merge(tc.Session)
+	if v := query.Get(queryParamStatementTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return s, fmt.Errorf("invalid %s: %s", queryParamStatementTimeout, v)
+		}
+		if s.StatementTimeout == 0 || d < s.StatementTimeout {
+			s.StatementTimeout = d
+		}
+	}
+	if v := query.Get(queryParamIsolation); v != "" {
+		s.Isolation = v
+	}
+	if v := query.Get(queryParamReadPreference); v != "" {
+		s.ReadPreference = v
+	}
+	if v := query.Get(queryParamReadConcern); v != "" {
+		s.ReadConcern = v
+	}
+	return s, nil
+}
+
+// withSession 把会话参数放入请求 context：超时直接作用于 context，其余由适配器读取
+func (dm *databaseManager) withSession(c *gin.Context) {
+	dbName, tableAlias := c.Param("database"), c.Param("table")
+	if dbName == "" || tableAlias == "" {
+		c.Next()
+		return
+	}
+	_, tc, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.Next()
+		return
+	}
+	ctx, cancel, err := dm.sessionContext(c.Request.Context(), dbName, tc, c.Request.URL.Query())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// sessionContext 按库、表配置与请求参数生成带会话参数的 context，GraphQL 直连查询同样使用
+func (dm *databaseManager) sessionContext(ctx context.Context, dbName string, tc *tableConfig, query url.Values) (context.Context, context.CancelFunc, error) {
+	dm.mutex.RLock()
+	dbCfg := dm.config.Databases[dbName]
+	dm.mutex.RUnlock()
+	cfg, err := requestSession(dbCfg, tc, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var settings sessionSettings
+	if cfg.Isolation != "" {
+		level, ok := isolationLevels[strings.ToLower(cfg.Isolation)]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid isolation: %s", cfg.Isolation)
+		}
+		settings.isolation = &level
+	}
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err == nil {
+			settings.readPreference, err = readpref.New(mode)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid read_preference: %s", cfg.ReadPreference)
+		}
+	}
+	if cfg.ReadConcern != "" {
+		switch cfg.ReadConcern {
+		case "local", "majority", "available", "linearizable", "snapshot":
+			settings.readConcern = &readconcern.ReadConcern{Level: cfg.ReadConcern}
+		default:
+			return nil, nil, fmt.Errorf("invalid read_concern: %s", cfg.ReadConcern)
+		}
+	}
+
+	ctx = context.WithValue(ctx, sessionSettingsKey{}, settings)
+	if strings.ToLower(dbCfg.Type) == "clickhouse" {
+		chSettings := clickhouse.Settings{}
+		for k, v := range cfg.ClickHouseSettings {
+			chSettings[k] = v
+		}
+		if cfg.StatementTimeout > 0 {
+			if _, ok := chSettings["max_execution_time"]; !ok {
+				chSettings["max_execution_time"] = int(cfg.StatementTimeout.Seconds() + 0.5)
+			}
+		}
+		if len(chSettings) > 0 {
+			ctx = clickhouse.Context(ctx, clickhouse.WithSettings(chSettings))
+		}
+	}
+	if cfg.StatementTimeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, cfg.StatementTimeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// ---- 适配器侧 ----
+
+// read 执行读操作；配置了隔离级别时放进只读事务
+func (a *gormAdapter) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	s := sessionFromContext(ctx)
+	if s.isolation == nil {
+		return fn(a.db.WithContext(ctx))
+	}
+	return a.db.WithContext(ctx).Transaction(fn, &sql.TxOptions{Isolation: *s.isolation, ReadOnly: true})
+}
+
+// txOptions 返回写事务的隔离级别
+func txOptions(ctx context.Context) []*sql.TxOptions {
+	if s := sessionFromContext(ctx); s.isolation != nil {
+		return []*sql.TxOptions{{Isolation: *s.isolation}}
+	}
+	return nil
+}
+
+// collection 按会话设置的读偏好/读关注返回集合
+func (a *mongoAdapter) collection(ctx context.Context, tc *tableConfig, extra ...*options.CollectionOptions) *mongo.Collection {
+	s := sessionFromContext(ctx)
+	opts := options.Collection()
+	if s.readPreference != nil {
+		opts.SetReadPreference(s.readPreference)
+	}
+	if s.readConcern != nil {
+		opts.SetReadConcern(s.readConcern)
+	}
+	return a.client.Database(a.database).Collection(tc.Name, append([]*options.CollectionOptions{opts}, extra...)...)
+}
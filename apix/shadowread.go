@@ -0,0 +1,300 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 影子读 ---------
+//
+// 更换适配器实现或切换到新副本前，把一部分读请求同时发到另一个已配置的库，异步比较结果与耗时。库配置：
+//
+//	shadow_read:
+//	  database: test_v2     # 影子库别名，可以是同一数据库的副本，或以另一种类型/参数连接的同一数据库
+//	  percent: 5            # 抽样比例（0-100）
+//	  timeout: 10s          # 影子查询超时
+//	  tables: [user]        # 只抽样这些表，默认全部；影子库中须有同别名的表
+//
+// 列表与单条查询在主库返回后按比例抽样，影子查询在后台以相同参数执行，不影响响应内容与耗时；
+// 结果不一致（记录数、total、字段值，值比较忽略驱动间的类型差异）或只有一侧出错时写日志。
+// 未指定排序的列表按主键配对比较。同时执行的影子查询超过上限时跳过本次抽样。
+//
+// 管理接口（受 operation_roles.shadow_reads 控制，默认 admin）：
+//
+//	GET /api/admin/shadow-reads   各库的抽样数、不一致数、两边平均耗时与最近的不一致记录
+
+const (
+	defaultShadowReadTimeout = 10 * time.Second
+	maxShadowReadsInflight   = 32
+	maxShadowReadMismatches  = 20
+)
+
+type shadowReadConfig struct {
+	Database string        `mapstructure:"database"`
+	Percent  float64       `mapstructure:"percent"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Tables   []string      `mapstructure:"tables"`
+}
+
+type shadowMismatch struct {
+	Table  string    `json:"table"`
+	Kind   string    `json:"kind"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+type shadowReadStats struct {
+	Database         string           `json:"database"`
+	ShadowDatabase   string           `json:"shadow_database"`
+	Sampled          int64            `json:"sampled"`
+	Matched          int64            `json:"matched"`
+	Mismatched       int64            `json:"mismatched"`
+	Skipped          int64            `json:"skipped"` // 影子查询并发已满未执行
+	PrimaryAvgMs     float64          `json:"primary_avg_ms"`
+	ShadowAvgMs      float64          `json:"shadow_avg_ms"`
+	RecentMismatches []shadowMismatch `json:"recent_mismatches"`
+
+	primaryTotal time.Duration
+	shadowTotal  time.Duration
+}
+
+// readTarget 为执行读请求的适配器与表配置
+type readTarget struct {
+	adapter databaseAdapter
+	tc      *tableConfig
+}
+
+// shadowReader 保存各库的影子读配置与统计
+type shadowReader struct {
+	mu       sync.Mutex
+	configs  map[string]shadowReadConfig
+	stats    map[string]*shadowReadStats
+	inflight chan struct{}
+}
+
+// setupShadowReads 校验各库的 shadow_read 配置
+func (dm *databaseManager) setupShadowReads() error {
+	s := &shadowReader{configs: map[string]shadowReadConfig{}, stats: map[string]*shadowReadStats{}, inflight: make(chan struct{}, maxShadowReadsInflight)}
+	for name, dbCfg := range dm.config.Databases {
+		cfg := dbCfg.ShadowRead
+		if cfg.Database == "" {
+			continue
+		}
+		if cfg.Database == name {
+			return fmt.Errorf("database %s: shadow_read database must be another database", name)
+		}
+		if _, ok := dm.adapters[cfg.Database]; !ok {
+			return fmt.Errorf("database %s: shadow_read database %s is not configured", name, cfg.Database)
+		}
+		if cfg.Percent <= 0 || cfg.Percent > 100 {
+			return fmt.Errorf("database %s: shadow_read percent must be in (0, 100], got %v", name, cfg.Percent)
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultShadowReadTimeout
+		}
+		s.configs[name] = cfg
+		s.stats[name] = &shadowReadStats{Database: name, ShadowDatabase: cfg.Database, RecentMismatches: []shadowMismatch{}}
+	}
+	if len(s.configs) > 0 {
+		dm.shadowReads = s
+	}
+	return nil
+}
+
+// shadowSample 判断本次读请求是否抽样，返回影子库的适配器与表配置
+func (dm *databaseManager) shadowSample(dbName string, tc *tableConfig) (shadowReadConfig, readTarget, bool) {
+	s := dm.shadowReads
+	if s == nil {
+		return shadowReadConfig{}, readTarget{}, false
+	}
+	cfg, ok := s.configs[dbName]
+	if !ok || (len(cfg.Tables) > 0 && !contains(cfg.Tables, tc.Alias)) || rand.Float64()*100 >= cfg.Percent {
+		return cfg, readTarget{}, false
+	}
+	adapter, stc, err := dm.getAdapterAndTableConfig(cfg.Database, tc.Alias)
+	if err != nil {
+		return cfg, readTarget{}, false
+	}
+	return cfg, readTarget{adapter: adapter, tc: stc}, true
+}
+
+// shadowRead 抽中时在影子库上异步执行 run 并与主库结果比较；primary 在抽中后立即调用，返回调用方不会再修改的副本
+func shadowRead[T any](dm *databaseManager, ctx context.Context, dbName string, tc *tableConfig, kind string,
+	primary func() T, primaryErr error, primaryElapsed time.Duration,
+	run func(ctx context.Context, target readTarget) (T, error),
+	diff func(primary, shadow T) string) {
+	cfg, target, ok := dm.shadowSample(dbName, tc)
+	if !ok {
+		return
+	}
+	s := dm.shadowReads
+	select {
+	case s.inflight <- struct{}{}:
+	default:
+		s.mu.Lock()
+		s.stats[dbName].Skipped++
+		s.mu.Unlock()
+		return
+	}
+	snapshot := primary()
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.Timeout)
+	go func() {
+		defer func() { <-s.inflight }()
+		defer cancel()
+		start := time.Now()
+		shadow, shadowErr := run(ctx, target)
+		elapsed := time.Since(start)
+		var reason string
+		switch {
+		case primaryErr != nil && shadowErr != nil:
+			if isNotFoundErr(primaryErr) != isNotFoundErr(shadowErr) {
+				reason = fmt.Sprintf("errors differ: primary %v, shadow %v", primaryErr, shadowErr)
+			}
+		case primaryErr != nil && isNotFoundErr(primaryErr):
+			reason = "record not found in primary but found in shadow"
+		case primaryErr != nil:
+			reason = "primary failed, shadow succeeded: " + primaryErr.Error()
+		case isNotFoundErr(shadowErr):
+			reason = "record not found in shadow"
+		case shadowErr != nil:
+			reason = "shadow failed: " + shadowErr.Error()
+		default:
+			reason = diff(snapshot, shadow)
+		}
+		s.record(dbName, tc.Alias, kind, reason, primaryElapsed, elapsed)
+	}()
+}
+
+// shadowList 对列表查询抽样；未指定排序时按主键配对比较
+func (dm *databaseManager) shadowList(ctx context.Context, dbName string, tc *tableConfig, params listParams, data []map[string]interface{}, total int64, err error, elapsed time.Duration) {
+	type listResult struct {
+		data  []map[string]interface{}
+		total int64
+	}
+	if dm.shadowReads == nil {
+		return
+	}
+	byKey := ""
+	if params.Order == "" && params.Keyset == nil {
+		byKey = tc.PrimaryKey
+	}
+	params.QueryFilters = maps.Clone(params.QueryFilters)
+	shadowRead(dm, ctx, dbName, tc, "list",
+		func() listResult { return listResult{copyRecords(data), total} }, err, elapsed,
+		func(ctx context.Context, target readTarget) (listResult, error) {
+			data, total, err := target.adapter.List(ctx, target.tc, params)
+			return listResult{data, total}, err
+		},
+		func(primary, shadow listResult) string {
+			if primary.total != shadow.total {
+				return fmt.Sprintf("total differs: primary %d, shadow %d", primary.total, shadow.total)
+			}
+			return diffShadowList(primary.data, shadow.data, byKey)
+		})
+}
+
+func (s *shadowReader) record(dbName, table, kind, reason string, primary, shadow time.Duration) {
+	s.mu.Lock()
+	st := s.stats[dbName]
+	st.Sampled++
+	st.primaryTotal += primary
+	st.shadowTotal += shadow
+	if reason == "" {
+		st.Matched++
+	} else {
+		st.Mismatched++
+		st.RecentMismatches = append(st.RecentMismatches, shadowMismatch{Table: table, Kind: kind, Reason: reason, Time: time.Now()})
+		if len(st.RecentMismatches) > maxShadowReadMismatches {
+			st.RecentMismatches = st.RecentMismatches[1:]
+		}
+	}
+	s.mu.Unlock()
+	if reason != "" {
+		log.Printf("[shadow-read] %s/%s %s mismatch against %s: %s (primary %s, shadow %s)",
+			dbName, table, kind, st.ShadowDatabase, reason, primary.Round(time.Microsecond), shadow.Round(time.Microsecond))
+	}
+}
+
+// ---- 结果比较 ----
+
+// diffShadowRecord 比较两条记录的字段集合与取值
+func diffShadowRecord(primary, shadow map[string]interface{}) string {
+	for k, v := range primary {
+		w, ok := shadow[k]
+		if !ok {
+			return fmt.Sprintf("field %s missing in shadow", k)
+		}
+		if !mirrorValueEqual(v, w) {
+			return fmt.Sprintf("field %s differs: primary %v, shadow %v", k, v, w)
+		}
+	}
+	for k := range shadow {
+		if _, ok := primary[k]; !ok {
+			return fmt.Sprintf("field %s only in shadow", k)
+		}
+	}
+	return ""
+}
+
+// diffShadowList 比较两个列表；byKey 非空时按该字段配对（结果顺序不确定时）
+func diffShadowList(primary, shadow []map[string]interface{}, byKey string) string {
+	if len(primary) != len(shadow) {
+		return fmt.Sprintf("row count differs: primary %d, shadow %d", len(primary), len(shadow))
+	}
+	if byKey == "" {
+		for i := range primary {
+			if d := diffShadowRecord(primary[i], shadow[i]); d != "" {
+				return fmt.Sprintf("row %d: %s", i, d)
+			}
+		}
+		return ""
+	}
+	index := make(map[string]map[string]interface{}, len(shadow))
+	for _, r := range shadow {
+		index[keyValueString(r[byKey])] = r
+	}
+	for _, r := range primary {
+		key := keyValueString(r[byKey])
+		other, ok := index[key]
+		if !ok {
+			return fmt.Sprintf("%s=%s missing in shadow", byKey, key)
+		}
+		if d := diffShadowRecord(r, other); d != "" {
+			return fmt.Sprintf("%s=%s: %s", byKey, key, d)
+		}
+	}
+	return ""
+}
+
+// ---- 管理接口 ----
+
+func (dm *databaseManager) handleShadowReads(c *gin.Context) {
+	if !dm.authorize(c, nil, opShadowReads) {
+		return
+	}
+	result := []shadowReadStats{}
+	if s := dm.shadowReads; s != nil {
+		s.mu.Lock()
+		for _, st := range s.stats {
+			snapshot := *st
+			if st.Sampled > 0 {
+				snapshot.PrimaryAvgMs = float64(st.primaryTotal.Microseconds()) / 1000 / float64(st.Sampled)
+				snapshot.ShadowAvgMs = float64(st.shadowTotal.Microseconds()) / 1000 / float64(st.Sampled)
+			}
+			snapshot.RecentMismatches = append([]shadowMismatch{}, st.RecentMismatches...)
+			result = append(result, snapshot)
+		}
+		s.mu.Unlock()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Database < result[j].Database })
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
@@ -0,0 +1,532 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// --------- 分片表路由 ---------
+//
+// 同一逻辑库水平拆分到多个实例（如 MySQL 分库）时，按分片键把请求路由到对应分片：
+//
+//	type: mysql
+//	shards:
+//	  strategy: hash          # hash：整数键取模、其它键 FNV 哈希取模；range：按整数键区间
+//	  key: id                 # 分片键（物理列名），默认为表主键
+//	  nodes:
+//	    - dsn: "user:pass@tcp(db0:3306)/shop"
+//	      max: 1000000        # range 策略：[min, max)，省略表示无界
+//	    - dsn: "user:pass@tcp(db1:3306)/shop"
+//	      min: 1000000
+//
+// 带分片键的单条读写只访问一个分片；列表、计数与不带分片键的操作并发访问所有分片后合并，
+// 列表按 order（默认主键）归并排序后分页。跨分片写入不保证原子性。
+// 元数据取自 dsn，未配置 dsn 时取第一个分片。
+
+const (
+	shardStrategyHash  = "hash"
+	shardStrategyRange = "range"
+)
+
+type shardsConfig struct {
+	Strategy string       `mapstructure:"strategy"`
+	Key      string       `mapstructure:"key"`
+	Nodes    []shardRange `mapstructure:"nodes"`
+}
+
+type shardRange struct {
+	DSN string `mapstructure:"dsn"`
+	Min *int64 `mapstructure:"min"`
+	Max *int64 `mapstructure:"max"`
+}
+
+type shardedAdapter struct {
+	shards []*gormAdapter
+	cfg    shardsConfig
+}
+
+func shardDialector(dbType, dsn string) (gorm.Dialector, error) {
+	switch dbType {
+	case "mysql", "tidb":
+		return mysql.Open(dsn), nil
+	case "postgresql", "cockroachdb":
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("sharding is not supported for %s", dbType)
+}
+
+func newShardedAdapter(dbConfig *databaseConfig, gormLogger logger.Interface) (*shardedAdapter, error) {
+	cfg := dbConfig.Shards
+	switch cfg.Strategy {
+	case "":
+		cfg.Strategy = shardStrategyHash
+	case shardStrategyHash, shardStrategyRange:
+	default:
+		return nil, fmt.Errorf("unknown shard strategy %q", cfg.Strategy)
+	}
+	a := &shardedAdapter{cfg: cfg}
+	nodeConfig := *dbConfig
+	nodeConfig.DSNs = nil
+	for i, node := range cfg.Nodes {
+		dialector, err := shardDialector(strings.ToLower(dbConfig.Type), node.DSN)
+		if err != nil {
+			return nil, err
+		}
+		db, err := setupGormDB(nodeConfig, gormLogger, dialector)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		a.shards = append(a.shards, newGormAdapter(db, dbConfig))
+	}
+	return a, nil
+}
+
+func (a *shardedAdapter) shardKey(tc *tableConfig) string {
+	if a.cfg.Key != "" {
+		return a.cfg.Key
+	}
+	return tc.PrimaryKey
+}
+
+// shardKeyInt 把分片键值统一为整数（JSON 数字、路径中的字符串）
+func shardKeyInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), true
+		}
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	case fmt.Stringer:
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// shardFor 返回分片键值所在的分片
+func (a *shardedAdapter) shardFor(v interface{}) (*gormAdapter, error) {
+	if v == nil {
+		return nil, fmt.Errorf("shard key value is null")
+	}
+	n := len(a.shards)
+	if a.cfg.Strategy == shardStrategyRange {
+		key, ok := shardKeyInt(v)
+		if !ok {
+			return nil, fmt.Errorf("range sharding requires an integer key, got %v", v)
+		}
+		for i, node := range a.cfg.Nodes {
+			if (node.Min == nil || key >= *node.Min) && (node.Max == nil || key < *node.Max) {
+				return a.shards[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no shard covers key %d", key)
+	}
+	if key, ok := shardKeyInt(v); ok {
+		idx := key % int64(n)
+		if idx < 0 {
+			idx += int64(n)
+		}
+		return a.shards[idx], nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(fmt.Sprint(v)))
+	return a.shards[h.Sum32()%uint32(n)], nil
+}
+
+// fanOut 并发在所有分片上执行，返回第一个错误
+func (a *shardedAdapter) fanOut(fn func(i int, s *gormAdapter) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(a.shards))
+	for i, s := range a.shards {
+		wg.Add(1)
+		go func(i int, s *gormAdapter) {
+			defer wg.Done()
+			errs[i] = fn(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (a *shardedAdapter) List(ctx context.Context, tc *tableConfig, params listParams) ([]map[string]interface{}, int64, error) {
+	order := params.Order
+	if order == "" {
+		order = tc.PrimaryKey
+	}
+	desc := strings.HasPrefix(order, "-")
+	orderField := strings.TrimPrefix(order, "-")
+	// 每个分片取前 page*page_size 条，归并后再分页
+	shardParams := params
+	shardParams.Page, shardParams.PageSize = 1, params.Page*params.PageSize
+	shardParams.Order = order
+	stripOrderField := false
+	if params.Fields != "" && orderField != "" && !contains(parseStringList(params.Fields), orderField) {
+		shardParams.Fields = params.Fields + "," + orderField
+		stripOrderField = true
+	}
+	results := make([][]map[string]interface{}, len(a.shards))
+	totals := make([]int64, len(a.shards))
+	err := a.fanOut(func(i int, s *gormAdapter) error {
+		var err error
+		results[i], totals[i], err = s.List(ctx, tc, shardParams)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	var merged []map[string]interface{}
+	var total int64
+	for i := range results {
+		merged = append(merged, results[i]...)
+		total += totals[i]
+	}
+	if orderField != "" {
+		sort.SliceStable(merged, func(i, j int) bool {
+			x, y := merged[i][orderField], merged[j][orderField]
+			if x == nil || y == nil {
+				return (x == nil && y != nil) != desc
+			}
+			c, _ := compareValues(x, y)
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	start := (params.Page - 1) * params.PageSize
+	if start > len(merged) {
+		start = len(merged)
+	}
+	end := start + params.PageSize
+	if end > len(merged) {
+		end = len(merged)
+	}
+	page := merged[start:end]
+	if stripOrderField {
+		for _, row := range page {
+			delete(row, orderField)
+		}
+	}
+	return page, total, nil
+}
+
+// groupRecords 按分片键把记录分组，返回每个分片的记录及其原始下标
+func (a *shardedAdapter) groupRecords(tc *tableConfig, records []map[string]interface{}) (map[*gormAdapter][]int, error) {
+	key := a.shardKey(tc)
+	groups := map[*gormAdapter][]int{}
+	for i, r := range records {
+		v, ok := r[key]
+		if !ok {
+			return nil, fmt.Errorf("record %d missing shard key '%s'", i, key)
+		}
+		s, err := a.shardFor(v)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		groups[s] = append(groups[s], i)
+	}
+	return groups, nil
+}
+
+func (a *shardedAdapter) BatchCreate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) ([]interface{}, []map[string]interface{}, error) {
+	groups, err := a.groupRecords(tc, records)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]interface{}, len(records))
+	created := make([]map[string]interface{}, len(records))
+	for s, idx := range groups {
+		batch := make([]map[string]interface{}, len(idx))
+		for j, i := range idx {
+			batch[j] = records[i]
+		}
+		batchIDs, batchRecords, err := s.BatchCreate(ctx, tc, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, i := range idx {
+			if j < len(batchIDs) {
+				ids[i] = batchIDs[j]
+			}
+			if j < len(batchRecords) {
+				created[i] = batchRecords[j]
+			}
+		}
+	}
+	return ids, created, nil
+}
+
+func (a *shardedAdapter) BatchUpdate(ctx context.Context, tc *tableConfig, records []map[string]interface{}) (int64, int64, error) {
+	key := a.shardKey(tc)
+	var matched, modified int64
+	var routed []map[string]interface{}
+	for _, r := range records {
+		if _, ok := r[key]; ok {
+			routed = append(routed, r)
+			continue
+		}
+		// 不含分片键的记录广播到所有分片
+		var mu sync.Mutex
+		err := a.fanOut(func(_ int, s *gormAdapter) error {
+			m, n, err := s.BatchUpdate(ctx, tc, []map[string]interface{}{r})
+			mu.Lock()
+			matched, modified = matched+m, modified+n
+			mu.Unlock()
+			return err
+		})
+		if err != nil {
+			return matched, modified, err
+		}
+	}
+	groups, err := a.groupRecords(tc, routed)
+	if err != nil {
+		return matched, modified, err
+	}
+	for s, idx := range groups {
+		batch := make([]map[string]interface{}, len(idx))
+		for j, i := range idx {
+			batch[j] = routed[i]
+		}
+		m, n, err := s.BatchUpdate(ctx, tc, batch)
+		matched, modified = matched+m, modified+n
+		if err != nil {
+			return matched, modified, err
+		}
+	}
+	return matched, modified, nil
+}
+
+func (a *shardedAdapter) BatchDelete(ctx context.Context, tc *tableConfig, ids []interface{}) (int64, error) {
+	var affected int64
+	if a.shardKey(tc) != tc.PrimaryKey {
+		var mu sync.Mutex
+		err := a.fanOut(func(_ int, s *gormAdapter) error {
+			n, err := s.BatchDelete(ctx, tc, ids)
+			mu.Lock()
+			affected += n
+			mu.Unlock()
+			return err
+		})
+		return affected, err
+	}
+	groups := map[*gormAdapter][]interface{}{}
+	for _, id := range ids {
+		s, err := a.shardFor(id)
+		if err != nil {
+			return 0, err
+		}
+		groups[s] = append(groups[s], id)
+	}
+	for s, batch := range groups {
+		n, err := s.BatchDelete(ctx, tc, batch)
+		affected += n
+		if err != nil {
+			return affected, err
+		}
+	}
+	return affected, nil
+}
+
+// routeFilter 过滤条件含分片键时返回对应分片，否则返回 nil 表示需访问所有分片
+func (a *shardedAdapter) routeFilter(tc *tableConfig, filter map[string]interface{}) (*gormAdapter, error) {
+	v, ok := filter[a.shardKey(tc)]
+	if !ok {
+		return nil, nil
+	}
+	return a.shardFor(v)
+}
+
+func (a *shardedAdapter) GetOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, fields string) (map[string]interface{}, error) {
+	s, err := a.routeFilter(tc, filter)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		return s.GetOne(ctx, tc, filter, fields)
+	}
+	records := make([]map[string]interface{}, len(a.shards))
+	err = a.fanOut(func(i int, s *gormAdapter) error {
+		record, err := s.GetOne(ctx, tc, filter, fields)
+		if isNotFoundErr(err) {
+			return nil
+		}
+		records[i] = record
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r != nil {
+			return r, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (a *shardedAdapter) UpdateOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}, data map[string]interface{}) (int64, int64, error) {
+	s, err := a.routeFilter(tc, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s != nil {
+		return s.UpdateOne(ctx, tc, filter, data)
+	}
+	var matched, modified int64
+	var mu sync.Mutex
+	err = a.fanOut(func(_ int, s *gormAdapter) error {
+		m, n, err := s.UpdateOne(ctx, tc, filter, data)
+		if isNotFoundErr(err) {
+			return nil
+		}
+		mu.Lock()
+		matched, modified = matched+m, modified+n
+		mu.Unlock()
+		return err
+	})
+	if err == nil && matched == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return matched, modified, err
+}
+
+func (a *shardedAdapter) DeleteOne(ctx context.Context, tc *tableConfig, filter map[string]interface{}) (int64, error) {
+	s, err := a.routeFilter(tc, filter)
+	if err != nil {
+		return 0, err
+	}
+	if s != nil {
+		return s.DeleteOne(ctx, tc, filter)
+	}
+	var affected int64
+	var mu sync.Mutex
+	err = a.fanOut(func(_ int, s *gormAdapter) error {
+		n, err := s.DeleteOne(ctx, tc, filter)
+		if isNotFoundErr(err) {
+			return nil
+		}
+		mu.Lock()
+		affected += n
+		mu.Unlock()
+		return err
+	})
+	if err == nil && affected == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return affected, err
+}
+
+func (a *shardedAdapter) CountAll(ctx context.Context, tc *tableConfig) (int64, error) {
+	counts := make([]int64, len(a.shards))
+	err := a.fanOut(func(i int, s *gormAdapter) error {
+		var err error
+		counts[i], err = s.CountAll(ctx, tc)
+		return err
+	})
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total, err
+}
+
+func (a *shardedAdapter) Close() error {
+	var errs []error
+	for _, s := range a.shards {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mutateWhere 先汇总所有分片的匹配数做 max_affected 校验，再逐个分片执行
+func (a *shardedAdapter) mutateWhere(opts mutateWhereOptions, run func(s *gormAdapter, opts mutateWhereOptions) (int64, int64, error)) (int64, int64, error) {
+	var matched, affected int64
+	var mu sync.Mutex
+	if opts.MaxAffected > 0 || opts.DryRun {
+		err := a.fanOut(func(_ int, s *gormAdapter) error {
+			m, _, err := run(s, mutateWhereOptions{DryRun: true})
+			mu.Lock()
+			matched += m
+			mu.Unlock()
+			return err
+		})
+		if err != nil {
+			return matched, 0, err
+		}
+		if opts.MaxAffected > 0 && matched > opts.MaxAffected {
+			return matched, 0, &tooManyAffectedError{Matched: matched, Max: opts.MaxAffected}
+		}
+		if opts.DryRun {
+			return matched, 0, nil
+		}
+		matched = 0
+	}
+	err := a.fanOut(func(_ int, s *gormAdapter) error {
+		m, n, err := run(s, mutateWhereOptions{})
+		mu.Lock()
+		matched, affected = matched+m, affected+n
+		mu.Unlock()
+		return err
+	})
+	return matched, affected, err
+}
+
+func (a *shardedAdapter) UpdateWhere(ctx context.Context, tc *tableConfig, filters url.Values, data map[string]interface{}, opts mutateWhereOptions) (int64, int64, error) {
+	return a.mutateWhere(opts, func(s *gormAdapter, o mutateWhereOptions) (int64, int64, error) {
+		return s.UpdateWhere(ctx, tc, filters, data, o)
+	})
+}
+
+func (a *shardedAdapter) DeleteWhere(ctx context.Context, tc *tableConfig, filters url.Values, opts mutateWhereOptions) (int64, int64, error) {
+	return a.mutateWhere(opts, func(s *gormAdapter, o mutateWhereOptions) (int64, int64, error) {
+		return s.DeleteWhere(ctx, tc, filters, o)
+	})
+}
+
+// runInRollbackTx 在每个分片上开启事务执行后全部回滚
+func (a *shardedAdapter) runInRollbackTx(ctx context.Context, fn func(databaseAdapter) error) error {
+	txAdapter := &shardedAdapter{cfg: a.cfg}
+	defer func() {
+		for _, s := range txAdapter.shards {
+			s.db.Rollback()
+		}
+	}()
+	for i, s := range a.shards {
+		tx := s.db.WithContext(ctx).Begin()
+		if tx.Error != nil {
+			return fmt.Errorf("shard %d: %w", i, tx.Error)
+		}
+		txAdapter.shards = append(txAdapter.shards, &gormAdapter{db: tx, config: s.config})
+	}
+	return fn(txAdapter)
+}
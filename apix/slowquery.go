@@ -0,0 +1,188 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+)
+
+// --------- 慢查询捕获 ---------
+//
+// 每个 SQL 库在内存中保留最近的慢查询（环形缓冲），无需再去翻轮转后的 gorm 日志：
+//
+//	slow_query:
+//	  size: 100          # 每个库保留的条数
+//	  threshold: ""      # 慢查询阈值，默认取 gorm_log.slow_threshold
+//
+// 管理接口（受 operation_roles.slow_queries 控制，默认 admin）：
+//
+//	GET /api/admin/slow-queries?database=test&limit=20   按耗时从高到低返回
+
+const (
+	defaultSlowQuerySize = 100
+	// 单条语句最多保留的长度，避免大批量 INSERT 占满内存
+	maxSlowQuerySQLLen = 4096
+)
+
+type slowQueryConfig struct {
+	Size      int    `mapstructure:"size"`
+	Threshold string `mapstructure:"threshold"`
+}
+
+type slowQuery struct {
+	Database   string    `json:"database"`
+	Table      string    `json:"table,omitempty"`
+	Statement  string    `json:"statement"`
+	DurationMs float64   `json:"duration_ms"`
+	Rows       int64     `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// slowQueryRing 是单个库的环形缓冲，写满后覆盖最旧的记录
+type slowQueryRing struct {
+	mu      sync.Mutex
+	entries []slowQuery
+	next    int
+	full    bool
+}
+
+func newSlowQueryRing(size int) *slowQueryRing {
+	return &slowQueryRing{entries: make([]slowQuery, size)}
+}
+
+func (r *slowQueryRing) add(q slowQuery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = q
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *slowQueryRing) snapshot() []slowQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.entries)
+	}
+	return append([]slowQuery(nil), r.entries[:n]...)
+}
+
+// slowQueryLogger 包装 gorm 日志，在原有输出之外记录超过阈值的查询
+type slowQueryLogger struct {
+	logger.Interface
+	database  string
+	threshold time.Duration
+	ring      *slowQueryRing
+}
+
+func (l *slowQueryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &slowQueryLogger{Interface: l.Interface.LogMode(level), database: l.database, threshold: l.threshold, ring: l.ring}
+}
+
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+	elapsed := time.Since(begin)
+	if elapsed < l.threshold {
+		return
+	}
+	sql, rows := fc()
+	q := slowQuery{
+		Database:   l.database,
+		Table:      sqlTableName(sql),
+		Statement:  sql,
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+		Rows:       rows,
+		Time:       begin,
+	}
+	if len(q.Statement) > maxSlowQuerySQLLen {
+		q.Statement = q.Statement[:maxSlowQuerySQLLen] + "..."
+	}
+	if err != nil {
+		q.Error = err.Error()
+	}
+	l.ring.add(q)
+}
+
+var sqlInsertTableRe = regexp.MustCompile(`(?i)\bINTO\s+[` + "`" + `"\[]?(\w+)[` + "`" + `"\]]?`)
+
+// sqlTableName 粗略取出语句操作的表名
+func sqlTableName(sql string) string {
+	clean := sqlStringRe.ReplaceAllString(stripSQLComments(sql), "?")
+	if m := sqlInsertTableRe.FindStringSubmatch(clean); m != nil {
+		return m[1]
+	}
+	if m := sqlTableRe.FindStringSubmatch(clean); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// slowQueryThreshold 解析 slow_query.threshold，未配置时沿用 gorm_log.slow_threshold
+func (cfg *dmConfig) slowQueryThreshold() (time.Duration, error) {
+	if cfg.SlowQuery.Threshold == "" {
+		return time.ParseDuration(cfg.GormLog.SlowThreshold)
+	}
+	d, err := time.ParseDuration(cfg.SlowQuery.Threshold)
+	if err != nil {
+		return 0, fmt.Errorf("invalid slow_query.threshold: %w", err)
+	}
+	return d, nil
+}
+
+// slowQueryLogger 为库 name 创建带慢查询记录的 gorm 日志
+func (dm *databaseManager) slowQueryLogger(name string, base logger.Interface, threshold time.Duration) logger.Interface {
+	size := dm.config.SlowQuery.Size
+	if size <= 0 {
+		size = defaultSlowQuerySize
+	}
+	ring := newSlowQueryRing(size)
+	dm.slowQueries[name] = ring
+	return &slowQueryLogger{Interface: base, database: name, threshold: threshold, ring: ring}
+}
+
+func (dm *databaseManager) handleSlowQueries(c *gin.Context) {
+	if !dm.authorize(c, nil, opSlowQueries) {
+		return
+	}
+	var rings map[string]*slowQueryRing
+	if name := c.Query("database"); name != "" {
+		ring, ok := dm.slowQueries[name]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No slow query log for database: " + name})
+			return
+		}
+		rings = map[string]*slowQueryRing{name: ring}
+	} else {
+		rings = dm.slowQueries
+	}
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+
+	result := []slowQuery{}
+	for _, ring := range rings {
+		result = append(result, ring.snapshot()...)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DurationMs > result[j].DurationMs })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
@@ -0,0 +1,283 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// --------- Snowflake ---------
+//
+// 通过 gosnowflake 驱动接入，复用 gormAdapter。数据库配置：
+//
+//	type: snowflake
+//	dsn: "user:password@account/DB/SCHEMA"
+//	warehouse: COMPUTE_WH   # 可选，覆盖 DSN 中的 warehouse
+//	role: ANALYST           # 可选，覆盖 DSN 中的 role
+//
+// Snowflake 未加引号的标识符不区分大小写（统一按大写存储），简单标识符不加引号，
+// 这样 API 中的小写字段名也能对应到大写列。
+
+// snowflakeDSN 把配置中的 warehouse/role 合并到 DSN
+func snowflakeDSN(dsn, warehouse, role string) (string, error) {
+	if warehouse == "" && role == "" {
+		return dsn, nil
+	}
+	cfg, err := gosnowflake.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if warehouse != "" {
+		cfg.Warehouse = warehouse
+	}
+	if role != "" {
+		cfg.Role = role
+	}
+	return gosnowflake.DSN(cfg)
+}
+
+type snowflakeDialector struct {
+	DSN string
+}
+
+func openSnowflake(dsn string) gorm.Dialector {
+	return &snowflakeDialector{DSN: dsn}
+}
+
+func (d *snowflakeDialector) Name() string {
+	return "snowflake"
+}
+
+func (d *snowflakeDialector) Initialize(db *gorm.DB) error {
+	// 不支持 RETURNING 与 SAVEPOINT
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{
+		CreateClauses: []string{"INSERT", "VALUES"},
+		UpdateClauses: []string{"UPDATE", "SET", "WHERE"},
+		DeleteClauses: []string{"DELETE", "FROM", "WHERE"},
+	})
+	db.Config.DisableNestedTransaction = true
+	conn, err := sql.Open("snowflake", d.DSN)
+	if err != nil {
+		return err
+	}
+	db.ConnPool = conn
+	return nil
+}
+
+func (d *snowflakeDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}}
+}
+
+func (d *snowflakeDialector) DataTypeOf(field *schema.Field) string {
+	switch field.DataType {
+	case schema.Bool:
+		return "BOOLEAN"
+	case schema.Int, schema.Uint:
+		return "NUMBER(38,0)"
+	case schema.Float:
+		return "FLOAT"
+	case schema.Time:
+		return "TIMESTAMP_NTZ"
+	case schema.Bytes:
+		return "BINARY"
+	default:
+		return "VARCHAR"
+	}
+}
+
+func (d *snowflakeDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (d *snowflakeDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteByte('?')
+}
+
+var snowflakeSimpleIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+func (d *snowflakeDialector) QuoteTo(writer clause.Writer, str string) {
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		if snowflakeSimpleIdent.MatchString(part) {
+			writer.WriteString(part)
+			continue
+		}
+		writer.WriteByte('"')
+		writer.WriteString(strings.ReplaceAll(part, `"`, `""`))
+		writer.WriteByte('"')
+	}
+}
+
+func (d *snowflakeDialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+// ---- Snowflake 元数据 ----
+func extractSnowflakeMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open snowflake database %s failed: %w", dbName, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`
+		SELECT TABLE_NAME, COALESCE(COMMENT, '')
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = CURRENT_SCHEMA() AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_NAME
+		LIMIT 500
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []TableMeta
+	for rows.Next() {
+		var name, comment string
+		if err := rows.Scan(&name, &comment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, TableMeta{Name: name, Comment: comment})
+	}
+	rows.Close()
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		colsRows, err := db.QueryContext(ctx, `
+			SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COALESCE(COMMENT, ''), IS_IDENTITY
+			FROM INFORMATION_SCHEMA.COLUMNS
+			WHERE TABLE_SCHEMA = CURRENT_SCHEMA() AND TABLE_NAME = ?
+			ORDER BY ORDINAL_POSITION
+		`, tables[i].Name)
+		if err != nil {
+			return err
+		}
+		var fields []FieldMeta
+		for colsRows.Next() {
+			var f FieldMeta
+			var nullable, identity string
+			var defaultVal sql.NullString
+			if err := colsRows.Scan(&f.Name, &f.Type, &nullable, &defaultVal, &f.Comment, &identity); err != nil {
+				colsRows.Close()
+				return err
+			}
+			f.Nullable = nullable == "YES"
+			f.AutoInc = identity == "YES"
+			// 自增列的默认值为序列表达式，不作为默认值
+			f.HasDefault = defaultVal.Valid && !f.AutoInc
+			if f.HasDefault {
+				f.Default = convertDefaultByType(defaultVal.String, f.Type, f.Nullable)
+			} else {
+				f.Default = convertDefaultByType("", f.Type, f.Nullable)
+			}
+			fields = append(fields, f)
+		}
+		colsRows.Close()
+		// Snowflake 不强制主键/唯一约束，但声明的约束可作为逻辑键
+		var uniques [][]string
+		pkCols, err := snowflakeKeyColumns(ctx, db, "PRIMARY KEYS", tables[i].Name)
+		if err != nil {
+			in.warn(tables[i].Name, metaStagePrimaryKey, err)
+		}
+		for _, cols := range pkCols {
+			for j := range fields {
+				for _, col := range cols {
+					if fields[j].Name == col {
+						fields[j].IsPrimary = true
+					}
+				}
+			}
+			if len(cols) == 1 {
+				tables[i].PrimaryKey = cols[0]
+			} else {
+				uniques = append(uniques, cols)
+			}
+		}
+		uniqueCols, err := snowflakeKeyColumns(ctx, db, "UNIQUE KEYS", tables[i].Name)
+		if err != nil {
+			in.warn(tables[i].Name, metaStageUniqueKeys, err)
+		}
+		uniques = append(uniques, uniqueCols...)
+		tables[i].UniqueKeys = dedupUniques(uniques)
+		tables[i].Fields = fields
+		tables[i].DefaultVals = collectDefaultValueFields(fields, tables[i].PrimaryKey)
+		for _, f := range fields {
+			if isSoftDelField(f.Name) {
+				tables[i].SoftDelKey = f.Name
+				tables[i].SoftDelType = guessSoftDelType(f.Type)
+				break
+			}
+		}
+		autoUpdate := map[string]interface{}{}
+		for _, f := range fields {
+			if isAutoUpdateField(f.Name) && isTimeType(f.Type) {
+				autoUpdate[f.Name] = "{{now}}"
+			}
+		}
+		if len(autoUpdate) > 0 {
+			tables[i].AutoUpdate = autoUpdate
+		}
+		return nil
+	})
+}
+
+// snowflakeKeyColumns 通过 SHOW PRIMARY KEYS / SHOW UNIQUE KEYS 读取约束，按约束名分组返回列
+func snowflakeKeyColumns(ctx context.Context, db *sql.DB, kind, table string) ([][]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SHOW %s IN TABLE %s`, kind, quoteSnowflakeIdent(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colIdx, nameIdx := -1, -1
+	for i, n := range names {
+		switch strings.ToLower(n) {
+		case "column_name":
+			colIdx = i
+		case "constraint_name":
+			nameIdx = i
+		}
+	}
+	if colIdx < 0 || nameIdx < 0 {
+		return nil, fmt.Errorf("unexpected SHOW %s result columns: %v", kind, names)
+	}
+	// SHOW 结果按约束名与 key_sequence 排序
+	var order []string
+	groups := map[string][]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(names))
+		dest := make([]interface{}, len(names))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		constraint := values[nameIdx].String
+		if _, ok := groups[constraint]; !ok {
+			order = append(order, constraint)
+		}
+		groups[constraint] = append(groups[constraint], values[colIdx].String)
+	}
+	result := make([][]string, 0, len(order))
+	for _, name := range order {
+		result = append(result, groups[name])
+	}
+	return result, rows.Err()
+}
+
+func quoteSnowflakeIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,94 @@
+package apix
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 按请求包含已软删除的记录 ---------
+//
+// 配置了 softdel_key 的表在列表、单条查询、更新与删除时都会排除已软删除的记录。
+// 具备 include_deleted 操作角色（默认 admin）的调用者可在任意接口上加 include_deleted=true 跳过该过滤，
+// 用于查看与修复已删除的数据：
+//
+//	GET /api/rest/test/user?include_deleted=true
+//	GET /api/rest/test/user/42?include_deleted=true
+//	PUT /api/rest/test/user/42?include_deleted=true     {"deleted_time": null}   恢复记录
+//
+//	auth:
+//	  operation_roles:
+//	    include_deleted: [admin, support]
+//
+// 未配置软删除的表忽略该参数；列表的 total 此时按查询实际统计，不使用缓存的表行数。
+
+const queryParamIncludeDeleted = "include_deleted"
+
+type includeDeletedKey struct{}
+
+// withIncludeDeleted 校验 include_deleted 参数与调用者角色，通过后在请求 context 中标记跳过软删除过滤
+func (dm *databaseManager) withIncludeDeleted(c *gin.Context) {
+	v := c.Query(queryParamIncludeDeleted)
+	if v == "" {
+		c.Next()
+		return
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + queryParamIncludeDeleted + ": " + v})
+		return
+	}
+	if !include {
+		c.Next()
+		return
+	}
+	_, tc, err := dm.getAdapterAndTableConfig(c.Param("database"), c.Param("table"))
+	if err != nil || tc.SoftDeleteKey == "" {
+		c.Next()
+		return
+	}
+	if !dm.authorize(c, tc, opIncludeDeleted) {
+		c.Abort()
+		return
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), includeDeletedKey{}, true))
+	c.Next()
+}
+
+// includeDeleted 当前请求是否跳过软删除过滤
+func includeDeleted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
+
+// ---- swagger ----
+
+// addIncludeDeletedParam 为软删除表的查询与单条更新、删除接口加上 include_deleted 参数
+func addIncludeDeletedParam(paths map[string]interface{}, basePath, idPath string) {
+	param := map[string]interface{}{
+		"name":        queryParamIncludeDeleted,
+		"in":          "query",
+		"schema":      map[string]string{"type": "boolean"},
+		"description": "包含已软删除的记录，需要 include_deleted 操作角色（默认 admin）",
+	}
+	for _, op := range []struct{ path, method string }{
+		{basePath, "get"}, {idPath, "get"}, {idPath, "put"}, {idPath, "patch"}, {idPath, "delete"},
+	} {
+		item, _ := paths[op.path].(map[string]interface{})
+		operation, _ := item[op.method].(map[string]interface{})
+		if operation == nil {
+			continue
+		}
+		switch params := operation["parameters"].(type) {
+		case []interface{}:
+			operation["parameters"] = append(params, param)
+		case []map[string]interface{}:
+			operation["parameters"] = append(params, param)
+		}
+	}
+}
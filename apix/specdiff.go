@@ -0,0 +1,224 @@
+package apix
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// --------- swagger 变更摘要 ---------
+//
+// 每次生成的 swagger.yaml 内容有变化时，旧版本保存为同目录的 swagger.prev.yaml，
+// 通过接口比较两次生成的差异，作为面向调用方的轻量不兼容变更检测：
+//
+//	GET /swagger/test/diff
+//
+//	{"database": "test", "changed_at": "2024-05-01T08:00:00Z", "breaking": true,
+//	 "paths":   {"added": ["GET /api/rest/test/order"], "removed": []},
+//	 "schemas": {"added": ["order"], "removed": []},
+//	 "fields":  {"added": [...], "removed": [{"schema": "user", "field": "age", "type": "integer"}],
+//	             "type_changed": [{"schema": "user", "field": "phone", "from": "integer", "to": "string"}],
+//	             "required_added": [...]}}
+//
+// 删除接口、删除字段、字段类型变化、字段变为必填视为不兼容（breaking）。
+
+const previousSwaggerFile = "swagger.prev.yaml"
+
+type specDoc struct {
+	Paths      map[string]map[string]interface{} `yaml:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]struct {
+				Type   string `yaml:"type"`
+				Format string `yaml:"format"`
+			} `yaml:"properties"`
+			Required []string `yaml:"required"`
+		} `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type specFieldChange struct {
+	Schema string `json:"schema"`
+	Field  string `json:"field"`
+	Type   string `json:"type,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+type specAddedRemoved struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+type specDiff struct {
+	Breaking bool             `json:"breaking"`
+	Paths    specAddedRemoved `json:"paths"`
+	Schemas  specAddedRemoved `json:"schemas"`
+	Fields   struct {
+		Added         []specFieldChange `json:"added"`
+		Removed       []specFieldChange `json:"removed"`
+		TypeChanged   []specFieldChange `json:"type_changed"`
+		RequiredAdded []specFieldChange `json:"required_added"`
+	} `json:"fields"`
+}
+
+// keepPreviousSwagger 新内容与现有 swagger.yaml 不同时，把现有文件保存为上一版本
+func keepPreviousSwagger(outputDir, content string) error {
+	current := filepath.Join(outputDir, "swagger.yaml")
+	old, err := os.ReadFile(current)
+	if err != nil || string(old) == content {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(outputDir, previousSwaggerFile), old, 0644)
+}
+
+func specOperations(doc specDoc) map[string]bool {
+	ops := map[string]bool{}
+	for p, methods := range doc.Paths {
+		for m := range methods {
+			ops[strings.ToUpper(m)+" "+p] = true
+		}
+	}
+	return ops
+}
+
+func specFieldType(typ, format string) string {
+	if format != "" {
+		return typ + "(" + format + ")"
+	}
+	return typ
+}
+
+func diffStringSets(prev, cur map[string]bool) specAddedRemoved {
+	d := specAddedRemoved{Added: []string{}, Removed: []string{}}
+	for k := range cur {
+		if !prev[k] {
+			d.Added = append(d.Added, k)
+		}
+	}
+	for k := range prev {
+		if !cur[k] {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// diffSpecs 比较两份 swagger 的接口、模型与字段
+func diffSpecs(prev, cur specDoc) specDiff {
+	var d specDiff
+	d.Paths = diffStringSets(specOperations(prev), specOperations(cur))
+	prevSchemas, curSchemas := map[string]bool{}, map[string]bool{}
+	for name := range prev.Components.Schemas {
+		prevSchemas[name] = true
+	}
+	for name := range cur.Components.Schemas {
+		curSchemas[name] = true
+	}
+	d.Schemas = diffStringSets(prevSchemas, curSchemas)
+	d.Fields.Added, d.Fields.Removed = []specFieldChange{}, []specFieldChange{}
+	d.Fields.TypeChanged, d.Fields.RequiredAdded = []specFieldChange{}, []specFieldChange{}
+
+	names := make([]string, 0, len(cur.Components.Schemas))
+	for name := range cur.Components.Schemas {
+		if prevSchemas[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ps, cs := prev.Components.Schemas[name], cur.Components.Schemas[name]
+		fields := map[string]bool{}
+		for f := range ps.Properties {
+			fields[f] = true
+		}
+		for f := range cs.Properties {
+			fields[f] = true
+		}
+		ordered := make([]string, 0, len(fields))
+		for f := range fields {
+			ordered = append(ordered, f)
+		}
+		sort.Strings(ordered)
+		prevRequired := map[string]bool{}
+		for _, r := range ps.Required {
+			prevRequired[r] = true
+		}
+		for _, f := range ordered {
+			pp, inPrev := ps.Properties[f]
+			cp, inCur := cs.Properties[f]
+			switch {
+			case !inPrev:
+				d.Fields.Added = append(d.Fields.Added, specFieldChange{Schema: name, Field: f, Type: specFieldType(cp.Type, cp.Format)})
+			case !inCur:
+				d.Fields.Removed = append(d.Fields.Removed, specFieldChange{Schema: name, Field: f, Type: specFieldType(pp.Type, pp.Format)})
+			default:
+				from, to := specFieldType(pp.Type, pp.Format), specFieldType(cp.Type, cp.Format)
+				if from != to {
+					d.Fields.TypeChanged = append(d.Fields.TypeChanged, specFieldChange{Schema: name, Field: f, From: from, To: to})
+				}
+			}
+		}
+		for _, r := range cs.Required {
+			if !prevRequired[r] {
+				d.Fields.RequiredAdded = append(d.Fields.RequiredAdded, specFieldChange{Schema: name, Field: r})
+			}
+		}
+	}
+	d.Breaking = len(d.Paths.Removed) > 0 || len(d.Schemas.Removed) > 0 || len(d.Fields.Removed) > 0 ||
+		len(d.Fields.TypeChanged) > 0 || len(d.Fields.RequiredAdded) > 0
+	return d
+}
+
+func readSpecDoc(filePath string) (specDoc, time.Time, error) {
+	var doc specDoc
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return doc, time.Time{}, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return doc, time.Time{}, err
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return doc, time.Time{}, fmt.Errorf("parse %s failed: %w", filePath, err)
+	}
+	return doc, info.ModTime(), nil
+}
+
+func handleSwaggerDiff(cfgsDir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dbalias := c.Param("dbalias")
+		dbname := findFileByAlias(filepath.Join(cfgsDir, "database"), dbalias)
+		dir := filepath.Join(cfgsDir, "table", dbname)
+		cur, _, err := readSpecDoc(filepath.Join(dir, "swagger.yaml"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "swagger.yaml not found for db: " + dbalias})
+			return
+		}
+		prev, changedAt, err := readSpecDoc(filepath.Join(dir, previousSwaggerFile))
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No previous swagger generation for db: " + dbalias})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// 上一版本文件在内容变化时写入，其修改时间即变化发生的时间
+		c.JSON(http.StatusOK, struct {
+			Database  string    `json:"database"`
+			ChangedAt time.Time `json:"changed_at"`
+			specDiff
+		}{dbalias, changedAt.UTC(), diffSpecs(prev, cur)})
+	}
+}
@@ -0,0 +1,160 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// --------- 状态流转校验 ---------
+//
+// 表配置中声明状态字段与允许的流转，写入前在 handler 中校验：
+//
+//	state_machine:
+//	  field: status
+//	  initial: [draft]                      # 创建时允许的状态，为空不限制
+//	  transitions:
+//	    draft: [submitted, cancelled]
+//	    submitted: [approved, rejected, draft]
+//
+// 单条更新、批量更新与批量任务按主键读取当前状态，不允许的流转返回 422 与当前状态允许的下一状态；
+// 状态不变视为合法，未出现在 transitions 中的状态为终态，尚无状态的记录按 initial 校验。update_where 无法逐条读取，
+// 改为在过滤条件中加入“当前状态可以流转到目标状态”的限制，不满足的记录不会被更新（不计入 matched_count）。
+
+type stateMachineConfig struct {
+	Field       string              `mapstructure:"field"`
+	Initial     []string            `mapstructure:"initial"`
+	Transitions map[string][]string `mapstructure:"transitions"`
+}
+
+type stateTransitionError struct {
+	Field   string // API 字段名
+	From    string // 创建时为空
+	To      string
+	Allowed []string
+}
+
+func (e *stateTransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("Invalid initial %s '%s'", e.Field, e.To)
+	}
+	return fmt.Sprintf("Invalid %s transition '%s' -> '%s'", e.Field, e.From, e.To)
+}
+
+// nextStates 返回某状态允许的下一状态；viper 加载后 transitions 的键为小写
+func (sm *stateMachineConfig) nextStates(from string) []string {
+	if next, ok := sm.Transitions[from]; ok {
+		return next
+	}
+	return sm.Transitions[strings.ToLower(from)]
+}
+
+func (sm *stateMachineConfig) allows(from, to string) bool {
+	return from == to || contains(sm.nextStates(from), to)
+}
+
+// sourcesOf 返回可以流转到 to 的状态（含 to 本身）
+func (sm *stateMachineConfig) sourcesOf(to string) []string {
+	sources := []string{to}
+	for from, next := range sm.Transitions {
+		if from != to && contains(next, to) {
+			sources = append(sources, from)
+		}
+	}
+	sort.Strings(sources[1:])
+	return sources
+}
+
+// guardInitialStates 校验创建时的状态
+func (tc *tableConfig) guardInitialStates(records []map[string]interface{}) error {
+	sm := tc.StateMachine
+	if sm.Field == "" || len(sm.Initial) == 0 {
+		return nil
+	}
+	for _, rec := range records {
+		v, ok := rec[sm.Field]
+		if !ok || v == nil {
+			continue
+		}
+		if to := fmt.Sprint(v); !contains(sm.Initial, to) {
+			return &stateTransitionError{Field: tc.apiFieldName(sm.Field), To: to, Allowed: sm.Initial}
+		}
+	}
+	return nil
+}
+
+// guardTransitions 按当前状态校验更新中的状态流转，keys 与 records 一一对应
+func (tc *tableConfig) guardTransitions(ctx context.Context, adapter databaseAdapter, records []map[string]interface{}, keys []lookupKey) error {
+	sm := tc.StateMachine
+	if sm.Field == "" {
+		return nil
+	}
+	var idx []int
+	var changing []lookupKey
+	for i, rec := range records {
+		if v, ok := rec[sm.Field]; ok && v != nil {
+			idx = append(idx, i)
+			changing = append(changing, keys[i])
+		}
+	}
+	if len(idx) == 0 {
+		return nil
+	}
+	current, err := batchGetRecords(ctx, adapter, tc, changing, sm.Field)
+	if err != nil {
+		return fmt.Errorf("failed to read current state: %w", err)
+	}
+	for j, i := range idx {
+		if current[j] == nil {
+			// 记录不存在，更新不会生效
+			continue
+		}
+		from, to := templateString(current[j][sm.Field]), fmt.Sprint(records[i][sm.Field])
+		if from == "" {
+			// 尚无状态的记录按创建时的规则校验
+			if len(sm.Initial) > 0 && !contains(sm.Initial, to) {
+				return &stateTransitionError{Field: tc.apiFieldName(sm.Field), To: to, Allowed: sm.Initial}
+			}
+			continue
+		}
+		if !sm.allows(from, to) {
+			allowed := sm.nextStates(from)
+			if allowed == nil {
+				allowed = []string{}
+			}
+			return &stateTransitionError{Field: tc.apiFieldName(sm.Field), From: from, To: to, Allowed: allowed}
+		}
+	}
+	return nil
+}
+
+// restrictTransitionFilters 为 update_where 加上当前状态的限制，已有同字段的 __in 过滤时取交集
+func (tc *tableConfig) restrictTransitionFilters(filters url.Values, updateData map[string]interface{}) error {
+	sm := tc.StateMachine
+	if sm.Field == "" {
+		return nil
+	}
+	v, ok := updateData[sm.Field]
+	if !ok || v == nil {
+		return nil
+	}
+	key := sm.Field + "__in"
+	sources := sm.sourcesOf(fmt.Sprint(v))
+	if existing := filters.Get(key); existing != "" {
+		// 与已有的 __in 条件取交集
+		var both []string
+		for _, s := range parseStringList(existing) {
+			if contains(sources, s) {
+				both = append(both, s)
+			}
+		}
+		sources = both
+		if len(sources) == 0 {
+			return &stateTransitionError{Field: tc.apiFieldName(sm.Field), From: existing, To: fmt.Sprint(v), Allowed: []string{}}
+		}
+	}
+	filters.Set(key, strings.Join(sources, ","))
+	return nil
+}
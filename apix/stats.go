@@ -0,0 +1,306 @@
+package apix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// --------- 列统计 GET /:database/:table/stats?fields=a,b ---------
+//
+// 返回每列的 min/max/avg/null_count/distinct_count，支持与列表接口相同的过滤条件（只统计匹配的记录）。
+// 未指定 fields 时统计全部列（Mongo 取首条文档的字段）。
+// 数值列才有 avg；布尔、JSON、二进制等不可比较的列只统计 null_count。
+// 隐藏字段不统计（fields 中指定时返回 400），脱敏字段的 min/max 脱敏后返回、不返回 avg。
+
+const maxStatsFields = 50
+
+type columnStats struct {
+	Min           interface{} `json:"min"`
+	Max           interface{} `json:"max"`
+	Avg           interface{} `json:"avg,omitempty"`
+	NullCount     int64       `json:"null_count"`
+	DistinctCount *int64      `json:"distinct_count,omitempty"`
+}
+
+// columnProfiler 为可选能力：支持列统计
+type columnProfiler interface {
+	ColumnStats(ctx context.Context, tc *tableConfig, fields []string, filters url.Values) (int64, map[string]*columnStats, error)
+}
+
+func (dm *databaseManager) handleStats(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opStats) {
+		return
+	}
+	profiler, ok := adapter.(columnProfiler)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "stats is not supported by this database type"})
+		return
+	}
+	query := tableConfig.physicalQuery(c.Request.URL.Query())
+	fields := parseStringList(query.Get(queryParamFields))
+	if len(fields) > maxStatsFields {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many fields, max %d", maxStatsFields)})
+		return
+	}
+	for _, f := range fields {
+		if contains(tableConfig.HiddenFields, f) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(f)})
+			return
+		}
+	}
+	total, stats, err := profiler.ColumnStats(c.Request.Context(), tableConfig, fields, query)
+	if err != nil {
+		var unknown *unknownFieldError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(unknown.Field)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats: " + err.Error()})
+		return
+	}
+	result := make(map[string]*columnStats, len(stats))
+	for f, s := range stats {
+		if contains(tableConfig.HiddenFields, f) {
+			continue
+		}
+		if kind, ok := tableConfig.MaskedFields[f]; ok {
+			s.Min, s.Max, s.Avg = maskValue(kind, s.Min), maskValue(kind, s.Max), nil
+		}
+		result[tableConfig.apiFieldName(f)] = s
+	}
+	resp := gin.H{"total": total, "stats": result}
+	if target, ok := adapter.(failoverTarget); ok {
+		if dsn, idx, ok := target.activeTarget(); ok {
+			resp["active_dsn"] = dsn
+			resp["active_dsn_index"] = idx
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type unknownFieldError struct {
+	Field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field: %s", e.Field)
+}
+
+// 列的统计类别
+const (
+	statsKindNumeric    = "numeric"    // min/max/avg/distinct
+	statsKindComparable = "comparable" // min/max/distinct
+	statsKindOther      = "other"      // 仅 null_count
+)
+
+// statsKindOf 按数据库类型名粗略判断列的统计类别
+func statsKindOf(dbType string) string {
+	t := strings.ToLower(dbType)
+	for _, k := range []string{"bool", "bit", "json", "blob", "binary", "bytea", "image", "xml", "array", "ntext", "geometry", "map(", "tuple("} {
+		if strings.Contains(t, k) {
+			return statsKindOther
+		}
+	}
+	for _, k := range []string{"int", "dec", "numeric", "float", "double", "real", "money", "number"} {
+		if strings.Contains(t, k) {
+			return statsKindNumeric
+		}
+	}
+	return statsKindComparable
+}
+
+func normalizeStatsValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case []byte:
+		var i int64
+		fmt.Sscan(string(n), &i)
+		return i
+	case string:
+		var i int64
+		fmt.Sscan(n, &i)
+		return i
+	}
+	return 0
+}
+
+func (a *gormAdapter) ColumnStats(ctx context.Context, tc *tableConfig, fields []string, filters url.Values) (int64, map[string]*columnStats, error) {
+	db := a.db.WithContext(ctx)
+	columnTypes, err := db.Migrator().ColumnTypes(tc.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	kinds := make(map[string]string, len(columnTypes))
+	var allColumns []string
+	for _, ct := range columnTypes {
+		kinds[ct.Name()] = statsKindOf(ct.DatabaseTypeName())
+		allColumns = append(allColumns, ct.Name())
+	}
+	if len(fields) == 0 {
+		fields = allColumns
+		if len(fields) > maxStatsFields {
+			fields = fields[:maxStatsFields]
+		}
+	}
+	// 字段名会拼入 SQL，必须是表中存在的列
+	exprs := []string{"COUNT(*) AS s_total"}
+	for i, f := range fields {
+		kind, ok := kinds[f]
+		if !ok {
+			return 0, nil, &unknownFieldError{Field: f}
+		}
+		col := db.Statement.Quote(f)
+		exprs = append(exprs, fmt.Sprintf("COUNT(%s) AS s%d_cnt", col, i))
+		if kind == statsKindOther {
+			continue
+		}
+		exprs = append(exprs,
+			fmt.Sprintf("MIN(%s) AS s%d_min", col, i),
+			fmt.Sprintf("MAX(%s) AS s%d_max", col, i),
+			fmt.Sprintf("COUNT(DISTINCT %s) AS s%d_distinct", col, i))
+		if kind == statsKindNumeric {
+			exprs = append(exprs, fmt.Sprintf("AVG(%s) AS s%d_avg", col, i))
+		}
+	}
+	row := map[string]interface{}{}
+	err = a.read(ctx, func(db *gorm.DB) error {
+		query := applyGormSoftDeleteFilter(db.Table(tc.Name), tc)
+		query, _ = applyGormQueryFilters(query, filters)
+		return query.Select(strings.Join(exprs, ", ")).Take(&row).Error
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, err
+	}
+	get := func(key string) interface{} {
+		// 部分驱动会把别名转为大写
+		if v, ok := row[key]; ok {
+			return v
+		}
+		return row[strings.ToUpper(key)]
+	}
+	total := toInt64(get("s_total"))
+	stats := make(map[string]*columnStats, len(fields))
+	for i, f := range fields {
+		s := &columnStats{NullCount: total - toInt64(get(fmt.Sprintf("s%d_cnt", i)))}
+		if kinds[f] != statsKindOther {
+			s.Min = normalizeStatsValue(get(fmt.Sprintf("s%d_min", i)))
+			s.Max = normalizeStatsValue(get(fmt.Sprintf("s%d_max", i)))
+			distinct := toInt64(get(fmt.Sprintf("s%d_distinct", i)))
+			s.DistinctCount = &distinct
+		}
+		if kinds[f] == statsKindNumeric {
+			s.Avg = normalizeStatsValue(get(fmt.Sprintf("s%d_avg", i)))
+		}
+		stats[f] = s
+	}
+	return total, stats, nil
+}
+
+func (a *mongoAdapter) ColumnStats(ctx context.Context, tc *tableConfig, fields []string, filters url.Values) (int64, map[string]*columnStats, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, filters)
+	if len(fields) == 0 {
+		var sample bson.M
+		if err := collection.FindOne(ctx, filter).Decode(&sample); err != nil {
+			if isNotFoundErr(err) {
+				return 0, map[string]*columnStats{}, nil
+			}
+			return 0, nil, err
+		}
+		for k := range sample {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		if len(fields) > maxStatsFields {
+			fields = fields[:maxStatsFields]
+		}
+	}
+	// 每列两个 facet：聚合值与去重计数
+	facet := bson.M{"total": bson.A{bson.M{"$count": "n"}}}
+	for i, f := range fields {
+		ref := "$" + f
+		facet[fmt.Sprintf("s%d", i)] = bson.A{
+			bson.M{"$group": bson.M{
+				"_id": nil,
+				"min": bson.M{"$min": ref},
+				"max": bson.M{"$max": ref},
+				"avg": bson.M{"$avg": ref},
+				"cnt": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{ref, nil}}, nil}}, 0, 1}}},
+			}},
+		}
+		facet[fmt.Sprintf("s%d_distinct", i)] = bson.A{
+			bson.M{"$match": bson.M{f: bson.M{"$ne": nil}}},
+			bson.M{"$group": bson.M{"_id": ref}},
+			bson.M{"$count": "n"},
+		}
+	}
+	pipeline := bson.A{bson.M{"$match": filter}, bson.M{"$facet": facet}}
+	cur, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cur.Close(ctx)
+	var docs []bson.M
+	if err := cur.All(ctx, &docs); err != nil {
+		return 0, nil, err
+	}
+	if len(docs) == 0 {
+		return 0, map[string]*columnStats{}, nil
+	}
+	first := func(key string) bson.M {
+		if arr, ok := docs[0][key].(bson.A); ok && len(arr) > 0 {
+			if m, ok := arr[0].(bson.M); ok {
+				return m
+			}
+		}
+		return bson.M{}
+	}
+	total := toInt64(first("total")["n"])
+	stats := make(map[string]*columnStats, len(fields))
+	for i, f := range fields {
+		g := first(fmt.Sprintf("s%d", i))
+		distinct := toInt64(first(fmt.Sprintf("s%d_distinct", i))["n"])
+		stats[f] = &columnStats{
+			Min:           g["min"],
+			Max:           g["max"],
+			Avg:           g["avg"],
+			NullCount:     total - toInt64(g["cnt"]),
+			DistinctCount: &distinct,
+		}
+	}
+	return total, stats, nil
+}
@@ -0,0 +1,119 @@
+package apix
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- filter[...] 过滤参数 ---------
+//
+// 过滤条件可写成 filter[字段__操作符]=值，与直接写 字段__操作符=值 等价，
+// 字段名与保留参数（page、order、fields、key 等）同名时也不会冲突：
+//
+//	GET /api/rest/test/user?filter[order]=3&filter[age__gte]=18&order=-id
+//
+// 开启 strict_filters（全局或表级）后只接受 filter[...] 形式，其余未知参数返回 400：
+//
+//	strict_filters: true
+
+const filterParamPrefix = "filter["
+
+// 不经过 reservedQueryParams 但由各接口自行读取的参数
+var nonFilterQueryParams = map[string]struct{}{
+	queryParamKey:            {},
+	queryParamCursor:         {},
+	queryParamOr:             {},
+	timeSeriesParamInterval:  {},
+	timeSeriesParamAgg:       {},
+	timeSeriesParamGroupBy:   {},
+	timeSeriesParamTimeField: {},
+	aggregateParamMetrics:    {},
+	aggregateParamLimit:      {},
+	distinctParamField:       {},
+	exportParamFormat:        {},
+	exportParamDelimiter:     {},
+	queryParamChunkSize:      {},
+}
+
+func isNonFilterParam(key string) bool {
+	if isReservedQueryParam(key) {
+		return true
+	}
+	_, ok := nonFilterQueryParams[key]
+	return ok
+}
+
+// strictFilters 判断表是否要求 filter[...] 形式
+func (dm *databaseManager) strictFilters(tc *tableConfig) bool {
+	if tc.StrictFilters != nil {
+		return *tc.StrictFilters
+	}
+	return dm.config.StrictFilters
+}
+
+// normalizeFilterParams 展开 filter[...] 参数；与保留参数同名的字段改写为 字段__eq
+func normalizeFilterParams(query url.Values, strict bool) (url.Values, bool, string) {
+	result := make(url.Values, len(query))
+	changed := false
+	for key, values := range query {
+		if strings.HasPrefix(key, filterParamPrefix) && strings.HasSuffix(key, "]") {
+			inner := key[len(filterParamPrefix) : len(key)-1]
+			if inner == "" {
+				return nil, false, key
+			}
+			if !strings.Contains(inner, "__") && isNonFilterParam(inner) {
+				inner += "__eq"
+			}
+			result[inner] = append(result[inner], values...)
+			changed = true
+			continue
+		}
+		if strict && !isNonFilterParam(key) {
+			return nil, false, key
+		}
+		result[key] = append(result[key], values...)
+	}
+	return result, changed, ""
+}
+
+// withFilterParams 在进入处理函数前把 filter[...] 参数改写为内部的 字段__操作符 形式
+func (dm *databaseManager) withFilterParams(c *gin.Context) {
+	dbName, tableAlias := c.Param("database"), c.Param("table")
+	if dbName == "" || tableAlias == "" {
+		c.Next()
+		return
+	}
+	_, tc, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.Next()
+		return
+	}
+	if err := validateOrFilters(c.Request.URL.Query()); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	strict := dm.strictFilters(tc)
+	query := c.Request.URL.Query()
+	if strict || strings.Contains(c.Request.URL.RawQuery, "filter%5B") || strings.Contains(c.Request.URL.RawQuery, "filter[") {
+		normalized, changed, bad := normalizeFilterParams(query, strict)
+		if bad != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unknown query parameter " + bad + ", filters must be passed as filter[field__op]=value"})
+			return
+		}
+		if changed {
+			c.Request.URL.RawQuery = normalized.Encode()
+		}
+		query = normalized
+	}
+	// 可过滤字段，见 filterfields.go
+	if appliesFilters(c) {
+		if err := tc.checkFilterFields(query); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.Next()
+}
@@ -1,11 +1,18 @@
 package apix
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
@@ -34,33 +41,153 @@ const swaggerHTMLTpl = `
 `
 
 func RegisterSwaggerUI(router *gin.Engine, prefix, cfgsDir string) {
-	router.GET(prefix+"/:dbalias/swagger.yaml", func(c *gin.Context) {
-		dbalias := c.Param("dbalias")
-		databaseDir := filepath.Join(cfgsDir, "database")
-		tableDir := filepath.Join(cfgsDir, "table")
+	defaultVersion := readSwaggerOptions(cfgsDir).OpenAPIVersion
+	serveSpec := func(asJSON bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			dbalias := c.Param("dbalias")
+			databaseDir := filepath.Join(cfgsDir, "database")
+			tableDir := filepath.Join(cfgsDir, "table")
 
-		// 根据数据库别名找到配置目录名
-		dbname := findFileByAlias(databaseDir, dbalias)
+			// 根据数据库别名找到配置目录名
+			dbname := findFileByAlias(databaseDir, dbalias)
 
-		swaggerPath := filepath.Join(tableDir, dbname, "swagger.yaml")
-		data, err := os.ReadFile(swaggerPath)
-		if err != nil {
-			c.String(http.StatusNotFound, "swagger.yaml not found for db: %s", dbname)
-			return
+			swaggerPath := filepath.Join(tableDir, dbname, "swagger.yaml")
+			data, err := os.ReadFile(swaggerPath)
+			if err != nil {
+				c.String(http.StatusNotFound, "swagger.yaml not found for db: %s", dbname)
+				return
+			}
+			version := c.DefaultQuery(queryParamOpenAPI, defaultVersion)
+			if version != openapiVersion30 && version != openapiVersion31 {
+				c.String(http.StatusBadRequest, "unsupported openapi version: %s", version)
+				return
+			}
+			data, err = renderOpenAPI(data, version, asJSON)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			contentType := "application/yaml"
+			if asJSON {
+				contentType = "application/json"
+			}
+			serveSwaggerFile(c, swaggerPath, data, contentType)
 		}
-		c.Data(http.StatusOK, "application/yaml", data)
-	})
+	}
+	router.GET(prefix+"/:dbalias/swagger.yaml", serveSpec(false))
+	router.GET(prefix+"/:dbalias/swagger.json", serveSpec(true))
+	router.GET(prefix+"/:dbalias/diff", handleSwaggerDiff(cfgsDir))
+	router.GET(prefix+"/:dbalias/jsonschema", handleJSONSchema(cfgsDir))
+	router.GET(prefix+"/:dbalias/jsonschema/:table", handleJSONSchema(cfgsDir))
 
 	// swagger ui 页面, 路径: /swagger/:dbalias
 	router.GET(prefix+"/:dbalias", func(c *gin.Context) {
 		dbalias := c.Param("dbalias")
-		yamlURL := prefix + "/" + dbalias + "/swagger.yaml"
+		// 页面使用的 Swagger UI 版本只支持 3.0
+		yamlURL := prefix + "/" + dbalias + "/swagger.yaml?" + queryParamOpenAPI + "=" + openapiVersion30
 		html := fmt.Sprintf(swaggerHTMLTpl, yamlURL)
 		c.Header("Content-Type", "text/html; charset=utf-8")
 		c.String(http.StatusOK, html)
 	})
 }
 
+// --------- 对外服务地址 ---------
+//
+// 生成的 swagger 默认不带 servers，客户端按同源访问；部署在网关之后（改写了域名或路径前缀）时，
+// 在 _base.yaml 中声明对外地址，写入每个库的 swagger：
+//
+//	swagger:
+//	  servers:
+//	    - url: https://api.example.com/ego        # 接口路径（/api/rest/...）拼接在其后
+//	      description: production
+//	    - url: https://gw.example.com/{database}  # {database} 替换为库别名
+//
+// 修改后需重启或调用 POST /api/admin/swagger/regenerate 重新生成。
+
+type swaggerServer struct {
+	URL         string `yaml:"url"`
+	Description string `yaml:"description"`
+}
+
+// swaggerServersFor 生成某个库的 servers 列表
+func swaggerServersFor(servers []swaggerServer, dbAlias string) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(servers))
+	for _, s := range servers {
+		if s.URL == "" {
+			continue
+		}
+		server := map[string]interface{}{"url": strings.TrimRight(strings.ReplaceAll(s.URL, "{database}", dbAlias), "/")}
+		if s.Description != "" {
+			server["description"] = s.Description
+		}
+		out = append(out, server)
+	}
+	return out
+}
+
+// serveSwaggerFile 带 ETag 与 Last-Modified 返回 swagger 文件，内容未变时返回 304
+func serveSwaggerFile(c *gin.Context, filePath string, data []byte, contentType string) {
+	sum := sha256.Sum256(data)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "no-cache")
+	var modTime time.Time
+	if info, err := os.Stat(filePath); err == nil {
+		modTime = info.ModTime()
+	}
+	// ServeContent 处理 If-None-Match / If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, filepath.Base(filePath), modTime, bytes.NewReader(data))
+}
+
+// --------- swagger 重新生成 ---------
+//
+// 表结构变化后无需重启即可重新提取元数据（同启动时的 ExtractDbMeta），原子替换 swagger.yaml，
+// 并重建该库的 GraphQL schema（受 operation_roles.swagger_regenerate 控制，默认 admin）：
+//
+//	POST /api/admin/swagger/regenerate?database=test
+//
+// 表配置文件同样会按新的元数据重新生成（保留自定义配置项）；REST 层已加载的表配置在重新加载配置后生效，见 configswap.go。
+
+var swaggerRegenMu sync.Mutex
+
+func (dm *databaseManager) handleSwaggerRegenerate(c *gin.Context) {
+	if !dm.authorize(c, nil, opSwaggerRegen) {
+		return
+	}
+	database := c.Query("database")
+	if database == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "database is required"})
+		return
+	}
+	if !dirExists(dm.configDir) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Swagger regeneration requires a config directory"})
+		return
+	}
+	swaggerRegenMu.Lock()
+	err := RegenerateDbMeta(dm.configDir, dm.restPrefix, database)
+	swaggerRegenMu.Unlock()
+	if errors.Is(err, errDbConfigNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"database": database, "regenerated": true}
+	for _, ep := range listGraphqlEndpoints() {
+		if path.Base(ep.path) != database {
+			continue
+		}
+		if err := ep.reload(); err != nil {
+			resp["graphql_error"] = err.Error()
+		} else {
+			resp["graphql_reloaded"] = ep.path
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // 遍历目录并查找匹配的文件
 func findFileByAlias(dirPath string, targetAlias string) string {
 	var result string
@@ -0,0 +1,192 @@
+package apix
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- swagger 文档编排 ---------
+//
+// 默认按表名字母顺序生成 tag 与接口。_base.yaml 中可把表分组并指定表内接口的顺序：
+//
+//	swagger:
+//	  tag_groups:                    # 输出 x-tagGroups（Redoc 等工具支持），未分组的表归入 Other
+//	    - name: 账户
+//	      tags: [user, user_profile]
+//	  operation_order: [list, get, create, update, delete, batch_get]   # 未列出的接口排在后面
+//
+// 表配置中可覆盖 tag 描述与各接口的摘要、说明：
+//
+//	docs:
+//	  description: 用户账号           # 默认取表注释
+//	  summaries: {list: 查询用户, create: 注册用户}
+//	  descriptions: {delete: 软删除，可在回收站恢复}
+//
+// 接口名：表路径上的 GET/POST/PUT 为 list、create、batch_update，{id} 上的 GET/PUT/PATCH/DELETE 为 get、update、patch、delete，
+// 其余取路径最后一段（batch_delete、batch_get、stats 等）。
+
+const otherTagGroup = "Other"
+
+type swaggerTagGroup struct {
+	Name string   `yaml:"name"`
+	Tags []string `yaml:"tags"`
+}
+
+type tableDocs struct {
+	Description  string            `yaml:"description"`
+	Summaries    map[string]string `yaml:"summaries"`
+	Descriptions map[string]string `yaml:"descriptions"`
+}
+
+// swaggerOperationName 由路径与方法得到接口名，basePath 为表路径
+func swaggerOperationName(basePath, p, method string) string {
+	switch strings.TrimPrefix(p, basePath) {
+	case "":
+		switch method {
+		case "get":
+			return "list"
+		case "post":
+			return "create"
+		case "put":
+			return "batch_update"
+		}
+	case "/{id}":
+		switch method {
+		case "get":
+			return "get"
+		case "put":
+			return "update"
+		case "patch":
+			return "patch"
+		case "delete":
+			return "delete"
+		}
+	}
+	return path.Base(p)
+}
+
+// applyTableDocs 按表配置覆盖该表各接口的摘要与说明
+func applyTableDocs(paths map[string]interface{}, basePath string, docs tableDocs) {
+	if len(docs.Summaries) == 0 && len(docs.Descriptions) == 0 {
+		return
+	}
+	for p, item := range paths {
+		if p != basePath && !strings.HasPrefix(p, basePath+"/") {
+			continue
+		}
+		for method, op := range item.(map[string]interface{}) {
+			operation, ok := op.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := swaggerOperationName(basePath, p, method)
+			if s := docs.Summaries[name]; s != "" {
+				operation["summary"] = s
+			}
+			if d := docs.Descriptions[name]; d != "" {
+				operation["description"] = d
+			}
+		}
+	}
+}
+
+// orderSwaggerTags 按分组顺序排列 tags，并生成 x-tagGroups
+func orderSwaggerTags(tags []map[string]string, groups []swaggerTagGroup) ([]map[string]string, []map[string]interface{}) {
+	if len(groups) == 0 {
+		return tags, nil
+	}
+	byName := make(map[string]map[string]string, len(tags))
+	for _, t := range tags {
+		byName[t["name"]] = t
+	}
+	var ordered []map[string]string
+	var xGroups []map[string]interface{}
+	grouped := map[string]bool{}
+	for _, g := range groups {
+		var names []string
+		for _, name := range g.Tags {
+			if t, ok := byName[name]; ok && !grouped[name] {
+				grouped[name] = true
+				ordered = append(ordered, t)
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			xGroups = append(xGroups, map[string]interface{}{"name": g.Name, "tags": names})
+		}
+	}
+	var rest []string
+	for _, t := range tags {
+		if !grouped[t["name"]] {
+			ordered = append(ordered, t)
+			rest = append(rest, t["name"])
+		}
+	}
+	// x-tagGroups 未覆盖的 tag 在 Redoc 中不显示，归入 Other
+	if len(rest) > 0 {
+		xGroups = append(xGroups, map[string]interface{}{"name": otherTagGroup, "tags": rest})
+	}
+	return ordered, xGroups
+}
+
+// orderSwaggerPaths 按 operation_order 重排 paths 及各路径下的方法，tablePrefix 为 {apiPrefix}/{dbAlias}/
+func orderSwaggerPaths(doc *yaml.Node, tablePrefix string, order []string) {
+	if len(order) == 0 || doc.Kind != yaml.MappingNode {
+		return
+	}
+	rank := func(name string) int {
+		for i, n := range order {
+			if n == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+	basePathOf := func(p string) string {
+		rest := strings.TrimPrefix(p, tablePrefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i]
+		}
+		return tablePrefix + rest
+	}
+	var paths *yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "paths" {
+			paths = doc.Content[i+1]
+		}
+	}
+	if paths == nil || paths.Kind != yaml.MappingNode {
+		return
+	}
+	type pair struct {
+		key, value *yaml.Node
+		rank       int
+	}
+	sortPairs := func(content []*yaml.Node, rankOf func(key, value *yaml.Node) int) []*yaml.Node {
+		pairs := make([]pair, 0, len(content)/2)
+		for i := 0; i+1 < len(content); i += 2 {
+			pairs = append(pairs, pair{content[i], content[i+1], rankOf(content[i], content[i+1])})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].rank < pairs[j].rank })
+		out := make([]*yaml.Node, 0, len(content))
+		for _, p := range pairs {
+			out = append(out, p.key, p.value)
+		}
+		return out
+	}
+	paths.Content = sortPairs(paths.Content, func(key, value *yaml.Node) int {
+		base := basePathOf(key.Value)
+		best := len(order)
+		value.Content = sortPairs(value.Content, func(method, _ *yaml.Node) int {
+			r := rank(swaggerOperationName(base, key.Value, method.Value))
+			if r < best {
+				best = r
+			}
+			return r
+		})
+		return best
+	})
+}
@@ -0,0 +1,60 @@
+package apix
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// --------- 表计数持久化 ---------
+//
+// 未过滤列表的 total 取自后台定时统计的表计数（total_cnt_interval）。配置了 job_queue.store 时，
+// 每次统计结果连同统计时间写入同一个 KVStore，重启后先加载上次的计数，首轮统计完成前不会返回适配器的 0。
+// 已不在配置中的表的计数不加载。
+
+const tableCountKeyPrefix = "table_count:"
+
+type tableCountEntry struct {
+	Count       int64     `json:"count"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// saveTableCount 写入一张表的计数，未配置 KVStore 时忽略
+func (dm *databaseManager) saveTableCount(key string, count int64, at time.Time) {
+	if dm.store == nil {
+		return
+	}
+	data, _ := json.Marshal(tableCountEntry{Count: count, RefreshedAt: at})
+	if err := dm.store.Set([]byte(tableCountKeyPrefix+key), data, 0); err != nil {
+		log.Printf("persist table count %s failed: %v", key, err)
+	}
+}
+
+// loadTableCounts 启动时从 KVStore 恢复表计数
+func (dm *databaseManager) loadTableCounts() {
+	if dm.store == nil {
+		return
+	}
+	known := map[string]bool{}
+	for dbName, dbCfg := range dm.config.Databases {
+		for _, t := range dbCfg.Tables {
+			known[dbName+"_"+t.Alias] = true
+		}
+	}
+	dm.countMutex.Lock()
+	defer dm.countMutex.Unlock()
+	err := dm.store.Scan([]byte(tableCountKeyPrefix), func(k, v []byte) error {
+		key := strings.TrimPrefix(string(k), tableCountKeyPrefix)
+		var entry tableCountEntry
+		if !known[key] || json.Unmarshal(v, &entry) != nil {
+			return nil
+		}
+		dm.tableCounts[key] = entry.Count
+		dm.tableCountsAt[key] = entry.RefreshedAt
+		return nil
+	})
+	if err != nil {
+		log.Printf("load table counts failed: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package apix
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --------- 表别名命名策略 ---------
+//
+// ExtractDbMeta 为新表生成默认别名（即 API 路径与 GraphQL 类型名）时应用，已有 alias 的表配置不受影响。
+// _base.yaml 中配置全局策略，库配置中的 table_naming 整体覆盖：
+//
+//	table_naming:
+//	  strip_prefix: tbl_     # 去掉表名前缀：tbl_order_items → order_items
+//	  singularize: true      # 最后一个单词转单数：order_items → order_item
+//	  case: camelCase        # as_is（默认）或 camelCase：order_item → orderItem
+//
+// 依次执行 strip_prefix、singularize、case；处理后为空时保留原表名。生成的别名冲突时见 aliases.go。
+
+const (
+	namingAsIs      = "as_is"
+	namingCamelCase = "camelCase"
+)
+
+type tableNaming struct {
+	StripPrefix string `yaml:"strip_prefix"`
+	Singularize bool   `yaml:"singularize"`
+	Case        string `yaml:"case"`
+	OnCollision string `yaml:"on_collision"` // 见 aliases.go
+}
+
+// readTableNaming 读取 _base.yaml 中的 table_naming
+func readTableNaming(cfgsDir string) *tableNaming {
+	var conf struct {
+		TableNaming *tableNaming `yaml:"table_naming"`
+	}
+	if data, err := os.ReadFile(filepath.Join(cfgsDir, "_base.yaml")); err == nil {
+		_ = yaml.Unmarshal(data, &conf)
+	}
+	return conf.TableNaming
+}
+
+func (n *tableNaming) onCollision() string {
+	if n == nil || n.OnCollision == "" {
+		return aliasCollisionError
+	}
+	return n.OnCollision
+}
+
+// alias 按策略由表名生成默认别名
+func (n *tableNaming) alias(table string) string {
+	if n == nil {
+		return table
+	}
+	name := table
+	if n.StripPrefix != "" {
+		name = strings.TrimPrefix(name, n.StripPrefix)
+	}
+	if n.Singularize {
+		i := strings.LastIndex(name, "_") + 1
+		name = name[:i] + singularize(name[i:])
+	}
+	if n.Case == namingCamelCase {
+		name = snakeToCamel(name)
+	}
+	if name == "" {
+		return table
+	}
+	return name
+}
+
+// singularize 处理常见的英文复数形式，不认识的词原样返回
+func singularize(word string) string {
+	lower := strings.ToLower(word)
+	switch {
+	case len(word) <= 3 || strings.HasSuffix(lower, "ss") || strings.HasSuffix(lower, "us") || strings.HasSuffix(lower, "is"):
+		return word
+	case strings.HasSuffix(lower, "ies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zzes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// snakeToCamel order_items → orderItems，首个单词保持原样
+func snakeToCamel(name string) string {
+	parts := strings.Split(strings.Trim(name, "_"), "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = upperFirst(parts[i])
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,101 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// --------- TiDB ---------
+//
+// type: tidb 使用 MySQL 方言，并针对 TiDB 调整：
+//
+//	tidb:
+//	  txn_mode: optimistic          # 会话事务模式 optimistic | pessimistic，默认沿用集群配置
+//	  write_conflict_retries: 3     # 写冲突（乐观事务提交失败）时整个事务的重试次数
+//	  replica_read: closest-replicas # 读副本策略 leader | follower | leader-and-follower | closest-replicas
+//
+// 元数据提取时识别 AUTO_RANDOM 主键（按自增列处理，不生成默认值）。
+
+type tidbConfig struct {
+	TxnMode              string `mapstructure:"txn_mode"`
+	WriteConflictRetries int    `mapstructure:"write_conflict_retries"`
+	ReplicaRead          string `mapstructure:"replica_read"`
+}
+
+// tidbDSN 把会话变量写入 DSN，由驱动在建立连接时 SET
+func tidbDSN(dsn string, cfg tidbConfig) (string, error) {
+	if cfg.TxnMode == "" && cfg.ReplicaRead == "" {
+		return dsn, nil
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if mc.Params == nil {
+		mc.Params = map[string]string{}
+	}
+	if cfg.TxnMode != "" {
+		mc.Params["tidb_txn_mode"] = "'" + cfg.TxnMode + "'"
+	}
+	if cfg.ReplicaRead != "" {
+		mc.Params["tidb_replica_read"] = "'" + cfg.ReplicaRead + "'"
+	}
+	return mc.FormatDSN(), nil
+}
+
+// isTiDBRetryableError 判断事务能否整体重试：写冲突、事务重试失败、事务期间表结构变更、死锁
+func isTiDBRetryableError(err error) bool {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return false
+	}
+	switch me.Number {
+	case 9007, 8022, 8028, 1213:
+		return true
+	}
+	return false
+}
+
+// ---- TiDB 元数据 ----
+
+var tidbAutoRandomColumn = regexp.MustCompile("(?i)^\\s*`([^`]+)`.*AUTO_RANDOM")
+
+func extractTiDBMeta(dsn, dbName string, in *introspection) ([]TableMeta, error) {
+	tables, err := extractMySQLMeta(dsn, dbName, in)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open tidb database %s failed: %w", dbName, err)
+	}
+	defer db.Close()
+	return in.tables(dbName, tables, func(ctx context.Context, i int) error {
+		// information_schema 不体现 AUTO_RANDOM，从建表语句识别
+		var name, createSQL string
+		if err := db.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE `%s`", tables[i].Name)).Scan(&name, &createSQL); err != nil {
+			// 没有 SHOW CREATE TABLE 权限时无法识别 AUTO_RANDOM，其余结构照常使用
+			in.warn(tables[i].Name, metaStageAutoIncrement, err)
+			return nil
+		}
+		for _, line := range strings.Split(createSQL, "\n") {
+			m := tidbAutoRandomColumn.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			for j := range tables[i].Fields {
+				if tables[i].Fields[j].Name == m[1] {
+					tables[i].Fields[j].AutoInc = true
+				}
+			}
+		}
+		tables[i].DefaultVals = collectDefaultValueFields(tables[i].Fields, tables[i].PrimaryKey)
+		return nil
+	})
+}
@@ -0,0 +1,231 @@
+package apix
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --------- 时间格式与时区 ---------
+//
+// 不同驱动返回的时间类型与格式不一致（MySQL/PG 返回 time.Time，Mongo 返回 DateTime，部分驱动返回字符串），
+// 表配置可统一响应中的时间格式，并按同样的格式解析请求体：
+//
+//	timezone: Asia/Shanghai            # 表级时区，响应中的时间转换到该时区
+//	time_format: datetime              # 表级格式：rfc3339 | datetime | date | unix | unix_ms | Go layout
+//	time_fields:                       # 字段级配置（物理列名），优先于表级
+//	  birthday: {format: date}
+//	  login_at: {format: unix_ms, timezone: UTC}
+//
+// 表级配置只作用于驱动返回的时间类型值，以及请求体中能按该格式完整解析的字符串；
+// 字段级配置的字段在请求体中必须是该格式的值，否则返回 400。时区无效时加载配置失败。
+
+const (
+	timeFormatRFC3339  = "rfc3339"
+	timeFormatDatetime = "datetime"
+	timeFormatDate     = "date"
+	timeFormatUnix     = "unix"
+	timeFormatUnixMs   = "unix_ms"
+)
+
+type timeFieldConfig struct {
+	Format   string `mapstructure:"format"`
+	Timezone string `mapstructure:"timezone"`
+}
+
+var locationCache sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// checkTimeSettings 加载配置时校验表级与字段级时区
+func checkTimeSettings(tc *tableConfig) error {
+	if _, err := loadLocation(tc.Timezone); err != nil {
+		return fmt.Errorf("table %s: invalid timezone '%s': %w", tc.Name, tc.Timezone, err)
+	}
+	for field, fc := range tc.TimeFields {
+		if _, err := loadLocation(fc.Timezone); err != nil {
+			return fmt.Errorf("table %s: invalid timezone '%s' for time_fields.%s: %w", tc.Name, fc.Timezone, field, err)
+		}
+	}
+	return nil
+}
+
+// timeLayout 把格式别名转换为 Go layout，unix 类格式返回空串
+func timeLayout(format string) string {
+	switch strings.ToLower(format) {
+	case "", timeFormatRFC3339:
+		return time.RFC3339Nano
+	case timeFormatDatetime:
+		return time.DateTime
+	case timeFormatDate:
+		return time.DateOnly
+	case timeFormatUnix, timeFormatUnixMs:
+		return ""
+	default:
+		return format
+	}
+}
+
+// timeSettings 返回字段实际生效的格式与时区；ok 为 false 表示未配置
+func (tc *tableConfig) timeSettings(field string) (format string, loc *time.Location, fieldLevel bool, ok bool) {
+	format, tz := tc.TimeFormat, tc.Timezone
+	if fc, exists := tc.TimeFields[field]; exists {
+		fieldLevel = true
+		if fc.Format != "" {
+			format = fc.Format
+		}
+		if fc.Timezone != "" {
+			tz = fc.Timezone
+		}
+	}
+	if format == "" && tz == "" {
+		return "", nil, false, false
+	}
+	// 时区已在加载配置时校验（checkTimeSettings）
+	loc, _ = loadLocation(tz)
+	return format, loc, fieldLevel, true
+}
+
+func (tc *tableConfig) hasTimeSettings() bool {
+	return tc.TimeFormat != "" || tc.Timezone != "" || len(tc.TimeFields) > 0
+}
+
+// 驱动以字符串返回时间时尝试的格式
+var driverTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+}
+
+func asTime(v interface{}, parseString bool) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	case primitive.DateTime:
+		return t.Time(), true
+	case []byte:
+		if parseString {
+			return asTime(string(t), true)
+		}
+	case string:
+		if parseString {
+			for _, layout := range driverTimeLayouts {
+				if parsed, err := time.Parse(layout, t); err == nil {
+					return parsed, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func formatTime(t time.Time, format string, loc *time.Location) interface{} {
+	if loc != nil {
+		t = t.In(loc)
+	}
+	switch strings.ToLower(format) {
+	case timeFormatUnix:
+		return t.Unix()
+	case timeFormatUnixMs:
+		return t.UnixMilli()
+	default:
+		return t.Format(timeLayout(format))
+	}
+}
+
+// formatRecordTimes 按配置格式化记录中的时间值（物理列名）
+func (tc *tableConfig) formatRecordTimes(record map[string]interface{}) map[string]interface{} {
+	if record == nil || !tc.hasTimeSettings() {
+		return record
+	}
+	for k, v := range record {
+		format, loc, fieldLevel, ok := tc.timeSettings(k)
+		if !ok {
+			continue
+		}
+		if t, isTime := asTime(v, fieldLevel); isTime && !t.IsZero() {
+			record[k] = formatTime(t, format, loc)
+		}
+	}
+	return record
+}
+
+func parseTimeValue(v interface{}, format string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch strings.ToLower(format) {
+	case timeFormatUnix, timeFormatUnixMs:
+		var n int64
+		switch num := v.(type) {
+		case float64:
+			n = int64(num)
+		case json.Number:
+			i, err := num.Int64()
+			if err != nil {
+				return time.Time{}, err
+			}
+			n = i
+		default:
+			return time.Time{}, fmt.Errorf("expected %s number", format)
+		}
+		if strings.ToLower(format) == timeFormatUnix {
+			return time.Unix(n, 0).In(loc), nil
+		}
+		return time.UnixMilli(n).In(loc), nil
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected time string")
+		}
+		return time.ParseInLocation(timeLayout(format), s, loc)
+	}
+}
+
+// parseRecordTimes 按配置把请求体中的时间值解析为 time.Time（物理列名）
+func (tc *tableConfig) parseRecordTimes(record map[string]interface{}) error {
+	if !tc.hasTimeSettings() {
+		return nil
+	}
+	for k, v := range record {
+		if v == nil {
+			continue
+		}
+		format, loc, fieldLevel, ok := tc.timeSettings(k)
+		if !ok || (!fieldLevel && timeLayout(format) == "") {
+			// 表级 unix 格式无法区分时间与普通数字，不做解析
+			continue
+		}
+		t, err := parseTimeValue(v, format, loc)
+		if err != nil {
+			if fieldLevel {
+				return fmt.Errorf("field '%s': invalid time value %v: %w", tc.apiFieldName(k), v, err)
+			}
+			continue
+		}
+		record[k] = t
+	}
+	return nil
+}
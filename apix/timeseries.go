@@ -0,0 +1,407 @@
+package apix
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// --------- 时序查询 ---------
+//
+//	GET /api/rest/:database/:table/timeseries?interval=5m&agg=avg&fields=temp,humidity&group_by=host&ts__gte=2024-01-01
+//
+// 按时间字段分桶聚合，其余过滤条件与列表接口相同。表配置：
+//
+//	timeseries:
+//	  time_field: ts          # 时间字段（物理列名），也可通过 time_field 参数指定
+//	  tags: [host, region]    # InfluxDB 的 tag 列，写入时作为 tag 而不是 field
+//	  retention: 30d          # 数据保留期（TimescaleDB/InfluxDB 元数据生成，仅用于文档）
+//
+// agg 支持 avg | sum | min | max | count，count 可不指定 fields。
+// TimescaleDB 使用 time_bucket，其它 SQL 数据库按各自方言换算。
+
+const (
+	timeSeriesParamInterval  = "interval"
+	timeSeriesParamAgg       = "agg"
+	timeSeriesParamGroupBy   = "group_by"
+	timeSeriesParamTimeField = "time_field"
+
+	maxTimeSeriesBuckets = 10000
+	maxTimeSeriesFields  = 20
+	timeSeriesBucketKey  = "bucket"
+)
+
+type timeSeriesConfig struct {
+	TimeField string   `mapstructure:"time_field"`
+	Tags      []string `mapstructure:"tags"`
+	Retention string   `mapstructure:"retention"`
+}
+
+type timeSeriesQuery struct {
+	TimeField string
+	Interval  time.Duration
+	Agg       string
+	Fields    []string
+	GroupBy   []string
+	Filters   url.Values
+	Limit     int
+}
+
+// timeSeriesQuerier 为可选能力：按时间分桶聚合，返回的每行包含 bucket、字段聚合值与分组字段
+type timeSeriesQuerier interface {
+	TimeSeries(ctx context.Context, tc *tableConfig, q timeSeriesQuery) ([]map[string]interface{}, error)
+}
+
+var timeSeriesAggs = map[string]string{"avg": "AVG", "sum": "SUM", "min": "MIN", "max": "MAX", "count": "COUNT"}
+
+// parseInterval 支持 Go duration 以及 d（天）、w（周）后缀
+func parseInterval(s string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	if n, ok := strings.CutSuffix(s, "w"); ok {
+		weeks, err := strconv.Atoi(n)
+		return time.Duration(weeks) * 7 * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+func (dm *databaseManager) handleTimeSeries(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opList) {
+		return
+	}
+	querier, ok := adapter.(timeSeriesQuerier)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "timeseries is not supported by this database type"})
+		return
+	}
+	raw := c.Request.URL.Query()
+	q := timeSeriesQuery{Agg: strings.ToLower(raw.Get(timeSeriesParamAgg)), Limit: maxTimeSeriesBuckets}
+	if q.Agg == "" {
+		q.Agg = "avg"
+	}
+	if _, ok := timeSeriesAggs[q.Agg]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agg, expected avg, sum, min, max or count"})
+		return
+	}
+	q.Interval, err = parseInterval(raw.Get(timeSeriesParamInterval))
+	if err != nil || q.Interval < time.Second {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected a duration of at least 1s such as 30s, 5m, 1h, 1d"})
+		return
+	}
+	q.TimeField = tableConfig.TimeSeries.TimeField
+	if v := raw.Get(timeSeriesParamTimeField); v != "" {
+		q.TimeField = tableConfig.physicalFieldName(v)
+	}
+	if q.TimeField == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "time_field is required, set timeseries.time_field in table config or pass time_field"})
+		return
+	}
+	q.GroupBy = tableConfig.physicalFieldNames(parseStringList(raw.Get(timeSeriesParamGroupBy)))
+	for _, p := range []string{timeSeriesParamInterval, timeSeriesParamAgg, timeSeriesParamGroupBy, timeSeriesParamTimeField} {
+		raw.Del(p)
+	}
+	q.Filters = tableConfig.physicalQuery(raw)
+	q.Fields = parseStringList(q.Filters.Get(queryParamFields))
+	if len(q.Fields) == 0 && q.Agg != "count" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fields is required for agg " + q.Agg})
+		return
+	}
+	if len(q.Fields)+len(q.GroupBy) > maxTimeSeriesFields {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many fields, max %d", maxTimeSeriesFields)})
+		return
+	}
+	// 多取一行用于判断分桶是否超限
+	q.Limit = maxTimeSeriesBuckets + 1
+	rows, err := querier.TimeSeries(c.Request.Context(), tableConfig, q)
+	if err != nil {
+		var unknown *unknownFieldError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown field: " + tableConfig.apiFieldName(unknown.Field)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query timeseries: " + err.Error()})
+		return
+	}
+	if len(rows) > maxTimeSeriesBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many buckets (max %d), increase interval or narrow the time range", maxTimeSeriesBuckets)})
+		return
+	}
+	format, loc, _, hasFormat := tableConfig.timeSettings(q.TimeField)
+	for _, row := range rows {
+		if t, ok := asTime(row[timeSeriesBucketKey], true); ok {
+			if hasFormat {
+				row[timeSeriesBucketKey] = formatTime(t, format, loc)
+			} else {
+				row[timeSeriesBucketKey] = t.UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"interval": q.Interval.String(),
+		"agg":      q.Agg,
+		"data":     tableConfig.apiRecords(rows),
+	})
+}
+
+// timeSeriesResult 把别名列（f0/g0）还原为字段名
+func timeSeriesResult(q timeSeriesQuery, get func(key string) interface{}) map[string]interface{} {
+	row := map[string]interface{}{timeSeriesBucketKey: get(timeSeriesBucketKey)}
+	if len(q.Fields) == 0 {
+		row["count"] = normalizeStatsValue(get("f0"))
+	}
+	for i, f := range q.Fields {
+		row[f] = normalizeStatsValue(get(fmt.Sprintf("f%d", i)))
+	}
+	for i, g := range q.GroupBy {
+		row[g] = get(fmt.Sprintf("g%d", i))
+	}
+	return row
+}
+
+// ---- GORM ----
+
+// timescaleEnabled 缓存各数据库是否安装了 TimescaleDB 扩展
+var timescaleEnabled sync.Map
+
+func (a *gormAdapter) hasTimescale(ctx context.Context) bool {
+	if v, ok := timescaleEnabled.Load(a.config); ok {
+		return v.(bool)
+	}
+	var n int64
+	err := a.db.WithContext(ctx).Raw(`SELECT COUNT(*) FROM pg_extension WHERE extname = 'timescaledb'`).Scan(&n).Error
+	enabled := err == nil && n > 0
+	timescaleEnabled.Store(a.config, enabled)
+	return enabled
+}
+
+// timeBucketExpr 返回按方言把时间列截断到 seconds 秒整数倍的表达式
+func (a *gormAdapter) timeBucketExpr(ctx context.Context, col string, seconds int64) (string, error) {
+	switch a.db.Dialector.Name() {
+	case "postgres":
+		if a.hasTimescale(ctx) {
+			return fmt.Sprintf("time_bucket(INTERVAL '%d seconds', %s)", seconds, col), nil
+		}
+		return fmt.Sprintf("to_timestamp(floor(extract(epoch FROM %s) / %d) * %d)", col, seconds, seconds), nil
+	case "mysql":
+		return fmt.Sprintf("FROM_UNIXTIME(FLOOR(UNIX_TIMESTAMP(%s) / %d) * %d)", col, seconds, seconds), nil
+	case "sqlite":
+		return fmt.Sprintf("datetime((CAST(strftime('%%s', %s) AS INTEGER) / %d) * %d, 'unixepoch')", col, seconds, seconds), nil
+	case "sqlserver":
+		return fmt.Sprintf("DATEADD(second, (DATEDIFF_BIG(second, '1970-01-01', %s) / %d) * %d, '1970-01-01')", col, seconds, seconds), nil
+	case "clickhouse":
+		return fmt.Sprintf("toStartOfInterval(%s, INTERVAL %d SECOND)", col, seconds), nil
+	case "snowflake":
+		return fmt.Sprintf("TIME_SLICE(%s, %d, 'SECOND')", col, seconds), nil
+	}
+	return "", fmt.Errorf("timeseries is not supported for %s", a.db.Dialector.Name())
+}
+
+func (a *gormAdapter) TimeSeries(ctx context.Context, tc *tableConfig, q timeSeriesQuery) ([]map[string]interface{}, error) {
+	db := a.db.WithContext(ctx)
+	columnTypes, err := db.Migrator().ColumnTypes(tc.Name)
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]struct{}, len(columnTypes))
+	for _, ct := range columnTypes {
+		columns[ct.Name()] = struct{}{}
+	}
+	// 字段名会拼入 SQL，必须是表中存在的列
+	quote := func(f string) (string, error) {
+		if _, ok := columns[f]; !ok {
+			return "", &unknownFieldError{Field: f}
+		}
+		return db.Statement.Quote(f), nil
+	}
+	timeCol, err := quote(q.TimeField)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := a.timeBucketExpr(ctx, timeCol, int64(q.Interval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	exprs := []string{bucket + " AS " + timeSeriesBucketKey}
+	groups := []string{bucket}
+	fn := timeSeriesAggs[q.Agg]
+	if len(q.Fields) == 0 {
+		exprs = append(exprs, "COUNT(*) AS f0")
+	}
+	for i, f := range q.Fields {
+		col, err := quote(f)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, fmt.Sprintf("%s(%s) AS f%d", fn, col, i))
+	}
+	for i, g := range q.GroupBy {
+		col, err := quote(g)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, fmt.Sprintf("%s AS g%d", col, i))
+		groups = append(groups, col)
+	}
+	var rows []map[string]interface{}
+	err = a.read(ctx, func(db *gorm.DB) error {
+		query := applyGormSoftDeleteFilter(db.Table(tc.Name), tc)
+		query, _ = applyGormQueryFilters(query, q.Filters)
+		return query.Select(strings.Join(exprs, ", ")).
+			Group(strings.Join(groups, ", ")).
+			Order(timeSeriesBucketKey).
+			Limit(q.Limit).
+			Find(&rows).Error
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		result[i] = timeSeriesResult(q, func(key string) interface{} {
+			// 部分驱动会把别名转为大写
+			if v, ok := row[key]; ok {
+				return v
+			}
+			return row[strings.ToUpper(key)]
+		})
+	}
+	return result, nil
+}
+
+// ---- Mongo ----
+
+var mongoTimeSeriesAccumulators = map[string]string{"avg": "$avg", "sum": "$sum", "min": "$min", "max": "$max"}
+
+func (a *mongoAdapter) TimeSeries(ctx context.Context, tc *tableConfig, q timeSeriesQuery) ([]map[string]interface{}, error) {
+	collection := a.collection(ctx, tc)
+	filter := applyMongoSoftDeleteFilter(ctx, bson.M{}, tc)
+	filter, _ = buildMongoQueryFilter(filter, q.Filters)
+	ms := q.Interval.Milliseconds()
+	epoch := bson.M{"$toLong": "$" + q.TimeField}
+	id := bson.M{timeSeriesBucketKey: bson.M{"$toDate": bson.M{"$subtract": bson.A{epoch, bson.M{"$mod": bson.A{epoch, ms}}}}}}
+	for i, g := range q.GroupBy {
+		id[fmt.Sprintf("g%d", i)] = "$" + g
+	}
+	group := bson.M{"_id": id}
+	if len(q.Fields) == 0 || q.Agg == "count" {
+		if len(q.Fields) == 0 {
+			group["f0"] = bson.M{"$sum": 1}
+		}
+		for i, f := range q.Fields {
+			group[fmt.Sprintf("f%d", i)] = bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$" + f, nil}}, nil}}, 0, 1}}}
+		}
+	} else {
+		for i, f := range q.Fields {
+			group[fmt.Sprintf("f%d", i)] = bson.M{mongoTimeSeriesAccumulators[q.Agg]: "$" + f}
+		}
+	}
+	filter[q.TimeField] = mergeMongoCondition(filter[q.TimeField], bson.M{"$ne": nil})
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": group},
+		bson.M{"$sort": bson.M{"_id." + timeSeriesBucketKey: 1}},
+		bson.M{"$limit": q.Limit},
+	}
+	cur, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var docs []bson.M
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		idDoc, _ := doc["_id"].(bson.M)
+		result[i] = timeSeriesResult(q, func(key string) interface{} {
+			if strings.HasPrefix(key, "f") {
+				return doc[key]
+			}
+			return idDoc[key]
+		})
+	}
+	return result, nil
+}
+
+// mergeMongoCondition 合并同一字段上已有的过滤条件
+func mergeMongoCondition(existing interface{}, cond bson.M) interface{} {
+	switch e := existing.(type) {
+	case nil:
+		return cond
+	case bson.M:
+		for k, v := range cond {
+			if _, ok := e[k]; !ok {
+				e[k] = v
+			}
+		}
+		return e
+	default:
+		// 已是等值条件，无需额外限制
+		return existing
+	}
+}
+
+// ---- TimescaleDB 元数据 ----
+
+// applyTimescaleMeta 识别 hypertable 的时间维度与保留策略；未安装扩展时查询失败，直接忽略
+func applyTimescaleMeta(db *sql.DB, tables []TableMeta) {
+	rows, err := db.Query(`
+		SELECT hypertable_name, column_name
+		FROM timescaledb_information.dimensions
+		WHERE hypertable_schema = 'public' AND dimension_type = 'Time'
+	`)
+	if err != nil {
+		return
+	}
+	timeFields := map[string]string{}
+	for rows.Next() {
+		var table, column string
+		if rows.Scan(&table, &column) == nil {
+			if _, ok := timeFields[table]; !ok {
+				timeFields[table] = column
+			}
+		}
+	}
+	rows.Close()
+	retentions := map[string]string{}
+	if rows, err := db.Query(`
+		SELECT hypertable_name, config->>'drop_after'
+		FROM timescaledb_information.jobs
+		WHERE proc_name = 'policy_retention' AND hypertable_schema = 'public'
+	`); err == nil {
+		for rows.Next() {
+			var table string
+			var dropAfter sql.NullString
+			if rows.Scan(&table, &dropAfter) == nil && dropAfter.Valid {
+				retentions[table] = dropAfter.String
+			}
+		}
+		rows.Close()
+	}
+	for i := range tables {
+		if field, ok := timeFields[tables[i].Name]; ok {
+			tables[i].TimeSeries = &TimeSeriesMeta{TimeField: field, Retention: retentions[tables[i].Name]}
+		}
+	}
+}
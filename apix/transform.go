@@ -0,0 +1,260 @@
+package apix
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --------- 响应字段转换 ---------
+//
+// 表配置中为字段声明输出转换（物理列名），按顺序执行：
+//
+//	field_transforms:
+//	  email: [trim, lowercase]                                  # trim、lowercase、uppercase 只处理字符串
+//	  amount: [{format_number: {decimals: 2, thousands: ","}}]  # 1234.5 → "1,234.50"
+//	  status: [{map: {"0": 停用, "1": 启用}}]                    # 代码 → 名称，未列出的值保持不变
+//	  full_name: [{template: "{first_name} {last_name}"}]       # 拼接其他字段，可生成表中不存在的字段
+//
+// 转换在字段隐藏与脱敏之后、字段别名之前执行，模板只能读到对外可见的值；
+// 所有转换基于读取到的原始记录计算，字段之间互不影响。format_number、map、template 的结果为字符串，
+// swagger 中相应字段的类型为 string，模板生成的新字段为只读。未知的转换在加载配置时报错。
+
+type fieldTransformStep func(v interface{}, record map[string]interface{}) interface{}
+
+type fieldTransform struct {
+	field string
+	steps []fieldTransformStep
+	// 第一步为模板时，字段可以不在记录中
+	generated bool
+	// 结果为字符串
+	stringResult bool
+}
+
+var transformTemplateRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// compileFieldTransforms 解析 field_transforms，字段按名称排序以保证输出稳定
+func compileFieldTransforms(cfg map[string][]interface{}) ([]fieldTransform, error) {
+	fields := make([]string, 0, len(cfg))
+	for f := range cfg {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	var out []fieldTransform
+	for _, f := range fields {
+		t := fieldTransform{field: f}
+		for i, raw := range cfg[f] {
+			step, err := compileTransformStep(raw)
+			if err != nil {
+				return nil, fmt.Errorf("field_transforms.%s: %w", f, err)
+			}
+			name := transformStepName(raw)
+			if i == 0 && name == "template" {
+				t.generated = true
+			}
+			if name == "format_number" || name == "map" || name == "template" {
+				t.stringResult = true
+			}
+			t.steps = append(t.steps, step)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func transformStepName(raw interface{}) string {
+	if name, ok := raw.(string); ok {
+		return name
+	}
+	if m, ok := stringKeyMap(raw); ok {
+		for name := range m {
+			return name
+		}
+	}
+	return ""
+}
+
+// stringKeyMap 兼容 yaml 解析出的非字符串键（如 map 转换中的数字代码）
+func stringKeyMap(raw interface{}) (map[string]interface{}, bool) {
+	switch m := raw.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func compileTransformStep(raw interface{}) (fieldTransformStep, error) {
+	if name, ok := raw.(string); ok {
+		switch name {
+		case "trim":
+			return stringStep(strings.TrimSpace), nil
+		case "lowercase":
+			return stringStep(strings.ToLower), nil
+		case "uppercase":
+			return stringStep(strings.ToUpper), nil
+		}
+		return nil, fmt.Errorf("unknown transform %q", name)
+	}
+	m, ok := stringKeyMap(raw)
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("invalid transform %v", raw)
+	}
+	for name, arg := range m {
+		switch name {
+		case "format_number":
+			var opts struct {
+				Decimals  int
+				Thousands string
+			}
+			if args, ok := stringKeyMap(arg); ok {
+				if d, err := strconv.Atoi(fmt.Sprint(args["decimals"])); err == nil {
+					opts.Decimals = d
+				}
+				if s, ok := args["thousands"].(string); ok {
+					opts.Thousands = s
+				}
+			}
+			return func(v interface{}, _ map[string]interface{}) interface{} {
+				return formatNumber(v, opts.Decimals, opts.Thousands)
+			}, nil
+		case "map":
+			labels, ok := stringKeyMap(arg)
+			if !ok {
+				return nil, fmt.Errorf("map expects code: label pairs")
+			}
+			return func(v interface{}, _ map[string]interface{}) interface{} {
+				if v == nil {
+					return nil
+				}
+				code := fmt.Sprint(v)
+				if label, ok := labels[code]; ok {
+					return fmt.Sprint(label)
+				}
+				// 配置经 viper 加载后键为小写
+				if label, ok := labels[strings.ToLower(code)]; ok {
+					return fmt.Sprint(label)
+				}
+				return v
+			}, nil
+		case "template":
+			tpl, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("template expects a string")
+			}
+			return func(_ interface{}, record map[string]interface{}) interface{} {
+				return transformTemplateRe.ReplaceAllStringFunc(tpl, func(m string) string {
+					v := record[m[1:len(m)-1]]
+					if v == nil {
+						return ""
+					}
+					return fmt.Sprint(v)
+				})
+			}, nil
+		}
+		return nil, fmt.Errorf("unknown transform %q", name)
+	}
+	return nil, nil
+}
+
+func stringStep(fn func(string) string) fieldTransformStep {
+	return func(v interface{}, _ map[string]interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			return fn(s)
+		}
+		return v
+	}
+}
+
+// formatNumber 按小数位与千分位分隔符格式化，无法解析为数字的值原样返回
+func formatNumber(v interface{}, decimals int, thousands string) interface{} {
+	if v == nil {
+		return nil
+	}
+	f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+	if err != nil {
+		return v
+	}
+	s := strconv.FormatFloat(f, 'f', decimals, 64)
+	if thousands == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// applyFieldTransforms 按表配置转换记录中的字段
+func (tc *tableConfig) applyFieldTransforms(record map[string]interface{}) map[string]interface{} {
+	if record == nil || len(tc.transforms) == 0 {
+		return record
+	}
+	original := copyRecord(record)
+	for _, t := range tc.transforms {
+		v, ok := original[t.field]
+		if !ok && !t.generated {
+			continue
+		}
+		for _, step := range t.steps {
+			v = step(v, original)
+		}
+		record[t.field] = v
+	}
+	return record
+}
+
+// ---- swagger ----
+
+// applyFieldTransformMeta 标记结果为字符串的字段，并收集模板生成的新字段，需在 applyFieldAliases 之前调用
+func applyFieldTransformMeta(t TableMeta, cfg map[string][]interface{}) TableMeta {
+	if len(cfg) == 0 {
+		return t
+	}
+	stringResult := map[string]bool{}
+	generated := map[string]bool{}
+	for field, steps := range cfg {
+		for i, raw := range steps {
+			switch transformStepName(raw) {
+			case "format_number", "map":
+				stringResult[field] = true
+			case "template":
+				stringResult[field] = true
+				generated[field] = i == 0
+			}
+		}
+	}
+	fields := make([]FieldMeta, len(t.Fields))
+	for i, f := range t.Fields {
+		f.Transformed = stringResult[f.Name]
+		fields[i] = f
+		delete(generated, f.Name)
+	}
+	t.Fields = fields
+	for field, ok := range generated {
+		if ok {
+			t.Computed = append(t.Computed, field)
+		}
+	}
+	sort.Strings(t.Computed)
+	return t
+}
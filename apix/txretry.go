@@ -0,0 +1,55 @@
+package apix
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// --------- 事务重试 ---------
+//
+// TiDB 乐观事务与 CockroachDB 的串行化隔离都可能在提交时失败，需要客户端重新执行整个事务。
+// gormAdapter 的写操作统一通过 transaction 执行，fn 需可重入（不依赖上次执行留下的状态）。
+
+const txRetryBaseDelay = 20 * time.Millisecond
+
+// txRetryPolicy 返回数据库类型对应的重试次数与错误判断
+func (a *gormAdapter) txRetryPolicy() (int, func(error) bool) {
+	if a.config == nil {
+		return 0, nil
+	}
+	switch strings.ToLower(a.config.Type) {
+	case "tidb":
+		return a.config.TiDB.WriteConflictRetries, isTiDBRetryableError
+	case "cockroachdb":
+		return a.config.CockroachDB.retries(), isCockroachRetryableError
+	}
+	return 0, nil
+}
+
+// transaction 在事务中执行 fn，遇到可重试的提交冲突时按退避重新执行；提交成功后执行事务中登记的 afterCommit 操作
+func (a *gormAdapter) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	retries, retryable := a.txRetryPolicy()
+	var hooks *afterCommitHooks
+	// 适配器绑定在外层事务上（runInRollbackTx）时提交的只是保存点，不收集提交后的操作
+	if _, nested := a.db.Statement.ConnPool.(gorm.TxCommitter); !nested {
+		ctx, hooks = withAfterCommit(ctx)
+	}
+	for attempt := 0; ; attempt++ {
+		hooks.reset()
+		err := a.db.WithContext(ctx).Transaction(fn, txOptions(ctx)...)
+		if err == nil {
+			hooks.run()
+		}
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(txRetryBaseDelay << attempt):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------- 批量 upsert ---------
+//
+//	POST /api/rest/:database/:table/upsert                     按 unique_keys 中第一组不含软删除字段的唯一键判断冲突
+//	POST /api/rest/:database/:table/upsert?on_conflict=email   指定冲突键（主键或 unique_keys 中的一组）
+//	[{"email": "a@b.c", "username": "alice"}, ...]
+//
+// 冲突键不存在的记录按创建处理（应用 default_values 与 auto_actor_fields），已存在的只更新请求中出现的字段与 auto_update 字段，
+// 冲突键、主键、immutable_fields 与创建时的操作人字段只在插入时写入。SQL 使用 ON CONFLICT / ON DUPLICATE KEY，
+// 冲突键必须与数据库中的唯一索引一致；MongoDB 使用 upsert 的 UpdateOne（$set 与 $setOnInsert）。
+// 同一请求的记录字段应一致，缺少的字段在插入时按 NULL 处理。需要 create 与 update 两种操作角色。
+// 配置了 version/merge 并发策略、行版本历史、状态流转或 row_filter 的表无法在 upsert 中逐条校验，返回 400。
+
+const queryParamOnConflict = "on_conflict"
+
+// upsertResult 中 Inserted/Updated 仅在适配器能区分时返回（MongoDB）
+type upsertResult struct {
+	Affected int64
+	Inserted *int64
+	Updated  *int64
+}
+
+// upserter 为可选能力：按冲突键插入或更新；keys 与 updateFields 为物理列名，updateFields 为空时已存在的记录保持不变
+type upserter interface {
+	Upsert(ctx context.Context, tc *tableConfig, keys, updateFields []string, records []map[string]interface{}) (upsertResult, error)
+}
+
+// upsertKeys 解析冲突键，未指定时取 unique_keys 中第一组不含软删除字段的唯一键，没有时使用主键
+func (tc *tableConfig) upsertKeys(param string) ([]string, error) {
+	uniques := tc.GetUniqueKeys()
+	if param == "" {
+		for _, uk := range uniques {
+			if !contains(uk, tc.SoftDeleteKey) {
+				return uk, nil
+			}
+		}
+		if tc.PrimaryKey != "" {
+			return []string{tc.PrimaryKey}, nil
+		}
+		return nil, fmt.Errorf("upsert requires unique_keys or a primary key")
+	}
+	fields := tc.physicalFieldNames(parseStringList(param))
+	if len(fields) == 1 && fields[0] == tc.PrimaryKey {
+		return fields, nil
+	}
+	for _, uk := range uniques {
+		if strings.Join(uk, ",") == strings.Join(fields, ",") {
+			return fields, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be the primary key or one of unique_keys: %s", queryParamOnConflict, param)
+}
+
+// upsertUnsupported 返回无法 upsert 的原因
+func (tc *tableConfig) upsertUnsupported() string {
+	switch {
+	case tc.checksConcurrency():
+		return "upsert is not supported for tables with concurrency strategy " + tc.Concurrency.Strategy
+	case tc.History.Enabled:
+		return "upsert is not supported for tables with history enabled"
+	case tc.StateMachine.Field != "" && len(tc.StateMachine.Transitions) > 0:
+		return "upsert is not supported for tables with state_machine transitions"
+	case len(tc.rowFilter) > 0:
+		return "upsert is not supported for tables with row_filter"
+	}
+	return ""
+}
+
+func (dm *databaseManager) handleUpsert(c *gin.Context) {
+	dbName := c.Param("database")
+	tableAlias := c.Param("table")
+	adapter, tableConfig, err := dm.getAdapterAndTableConfig(dbName, tableAlias)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !dm.authorize(c, tableConfig, opCreate) || !dm.authorize(c, tableConfig, opUpdate) {
+		return
+	}
+	if _, ok := adapter.(upserter); !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "upsert is not supported by this database type"})
+		return
+	}
+	if reason := tableConfig.upsertUnsupported(); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+	keys, err := tableConfig.upsertKeys(c.Query(queryParamOnConflict))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var records []map[string]interface{}
+	if err := c.ShouldBindJSON(&records); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload: " + err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No records to upsert"})
+		return
+	}
+	// 更新的字段为请求中出现的字段（默认值与创建字段之前）
+	supplied := map[string]bool{}
+	actor := dm.currentActor(c)
+	for i := range records {
+		if records[i], err = tableConfig.inputRecord(records[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, k := range keys {
+			if records[i][k] == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("record %d missing conflict key '%s'", i, tableConfig.apiFieldName(k))})
+				return
+			}
+		}
+		for f := range records[i] {
+			supplied[f] = true
+		}
+		if err := applyDefaultValues(records[i], tableConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		applyAutoUpdateFields(records[i], tableConfig)
+		applyAutoActorFields(records[i], tableConfig, actor, true)
+		applyAutoActorFields(records[i], tableConfig, actor, false)
+	}
+	if err := tableConfig.validateRecords(records, false); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	if err := tableConfig.guardInitialStates(records); err != nil {
+		writeGuardError(c, err)
+		return
+	}
+	for _, f := range tableConfig.GetAutoUpdateFields() {
+		supplied[f] = true
+	}
+	if actor != "" {
+		for _, f := range tableConfig.AutoActorFields.OnUpdate {
+			supplied[f] = true
+		}
+	}
+	for _, f := range append(append([]string{tableConfig.PrimaryKey}, keys...), tableConfig.ImmutableFields...) {
+		delete(supplied, f)
+	}
+	for _, f := range tableConfig.AutoActorFields.OnCreate {
+		if !contains(tableConfig.AutoActorFields.OnUpdate, f) {
+			delete(supplied, f)
+		}
+	}
+	updateFields := make([]string, 0, len(supplied))
+	for f := range supplied {
+		updateFields = append(updateFields, f)
+	}
+	sort.Strings(updateFields)
+
+	var result upsertResult
+	executed, err := runMutation(c.Request.Context(), adapter, dryRun, func(a databaseAdapter) error {
+		var err error
+		result, err = a.(upserter).Upsert(c.Request.Context(), tableConfig, keys, updateFields, records)
+		if err != nil || tableConfig.PrimaryKey == "" || contains(keys, tableConfig.PrimaryKey) {
+			return err
+		}
+		return fillUpsertIDs(c.Request.Context(), a, tableConfig, keys, records)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upsert: " + err.Error()})
+		return
+	}
+	records = fixPkFieldToString(records, tableConfig.PrimaryKey).([]map[string]interface{})
+	resp := gin.H{
+		"on_conflict":   tableConfig.apiFieldNames(keys),
+		"update_fields": tableConfig.apiFieldNames(updateFields),
+		"affected":      result.Affected,
+		"data":          tableConfig.renderRecords(records),
+	}
+	if result.Inserted != nil {
+		resp["inserted"] = *result.Inserted
+	}
+	if result.Updated != nil {
+		resp["updated"] = *result.Updated
+	}
+	if dryRun {
+		resp["dry_run"], resp["executed"] = true, executed
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// fillUpsertIDs 按冲突键读回主键：驱动回填的自增主键对被更新的记录不可靠，默认值生成的主键也未被使用
+func fillUpsertIDs(ctx context.Context, adapter databaseAdapter, tc *tableConfig, keys []string, records []map[string]interface{}) error {
+	lookups := make([]lookupKey, len(records))
+	for i, r := range records {
+		values := make([]interface{}, len(keys))
+		for j, k := range keys {
+			values[j] = r[k]
+		}
+		lookups[i] = lookupKey{Fields: keys, Values: values}
+	}
+	found, err := batchGetRecords(ctx, adapter, tc, lookups, tc.PrimaryKey)
+	if err != nil {
+		return err
+	}
+	for i, existing := range found {
+		delete(records[i], idPlaceholder)
+		if existing == nil {
+			// 命中的是已软删除的记录
+			delete(records[i], tc.PrimaryKey)
+			continue
+		}
+		records[i][tc.PrimaryKey] = existing[tc.PrimaryKey]
+	}
+	return nil
+}
+
+// ---- GORM ----
+
+func (a *gormAdapter) Upsert(ctx context.Context, tc *tableConfig, keys, updateFields []string, records []map[string]interface{}) (upsertResult, error) {
+	columns := make([]clause.Column, len(keys))
+	for i, k := range keys {
+		columns[i] = clause.Column{Name: k}
+	}
+	onConflict := clause.OnConflict{Columns: columns, DoNothing: len(updateFields) == 0}
+	if len(updateFields) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateFields)
+	}
+	var result upsertResult
+	err := a.transaction(ctx, func(tx *gorm.DB) error {
+		res := tx.Table(tc.Name).Clauses(onConflict).Create(&records)
+		result.Affected = res.RowsAffected
+		return res.Error
+	})
+	return result, err
+}
+
+// ---- Mongo ----
+
+func (a *mongoAdapter) Upsert(ctx context.Context, tc *tableConfig, keys, updateFields []string, records []map[string]interface{}) (upsertResult, error) {
+	collection := a.collection(ctx, tc)
+	models := make([]mongo.WriteModel, len(records))
+	for i, rec := range records {
+		filter := bson.M{}
+		for _, k := range keys {
+			v := rec[k]
+			if k == "_id" {
+				if str, ok := v.(string); ok && len(str) == 24 {
+					if oid, err := primitive.ObjectIDFromHex(str); err == nil {
+						v = oid
+					}
+				}
+			}
+			filter[k] = v
+		}
+		set, setOnInsert := bson.M{}, bson.M{}
+		for k, v := range rec {
+			if _, isKey := filter[k]; isKey {
+				continue
+			}
+			if contains(updateFields, k) {
+				set[k] = v
+			} else {
+				setOnInsert[k] = v
+			}
+		}
+		update := bson.M{}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(setOnInsert) > 0 {
+			update["$setOnInsert"] = setOnInsert
+		}
+		if len(update) == 0 {
+			// 只有冲突键时仍需一个更新操作符才能插入
+			update["$setOnInsert"] = filter
+		}
+		models[i] = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+	}
+	res, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+	if err != nil {
+		return upsertResult{}, err
+	}
+	inserted, updated := res.UpsertedCount, res.MatchedCount
+	for i, id := range res.UpsertedIDs {
+		if tc.PrimaryKey == "_id" && records[i]["_id"] == nil {
+			records[i]["_id"] = id
+		}
+	}
+	return upsertResult{Affected: inserted + res.ModifiedCount, Inserted: &inserted, Updated: &updated}, nil
+}
@@ -0,0 +1,217 @@
+package apix
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --------- 字段校验规则 ---------
+//
+// 表配置中按物理列名声明校验规则，写入数据库前在 handler 中校验：
+//
+//	validations:
+//	  username: {required: true, max_length: 32, regex: '^[a-z][a-z0-9_]*$'}
+//	  age: {min: 0, max: 150}
+//	  status: {enum: [draft, published]}
+//
+// 创建（批量创建、upsert、导入、批量任务）时 required 要求字段存在且不为 null 或空字符串；
+// 更新（单条、批量、update_where）只校验请求中出现的字段，出现时同样不能为空。
+// min/max 比较数值（数字字符串按数值比较），max_length 按字符数计算，regex 与 enum 比较值的字符串形式。
+// 不通过时返回 422，errors 列出每个失败的字段：{"index": 0, "field": "age", "rule": "max", "message": "must be <= 150"}，
+// 单条更新时没有 index。规则同时写入生成的 swagger（minimum、maximum、maxLength、pattern、enum、required）。
+
+type fieldValidation struct {
+	Required  bool          `mapstructure:"required" yaml:"required" json:"required,omitempty"`
+	Min       *float64      `mapstructure:"min" yaml:"min" json:"min,omitempty"`
+	Max       *float64      `mapstructure:"max" yaml:"max" json:"max,omitempty"`
+	Regex     string        `mapstructure:"regex" yaml:"regex" json:"regex,omitempty"`
+	Enum      []interface{} `mapstructure:"enum" yaml:"enum" json:"enum,omitempty"`
+	MaxLength int           `mapstructure:"max_length" yaml:"max_length" json:"max_length,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+type validationFailure struct {
+	Index   *int   `json:"index,omitempty"`
+	Field   string `json:"field"` // API 字段名
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type validationError struct {
+	Failures []validationFailure
+}
+
+func (e *validationError) Error() string {
+	fields := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		if !contains(fields, f.Field) {
+			fields = append(fields, f.Field)
+		}
+	}
+	return "Validation failed: " + strings.Join(fields, ",")
+}
+
+// compileValidations 校验规则配置并编译正则
+func compileValidations(tc *tableConfig) error {
+	for field, v := range tc.Validations {
+		if v.Regex != "" {
+			re, err := regexp.Compile(v.Regex)
+			if err != nil {
+				return fmt.Errorf("table %s: invalid regex for %s: %w", tc.Name, field, err)
+			}
+			v.pattern = re
+		}
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return fmt.Errorf("table %s: validation min > max for %s", tc.Name, field)
+		}
+		if v.MaxLength < 0 {
+			return fmt.Errorf("table %s: negative max_length for %s", tc.Name, field)
+		}
+		tc.Validations[field] = v
+	}
+	return nil
+}
+
+// validateRecords 按规则校验待写入的记录（物理列名），失败项带记录序号；partial 为更新，只校验出现的字段
+func (tc *tableConfig) validateRecords(records []map[string]interface{}, partial bool) error {
+	return tc.validate(records, partial, true)
+}
+
+// validateUpdate 校验单条更新的内容
+func (tc *tableConfig) validateUpdate(record map[string]interface{}) error {
+	return tc.validate([]map[string]interface{}{record}, true, false)
+}
+
+func (tc *tableConfig) validate(records []map[string]interface{}, partial, indexed bool) error {
+	if len(tc.Validations) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(tc.Validations))
+	for f := range tc.Validations {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	var failures []validationFailure
+	for i, rec := range records {
+		for _, f := range fields {
+			v, present := rec[f]
+			if !present && partial {
+				continue
+			}
+			for _, fail := range tc.Validations[f].check(v) {
+				fail.Field = tc.apiFieldName(f)
+				if indexed {
+					index := i
+					fail.Index = &index
+				}
+				failures = append(failures, fail)
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &validationError{Failures: failures}
+}
+
+// check 返回值不满足的规则；空值只检查 required
+func (v fieldValidation) check(value interface{}) []validationFailure {
+	if value == nil || value == "" {
+		if v.Required {
+			return []validationFailure{{Rule: "required", Message: "is required"}}
+		}
+		return nil
+	}
+	var failures []validationFailure
+	if v.Min != nil || v.Max != nil {
+		n, ok := validationNumber(value)
+		switch {
+		case !ok:
+			failures = append(failures, validationFailure{Rule: "type", Message: "must be a number"})
+		case v.Min != nil && n < *v.Min:
+			failures = append(failures, validationFailure{Rule: "min", Message: "must be >= " + formatValidationNumber(*v.Min)})
+		case v.Max != nil && n > *v.Max:
+			failures = append(failures, validationFailure{Rule: "max", Message: "must be <= " + formatValidationNumber(*v.Max)})
+		}
+	}
+	s := keyValueString(value)
+	if v.MaxLength > 0 && utf8.RuneCountInString(s) > v.MaxLength {
+		failures = append(failures, validationFailure{Rule: "max_length", Message: fmt.Sprintf("must be at most %d characters", v.MaxLength)})
+	}
+	if v.pattern != nil && !v.pattern.MatchString(s) {
+		failures = append(failures, validationFailure{Rule: "regex", Message: "must match " + v.Regex})
+	}
+	if len(v.Enum) > 0 {
+		allowed := make([]string, len(v.Enum))
+		for i, e := range v.Enum {
+			allowed[i] = keyValueString(e)
+		}
+		if !contains(allowed, s) {
+			failures = append(failures, validationFailure{Rule: "enum", Message: "must be one of " + strings.Join(allowed, ", ")})
+		}
+	}
+	return failures
+}
+
+func validationNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	case int32:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	}
+	return toFloat(v)
+}
+
+func formatValidationNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// ---- swagger ----
+
+// applyValidationMeta 把校验规则写入字段元数据，需在字段别名之前调用
+func applyValidationMeta(t TableMeta, validations map[string]fieldValidation) TableMeta {
+	if len(validations) == 0 {
+		return t
+	}
+	fields := make([]FieldMeta, len(t.Fields))
+	for i, f := range t.Fields {
+		if v, ok := validations[f.Name]; ok {
+			f.Validation = &v
+		}
+		fields[i] = f
+	}
+	t.Fields = fields
+	return t
+}
+
+// validationSchema 把校验规则写入字段的 swagger 属性
+func validationSchema(prop map[string]interface{}, v *fieldValidation) {
+	if v.Min != nil {
+		prop["minimum"] = *v.Min
+	}
+	if v.Max != nil {
+		prop["maximum"] = *v.Max
+	}
+	if v.MaxLength > 0 {
+		prop["maxLength"] = v.MaxLength
+	}
+	if v.Regex != "" {
+		prop["pattern"] = v.Regex
+	}
+	if len(v.Enum) > 0 {
+		prop["enum"] = v.Enum
+	}
+}
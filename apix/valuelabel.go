@@ -0,0 +1,236 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------- 编码值多语言名称 ---------
+//
+// 为编码字段（状态、类型等）输出当前语言的名称，作为 {字段}_label 伴随字段加入列表与单条查询的响应，
+// 原字段的值不变。名称可以在配置中维护，也可以取自同库的字典表（每种语言一列）：
+//
+//	value_labels:
+//	  status:
+//	    as: status_label          # 默认 {字段}_label
+//	    default_locale: en        # 没有匹配的语言时使用，默认为配置中排序后的第一个
+//	    values:
+//	      "1": {en: active, zh: 激活}
+//	      "0": {en: inactive, zh: 停用}
+//	  level:
+//	    table: level_dict         # 字典表名或别名
+//	    key: code                 # 字典表匹配列，默认主键
+//	    locales: {en: name_en, zh: name_zh}   # 语言 → 名称列
+//
+// 语言按 Accept-Language 协商：先精确匹配（zh-TW），再按主语言匹配（zh），都不匹配时使用 default_locale；
+// 某个值在所选语言下没有名称时取 default_locale 的名称，仍没有时为 null。响应带 Vary: Accept-Language。
+// 字典表与 label_joins 共用缓存（label_join_cache）。
+
+type valueLabelConfig struct {
+	As            string                       `mapstructure:"as" yaml:"as"`
+	DefaultLocale string                       `mapstructure:"default_locale" yaml:"default_locale"`
+	Values        map[string]map[string]string `mapstructure:"values" yaml:"values"`
+	Table         string                       `mapstructure:"table" yaml:"table"`
+	Key           string                       `mapstructure:"key" yaml:"key"`
+	Locales       map[string]string            `mapstructure:"locales" yaml:"locales"`
+}
+
+type valueLabel struct {
+	Field string
+	valueLabelConfig
+	locales []string // 可选语言，小写并排序
+}
+
+// checkValueLabels 校验表配置中的 value_labels，补全默认值；字典表需在同一个库中
+func checkValueLabels(dbAlias string, tables []tableConfig) error {
+	for i := range tables {
+		tc := &tables[i]
+		tc.valueLabels = nil
+		fields := make([]string, 0, len(tc.ValueLabels))
+		for f := range tc.ValueLabels {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			vl := valueLabel{Field: f, valueLabelConfig: tc.ValueLabels[f]}
+			if vl.As == "" {
+				vl.As = f + "_label"
+			}
+			switch {
+			case vl.Table != "" && len(vl.Values) > 0:
+				return fmt.Errorf("table %s in db %s: value_labels.%s: values and table are mutually exclusive", tc.Name, dbAlias, f)
+			case vl.Table != "":
+				ref := findTableConfig(tables, vl.Table)
+				if ref == nil {
+					return fmt.Errorf("table %s in db %s: value_labels.%s references unknown table '%s'", tc.Name, dbAlias, f, vl.Table)
+				}
+				if vl.Key == "" {
+					vl.Key = ref.PrimaryKey
+				}
+				if vl.Key == "" || len(vl.Locales) == 0 {
+					return fmt.Errorf("table %s in db %s: value_labels.%s requires key and locales for table '%s'", tc.Name, dbAlias, f, vl.Table)
+				}
+				columns := make(map[string]string, len(vl.Locales))
+				for locale, col := range vl.Locales {
+					columns[strings.ToLower(locale)] = col
+					vl.locales = append(vl.locales, strings.ToLower(locale))
+				}
+				vl.Locales = columns
+			case len(vl.Values) > 0:
+				seen := map[string]bool{}
+				values := make(map[string]map[string]string, len(vl.Values))
+				for code, names := range vl.Values {
+					values[code] = make(map[string]string, len(names))
+					for locale, name := range names {
+						locale = strings.ToLower(locale)
+						values[code][locale] = name
+						if !seen[locale] {
+							seen[locale] = true
+							vl.locales = append(vl.locales, locale)
+						}
+					}
+				}
+				vl.Values = values
+			default:
+				return fmt.Errorf("table %s in db %s: value_labels.%s requires values or table", tc.Name, dbAlias, f)
+			}
+			sort.Strings(vl.locales)
+			vl.DefaultLocale = strings.ToLower(vl.DefaultLocale)
+			if vl.DefaultLocale == "" {
+				vl.DefaultLocale = vl.locales[0]
+			} else if !contains(vl.locales, vl.DefaultLocale) {
+				return fmt.Errorf("table %s in db %s: value_labels.%s default_locale '%s' has no labels", tc.Name, dbAlias, f, vl.DefaultLocale)
+			}
+			tc.valueLabels = append(tc.valueLabels, vl)
+		}
+	}
+	return nil
+}
+
+// parseAcceptLanguage 按权重从高到低返回请求的语言（小写），忽略 q=0 与 *
+func parseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return primary
+}
+
+// negotiate 从请求的语言中选出已配置的语言
+func (vl *valueLabel) negotiate(requested []string) string {
+	for _, tag := range requested {
+		if contains(vl.locales, tag) {
+			return tag
+		}
+		primary := primaryLanguage(tag)
+		if contains(vl.locales, primary) {
+			return primary
+		}
+		for _, l := range vl.locales {
+			if primaryLanguage(l) == primary {
+				return l
+			}
+		}
+	}
+	return vl.DefaultLocale
+}
+
+// applyValueLabels 按请求语言为记录补充编码值名称字段
+func (dm *databaseManager) applyValueLabels(c *gin.Context, dbName string, tc *tableConfig, records ...map[string]interface{}) {
+	if len(tc.valueLabels) == 0 {
+		return
+	}
+	c.Header("Vary", "Accept-Language")
+	dm.labelCodedValues(c.Request.Context(), c.GetHeader("Accept-Language"), dbName, tc, records...)
+}
+
+func (dm *databaseManager) labelCodedValues(ctx context.Context, acceptLanguage string, dbName string, tc *tableConfig, records ...map[string]interface{}) {
+	requested := parseAcceptLanguage(acceptLanguage)
+	for i := range tc.valueLabels {
+		vl := &tc.valueLabels[i]
+		locale := vl.negotiate(requested)
+		label := func(code string) interface{} {
+			names := vl.Values[code]
+			if name, ok := names[locale]; ok {
+				return name
+			}
+			if name, ok := names[vl.DefaultLocale]; ok {
+				return name
+			}
+			return nil
+		}
+		if vl.Table != "" {
+			labels := dm.referenceLabels(ctx, dbName, labelJoin{Table: vl.Table, Key: vl.Key, Label: vl.Locales[locale]})
+			fallback := labels
+			if locale != vl.DefaultLocale {
+				fallback = dm.referenceLabels(ctx, dbName, labelJoin{Table: vl.Table, Key: vl.Key, Label: vl.Locales[vl.DefaultLocale]})
+			}
+			label = func(code string) interface{} {
+				if name := labels[code]; name != nil {
+					return name
+				}
+				return fallback[code]
+			}
+		}
+		for _, record := range records {
+			if record == nil {
+				continue
+			}
+			v, ok := record[vl.Field]
+			if !ok {
+				continue
+			}
+			if v == nil {
+				record[vl.As] = nil
+				continue
+			}
+			record[vl.As] = label(fmt.Sprint(v))
+		}
+	}
+}
+
+// ---- swagger ----
+
+// valueLabelFields 返回表配置中 value_labels 生成的字段，用于生成 swagger
+func valueLabelFields(labels map[string]valueLabelConfig) []string {
+	var fields []string
+	for f, vl := range labels {
+		if vl.As == "" {
+			vl.As = f + "_label"
+		}
+		fields = append(fields, vl.As)
+	}
+	return fields
+}
@@ -0,0 +1,98 @@
+package apix
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// --------- 启动预热 ---------
+//
+// 热点表在表配置中开启预热：
+//
+//	warmup: true
+//
+// 初始化时（路由开始处理请求之前）并发统计这些表的计数，并预读前 rows 条记录，
+// 提前建立连接、加载数据库自身的缓存，避免冷启动后的首批请求集中变慢。ego 不缓存单条记录，
+// 预读结果不保留。整个阶段受 timeout 限制，超时或出错只记录日志，不影响启动。
+//
+//	warmup:
+//	  rows: 100          # 每张表预读的记录数，0 为只统计计数
+//	  concurrency: 4     # 同时预热的表数
+//	  timeout: 30s
+
+type warmupConfig struct {
+	Rows        int           `mapstructure:"rows"`
+	Concurrency int           `mapstructure:"concurrency"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+}
+
+// warmUp 预热开启了 warmup 的表，完成或超时后返回
+func (dm *databaseManager) warmUp() {
+	type target struct {
+		dbName  string
+		adapter databaseAdapter
+		table   tableConfig
+	}
+	var targets []target
+	for dbName, dbCfg := range dm.config.Databases {
+		adapter, ok := dm.adapters[dbName]
+		if !ok {
+			continue
+		}
+		for _, t := range dbCfg.Tables {
+			if t.Warmup {
+				targets = append(targets, target{dbName, adapter, t})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	cfg := dm.config.Warmup
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	sem := make(chan struct{}, max(cfg.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := dm.warmUpTable(ctx, t.dbName, t.adapter, &t.table, cfg.Rows); err != nil {
+				log.Printf("[Warmup] %s.%s failed: %v", t.dbName, t.table.Alias, err)
+			}
+		}(t)
+	}
+	wg.Wait()
+	log.Printf("[Warmup] %d tables in %s", len(targets), time.Since(start).Round(time.Millisecond))
+}
+
+func (dm *databaseManager) warmUpTable(ctx context.Context, dbName string, adapter databaseAdapter, tc *tableConfig, rows int) error {
+	count, err := adapter.CountAll(ctx, tc)
+	if err != nil {
+		return fmt.Errorf("count: %w", err)
+	}
+	key := fmt.Sprintf("%s_%s", dbName, tc.Alias)
+	now := time.Now()
+	dm.countMutex.Lock()
+	dm.tableCounts[key] = count
+	dm.tableCountsAt[key] = now
+	dm.countMutex.Unlock()
+	dm.saveTableCount(key, count, now)
+	if rows <= 0 {
+		return nil
+	}
+	if _, _, err := adapter.List(ctx, tc, listParams{Page: 1, PageSize: rows}); err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	return nil
+}
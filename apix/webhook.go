@@ -0,0 +1,363 @@
+package apix
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"ego/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------- Webhook ---------
+//
+// 表的创建、更新、删除成功（事务提交）后，异步向配置的地址 POST 签名的 JSON。表配置：
+//
+//	webhooks:
+//	  - url: https://hooks.example.com/ego
+//	    events: [created, updated, deleted]   # 为空时全部
+//	    secret: ""                            # 覆盖全局 webhooks.secret
+//	    headers: {X-Env: prod}
+//
+// 全局配置：
+//
+//	webhooks:
+//	  secret: change-me              # HMAC-SHA256 签名密钥，表未单独配置时使用
+//	  timeout: 10s
+//	  max_retries: 5                 # 失败重试次数，按 job_queue.retry_delay 指数退避
+//	  dead_letter_retention: 168h    # 死信保留时长，0 为永久保存
+//
+// 写入通过 gorm 回调捕获（SQL 库，需要 primary_key），与审计一样覆盖单条、批量、upsert、update_where、delete_where、
+// 导入等全部写入，每条记录一个事件；试运行与回滚的事务不投递。软删除记为 deleted，upsert 的事件为 upserted，
+// 投递给订阅了 created 或 updated 的地址。请求体：
+//
+//	{"id": "事件 ID", "event": "updated", "time": "...", "database": "test", "table": "user", "record_id": "1",
+//	 "request_id": "...", "actor": "alice", "old": {...}, "new": {...}}
+//
+// 字段按 API 名（field_aliases），hidden_fields 不发送，masked_fields 脱敏后发送。请求头带 X-Ego-Event、
+// X-Ego-Delivery（事件 ID，重试时不变，可用于去重）、X-Ego-Timestamp 与 X-Ego-Signature: sha256=<hex>，
+// 签名为 HMAC-SHA256(secret, timestamp + "." + body)。
+//
+// 每次投递是一个 webhook 类型的后台任务（/api/jobs 可查看），2xx 视为成功；重试用尽后写入 job_queue.store
+// 的死信队列（需要配置 store）。管理接口（受 operation_roles.webhooks 控制，默认 admin）：
+//
+//	GET    /api/admin/webhooks/dead_letters?database=test&table=user
+//	POST   /api/admin/webhooks/dead_letters/:id/retry     重新投递并移出死信队列
+//	DELETE /api/admin/webhooks/dead_letters/:id
+
+const (
+	jobTypeWebhook = "webhook"
+
+	webhookDeadLetterPrefix = "webhook_dead:"
+)
+
+type webhookConfig struct {
+	Secret              string        `mapstructure:"secret"`
+	Timeout             time.Duration `mapstructure:"timeout"`
+	MaxRetries          int           `mapstructure:"max_retries"`
+	DeadLetterRetention time.Duration `mapstructure:"dead_letter_retention"`
+}
+
+// tableWebhookConfig 表配置中的 webhooks
+type tableWebhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Events  []string          `mapstructure:"events"`
+	Secret  string            `mapstructure:"secret"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// subscribes 地址是否订阅了该事件
+func (h *tableWebhookConfig) subscribes(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	if event == changeUpserted {
+		return contains(h.Events, changeCreated) || contains(h.Events, changeUpdated)
+	}
+	return contains(h.Events, event)
+}
+
+// webhookDelivery 后台任务的 payload，投递时按 database、table、url 查找当前配置
+type webhookDelivery struct {
+	Database string      `json:"database"`
+	Table    string      `json:"table"`
+	URL      string      `json:"url"`
+	Event    changeEvent `json:"event"`
+}
+
+// webhookDeadLetter 重试用尽的投递
+type webhookDeadLetter struct {
+	ID       string          `json:"id"` // 任务 ID
+	FailedAt time.Time       `json:"failed_at"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Delivery webhookDelivery `json:"delivery"`
+}
+
+type webhookDispatcher struct {
+	config webhookConfig
+	client *http.Client
+	hooks  map[string][]tableWebhookConfig // 库别名.表别名 → 地址
+}
+
+// setupWebhooks 校验配置并为配置了 webhooks 的表注册回调；需在 setupJobQueue 之前，以便恢复的投递任务能找到配置
+func (dm *databaseManager) setupWebhooks() error {
+	cfg := dm.config.Webhooks
+	d := &webhookDispatcher{config: cfg, client: &http.Client{Timeout: cfg.Timeout}, hooks: map[string][]tableWebhookConfig{}}
+	for name, dbCfg := range dm.config.Databases {
+		tables := map[string]*auditTable{}
+		for i := range dbCfg.Tables {
+			tc := &dbCfg.Tables[i]
+			if len(tc.Webhooks) == 0 {
+				continue
+			}
+			for j := range tc.Webhooks {
+				h := &tc.Webhooks[j]
+				if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return fmt.Errorf("table %s.%s: invalid webhook url %q", name, tc.Alias, h.URL)
+				}
+				for _, e := range h.Events {
+					if e != changeCreated && e != changeUpdated && e != changeDeleted {
+						return fmt.Errorf("table %s.%s: invalid webhook event %q, expected created, updated or deleted", name, tc.Alias, e)
+					}
+				}
+				if h.Secret == "" {
+					h.Secret = cfg.Secret
+				}
+				if h.Secret == "" {
+					return fmt.Errorf("table %s.%s: webhook %s requires a secret (webhooks.secret)", name, tc.Alias, h.URL)
+				}
+			}
+			if tc.PrimaryKey == "" {
+				return fmt.Errorf("table %s.%s: webhooks require primary_key", name, tc.Alias)
+			}
+			tables[tc.Name] = &auditTable{dbName: name, tc: tc}
+			d.hooks[name+"."+tc.Alias] = tc.Webhooks
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		if dm.config.JobQueue.Store == "" {
+			return fmt.Errorf("webhooks require job_queue.store for the dead letter queue")
+		}
+		var dbs []*gorm.DB
+		switch a := dm.adapters[name].(type) {
+		case *gormAdapter:
+			dbs = []*gorm.DB{a.db}
+		case *shardedAdapter:
+			for _, s := range a.shards {
+				dbs = append(dbs, s.db)
+			}
+		default:
+			return fmt.Errorf("webhooks are only supported on SQL databases, %s is %s", name, dbCfg.Type)
+		}
+		for _, db := range dbs {
+			err := registerChangeFeed(db, "webhook", tables, func(tc *tableConfig, entries []auditEntry) {
+				dm.enqueueWebhooks(changeEvents(tc, entries))
+			})
+			if err != nil {
+				return fmt.Errorf("failed to set up webhooks for %s: %w", name, err)
+			}
+		}
+	}
+	dm.webhooks = d
+	return nil
+}
+
+// enqueueWebhooks 为每个事件、每个订阅的地址提交一个投递任务
+func (dm *databaseManager) enqueueWebhooks(events []changeEvent) {
+	for _, e := range events {
+		for _, h := range dm.webhooks.hooks[e.Database+"."+e.Table] {
+			if !h.subscribes(e.Event) {
+				continue
+			}
+			dm.enqueueWebhook(webhookDelivery{Database: e.Database, Table: e.Table, URL: h.URL, Event: e})
+		}
+	}
+}
+
+func (dm *databaseManager) enqueueWebhook(d webhookDelivery) (utils.Job, error) {
+	job, err := dm.jobQueue.Enqueue(jobTypeWebhook, d, utils.EnqueueOptions{MaxRetries: dm.webhooks.config.MaxRetries})
+	if err != nil {
+		log.Printf("enqueue webhook %s for %s.%s failed: %v", d.URL, d.Database, d.Table, err)
+	}
+	return job, err
+}
+
+// ---- 投递 ----
+
+func (dm *databaseManager) runWebhookJob(ctx context.Context, job utils.Job) error {
+	var d webhookDelivery
+	if err := json.Unmarshal(job.Payload, &d); err != nil {
+		return err
+	}
+	err := dm.deliverWebhook(ctx, d)
+	if err != nil && job.Attempts > job.MaxRetries && ctx.Err() == nil {
+		dm.deadLetterWebhook(webhookDeadLetter{ID: job.ID, FailedAt: time.Now(), Attempts: job.Attempts, Error: err.Error(), Delivery: d})
+	}
+	return err
+}
+
+func (dm *databaseManager) deliverWebhook(ctx context.Context, d webhookDelivery) error {
+	w := dm.webhooks
+	if w == nil {
+		return fmt.Errorf("webhooks are not set up")
+	}
+	var hook *tableWebhookConfig
+	for i, h := range w.hooks[d.Database+"."+d.Table] {
+		if h.URL == d.URL {
+			hook = &w.hooks[d.Database+"."+d.Table][i]
+			break
+		}
+	}
+	if hook == nil {
+		return fmt.Errorf("webhook %s is no longer configured for %s.%s", d.URL, d.Database, d.Table)
+	}
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ego-webhook")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("X-Ego-Event", d.Event.Event)
+	req.Header.Set("X-Ego-Delivery", d.Event.ID)
+	req.Header.Set("X-Ego-Timestamp", timestamp)
+	req.Header.Set("X-Ego-Signature", "sha256="+webhookSignature(hook.Secret, timestamp, body))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded %s", d.URL, resp.Status)
+	}
+	return nil
+}
+
+// webhookSignature HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ---- 死信队列 ----
+
+func (dm *databaseManager) deadLetterWebhook(dl webhookDeadLetter) {
+	log.Printf("webhook %s for %s.%s failed after %d attempts: %s", dl.Delivery.URL, dl.Delivery.Database, dl.Delivery.Table, dl.Attempts, dl.Error)
+	if dm.store == nil {
+		return
+	}
+	data, err := json.Marshal(dl)
+	if err != nil {
+		log.Printf("encode webhook dead letter failed: %v", err)
+		return
+	}
+	if err := dm.store.Set([]byte(webhookDeadLetterPrefix+dl.ID), data, dm.webhooks.config.DeadLetterRetention); err != nil {
+		log.Printf("write webhook dead letter failed: %v", err)
+	}
+}
+
+// deadLetterFor 读取路径参数 id 对应的死信，失败时写响应并返回 nil
+func (dm *databaseManager) deadLetterFor(c *gin.Context) *webhookDeadLetter {
+	if !dm.authorize(c, nil, opWebhooks) {
+		return nil
+	}
+	if dm.store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook dead letter queue requires job_queue.store"})
+		return nil
+	}
+	key := []byte(webhookDeadLetterPrefix + c.Param("id"))
+	ok, err := dm.store.Has(key)
+	if err == nil && !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return nil
+	}
+	var data []byte
+	if err == nil {
+		data, err = dm.store.Get(key)
+	}
+	var dl webhookDeadLetter
+	if err == nil {
+		err = json.Unmarshal(data, &dl)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read dead letter: " + err.Error()})
+		return nil
+	}
+	return &dl
+}
+
+func (dm *databaseManager) handleWebhookDeadLetters(c *gin.Context) {
+	if !dm.authorize(c, nil, opWebhooks) {
+		return
+	}
+	result := []webhookDeadLetter{}
+	if dm.store != nil {
+		dbName, table := c.Query("database"), c.Query("table")
+		err := dm.store.Scan([]byte(webhookDeadLetterPrefix), func(_, v []byte) error {
+			var dl webhookDeadLetter
+			if json.Unmarshal(v, &dl) == nil && (dbName == "" || dl.Delivery.Database == dbName) && (table == "" || dl.Delivery.Table == table) {
+				result = append(result, dl)
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read dead letters: " + err.Error()})
+			return
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FailedAt.After(result[j].FailedAt) })
+	c.JSON(http.StatusOK, gin.H{"total": len(result), "data": result})
+}
+
+func (dm *databaseManager) handleWebhookDeadLetterRetry(c *gin.Context) {
+	dl := dm.deadLetterFor(c)
+	if dl == nil {
+		return
+	}
+	job, err := dm.enqueueWebhook(dl.Delivery)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue webhook: " + err.Error()})
+		return
+	}
+	if err := dm.store.Delete([]byte(webhookDeadLetterPrefix + dl.ID)); err != nil {
+		log.Printf("delete webhook dead letter %s failed: %v", dl.ID, err)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID})
+}
+
+func (dm *databaseManager) handleWebhookDeadLetterDelete(c *gin.Context) {
+	dl := dm.deadLetterFor(c)
+	if dl == nil {
+		return
+	}
+	if err := dm.store.Delete([]byte(webhookDeadLetterPrefix + dl.ID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dead letter: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dead letter deleted"})
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -15,14 +17,33 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTests(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
 	port := 8080
 	cfgs := "./cfgs"
 
+	fs := flag.NewFlagSet("ego", flag.ExitOnError)
+	refreshMeta := fs.Bool("refresh-meta", false, "ignore the schema cache and extract metadata from every database")
+	fs.Parse(os.Args[1:])
+	if *refreshMeta {
+		if err := apix.ClearMetaCache(cfgs); err != nil {
+			fmt.Println("Clear meta cache failed:", err)
+			os.Exit(1)
+		}
+	}
+
 	// 创建 Gin 引擎
 	router := gin.Default()
+	// 不信任 X-Forwarded-For，ClientIP 为连接的对端地址；限流经代理部署时配置 rate_limit.trusted_proxies
+	router.SetTrustedProxies(nil)
 
 	// 注册Restful Graphql API
-	apix.RegisterRestfulAndGraphql(router, cfgs, port)
+	handle := apix.RegisterRestfulAndGraphql(router, cfgs, port)
 
 	// 创建服务器实例
 	server := &http.Server{
@@ -41,6 +62,19 @@ func main() {
 		}
 	}()
 
+	// SIGHUP 重新加载配置，校验未通过时继续使用原配置
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			if err := handle.Reload(); err != nil {
+				fmt.Println("Reload failed, keeping current config:", err)
+			} else {
+				fmt.Println("Config reloaded")
+			}
+		}
+	}()
+
 	// 等待终止信号
 	<-stopChan
 
@@ -53,6 +87,51 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		fmt.Println("Server forced to shutdown:", err)
 	}
+	if err := handle.Close(); err != nil {
+		fmt.Println("Close failed:", err)
+	}
 
 	fmt.Println("Server gracefully stopped")
 }
+
+// runTests 执行 ego test [-cfgs dir] [-mongo uri] [-v] [场景文件或目录...]
+func runTests(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	cfgs := fs.String("cfgs", "./cfgs", "config directory")
+	mongoURI := fs.String("mongo", "", "MongoDB server for mongodb databases, disabled when empty")
+	verbose := fs.Bool("v", false, "print every step and server logs")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{filepath.Join(*cfgs, "tests")}
+	}
+	_, failed, err := apix.RunTests(apix.TestOptions{Cfgs: *cfgs, MongoURI: *mongoURI, Verbose: *verbose}, paths)
+	if err != nil {
+		fmt.Println("Test failed:", err)
+		return 2
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runExport 执行 ego export [-cfgs dir] [-out dir] [-prefix /api/rest]，导出 GraphQL SDL 与各表 JSON Schema
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cfgs := fs.String("cfgs", "./cfgs", "config directory")
+	out := fs.String("out", "./schemas", "output directory")
+	prefix := fs.String("prefix", "", "REST prefix used in the generated swagger, default /api/rest")
+	fs.Parse(args)
+
+	files, err := apix.ExportSchemas(*cfgs, *out, *prefix)
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	if err != nil {
+		fmt.Println("Export failed:", err)
+		return 1
+	}
+	return 0
+}
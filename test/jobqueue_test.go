@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"ego/utils"
+)
+
+func waitJob(t *testing.T, q *utils.JobQueue, id string, status string) utils.Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := q.Get(id); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	job, _ := q.Get(id)
+	t.Fatalf("job %s status %s, want %s", id, job.Status, status)
+	return job
+}
+
+func TestJobQueue_Priority(t *testing.T) {
+	q := utils.NewJobQueue(nil, 1)
+	var mu sync.Mutex
+	var order []string
+	q.Register("p", func(ctx context.Context, job utils.Job) error {
+		mu.Lock()
+		order = append(order, job.ID)
+		mu.Unlock()
+		return nil
+	}, 0)
+
+	// 启动前入队，保证按优先级而不是入队顺序执行
+	_, err := q.Enqueue("p", nil, utils.EnqueueOptions{ID: "low", Priority: 1})
+	assert.NoError(t, err)
+	_, err = q.Enqueue("p", nil, utils.EnqueueOptions{ID: "high", Priority: 10})
+	assert.NoError(t, err)
+	_, err = q.Enqueue("p", nil, utils.EnqueueOptions{ID: "mid", Priority: 5})
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.Start())
+	defer q.Stop()
+	waitJob(t, q, "low", utils.JobCompleted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"high", "mid", "low"}, order)
+}
+
+func TestJobQueue_Retry(t *testing.T) {
+	q := utils.NewJobQueue(nil, 2)
+	q.SetRetryDelay(10 * time.Millisecond)
+	var calls int32
+	q.Register("flaky", func(ctx context.Context, job utils.Job) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, 0)
+	q.Register("broken", func(ctx context.Context, job utils.Job) error {
+		return errors.New("always")
+	}, 0)
+	assert.NoError(t, q.Start())
+	defer q.Stop()
+
+	_, err := q.Enqueue("flaky", map[string]int{"n": 1}, utils.EnqueueOptions{ID: "flaky", MaxRetries: 3})
+	assert.NoError(t, err)
+	_, err = q.Enqueue("broken", nil, utils.EnqueueOptions{ID: "broken", MaxRetries: 1})
+	assert.NoError(t, err)
+
+	job := waitJob(t, q, "flaky", utils.JobCompleted)
+	assert.Equal(t, 3, job.Attempts)
+
+	job = waitJob(t, q, "broken", utils.JobFailed)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "always", job.LastError)
+
+	// 失败任务可手动重试
+	assert.NoError(t, q.Retry("broken"))
+	waitJob(t, q, "broken", utils.JobFailed)
+	assert.ErrorIs(t, q.Retry("flaky"), utils.ErrJobNotRetryable)
+}
+
+func TestJobQueue_ConcurrencyLimit(t *testing.T) {
+	q := utils.NewJobQueue(nil, 4)
+	var running, maxRunning int32
+	q.Register("limited", func(ctx context.Context, job utils.Job) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}, 2)
+	assert.NoError(t, q.Start())
+	defer q.Stop()
+
+	var last utils.Job
+	for i := 0; i < 6; i++ {
+		job, err := q.Enqueue("limited", i, utils.EnqueueOptions{})
+		assert.NoError(t, err)
+		last = job
+	}
+	waitJob(t, q, last.ID, utils.JobCompleted)
+	assert.Len(t, q.List("limited", utils.JobCompleted), 6)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+}
+
+func TestJobQueue_Cancel(t *testing.T) {
+	q := utils.NewJobQueue(nil, 1)
+	started := make(chan struct{})
+	q.Register("long", func(ctx context.Context, job utils.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, 0)
+	assert.NoError(t, q.Start())
+	defer q.Stop()
+
+	_, err := q.Enqueue("long", nil, utils.EnqueueOptions{ID: "running"})
+	assert.NoError(t, err)
+	_, err = q.Enqueue("long", nil, utils.EnqueueOptions{ID: "queued"})
+	assert.NoError(t, err)
+	<-started
+
+	assert.NoError(t, q.Cancel("queued"))
+	assert.NoError(t, q.Cancel("running"))
+	waitJob(t, q, "running", utils.JobCancelled)
+	waitJob(t, q, "queued", utils.JobCancelled)
+	assert.ErrorIs(t, q.Cancel("running"), utils.ErrJobNotCancellable)
+	assert.ErrorIs(t, q.Cancel("missing"), utils.ErrJobNotFound)
+}
+
+func TestJobQueue_Persistence(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "jobqueue_test")
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	kv, err := utils.Open(path)
+	assert.NoError(t, err)
+	q := utils.NewJobQueue(kv, 1)
+	q.Register("later", func(ctx context.Context, job utils.Job) error { return nil }, 0)
+	_, err = q.Enqueue("later", map[string]string{"k": "v"}, utils.EnqueueOptions{ID: "persisted", Delay: time.Hour})
+	assert.NoError(t, err)
+	assert.NoError(t, q.SetProgress("persisted", map[string]int{"done": 3}))
+	assert.NoError(t, q.Start())
+	q.Stop()
+	assert.NoError(t, kv.Close())
+
+	kv, err = utils.Open(path)
+	assert.NoError(t, err)
+	defer kv.Close()
+	q = utils.NewJobQueue(kv, 1)
+	assert.NoError(t, q.Start())
+	defer q.Stop()
+	job, ok := q.Get("persisted")
+	assert.True(t, ok)
+	assert.Equal(t, utils.JobPending, job.Status)
+	assert.JSONEq(t, `{"k":"v"}`, string(job.Payload))
+	assert.JSONEq(t, `{"done":3}`, string(job.Progress))
+}
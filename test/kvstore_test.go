@@ -1,15 +1,18 @@
 package test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/stretchr/testify/assert"
 
-	"gogo/utils"
+	"ego/utils"
 )
 
 func TestKVStore_SetGetDelete(t *testing.T) {
@@ -74,3 +77,45 @@ func TestKVStore_TTL(t *testing.T) {
 	val, err = kv.Get(key)
 	assert.ErrorIs(t, err, badger.ErrKeyNotFound)
 }
+
+func TestKVStore_Update(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "badger_test_update")
+	defer os.RemoveAll(path)
+
+	kv, err := utils.Open(path)
+	assert.NoError(t, err)
+	defer kv.Close()
+
+	key := []byte("counter")
+	incr := func(old []byte, found bool) ([]byte, time.Duration, error) {
+		n := 0
+		if found {
+			n, _ = strconv.Atoi(string(old))
+		}
+		return []byte(strconv.Itoa(n + 1)), 0, nil
+	}
+
+	// 并发递增
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, kv.Update(key, incr))
+		}()
+	}
+	wg.Wait()
+
+	val, err := kv.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "20", string(val))
+
+	// fn 返回错误时不写入
+	err = kv.Update(key, func(old []byte, found bool) ([]byte, time.Duration, error) {
+		return nil, 0, errors.New("abort")
+	})
+	assert.EqualError(t, err, "abort")
+	val, err = kv.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "20", string(val))
+}
@@ -7,7 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	"gogo/utils"
+	"ego/utils"
 )
 
 func TestScheduler_AddAndRemoveJob(t *testing.T) {
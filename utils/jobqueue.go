@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const jobKeyPrefix = "job:"
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobTypeNotFound   = errors.New("job type not registered")
+	ErrJobNotCancellable = errors.New("job already finished")
+	ErrJobNotRetryable   = errors.New("only failed or cancelled jobs can be retried")
+)
+
+// Job 队列中的任务
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Priority   int             `json:"priority"` // 越大越先执行
+	Status     string          `json:"status"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Progress   json.RawMessage `json:"progress,omitempty"`
+	Attempts   int             `json:"attempts"`
+	MaxRetries int             `json:"max_retries"`
+	LastError  string          `json:"last_error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	RunAt      time.Time       `json:"run_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+
+	cancelRequested bool
+}
+
+func (j *Job) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// JobHandler 任务处理函数，ctx 在任务被取消或队列停止时取消
+type JobHandler func(ctx context.Context, job Job) error
+
+type jobType struct {
+	handler JobHandler
+	limit   int
+	running int
+}
+
+// JobQueue 带优先级、重试与并发限制的任务队列。
+// store 不为 nil 时任务持久化到 KVStore，重启后未完成的任务继续执行。
+type JobQueue struct {
+	store      *KVStore
+	workers    int
+	retention  time.Duration
+	retryDelay time.Duration
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	types    map[string]*jobType
+	cancels  map[string]context.CancelFunc
+	active   int
+	seq      int64
+	notify   chan struct{}
+	ctx      context.Context
+	stop     context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewJobQueue 创建任务队列，workers 为全局并发上限
+func NewJobQueue(store *KVStore, workers int) *JobQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobQueue{
+		store:      store,
+		workers:    workers,
+		retryDelay: time.Second,
+		jobs:       make(map[string]*Job),
+		types:      make(map[string]*jobType),
+		cancels:    make(map[string]context.CancelFunc),
+		notify:     make(chan struct{}, 1),
+		ctx:        ctx,
+		stop:       cancel,
+	}
+}
+
+// SetRetention 设置已结束任务的保留时长，<= 0 表示一直保留
+func (q *JobQueue) SetRetention(d time.Duration) {
+	q.mu.Lock()
+	q.retention = d
+	q.mu.Unlock()
+}
+
+// SetRetryDelay 设置重试的基础间隔，第 n 次重试等待 delay * 2^(n-1)
+func (q *JobQueue) SetRetryDelay(d time.Duration) {
+	q.mu.Lock()
+	q.retryDelay = d
+	q.mu.Unlock()
+}
+
+// Register 注册任务类型，concurrency 为该类型的并发上限（<= 0 表示只受全局限制）
+func (q *JobQueue) Register(typ string, handler JobHandler, concurrency int) {
+	q.mu.Lock()
+	q.types[typ] = &jobType{handler: handler, limit: concurrency}
+	q.mu.Unlock()
+	q.wake()
+}
+
+// EnqueueOptions 入队参数
+type EnqueueOptions struct {
+	ID         string
+	Priority   int
+	MaxRetries int
+	Delay      time.Duration
+}
+
+// Enqueue 添加任务，payload 会被序列化为 JSON
+func (q *JobQueue) Enqueue(typ string, payload interface{}, opts EnqueueOptions) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now()
+	q.mu.Lock()
+	if _, ok := q.types[typ]; !ok {
+		q.mu.Unlock()
+		return Job{}, ErrJobTypeNotFound
+	}
+	id := opts.ID
+	if id == "" {
+		q.seq++
+		id = now.Format("20060102150405.000000") + "-" + strconv.FormatInt(q.seq, 10)
+	}
+	job := &Job{
+		ID:         id,
+		Type:       typ,
+		Priority:   opts.Priority,
+		Status:     JobPending,
+		Payload:    data,
+		MaxRetries: opts.MaxRetries,
+		CreatedAt:  now,
+		RunAt:      now.Add(opts.Delay),
+	}
+	q.jobs[id] = job
+	q.pruneLocked(now)
+	err = q.persistLocked(job)
+	snapshot := *job
+	q.mu.Unlock()
+	q.wake()
+	return snapshot, err
+}
+
+// Start 加载持久化的任务并启动调度
+func (q *JobQueue) Start() error {
+	if err := q.load(); err != nil {
+		return err
+	}
+	q.wg.Add(1)
+	go q.loop()
+	return nil
+}
+
+// Stop 停止调度并取消执行中的任务，等待其退出
+func (q *JobQueue) Stop() {
+	q.stopOnce.Do(func() {
+		q.stop()
+		q.wg.Wait()
+	})
+}
+
+// Get 返回任务快照
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List 按创建时间倒序返回任务，typ/status 为空表示不过滤
+func (q *JobQueue) List(typ, status string) []Job {
+	q.mu.Lock()
+	result := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		if (typ == "" || job.Type == typ) && (status == "" || job.Status == status) {
+			result = append(result, *job)
+		}
+	}
+	q.mu.Unlock()
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].CreatedAt.Equal(result[b].CreatedAt) {
+			return result[a].ID > result[b].ID
+		}
+		return result[a].CreatedAt.After(result[b].CreatedAt)
+	})
+	return result
+}
+
+// Cancel 取消任务：排队中的直接取消，执行中的通知其 ctx
+func (q *JobQueue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	switch job.Status {
+	case JobPending:
+		q.finishLocked(job, JobCancelled, "")
+		return q.persistLocked(job)
+	case JobRunning:
+		job.cancelRequested = true
+		if cancel, ok := q.cancels[id]; ok {
+			cancel()
+		}
+		return nil
+	default:
+		return ErrJobNotCancellable
+	}
+}
+
+// Retry 重新排队失败或已取消的任务
+func (q *JobQueue) Retry(id string) error {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if job.Status != JobFailed && job.Status != JobCancelled {
+		q.mu.Unlock()
+		return ErrJobNotRetryable
+	}
+	job.Status = JobPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.FinishedAt = nil
+	job.cancelRequested = false
+	err := q.persistLocked(job)
+	q.mu.Unlock()
+	q.wake()
+	return err
+}
+
+// SetProgress 更新任务进度（序列化为 JSON），供处理函数汇报
+func (q *JobQueue) SetProgress(id string, progress interface{}) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.Progress = data
+	return q.persistLocked(job)
+}
+
+func (q *JobQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) loop() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		q.dispatch()
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *JobQueue) dispatch() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.active < q.workers && q.ctx.Err() == nil {
+		job := q.nextLocked(time.Now())
+		if job == nil {
+			return
+		}
+		q.startLocked(job)
+	}
+}
+
+// nextLocked 选出可执行的任务：优先级高者优先，同优先级先入先出
+func (q *JobQueue) nextLocked(now time.Time) *Job {
+	var best *Job
+	for _, job := range q.jobs {
+		if job.Status != JobPending || job.RunAt.After(now) {
+			continue
+		}
+		t, ok := q.types[job.Type]
+		if !ok || (t.limit > 0 && t.running >= t.limit) {
+			continue
+		}
+		if best == nil || job.Priority > best.Priority ||
+			(job.Priority == best.Priority && (job.CreatedAt.Before(best.CreatedAt) ||
+				(job.CreatedAt.Equal(best.CreatedAt) && job.ID < best.ID))) {
+			best = job
+		}
+	}
+	return best
+}
+
+func (q *JobQueue) startLocked(job *Job) {
+	t := q.types[job.Type]
+	now := time.Now()
+	job.Status = JobRunning
+	job.Attempts++
+	job.StartedAt = &now
+	q.persistLocked(job)
+	t.running++
+	q.active++
+	ctx, cancel := context.WithCancel(q.ctx)
+	q.cancels[job.ID] = cancel
+	snapshot := *job
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		err := runJobHandler(ctx, t.handler, snapshot)
+		cancel()
+		q.complete(job, t, err)
+	}()
+}
+
+func runJobHandler(ctx context.Context, handler JobHandler, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (q *JobQueue) complete(job *Job, t *jobType, err error) {
+	q.mu.Lock()
+	t.running--
+	q.active--
+	delete(q.cancels, job.ID)
+	switch {
+	case job.cancelRequested:
+		q.finishLocked(job, JobCancelled, "")
+	case err == nil:
+		q.finishLocked(job, JobCompleted, "")
+	case q.ctx.Err() != nil:
+		// 队列停止导致的中断，按重试规则决定下次启动时是否继续
+		q.retryOrFailLocked(job, "interrupted: "+err.Error())
+	default:
+		q.retryOrFailLocked(job, err.Error())
+	}
+	q.persistLocked(job)
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *JobQueue) retryOrFailLocked(job *Job, msg string) {
+	job.LastError = msg
+	if job.Attempts <= job.MaxRetries {
+		job.Status = JobPending
+		job.RunAt = time.Now().Add(q.retryDelay << (job.Attempts - 1))
+		return
+	}
+	q.finishLocked(job, JobFailed, msg)
+}
+
+func (q *JobQueue) finishLocked(job *Job, status, msg string) {
+	now := time.Now()
+	job.Status = status
+	if msg != "" {
+		job.LastError = msg
+	}
+	job.FinishedAt = &now
+}
+
+// pruneLocked 清理超过保留期的已结束任务
+func (q *JobQueue) pruneLocked(now time.Time) {
+	if q.retention <= 0 {
+		return
+	}
+	deadline := now.Add(-q.retention)
+	for id, job := range q.jobs {
+		if job.Finished() && job.FinishedAt != nil && job.FinishedAt.Before(deadline) {
+			delete(q.jobs, id)
+			if q.store != nil {
+				q.store.Delete([]byte(jobKeyPrefix + id))
+			}
+		}
+	}
+}
+
+func (q *JobQueue) persistLocked(job *Job) error {
+	if q.store == nil {
+		return nil
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.store.Set([]byte(jobKeyPrefix+job.ID), data, 0)
+}
+
+// load 从 KVStore 恢复任务；上次中断时仍在执行的任务按重试规则处理
+func (q *JobQueue) load() error {
+	if q.store == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.store.Scan([]byte(jobKeyPrefix), func(key, value []byte) error {
+		var job Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return nil
+		}
+		if job.Status == JobRunning {
+			q.retryOrFailLocked(&job, "interrupted by restart")
+			q.persistLocked(&job)
+		}
+		q.jobs[job.ID] = &job
+		return nil
+	})
+}
@@ -76,3 +76,56 @@ func (kv *KVStore) Has(key []byte) (bool, error) {
 
 	return err == nil, err
 }
+
+// Scan 按前缀遍历 key，fn 返回错误时停止遍历
+func (kv *KVStore) Scan(prefix []byte, fn func(key, value []byte) error) error {
+	return kv.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(item.KeyCopy(nil), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update 原子地读改写 key：fn 收到旧值（不存在或已过期时 found 为 false），返回新值与 TTL（<= 0 为永久保存）。
+// 并发写入冲突时重试，fn 可能被调用多次
+func (kv *KVStore) Update(key []byte, fn func(old []byte, found bool) ([]byte, time.Duration, error)) error {
+	for {
+		err := kv.db.Update(func(txn *badger.Txn) error {
+			var old []byte
+			found := false
+			item, err := txn.Get(key)
+			switch err {
+			case nil:
+				if old, err = item.ValueCopy(nil); err != nil {
+					return err
+				}
+				found = true
+			case badger.ErrKeyNotFound:
+			default:
+				return err
+			}
+			val, ttl, err := fn(old, found)
+			if err != nil {
+				return err
+			}
+			entry := badger.NewEntry(key, val)
+			if ttl > 0 {
+				entry = entry.WithTTL(ttl)
+			}
+			return txn.SetEntry(entry)
+		})
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}